@@ -24,14 +24,72 @@ type DentryCache struct {
 	sync.Mutex
 	cache      map[string]uint64
 	expiration time.Time
+	ttl        time.Duration
+
+	// names known not to exist, with the time they expire at
+	negative    map[string]time.Time
+	negativeTTL time.Duration
 }
 
 // NewDentryCache returns a new dentry cache.
 func NewDentryCache() *DentryCache {
+	return NewDentryCacheWithTTL(DentryValidDuration)
+}
+
+// NewDentryCacheWithTTL returns a new dentry cache whose items are valid for ttl.
+func NewDentryCacheWithTTL(ttl time.Duration) *DentryCache {
 	return &DentryCache{
 		cache:      make(map[string]uint64),
-		expiration: time.Now().Add(DentryValidDuration),
+		expiration: time.Now().Add(ttl),
+		ttl:        ttl,
+		negative:   make(map[string]time.Time),
+	}
+}
+
+// SetNegativeTTL sets how long a name stays known not to exist, 0 disables the
+// negative entries.
+func (dc *DentryCache) SetNegativeTTL(ttl time.Duration) {
+	if dc == nil {
+		return
+	}
+	dc.Lock()
+	defer dc.Unlock()
+	dc.negativeTTL = ttl
+	if ttl == 0 {
+		dc.negative = make(map[string]time.Time)
+	}
+}
+
+// PutNegative records that the name does not exist.
+func (dc *DentryCache) PutNegative(name string) {
+	if dc == nil {
+		return
+	}
+	dc.Lock()
+	defer dc.Unlock()
+	if dc.negativeTTL == 0 {
+		return
+	}
+	delete(dc.cache, name)
+	dc.negative[name] = time.Now().Add(dc.negativeTTL)
+}
+
+// IsNegative returns if the name is known not to exist.
+func (dc *DentryCache) IsNegative(name string) bool {
+	if dc == nil {
+		return false
+	}
+	dc.Lock()
+	defer dc.Unlock()
+	expiration, ok := dc.negative[name]
+	if !ok {
+		return false
+	}
+	if expiration.Before(time.Now()) {
+		delete(dc.negative, name)
+		return false
 	}
+	return true
 }
 
 // Put puts an item into the cache.
@@ -42,7 +100,8 @@ func (dc *DentryCache) Put(name string, ino uint64) {
 	dc.Lock()
 	defer dc.Unlock()
 	dc.cache[name] = ino
-	dc.expiration = time.Now().Add(DentryValidDuration)
+	delete(dc.negative, name)
+	dc.expiration = time.Now().Add(dc.ttl)
 }
 
 // Get gets the item from the cache based on the given key.
@@ -69,4 +128,5 @@ func (dc *DentryCache) Delete(name string) {
 	dc.Lock()
 	defer dc.Unlock()
 	delete(dc.cache, name)
+	delete(dc.negative, name)
 }
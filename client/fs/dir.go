@@ -884,8 +884,7 @@ func (d *Dir) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
 		log.LogErrorf("Set 'DirStat' is not supported.")
 		return fuse.ENOSYS
 	}
-	// TODO： implement flag to improve compatible (Mofei Zhang)
-	if err = d.super.mw.XAttrSet_ll(ino, []byte(name), []byte(value)); err != nil {
+	if err = d.super.mw.XAttrSetFlags_ll(ino, []byte(name), []byte(value), int(req.Flags)); err != nil {
 		log.LogErrorf("Setxattr: ino(%v) name(%v) err(%v)", ino, name, err)
 		return ParseError(err)
 	}
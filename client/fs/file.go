@@ -694,8 +694,7 @@ func (f *File) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
 	ino := f.info.Inode
 	name := req.Name
 	value := req.Xattr
-	// TODO： implement flag to improve compatible (Mofei Zhang)
-	if err = f.super.mw.XAttrSet_ll(ino, []byte(name), []byte(value)); err != nil {
+	if err = f.super.mw.XAttrSetFlags_ll(ino, []byte(name), []byte(value), int(req.Flags)); err != nil {
 		log.LogErrorf("Setxattr: ino(%v) name(%v) err(%v)", ino, name, err)
 		return ParseError(err)
 	}
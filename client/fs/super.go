@@ -236,6 +236,10 @@ func NewSuper(opt *proto.MountOptions) (s *Super, err error) {
 
 		DisableMetaCache:             DisableMetaCache,
 		MinWriteAbleDataPartitionCnt: opt.MinWriteAbleDataPartitionCnt,
+		WriteReplyTimeout:            time.Duration(opt.WriteReplyTimeout) * time.Millisecond,
+		MaxRecoverBackoff:            time.Duration(opt.MaxRecoverBackoff) * time.Millisecond,
+		ExtentHandlerBufferSize:      opt.ExtentHandlerBufferSize,
+		FlushConcurrency:             opt.FlushConcurrency,
 	}
 
 	s.ec, err = stream.NewExtentClient(extentConfig)
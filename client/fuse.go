@@ -93,6 +93,9 @@ const (
 	DefaultUDSName       = "/tmp/CubeFS-fdstore.sock"
 
 	DefaultLogPath = "/var/log/cubefs"
+
+	godebugEnv      = "GODEBUG"
+	madvDontNeedEnv = "CFS_MADVDONTNEED" // set to 0 to keep the madvise behavior of go runtime
 )
 
 var (
@@ -524,11 +527,7 @@ func startDaemon() error {
 		}
 	}
 
-	env := os.Environ()
-
-	// add GODEBUG=madvdontneed=1 environ, to make sysUnused uses madvise(MADV_DONTNEED) to signal the kernel that a
-	// range of allocated memory contains unneeded data.
-	env = append(env, "GODEBUG=madvdontneed=1")
+	env := daemonEnv(os.Environ())
 	err = daemonize.Run(cmdPath, args, env, os.Stdout)
 	if err != nil {
 		return fmt.Errorf("startDaemon failed: daemon start failed, cmd(%v) args(%v) env(%v) err(%v)\n", cmdPath, args, env, err)
@@ -537,6 +536,45 @@ func startDaemon() error {
 	return nil
 }
 
+// daemonEnv returns the environ of the daemon process.
+//
+// GODEBUG=madvdontneed=1 is added, to make sysUnused uses madvise(MADV_DONTNEED) to signal the kernel that a
+// range of allocated memory contains unneeded data. It is merged into the GODEBUG already set instead of
+// replacing it, an explicit madvdontneed in GODEBUG is kept, and CFS_MADVDONTNEED=0 opts out of it.
+func daemonEnv(environ []string) []string {
+	env := make([]string, 0, len(environ)+1)
+	godebug := ""
+	optOut := false
+	for _, kv := range environ {
+		switch {
+		case strings.HasPrefix(kv, godebugEnv+"="):
+			godebug = strings.TrimPrefix(kv, godebugEnv+"=")
+			continue
+		case strings.HasPrefix(kv, madvDontNeedEnv+"="):
+			v := strings.TrimPrefix(kv, madvDontNeedEnv+"=")
+			optOut = v == "0" || strings.EqualFold(v, "false")
+		}
+		env = append(env, kv)
+	}
+
+	setByUser := false
+	for _, opt := range strings.Split(godebug, ",") {
+		if strings.HasPrefix(opt, "madvdontneed=") {
+			setByUser = true
+		}
+	}
+	if !setByUser && !optOut {
+		if godebug != "" {
+			godebug += ","
+		}
+		godebug += "madvdontneed=1"
+	}
+	if godebug != "" {
+		env = append(env, godebugEnv+"="+godebug)
+	}
+	return env
+}
+
 func waitListenAndServe(statusCh chan error, addr string, handler http.Handler) {
 	var err error
 	var loop int = 0
@@ -785,6 +823,10 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 	opt.RequestTimeout = GlobalMountOptions[proto.RequestTimeout].GetInt64()
 	opt.MinWriteAbleDataPartitionCnt = int(GlobalMountOptions[proto.MinWriteAbleDataPartitionCnt].GetInt64())
 	opt.FileSystemName = GlobalMountOptions[proto.FileSystemName].GetString()
+	opt.WriteReplyTimeout = GlobalMountOptions[proto.WriteReplyTimeout].GetInt64()
+	opt.MaxRecoverBackoff = GlobalMountOptions[proto.MaxRecoverBackoff].GetInt64()
+	opt.ExtentHandlerBufferSize = int(GlobalMountOptions[proto.ExtentHandlerBufferSize].GetInt64())
+	opt.FlushConcurrency = int(GlobalMountOptions[proto.FlushConcurrency].GetInt64())
 
 	if opt.MountPoint == "" || opt.Volname == "" || opt.Owner == "" || opt.Master == "" {
 		return nil, errors.New(fmt.Sprintf("invalid config file: lack of mandatory fields, mountPoint(%v), volName(%v), owner(%v), masterAddr(%v)", opt.MountPoint, opt.Volname, opt.Owner, opt.Master))
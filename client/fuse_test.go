@@ -0,0 +1,41 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemonEnvMadvDontNeed(t *testing.T) {
+	// standalone default
+	env := daemonEnv([]string{"PATH=/bin"})
+	require.Equal(t, []string{"PATH=/bin", "GODEBUG=madvdontneed=1"}, env)
+
+	// merged into the GODEBUG of the user
+	env = daemonEnv([]string{"GODEBUG=gctrace=1", "PATH=/bin"})
+	require.Equal(t, []string{"PATH=/bin", "GODEBUG=gctrace=1,madvdontneed=1"}, env)
+
+	// explicit madvdontneed of the user is kept
+	env = daemonEnv([]string{"GODEBUG=madvdontneed=0,gctrace=1"})
+	require.Equal(t, []string{"GODEBUG=madvdontneed=0,gctrace=1"}, env)
+
+	// disabled
+	env = daemonEnv([]string{"CFS_MADVDONTNEED=0", "PATH=/bin"})
+	require.Equal(t, []string{"CFS_MADVDONTNEED=0", "PATH=/bin"}, env)
+	env = daemonEnv([]string{"CFS_MADVDONTNEED=false", "GODEBUG=gctrace=1"})
+	require.Equal(t, []string{"CFS_MADVDONTNEED=false", "GODEBUG=gctrace=1"}, env)
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	syslog "log"
+	"math"
 	"os"
 	"path"
 	"regexp"
@@ -31,6 +32,7 @@ import (
 	"golang.org/x/time/rate"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/loadutil"
 	"github.com/cubefs/cubefs/util/log"
@@ -297,6 +299,38 @@ func (d *Disk) doBackendTask() {
 	}
 }
 
+// doScrubTask checks the extents of the partitions of the disk against their block
+// crcs, reading at most scrubRate bytes per second for the whole disk. The corrupt
+// extents found are reported, and kept by the extent store for the repair.
+func (d *Disk) doScrubTask() {
+	scrubRate := d.dataNode.scrubRate
+	limiter := rate.NewLimiter(rate.Limit(scrubRate), int(math.Max(float64(scrubRate), util.BlockSize)))
+	for {
+		partitions := make([]*DataPartition, 0)
+		d.RLock()
+		for _, dp := range d.partitionMap {
+			partitions = append(partitions, dp)
+		}
+		d.RUnlock()
+		for _, dp := range partitions {
+			if d.Status == proto.Unavailable || dp.Status() == proto.Unavailable {
+				continue
+			}
+			corrupt, err := dp.extentStore.ScrubExtents(context.Background(), d.dataNode.scrubInterval, limiter)
+			if err != nil {
+				log.LogWarnf("action[doScrubTask] disk %v partition %v err %v", d.Path, dp.partitionID, err)
+			}
+			for extentID, blocks := range corrupt {
+				msg := fmt.Sprintf("disk %v partition %v extent %v blocks %v crc mismatch on %v",
+					d.Path, dp.partitionID, extentID, blocks, LocalIP)
+				exporter.Warning(msg)
+				log.LogErrorf("action[doScrubTask] %v", msg)
+			}
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
 const (
 	DiskStatusFile = ".diskStatus"
 )
@@ -62,6 +62,7 @@ const (
 
 type DataPartitionMetadata struct {
 	VolumeID                string
+	VolID                   uint64
 	PartitionID             uint64
 	PartitionSize           int
 	PartitionType           int
@@ -107,6 +108,7 @@ type MetaMultiSnapshotInfo struct {
 type DataPartition struct {
 	clusterID       string
 	volumeID        string
+	volID           uint64 // ID of the vol, 0 until it is known
 	partitionID     uint64
 	partitionStatus int
 	partitionSize   int
@@ -133,6 +135,7 @@ type DataPartition struct {
 	stopRaftC chan uint64
 	storeC    chan uint64
 	stopC     chan bool
+	repairC   chan struct{} // signaled when a replica missed a write of a normal extent
 
 	raftStatus int32
 
@@ -210,6 +213,11 @@ func (dp *DataPartition) IsEquareCreateDataPartitionRequst(request *proto.Create
 	return
 }
 
+// getVolID returns the ID of the vol of the partition, 0 if it is not known yet.
+func (dp *DataPartition) getVolID() uint64 {
+	return atomic.LoadUint64(&dp.volID)
+}
+
 func (dp *DataPartition) ForceSetDataPartitionToLoadding() {
 	atomic.StoreInt32(&dp.isLoadingDataPartition, 1)
 }
@@ -246,6 +254,7 @@ func LoadDataPartition(partitionDir string, disk *Disk) (dp *DataPartition, err
 
 	dpCfg := &dataPartitionCfg{
 		VolName:       meta.VolumeID,
+		VolID:         meta.VolID,
 		PartitionSize: meta.PartitionSize,
 		PartitionType: meta.PartitionType,
 		PartitionID:   meta.PartitionID,
@@ -306,6 +315,7 @@ func newDataPartition(dpCfg *dataPartitionCfg, disk *Disk, isCreate bool) (dp *D
 
 	partition := &DataPartition{
 		volumeID:                dpCfg.VolName,
+		volID:                   dpCfg.VolID,
 		clusterID:               dpCfg.ClusterID,
 		partitionID:             partitionID,
 		replicaNum:              dpCfg.ReplicaNum,
@@ -318,6 +328,7 @@ func newDataPartition(dpCfg *dataPartitionCfg, disk *Disk, isCreate bool) (dp *D
 		stopC:                   make(chan bool, 0),
 		stopRaftC:               make(chan uint64, 0),
 		storeC:                  make(chan uint64, 128),
+		repairC:                 make(chan struct{}, 1),
 		snapshot:                make([]*proto.File, 0),
 		partitionStatus:         proto.ReadWrite,
 		config:                  dpCfg,
@@ -554,6 +565,7 @@ func (dp *DataPartition) PersistMetadata() (err error) {
 
 	md := &DataPartitionMetadata{
 		VolumeID:                dp.config.VolName,
+		VolID:                   dp.getVolID(),
 		PartitionID:             dp.config.PartitionID,
 		ReplicaNum:              dp.config.ReplicaNum,
 		PartitionSize:           dp.config.PartitionSize,
@@ -575,6 +587,19 @@ func (dp *DataPartition) PersistMetadata() (err error) {
 	err = os.Rename(fileName, path.Join(dp.Path(), DataPartitionMetadataFileName))
 	return
 }
+
+// setVolName persists the name of the vol after it is renamed on the master.
+func (dp *DataPartition) setVolName(volName string) (err error) {
+	oldName := dp.config.VolName
+	dp.config.VolName = volName
+	if err = dp.PersistMetadata(); err != nil {
+		dp.config.VolName = oldName
+		return
+	}
+	dp.volumeID = volName
+	return
+}
+
 func (dp *DataPartition) statusUpdateScheduler() {
 	ticker := time.NewTicker(time.Minute)
 	snapshotTicker := time.NewTicker(time.Minute * 5)
@@ -599,6 +624,8 @@ func (dp *DataPartition) statusUpdateScheduler() {
 			} else {
 				dp.LaunchRepair(proto.NormalExtentType)
 			}
+		case <-dp.repairC:
+			dp.LaunchRepair(proto.NormalExtentType)
 		case <-snapshotTicker.C:
 			dp.ReloadSnapshot()
 		case <-dp.stopC:
@@ -710,6 +737,15 @@ func (dp *DataPartition) String() (m string) {
 	return fmt.Sprintf(DataPartitionPrefix+"_%v_%v", dp.partitionID, dp.partitionSize)
 }
 
+// triggerNormalExtentRepair makes the leader repair the normal extents without
+// waiting for the next scheduled repair, it does not block.
+func (dp *DataPartition) triggerNormalExtentRepair() {
+	select {
+	case dp.repairC <- struct{}{}:
+	default:
+	}
+}
+
 // LaunchRepair launches the repair of extents.
 func (dp *DataPartition) LaunchRepair(extentType uint8) {
 	if dp.partitionStatus == proto.Unavailable {
@@ -786,6 +822,10 @@ func (dp *DataPartition) fetchReplicasFromMaster() (isLeader bool, replicas []st
 		time.Sleep(10 * time.Second)
 	}
 
+	// the partitions created by an older master don't know the ID of their vol
+	if partition.VolID != 0 && dp.getVolID() == 0 {
+		atomic.StoreUint64(&dp.volID, partition.VolID)
+	}
 	for _, host := range partition.Hosts {
 		replicas = append(replicas, host)
 	}
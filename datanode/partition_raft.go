@@ -38,6 +38,7 @@ import (
 
 type dataPartitionCfg struct {
 	VolName       string              `json:"vol_name"`
+	VolID         uint64              `json:"vol_id"`
 	ClusterID     string              `json:"cluster_id"`
 	PartitionID   uint64              `json:"partition_id"`
 	PartitionSize int                 `json:"partition_size"`
@@ -37,6 +37,7 @@ import (
 	"github.com/cubefs/cubefs/raftstore"
 	"github.com/cubefs/cubefs/repl"
 	masterSDK "github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/storage"
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/atomicutil"
 	"github.com/cubefs/cubefs/util/config"
@@ -66,6 +67,7 @@ const (
 	DefaultDiskMaxErr          = 1
 	DefaultDiskRetainMin       = 5 * util.GB // GB
 	DefaultNameResolveInterval = 1           // minutes
+	DefaultScrubInterval       = 7 * 24 * time.Hour
 )
 
 const (
@@ -98,6 +100,12 @@ const (
 	CfgMetricsDegrade = "metricsDegrade" // int
 
 	CfgDiskRdonlySpace = "diskRdonlySpace" // int
+
+	ConfigKeyExtentMaxSize      = "extentMaxSize"      // int, bytes
+	ConfigKeyVerifyReadCrc      = "verifyReadCrc"      // bool
+	ConfigKeyPreallocateExtents = "preallocateExtents" // bool
+	ConfigKeyScrubRate          = "scrubRate"          // int, bytes per second read by the scrub of a disk, 0 disables it
+	ConfigKeyScrubInterval      = "scrubInterval"      // int, hours between two scrubs of an extent
 	// smux Config
 	ConfigKeyEnableSmuxClient  = "enableSmuxConnPool" //bool
 	ConfigKeySmuxPortShift     = "smuxPortShift"      //int
@@ -165,6 +173,9 @@ type DataNode struct {
 	serviceIDKey            string
 	cpuUtil                 atomicutil.Float64
 	cpuSamplerDone          chan struct{}
+	volWriteQuorums         atomic.Value // map[uint64]int, write quorum of vols by ID from master heartbeat
+	scrubRate               int64
+	scrubInterval           time.Duration
 }
 
 type verOp2Phase struct {
@@ -333,9 +344,28 @@ func (s *DataNode) parseConfig(cfg *config.Config) (err error) {
 
 	s.serviceIDKey = cfg.GetString(ConfigServiceIDKey)
 
+	if maxSize := cfg.GetInt64(ConfigKeyExtentMaxSize); maxSize != 0 {
+		if err = storage.SetExtentMaxSize(maxSize); err != nil {
+			return fmt.Errorf("parseConfig: %v", err)
+		}
+	}
+	storage.SetVerifyReadCrc(cfg.GetBool(ConfigKeyVerifyReadCrc))
+	storage.SetPreallocateExtents(cfg.GetBool(ConfigKeyPreallocateExtents))
+	if s.scrubRate = cfg.GetInt64(ConfigKeyScrubRate); s.scrubRate < 0 {
+		return fmt.Errorf("parseConfig: invalid %v %v", ConfigKeyScrubRate, s.scrubRate)
+	}
+	s.scrubInterval = DefaultScrubInterval
+	if hours := cfg.GetInt64(ConfigKeyScrubInterval); hours > 0 {
+		s.scrubInterval = time.Duration(hours) * time.Hour
+	}
+
 	log.LogDebugf("action[parseConfig] load masterAddrs(%v).", MasterClient.Nodes())
 	log.LogDebugf("action[parseConfig] load port(%v).", s.port)
 	log.LogDebugf("action[parseConfig] load zoneName(%v).", s.zoneName)
+	log.LogDebugf("action[parseConfig] load extentMaxSize(%v).", storage.GetExtentMaxSize())
+	log.LogDebugf("action[parseConfig] load verifyReadCrc(%v).", storage.IsVerifyReadCrc())
+	log.LogDebugf("action[parseConfig] load preallocateExtents(%v).", storage.IsPreallocateExtents())
+	log.LogDebugf("action[parseConfig] load scrubRate(%v) scrubInterval(%v).", s.scrubRate, s.scrubInterval)
 	return
 }
 
@@ -595,6 +625,7 @@ func (s *DataNode) registerHandler() {
 	http.HandleFunc("/setAutoRepairStatus", s.setAutoRepairStatus)
 	http.HandleFunc("/getTinyDeleted", s.getTinyDeleted)
 	http.HandleFunc("/getNormalDeleted", s.getNormalDeleted)
+	http.HandleFunc("/getCorruptExtents", s.getCorruptExtents)
 	http.HandleFunc("/getSmuxPoolStat", s.getSmuxPoolStat())
 	http.HandleFunc("/setMetricsDegrade", s.setMetricsDegrade)
 	http.HandleFunc("/getMetricsDegrade", s.getMetricsDegrade)
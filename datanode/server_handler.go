@@ -284,6 +284,29 @@ func (s *DataNode) getBlockCrcAPI(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (s *DataNode) getCorruptExtents(w http.ResponseWriter, r *http.Request) {
+	var (
+		partitionID uint64
+		err         error
+	)
+	if err = r.ParseForm(); err != nil {
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if partitionID, err = strconv.ParseUint(r.FormValue("id"), 10, 64); err != nil {
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	partition := s.space.Partition(partitionID)
+	if partition == nil {
+		s.buildFailureResp(w, http.StatusNotFound, "partition not exist")
+		return
+	}
+
+	s.buildSuccessResp(w, partition.ExtentStore().GetCorruptExtents())
+	return
+}
+
 func (s *DataNode) getTinyDeleted(w http.ResponseWriter, r *http.Request) {
 	var (
 		partitionID uint64
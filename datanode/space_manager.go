@@ -252,6 +252,9 @@ func (manager *SpaceManager) LoadDisk(path string, reservedSpace, diskRdonlySpac
 		manager.putDisk(disk)
 		err = nil
 		go disk.doBackendTask()
+		if manager.dataNode.scrubRate > 0 {
+			go disk.doScrubTask()
+		}
 	}
 	return
 }
@@ -386,6 +389,7 @@ func (manager *SpaceManager) CreatePartition(request *proto.CreateDataPartitionR
 	dpCfg := &dataPartitionCfg{
 		PartitionID:   request.PartitionId,
 		VolName:       request.VolumeId,
+		VolID:         request.VolId,
 		Peers:         request.Members,
 		Hosts:         request.Hosts,
 		RaftStore:     manager.raftStore,
@@ -481,6 +481,18 @@ func (s *DataNode) handleHeartbeatPacket(p *repl.Packet) {
 					s.diskQosEnable)
 			}
 			s.diskQosEnableFromMaster = request.EnableDiskQos
+			s.volWriteQuorums.Store(request.VolWriteQuorums)
+			for partitionID, volName := range request.DataPartitionVolNames {
+				dp := s.space.Partition(partitionID)
+				if dp == nil || dp.volumeID == volName {
+					continue
+				}
+				if err := dp.setVolName(volName); err != nil {
+					log.LogErrorf("action[handleHeartbeatPacket] dp[%v] set vol name[%v] err[%v]", partitionID, volName, err)
+					continue
+				}
+				log.LogWarnf("action[handleHeartbeatPacket] dp[%v] vol renamed to [%v]", partitionID, volName)
+			}
 
 			var needUpdate bool
 			if request.QosFlowWriteLimit > 0 && request.QosFlowWriteLimit != s.diskFlowWriteLimit {
@@ -714,7 +726,7 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 		partition.disk.allocCheckLimit(proto.FlowWriteType, uint32(p.Size))
 		partition.disk.allocCheckLimit(proto.IopsWriteType, 1)
 
-		_, err = store.Write(p.ExtentID, p.ExtentOffset, int64(p.Size), p.Data, p.CRC, storage.AppendWriteType, p.IsSyncWrite())
+		_, err = store.Write(p.ExtentID, p.ExtentOffset, int64(p.Size), p.Data, storeCrc(p), storage.AppendWriteType, p.IsSyncWrite())
 		if !shallDegrade {
 			s.metrics.MetricIOBytes.AddWithLabels(int64(p.Size), metricPartitionIOLabels)
 			partitionIOMetric.SetWithLabels(err, metricPartitionIOLabels)
@@ -731,7 +743,7 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 		partition.disk.allocCheckLimit(proto.FlowWriteType, uint32(p.Size))
 		partition.disk.allocCheckLimit(proto.IopsWriteType, 1)
 
-		_, err = store.Write(p.ExtentID, p.ExtentOffset, int64(p.Size), p.Data, p.CRC, storage.AppendWriteType, p.IsSyncWrite())
+		_, err = store.Write(p.ExtentID, p.ExtentOffset, int64(p.Size), p.Data, storeCrc(p), storage.AppendWriteType, p.IsSyncWrite())
 		if !shallDegrade {
 			s.metrics.MetricIOBytes.AddWithLabels(int64(p.Size), metricPartitionIOLabels)
 			partitionIOMetric.SetWithLabels(err, metricPartitionIOLabels)
@@ -941,6 +953,7 @@ func (s *DataNode) extentRepairReadPacket(p *repl.Packet, connect net.Conn, isRe
 		err = nil
 		reply := repl.NewStreamReadResponsePacket(p.ReqID, p.PartitionID, p.ExtentID)
 		reply.StartT = p.StartT
+		reply.CrcAlg = p.CrcAlg
 		currReadSize := uint32(util.Min(int(needReplySize), util.ReadBlockSize))
 		if currReadSize == util.ReadBlockSize {
 			reply.Data, _ = proto.Buffers.Get(util.ReadBlockSize)
@@ -958,7 +971,7 @@ func (s *DataNode) extentRepairReadPacket(p *repl.Packet, connect net.Conn, isRe
 		partition.Disk().allocCheckLimit(proto.IopsReadType, 1)
 		partition.Disk().allocCheckLimit(proto.FlowReadType, currReadSize)
 
-		reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, isRepairRead)
+		reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, isRepairRead, p.CrcAlg)
 		if !shallDegrade {
 			s.metrics.MetricIOBytes.AddWithLabels(int64(p.Size), metricPartitionIOLabels)
 			partitionIOMetric.SetWithLabels(err, metricPartitionIOLabels)
@@ -1078,6 +1091,7 @@ func (s *DataNode) tinyExtentRepairRead(request *repl.Packet, connect net.Conn)
 			break
 		}
 		reply := repl.NewTinyExtentStreamReadResponsePacket(request.ReqID, request.PartitionID, request.ExtentID)
+		reply.CrcAlg = request.CrcAlg
 		reply.ArgLen = TinyExtentRepairReadResponseArgLen
 		reply.Arg = make([]byte, TinyExtentRepairReadResponseArgLen)
 		s.attachAvaliSizeOnTinyExtentRepairRead(reply, avaliReplySize)
@@ -1104,7 +1118,7 @@ func (s *DataNode) tinyExtentRepairRead(request *repl.Packet, connect net.Conn)
 			reply.Data = make([]byte, currReadSize)
 		}
 		reply.ExtentOffset = offset
-		reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, false)
+		reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, false, request.CrcAlg)
 		if err != nil {
 			return
 		}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/cubefs/cubefs/repl"
 	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util/log"
 )
 
 func (s *DataNode) Post(p *repl.Packet) error {
@@ -29,6 +30,7 @@ func (s *DataNode) Post(p *repl.Packet) error {
 		p.NeedReply = false
 	}
 	s.cleanupPkt(p)
+	s.repairLaggingReplicas(p)
 	s.addMetrics(p)
 	return nil
 }
@@ -55,7 +57,8 @@ func (s *DataNode) releaseExtent(p *repl.Packet) {
 	}
 	partition := p.Object.(*DataPartition)
 	store := partition.ExtentStore()
-	if p.IsErrPacket() {
+	// a tiny extent missed by a follower is repaired before being written again
+	if p.IsErrPacket() || len(p.LaggingFollowers()) > 0 {
 		store.SendToBrokenTinyExtentC(p.ExtentID)
 	} else {
 		store.SendToAvailableTinyExtentC(p.ExtentID)
@@ -63,6 +66,18 @@ func (s *DataNode) releaseExtent(p *repl.Packet) {
 	atomic.StoreInt32(&p.IsReleased, IsReleased)
 }
 
+// repairLaggingReplicas triggers the repair of a normal extent written on the write
+// quorum only, the tiny extents are repaired once released as broken.
+func (s *DataNode) repairLaggingReplicas(p *repl.Packet) {
+	if len(p.LaggingFollowers()) == 0 || p.IsErrPacket() || p.IsTinyExtentType() || p.Object == nil {
+		return
+	}
+	partition := p.Object.(*DataPartition)
+	log.LogWarnf("action[repairLaggingReplicas] partition(%v) extent(%v) missed by followers(%v), trigger repair",
+		partition.partitionID, p.ExtentID, p.LaggingFollowers())
+	partition.triggerNormalExtentRepair()
+}
+
 func (s *DataNode) addMetrics(p *repl.Packet) {
 	if p.IsMasterCommand() || p.ShallDegrade() {
 		return
@@ -18,7 +18,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/cubefs/cubefs/util/log"
-	"hash/crc32"
 	"sync/atomic"
 
 	"github.com/cubefs/cubefs/proto"
@@ -55,6 +54,9 @@ func (s *DataNode) Prepare(p *repl.Packet) (err error) {
 	if err = s.checkPartition(p); err != nil {
 		return
 	}
+	if p.IsForwardPacket() {
+		p.SetWriteQuorum(s.getVolWriteQuorum(p.Object.(*DataPartition).getVolID()))
+	}
 	// For certain packet, we meed to add some additional extent information.
 	if err = s.addExtentInfo(p); err != nil {
 		return
@@ -63,6 +65,16 @@ func (s *DataNode) Prepare(p *repl.Packet) (err error) {
 	return
 }
 
+// getVolWriteQuorum returns the write quorum set by master for the vol, 0 means all replicas.
+// The vols are identified by ID as their name changes when they are renamed.
+func (s *DataNode) getVolWriteQuorum(volID uint64) int {
+	if volID == 0 {
+		return 0
+	}
+	quorums, _ := s.volWriteQuorums.Load().(map[uint64]int)
+	return quorums[volID]
+}
+
 func (s *DataNode) checkStoreMode(p *repl.Packet) (err error) {
 	if p.ExtentType == proto.TinyExtentType || p.ExtentType == proto.NormalExtentType {
 		return nil
@@ -75,7 +87,7 @@ func (s *DataNode) checkCrc(p *repl.Packet) (err error) {
 	if !p.IsWriteOperation() {
 		return
 	}
-	crc := crc32.ChecksumIEEE(p.Data[:p.Size])
+	crc := p.ComputeCRC(p.Data[:p.Size])
 	if crc != p.CRC {
 		return storage.CrcMismatchError
 	}
@@ -83,6 +95,18 @@ func (s *DataNode) checkCrc(p *repl.Packet) (err error) {
 	return
 }
 
+// storeCrc returns the crc of a write packet to keep as the crc of the block it
+// fills. The block crcs of the extent store are always crc32 IEEE ones, whatever
+// the algorithm of the client, so that the extents of the replicas compare alike.
+// For another algorithm 0 is returned and the block crc is computed from the disk
+// when it is needed.
+func storeCrc(p *repl.Packet) uint32 {
+	if p.CrcAlg != proto.CrcAlgIEEE {
+		return 0
+	}
+	return p.CRC
+}
+
 func (s *DataNode) checkPartition(p *repl.Packet) (err error) {
 	dp := s.space.Partition(p.PartitionID)
 	if dp == nil {
@@ -0,0 +1,183 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const defaultAppendBufferSize = util.BlockSize
+
+// appendBuffer coalesces the small writes issued on an O_APPEND fd.
+//
+// Appends are sequential by nature, so consecutive writes are gathered and
+// sent with a single append when the buffer is full, when the window expires
+// or when the fd is flushed. A write is either buffered as a whole or sent
+// by itself, and the buffer never exceeds one packet, so the data of a
+// single write is never split between two appends.
+type appendBuffer struct {
+	sync.Mutex
+	buf     []byte
+	size    int
+	window  time.Duration
+	timer   *time.Timer
+	err     error // error of the last background flush, reported by the next call
+	closed  bool
+	flushFn func(data []byte) error
+}
+
+func newAppendBuffer(size int, window time.Duration, flushFn func(data []byte) error) *appendBuffer {
+	if size <= 0 || size > defaultAppendBufferSize {
+		size = defaultAppendBufferSize
+	}
+	return &appendBuffer{
+		size:    size,
+		window:  window,
+		flushFn: flushFn,
+	}
+}
+
+// Write buffers data, or sends it directly if it does not fit in the buffer.
+func (ab *appendBuffer) Write(data []byte) (n int, err error) {
+	ab.Lock()
+	defer ab.Unlock()
+	if err = ab.takeErr(); err != nil {
+		return
+	}
+	if len(ab.buf)+len(data) > ab.size {
+		if err = ab.flush(); err != nil {
+			return
+		}
+	}
+	if len(data) >= ab.size || ab.closed {
+		if err = ab.flushFn(data); err != nil {
+			return
+		}
+		return len(data), nil
+	}
+	if len(ab.buf) == 0 {
+		if ab.buf == nil {
+			ab.buf = make([]byte, 0, ab.size)
+		}
+		ab.armTimer()
+	}
+	ab.buf = append(ab.buf, data...)
+	return len(data), nil
+}
+
+// Flush sends the buffered data and reports any pending background error.
+func (ab *appendBuffer) Flush() error {
+	ab.Lock()
+	defer ab.Unlock()
+	if err := ab.takeErr(); err != nil {
+		return err
+	}
+	return ab.flush()
+}
+
+// Close flushes the buffered data, later writes go directly.
+// Buffered returns the size of the data waiting to be sent.
+func (ab *appendBuffer) Buffered() int {
+	ab.Lock()
+	defer ab.Unlock()
+	return len(ab.buf)
+}
+
+func (ab *appendBuffer) Close() error {
+	ab.Lock()
+	defer ab.Unlock()
+	ab.closed = true
+	if err := ab.takeErr(); err != nil {
+		return err
+	}
+	return ab.flush()
+}
+
+func (ab *appendBuffer) armTimer() {
+	if ab.timer == nil {
+		ab.timer = time.AfterFunc(ab.window, ab.onTimeout)
+		return
+	}
+	ab.timer.Reset(ab.window)
+}
+
+func (ab *appendBuffer) onTimeout() {
+	ab.Lock()
+	defer ab.Unlock()
+	if err := ab.flush(); err != nil {
+		log.LogErrorf("appendBuffer: flush on timeout failed: %v", err)
+		ab.err = err
+	}
+}
+
+func (ab *appendBuffer) flush() (err error) {
+	if ab.timer != nil {
+		ab.timer.Stop()
+	}
+	if len(ab.buf) == 0 {
+		return
+	}
+	err = ab.flushFn(ab.buf)
+	// the buffered writes have been acknowledged already, drop them on error
+	// and report the failure instead of retrying a partially applied append
+	ab.buf = ab.buf[:0]
+	return
+}
+
+func (ab *appendBuffer) takeErr() (err error) {
+	err, ab.err = ab.err, nil
+	return
+}
+
+// inodeLocks hands out a mutex per inode, the mutex is released when no one holds or waits for it.
+type inodeLocks struct {
+	sync.Mutex
+	locks map[uint64]*inodeLock
+}
+
+type inodeLock struct {
+	sync.Mutex
+	ref int
+}
+
+func (il *inodeLocks) lock(ino uint64) {
+	il.Lock()
+	if il.locks == nil {
+		il.locks = make(map[uint64]*inodeLock)
+	}
+	l, ok := il.locks[ino]
+	if !ok {
+		l = &inodeLock{}
+		il.locks[ino] = l
+	}
+	l.ref++
+	il.Unlock()
+	l.Lock()
+}
+
+func (il *inodeLocks) unlock(ino uint64) {
+	il.Lock()
+	l := il.locks[ino]
+	l.ref--
+	if l.ref == 0 {
+		delete(il.locks, ino)
+	}
+	il.Unlock()
+	l.Unlock()
+}
@@ -0,0 +1,347 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brahma-adshonor/gohook"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/stretchr/testify/require"
+)
+
+// appendRecorder stands for the extent client, it keeps every append packet.
+type appendRecorder struct {
+	sync.Mutex
+	packets [][]byte
+	err     error
+}
+
+func (r *appendRecorder) append(data []byte) error {
+	r.Lock()
+	defer r.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.packets = append(r.packets, append([]byte(nil), data...))
+	return nil
+}
+
+func (r *appendRecorder) content() []byte {
+	r.Lock()
+	defer r.Unlock()
+	return bytes.Join(r.packets, nil)
+}
+
+func (r *appendRecorder) count() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.packets)
+}
+
+func logLine(i int) []byte {
+	return []byte(fmt.Sprintf("2023-01-01 00:00:00 [INFO] request %d done\n", i))
+}
+
+func TestAppendBufferCoalesce(t *testing.T) {
+	r := &appendRecorder{}
+	ab := newAppendBuffer(1024, time.Hour, r.append)
+
+	var expect []byte
+	lineCnt := 0
+	for i := 0; len(expect) < 4096; i++ {
+		line := logLine(i)
+		n, err := ab.Write(line)
+		require.NoError(t, err)
+		require.Equal(t, len(line), n)
+		expect = append(expect, line...)
+		lineCnt++
+	}
+	require.NoError(t, ab.Flush())
+	require.Equal(t, expect, r.content())
+	require.Less(t, r.count(), lineCnt/10)
+
+	// every packet holds whole lines only, no write is split
+	for _, p := range r.packets {
+		require.LessOrEqual(t, len(p), 1024)
+		require.Equal(t, byte('\n'), p[len(p)-1])
+	}
+
+	// a large write is sent by itself after the buffered data
+	r.packets = nil
+	_, err := ab.Write([]byte("small\n"))
+	require.NoError(t, err)
+	big := bytes.Repeat([]byte("x"), 2048)
+	_, err = ab.Write(big)
+	require.NoError(t, err)
+	require.Equal(t, 2, r.count())
+	require.Equal(t, big, r.packets[1])
+
+	require.NoError(t, ab.Close())
+	_, err = ab.Write([]byte("after close\n"))
+	require.NoError(t, err)
+	require.Equal(t, 3, r.count())
+}
+
+func TestAppendBufferWindow(t *testing.T) {
+	r := &appendRecorder{}
+	ab := newAppendBuffer(0, 20*time.Millisecond, r.append)
+
+	_, err := ab.Write(logLine(0))
+	require.NoError(t, err)
+	_, err = ab.Write(logLine(1))
+	require.NoError(t, err)
+	require.Equal(t, 0, r.count())
+
+	require.Eventually(t, func() bool { return r.count() == 1 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, append(logLine(0), logLine(1)...), r.content())
+
+	// the error of a background flush is reported by the next call
+	r.err = errors.New("mock error")
+	_, err = ab.Write(logLine(2))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		ab.Lock()
+		defer ab.Unlock()
+		return ab.err != nil
+	}, time.Second, 5*time.Millisecond)
+	require.Error(t, ab.Flush())
+	require.NoError(t, ab.Flush())
+}
+
+func BenchmarkAppendBufferLogging(b *testing.B) {
+	for _, window := range []time.Duration{0, time.Second} {
+		b.Run(fmt.Sprintf("window=%v", window), func(b *testing.B) {
+			r := &appendRecorder{}
+			var write func([]byte) (int, error)
+			ab := newAppendBuffer(0, window, r.append)
+			if window == 0 {
+				write = func(data []byte) (int, error) { return len(data), r.append(data) }
+			} else {
+				write = ab.Write
+			}
+			line := logLine(0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := write(line); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := ab.Flush(); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(r.count())/float64(b.N), "packets/op")
+		})
+	}
+}
+
+// mockExtentFiles keeps the content of the files written through the mocked extent client.
+var mockExtentFiles = struct {
+	sync.Mutex
+	data map[uint64][]byte
+}{data: make(map[uint64][]byte)}
+
+func MockFileSize(ec *stream.ExtentClient, inode uint64) (size int, gen uint64, valid bool) {
+	mockExtentFiles.Lock()
+	defer mockExtentFiles.Unlock()
+	return len(mockExtentFiles.data[inode]), 0, true
+}
+
+func MockExtentWrite(ec *stream.ExtentClient, inode uint64, offset int, data []byte, flags int, checkFunc func() error) (int, error) {
+	// the size is read and the data is written in separate steps like the streamer does,
+	// so appends racing with each other would overlap without the caller serializing them
+	mockExtentFiles.Lock()
+	size := len(mockExtentFiles.data[inode])
+	mockExtentFiles.Unlock()
+	runtime.Gosched()
+	mockExtentFiles.Lock()
+	defer mockExtentFiles.Unlock()
+	file := mockExtentFiles.data[inode]
+	if flags&proto.FlagsAppend == 0 {
+		if end := offset + len(data); end > len(file) {
+			file = append(file, make([]byte, end-len(file))...)
+		}
+		copy(file[offset:], data)
+		mockExtentFiles.data[inode] = file
+		return len(data), nil
+	}
+	mockExtentFiles.data[inode] = append(file[:size], data...)
+	return len(data), nil
+}
+
+func MockExtentRead(ec *stream.ExtentClient, inode uint64, data []byte, offset int, size int) (int, error) {
+	mockExtentFiles.Lock()
+	defer mockExtentFiles.Unlock()
+	file := mockExtentFiles.data[inode]
+	if offset >= len(file) {
+		return 0, io.EOF
+	}
+	n := copy(data[:size], file[offset:])
+	if n < size {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func MockExtentFlush(ec *stream.ExtentClient, inode uint64) error {
+	return nil
+}
+
+func MockGetStreamer(ec *stream.ExtentClient, inode uint64) *stream.Streamer {
+	return &stream.Streamer{}
+}
+
+func TestAppendRecordConcurrent(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Write":       MockExtentWrite,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+
+	const (
+		appenders = 8
+		records   = 50
+	)
+	ino := uint64(1000)
+	mockExtentFiles.Lock()
+	delete(mockExtentFiles.data, ino)
+	mockExtentFiles.Unlock()
+
+	type record struct {
+		offset int
+		data   []byte
+	}
+	results := make(chan record, appenders*records)
+	var wg sync.WaitGroup
+	for i := 0; i < appenders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// every appender uses its own fd of the same inode
+			f := &file{ino: ino, flags: uint32(os.O_WRONLY | os.O_APPEND)}
+			for j := 0; j < records; j++ {
+				data := []byte(fmt.Sprintf("appender %d record %d\n", i, j))
+				offset, err := c.appendRecord(f, data)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				results <- record{offset: offset, data: data}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+
+	mockExtentFiles.Lock()
+	content := mockExtentFiles.data[ino]
+	mockExtentFiles.Unlock()
+
+	var all []record
+	total := 0
+	for r := range results {
+		all = append(all, r)
+		total += len(r.data)
+		// every record is found whole at its returned offset
+		require.Equal(t, r.data, content[r.offset:r.offset+len(r.data)])
+	}
+	require.Len(t, all, appenders*records)
+	require.Equal(t, total, len(content))
+
+	// the offset ranges don't overlap
+	sort.Slice(all, func(i, j int) bool { return all[i].offset < all[j].offset })
+	for i := 1; i < len(all); i++ {
+		require.Equal(t, all[i-1].offset+len(all[i-1].data), all[i].offset)
+	}
+	require.Empty(t, c.appendLocks.locks)
+}
+
+func TestAppendRecordWithAppendWrites(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Write":       MockExtentWrite,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+
+	const records = 100
+	ino := uint64(1001)
+	mockExtentFiles.Lock()
+	delete(mockExtentFiles.data, ino)
+	mockExtentFiles.Unlock()
+
+	type record struct {
+		offset int
+		data   []byte
+	}
+	results := make(chan record, records)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		f := &file{ino: ino, flags: uint32(os.O_WRONLY | os.O_APPEND)}
+		for j := 0; j < records; j++ {
+			data := []byte(fmt.Sprintf("record %d\n", j))
+			offset, err := c.appendRecord(f, data)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results <- record{offset: offset, data: data}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		// a plain O_APPEND fd of the same inode
+		f := &file{ino: ino, flags: uint32(os.O_WRONLY | os.O_APPEND), pos: &filePos{}}
+		for j := 0; j < records; j++ {
+			if _, err := c.fileWrite(f, -1, []byte(fmt.Sprintf("write %d\n", j))); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(results)
+
+	mockExtentFiles.Lock()
+	content := mockExtentFiles.data[ino]
+	mockExtentFiles.Unlock()
+	for r := range results {
+		require.Equal(t, r.data, content[r.offset:r.offset+len(r.data)])
+	}
+	require.Empty(t, c.appendLocks.locks)
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	gopath "path"
+	"sync"
+)
+
+// maxDentryEpochs bounds the maps of dentryEpochs, they are reset once larger.
+const maxDentryEpochs = 1 << 16
+
+// dentryEpochs orders the entries of the dentry cache with the local changes of the
+// namespace, so that the process sees its own creates, unlinks and renames through
+// the cache, even when a lookup raced with them. Every local change bumps the epoch
+// and records it on the changed path and on its parent dir. A cached entry is tagged
+// with the epoch its lookup started at, it is stale if its parent dir, the path or
+// one of its ancestors changed since.
+type dentryEpochs struct {
+	sync.Mutex
+	epoch   uint64
+	floor   uint64            // the entries tagged before it are stale
+	dirs    map[string]uint64 // dir -> epoch of the last local change of its entries
+	paths   map[string]uint64 // path -> epoch of the last local change of the path
+	entries map[string]uint64 // cached path -> epoch its lookup started at
+}
+
+// current returns the epoch to tag the entries looked up from now on with.
+func (de *dentryEpochs) current() uint64 {
+	de.Lock()
+	defer de.Unlock()
+	return de.epoch
+}
+
+// changed records a local change of path. The caller must hold the lock.
+func (de *dentryEpochs) changed(path string) {
+	if de.dirs == nil || len(de.dirs)+len(de.paths) >= maxDentryEpochs {
+		// forgetting the changes makes all the entries cached so far stale
+		de.dirs = make(map[string]uint64)
+		de.paths = make(map[string]uint64)
+		de.floor = de.epoch + 1
+	}
+	de.epoch++
+	de.dirs[gopath.Dir(path)] = de.epoch
+	de.paths[path] = de.epoch
+	delete(de.entries, path)
+}
+
+// stale returns if an entry of path tagged with epoch is older than a local change.
+// The caller must hold the lock.
+func (de *dentryEpochs) stale(path string, epoch uint64) bool {
+	if epoch < de.floor || de.dirs[gopath.Dir(path)] > epoch {
+		return true
+	}
+	for p := path; ; p = gopath.Dir(p) {
+		if de.paths[p] > epoch {
+			return true
+		}
+		if p == "/" || p == "." {
+			return false
+		}
+	}
+}
+
+// tag records the epoch of the entry of path, false if it is already stale. The
+// caller must hold the lock.
+func (de *dentryEpochs) tag(path string, epoch uint64) bool {
+	if de.stale(path, epoch) {
+		return false
+	}
+	if de.entries == nil || len(de.entries) >= maxDentryEpochs {
+		de.entries = make(map[string]uint64)
+	}
+	de.entries[path] = epoch
+	return true
+}
+
+// valid returns if the entry of path is tagged and not stale. The caller must hold
+// the lock.
+func (de *dentryEpochs) valid(path string) bool {
+	epoch, ok := de.entries[path]
+	return ok && !de.stale(path, epoch)
+}
+
+// getDentry returns the inode of path from the dentry cache, negative if the path
+// is known not to exist. The entries older than a local change are missed.
+func (c *client) getDentry(path string) (ino uint64, negative, ok bool) {
+	c.de.Lock()
+	defer c.de.Unlock()
+	if !c.de.valid(path) {
+		return 0, false, false
+	}
+	if c.dc.IsNegative(path) {
+		return 0, true, false
+	}
+	ino, ok = c.dc.Get(path)
+	return
+}
+
+// putDentry caches the inode of path looked up from epoch, unless path changed
+// locally since.
+func (c *client) putDentry(path string, ino uint64, epoch uint64) {
+	c.de.Lock()
+	defer c.de.Unlock()
+	if c.de.tag(path, epoch) {
+		c.dc.Put(path, ino)
+	}
+}
+
+// putNegativeDentry caches that path, looked up from epoch, does not exist, unless
+// path changed locally since.
+func (c *client) putNegativeDentry(path string, epoch uint64) {
+	c.de.Lock()
+	defer c.de.Unlock()
+	if c.de.tag(path, epoch) {
+		c.dc.PutNegative(path)
+	}
+}
+
+// dentryChanged drops the cached entries of the paths changed by the process, and
+// makes the entries of their subtrees and siblings looked up before stale.
+func (c *client) dentryChanged(paths ...string) {
+	c.de.Lock()
+	defer c.de.Unlock()
+	for _, path := range paths {
+		path = gopath.Clean(path)
+		c.de.changed(path)
+		c.dc.Delete(path)
+	}
+}
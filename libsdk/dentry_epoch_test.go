@@ -0,0 +1,86 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/brahma-adshonor/gohook"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func MockCreateFound(mw *meta.MetaWrapper, parentID uint64, name string, mode, uid, gid uint32, target []byte) (*proto.InodeInfo, error) {
+	mockMissingPaths.Delete("/ryw/" + name)
+	return &proto.InodeInfo{Inode: uint64(len("/ryw/"+name)) + proto.RootIno, Mode: mode}, nil
+}
+
+func TestDentryReadYourWrites(t *testing.T) {
+	c := newMockClient(t)
+	require.NoError(t, gohook.HookMethod(c.mw, "Create_ll", MockCreateFound, nil))
+	defer gohook.UnHookMethod(c.mw, "Create_ll")
+	c.dc.SetNegativeTTL(time.Minute)
+
+	// the path is cached missing, a create makes it found at once
+	mockMissingPaths.Store("/ryw/a", true)
+	defer mockMissingPaths.Delete("/ryw/a")
+	_, err := c.lookupPath("/ryw/a")
+	require.Equal(t, syscall.ENOENT, err)
+	_, err = c.lookupPath("/ryw/a")
+	require.Equal(t, syscall.ENOENT, err)
+	_, created, err := c.createOrOpen(proto.RootIno, "a", "/ryw/a", 0644)
+	require.NoError(t, err)
+	require.True(t, created)
+	info, err := c.lookupPath("/ryw/a")
+	require.NoError(t, err)
+	require.Equal(t, uint64(len("/ryw/a"))+proto.RootIno, info.Inode)
+
+	// a lookup which started before the create does not cache its stale result
+	mockMissingPaths.Store("/ryw/b", true)
+	defer mockMissingPaths.Delete("/ryw/b")
+	epoch := c.de.current()
+	_, _, err = c.createOrOpen(proto.RootIno, "b", "/ryw/b", 0644)
+	require.NoError(t, err)
+	c.putNegativeDentry("/ryw/b", epoch)
+	mockLookupPathCnt = 0
+	_, err = c.lookupPath("/ryw/b")
+	require.NoError(t, err)
+	require.Equal(t, 1, mockLookupPathCnt)
+	// and the fresh entry is cached
+	_, err = c.lookupPath("/ryw/b")
+	require.NoError(t, err)
+	require.Equal(t, 1, mockLookupPathCnt)
+
+	// the entries under a renamed dir are stale
+	_, err = c.lookupPath("/ryw/dir/file")
+	require.NoError(t, err)
+	c.dentryChanged("/ryw/dir", "/ryw/dir2")
+	mockLookupPathCnt = 0
+	_, err = c.lookupPath("/ryw/dir/file")
+	require.NoError(t, err)
+	require.Equal(t, 1, mockLookupPathCnt)
+
+	// the entries of other dirs stay cached
+	_, err = c.lookupPath("/other/file")
+	require.NoError(t, err)
+	c.dentryChanged("/ryw/c")
+	mockLookupPathCnt = 0
+	_, err = c.lookupPath("/other/file")
+	require.NoError(t, err)
+	require.Equal(t, 0, mockLookupPathCnt)
+}
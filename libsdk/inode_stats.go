@@ -0,0 +1,87 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// inodeStats counts the io issued by the client on an inode.
+type inodeStats struct {
+	readBytes  uint64
+	readOps    uint64
+	writeBytes uint64
+	writeOps   uint64
+	rowOps     uint64 // writes overwriting existing data, redirected to new extents on write
+}
+
+// inodeStatsView is the json view of inodeStats replied by cfs_get_inode_stats.
+type inodeStatsView struct {
+	Inode      uint64 `json:"inode"`
+	ReadBytes  uint64 `json:"readBytes"`
+	ReadOps    uint64 `json:"readOps"`
+	WriteBytes uint64 `json:"writeBytes"`
+	WriteOps   uint64 `json:"writeOps"`
+	RowOps     uint64 `json:"rowOps"`
+}
+
+// inodeStatsMap holds the io counters of the inodes accessed by the client.
+type inodeStatsMap struct {
+	stats sync.Map // inode -> *inodeStats
+}
+
+func (m *inodeStatsMap) get(ino uint64) *inodeStats {
+	if s, ok := m.stats.Load(ino); ok {
+		return s.(*inodeStats)
+	}
+	s, _ := m.stats.LoadOrStore(ino, &inodeStats{})
+	return s.(*inodeStats)
+}
+
+func (m *inodeStatsMap) recordRead(ino uint64, n int) {
+	s := m.get(ino)
+	atomic.AddUint64(&s.readOps, 1)
+	atomic.AddUint64(&s.readBytes, uint64(n))
+}
+
+func (m *inodeStatsMap) recordWrite(ino uint64, n int, row bool) {
+	s := m.get(ino)
+	atomic.AddUint64(&s.writeOps, 1)
+	atomic.AddUint64(&s.writeBytes, uint64(n))
+	if row {
+		atomic.AddUint64(&s.rowOps, 1)
+	}
+}
+
+func (m *inodeStatsMap) snapshot(ino uint64) inodeStatsView {
+	view := inodeStatsView{Inode: ino}
+	v, ok := m.stats.Load(ino)
+	if !ok {
+		return view
+	}
+	s := v.(*inodeStats)
+	view.ReadBytes = atomic.LoadUint64(&s.readBytes)
+	view.ReadOps = atomic.LoadUint64(&s.readOps)
+	view.WriteBytes = atomic.LoadUint64(&s.writeBytes)
+	view.WriteOps = atomic.LoadUint64(&s.writeOps)
+	view.RowOps = atomic.LoadUint64(&s.rowOps)
+	return view
+}
+
+// reset drops the counters of the inode, io in flight may still be counted afterwards.
+func (m *inodeStatsMap) reset(ino uint64) {
+	m.stats.Delete(ino)
+}
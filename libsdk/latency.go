@@ -0,0 +1,214 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	syslog "log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/stat"
+)
+
+const (
+	latencyPath  = "/latency"
+	connPoolPath = "/connpool"
+
+	exporterRole    = "libcfs"
+	opLatencyMetric = "op"
+)
+
+// operations whose latency is collected by the client
+const (
+	opGetattr = iota
+	opSetattr
+	opOpen
+	opFlush
+	opClose
+	opWrite
+	opRead
+	opReaddir
+	opLsdir
+	opMkdirs
+	opRmdir
+	opUnlink
+	opRename
+	opFdatasync
+	opCount
+)
+
+var opNames = [opCount]string{
+	opGetattr:   "getattr",
+	opSetattr:   "setattr",
+	opOpen:      "open",
+	opFlush:     "flush",
+	opClose:     "close",
+	opWrite:     "write",
+	opRead:      "read",
+	opReaddir:   "readdir",
+	opLsdir:     "lsdir",
+	opMkdirs:    "mkdirs",
+	opRmdir:     "rmdir",
+	opUnlink:    "unlink",
+	opRename:    "rename",
+	opFdatasync: "fdatasync",
+}
+
+var (
+	profServerOnce sync.Once
+	exporterOnce   sync.Once
+)
+
+// exportLatency publishes the latency of an operation to the exporter, it is
+// replaced in tests.
+var exportLatency = func(labels map[string]string, start time.Time) {
+	exporter.NewTPSince(opLatencyMetric, start).SetWithLabels(labels)
+}
+
+func (c *client) observeLatency(op int, start time.Time) {
+	c.latency[op].ObserveSince(start)
+	if c.exportLatency {
+		exportLatency(map[string]string{exporter.Vol: c.volName, exporter.Op: opNames[op]}, start)
+	}
+}
+
+// latencySnapshot returns the histogram summary of every operation which has been issued.
+func (c *client) latencySnapshot() map[string]stat.LatencySnapshot {
+	snapshots := make(map[string]stat.LatencySnapshot)
+	for op := range c.latency {
+		s := c.latency[op].Snapshot()
+		if s.Count == 0 {
+			continue
+		}
+		snapshots[opNames[op]] = s
+	}
+	return snapshots
+}
+
+// latencyHandler replies the latency histograms of all clients, or of the one given by "id".
+func latencyHandler(w http.ResponseWriter, r *http.Request) {
+	reply := make(map[int64]map[string]stat.LatencySnapshot)
+	if v := r.FormValue("id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c, exist := getClient(id)
+		if !exist {
+			http.Error(w, "client not found", http.StatusNotFound)
+			return
+		}
+		reply[id] = c.latencySnapshot()
+	} else {
+		gClientManager.mu.RLock()
+		for id, c := range gClientManager.clients {
+			reply[id] = c.latencySnapshot()
+		}
+		gClientManager.mu.RUnlock()
+	}
+	data, err := json.Marshal(reply)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// connPoolStat is the stat of the connections of the process to the data nodes,
+// and of every client to the meta nodes.
+type connPoolStat struct {
+	Data *util.ConnPoolStat           `json:"data"`
+	Meta map[int64]*util.ConnPoolStat `json:"meta"`
+}
+
+// connPoolHandler replies the connection pool stat of all clients, or of the one
+// given by "id".
+func connPoolHandler(w http.ResponseWriter, r *http.Request) {
+	reply := &connPoolStat{
+		Data: stream.StreamConnPool.GetStat(),
+		Meta: make(map[int64]*util.ConnPoolStat),
+	}
+	if v := r.FormValue("id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c, exist := getClient(id)
+		if !exist {
+			http.Error(w, "client not found", http.StatusNotFound)
+			return
+		}
+		if c.mw != nil {
+			reply.Meta[id] = c.mw.ConnPoolStat()
+		}
+	} else {
+		gClientManager.mu.RLock()
+		for id, c := range gClientManager.clients {
+			if c.mw != nil {
+				reply.Meta[id] = c.mw.ConnPoolStat()
+			}
+		}
+		gClientManager.mu.RUnlock()
+	}
+	data, err := json.Marshal(reply)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// startExporter initializes the exporter which pushes the metrics to pushAddr,
+// it is process-wide so only the first client which sets pushAddr starts it.
+func startExporter(cluster, pushAddr string) {
+	exporterOnce.Do(func() {
+		data, _ := json.Marshal(&pushConfig{PushAddr: pushAddr})
+		cfg := config.LoadConfigString(string(data))
+		exporter.Init(exporterRole, cfg)
+		exporter.RegistConsul(cluster, exporterRole, cfg)
+	})
+}
+
+// startProfServer serves the latency histograms and the connection pool stat on
+// the given port of host, localhost by default. Only the first client which sets
+// profPort starts the server.
+func startProfServer(host, port string) {
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	profServerOnce.Do(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc(latencyPath, latencyHandler)
+		mux.HandleFunc(connPoolPath, connPoolHandler)
+		addr := net.JoinHostPort(host, port)
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				syslog.Printf("libsdk: prof server on %v stopped: %v", addr, err)
+			}
+		}()
+	})
+}
@@ -23,12 +23,35 @@ typedef struct { const char *p; ptrdiff_t n; } _GoString_;
 
 
 #define _GNU_SOURCE
+#include <stdio.h>
 #include <string.h>
 #include <stdint.h>
+#include <unistd.h>
 #include <sys/types.h>
 #include <sys/stat.h>
+#include <sys/statvfs.h>
 #include <dirent.h>
 #include <fcntl.h>
+#include <sys/uio.h>
+#include <sys/xattr.h>
+
+#ifndef RWF_HIPRI
+#define RWF_HIPRI 0x00000001
+#define RWF_DSYNC 0x00000002
+#define RWF_SYNC 0x00000004
+#define RWF_NOWAIT 0x00000008
+#define RWF_APPEND 0x00000010
+#endif
+
+#define CFS_COPY_PRESERVE_MTIME 0x1
+
+#define CFS_INODE_FLAG_IMMUTABLE 0x1
+#define CFS_INODE_FLAG_APPEND 0x2
+
+#define CFS_WATCH_MODIFY 0x1
+#define CFS_WATCH_DELETE 0x2
+#define CFS_WATCH_MOVE 0x4
+#define CFS_WATCH_OVERFLOW 0x8
 
 struct cfs_stat_info {
     uint64_t ino;
@@ -47,6 +70,23 @@ struct cfs_stat_info {
     uint32_t gid;
 };
 
+/* cfs_stat_info extended with the fields added later, filled by the *2 calls */
+struct cfs_stat_info2 {
+    struct cfs_stat_info st;
+    uint64_t dev;
+};
+
+struct cfs_watch_event {
+    int32_t  wd;
+    uint32_t mask;
+    uint64_t ino;
+};
+
+struct cfs_rename_pair {
+    const char *from;
+    const char *to;
+};
+
 struct cfs_summary_info {
     int64_t files;
     int64_t subdirs;
@@ -132,23 +172,71 @@ extern int cfs_set_client(int64_t id, char* key, char* val);
 extern int cfs_start_client(int64_t id);
 extern void cfs_close_client(int64_t id);
 extern int cfs_chdir(int64_t id, char* path);
+extern int cfs_set_fsuid(int64_t id, uint32_t uid);
+extern int cfs_set_fsgid(int64_t id, uint32_t gid);
 extern char* cfs_getcwd(int64_t id);
+extern int cfs_statvfs(int64_t id, char* path, struct statvfs* buf);
 extern int cfs_getattr(int64_t id, char* path, struct cfs_stat_info* stat);
+extern int cfs_fstatat(int64_t id, int dirfd, char* path, struct cfs_stat_info* stat, int flags);
+extern int cfs_getattr2(int64_t id, char* path, struct cfs_stat_info2* stat);
+extern int cfs_fstatat2(int64_t id, int dirfd, char* path, struct cfs_stat_info2* stat, int flags);
+extern int cfs_faccessat(int64_t id, int dirfd, char* path, int mode, int flags);
+extern int cfs_access(int64_t id, char* path, int mode);
+extern int cfs_stat_batch(int64_t id, char** paths, struct cfs_stat_info* stats, int* status, int count);
 extern int cfs_setattr(int64_t id, char* path, struct cfs_stat_info* stat, int valid);
+extern int cfs_chattr(int64_t id, char* path, uint32_t flags);
+extern int cfs_lsattr(int64_t id, char* path, uint32_t* flags);
+extern int cfs_watch(int64_t id, char* path, uint32_t mask);
+extern int cfs_unwatch(int64_t id, int wd);
+extern ssize_t cfs_read_events(int64_t id, void* buf, size_t size);
 extern int cfs_open(int64_t id, char* path, int flags, mode_t mode);
+extern int cfs_open_ex(int64_t id, char* path, int flags, mode_t mode, int* created);
+extern int cfs_create_sized(int64_t id, char* path, mode_t mode, int64_t size);
 extern int cfs_flush(int64_t id, int fd);
+extern int cfs_fdatasync(int64_t id, int fd);
+extern int cfs_sync_file_range(int64_t id, int fd, off_t offset, off_t nbytes, unsigned int flags);
 extern void cfs_close(int64_t id, int fd);
+extern int64_t cfs_checkpoint(int64_t id);
 extern ssize_t cfs_write(int64_t id, int fd, void* buf, size_t size, off_t off);
+extern int cfs_prepare_write(int64_t id, int fd, int64_t expected_bytes);
+extern ssize_t cfs_get_inode_stats(int64_t id, int fd, void* buf, size_t size);
+extern int cfs_reset_inode_stats(int64_t id, int fd);
+extern ssize_t cfs_append_record(int64_t id, int fd, void* buf, size_t size, off_t* outOffset);
 extern ssize_t cfs_read(int64_t id, int fd, void* buf, size_t size, off_t off);
+extern ssize_t cfs_pwritev2(int64_t id, int fd, struct iovec* iov, int iovcnt, off_t off, int flags);
+extern ssize_t cfs_preadv2(int64_t id, int fd, struct iovec* iov, int iovcnt, off_t off, int flags);
+extern ssize_t cfs_read_tail(int64_t id, int fd, void* buf, size_t size);
+extern ssize_t cfs_copy_file_range(int64_t id, int fdIn, off_t* offIn, int fdOut, off_t* offOut, size_t size, unsigned int flags);
+extern int cfs_posix_fallocate(int64_t id, int fd, off_t off, off_t length);
+extern int cfs_fallocate(int64_t id, int fd, int mode, off_t off, off_t length);
+extern off_t cfs_lseek(int64_t id, int fd, off_t offset, int whence);
+extern int cfs_is_cached(int64_t id, int fd, off_t off, size_t size);
+extern int cfs_dup(int64_t id, int fd);
+extern int cfs_open_dup(int64_t id, int fd);
+extern int cfs_dup2(int64_t id, int oldfd, int newfd);
+extern int cfs_fcntl(int64_t id, int fd, int cmd, int arg);
+extern int cfs_fcntl_lock(int64_t id, int fd, int cmd, struct flock* lk);
 extern int cfs_batch_get_inodes(int64_t id, int fd, void* iids, GoSlice stats, int count);
 extern int cfs_refreshsummary(int64_t id, char* path, int goroutine_num);
 extern int cfs_readdir(int64_t id, int fd, GoSlice dirents, int count);
+extern int cfs_getdents(int64_t id, int fd, GoSlice dirents, int count);
+extern ssize_t cfs_getdents64(int64_t id, int fd, void* buf, size_t count);
+extern off_t cfs_telldir(int64_t id, int fd);
+extern int cfs_seekdir(int64_t id, int fd, off_t off);
 extern int cfs_lsdir(int64_t id, int fd, GoSlice direntsInfo, int count);
 extern int cfs_mkdirs(int64_t id, char* path, mode_t mode);
 extern int cfs_rmdir(int64_t id, char* path);
 extern int cfs_unlink(int64_t id, char* path);
+extern int cfs_flink(int64_t id, int fd, char* path);
+extern int cfs_linkat(int64_t id, int oldDirfd, char* oldPath, int newDirfd, char* newPath, int flags);
 extern int cfs_rename(int64_t id, char* from, char* to);
+extern int cfs_renameat2(int64_t id, int fromDirfd, char* from, int toDirfd, char* to, unsigned int flags);
+extern int cfs_rename_with_attr(int64_t id, char* from, char* to, struct cfs_stat_info* stat, int valid);
+extern int cfs_rename_batch(int64_t id, struct cfs_rename_pair* pairs, int count);
 extern int cfs_fchmod(int64_t id, int fd, mode_t mode);
+extern int cfs_setxattr(int64_t id, char* path, char* name, void* value, size_t size, int flags);
+extern int cfs_lsetxattr(int64_t id, char* path, char* name, void* value, size_t size, int flags);
+extern int cfs_fsetxattr(int64_t id, int fd, char* name, void* value, size_t size, int flags);
 extern int cfs_getsummary(int64_t id, char* path, struct cfs_summary_info* summary, char* useCache, int goroutine_num);
 
 #ifdef __cplusplus
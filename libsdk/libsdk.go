@@ -17,12 +17,36 @@ package main
 /*
 
 #define _GNU_SOURCE
+#include <stdio.h>
 #include <string.h>
 #include <stdint.h>
+#include <unistd.h>
 #include <sys/types.h>
 #include <sys/stat.h>
+#include <sys/statvfs.h>
 #include <dirent.h>
 #include <fcntl.h>
+#include <sys/uio.h>
+#include <sys/xattr.h>
+#include <linux/falloc.h>
+
+#ifndef RWF_HIPRI
+#define RWF_HIPRI 0x00000001
+#define RWF_DSYNC 0x00000002
+#define RWF_SYNC 0x00000004
+#define RWF_NOWAIT 0x00000008
+#define RWF_APPEND 0x00000010
+#endif
+
+#define CFS_COPY_PRESERVE_MTIME 0x1
+
+#define CFS_INODE_FLAG_IMMUTABLE 0x1
+#define CFS_INODE_FLAG_APPEND 0x2
+
+#define CFS_WATCH_MODIFY 0x1
+#define CFS_WATCH_DELETE 0x2
+#define CFS_WATCH_MOVE 0x4
+#define CFS_WATCH_OVERFLOW 0x8
 
 struct cfs_stat_info {
     uint64_t ino;
@@ -41,6 +65,23 @@ struct cfs_stat_info {
     uint32_t gid;
 };
 
+// cfs_stat_info extended with the fields added later, filled by the *2 calls
+struct cfs_stat_info2 {
+    struct cfs_stat_info st;
+    uint64_t dev;
+};
+
+struct cfs_watch_event {
+    int32_t  wd;
+    uint32_t mask;
+    uint64_t ino;
+};
+
+struct cfs_rename_pair {
+    const char *from;
+    const char *to;
+};
+
 struct cfs_summary_info {
     int64_t files;
     int64_t subdirs;
@@ -75,13 +116,18 @@ import "C"
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
 	syslog "log"
+	"math"
 	"os"
+	"os/signal"
 	gopath "path"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -98,8 +144,10 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/data/blobstore"
 	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/cubefs/cubefs/sdk/data/wrapper"
 	masterSDK "github.com/cubefs/cubefs/sdk/master"
 	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/auditlog"
 	"github.com/cubefs/cubefs/util/buf"
 	"github.com/cubefs/cubefs/util/errors"
@@ -110,11 +158,54 @@ import (
 const (
 	defaultBlkSize = uint32(1) << 12
 
+	// same as the statfs of the fuse client
+	defaultMaxInodeID = uint64(1<<63 - 1)
+	volStatCacheTime  = 2 * time.Second
+
 	maxFdNum uint = 10240000
 
 	MaxSizePutOnce = int64(1) << 23
+
+	copyBufferSize    = 1 << 20
+	copyPreserveMtime = uint32(C.CFS_COPY_PRESERVE_MTIME)
+
+	// the symlinks followed resolving a path, as linux
+	maxSymlinkHops = 40
+
+	// the offset and the size of the io on O_DIRECT fds must be multiples of it
+	directIOAlign = 4096
+
+	fallocKeepSize  = int(C.FALLOC_FL_KEEP_SIZE)
+	fallocPunchHole = int(C.FALLOC_FL_PUNCH_HOLE)
+
+	atFdcwd         = int(C.AT_FDCWD)
+	renameNoReplace = uint32(C.RENAME_NOREPLACE)
+	renameExchange  = uint32(C.RENAME_EXCHANGE)
+
+	atEmptyPath       = int(C.AT_EMPTY_PATH)
+	atSymlinkFollow   = int(C.AT_SYMLINK_FOLLOW)
+	atSymlinkNofollow = int(C.AT_SYMLINK_NOFOLLOW)
+	atNoAutomount     = int(C.AT_NO_AUTOMOUNT)
+	atEaccess         = int(C.AT_EACCESS)
+
+	accessRead  = uint32(C.R_OK)
+	accessWrite = uint32(C.W_OK)
+	accessExec  = uint32(C.X_OK)
+
+	syncFileRangeWaitBefore = uint32(C.SYNC_FILE_RANGE_WAIT_BEFORE)
+	syncFileRangeWrite      = uint32(C.SYNC_FILE_RANGE_WRITE)
+	syncFileRangeWaitAfter  = uint32(C.SYNC_FILE_RANGE_WAIT_AFTER)
+
+	xattrCreate  = int(C.XATTR_CREATE)
+	xattrReplace = int(C.XATTR_REPLACE)
+	// the limits of the names and the values of the xattrs, as linux
+	xattrNameMax = 255
+	xattrSizeMax = 1 << 16
 )
 
+// xattrNamespaces are the prefixes the names of the xattrs must start with.
+var xattrNamespaces = []string{"user.", "trusted.", "security.", "system."}
+
 var gClientManager *clientManager
 
 var (
@@ -129,8 +220,14 @@ var (
 	statusENOTDIR = errorToStatus(syscall.ENOTDIR)
 	statusEISDIR  = errorToStatus(syscall.EISDIR)
 	statusENOSPC  = errorToStatus(syscall.ENOSPC)
+
+	statusEOPNOTSUPP = errorToStatus(syscall.EOPNOTSUPP)
+	statusEPERM      = errorToStatus(syscall.EPERM)
+	statusELOOP      = errorToStatus(syscall.ELOOP)
 )
-var once sync.Once
+
+// ignoreSignalsOnce guards the process-wide signal disposition set by ignoreSignals.
+var ignoreSignalsOnce sync.Once
 
 func init() {
 	gClientManager = &clientManager{
@@ -169,6 +266,9 @@ func newClient() *client {
 		sc:                  fs.NewSummaryCache(fs.DefaultSummaryExpiration, fs.MaxSummaryCache),
 		ic:                  fs.NewInodeCache(fs.DefaultInodeExpiration, fs.MaxInodeCache),
 		dc:                  fs.NewDentryCache(),
+		inodeCacheSize:      fs.MaxInodeCache,
+		inodeCacheTTL:       fs.DefaultInodeExpiration,
+		dentryCacheTTL:      fs.DentryValidDuration,
 	}
 
 	gClientManager.mu.Lock()
@@ -192,23 +292,141 @@ func removeClient(id int64) {
 }
 
 type file struct {
-	fd    uint
-	ino   uint64
-	pino  uint64
-	flags uint32
-	mode  uint32
+	fd        uint
+	ino       uint64
+	pino      uint64
+	flags     uint32
+	mode      uint32
+	fileCache bool
+	regular   bool // stream is opened for regular file
+	cloexec   bool // FD_CLOEXEC, only kept for F_GETFD as the fd is never exec'ed
+
+	// file offset used by the io with offset -1, shared with the fds from cfs_dup
+	pos *filePos
 
 	// dir only
 	dirp *dirStream
+	path string // the absolute path of the dir, the base of the paths relative to the fd
 
 	//rw
 	fileWriter *blobstore.Writer
 	fileReader *blobstore.Reader
+
+	// coalesces small writes of O_APPEND fd
+	appendBuf *appendBuffer
+
+	// the unnamed file of O_TMPFILE, shared with the fds from cfs_dup
+	tmp *tmpFile
+}
+
+// filePos is locked by the io at the file offset for the whole io, like the
+// f_pos_lock of linux, so the io of the fds sharing it never overlap.
+type filePos struct {
+	sync.Mutex
+	off int
+}
+
+// lockPos locks the file offset of f if the io at off uses it.
+func (f *file) lockPos(off int) func() {
+	if off >= 0 {
+		return func() {}
+	}
+	f.pos.Lock()
+	return f.pos.Unlock
+}
+
+// tmpFile tracks an O_TMPFILE inode, it is removed when the last fd is closed
+// unless it has been linked into the namespace by then.
+type tmpFile struct {
+	sync.Mutex
+	refs   int
+	linked bool
 }
 
 type dirStream struct {
 	pos     int
-	dirents []proto.Dentry
+	dirents []proto.Dentry // sorted by name
+
+	// the d_off cookies handed out, a cookie is the position after the entry
+	// of the name and stays valid when the dir is read again.
+	cookies    map[int64]string
+	names      map[string]int64
+	lastCookie int64
+	// the name the stream resumes after once the dir is read again
+	resume *string
+}
+
+// cookie returns the d_off of the position after the i-th entry.
+func (d *dirStream) cookie(i int) int64 {
+	name := d.dirents[i].Name
+	if cookie, ok := d.names[name]; ok {
+		return cookie
+	}
+	if d.cookies == nil {
+		d.cookies = make(map[int64]string)
+		d.names = make(map[string]int64)
+	}
+	d.lastCookie++
+	d.cookies[d.lastCookie] = name
+	d.names[name] = d.lastCookie
+	return d.lastCookie
+}
+
+// peek returns the next entry and its d_off without moving the stream, false
+// at the end of the dir.
+func (d *dirStream) peek() (dentry proto.Dentry, off int64, ok bool) {
+	if d.pos >= len(d.dirents) {
+		return
+	}
+	return d.dirents[d.pos], d.cookie(d.pos), true
+}
+
+// next returns the next entry and its d_off, false at the end of the dir.
+func (d *dirStream) next() (dentry proto.Dentry, off int64, ok bool) {
+	if dentry, off, ok = d.peek(); ok {
+		d.pos++
+	}
+	return
+}
+
+// direntType returns the d_type of the dentry type.
+func direntType(mode uint32) C.uchar {
+	switch {
+	case proto.IsRegular(mode):
+		return C.DT_REG
+	case proto.IsDir(mode):
+		return C.DT_DIR
+	case proto.IsSymlink(mode):
+		return C.DT_LNK
+	default:
+		return C.DT_UNKNOWN
+	}
+}
+
+// tell returns the d_off of the current position.
+func (d *dirStream) tell() int64 {
+	if d.pos == 0 {
+		return 0
+	}
+	return d.cookie(d.pos - 1)
+}
+
+// seek moves to the position of the cookie returned by tell or next, the dir
+// is read again so that the entries created since are seen. Resuming by name
+// does not skip the entries which were there, whatever is created or removed.
+func (d *dirStream) seek(off int64) error {
+	if off == 0 {
+		d.resume = nil
+	} else {
+		name, ok := d.cookies[off]
+		if !ok {
+			return syscall.EINVAL
+		}
+		d.resume = &name
+	}
+	d.dirents = nil
+	d.pos = 0
+	return nil
 }
 
 type client struct {
@@ -239,18 +457,57 @@ type client struct {
 	cluster             string
 	dirChildrenNumLimit uint32
 	enableAudit         bool
+	noCachePatterns     []string // path globs which always bypass inode and dentry cache
+	profPort            string
+	profHost            string        // interface the prof server listens on, localhost if empty
+	appendWindow        time.Duration // coalesce small writes on O_APPEND fds within the window, 0 to disable
+	appendBufSize       int
+	ignoreSignals       bool          // ignore SIGHUP and SIGTERM process-wide, for a standalone server linking libcfs
+	snapshotReadSeq     uint64        // read the files as of the snapshot version, 0 to read the latest
+	watchInterval       time.Duration // how often the watched files are polled for changes
+	writeAffinity       string        // prefer or strict, write to the data partitions of writeZone
+	writeZone           string        // the zone of the data nodes on the client host if empty
+	fdBase              uint          // fds are allocated from it, the ones below are left to the embedder
+	negativeDentryTTL   time.Duration // how long a missing path is remembered, 0 to look it up every time
+	inodeCacheSize      int
+	inodeCacheTTL       time.Duration
+	dentryCacheTTL      time.Duration
+	exportLatency       bool // publish the latency of the operations to the exporter
+	enforceAccess       bool // cfs_faccessat checks the permissions, not only the existence
 
 	// runtime context
 	cwd    string // current working directory
+	dev    uint64 // st_dev of the files, see volumeDev
+	fsuid  uint32 // owner of the files and directories created by the client
+	fsgid  uint32
 	fdmap  map[uint]*file
 	fdset  *bitset.BitSet
 	fdlock sync.RWMutex
 
+	// serialize appends of the same inode
+	appendLocks inodeLocks
+
+	// advisory byte-range locks of cfs_fcntl_lock, owned by the fds
+	rangeLocks lockTable
+
+	// files watched by cfs_watch
+	watcher *watcher
+
+	// per-operation latency histograms, indexed by op*
+	latency [opCount]stat.LatencyHistogram
+
+	// io counters of the inodes, see cfs_get_inode_stats
+	inodeStats inodeStatsMap
+
+	// space of the volume reported by cfs_statvfs
+	volStat volStat
+
 	// server info
 	mw   *meta.MetaWrapper
 	ec   *stream.ExtentClient
 	ic   *fs.InodeCache
 	dc   *fs.DentryCache
+	de   dentryEpochs
 	bc   *bcache.BcacheClient
 	ebsc *blobstore.BlobStoreClient
 	sc   *fs.SummaryCache
@@ -322,6 +579,95 @@ func cfs_set_client(id C.int64_t, key, val *C.char) C.int {
 		} else {
 			c.enableAudit = false
 		}
+	case "noCachePaths":
+		patterns, err := parseNoCachePatterns(v)
+		if err != nil {
+			return statusEINVAL
+		}
+		c.noCachePatterns = patterns
+	case "profPort":
+		if _, err := strconv.ParseUint(v, 10, 16); err != nil {
+			return statusEINVAL
+		}
+		c.profPort = v
+	case "profHost":
+		c.profHost = v
+	case "appendCoalesceWindowMs":
+		window, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return statusEINVAL
+		}
+		c.appendWindow = time.Duration(window) * time.Millisecond
+	case "ignoreSignals":
+		if v == "true" {
+			c.ignoreSignals = true
+		} else {
+			c.ignoreSignals = false
+		}
+	case "appendCoalesceSize":
+		size, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || size > defaultAppendBufferSize {
+			return statusEINVAL
+		}
+		c.appendBufSize = int(size)
+	case "snapshotReadSeq":
+		seq, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return statusEINVAL
+		}
+		c.snapshotReadSeq = seq
+	case "watchIntervalMs":
+		interval, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || interval == 0 {
+			return statusEINVAL
+		}
+		c.watchInterval = time.Duration(interval) * time.Millisecond
+	case "writeAffinity":
+		switch v {
+		case wrapper.WriteAffinityNone, wrapper.WriteAffinityPrefer, wrapper.WriteAffinityStrict:
+			c.writeAffinity = v
+		default:
+			return statusEINVAL
+		}
+	case "writeZone":
+		c.writeZone = v
+	case "fdBase":
+		base, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || uint(base) >= maxFdNum {
+			return statusEINVAL
+		}
+		c.fdBase = uint(base)
+	case "negativeDentryTtlMs":
+		ttl, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return statusEINVAL
+		}
+		c.negativeDentryTTL = time.Duration(ttl) * time.Millisecond
+		c.dc.SetNegativeTTL(c.negativeDentryTTL)
+	case "inodeCacheSize":
+		size, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || size == 0 {
+			return statusEINVAL
+		}
+		c.inodeCacheSize = int(size)
+	case "inodeCacheTtlSec":
+		ttl, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return statusEINVAL
+		}
+		c.inodeCacheTTL = time.Duration(ttl) * time.Second
+	case "dentryCacheTtlSec":
+		ttl, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return statusEINVAL
+		}
+		c.dentryCacheTTL = time.Duration(ttl) * time.Second
+	case "enforceAccess":
+		if v == "true" {
+			c.enforceAccess = true
+		} else {
+			c.enforceAccess = false
+		}
 	default:
 		return statusEINVAL
 	}
@@ -346,6 +692,9 @@ func cfs_start_client(id C.int64_t) C.int {
 //export cfs_close_client
 func cfs_close_client(id C.int64_t) {
 	if c, exist := getClient(int64(id)); exist {
+		if c.watcher != nil {
+			c.watcher.stop()
+		}
 		if c.ec != nil {
 			_ = c.ec.Close()
 		}
@@ -369,6 +718,9 @@ func cfs_chdir(id C.int64_t, path *C.char) C.int {
 	if err != nil {
 		return errorToStatus(err)
 	}
+	if dirInfo, cwd, err = c.resolveSymlink(cwd, dirInfo); err != nil {
+		return errorToStatus(err)
+	}
 	if !proto.IsDir(dirInfo.Mode) {
 		return statusENOTDIR
 	}
@@ -376,6 +728,26 @@ func cfs_chdir(id C.int64_t, path *C.char) C.int {
 	return statusOK
 }
 
+//export cfs_set_fsuid
+func cfs_set_fsuid(id C.int64_t, uid C.uint32_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	c.setFsuid(uint32(uid))
+	return statusOK
+}
+
+//export cfs_set_fsgid
+func cfs_set_fsgid(id C.int64_t, gid C.uint32_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	c.setFsgid(uint32(gid))
+	return statusOK
+}
+
 //export cfs_getcwd
 func cfs_getcwd(id C.int64_t) *C.char {
 	c, exist := getClient(int64(id)) // client's working directory
@@ -385,64 +757,188 @@ func cfs_getcwd(id C.int64_t) *C.char {
 	return C.CString(c.cwd)
 }
 
+/*
+ * cfs_statvfs reports the space of the volume, in blocks of 4KB. It is fetched
+ * from the master at most every few seconds, the bytes written by the client
+ * in between are counted as used.
+ */
+
+//export cfs_statvfs
+func cfs_statvfs(id C.int64_t, path *C.char, buf *C.struct_statvfs) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	if _, err := c.lookupPath(c.absPath(C.GoString(path))); err != nil {
+		return errorToStatus(err)
+	}
+
+	st := c.statvfs()
+	buf.f_bsize = C.ulong(st.Bsize)
+	buf.f_frsize = C.ulong(st.Frsize)
+	buf.f_blocks = C.fsblkcnt_t(st.Blocks)
+	buf.f_bfree = C.fsblkcnt_t(st.Bfree)
+	buf.f_bavail = C.fsblkcnt_t(st.Bavail)
+	buf.f_files = C.fsfilcnt_t(st.Files)
+	buf.f_ffree = C.fsfilcnt_t(st.Ffree)
+	buf.f_favail = C.fsfilcnt_t(st.Ffree)
+	buf.f_namemax = C.ulong(st.Namelen)
+	buf.f_fsid = C.ulong(c.dev)
+	return statusOK
+}
+
 //export cfs_getattr
 func cfs_getattr(id C.int64_t, path *C.char, stat *C.struct_cfs_stat_info) C.int {
 	c, exist := getClient(int64(id))
 	if !exist {
 		return statusEINVAL
 	}
+	defer c.observeLatency(opGetattr, time.Now())
 
 	info, err := c.lookupPath(c.absPath(C.GoString(path)))
 	if err != nil {
 		return errorToStatus(err)
 	}
 
-	// fill up the stat
-	stat.ino = C.uint64_t(info.Inode)
-	stat.size = C.uint64_t(info.Size)
-	stat.nlink = C.uint32_t(info.Nlink)
-	stat.blk_size = C.uint32_t(defaultBlkSize)
-	stat.uid = C.uint32_t(info.Uid)
-	stat.gid = C.uint32_t(info.Gid)
+	fillStat(stat, info)
+	return statusOK
+}
 
-	if info.Size%512 != 0 {
-		stat.blocks = C.uint64_t(info.Size>>9) + 1
-	} else {
-		stat.blocks = C.uint64_t(info.Size >> 9)
-	}
-	// fill up the mode
-	if proto.IsRegular(info.Mode) {
-		stat.mode = C.uint32_t(C.S_IFREG) | C.uint32_t(info.Mode&0777)
-	} else if proto.IsDir(info.Mode) {
-		stat.mode = C.uint32_t(C.S_IFDIR) | C.uint32_t(info.Mode&0777)
-	} else if proto.IsSymlink(info.Mode) {
-		stat.mode = C.uint32_t(C.S_IFLNK) | C.uint32_t(info.Mode&0777)
-	} else {
-		stat.mode = C.uint32_t(C.S_IFSOCK) | C.uint32_t(info.Mode&0777)
+/*
+ * cfs_fstatat stats path relative to the dir fd dirfd, following a symlink at the
+ * end of path unless AT_SYMLINK_NOFOLLOW is set. With AT_EMPTY_PATH an empty path
+ * stats the file open at dirfd, an O_TMPFILE file included.
+ */
+
+//export cfs_fstatat
+func cfs_fstatat(id C.int64_t, dirfd C.int, path *C.char, stat *C.struct_cfs_stat_info, flags C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
 	}
+	defer c.observeLatency(opGetattr, time.Now())
 
-	// fill up the time struct
-	t := info.AccessTime.UnixNano()
-	stat.atime = C.uint64_t(t / 1e9)
-	stat.atime_nsec = C.uint32_t(t % 1e9)
+	info, err := c.fstatat(int(dirfd), C.GoString(path), int(flags))
+	if err != nil {
+		return errorToStatus(err)
+	}
+	fillStat(stat, info)
+	return statusOK
+}
 
-	t = info.ModifyTime.UnixNano()
-	stat.mtime = C.uint64_t(t / 1e9)
-	stat.mtime_nsec = C.uint32_t(t % 1e9)
+/*
+ * cfs_getattr2 and cfs_fstatat2 are cfs_getattr and cfs_fstatat filling a
+ * struct cfs_stat_info2, which also has the st_dev of the volume, see volumeDev.
+ * struct cfs_stat_info is left as is for the callers built against it.
+ */
 
-	t = info.CreateTime.UnixNano()
-	stat.ctime = C.uint64_t(t / 1e9)
-	stat.ctime_nsec = C.uint32_t(t % 1e9)
+//export cfs_getattr2
+func cfs_getattr2(id C.int64_t, path *C.char, stat *C.struct_cfs_stat_info2) C.int {
+	if status := cfs_getattr(id, path, &stat.st); status != statusOK {
+		return status
+	}
+	c, _ := getClient(int64(id))
+	stat.dev = C.uint64_t(c.dev)
+	return statusOK
+}
 
+//export cfs_fstatat2
+func cfs_fstatat2(id C.int64_t, dirfd C.int, path *C.char, stat *C.struct_cfs_stat_info2, flags C.int) C.int {
+	if status := cfs_fstatat(id, dirfd, path, &stat.st, flags); status != statusOK {
+		return status
+	}
+	c, _ := getClient(int64(id))
+	stat.dev = C.uint64_t(c.dev)
 	return statusOK
 }
 
+/*
+ * cfs_faccessat checks path relative to the dir fd dirfd like faccessat(2). By
+ * default only the existence of the file is checked and the permissions are left
+ * to the application. With enforceAccess set, mode is checked against the mode and
+ * the owner of the file, for the real uid and gid of the process, or the effective
+ * ones with AT_EACCESS, and EACCES is returned if it is not granted.
+ */
+
+//export cfs_faccessat
+func cfs_faccessat(id C.int64_t, dirfd C.int, path *C.char, mode C.int, flags C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opGetattr, time.Now())
+
+	err := c.faccessat(int(dirfd), C.GoString(path), uint32(mode), int(flags))
+	return errorToStatus(err)
+}
+
+//export cfs_access
+func cfs_access(id C.int64_t, path *C.char, mode C.int) C.int {
+	return cfs_faccessat(id, C.int(atFdcwd), path, mode, 0)
+}
+
+/*
+ * cfs_stat_batch stats count paths in one call, the inodes not in the cache are
+ * fetched with one request per meta partition. status[i] is 0 if stats[i] is
+ * filled up or the error of paths[i], the number of paths stat-ed is returned.
+ */
+
+//export cfs_stat_batch
+func cfs_stat_batch(id C.int64_t, paths **C.char, stats *C.struct_cfs_stat_info, status *C.int, count C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	if count < 0 {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opGetattr, time.Now())
+
+	var cpaths []*C.char
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&cpaths))
+	hdr.Data = uintptr(unsafe.Pointer(paths))
+	hdr.Len = int(count)
+	hdr.Cap = int(count)
+
+	var cstats []C.struct_cfs_stat_info
+	hdr = (*reflect.SliceHeader)(unsafe.Pointer(&cstats))
+	hdr.Data = uintptr(unsafe.Pointer(stats))
+	hdr.Len = int(count)
+	hdr.Cap = int(count)
+
+	var cstatus []C.int
+	hdr = (*reflect.SliceHeader)(unsafe.Pointer(&cstatus))
+	hdr.Data = uintptr(unsafe.Pointer(status))
+	hdr.Len = int(count)
+	hdr.Cap = int(count)
+
+	absPaths := make([]string, count)
+	for i := range cpaths {
+		absPaths[i] = c.absPath(C.GoString(cpaths[i]))
+	}
+
+	var n C.int
+	infos, errs := c.statBatch(absPaths)
+	for i := range infos {
+		if errs[i] != nil {
+			cstatus[i] = errorToStatus(errs[i])
+			continue
+		}
+		fillStat(&cstats[i], infos[i])
+		cstatus[i] = statusOK
+		n++
+	}
+	return n
+}
+
 //export cfs_setattr
 func cfs_setattr(id C.int64_t, path *C.char, stat *C.struct_cfs_stat_info, valid C.int) C.int {
 	c, exist := getClient(int64(id))
 	if !exist {
 		return statusEINVAL
 	}
+	defer c.observeLatency(opSetattr, time.Now())
 
 	info, err := c.lookupPath(c.absPath(C.GoString(path)))
 	if err != nil {
@@ -458,34 +954,236 @@ func cfs_setattr(id C.int64_t, path *C.char, stat *C.struct_cfs_stat_info, valid
 	return statusOK
 }
 
-//export cfs_open
-func cfs_open(id C.int64_t, path *C.char, flags C.int, mode C.mode_t) C.int {
+/*
+ * cfs_chattr replaces the CFS_INODE_FLAG_* flags of a regular file. An immutable
+ * file can not be written, truncated, linked, renamed or removed, an append-only
+ * file only accepts writes at its end.
+ */
+
+//export cfs_chattr
+func cfs_chattr(id C.int64_t, path *C.char, flags C.uint32_t) C.int {
 	c, exist := getClient(int64(id))
 	if !exist {
 		return statusEINVAL
 	}
-	start := time.Now()
+	defer c.observeLatency(opSetattr, time.Now())
 
-	fuseMode := uint32(mode) & uint32(0777)
-	fuseFlags := uint32(flags) &^ uint32(0x8000)
-	accFlags := fuseFlags & uint32(C.O_ACCMODE)
+	info, err := c.lookupPath(c.absPath(C.GoString(path)))
+	if err != nil {
+		return errorToStatus(err)
+	}
+	if !proto.IsRegular(info.Mode) {
+		return statusEOPNOTSUPP
+	}
+	if err = c.mw.SetInodeFlags_ll(info.Inode, uint32(flags)); err != nil {
+		return errorToStatus(err)
+	}
+	c.ic.Delete(info.Inode)
+	return statusOK
+}
 
-	absPath := c.absPath(C.GoString(path))
+//export cfs_lsattr
+func cfs_lsattr(id C.int64_t, path *C.char, flags *C.uint32_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opGetattr, time.Now())
 
-	var info *proto.InodeInfo
-	var parentIno uint64
+	info, err := c.lookupPath(c.absPath(C.GoString(path)))
+	if err != nil {
+		return errorToStatus(err)
+	}
+	*flags = C.uint32_t(info.Flags)
+	return statusOK
+}
 
-	/*
-	 * Note that the rwx mode is ignored when using libsdk
-	 */
+/*
+ * cfs_watch watches the changes of the file at path, mask is a combination of
+ * CFS_WATCH_MODIFY, CFS_WATCH_DELETE and CFS_WATCH_MOVE. It returns a positive
+ * watch descriptor, reported in the wd of the events. The changes are polled
+ * from the meta nodes every watchIntervalMs, one second by default.
+ */
 
-	if fuseFlags&uint32(C.O_CREAT) != 0 {
-		if accFlags != uint32(C.O_WRONLY) && accFlags != uint32(C.O_RDWR) {
-			return statusEACCES
-		}
-		dirpath, name := gopath.Split(absPath)
-		dirInfo, err := c.lookupPath(dirpath)
-		if err != nil {
+//export cfs_watch
+func cfs_watch(id C.int64_t, path *C.char, mask C.uint32_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist || c.watcher == nil {
+		return statusEINVAL
+	}
+
+	absPath := c.absPath(C.GoString(path))
+	info, err := c.lookupPath(absPath)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	var parent uint64
+	dirpath, name := gopath.Split(absPath)
+	if name != "" {
+		dirInfo, err := c.lookupPath(dirpath)
+		if err != nil {
+			return errorToStatus(err)
+		}
+		parent = dirInfo.Inode
+	}
+	wd, err := c.watcher.add(info.Inode, parent, name, uint32(mask))
+	if err != nil {
+		return errorToStatus(err)
+	}
+	return C.int(wd)
+}
+
+//export cfs_unwatch
+func cfs_unwatch(id C.int64_t, wd C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist || c.watcher == nil {
+		return statusEINVAL
+	}
+	return errorToStatus(c.watcher.remove(int32(wd)))
+}
+
+/*
+ * cfs_read_events fills buf with the pending struct cfs_watch_event and returns
+ * the number of bytes filled, 0 if there is no event. It does not block.
+ */
+
+//export cfs_read_events
+func cfs_read_events(id C.int64_t, buf unsafe.Pointer, size C.size_t) C.ssize_t {
+	c, exist := getClient(int64(id))
+	if !exist || c.watcher == nil {
+		return C.ssize_t(statusEINVAL)
+	}
+
+	var events []C.struct_cfs_watch_event
+	eventSize := C.size_t(unsafe.Sizeof(C.struct_cfs_watch_event{}))
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&events))
+	hdr.Data = uintptr(buf)
+	hdr.Len = int(size / eventSize)
+	hdr.Cap = int(size / eventSize)
+
+	pending := c.watcher.read(len(events))
+	for i, event := range pending {
+		events[i].wd = C.int32_t(event.wd)
+		events[i].mask = C.uint32_t(event.mask)
+		events[i].ino = C.uint64_t(event.ino)
+	}
+	return C.ssize_t(C.size_t(len(pending)) * eventSize)
+}
+
+//export cfs_open
+func cfs_open(id C.int64_t, path *C.char, flags C.int, mode C.mode_t) C.int {
+	return _cfs_open(id, path, flags, mode, nil)
+}
+
+/*
+ * cfs_open_ex is cfs_open that also tells whether the file was created by
+ * this call, only one of the callers racing to create the same path sees
+ * created set to 1.
+ */
+
+//export cfs_open_ex
+func cfs_open_ex(id C.int64_t, path *C.char, flags C.int, mode C.mode_t, created *C.int) C.int {
+	var isCreated bool
+	fd := _cfs_open(id, path, flags, mode, &isCreated)
+	if created != nil {
+		*created = 0
+		if fd >= 0 && isCreated {
+			*created = 1
+		}
+	}
+	return fd
+}
+
+/*
+ * cfs_create_sized creates a new file of size bytes and returns it opened for
+ * reading and writing. The space of the whole file is allocated up front, in
+ * normal extents when the size allows, so the writes within size do not fail
+ * with ENOSPC. EEXIST is returned if path exists.
+ */
+
+//export cfs_create_sized
+func cfs_create_sized(id C.int64_t, path *C.char, mode C.mode_t, size C.int64_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	if size <= 0 {
+		return statusEINVAL
+	}
+	if !proto.IsHot(c.volType) {
+		return statusEOPNOTSUPP
+	}
+
+	var created bool
+	fd := _cfs_open(id, path, C.int(C.O_CREAT|C.O_RDWR), mode, &created)
+	if fd < 0 {
+		return fd
+	}
+	f := c.getFile(uint(fd))
+	if !created || !f.regular {
+		cfs_close(id, fd)
+		return errorToStatus(syscall.EEXIST)
+	}
+
+	if err := c.preallocate(f, int(size)); err != nil {
+		cfs_close(id, fd)
+		_, name := gopath.Split(c.absPath(C.GoString(path)))
+		if info, e := c.mw.Delete_ll(f.pino, name, false); e == nil && info != nil {
+			_ = c.mw.Evict(info.Inode)
+			c.ic.Delete(info.Inode)
+			c.dentryChanged(c.absPath(C.GoString(path)))
+		}
+		return errorToStatus(err)
+	}
+	return fd
+}
+
+func _cfs_open(id C.int64_t, path *C.char, flags C.int, mode C.mode_t, created *bool) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	start := time.Now()
+	defer c.observeLatency(opOpen, start)
+
+	fuseMode := uint32(mode) & uint32(0777)
+	fuseFlags := uint32(flags) &^ uint32(0x8000)
+	accFlags := fuseFlags & uint32(C.O_ACCMODE)
+
+	absPath := c.absPath(C.GoString(path))
+
+	var info *proto.InodeInfo
+	var parentIno uint64
+
+	/*
+	 * Note that the rwx mode is ignored when using libsdk
+	 */
+
+	if fuseFlags&uint32(C.O_TMPFILE) == uint32(C.O_TMPFILE) {
+		if accFlags != uint32(C.O_WRONLY) && accFlags != uint32(C.O_RDWR) {
+			return statusEINVAL
+		}
+		dirInfo, err := c.lookupPath(absPath)
+		if err != nil {
+			return errorToStatus(err)
+		}
+		if !proto.IsDir(dirInfo.Mode) {
+			return statusENOTDIR
+		}
+		f, err := c.openTmpFile(dirInfo.Inode, fuseFlags&^uint32(C.O_TMPFILE), fuseMode)
+		if err != nil {
+			return errorToStatus(err)
+		}
+		return C.int(f.fd)
+	}
+
+	if fuseFlags&uint32(C.O_CREAT) != 0 {
+		if accFlags != uint32(C.O_WRONLY) && accFlags != uint32(C.O_RDWR) {
+			return statusEACCES
+		}
+		dirpath, name := gopath.Split(absPath)
+		dirInfo, err := c.lookupPath(dirpath)
+		if err != nil {
 			return errorToStatus(err)
 		}
 		parentIno = dirInfo.Inode
@@ -496,15 +1194,12 @@ func cfs_open(id C.int64_t, path *C.char, flags C.int, mode C.mode_t) C.int {
 				auditlog.FormatLog("Create", dirpath, "nil", err, time.Since(start).Microseconds(), 0, 0)
 			}
 		}()
-		newInfo, err := c.create(dirInfo.Inode, name, fuseMode)
+		newInfo, isCreated, err := c.createOrOpen(dirInfo.Inode, name, absPath, fuseMode)
 		if err != nil {
-			if err != syscall.EEXIST {
-				return errorToStatus(err)
-			}
-			newInfo, err = c.lookupPath(absPath)
-			if err != nil {
-				return errorToStatus(err)
-			}
+			return errorToStatus(err)
+		}
+		if created != nil {
+			*created = isCreated
 		}
 		info = newInfo
 	} else {
@@ -520,6 +1215,23 @@ func cfs_open(id C.int64_t, path *C.char, flags C.int, mode C.mode_t) C.int {
 		}
 		info = newInfo
 	}
+	if proto.IsSymlink(info.Mode) {
+		if fuseFlags&uint32(C.O_NOFOLLOW) != 0 {
+			return statusELOOP
+		}
+		targetInfo, targetPath, err := c.resolveSymlink(absPath, info)
+		if err != nil {
+			return errorToStatus(err)
+		}
+		dirInfo, err := c.lookupPath(gopath.Dir(targetPath))
+		if err != nil {
+			return errorToStatus(err)
+		}
+		info, absPath, parentIno = targetInfo, targetPath, dirInfo.Inode
+	}
+	if openDeniedByInodeFlags(info.Flags, fuseFlags) {
+		return statusEPERM
+	}
 	var fileCache bool
 	if c.cacheRuleKey == "" {
 		fileCache = false
@@ -531,9 +1243,14 @@ func cfs_open(id C.int64_t, path *C.char, flags C.int, mode C.mode_t) C.int {
 	if f == nil {
 		return statusEMFILE
 	}
+	if proto.IsDir(info.Mode) {
+		f.path = absPath
+	}
 
 	if proto.IsRegular(info.Mode) {
+		f.regular = true
 		c.openStream(f)
+		c.openAppendBuffer(f)
 		if fuseFlags&uint32(C.O_TRUNC) != 0 {
 			if accFlags != uint32(C.O_WRONLY) && accFlags != uint32(C.O_RDWR) {
 				c.closeStream(f)
@@ -551,23 +1268,91 @@ func cfs_open(id C.int64_t, path *C.char, flags C.int, mode C.mode_t) C.int {
 	return C.int(f.fd)
 }
 
+// openDeniedByInodeFlags returns if the open flags would allow modifying a file
+// against its immutable or append-only flag.
+func openDeniedByInodeFlags(inodeFlags, openFlags uint32) bool {
+	writable := openFlags&uint32(C.O_ACCMODE) != uint32(C.O_RDONLY) || openFlags&uint32(C.O_TRUNC) != 0
+	if inodeFlags&proto.InodeFlagImmutable != 0 {
+		return writable
+	}
+	if inodeFlags&proto.InodeFlagAppendOnly != 0 {
+		return writable && (openFlags&uint32(C.O_APPEND) == 0 || openFlags&uint32(C.O_TRUNC) != 0)
+	}
+	return false
+}
+
 //export cfs_flush
 func cfs_flush(id C.int64_t, fd C.int) C.int {
 	c, exist := getClient(int64(id))
 	if !exist {
 		return statusEINVAL
 	}
+	defer c.observeLatency(opFlush, time.Now())
 
 	f := c.getFile(uint(fd))
 	if f == nil {
 		return statusEBADFD
 	}
 
-	err := c.flush(f)
+	err := c.fsync(f, false)
+	if err != nil {
+		return statusEIO
+	}
+	return statusOK
+}
+
+/*
+ * cfs_fdatasync flushes the data written to fd like cfs_flush, but it does not
+ * read the attributes of the inode back from the metanode.
+ */
+
+//export cfs_fdatasync
+func cfs_fdatasync(id C.int64_t, fd C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opFdatasync, time.Now())
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	if err := c.fsync(f, true); err != nil {
+		return statusEIO
+	}
+	return statusOK
+}
+
+/*
+ * cfs_sync_file_range flushes the data written to fd in [offset, offset+nbytes),
+ * to the end of the file if nbytes is 0, like sync_file_range(2). The data written
+ * elsewhere stays buffered. SYNC_FILE_RANGE_WRITE sends the buffered data of the
+ * range, with SYNC_FILE_RANGE_WAIT_BEFORE or SYNC_FILE_RANGE_WAIT_AFTER the call
+ * returns once the data of the range and its extent keys are persisted.
+ */
+
+//export cfs_sync_file_range
+func cfs_sync_file_range(id C.int64_t, fd C.int, offset C.off_t, nbytes C.off_t, flags C.uint) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opFdatasync, time.Now())
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	err := c.syncFileRange(f, int64(offset), int64(nbytes), uint32(flags))
+	if err == syscall.EINVAL {
+		return statusEINVAL
+	}
 	if err != nil {
 		return statusEIO
 	}
-	c.ic.Delete(f.ino)
 	return statusOK
 }
 
@@ -577,19 +1362,46 @@ func cfs_close(id C.int64_t, fd C.int) {
 	if !exist {
 		return
 	}
+	defer c.observeLatency(opClose, time.Now())
 	f := c.releaseFD(uint(fd))
 	if f != nil {
-		c.flush(f)
-		c.closeStream(f)
+		c.closeFile(f)
+	}
+}
+
+/*
+ * Flush the open files and create a snapshot version of the volume. The
+ * version returned is the checkpoint token, a client started with
+ * snapshotReadSeq set to it reads the files as they were at the checkpoint.
+ */
+
+//export cfs_checkpoint
+func cfs_checkpoint(id C.int64_t) C.int64_t {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return C.int64_t(statusEINVAL)
 	}
+
+	verSeq, err := c.checkpoint()
+	if err != nil {
+		return C.int64_t(errorToStatus(err))
+	}
+	return C.int64_t(verSeq)
 }
 
+/*
+ * Write to fd at off, or at the file offset if off is negative. On an O_DIRECT
+ * fd the offset and the size must be multiples of 4096, otherwise EINVAL is
+ * returned, and the data is written to the data nodes before it returns.
+ */
+
 //export cfs_write
 func cfs_write(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.off_t) C.ssize_t {
 	c, exist := getClient(int64(id))
 	if !exist {
 		return C.ssize_t(statusEINVAL)
 	}
+	defer c.observeLatency(opWrite, time.Now())
 
 	f := c.getFile(uint(fd))
 	if f == nil {
@@ -608,38 +1420,72 @@ func cfs_write(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.
 	hdr.Len = int(size)
 	hdr.Cap = int(size)
 
-	var flags int
-	var wait bool
-
-	if f.flags&uint32(C.O_DIRECT) != 0 || f.flags&uint32(C.O_SYNC) != 0 || f.flags&uint32(C.O_DSYNC) != 0 {
-		if proto.IsHot(c.volType) {
-			wait = true
-		}
-	}
-	if f.flags&uint32(C.O_APPEND) != 0 || proto.IsCold(c.volType) {
-		flags |= proto.FlagsAppend
-		flags |= proto.FlagsSyncWrite
-	}
-
-	n, err := c.write(f, int(off), buffer, flags)
+	n, err := c.fileWrite(f, int(off), buffer)
 	if err != nil {
 		if err == syscall.ENOSPC {
 			return C.ssize_t(statusENOSPC)
 		}
+		if err == syscall.EINVAL {
+			return C.ssize_t(statusEINVAL)
+		}
 		return C.ssize_t(statusEIO)
 	}
+	return C.ssize_t(n)
+}
+
+/*
+ * cfs_prepare_write gets fd ready for writing expected_bytes at its current
+ * position: the extent of the first write is allocated and the connection to
+ * its data node is opened up front. It is a hint, the data written afterwards
+ * is the same with or without it.
+ */
+
+//export cfs_prepare_write
+func cfs_prepare_write(id C.int64_t, fd C.int, expected_bytes C.int64_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
 
-	if wait {
-		if err = c.flush(f); err != nil {
-			return C.ssize_t(statusEIO)
-		}
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
 	}
 
-	return C.ssize_t(n)
+	accFlags := f.flags & uint32(C.O_ACCMODE)
+	if accFlags != uint32(C.O_WRONLY) && accFlags != uint32(C.O_RDWR) {
+		return statusEACCES
+	}
+	if expected_bytes <= 0 {
+		return statusEINVAL
+	}
+	if !proto.IsHot(c.volType) || f.appendBuf != nil {
+		return statusOK
+	}
+
+	f.pos.Lock()
+	offset := f.ioOffset(-1)
+	f.pos.Unlock()
+	if f.flags&uint32(C.O_APPEND) != 0 {
+		offset, _ = c.fileSize(f.ino)
+	}
+
+	c.ec.GetStreamer(f.ino).SetParentInode(f.pino)
+	if err := c.ec.PrepareWrite(f.ino, offset, int(expected_bytes)); err != nil {
+		return errorToStatus(err)
+	}
+	return statusOK
 }
 
-//export cfs_read
-func cfs_read(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.off_t) C.ssize_t {
+/*
+ * cfs_get_inode_stats fills buf with the io counters of the file of fd as json:
+ * the bytes and ops read and written by the client, and rowOps, the writes
+ * overwriting existing data. It returns the length of the json, or -ERANGE if
+ * buf is too small.
+ */
+
+//export cfs_get_inode_stats
+func cfs_get_inode_stats(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t) C.ssize_t {
 	c, exist := getClient(int64(id))
 	if !exist {
 		return C.ssize_t(statusEINVAL)
@@ -650,28 +1496,28 @@ func cfs_read(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.o
 		return C.ssize_t(statusEBADFD)
 	}
 
-	accFlags := f.flags & uint32(C.O_ACCMODE)
-	if accFlags == uint32(C.O_WRONLY) {
-		return C.ssize_t(statusEACCES)
+	data, err := json.Marshal(c.inodeStats.snapshot(f.ino))
+	if err != nil {
+		return C.ssize_t(statusEIO)
+	}
+	if len(data) > int(size) {
+		return C.ssize_t(errorToStatus(syscall.ERANGE))
 	}
 
 	var buffer []byte
-
 	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&buffer))
 	hdr.Data = uintptr(buf)
 	hdr.Len = int(size)
 	hdr.Cap = int(size)
-
-	n, err := c.read(f, int(off), buffer)
-	if err != nil {
-		return C.ssize_t(statusEIO)
-	}
-
-	return C.ssize_t(n)
+	return C.ssize_t(copy(buffer, data))
 }
 
-//export cfs_batch_get_inodes
-func cfs_batch_get_inodes(id C.int64_t, fd C.int, iids unsafe.Pointer, stats []C.struct_cfs_stat_info, count C.int) (n C.int) {
+/*
+ * cfs_reset_inode_stats clears the io counters of the file of fd.
+ */
+
+//export cfs_reset_inode_stats
+func cfs_reset_inode_stats(id C.int64_t, fd C.int) C.int {
 	c, exist := getClient(int64(id))
 	if !exist {
 		return statusEINVAL
@@ -681,396 +1527,351 @@ func cfs_batch_get_inodes(id C.int64_t, fd C.int, iids unsafe.Pointer, stats []C
 	if f == nil {
 		return statusEBADFD
 	}
+	c.inodeStats.reset(f.ino)
+	return statusOK
+}
 
-	var inodeIDS []uint64
-
-	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&inodeIDS))
-	hdr.Data = uintptr(iids)
-	hdr.Len = int(count)
-	hdr.Cap = int(count)
+//export cfs_append_record
+func cfs_append_record(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, outOffset *C.off_t) C.ssize_t {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return C.ssize_t(statusEINVAL)
+	}
+	defer c.observeLatency(opWrite, time.Now())
 
-	infos := c.mw.BatchInodeGet(inodeIDS)
-	if len(infos) > int(count) {
-		return statusEINVAL
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return C.ssize_t(statusEBADFD)
 	}
 
-	for i := 0; i < len(infos); i++ {
-		// fill up the stat
-		stats[i].ino = C.uint64_t(infos[i].Inode)
-		stats[i].size = C.uint64_t(infos[i].Size)
-		stats[i].blocks = C.uint64_t(infos[i].Size >> 9)
-		stats[i].nlink = C.uint32_t(infos[i].Nlink)
-		stats[i].blk_size = C.uint32_t(defaultBlkSize)
-		stats[i].uid = C.uint32_t(infos[i].Uid)
-		stats[i].gid = C.uint32_t(infos[i].Gid)
-
-		// fill up the mode
-		if proto.IsRegular(infos[i].Mode) {
-			stats[i].mode = C.uint32_t(C.S_IFREG) | C.uint32_t(infos[i].Mode&0777)
-		} else if proto.IsDir(infos[i].Mode) {
-			stats[i].mode = C.uint32_t(C.S_IFDIR) | C.uint32_t(infos[i].Mode&0777)
-		} else if proto.IsSymlink(infos[i].Mode) {
-			stats[i].mode = C.uint32_t(C.S_IFLNK) | C.uint32_t(infos[i].Mode&0777)
-		} else {
-			stats[i].mode = C.uint32_t(C.S_IFSOCK) | C.uint32_t(infos[i].Mode&0777)
-		}
+	accFlags := f.flags & uint32(C.O_ACCMODE)
+	if accFlags != uint32(C.O_WRONLY) && accFlags != uint32(C.O_RDWR) {
+		return C.ssize_t(statusEACCES)
+	}
+	if !proto.IsHot(c.volType) {
+		return C.ssize_t(statusEOPNOTSUPP)
+	}
 
-		// fill up the time struct
-		t := infos[i].AccessTime.UnixNano()
-		stats[i].atime = C.uint64_t(t / 1e9)
-		stats[i].atime_nsec = C.uint32_t(t % 1e9)
+	var buffer []byte
 
-		t = infos[i].ModifyTime.UnixNano()
-		stats[i].mtime = C.uint64_t(t / 1e9)
-		stats[i].mtime_nsec = C.uint32_t(t % 1e9)
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&buffer))
+	hdr.Data = uintptr(buf)
+	hdr.Len = int(size)
+	hdr.Cap = int(size)
 
-		t = infos[i].CreateTime.UnixNano()
-		stats[i].ctime = C.uint64_t(t / 1e9)
-		stats[i].ctime_nsec = C.uint32_t(t % 1e9)
+	// the coalesced appends of this fd land before the record
+	if f.appendBuf != nil {
+		if err := f.appendBuf.Flush(); err != nil {
+			return C.ssize_t(statusEIO)
+		}
 	}
 
-	n = C.int(len(infos))
-	return
-}
-
-//export cfs_refreshsummary
-func cfs_refreshsummary(id C.int64_t, path *C.char, goroutine_num C.int) C.int {
-	c, exist := getClient(int64(id))
-	if !exist {
-		return statusEINVAL
-	}
-	if !c.enableSummary {
-		return statusEINVAL
-	}
-	info, err := c.lookupPath(c.absPath(C.GoString(path)))
-	var ino uint64
+	offset, err := c.appendRecord(f, buffer)
 	if err != nil {
-		ino = proto.RootIno
-	} else {
-		ino = info.Inode
+		if err == syscall.ENOSPC {
+			return C.ssize_t(statusENOSPC)
+		}
+		return C.ssize_t(statusEIO)
 	}
-	goroutineNum := int32(goroutine_num)
-	err = c.mw.RefreshSummary_ll(ino, goroutineNum)
-	if err != nil {
-		return errorToStatus(err)
+	if outOffset != nil {
+		*outOffset = C.off_t(offset)
 	}
-	return statusOK
+	return C.ssize_t(len(buffer))
 }
 
 /*
- * Note that readdir is not thread-safe according to the POSIX spec.
+ * Read from fd at off, or at the file offset if off is negative. On an O_DIRECT
+ * fd the offset and the size must be multiples of 4096, otherwise EINVAL is
+ * returned, and the data is read from the data nodes bypassing the block cache.
  */
 
-//export cfs_readdir
-func cfs_readdir(id C.int64_t, fd C.int, dirents []C.struct_cfs_dirent, count C.int) (n C.int) {
+//export cfs_read
+func cfs_read(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.off_t) C.ssize_t {
 	c, exist := getClient(int64(id))
 	if !exist {
-		return statusEINVAL
+		return C.ssize_t(statusEINVAL)
 	}
+	defer c.observeLatency(opRead, time.Now())
 
 	f := c.getFile(uint(fd))
 	if f == nil {
-		return statusEBADFD
+		return C.ssize_t(statusEBADFD)
 	}
 
-	if f.dirp == nil {
-		f.dirp = &dirStream{}
-		dentries, err := c.mw.ReadDir_ll(f.ino)
-		if err != nil {
-			return errorToStatus(err)
-		}
-		f.dirp.dirents = dentries
+	accFlags := f.flags & uint32(C.O_ACCMODE)
+	if accFlags == uint32(C.O_WRONLY) {
+		return C.ssize_t(statusEACCES)
 	}
 
-	dirp := f.dirp
-	for dirp.pos < len(dirp.dirents) && n < count {
-		// fill up ino
-		dirents[n].ino = C.uint64_t(dirp.dirents[dirp.pos].Inode)
+	var buffer []byte
 
-		// fill up d_type
-		if proto.IsRegular(dirp.dirents[dirp.pos].Type) {
-			dirents[n].d_type = C.DT_REG
-		} else if proto.IsDir(dirp.dirents[dirp.pos].Type) {
-			dirents[n].d_type = C.DT_DIR
-		} else if proto.IsSymlink(dirp.dirents[dirp.pos].Type) {
-			dirents[n].d_type = C.DT_LNK
-		} else {
-			dirents[n].d_type = C.DT_UNKNOWN
-		}
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&buffer))
+	hdr.Data = uintptr(buf)
+	hdr.Len = int(size)
+	hdr.Cap = int(size)
 
-		// fill up name
-		nameLen := len(dirp.dirents[dirp.pos].Name)
-		if nameLen >= 256 {
-			nameLen = 255
+	n, err := c.fileRead(f, int(off), buffer)
+	if err != nil {
+		if err == syscall.EINVAL {
+			return C.ssize_t(statusEINVAL)
 		}
-		hdr := (*reflect.StringHeader)(unsafe.Pointer(&dirp.dirents[dirp.pos].Name))
-		C.memcpy(unsafe.Pointer(&dirents[n].name[0]), unsafe.Pointer(hdr.Data), C.size_t(nameLen))
-		dirents[n].name[nameLen] = 0
-		dirents[n].nameLen = C.uint32_t(nameLen)
-		// advance cursor
-		dirp.pos++
-		n++
+		return C.ssize_t(statusEIO)
 	}
-
-	return n
+	return C.ssize_t(n)
 }
 
-//export cfs_lsdir
-func cfs_lsdir(id C.int64_t, fd C.int, direntsInfo []C.struct_cfs_dirent_info, count C.int) (n C.int) {
+/*
+ * Vectored write like pwritev2(2), at off or at the file offset if off is -1. The
+ * buffers are written as one. RWF_DSYNC and RWF_SYNC make the call wait for the
+ * data to be written, RWF_HIPRI is ignored. RWF_NOWAIT, RWF_APPEND and unknown
+ * flags are not supported, EOPNOTSUPP is returned for them.
+ */
+
+//export cfs_pwritev2
+func cfs_pwritev2(id C.int64_t, fd C.int, iov *C.struct_iovec, iovcnt C.int, off C.off_t, flags C.int) C.ssize_t {
 	c, exist := getClient(int64(id))
 	if !exist {
-		return statusEINVAL
+		return C.ssize_t(statusEINVAL)
 	}
+	defer c.observeLatency(opWrite, time.Now())
 
 	f := c.getFile(uint(fd))
 	if f == nil {
-		return statusEBADFD
+		return C.ssize_t(statusEBADFD)
 	}
 
-	if f.dirp == nil {
-		f.dirp = &dirStream{}
-		dentries, err := c.mw.ReadDir_ll(f.ino)
-		if err != nil {
-			return errorToStatus(err)
-		}
-		f.dirp.dirents = dentries
+	accFlags := f.flags & uint32(C.O_ACCMODE)
+	if accFlags != uint32(C.O_WRONLY) && accFlags != uint32(C.O_RDWR) {
+		return C.ssize_t(statusEACCES)
+	}
+	if off < -1 || iovcnt < 0 || iovcnt > maxIovCnt {
+		return C.ssize_t(statusEINVAL)
 	}
 
-	dirp := f.dirp
-	inodeIDS := make([]uint64, count, count)
-	inodeMap := make(map[uint64]C.int)
-	for dirp.pos < len(dirp.dirents) && n < count {
-		inodeIDS[n] = dirp.dirents[dirp.pos].Inode
-		inodeMap[dirp.dirents[dirp.pos].Inode] = n
-		// fill up d_type
-		if proto.IsRegular(dirp.dirents[dirp.pos].Type) {
-			direntsInfo[n].d_type = C.DT_REG
-		} else if proto.IsDir(dirp.dirents[dirp.pos].Type) {
-			direntsInfo[n].d_type = C.DT_DIR
-		} else if proto.IsSymlink(dirp.dirents[dirp.pos].Type) {
-			direntsInfo[n].d_type = C.DT_LNK
-		} else {
-			direntsInfo[n].d_type = C.DT_UNKNOWN
-		}
-		nameLen := len(dirp.dirents[dirp.pos].Name)
-		if nameLen >= 256 {
-			nameLen = 255
-		}
-		hdr := (*reflect.StringHeader)(unsafe.Pointer(&dirp.dirents[dirp.pos].Name))
+	buffers := iovecBuffers(iov, int(iovcnt))
+	n, err := c.pwritev2(f, int(off), buffers, int(flags))
+	if err != nil {
+		return C.ssize_t(errorToStatus(err))
+	}
+	return C.ssize_t(n)
+}
 
-		C.memcpy(unsafe.Pointer(&direntsInfo[n].name[0]), unsafe.Pointer(hdr.Data), C.size_t(nameLen))
-		direntsInfo[n].name[nameLen] = 0
-		direntsInfo[n].nameLen = C.uint32_t(nameLen)
+/*
+ * Vectored read like preadv2(2), at off or at the file offset if off is -1. With
+ * RWF_NOWAIT EAGAIN is returned unless the data is in the block cache, RWF_HIPRI,
+ * RWF_DSYNC and RWF_SYNC are ignored. Other flags are not supported, EOPNOTSUPP is
+ * returned for them.
+ */
 
-		// advance cursor
-		dirp.pos++
-		n++
-	}
-	if n == 0 {
-		return n
-	}
-	infos := c.mw.BatchInodeGet(inodeIDS)
-	if len(infos) != int(n) {
-		return statusEIO
+//export cfs_preadv2
+func cfs_preadv2(id C.int64_t, fd C.int, iov *C.struct_iovec, iovcnt C.int, off C.off_t, flags C.int) C.ssize_t {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return C.ssize_t(statusEINVAL)
 	}
-	for i := 0; i < len(infos); i++ {
-		// fill up the stat
-		index := inodeMap[infos[i].Inode]
-		direntsInfo[index].stat.size = C.uint64_t(infos[i].Size)
+	defer c.observeLatency(opRead, time.Now())
 
-		// fill up the mode
-		if proto.IsRegular(infos[i].Mode) {
-			direntsInfo[index].stat.mode = C.uint32_t(C.S_IFREG) | C.uint32_t(infos[i].Mode&0777)
-		} else if proto.IsDir(infos[i].Mode) {
-			direntsInfo[index].stat.mode = C.uint32_t(C.S_IFDIR) | C.uint32_t(infos[i].Mode&0777)
-		} else if proto.IsSymlink(infos[i].Mode) {
-			direntsInfo[index].stat.mode = C.uint32_t(C.S_IFLNK) | C.uint32_t(infos[i].Mode&0777)
-		} else {
-			direntsInfo[index].stat.mode = C.uint32_t(C.S_IFSOCK) | C.uint32_t(infos[i].Mode&0777)
-		}
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return C.ssize_t(statusEBADFD)
+	}
 
-		// fill up the time struct
-		t := infos[index].AccessTime.UnixNano()
-		direntsInfo[index].stat.atime = C.uint64_t(t / 1e9)
-		direntsInfo[index].stat.atime_nsec = C.uint32_t(t % 1e9)
+	accFlags := f.flags & uint32(C.O_ACCMODE)
+	if accFlags == uint32(C.O_WRONLY) {
+		return C.ssize_t(statusEACCES)
+	}
+	if off < -1 || iovcnt < 0 || iovcnt > maxIovCnt {
+		return C.ssize_t(statusEINVAL)
+	}
 
-		t = infos[index].ModifyTime.UnixNano()
-		direntsInfo[index].stat.mtime = C.uint64_t(t / 1e9)
-		direntsInfo[index].stat.mtime_nsec = C.uint32_t(t % 1e9)
+	buffers := iovecBuffers(iov, int(iovcnt))
+	n, err := c.preadv2(f, int(off), buffers, int(flags))
+	if err != nil {
+		return C.ssize_t(errorToStatus(err))
 	}
-	return n
+	return C.ssize_t(n)
+}
 
+// iovecBuffers returns the buffers of the iovec array, they refer to the C memory.
+func iovecBuffers(iov *C.struct_iovec, iovcnt int) [][]byte {
+	var iovs []C.struct_iovec
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&iovs))
+	hdr.Data = uintptr(unsafe.Pointer(iov))
+	hdr.Len = iovcnt
+	hdr.Cap = iovcnt
+
+	buffers := make([][]byte, 0, iovcnt)
+	for _, v := range iovs {
+		var buffer []byte
+		hdr = (*reflect.SliceHeader)(unsafe.Pointer(&buffer))
+		hdr.Data = uintptr(v.iov_base)
+		hdr.Len = int(v.iov_len)
+		hdr.Cap = int(v.iov_len)
+		buffers = append(buffers, buffer)
+	}
+	return buffers
 }
 
-//export cfs_mkdirs
-func cfs_mkdirs(id C.int64_t, path *C.char, mode C.mode_t) C.int {
+/*
+ * Read the last size bytes of the file into buf, the whole file is read when
+ * it is shorter. The size of the file comes from the open stream or the cached
+ * inode, an expired inode is fetched from the metanode again.
+ * The file offset is not changed.
+ */
+
+//export cfs_read_tail
+func cfs_read_tail(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t) C.ssize_t {
 	c, exist := getClient(int64(id))
 	if !exist {
-		return statusEINVAL
+		return C.ssize_t(statusEINVAL)
 	}
+	defer c.observeLatency(opRead, time.Now())
 
-	start := time.Now()
-	var gerr error
-	var gino uint64
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return C.ssize_t(statusEBADFD)
+	}
 
-	dirpath := c.absPath(C.GoString(path))
-	if dirpath == "/" {
-		return statusEEXIST
+	accFlags := f.flags & uint32(C.O_ACCMODE)
+	if accFlags == uint32(C.O_WRONLY) {
+		return C.ssize_t(statusEACCES)
 	}
 
-	defer func() {
-		if gerr == nil {
-			auditlog.FormatLog("Mkdir", dirpath, "nil", gerr, time.Since(start).Microseconds(), gino, 0)
-		} else {
-			auditlog.FormatLog("Mkdir", dirpath, "nil", gerr, time.Since(start).Microseconds(), 0, 0)
-		}
-	}()
+	var buffer []byte
 
-	pino := proto.RootIno
-	dirs := strings.Split(dirpath, "/")
-	for _, dir := range dirs {
-		if dir == "/" || dir == "" {
-			continue
-		}
-		child, _, err := c.mw.Lookup_ll(pino, dir)
-		if err != nil {
-			if err == syscall.ENOENT {
-				info, err := c.mkdir(pino, dir, uint32(mode))
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&buffer))
+	hdr.Data = uintptr(buf)
+	hdr.Len = int(size)
+	hdr.Cap = int(size)
 
-				if err != nil {
-					if err != syscall.EEXIST {
-						gerr = err
-						return errorToStatus(err)
-					}
-				} else {
-					child = info.Inode
-				}
-			} else {
-				gerr = err
-				return errorToStatus(err)
-			}
-		}
-		pino = child
-		gino = child
+	n, err := c.readTail(f, buffer)
+	if err != nil {
+		return C.ssize_t(errorToStatus(err))
 	}
-
-	return 0
+	return C.ssize_t(n)
 }
 
-//export cfs_rmdir
-func cfs_rmdir(id C.int64_t, path *C.char) C.int {
+/*
+ * Copy a range of fdIn to fdOut like copy_file_range(2), a NULL offset means
+ * the file offset is used and advanced. With CFS_COPY_PRESERVE_MTIME the mtime
+ * of fdIn is set on fdOut once the data is copied. The ranges of a copy within
+ * a file must not overlap, EINVAL is returned otherwise.
+ */
+
+//export cfs_copy_file_range
+func cfs_copy_file_range(id C.int64_t, fdIn C.int, offIn *C.off_t, fdOut C.int, offOut *C.off_t, size C.size_t, flags C.uint) C.ssize_t {
 	c, exist := getClient(int64(id))
 	if !exist {
-		return statusEINVAL
+		return C.ssize_t(statusEINVAL)
 	}
-	start := time.Now()
-	var err error
-	var info *proto.InodeInfo
 
-	absPath := c.absPath(C.GoString(path))
-	defer func() {
-		if info == nil {
-			auditlog.FormatLog("Rmdir", absPath, "nil", err, time.Since(start).Microseconds(), 0, 0)
-		} else {
-			auditlog.FormatLog("Rmdir", absPath, "nil", err, time.Since(start).Microseconds(), info.Inode, 0)
-		}
-	}()
-	dirpath, name := gopath.Split(absPath)
-	dirInfo, err := c.lookupPath(dirpath)
-	if err != nil {
-		return errorToStatus(err)
+	src := c.getFile(uint(fdIn))
+	dst := c.getFile(uint(fdOut))
+	if src == nil || dst == nil {
+		return C.ssize_t(statusEBADFD)
 	}
-
-	info, err = c.mw.Delete_ll(dirInfo.Inode, name, true)
-	c.ic.Delete(dirInfo.Inode)
-	c.dc.Delete(absPath)
-	return errorToStatus(err)
-}
-
-//export cfs_unlink
-func cfs_unlink(id C.int64_t, path *C.char) C.int {
-	c, exist := getClient(int64(id))
-	if !exist {
-		return statusEINVAL
+	if uint32(flags)&^copyPreserveMtime != 0 || !src.regular || !dst.regular {
+		return C.ssize_t(statusEINVAL)
+	}
+	if src.flags&uint32(C.O_ACCMODE) == uint32(C.O_WRONLY) {
+		return C.ssize_t(statusEACCES)
+	}
+	accFlags := dst.flags & uint32(C.O_ACCMODE)
+	if accFlags != uint32(C.O_WRONLY) && accFlags != uint32(C.O_RDWR) {
+		return C.ssize_t(statusEACCES)
+	}
+	if dst.flags&uint32(C.O_APPEND) != 0 {
+		return C.ssize_t(statusEBADFD)
 	}
 
-	start := time.Now()
-	var err error
-	var info *proto.InodeInfo
-
-	absPath := c.absPath(C.GoString(path))
-	dirpath, name := gopath.Split(absPath)
-
-	defer func() {
-		if info == nil {
-			auditlog.FormatLog("Unlink", absPath, "nil", err, time.Since(start).Microseconds(), 0, 0)
-		} else {
-			auditlog.FormatLog("Unlink", absPath, "nil", err, time.Since(start).Microseconds(), info.Inode, 0)
-		}
-	}()
-	dirInfo, err := c.lookupPath(dirpath)
-	if err != nil {
-		return errorToStatus(err)
+	// lock the offsets in address order, so copies in opposite directions don't deadlock
+	locks := make([]*filePos, 0, 2)
+	if offIn == nil {
+		locks = append(locks, src.pos)
+	}
+	if offOut == nil && (offIn != nil || dst.pos != src.pos) {
+		locks = append(locks, dst.pos)
+	}
+	if len(locks) == 2 && uintptr(unsafe.Pointer(locks[0])) > uintptr(unsafe.Pointer(locks[1])) {
+		locks[0], locks[1] = locks[1], locks[0]
+	}
+	for _, pos := range locks {
+		pos.Lock()
+		defer pos.Unlock()
 	}
 
-	_, mode, err := c.mw.Lookup_ll(dirInfo.Inode, name)
-	if err != nil {
-		return errorToStatus(err)
+	srcOff := src.pos.off
+	if offIn != nil {
+		srcOff = int(*offIn)
 	}
-	if proto.IsDir(mode) {
-		return statusEISDIR
+	dstOff := dst.pos.off
+	if offOut != nil {
+		dstOff = int(*offOut)
+	}
+	if srcOff < 0 || dstOff < 0 {
+		return C.ssize_t(statusEINVAL)
 	}
 
-	info, err = c.mw.Delete_ll(dirInfo.Inode, name, false)
-	if err != nil {
-		return errorToStatus(err)
+	// the bytes copied before an error are reported like a short copy
+	n, err := c.copyFileRange(src, srcOff, dst, dstOff, int(size), uint32(flags))
+	if err != nil && n == 0 {
+		return C.ssize_t(errorToStatus(err))
 	}
 
-	if info != nil {
-		_ = c.mw.Evict(info.Inode)
-		c.ic.Delete(info.Inode)
+	if offIn != nil {
+		*offIn += C.off_t(n)
+	} else {
+		src.pos.off = srcOff + n
 	}
-	return 0
+	if offOut != nil {
+		*offOut += C.off_t(n)
+	} else {
+		dst.pos.off = dstOff + n
+	}
+	return C.ssize_t(n)
 }
 
-//export cfs_rename
-func cfs_rename(id C.int64_t, from *C.char, to *C.char) C.int {
+/*
+ * Return 1 if the range of the file is fully present in the block cache,
+ * so reading it requires no fetch from the data nodes, 0 if not.
+ */
+
+//export cfs_is_cached
+func cfs_is_cached(id C.int64_t, fd C.int, off C.off_t, size C.size_t) C.int {
 	c, exist := getClient(int64(id))
 	if !exist {
 		return statusEINVAL
 	}
 
-	start := time.Now()
-	var err error
-
-	absFrom := c.absPath(C.GoString(from))
-	absTo := c.absPath(C.GoString(to))
-
-	defer func() {
-		auditlog.FormatLog("Rename", absFrom, absTo, err, time.Since(start).Microseconds(), 0, 0)
-	}()
-
-	srcDirPath, srcName := gopath.Split(absFrom)
-	dstDirPath, dstName := gopath.Split(absTo)
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+	if off < 0 {
+		return statusEINVAL
+	}
+	if !proto.IsHot(c.volType) {
+		return statusEOPNOTSUPP
+	}
 
-	srcDirInfo, err := c.lookupPath(srcDirPath)
+	cached, err := c.ec.IsCached(f.ino, int(off), int(size))
 	if err != nil {
 		return errorToStatus(err)
 	}
-	dstDirInfo, err := c.lookupPath(dstDirPath)
-	if err != nil {
-		return errorToStatus(err)
+	if cached {
+		return 1
 	}
-
-	err = c.mw.Rename_ll(srcDirInfo.Inode, srcName, dstDirInfo.Inode, dstName, false)
-	c.ic.Delete(srcDirInfo.Inode)
-	c.ic.Delete(dstDirInfo.Inode)
-	c.dc.Delete(absFrom)
-	return errorToStatus(err)
+	return 0
 }
 
-//export cfs_fchmod
-func cfs_fchmod(id C.int64_t, fd C.int, mode C.mode_t) C.int {
+/*
+ * Make sure the range of the file is backed like posix_fallocate(3), the file
+ * grows to off+length if it is shorter and the extents of the growth are
+ * allocated. ENOSPC is returned if they can't be, the file is left alone then.
+ */
+
+//export cfs_posix_fallocate
+func cfs_posix_fallocate(id C.int64_t, fd C.int, off C.off_t, length C.off_t) C.int {
 	c, exist := getClient(int64(id))
 	if !exist {
 		return statusEINVAL
@@ -1080,337 +1881,2637 @@ func cfs_fchmod(id C.int64_t, fd C.int, mode C.mode_t) C.int {
 	if f == nil {
 		return statusEBADFD
 	}
-
-	info, err := c.mw.InodeGet_ll(f.ino)
-	if err != nil {
-		return errorToStatus(err)
+	if off < 0 || length <= 0 {
+		return statusEINVAL
+	}
+	accFlags := f.flags & uint32(C.O_ACCMODE)
+	if accFlags == uint32(C.O_RDONLY) {
+		return statusEBADFD
+	}
+	if !f.regular {
+		return statusEOPNOTSUPP
 	}
 
-	err = c.setattr(info, proto.AttrMode, uint32(mode), 0, 0, 0, 0)
-	if err != nil {
+	if err := c.posixFallocate(f, int(off), int(length)); err != nil {
 		return errorToStatus(err)
 	}
-	c.ic.Delete(info.Inode)
 	return statusOK
 }
 
-//export cfs_getsummary
-func cfs_getsummary(id C.int64_t, path *C.char, summary *C.struct_cfs_summary_info, useCache *C.char, goroutine_num C.int) C.int {
+/*
+ * cfs_fallocate is fallocate(2) of the modes the volume can serve: 0 grows the
+ * file like cfs_posix_fallocate, FALLOC_FL_PUNCH_HOLE|FALLOC_FL_KEEP_SIZE makes
+ * the range within the file read back as zeros. EOPNOTSUPP for the others.
+ */
+
+//export cfs_fallocate
+func cfs_fallocate(id C.int64_t, fd C.int, mode C.int, off C.off_t, length C.off_t) C.int {
 	c, exist := getClient(int64(id))
 	if !exist {
 		return statusEINVAL
 	}
 
-	info, err := c.lookupPath(c.absPath(C.GoString(path)))
-	if err != nil {
-		return errorToStatus(err)
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
 	}
-
-	if strings.ToLower(C.GoString(useCache)) == "true" {
-		cacheSummaryInfo := c.sc.Get(info.Inode)
-		if cacheSummaryInfo != nil {
-			summary.files = C.int64_t(cacheSummaryInfo.Files)
-			summary.subdirs = C.int64_t(cacheSummaryInfo.Subdirs)
-			summary.fbytes = C.int64_t(cacheSummaryInfo.Fbytes)
-			return statusOK
-		}
+	if off < 0 || length <= 0 {
+		return statusEINVAL
+	}
+	accFlags := f.flags & uint32(C.O_ACCMODE)
+	if accFlags == uint32(C.O_RDONLY) {
+		return statusEBADFD
+	}
+	if !f.regular {
+		return statusEOPNOTSUPP
 	}
 
-	if !proto.IsDir(info.Mode) {
-		return statusENOTDIR
+	var err error
+	switch int(mode) {
+	case 0:
+		err = c.posixFallocate(f, int(off), int(length))
+	case fallocPunchHole | fallocKeepSize:
+		err = c.punchHole(f, int(off), int(length))
+	default:
+		return statusEOPNOTSUPP
 	}
-	goroutineNum := int32(goroutine_num)
-	summaryInfo, err := c.mw.GetSummary_ll(info.Inode, goroutineNum)
 	if err != nil {
 		return errorToStatus(err)
 	}
-	if strings.ToLower(C.GoString(useCache)) != "false" {
-		c.sc.Put(info.Inode, &summaryInfo)
-	}
-	summary.files = C.int64_t(summaryInfo.Files)
-	summary.subdirs = C.int64_t(summaryInfo.Subdirs)
-	summary.fbytes = C.int64_t(summaryInfo.Fbytes)
 	return statusOK
 }
 
-// internals
+//export cfs_lseek
+func cfs_lseek(id C.int64_t, fd C.int, offset C.off_t, whence C.int) C.off_t {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return C.off_t(statusEINVAL)
+	}
 
-func (c *client) absPath(path string) string {
-	p := gopath.Clean(path)
-	if !gopath.IsAbs(p) {
-		p = gopath.Join(c.cwd, p)
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return C.off_t(statusEBADFD)
 	}
-	return gopath.Clean(p)
-}
 
-func (c *client) start() (err error) {
-	var masters = strings.Split(c.masterAddr, ",")
-	if c.logDir != "" {
-		if c.logLevel == "" {
-			c.logLevel = "WARN"
-		}
-		level := parseLogLevel(c.logLevel)
-		log.InitLog(c.logDir, "libcfs", level, nil, log.DefaultLogLeftSpaceLimit)
-		stat.NewStatistic(c.logDir, "libcfs", int64(stat.DefaultStatLogSize), stat.DefaultTimeOutUs, true)
+	f.pos.Lock()
+	defer f.pos.Unlock()
+
+	var base int
+	switch whence {
+	case C.SEEK_SET:
+	case C.SEEK_CUR:
+		base = f.pos.off
+	case C.SEEK_END:
+		base, _ = c.fileSize(f.ino)
+	default:
+		return C.off_t(statusEINVAL)
 	}
-	proto.InitBufferPool(int64(32768))
-	if c.readBlockThread == 0 {
-		c.readBlockThread = 10
+	if base+int(offset) < 0 {
+		return C.off_t(statusEINVAL)
 	}
-	if c.writeBlockThread == 0 {
-		c.writeBlockThread = 10
+	f.pos.off = base + int(offset)
+	return C.off_t(f.pos.off)
+}
+
+//export cfs_dup
+func cfs_dup(id C.int64_t, fd C.int) C.int {
+	return dupFD(id, fd, true)
+}
+
+//export cfs_open_dup
+func cfs_open_dup(id C.int64_t, fd C.int) C.int {
+	return dupFD(id, fd, false)
+}
+
+/*
+ * cfs_dup2 makes newfd refer to the file of oldfd like dup2(2), the file open at
+ * newfd is closed first. newfd shares the offset with oldfd, and it must not be
+ * below the fdBase of the client. EBUSY is returned if newfd is opened again
+ * while it is being closed.
+ */
+
+//export cfs_dup2
+func cfs_dup2(id C.int64_t, oldfd C.int, newfd C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
 	}
-	if err = c.loadConfFromMaster(masters); err != nil {
-		return
+
+	f := c.getFile(uint(oldfd))
+	if f == nil {
+		return statusEBADFD
 	}
-	if err = c.checkPermission(); err != nil {
-		err = errors.NewErrorf("check permission failed: %v", err)
-		syslog.Println(err)
-		return
+	if newfd < 0 || uint(newfd) < c.fdBase || uint(newfd) > maxFdNum {
+		return statusEBADFD
 	}
 
-	if c.enableAudit {
-		_, err = auditlog.InitAudit(c.logDir, "clientSdk", int64(auditlog.DefaultAuditLogSize))
-		if err != nil {
-			log.LogWarnf("Init audit log fail: %v", err)
-		}
+	newFile := c.dup2File(f, uint(newfd))
+	if newFile == nil {
+		return errorToStatus(syscall.EBUSY)
 	}
+	return C.int(newFile.fd)
+}
 
-	if c.enableSummary {
-		c.sc = fs.NewSummaryCache(fs.DefaultSummaryExpiration, fs.MaxSummaryCache)
+func dupFD(id C.int64_t, fd C.int, sharePos bool) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	newFile := c.dupFile(f, sharePos)
+	if newFile == nil {
+		return statusEMFILE
+	}
+	return C.int(newFile.fd)
+}
+
+/*
+ * cfs_fcntl supports F_DUPFD, F_DUPFD_CLOEXEC, F_GETFD, F_SETFD, F_GETFL and
+ * F_SETFL. The fd of F_DUPFD is the lowest free fd of the client, arg is ignored.
+ */
+
+//export cfs_fcntl
+func cfs_fcntl(id C.int64_t, fd C.int, cmd C.int, arg C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	ret, err := c.fcntl(f, int(cmd), int(arg))
+	if err != nil {
+		return errorToStatus(err)
+	}
+	return C.int(ret)
+}
+
+/*
+ * cfs_fcntl_lock supports F_GETLK, F_SETLK and F_SETLKW of advisory byte-range
+ * locks. The locks are owned by the fd like open file description locks, and
+ * they are released when the fd is closed. F_GETLK reports l_pid as -1.
+ */
+
+//export cfs_fcntl_lock
+func cfs_fcntl_lock(id C.int64_t, fd C.int, cmd C.int, lk *C.struct_flock) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	flock := syscall.Flock_t{
+		Type:   int16(lk.l_type),
+		Whence: int16(lk.l_whence),
+		Start:  int64(lk.l_start),
+		Len:    int64(lk.l_len),
+	}
+	if err := c.fcntlLock(f, int(cmd), &flock); err != nil {
+		return errorToStatus(err)
+	}
+	if int(cmd) == syscall.F_GETLK {
+		lk.l_type = C.short(flock.Type)
+		lk.l_whence = C.short(flock.Whence)
+		lk.l_start = C.off_t(flock.Start)
+		lk.l_len = C.off_t(flock.Len)
+		lk.l_pid = C.pid_t(flock.Pid)
+	}
+	return statusOK
+}
+
+//export cfs_batch_get_inodes
+func cfs_batch_get_inodes(id C.int64_t, fd C.int, iids unsafe.Pointer, stats []C.struct_cfs_stat_info, count C.int) (n C.int) {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	var inodeIDS []uint64
+
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&inodeIDS))
+	hdr.Data = uintptr(iids)
+	hdr.Len = int(count)
+	hdr.Cap = int(count)
+
+	infos := c.mw.BatchInodeGet(inodeIDS)
+	if len(infos) > int(count) {
+		return statusEINVAL
+	}
+
+	for i := 0; i < len(infos); i++ {
+		// fill up the stat
+		stats[i].ino = C.uint64_t(infos[i].Inode)
+		stats[i].size = C.uint64_t(infos[i].Size)
+		stats[i].blocks = C.uint64_t(infos[i].Size >> 9)
+		stats[i].nlink = C.uint32_t(infos[i].Nlink)
+		stats[i].blk_size = C.uint32_t(defaultBlkSize)
+		stats[i].uid = C.uint32_t(infos[i].Uid)
+		stats[i].gid = C.uint32_t(infos[i].Gid)
+
+		// fill up the mode
+		if proto.IsRegular(infos[i].Mode) {
+			stats[i].mode = C.uint32_t(C.S_IFREG) | C.uint32_t(infos[i].Mode&0777)
+		} else if proto.IsDir(infos[i].Mode) {
+			stats[i].mode = C.uint32_t(C.S_IFDIR) | C.uint32_t(infos[i].Mode&0777)
+		} else if proto.IsSymlink(infos[i].Mode) {
+			stats[i].mode = C.uint32_t(C.S_IFLNK) | C.uint32_t(infos[i].Mode&0777)
+		} else {
+			stats[i].mode = C.uint32_t(C.S_IFSOCK) | C.uint32_t(infos[i].Mode&0777)
+		}
+
+		// fill up the time struct
+		t := infos[i].AccessTime.UnixNano()
+		stats[i].atime = C.uint64_t(t / 1e9)
+		stats[i].atime_nsec = C.uint32_t(t % 1e9)
+
+		t = infos[i].ModifyTime.UnixNano()
+		stats[i].mtime = C.uint64_t(t / 1e9)
+		stats[i].mtime_nsec = C.uint32_t(t % 1e9)
+
+		t = infos[i].CreateTime.UnixNano()
+		stats[i].ctime = C.uint64_t(t / 1e9)
+		stats[i].ctime_nsec = C.uint32_t(t % 1e9)
+	}
+
+	n = C.int(len(infos))
+	return
+}
+
+//export cfs_refreshsummary
+func cfs_refreshsummary(id C.int64_t, path *C.char, goroutine_num C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	if !c.enableSummary {
+		return statusEINVAL
+	}
+	info, err := c.lookupPath(c.absPath(C.GoString(path)))
+	var ino uint64
+	if err != nil {
+		ino = proto.RootIno
+	} else {
+		ino = info.Inode
+	}
+	goroutineNum := int32(goroutine_num)
+	err = c.mw.RefreshSummary_ll(ino, goroutineNum)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	return statusOK
+}
+
+/*
+ * Note that readdir is not thread-safe according to the POSIX spec.
+ */
+
+//export cfs_readdir
+func cfs_readdir(id C.int64_t, fd C.int, dirents []C.struct_cfs_dirent, count C.int) (n C.int) {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opReaddir, time.Now())
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	if err := c.loadDirStream(f); err != nil {
+		return errorToStatus(err)
+	}
+
+	dirp := f.dirp
+	for dirp.pos < len(dirp.dirents) && n < count {
+		// fill up ino
+		dirents[n].ino = C.uint64_t(dirp.dirents[dirp.pos].Inode)
+
+		// fill up d_type
+		if proto.IsRegular(dirp.dirents[dirp.pos].Type) {
+			dirents[n].d_type = C.DT_REG
+		} else if proto.IsDir(dirp.dirents[dirp.pos].Type) {
+			dirents[n].d_type = C.DT_DIR
+		} else if proto.IsSymlink(dirp.dirents[dirp.pos].Type) {
+			dirents[n].d_type = C.DT_LNK
+		} else {
+			dirents[n].d_type = C.DT_UNKNOWN
+		}
+
+		// fill up name
+		nameLen := len(dirp.dirents[dirp.pos].Name)
+		if nameLen >= 256 {
+			nameLen = 255
+		}
+		hdr := (*reflect.StringHeader)(unsafe.Pointer(&dirp.dirents[dirp.pos].Name))
+		C.memcpy(unsafe.Pointer(&dirents[n].name[0]), unsafe.Pointer(hdr.Data), C.size_t(nameLen))
+		dirents[n].name[nameLen] = 0
+		dirents[n].nameLen = C.uint32_t(nameLen)
+		// advance cursor
+		dirp.pos++
+		n++
+	}
+
+	return n
+}
+
+/*
+ * cfs_getdents reads the entries like cfs_readdir into struct dirent, d_off of
+ * an entry is the cookie of the position after it, which can be passed to
+ * cfs_seekdir to resume the stream from there.
+ */
+
+//export cfs_getdents
+func cfs_getdents(id C.int64_t, fd C.int, dirents []C.struct_dirent, count C.int) (n C.int) {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opReaddir, time.Now())
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	if err := c.loadDirStream(f); err != nil {
+		return errorToStatus(err)
+	}
+
+	for n < count {
+		dentry, off, ok := f.dirp.next()
+		if !ok {
+			break
+		}
+		dirents[n].d_ino = C.ulong(dentry.Inode)
+		dirents[n].d_off = C.long(off)
+		dirents[n].d_reclen = C.ushort(unsafe.Sizeof(dirents[n]))
+		dirents[n].d_type = direntType(dentry.Type)
+		name := direntName(dentry.Name)
+		hdr := (*reflect.StringHeader)(unsafe.Pointer(&name))
+		C.memcpy(unsafe.Pointer(&dirents[n].d_name[0]), unsafe.Pointer(hdr.Data), C.size_t(len(name)))
+		dirents[n].d_name[len(name)] = 0
+		n++
+	}
+	return n
+}
+
+/*
+ * cfs_getdents64 fills buf with the struct dirent64 records of the next entries
+ * like getdents64(2), and returns the bytes filled, 0 at the end of the dir.
+ * The records are 8 bytes aligned, d_reclen is the size of a record.
+ */
+
+//export cfs_getdents64
+func cfs_getdents64(id C.int64_t, fd C.int, buf unsafe.Pointer, count C.size_t) C.ssize_t {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return C.ssize_t(statusEINVAL)
+	}
+	defer c.observeLatency(opReaddir, time.Now())
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return C.ssize_t(statusEBADFD)
+	}
+
+	var buffer []byte
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&buffer))
+	hdr.Data = uintptr(buf)
+	hdr.Len = int(count)
+	hdr.Cap = int(count)
+
+	n, err := c.getdents64(f, buffer)
+	if err != nil {
+		return C.ssize_t(errorToStatus(err))
+	}
+	return C.ssize_t(n)
+}
+
+//export cfs_telldir
+func cfs_telldir(id C.int64_t, fd C.int) C.off_t {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return C.off_t(statusEINVAL)
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return C.off_t(statusEBADFD)
+	}
+	if f.dirp == nil {
+		return 0
+	}
+	return C.off_t(f.dirp.tell())
+}
+
+//export cfs_seekdir
+func cfs_seekdir(id C.int64_t, fd C.int, off C.off_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+	if f.dirp == nil {
+		f.dirp = &dirStream{}
+	}
+	return errorToStatus(f.dirp.seek(int64(off)))
+}
+
+//export cfs_lsdir
+func cfs_lsdir(id C.int64_t, fd C.int, direntsInfo []C.struct_cfs_dirent_info, count C.int) (n C.int) {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opLsdir, time.Now())
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	if err := c.loadDirStream(f); err != nil {
+		return errorToStatus(err)
+	}
+
+	dirp := f.dirp
+	inodeIDS := make([]uint64, count, count)
+	inodeMap := make(map[uint64]C.int)
+	for dirp.pos < len(dirp.dirents) && n < count {
+		inodeIDS[n] = dirp.dirents[dirp.pos].Inode
+		inodeMap[dirp.dirents[dirp.pos].Inode] = n
+		// fill up d_type
+		if proto.IsRegular(dirp.dirents[dirp.pos].Type) {
+			direntsInfo[n].d_type = C.DT_REG
+		} else if proto.IsDir(dirp.dirents[dirp.pos].Type) {
+			direntsInfo[n].d_type = C.DT_DIR
+		} else if proto.IsSymlink(dirp.dirents[dirp.pos].Type) {
+			direntsInfo[n].d_type = C.DT_LNK
+		} else {
+			direntsInfo[n].d_type = C.DT_UNKNOWN
+		}
+		nameLen := len(dirp.dirents[dirp.pos].Name)
+		if nameLen >= 256 {
+			nameLen = 255
+		}
+		hdr := (*reflect.StringHeader)(unsafe.Pointer(&dirp.dirents[dirp.pos].Name))
+
+		C.memcpy(unsafe.Pointer(&direntsInfo[n].name[0]), unsafe.Pointer(hdr.Data), C.size_t(nameLen))
+		direntsInfo[n].name[nameLen] = 0
+		direntsInfo[n].nameLen = C.uint32_t(nameLen)
+
+		// advance cursor
+		dirp.pos++
+		n++
+	}
+	if n == 0 {
+		return n
+	}
+	infos := c.mw.BatchInodeGet(inodeIDS)
+	if len(infos) != int(n) {
+		return statusEIO
+	}
+	for i := 0; i < len(infos); i++ {
+		// fill up the stat
+		index := inodeMap[infos[i].Inode]
+		direntsInfo[index].stat.size = C.uint64_t(infos[i].Size)
+
+		// fill up the mode
+		if proto.IsRegular(infos[i].Mode) {
+			direntsInfo[index].stat.mode = C.uint32_t(C.S_IFREG) | C.uint32_t(infos[i].Mode&0777)
+		} else if proto.IsDir(infos[i].Mode) {
+			direntsInfo[index].stat.mode = C.uint32_t(C.S_IFDIR) | C.uint32_t(infos[i].Mode&0777)
+		} else if proto.IsSymlink(infos[i].Mode) {
+			direntsInfo[index].stat.mode = C.uint32_t(C.S_IFLNK) | C.uint32_t(infos[i].Mode&0777)
+		} else {
+			direntsInfo[index].stat.mode = C.uint32_t(C.S_IFSOCK) | C.uint32_t(infos[i].Mode&0777)
+		}
+
+		// fill up the time struct
+		t := infos[index].AccessTime.UnixNano()
+		direntsInfo[index].stat.atime = C.uint64_t(t / 1e9)
+		direntsInfo[index].stat.atime_nsec = C.uint32_t(t % 1e9)
+
+		t = infos[index].ModifyTime.UnixNano()
+		direntsInfo[index].stat.mtime = C.uint64_t(t / 1e9)
+		direntsInfo[index].stat.mtime_nsec = C.uint32_t(t % 1e9)
+	}
+	return n
+
+}
+
+//export cfs_mkdirs
+func cfs_mkdirs(id C.int64_t, path *C.char, mode C.mode_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opMkdirs, time.Now())
+
+	start := time.Now()
+	var gerr error
+	var gino uint64
+
+	dirpath := c.absPath(C.GoString(path))
+	if dirpath == "/" {
+		return statusEEXIST
+	}
+
+	defer func() {
+		if gerr == nil {
+			auditlog.FormatLog("Mkdir", dirpath, "nil", gerr, time.Since(start).Microseconds(), gino, 0)
+		} else {
+			auditlog.FormatLog("Mkdir", dirpath, "nil", gerr, time.Since(start).Microseconds(), 0, 0)
+		}
+	}()
+
+	pino := proto.RootIno
+	dirs := strings.Split(dirpath, "/")
+	curPath := "/"
+	for _, dir := range dirs {
+		if dir == "/" || dir == "" {
+			continue
+		}
+		curPath = gopath.Join(curPath, dir)
+		child, _, err := c.mw.Lookup_ll(pino, dir)
+		if err != nil {
+			if err == syscall.ENOENT {
+				info, err := c.mkdir(pino, dir, uint32(mode))
+				c.dentryChanged(curPath)
+
+				if err != nil {
+					if err != syscall.EEXIST {
+						gerr = err
+						return errorToStatus(err)
+					}
+				} else {
+					child = info.Inode
+				}
+			} else {
+				gerr = err
+				return errorToStatus(err)
+			}
+		}
+		pino = child
+		gino = child
+	}
+
+	return 0
+}
+
+//export cfs_rmdir
+func cfs_rmdir(id C.int64_t, path *C.char) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opRmdir, time.Now())
+	start := time.Now()
+	var err error
+	var info *proto.InodeInfo
+
+	absPath := c.absPath(C.GoString(path))
+	defer func() {
+		if info == nil {
+			auditlog.FormatLog("Rmdir", absPath, "nil", err, time.Since(start).Microseconds(), 0, 0)
+		} else {
+			auditlog.FormatLog("Rmdir", absPath, "nil", err, time.Since(start).Microseconds(), info.Inode, 0)
+		}
+	}()
+	dirpath, name := gopath.Split(absPath)
+	dirInfo, err := c.lookupPath(dirpath)
+	if err != nil {
+		return errorToStatus(err)
+	}
+
+	info, err = c.mw.Delete_ll(dirInfo.Inode, name, true)
+	c.ic.Delete(dirInfo.Inode)
+	c.dentryChanged(absPath)
+	return errorToStatus(err)
+}
+
+//export cfs_unlink
+func cfs_unlink(id C.int64_t, path *C.char) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opUnlink, time.Now())
+
+	start := time.Now()
+	var err error
+	var info *proto.InodeInfo
+
+	absPath := c.absPath(C.GoString(path))
+	dirpath, name := gopath.Split(absPath)
+
+	defer func() {
+		if info == nil {
+			auditlog.FormatLog("Unlink", absPath, "nil", err, time.Since(start).Microseconds(), 0, 0)
+		} else {
+			auditlog.FormatLog("Unlink", absPath, "nil", err, time.Since(start).Microseconds(), info.Inode, 0)
+		}
+	}()
+	dirInfo, err := c.lookupPath(dirpath)
+	if err != nil {
+		return errorToStatus(err)
+	}
+
+	_, mode, err := c.mw.Lookup_ll(dirInfo.Inode, name)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	if proto.IsDir(mode) {
+		return statusEISDIR
+	}
+
+	info, err = c.mw.Delete_ll(dirInfo.Inode, name, false)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	c.dentryChanged(absPath)
+
+	if info != nil {
+		_ = c.mw.Evict(info.Inode)
+		c.ic.Delete(info.Inode)
+	}
+	return 0
+}
+
+/*
+ * cfs_flink links the file open at fd to path, like linkat(2) of fd with
+ * AT_EMPTY_PATH. The unnamed file of O_TMPFILE is kept once linked.
+ */
+
+//export cfs_flink
+func cfs_flink(id C.int64_t, fd C.int, path *C.char) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+	if !f.regular {
+		return statusEPERM
+	}
+
+	absPath := c.absPath(C.GoString(path))
+	dirpath, name := gopath.Split(absPath)
+	dirInfo, err := c.lookupPath(dirpath)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	if !proto.IsDir(dirInfo.Mode) {
+		return statusENOTDIR
+	}
+	err = c.linkFile(f, dirInfo.Inode, name)
+	c.dentryChanged(absPath)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	return statusOK
+}
+
+/*
+ * cfs_linkat links oldpath relative to the dir fd olddirfd to newpath relative to
+ * newdirfd, a symlink at the end of oldpath is followed only with AT_SYMLINK_FOLLOW.
+ * With AT_EMPTY_PATH an empty oldpath links the file open at olddirfd, which gives
+ * a name to an O_TMPFILE file.
+ */
+
+//export cfs_linkat
+func cfs_linkat(id C.int64_t, oldDirfd C.int, oldPath *C.char, newDirfd C.int, newPath *C.char, flags C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	err := c.linkat(int(oldDirfd), C.GoString(oldPath), int(newDirfd), C.GoString(newPath), int(flags))
+	return errorToStatus(err)
+}
+
+//export cfs_rename
+func cfs_rename(id C.int64_t, from *C.char, to *C.char) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opRename, time.Now())
+
+	start := time.Now()
+	var err error
+
+	absFrom := c.absPath(C.GoString(from))
+	absTo := c.absPath(C.GoString(to))
+
+	defer func() {
+		auditlog.FormatLog("Rename", absFrom, absTo, err, time.Since(start).Microseconds(), 0, 0)
+	}()
+
+	srcDirPath, srcName := gopath.Split(absFrom)
+	dstDirPath, dstName := gopath.Split(absTo)
+
+	srcDirInfo, err := c.lookupPath(srcDirPath)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	dstDirInfo, err := c.lookupPath(dstDirPath)
+	if err != nil {
+		return errorToStatus(err)
+	}
+
+	err = c.mw.Rename_ll(srcDirInfo.Inode, srcName, dstDirInfo.Inode, dstName, false)
+	c.ic.Delete(srcDirInfo.Inode)
+	c.ic.Delete(dstDirInfo.Inode)
+	c.dentryChanged(absFrom, absTo)
+	return errorToStatus(err)
+}
+
+/*
+ * cfs_renameat2 renames like renameat2(2), the relative paths are resolved
+ * against the dir fds or the cwd for AT_FDCWD. RENAME_NOREPLACE fails with
+ * EEXIST if to exists, RENAME_EXCHANGE swaps from and to in one transaction.
+ * Without flags, a file at to is replaced.
+ */
+
+//export cfs_renameat2
+func cfs_renameat2(id C.int64_t, fromDirfd C.int, from *C.char, toDirfd C.int, to *C.char, flags C.uint) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opRename, time.Now())
+
+	start := time.Now()
+	var (
+		absFrom, absTo string
+		err            error
+	)
+	defer func() {
+		auditlog.FormatLog("Rename", absFrom, absTo, err, time.Since(start).Microseconds(), 0, 0)
+	}()
+
+	if absFrom, err = c.atPath(int(fromDirfd), C.GoString(from)); err != nil {
+		return errorToStatus(err)
+	}
+	if absTo, err = c.atPath(int(toDirfd), C.GoString(to)); err != nil {
+		return errorToStatus(err)
+	}
+	err = c.renameFlags(absFrom, absTo, uint32(flags))
+	return errorToStatus(err)
+}
+
+/*
+ * cfs_rename_with_attr renames the file and sets its mode, uid and gid as
+ * selected by valid in one transaction, so the file never shows up at the
+ * destination with other attributes. Other bits of valid are ignored.
+ */
+
+//export cfs_rename_with_attr
+func cfs_rename_with_attr(id C.int64_t, from *C.char, to *C.char, stat *C.struct_cfs_stat_info, valid C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opRename, time.Now())
+
+	start := time.Now()
+	var err error
+
+	absFrom := c.absPath(C.GoString(from))
+	absTo := c.absPath(C.GoString(to))
+
+	defer func() {
+		auditlog.FormatLog("Rename", absFrom, absTo, err, time.Since(start).Microseconds(), 0, 0)
+	}()
+
+	err = c.renameWithAttr(absFrom, absTo, uint32(valid), uint32(stat.mode), uint32(stat.uid), uint32(stat.gid))
+	return errorToStatus(err)
+}
+
+/*
+ * cfs_rename_batch renames count files in one transaction, either all the files
+ * are renamed or none is. A path can appear in one pair only, and existing
+ * destinations are not overwritten.
+ */
+
+//export cfs_rename_batch
+func cfs_rename_batch(id C.int64_t, pairs *C.struct_cfs_rename_pair, count C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	if count <= 0 || pairs == nil {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opRename, time.Now())
+
+	start := time.Now()
+	var err error
+
+	var cPairs []C.struct_cfs_rename_pair
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&cPairs))
+	hdr.Data = uintptr(unsafe.Pointer(pairs))
+	hdr.Len = int(count)
+	hdr.Cap = int(count)
+
+	absPairs := make([][2]string, 0, len(cPairs))
+	for _, p := range cPairs {
+		absPairs = append(absPairs, [2]string{c.absPath(C.GoString(p.from)), c.absPath(C.GoString(p.to))})
+	}
+
+	defer func() {
+		for _, p := range absPairs {
+			auditlog.FormatLog("RenameBatch", p[0], p[1], err, time.Since(start).Microseconds(), 0, 0)
+		}
+	}()
+
+	err = c.renameBatch(absPairs)
+	return errorToStatus(err)
+}
+
+//export cfs_fchmod
+func cfs_fchmod(id C.int64_t, fd C.int, mode C.mode_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	info, err := c.mw.InodeGet_ll(f.ino)
+	if err != nil {
+		return errorToStatus(err)
+	}
+
+	err = c.setattr(info, proto.AttrMode, uint32(mode), 0, 0, 0, 0)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	c.ic.Delete(info.Inode)
+	return statusOK
+}
+
+/*
+ * cfs_setxattr sets the xattr name of the file at path to the size bytes of value,
+ * following a symlink at the end of path. With XATTR_CREATE it fails with EEXIST if
+ * the xattr exists, with XATTR_REPLACE with ENODATA if it is missing, without flags
+ * the xattr is created or replaced. The name must be in the user, trusted, security
+ * or system namespace.
+ */
+
+//export cfs_setxattr
+func cfs_setxattr(id C.int64_t, path *C.char, name *C.char, value unsafe.Pointer, size C.size_t, flags C.int) C.int {
+	return setxattrAt(id, path, name, value, size, flags, true)
+}
+
+/*
+ * cfs_lsetxattr is cfs_setxattr of a symlink itself.
+ */
+
+//export cfs_lsetxattr
+func cfs_lsetxattr(id C.int64_t, path *C.char, name *C.char, value unsafe.Pointer, size C.size_t, flags C.int) C.int {
+	return setxattrAt(id, path, name, value, size, flags, false)
+}
+
+func setxattrAt(id C.int64_t, path *C.char, name *C.char, value unsafe.Pointer, size C.size_t, flags C.int, follow bool) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opSetattr, time.Now())
+
+	absPath := c.absPath(C.GoString(path))
+	info, err := c.lookupPath(absPath)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	if follow {
+		if info, _, err = c.resolveSymlink(absPath, info); err != nil {
+			return errorToStatus(err)
+		}
+	}
+	err = c.setxattr(info, C.GoString(name), C.GoBytes(value, C.int(size)), int(flags))
+	return errorToStatus(err)
+}
+
+/*
+ * cfs_fsetxattr is cfs_setxattr of the file open at fd.
+ */
+
+//export cfs_fsetxattr
+func cfs_fsetxattr(id C.int64_t, fd C.int, name *C.char, value unsafe.Pointer, size C.size_t, flags C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	defer c.observeLatency(opSetattr, time.Now())
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+	info, err := c.mw.InodeGet_ll(f.ino)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	err = c.setxattr(info, C.GoString(name), C.GoBytes(value, C.int(size)), int(flags))
+	return errorToStatus(err)
+}
+
+//export cfs_getsummary
+func cfs_getsummary(id C.int64_t, path *C.char, summary *C.struct_cfs_summary_info, useCache *C.char, goroutine_num C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	info, err := c.lookupPath(c.absPath(C.GoString(path)))
+	if err != nil {
+		return errorToStatus(err)
+	}
+
+	if strings.ToLower(C.GoString(useCache)) == "true" {
+		cacheSummaryInfo := c.sc.Get(info.Inode)
+		if cacheSummaryInfo != nil {
+			summary.files = C.int64_t(cacheSummaryInfo.Files)
+			summary.subdirs = C.int64_t(cacheSummaryInfo.Subdirs)
+			summary.fbytes = C.int64_t(cacheSummaryInfo.Fbytes)
+			return statusOK
+		}
+	}
+
+	if !proto.IsDir(info.Mode) {
+		return statusENOTDIR
+	}
+	goroutineNum := int32(goroutine_num)
+	summaryInfo, err := c.mw.GetSummary_ll(info.Inode, goroutineNum)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	if strings.ToLower(C.GoString(useCache)) != "false" {
+		c.sc.Put(info.Inode, &summaryInfo)
+	}
+	summary.files = C.int64_t(summaryInfo.Files)
+	summary.subdirs = C.int64_t(summaryInfo.Subdirs)
+	summary.fbytes = C.int64_t(summaryInfo.Fbytes)
+	return statusOK
+}
+
+// internals
+
+func (c *client) absPath(path string) string {
+	p := gopath.Clean(path)
+	if !gopath.IsAbs(p) {
+		p = gopath.Join(c.cwd, p)
+	}
+	return gopath.Clean(p)
+}
+
+// atPath returns the absolute path of path relative to the dir fd dirfd, the
+// path of the dir is the one it was opened with.
+func (c *client) atPath(dirfd int, path string) (string, error) {
+	if gopath.IsAbs(path) || dirfd == atFdcwd {
+		return c.absPath(path), nil
+	}
+	f := c.getFile(uint(dirfd))
+	if f == nil {
+		return "", syscall.EBADF
+	}
+	if f.path == "" {
+		return "", syscall.ENOTDIR
+	}
+	return gopath.Join(f.path, path), nil
+}
+
+// fileAt resolves path relative to the dir fd dirfd like atPath, except that an
+// empty path with AT_EMPTY_PATH is the file open at dirfd, or the cwd for AT_FDCWD.
+// It returns either the file or the absolute path, an empty path without
+// AT_EMPTY_PATH is EINVAL.
+func (c *client) fileAt(dirfd int, path string, flags int) (*file, string, error) {
+	if path != "" {
+		absPath, err := c.atPath(dirfd, path)
+		return nil, absPath, err
+	}
+	if flags&atEmptyPath == 0 {
+		return nil, "", syscall.EINVAL
+	}
+	if dirfd == atFdcwd {
+		return nil, c.absPath("."), nil
+	}
+	f := c.getFile(uint(dirfd))
+	if f == nil {
+		return nil, "", syscall.EBADF
+	}
+	return f, "", nil
+}
+
+func (c *client) fstatat(dirfd int, path string, flags int) (*proto.InodeInfo, error) {
+	if flags&^(atEmptyPath|atSymlinkNofollow|atNoAutomount) != 0 {
+		return nil, syscall.EINVAL
+	}
+	f, absPath, err := c.fileAt(dirfd, path, flags)
+	if err != nil {
+		return nil, err
+	}
+	if f != nil {
+		return c.mw.InodeGet_ll(f.ino)
+	}
+	info, err := c.lookupPath(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if flags&atSymlinkNofollow == 0 {
+		info, _, err = c.resolveSymlink(absPath, info)
+	}
+	return info, err
+}
+
+// processIDs returns the uid and the gids of the process, the effective ones or
+// the real ones.
+var processIDs = func(effective bool) (uid uint32, gids []uint32) {
+	if effective {
+		uid, gids = uint32(os.Geteuid()), []uint32{uint32(os.Getegid())}
+	} else {
+		uid, gids = uint32(os.Getuid()), []uint32{uint32(os.Getgid())}
+	}
+	groups, _ := os.Getgroups()
+	for _, g := range groups {
+		gids = append(gids, uint32(g))
+	}
+	return
+}
+
+func (c *client) faccessat(dirfd int, path string, mode uint32, flags int) error {
+	if mode&^(accessRead|accessWrite|accessExec) != 0 || flags&^(atEaccess|atSymlinkNofollow|atEmptyPath) != 0 {
+		return syscall.EINVAL
+	}
+	info, err := c.fstatat(dirfd, path, flags&^atEaccess)
+	if err != nil {
+		return err
+	}
+	if !c.enforceAccess || mode == 0 {
+		return nil
+	}
+	uid, gids := processIDs(flags&atEaccess != 0)
+	if !accessAllowed(info, mode, uid, gids) {
+		return syscall.EACCES
+	}
+	return nil
+}
+
+// accessAllowed returns if the access mode of R_OK, W_OK and X_OK to the inode
+// is granted to uid and gids. Root is granted the reads and the writes, and the
+// execution if anyone may execute the file.
+func accessAllowed(info *proto.InodeInfo, mode uint32, uid uint32, gids []uint32) bool {
+	perm := info.Mode & 0777
+	if uid == 0 {
+		return mode&accessExec == 0 || proto.IsDir(info.Mode) || perm&0111 != 0
+	}
+	var granted uint32
+	if uid == info.Uid {
+		granted = perm >> 6
+	} else {
+		granted = perm
+		for _, gid := range gids {
+			if gid == info.Gid {
+				granted = perm >> 3
+				break
+			}
+		}
+	}
+	return mode&^(granted&7) == 0
+}
+
+func (c *client) linkat(oldDirfd int, oldPath string, newDirfd int, newPath string, flags int) error {
+	if flags&^(atEmptyPath|atSymlinkFollow) != 0 {
+		return syscall.EINVAL
+	}
+	f, absOld, err := c.fileAt(oldDirfd, oldPath, flags)
+	if err != nil {
+		return err
+	}
+	absNew, err := c.atPath(newDirfd, newPath)
+	if err != nil {
+		return err
+	}
+	dirpath, name := gopath.Split(absNew)
+	dirInfo, err := c.lookupPath(dirpath)
+	if err != nil {
+		return err
+	}
+	if !proto.IsDir(dirInfo.Mode) {
+		return syscall.ENOTDIR
+	}
+
+	if f != nil {
+		if !f.regular {
+			return syscall.EPERM
+		}
+		err = c.linkFile(f, dirInfo.Inode, name)
+	} else {
+		var info *proto.InodeInfo
+		if info, err = c.lookupPath(absOld); err != nil {
+			return err
+		}
+		if flags&atSymlinkFollow != 0 {
+			if info, _, err = c.resolveSymlink(absOld, info); err != nil {
+				return err
+			}
+		}
+		if proto.IsDir(info.Mode) {
+			return syscall.EPERM
+		}
+		if _, err = c.mw.Link(dirInfo.Inode, name, info.Inode); err == nil {
+			c.ic.Delete(info.Inode)
+		}
+	}
+	c.dentryChanged(absNew)
+	return err
+}
+
+func (c *client) renameFlags(absFrom, absTo string, flags uint32) (err error) {
+	if flags&^(renameNoReplace|renameExchange) != 0 || flags == renameNoReplace|renameExchange {
+		return syscall.EINVAL
+	}
+	srcDirPath, srcName := gopath.Split(absFrom)
+	dstDirPath, dstName := gopath.Split(absTo)
+	srcDirInfo, err := c.lookupPath(srcDirPath)
+	if err != nil {
+		return err
+	}
+	dstDirInfo, err := c.lookupPath(dstDirPath)
+	if err != nil {
+		return err
+	}
+
+	switch flags {
+	case renameExchange:
+		err = c.mw.RenameExchange_ll(srcDirInfo.Inode, srcName, dstDirInfo.Inode, dstName)
+	case renameNoReplace:
+		err = c.mw.Rename_ll(srcDirInfo.Inode, srcName, dstDirInfo.Inode, dstName, false)
+	default:
+		err = c.mw.Rename_ll(srcDirInfo.Inode, srcName, dstDirInfo.Inode, dstName, true)
+	}
+	c.ic.Delete(srcDirInfo.Inode)
+	c.ic.Delete(dstDirInfo.Inode)
+	c.dentryChanged(absFrom, absTo)
+	return err
+}
+
+// initCaches rebuilds the inode and dentry caches if their size or ttl has been
+// changed from the defaults they are created with.
+func (c *client) initCaches() {
+	if c.inodeCacheSize != fs.MaxInodeCache || c.inodeCacheTTL != fs.DefaultInodeExpiration {
+		c.ic = fs.NewInodeCache(c.inodeCacheTTL, c.inodeCacheSize)
+	}
+	if c.dentryCacheTTL != fs.DentryValidDuration {
+		c.dc = fs.NewDentryCacheWithTTL(c.dentryCacheTTL)
+		c.dc.SetNegativeTTL(c.negativeDentryTTL)
+	}
+}
+
+func (c *client) start() (err error) {
+	var masters = strings.Split(c.masterAddr, ",")
+	if c.logDir != "" {
+		if c.logLevel == "" {
+			c.logLevel = "WARN"
+		}
+		level := parseLogLevel(c.logLevel)
+		log.InitLog(c.logDir, "libcfs", level, nil, log.DefaultLogLeftSpaceLimit)
+		stat.NewStatistic(c.logDir, "libcfs", int64(stat.DefaultStatLogSize), stat.DefaultTimeOutUs, true)
+	}
+	c.setupSignals()
+	c.initCaches()
+	proto.InitBufferPool(int64(32768))
+	if c.readBlockThread == 0 {
+		c.readBlockThread = 10
+	}
+	if c.writeBlockThread == 0 {
+		c.writeBlockThread = 10
+	}
+	if err = c.loadConfFromMaster(masters); err != nil {
+		return
+	}
+	c.dev = volumeDev(c.cluster, c.volName)
+	if err = c.checkPermission(); err != nil {
+		err = errors.NewErrorf("check permission failed: %v", err)
+		syslog.Println(err)
+		return
+	}
+
+	if c.enableAudit {
+		_, err = auditlog.InitAudit(c.logDir, "clientSdk", int64(auditlog.DefaultAuditLogSize))
+		if err != nil {
+			log.LogWarnf("Init audit log fail: %v", err)
+		}
+	}
+
+	if c.enableSummary {
+		c.sc = fs.NewSummaryCache(fs.DefaultSummaryExpiration, fs.MaxSummaryCache)
+	}
+	if c.enableBcache {
+		c.bc = bcache.NewBcacheClient()
+	}
+	var ebsc *blobstore.BlobStoreClient
+	if c.ebsEndpoint != "" {
+		if ebsc, err = blobstore.NewEbsClient(access.Config{
+			ConnMode: access.NoLimitConnMode,
+			Consul: access.ConsulConfig{
+				Address: c.ebsEndpoint,
+			},
+			MaxSizePutOnce: MaxSizePutOnce,
+			Logger: &access.Logger{
+				Filename: gopath.Join(c.logDir, "libcfs/ebs.log"),
+			},
+		}); err != nil {
+			return
+		}
+	}
+	var mw *meta.MetaWrapper
+	if mw, err = meta.NewMetaWrapper(&meta.MetaConfig{
+		Volume:        c.volName,
+		Masters:       masters,
+		ValidateOwner: false,
+		EnableSummary: c.enableSummary,
+		VerReadSeq:    c.snapshotReadSeq,
+	}); err != nil {
+		log.LogErrorf("newClient NewMetaWrapper failed(%v)", err)
+		return err
+	}
+	var ec *stream.ExtentClient
+	if ec, err = stream.NewExtentClient(&stream.ExtentConfig{
+		Volume:            c.volName,
+		VolumeType:        c.volType,
+		Masters:           masters,
+		FollowerRead:      c.followerRead,
+		WriteAffinity:     c.writeAffinity,
+		WriteZone:         c.writeZone,
+		OnAppendExtentKey: mw.AppendExtentKey,
+		OnSplitExtentKey:  mw.SplitExtentKey,
+		OnGetExtents:      mw.GetExtents,
+		OnTruncate:        mw.Truncate,
+		BcacheEnable:      c.enableBcache,
+		OnLoadBcache:      c.bc.Get,
+		OnCacheBcache:     c.bc.Put,
+		OnEvictBcache:     c.bc.Evict,
+		DisableMetaCache:  true,
+		VerReadSeq:        c.snapshotReadSeq,
+	}); err != nil {
+		log.LogErrorf("newClient NewExtentClient failed(%v)", err)
+		return
+	}
+
+	c.mw = mw
+	c.ec = ec
+	c.ebsc = ebsc
+	c.watcher = newWatcher(mw, c.watchInterval)
+	if c.profPort != "" {
+		startProfServer(c.profHost, c.profPort)
+	}
+	if c.pushAddr != "" {
+		startExporter(c.cluster, c.pushAddr)
+		c.exportLatency = true
+	}
+	return nil
+}
+
+// setupSignals ignores SIGHUP and SIGTERM if the client is configured to.
+// The disposition is process-wide, so it is left to the embedding application
+// unless it opts in.
+func (c *client) setupSignals() {
+	if !c.ignoreSignals {
+		return
+	}
+	ignoreSignalsOnce.Do(func() {
+		signal.Ignore(syscall.SIGHUP, syscall.SIGTERM)
+	})
+}
+
+func (c *client) checkPermission() (err error) {
+	if c.accessKey == "" || c.secretKey == "" {
+		err = errors.New("invalid AccessKey or SecretKey")
+		return
+	}
+
+	// checkPermission
+	var mc = masterSDK.NewMasterClientFromString(c.masterAddr, false)
+	var userInfo *proto.UserInfo
+	if userInfo, err = mc.UserAPI().GetAKInfo(c.accessKey); err != nil {
+		return
+	}
+	if userInfo.SecretKey != c.secretKey {
+		err = proto.ErrNoPermission
+		return
+	}
+	var policy = userInfo.Policy
+	if policy.IsOwn(c.volName) {
+		return
+	}
+	// read write
+	if policy.IsAuthorized(c.volName, c.subDir, proto.POSIXWriteAction) &&
+		policy.IsAuthorized(c.volName, c.subDir, proto.POSIXReadAction) {
+		return
+	}
+	// read only
+	if policy.IsAuthorized(c.volName, c.subDir, proto.POSIXReadAction) &&
+		!policy.IsAuthorized(c.volName, c.subDir, proto.POSIXWriteAction) {
+		return
+	}
+	err = proto.ErrNoPermission
+	return
+}
+
+func (c *client) allocFD(ino uint64, flags, mode uint32, fileCache bool, fileSize uint64, parentInode uint64) *file {
+	c.fdlock.Lock()
+	defer c.fdlock.Unlock()
+	fd, ok := c.fdset.NextClear(c.fdBase)
+	if !ok || fd > maxFdNum {
+		return nil
+	}
+	return c.setFD(fd, ino, flags, mode, fileCache, fileSize, parentInode)
+}
+
+// allocFDAt allocates the given fd, it returns nil if the fd is in use.
+func (c *client) allocFDAt(fd uint, ino uint64, flags, mode uint32, fileCache bool, fileSize uint64, parentInode uint64) *file {
+	c.fdlock.Lock()
+	defer c.fdlock.Unlock()
+	if c.fdset.Test(fd) {
+		return nil
+	}
+	return c.setFD(fd, ino, flags, mode, fileCache, fileSize, parentInode)
+}
+
+// setFD makes fd refer to a new file of ino. The caller must hold fdlock.
+func (c *client) setFD(fd uint, ino uint64, flags, mode uint32, fileCache bool, fileSize uint64, parentInode uint64) *file {
+	c.fdset.Set(fd)
+	f := &file{fd: fd, ino: ino, flags: flags, mode: mode, pino: parentInode, fileCache: fileCache, pos: &filePos{}}
+	f.cloexec = flags&uint32(C.O_CLOEXEC) != 0
+	if proto.IsCold(c.volType) {
+		clientConf := blobstore.ClientConfig{
+			VolName:         c.volName,
+			VolType:         c.volType,
+			BlockSize:       c.ebsBlockSize,
+			Ino:             ino,
+			Bc:              c.bc,
+			Mw:              c.mw,
+			Ec:              c.ec,
+			Ebsc:            c.ebsc,
+			EnableBcache:    c.enableBcache,
+			WConcurrency:    c.writeBlockThread,
+			ReadConcurrency: c.readBlockThread,
+			CacheAction:     c.cacheAction,
+			FileCache:       fileCache,
+			FileSize:        fileSize,
+			CacheThreshold:  c.cacheThreshold,
+		}
+		f.fileWriter.FreeCache()
+		switch flags & 0xff {
+		case syscall.O_RDONLY:
+			f.fileReader = blobstore.NewReader(clientConf)
+			f.fileWriter = nil
+		case syscall.O_WRONLY:
+			f.fileWriter = blobstore.NewWriter(clientConf)
+			f.fileReader = nil
+		case syscall.O_RDWR:
+			f.fileReader = blobstore.NewReader(clientConf)
+			f.fileWriter = blobstore.NewWriter(clientConf)
+		default:
+			f.fileWriter = blobstore.NewWriter(clientConf)
+			f.fileReader = nil
+		}
+	}
+	c.fdmap[fd] = f
+	return f
+}
+
+// dupFile allocates a new fd of the file. With sharePos the new fd shares the file
+// offset with f like dup(2), otherwise it starts with a copy of the current offset.
+func (c *client) dupFile(f *file, sharePos bool) *file {
+	size, _ := c.fileSize(f.ino)
+	return c.initDup(f, c.allocFD(f.ino, f.flags, f.mode, f.fileCache, uint64(size), f.pino), sharePos)
+}
+
+// dup2File makes newfd refer to the file of f like dup2(2), sharing the offset. The
+// file open at newfd is closed first, nil is returned if newfd is taken meanwhile.
+func (c *client) dup2File(f *file, newfd uint) *file {
+	if newfd == f.fd {
+		return f
+	}
+	if old := c.releaseFD(newfd); old != nil {
+		c.closeFile(old)
+	}
+	size, _ := c.fileSize(f.ino)
+	return c.initDup(f, c.allocFDAt(newfd, f.ino, f.flags, f.mode, f.fileCache, uint64(size), f.pino), true)
+}
+
+// initDup sets up newFile, allocated as a duplicate of f.
+func (c *client) initDup(f *file, newFile *file, sharePos bool) *file {
+	if newFile == nil {
+		return nil
+	}
+	// the close-on-exec flag is not inherited by the duplicate
+	newFile.cloexec = false
+	newFile.path = f.path
+	if sharePos {
+		newFile.pos = f.pos
+	} else {
+		f.pos.Lock()
+		newFile.pos.off = f.pos.off
+		f.pos.Unlock()
+	}
+	if f.tmp != nil {
+		f.tmp.Lock()
+		f.tmp.refs++
+		f.tmp.Unlock()
+		newFile.tmp = f.tmp
+	}
+	if f.regular {
+		newFile.regular = true
+		c.openStream(newFile)
+		c.openAppendBuffer(newFile)
+	}
+	return newFile
+}
+
+// the per-call flags of cfs_preadv2 and cfs_pwritev2
+const (
+	rwfHipri  = int(C.RWF_HIPRI)
+	rwfDsync  = int(C.RWF_DSYNC)
+	rwfSync   = int(C.RWF_SYNC)
+	rwfNowait = int(C.RWF_NOWAIT)
+
+	// the iovecs of a vectored io, IOV_MAX
+	maxIovCnt = 1024
+)
+
+const (
+	// the status flags returned by F_GETFL
+	fileStatusFlags = uint32(C.O_ACCMODE | C.O_APPEND | C.O_NONBLOCK | C.O_DIRECT | C.O_SYNC | C.O_DSYNC | C.O_NOATIME)
+	// the status flags changeable by F_SETFL
+	fileSetFlags = uint32(C.O_APPEND | C.O_NONBLOCK | C.O_NOATIME)
+)
+
+func (c *client) fcntl(f *file, cmd, arg int) (int, error) {
+	switch cmd {
+	case syscall.F_DUPFD, int(C.F_DUPFD_CLOEXEC):
+		newFile := c.dupFile(f, true)
+		if newFile == nil {
+			return 0, syscall.EMFILE
+		}
+		newFile.cloexec = cmd == int(C.F_DUPFD_CLOEXEC)
+		return int(newFile.fd), nil
+	case syscall.F_GETFD:
+		if f.cloexec {
+			return syscall.FD_CLOEXEC, nil
+		}
+		return 0, nil
+	case syscall.F_SETFD:
+		f.cloexec = arg&syscall.FD_CLOEXEC != 0
+		return 0, nil
+	case syscall.F_GETFL:
+		return int(f.flags & fileStatusFlags), nil
+	case syscall.F_SETFL:
+		c.setFileFlags(f, uint32(arg))
+		return 0, nil
+	default:
+		return 0, syscall.EINVAL
+	}
+}
+
+func (c *client) fcntlLock(f *file, cmd int, flock *syscall.Flock_t) error {
+	var base int64
+	switch int(flock.Whence) {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		f.pos.Lock()
+		base = int64(f.pos.off)
+		f.pos.Unlock()
+	case io.SeekEnd:
+		size, _ := c.fileSize(f.ino)
+		base = int64(size)
+	default:
+		return syscall.EINVAL
+	}
+	lk := rangeLock{owner: uint64(f.fd), typ: flock.Type, start: base + flock.Start}
+	switch {
+	case flock.Len > 0:
+		lk.end = lk.start + flock.Len
+	case flock.Len == 0:
+		lk.end = math.MaxInt64
+	default:
+		lk.end = lk.start
+		lk.start += flock.Len
+	}
+	if lk.start < 0 || lk.end < lk.start {
+		return syscall.EINVAL
+	}
+
+	switch cmd {
+	case syscall.F_GETLK:
+		if lk.typ != syscall.F_RDLCK && lk.typ != syscall.F_WRLCK {
+			return syscall.EINVAL
+		}
+		l, found := c.rangeLocks.getLock(f.ino, lk)
+		if !found {
+			flock.Type = syscall.F_UNLCK
+			return nil
+		}
+		flock.Type = l.typ
+		flock.Whence = io.SeekStart
+		flock.Start = l.start
+		flock.Len = 0
+		if l.end != math.MaxInt64 {
+			flock.Len = l.end - l.start
+		}
+		flock.Pid = -1
+		return nil
+	case syscall.F_SETLK, syscall.F_SETLKW:
+		switch lk.typ {
+		case syscall.F_RDLCK:
+			if f.flags&uint32(C.O_ACCMODE) == uint32(C.O_WRONLY) {
+				return syscall.EBADF
+			}
+		case syscall.F_WRLCK:
+			if f.flags&uint32(C.O_ACCMODE) == uint32(C.O_RDONLY) {
+				return syscall.EBADF
+			}
+		case syscall.F_UNLCK:
+		default:
+			return syscall.EINVAL
+		}
+		return c.rangeLocks.setLock(f.ino, lk, cmd == syscall.F_SETLKW)
+	default:
+		return syscall.EINVAL
+	}
+}
+
+// setFileFlags changes the status flags of f which can be set by F_SETFL,
+// the others in flags are ignored.
+func (c *client) setFileFlags(f *file, flags uint32) {
+	appendBefore := f.flags&uint32(C.O_APPEND) != 0
+	f.flags = f.flags&^fileSetFlags | flags&fileSetFlags
+	appendAfter := f.flags&uint32(C.O_APPEND) != 0
+	if !f.regular || appendBefore == appendAfter {
+		return
+	}
+	if appendAfter {
+		c.openAppendBuffer(f)
+		return
+	}
+	if f.appendBuf != nil {
+		if err := f.appendBuf.Close(); err != nil {
+			log.LogErrorf("setFileFlags: flush appended data failed, ino(%v) err(%v)", f.ino, err)
+		}
+		f.appendBuf = nil
+	}
+}
+
+// ioOffset returns the offset of an io, off < 0 means the io is done at the file offset.
+func (f *file) ioOffset(off int) int {
+	if off < 0 {
+		return f.pos.off
+	}
+	return off
+}
+
+func (c *client) getFile(fd uint) *file {
+	c.fdlock.Lock()
+	f := c.fdmap[fd]
+	c.fdlock.Unlock()
+	return f
+}
+
+// closeFile releases the locks, the data and the stream of a file whose fd is
+// released.
+func (c *client) closeFile(f *file) {
+	c.rangeLocks.releaseOwner(f.ino, uint64(f.fd))
+	c.flush(f)
+	c.closeStream(f)
+	if f.tmp != nil {
+		c.closeTmpFile(f)
+	}
+}
+
+func (c *client) releaseFD(fd uint) *file {
+	c.fdlock.Lock()
+	defer c.fdlock.Unlock()
+	f, ok := c.fdmap[fd]
+	if !ok {
+		return nil
+	}
+	delete(c.fdmap, fd)
+	c.fdset.Clear(fd)
+	c.ic.Delete(f.ino)
+	return f
+}
+
+// volumeDev returns the st_dev of the files of the volume of the cluster. MySQL
+// and others take two files with the same st_dev and inode for the same file, so
+// it differs between the volumes and is stable across the clients of a volume.
+func volumeDev(cluster, volName string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(cluster))
+	h.Write([]byte{0})
+	h.Write([]byte(volName))
+	if dev := h.Sum64(); dev != 0 {
+		return dev
+	}
+	return 1
+}
+
+func fillStat(stat *C.struct_cfs_stat_info, info *proto.InodeInfo) {
+	// fill up the stat
+	stat.ino = C.uint64_t(info.Inode)
+	stat.size = C.uint64_t(info.Size)
+	stat.nlink = C.uint32_t(info.Nlink)
+	stat.blk_size = C.uint32_t(defaultBlkSize)
+	stat.uid = C.uint32_t(info.Uid)
+	stat.gid = C.uint32_t(info.Gid)
+
+	if info.Size%512 != 0 {
+		stat.blocks = C.uint64_t(info.Size>>9) + 1
+	} else {
+		stat.blocks = C.uint64_t(info.Size >> 9)
+	}
+	// fill up the mode
+	if proto.IsRegular(info.Mode) {
+		stat.mode = C.uint32_t(C.S_IFREG) | C.uint32_t(info.Mode&0777)
+	} else if proto.IsDir(info.Mode) {
+		stat.mode = C.uint32_t(C.S_IFDIR) | C.uint32_t(info.Mode&0777)
+	} else if proto.IsSymlink(info.Mode) {
+		stat.mode = C.uint32_t(C.S_IFLNK) | C.uint32_t(info.Mode&0777)
+	} else {
+		stat.mode = C.uint32_t(C.S_IFSOCK) | C.uint32_t(info.Mode&0777)
+	}
+
+	// fill up the time struct
+	t := info.AccessTime.UnixNano()
+	stat.atime = C.uint64_t(t / 1e9)
+	stat.atime_nsec = C.uint32_t(t % 1e9)
+
+	t = info.ModifyTime.UnixNano()
+	stat.mtime = C.uint64_t(t / 1e9)
+	stat.mtime_nsec = C.uint32_t(t % 1e9)
+
+	t = info.CreateTime.UnixNano()
+	stat.ctime = C.uint64_t(t / 1e9)
+	stat.ctime_nsec = C.uint32_t(t % 1e9)
+}
+
+func (c *client) lookupPath(path string) (*proto.InodeInfo, error) {
+	path = gopath.Clean(path)
+	useCache := !c.isNoCachePath(path)
+
+	var ino uint64
+	var ok bool
+	if useCache {
+		var negative bool
+		if ino, negative, ok = c.getDentry(path); negative {
+			return nil, syscall.ENOENT
+		}
+	}
+	if !ok {
+		epoch := c.de.current()
+		inoInterval, err := c.mw.LookupPath(path)
+		if err != nil {
+			if err == syscall.ENOENT && useCache {
+				c.putNegativeDentry(path, epoch)
+			}
+			return nil, err
+		}
+		if useCache {
+			c.putDentry(path, inoInterval, epoch)
+		}
+		ino = inoInterval
+	}
+	if useCache {
+		if info := c.ic.Get(ino); info != nil {
+			return info, nil
+		}
+	}
+	info, err := c.mw.InodeGet_ll(ino)
+	if err != nil {
+		return nil, err
+	}
+	if useCache {
+		c.ic.Put(info)
+	}
+
+	return info, nil
+}
+
+// resolveSymlink follows the symlink info at path, the relative targets are
+// resolved against the dir of the link. It returns the inode and the path the
+// links end at, ELOOP after maxSymlinkHops links.
+func (c *client) resolveSymlink(path string, info *proto.InodeInfo) (*proto.InodeInfo, string, error) {
+	var err error
+	for hops := 0; proto.IsSymlink(info.Mode); hops++ {
+		if hops == maxSymlinkHops {
+			return nil, "", syscall.ELOOP
+		}
+		target := string(info.Target)
+		if !gopath.IsAbs(target) {
+			target = gopath.Join(gopath.Dir(path), target)
+		}
+		path = gopath.Clean(target)
+		if info, err = c.lookupPath(path); err != nil {
+			return nil, "", err
+		}
+	}
+	return info, path, nil
+}
+
+// setxattr sets the xattr name of info to value, flags are the XATTR_* flags of
+// setxattr(2).
+func (c *client) setxattr(info *proto.InodeInfo, name string, value []byte, flags int) error {
+	if len(name) == 0 || len(name) > xattrNameMax {
+		return syscall.ERANGE
+	}
+	if len(value) > xattrSizeMax {
+		return syscall.E2BIG
+	}
+	var metaFlags int
+	switch flags {
+	case 0:
+	case xattrCreate:
+		metaFlags = meta.XAttrCreate
+	case xattrReplace:
+		metaFlags = meta.XAttrReplace
+	default:
+		return syscall.EINVAL
+	}
+	namespace := ""
+	for _, ns := range xattrNamespaces {
+		if strings.HasPrefix(name, ns) && len(name) > len(ns) {
+			namespace = ns
+			break
+		}
+	}
+	if namespace == "" {
+		return syscall.EOPNOTSUPP
+	}
+	// as linux, the user xattrs are only on the regular files and the dirs
+	if namespace == "user." && !proto.IsRegular(info.Mode) && !proto.IsDir(info.Mode) {
+		return syscall.EPERM
+	}
+	return c.mw.XAttrSetFlags_ll(info.Inode, []byte(name), value, metaFlags)
+}
+
+// statBatch is lookupPath of many paths, the inodes missing in the inode cache
+// are fetched together, so there is one request per meta partition instead of
+// one per path.
+func (c *client) statBatch(paths []string) (infos []*proto.InodeInfo, errs []error) {
+	infos = make([]*proto.InodeInfo, len(paths))
+	errs = make([]error, len(paths))
+	inos := make([]uint64, len(paths))
+	useCache := make([]bool, len(paths))
+	pending := make(map[uint64]struct{})
+	for i, path := range paths {
+		path = gopath.Clean(path)
+		useCache[i] = !c.isNoCachePath(path)
+
+		var ok bool
+		if useCache[i] {
+			var negative bool
+			if inos[i], negative, ok = c.getDentry(path); negative {
+				errs[i] = syscall.ENOENT
+				continue
+			}
+		}
+		if !ok {
+			epoch := c.de.current()
+			ino, err := c.mw.LookupPath(path)
+			if err != nil {
+				if err == syscall.ENOENT && useCache[i] {
+					c.putNegativeDentry(path, epoch)
+				}
+				errs[i] = err
+				continue
+			}
+			if useCache[i] {
+				c.putDentry(path, ino, epoch)
+			}
+			inos[i] = ino
+		}
+		if useCache[i] {
+			if infos[i] = c.ic.Get(inos[i]); infos[i] != nil {
+				continue
+			}
+		}
+		pending[inos[i]] = struct{}{}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	batch := make([]uint64, 0, len(pending))
+	for ino := range pending {
+		batch = append(batch, ino)
+	}
+	fetched := make(map[uint64]*proto.InodeInfo, len(batch))
+	for _, info := range c.mw.BatchInodeGet(batch) {
+		fetched[info.Inode] = info
+	}
+	for i := range paths {
+		if errs[i] != nil || infos[i] != nil {
+			continue
+		}
+		info, ok := fetched[inos[i]]
+		if !ok {
+			// the batch leaves out the inodes it failed to get, ask for the reason
+			if info, errs[i] = c.mw.InodeGet_ll(inos[i]); errs[i] != nil {
+				continue
+			}
+			fetched[inos[i]] = info
+		}
+		if useCache[i] {
+			c.ic.Put(info)
+		}
+		infos[i] = info
 	}
-	if c.enableBcache {
-		c.bc = bcache.NewBcacheClient()
+	return
+}
+
+// isNoCachePath reports whether the absolute path matches one of the
+// configured no-cache globs, e.g. lock files that must never be read stale.
+func (c *client) isNoCachePath(path string) bool {
+	for _, pattern := range c.noCachePatterns {
+		if matched, _ := gopath.Match(pattern, path); matched {
+			return true
+		}
 	}
-	var ebsc *blobstore.BlobStoreClient
-	if c.ebsEndpoint != "" {
-		if ebsc, err = blobstore.NewEbsClient(access.Config{
-			ConnMode: access.NoLimitConnMode,
-			Consul: access.ConsulConfig{
-				Address: c.ebsEndpoint,
-			},
-			MaxSizePutOnce: MaxSizePutOnce,
-			Logger: &access.Logger{
-				Filename: gopath.Join(c.logDir, "libcfs/ebs.log"),
-			},
-		}); err != nil {
-			return
+	return false
+}
+
+func parseNoCachePatterns(val string) (patterns []string, err error) {
+	for _, pattern := range strings.Split(val, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err = gopath.Match(pattern, ""); err != nil {
+			return nil, err
 		}
+		patterns = append(patterns, pattern)
 	}
-	var mw *meta.MetaWrapper
-	if mw, err = meta.NewMetaWrapper(&meta.MetaConfig{
-		Volume:        c.volName,
-		Masters:       masters,
-		ValidateOwner: false,
-		EnableSummary: c.enableSummary,
-	}); err != nil {
-		log.LogErrorf("newClient NewMetaWrapper failed(%v)", err)
+	return
+}
+
+func (c *client) setattr(info *proto.InodeInfo, valid uint32, mode, uid, gid uint32, atime, mtime int64) error {
+	// Only rwx mode bit can be set
+	if valid&proto.AttrMode != 0 {
+		fuseMode := mode & uint32(0777)
+		mode = info.Mode &^ uint32(0777) // clear rwx mode bit
+		mode |= fuseMode
+	}
+	return c.mw.Setattr(info.Inode, valid, mode, uid, gid, atime, mtime)
+}
+
+func (c *client) renameWithAttr(absFrom, absTo string, valid, mode, uid, gid uint32) error {
+	srcDirPath, srcName := gopath.Split(absFrom)
+	dstDirPath, dstName := gopath.Split(absTo)
+
+	srcDirInfo, err := c.lookupPath(srcDirPath)
+	if err != nil {
 		return err
 	}
-	var ec *stream.ExtentClient
-	if ec, err = stream.NewExtentClient(&stream.ExtentConfig{
-		Volume:            c.volName,
-		VolumeType:        c.volType,
-		Masters:           masters,
-		FollowerRead:      c.followerRead,
-		OnAppendExtentKey: mw.AppendExtentKey,
-		OnSplitExtentKey:  mw.SplitExtentKey,
-		OnGetExtents:      mw.GetExtents,
-		OnTruncate:        mw.Truncate,
-		BcacheEnable:      c.enableBcache,
-		OnLoadBcache:      c.bc.Get,
-		OnCacheBcache:     c.bc.Put,
-		OnEvictBcache:     c.bc.Evict,
-		DisableMetaCache:  true,
-	}); err != nil {
-		log.LogErrorf("newClient NewExtentClient failed(%v)", err)
+	dstDirInfo, err := c.lookupPath(dstDirPath)
+	if err != nil {
+		return err
+	}
+	info, err := c.lookupPath(absFrom)
+	if err != nil {
+		return err
+	}
+	// Only rwx mode bit can be set
+	if valid&proto.AttrMode != 0 {
+		mode = info.Mode&^uint32(0777) | mode&uint32(0777)
+	}
+
+	err = c.mw.RenameWithAttr_ll(srcDirInfo.Inode, srcName, dstDirInfo.Inode, dstName, false, valid, mode, uid, gid)
+	c.ic.Delete(srcDirInfo.Inode)
+	c.ic.Delete(dstDirInfo.Inode)
+	c.ic.Delete(info.Inode)
+	c.dentryChanged(absFrom, absTo)
+	return err
+}
+
+// renameBatch renames the from/to pairs of absolute paths in one transaction.
+func (c *client) renameBatch(absPairs [][2]string) error {
+	pairs := make([]meta.RenamePair, 0, len(absPairs))
+	dirs := make(map[uint64]struct{})
+	for _, p := range absPairs {
+		srcDirPath, srcName := gopath.Split(p[0])
+		dstDirPath, dstName := gopath.Split(p[1])
+		srcDirInfo, err := c.lookupPath(srcDirPath)
+		if err != nil {
+			return err
+		}
+		dstDirInfo, err := c.lookupPath(dstDirPath)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, meta.RenamePair{
+			SrcParentID: srcDirInfo.Inode,
+			SrcName:     srcName,
+			DstParentID: dstDirInfo.Inode,
+			DstName:     dstName,
+		})
+		dirs[srcDirInfo.Inode] = struct{}{}
+		dirs[dstDirInfo.Inode] = struct{}{}
+	}
+
+	err := c.mw.RenameBatch_ll(pairs, false)
+	for ino := range dirs {
+		c.ic.Delete(ino)
+	}
+	for _, p := range absPairs {
+		c.dentryChanged(p[0], p[1])
+	}
+	return err
+}
+
+func (c *client) create(pino uint64, name string, mode uint32) (info *proto.InodeInfo, err error) {
+	fuseMode := mode & 0777
+	uid, gid := c.fsids()
+	return c.mw.Create_ll(pino, name, fuseMode, uid, gid, nil)
+}
+
+// openTmpFile creates a file without a name in the dir pino and opens it, the
+// inode holds the link of the fd until it is linked into the dir tree.
+func (c *client) openTmpFile(pino uint64, flags, mode uint32) (*file, error) {
+	uid, gid := c.fsids()
+	info, err := c.mw.InodeCreate_ll(pino, mode&0777, uid, gid, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	f := c.allocFD(info.Inode, flags, mode&0777, false, 0, pino)
+	if f == nil {
+		c.removeInode(info.Inode)
+		return nil, syscall.EMFILE
+	}
+	f.regular = true
+	f.tmp = &tmpFile{refs: 1}
+	c.openStream(f)
+	c.openAppendBuffer(f)
+	return f, nil
+}
+
+// linkFile links the file open at f to name in the dir pino. The link held by
+// the fds of an O_TMPFILE file is dropped once the file has a name.
+func (c *client) linkFile(f *file, pino uint64, name string) error {
+	if f.tmp != nil {
+		f.tmp.Lock()
+		defer f.tmp.Unlock()
+	}
+	if _, err := c.mw.Link(pino, name, f.ino); err != nil {
+		return err
+	}
+	c.ic.Delete(f.ino)
+	if f.tmp == nil || f.tmp.linked {
+		return nil
+	}
+	f.tmp.linked = true
+	if _, err := c.mw.InodeUnlink_ll(f.ino); err != nil {
+		log.LogWarnf("linkFile: unlink the fd link of ino(%v) err(%v)", f.ino, err)
+	}
+	return nil
+}
+
+// closeTmpFile removes the O_TMPFILE file of f if it is the last fd and the file
+// has not been linked.
+func (c *client) closeTmpFile(f *file) {
+	f.tmp.Lock()
+	defer f.tmp.Unlock()
+	f.tmp.refs--
+	if f.tmp.refs > 0 || f.tmp.linked {
+		return
+	}
+	c.removeInode(f.ino)
+}
+
+func (c *client) removeInode(ino uint64) {
+	if _, err := c.mw.InodeUnlink_ll(ino); err != nil {
+		log.LogWarnf("removeInode: unlink ino(%v) err(%v)", ino, err)
+		return
+	}
+	_ = c.mw.Evict(ino)
+	c.ic.Delete(ino)
+}
+
+// direntName returns the name of a dentry, truncated to fit d_name.
+func direntName(name string) string {
+	if len(name) >= 256 {
+		return name[:255]
+	}
+	return name
+}
+
+// getdents64 fills buf with the struct dirent64 records of the next entries of
+// the dir f and returns the bytes filled. It fails with EINVAL if buf is too
+// small for the first record.
+func (c *client) getdents64(f *file, buf []byte) (int, error) {
+	if err := c.loadDirStream(f); err != nil {
+		return 0, err
+	}
+	var d C.struct_dirent64
+	var (
+		inoOff    = int(unsafe.Offsetof(d.d_ino))
+		offOff    = int(unsafe.Offsetof(d.d_off))
+		reclenOff = int(unsafe.Offsetof(d.d_reclen))
+		typeOff   = int(unsafe.Offsetof(d.d_type))
+		nameOff   = int(unsafe.Offsetof(d.d_name))
+	)
+	n := 0
+	for {
+		dentry, off, ok := f.dirp.peek()
+		if !ok {
+			break
+		}
+		name := direntName(dentry.Name)
+		reclen := (nameOff + len(name) + 1 + 7) &^ 7
+		if n+reclen > len(buf) {
+			if n == 0 {
+				return 0, syscall.EINVAL
+			}
+			break
+		}
+		rec := buf[n : n+reclen]
+		*(*uint64)(unsafe.Pointer(&rec[inoOff])) = dentry.Inode
+		*(*int64)(unsafe.Pointer(&rec[offOff])) = off
+		*(*uint16)(unsafe.Pointer(&rec[reclenOff])) = uint16(reclen)
+		rec[typeOff] = byte(direntType(dentry.Type))
+		copy(rec[nameOff:], name)
+		for i := nameOff + len(name); i < reclen; i++ {
+			rec[i] = 0
+		}
+		f.dirp.pos++
+		n += reclen
+	}
+	return n, nil
+}
+
+// loadDirStream reads the entries of the dir f if the stream has none, either
+// at the first read or after a seek.
+func (c *client) loadDirStream(f *file) error {
+	if f.dirp == nil {
+		f.dirp = &dirStream{}
+	}
+	dirp := f.dirp
+	if dirp.dirents != nil {
+		return nil
+	}
+	dentries, err := c.mw.ReadDir_ll(f.ino)
+	if err != nil {
+		return err
+	}
+	if dentries == nil {
+		dentries = []proto.Dentry{}
+	}
+	sort.Slice(dentries, func(i, j int) bool { return dentries[i].Name < dentries[j].Name })
+	dirp.dirents = dentries
+	if dirp.resume != nil {
+		name := *dirp.resume
+		dirp.pos = sort.Search(len(dentries), func(i int) bool { return dentries[i].Name > name })
+		dirp.resume = nil
+	}
+	return nil
+}
+
+// createOrOpen creates the file, or looks it up if it exists already. The
+// dentry is created atomically by the metanode, so only one of the callers
+// racing on the same path gets created set.
+func (c *client) createOrOpen(pino uint64, name, absPath string, mode uint32) (info *proto.InodeInfo, created bool, err error) {
+	info, err = c.create(pino, name, mode)
+	if err != syscall.ENOENT {
+		// created by the call or by someone else since the path was found missing
+		c.dentryChanged(absPath)
+	}
+	if err == nil {
+		return info, true, nil
+	}
+	if err != syscall.EEXIST {
+		return nil, false, err
+	}
+	info, err = c.lookupPath(absPath)
+	return
+}
+
+func (c *client) mkdir(pino uint64, name string, mode uint32) (info *proto.InodeInfo, err error) {
+	fuseMode := mode & 0777
+	fuseMode |= uint32(os.ModeDir)
+	uid, gid := c.fsids()
+	return c.mw.Create_ll(pino, name, fuseMode, uid, gid, nil)
+}
+
+// setFsuid sets the owner of the files and directories created afterwards,
+// so that a gateway can create them on behalf of the requesting user.
+func (c *client) setFsuid(uid uint32) {
+	atomic.StoreUint32(&c.fsuid, uid)
+}
+
+func (c *client) setFsgid(gid uint32) {
+	atomic.StoreUint32(&c.fsgid, gid)
+}
+
+func (c *client) fsids() (uid, gid uint32) {
+	return atomic.LoadUint32(&c.fsuid), atomic.LoadUint32(&c.fsgid)
+}
+
+func (c *client) openStream(f *file) {
+	// read data through the block cache, which is what cfs_is_cached queries
+	if c.enableBcache && proto.IsHot(c.volType) {
+		_ = c.ec.OpenStreamWithCache(f.ino, true)
+		return
+	}
+	_ = c.ec.OpenStream(f.ino)
+}
+
+func (c *client) openAppendBuffer(f *file) {
+	if c.appendWindow == 0 || !proto.IsHot(c.volType) || f.flags&uint32(C.O_APPEND) == 0 {
+		return
+	}
+	// synchronous fds wait for every write, nothing to coalesce
+	if f.flags&uint32(C.O_DIRECT) != 0 || f.flags&uint32(C.O_SYNC) != 0 || f.flags&uint32(C.O_DSYNC) != 0 {
 		return
 	}
-
-	c.mw = mw
-	c.ec = ec
-	c.ebsc = ebsc
+	f.appendBuf = newAppendBuffer(c.appendBufSize, c.appendWindow, func(data []byte) error {
+		_, err := c.appendRecord(f, data)
+		return err
+	})
+}
+
+// appendRecord appends data to the end of the file and returns the offset where it landed.
+// Appends of the same inode are serialized, so concurrent appenders get distinct offsets.
+func (c *client) appendRecord(f *file, data []byte) (offset int, err error) {
+	c.appendLocks.lock(f.ino)
+	defer c.appendLocks.unlock(f.ino)
+	offset, _ = c.fileSize(f.ino)
+	if _, err = c.write(f, offset, data, proto.FlagsAppend|proto.FlagsSyncWrite); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+func (c *client) closeStream(f *file) {
+	if f.appendBuf != nil {
+		if err := f.appendBuf.Close(); err != nil {
+			log.LogErrorf("closeStream: flush appended data failed, ino(%v) err(%v)", f.ino, err)
+		}
+	}
+	_ = c.ec.CloseStream(f.ino)
+	_ = c.ec.EvictStream(f.ino)
+	f.fileWriter.FreeCache()
+	f.fileWriter = nil
+	f.fileReader = nil
+}
+
+func (c *client) flush(f *file) error {
+	if f.appendBuf != nil {
+		if err := f.appendBuf.Flush(); err != nil {
+			return err
+		}
+	}
+	if proto.IsHot(c.volType) {
+		return c.ec.Flush(f.ino)
+	} else {
+		if f.fileWriter != nil {
+			return f.fileWriter.Flush(f.ino, c.ctx(c.id, f.ino))
+		}
+	}
+	return nil
+}
+
+// fsync flushes the data of f, the extent keys sent to the metanode carry the
+// size and mtime of the inode. Unless datasync is set, the inode is then read
+// back from the metanode, so the cached attributes are the committed ones.
+func (c *client) fsync(f *file, datasync bool) error {
+	if err := c.flush(f); err != nil {
+		return err
+	}
+	if datasync {
+		return nil
+	}
+	c.ic.Delete(f.ino)
+	info, err := c.mw.InodeGet_ll(f.ino)
+	if err != nil {
+		return err
+	}
+	c.ic.Put(info)
+	return nil
+}
+
+// syncFileRange flushes the data of f written to [off, off+nbytes), flags are the
+// SYNC_FILE_RANGE_* flags. Only the extent handlers writing the range are flushed
+// on hot volumes, the cold volumes flush the whole file.
+func (c *client) syncFileRange(f *file, off, nbytes int64, flags uint32) error {
+	if off < 0 || nbytes < 0 || flags&^(syncFileRangeWaitBefore|syncFileRangeWrite|syncFileRangeWaitAfter) != 0 {
+		return syscall.EINVAL
+	}
+	if flags == 0 {
+		return nil
+	}
+	size := nbytes
+	if size == 0 || size > math.MaxInt64-off {
+		size = math.MaxInt64 - off
+	}
+	if f.appendBuf != nil {
+		if err := f.appendBuf.Flush(); err != nil {
+			return err
+		}
+	}
+	wait := flags&(syncFileRangeWaitBefore|syncFileRangeWaitAfter) != 0
+	if proto.IsHot(c.volType) {
+		return c.ec.FlushRange(f.ino, int(off), int(size), wait)
+	}
+	if f.fileWriter != nil {
+		return f.fileWriter.Flush(f.ino, c.ctx(c.id, f.ino))
+	}
+	return nil
+}
+
+// checkpoint flushes the open files, so the extent keys of the data written so
+// far are in the metanode, then creates a snapshot version of the vol.
+func (c *client) checkpoint() (verSeq uint64, err error) {
+	c.fdlock.RLock()
+	files := make([]*file, 0, len(c.fdmap))
+	for _, f := range c.fdmap {
+		files = append(files, f)
+	}
+	c.fdlock.RUnlock()
+
+	for _, f := range files {
+		if err = c.flush(f); err != nil {
+			log.LogErrorf("checkpoint: flush ino(%v) err(%v)", f.ino, err)
+			return 0, syscall.EIO
+		}
+		c.ic.Delete(f.ino)
+	}
+
+	mc := masterSDK.NewMasterClientFromString(c.masterAddr, false)
+	ver, err := mc.AdminAPI().CreateVersion(c.volName)
+	if err != nil {
+		log.LogErrorf("checkpoint: create version of vol(%v) err(%v)", c.volName, err)
+		return 0, syscall.EIO
+	}
+	return ver.Ver, nil
+}
+
+func (c *client) truncate(f *file, size int) error {
+	err := c.ec.Truncate(c.mw, f.pino, f.ino, size)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// posixFallocate grows f to offset+length if it is shorter. The extents of the
+// growth are allocated like preallocate does, so a later write of the range
+// can't fail for lack of space. ENOSPC if they can't be allocated, the size of
+// the file is restored then.
+func (c *client) posixFallocate(f *file, offset, length int) error {
+	if f.appendBuf != nil {
+		if err := f.appendBuf.Flush(); err != nil {
+			return err
+		}
+	}
+
+	size, _ := c.fileSize(f.ino)
+	end := offset + length
+	if end <= size {
+		return nil
+	}
+	// the part between the old end of the file and offset is left as a hole
+	start := util.Max(offset, size)
+	if err := c.allocate(f, start, end-start); err != nil {
+		log.LogWarnf("posixFallocate: ino(%v) size(%v) range [%v, %v) err(%v)", f.ino, size, start, end, err)
+		if err = c.truncate(f, size); err != nil {
+			log.LogErrorf("posixFallocate: ino(%v) restore size(%v) err(%v)", f.ino, size, err)
+		}
+		c.ic.Delete(f.ino)
+		return syscall.ENOSPC
+	}
 	return nil
 }
 
-func (c *client) checkPermission() (err error) {
-	if c.accessKey == "" || c.secretKey == "" {
-		err = errors.New("invalid AccessKey or SecretKey")
-		return
-	}
+// volStat caches the space of the volume fetched from the master, the bytes
+// written by the client since are counted as used until the next fetch.
+type volStat struct {
+	sync.Mutex
+	fetched time.Time
+	written uint64
+}
 
-	// checkPermission
-	var mc = masterSDK.NewMasterClientFromString(c.masterAddr, false)
-	var userInfo *proto.UserInfo
-	if userInfo, err = mc.UserAPI().GetAKInfo(c.accessKey); err != nil {
-		return
+func (s *volStat) addWritten(n int) {
+	s.Lock()
+	s.written += uint64(n)
+	s.Unlock()
+}
+
+func (c *client) statvfs() *syscall.Statfs_t {
+	c.volStat.Lock()
+	if time.Since(c.volStat.fetched) > volStatCacheTime {
+		if err := c.mw.RefreshVolStat(); err != nil {
+			log.LogWarnf("statvfs: refresh volume stat err(%v)", err)
+		}
+		c.volStat.fetched = time.Now()
+		c.volStat.written = 0
 	}
-	if userInfo.SecretKey != c.secretKey {
-		err = proto.ErrNoPermission
-		return
+	written := c.volStat.written
+	c.volStat.Unlock()
+
+	total, used, inodeCount := c.mw.Statfs()
+	used += written
+	if used > total {
+		used = total
 	}
-	var policy = userInfo.Policy
-	if policy.IsOwn(c.volName) {
-		return
+	return &syscall.Statfs_t{
+		Bsize:   int64(defaultBlkSize),
+		Frsize:  int64(defaultBlkSize),
+		Blocks:  total / uint64(defaultBlkSize),
+		Bfree:   (total - used) / uint64(defaultBlkSize),
+		Bavail:  (total - used) / uint64(defaultBlkSize),
+		Files:   inodeCount,
+		Ffree:   defaultMaxInodeID - inodeCount,
+		Namelen: int64(fs.DefaultMaxNameLen),
 	}
-	// read write
-	if policy.IsAuthorized(c.volName, c.subDir, proto.POSIXWriteAction) &&
-		policy.IsAuthorized(c.volName, c.subDir, proto.POSIXReadAction) {
-		return
+}
+
+// freeSpace returns the bytes left in the volume.
+func (c *client) freeSpace() uint64 {
+	total, used, _ := c.mw.Statfs()
+	if total > used {
+		return total - used
 	}
-	// read only
-	if policy.IsAuthorized(c.volName, c.subDir, proto.POSIXReadAction) &&
-		!policy.IsAuthorized(c.volName, c.subDir, proto.POSIXWriteAction) {
-		return
+	return 0
+}
+
+// preallocate allocates the first size bytes of the newly created file f, it
+// fails early if they don't fit in the free space of the volume.
+func (c *client) preallocate(f *file, size int) error {
+	if free := c.freeSpace(); uint64(size) > free {
+		log.LogWarnf("preallocate: ino(%v) size(%v) free(%v) no space", f.ino, size, free)
+		return syscall.ENOSPC
 	}
-	err = proto.ErrNoPermission
-	return
+
+	return c.allocate(f, 0, size)
 }
 
-func (c *client) allocFD(ino uint64, flags, mode uint32, fileCache bool, fileSize uint64, parentInode uint64) *file {
-	c.fdlock.Lock()
-	defer c.fdlock.Unlock()
-	fd, ok := c.fdset.NextClear(0)
-	if !ok || fd > maxFdNum {
-		return nil
+// allocate writes zeros to [offset, offset+size) of f, the extents are allocated
+// for the whole range with the store mode of its size.
+func (c *client) allocate(f *file, offset, size int) error {
+	c.ec.GetStreamer(f.ino).SetParentInode(f.pino)
+	if err := c.ec.PrepareWrite(f.ino, offset, size); err != nil {
+		return err
 	}
-	c.fdset.Set(fd)
-	f := &file{fd: fd, ino: ino, flags: flags, mode: mode, pino: parentInode}
-	if proto.IsCold(c.volType) {
-		clientConf := blobstore.ClientConfig{
-			VolName:         c.volName,
-			VolType:         c.volType,
-			BlockSize:       c.ebsBlockSize,
-			Ino:             ino,
-			Bc:              c.bc,
-			Mw:              c.mw,
-			Ec:              c.ec,
-			Ebsc:            c.ebsc,
-			EnableBcache:    c.enableBcache,
-			WConcurrency:    c.writeBlockThread,
-			ReadConcurrency: c.readBlockThread,
-			CacheAction:     c.cacheAction,
-			FileCache:       fileCache,
-			FileSize:        fileSize,
-			CacheThreshold:  c.cacheThreshold,
-		}
-		f.fileWriter.FreeCache()
-		switch flags & 0xff {
-		case syscall.O_RDONLY:
-			f.fileReader = blobstore.NewReader(clientConf)
-			f.fileWriter = nil
-		case syscall.O_WRONLY:
-			f.fileWriter = blobstore.NewWriter(clientConf)
-			f.fileReader = nil
-		case syscall.O_RDWR:
-			f.fileReader = blobstore.NewReader(clientConf)
-			f.fileWriter = blobstore.NewWriter(clientConf)
-		default:
-			f.fileWriter = blobstore.NewWriter(clientConf)
-			f.fileReader = nil
+	end := offset + size
+	zeros := make([]byte, util.Min(size, copyBufferSize))
+	for off := offset; off < end; {
+		n, err := c.write(f, off, zeros[:util.Min(len(zeros), end-off)], 0)
+		if err != nil {
+			return err
 		}
+		off += n
 	}
-	c.fdmap[fd] = f
-	return f
+	if err := c.flush(f); err != nil {
+		return err
+	}
+	c.ic.Delete(f.ino)
+	return nil
 }
 
-func (c *client) getFile(fd uint) *file {
-	c.fdlock.Lock()
-	f := c.fdmap[fd]
-	c.fdlock.Unlock()
-	return f
-}
+// punchHole zeroes the range of the file, the part beyond the end of the file
+// is left alone so the size does not change. The zeros are written over the
+// range, the extents under it are not released.
+func (c *client) punchHole(f *file, offset, length int) error {
+	if f.appendBuf != nil {
+		if err := f.appendBuf.Flush(); err != nil {
+			return err
+		}
+	}
 
-func (c *client) releaseFD(fd uint) *file {
-	c.fdlock.Lock()
-	defer c.fdlock.Unlock()
-	f, ok := c.fdmap[fd]
-	if !ok {
+	size, _ := c.fileSize(f.ino)
+	end := util.Min(offset+length, size)
+	if offset >= end {
 		return nil
 	}
-	delete(c.fdmap, fd)
-	c.fdset.Clear(fd)
+	zeros := make([]byte, util.Min(end-offset, copyBufferSize))
+	for off := offset; off < end; {
+		n, err := c.write(f, off, zeros[:util.Min(len(zeros), end-off)], 0)
+		if err != nil {
+			return err
+		}
+		off += n
+	}
+	if err := c.flush(f); err != nil {
+		return err
+	}
 	c.ic.Delete(f.ino)
-	return f
+	return nil
 }
 
-func (c *client) lookupPath(path string) (*proto.InodeInfo, error) {
-	ino, ok := c.dc.Get(gopath.Clean(path))
-	if !ok {
-		inoInterval, err := c.mw.LookupPath(gopath.Clean(path))
-		if err != nil {
-			return nil, err
+// fileWrite writes buffer to f at off, or at the file offset if off < 0.
+func (c *client) fileWrite(f *file, off int, buffer []byte) (n int, err error) {
+	return c.fileWriteSync(f, off, buffer, false)
+}
+
+// fileWriteSync is fileWrite, with sync it waits for the data to be written like
+// an O_DSYNC fd.
+func (c *client) fileWriteSync(f *file, off int, buffer []byte, sync bool) (n int, err error) {
+	defer f.lockPos(off)()
+	offset := f.ioOffset(off)
+
+	var flags int
+	wait := sync
+
+	if f.flags&uint32(C.O_DIRECT) != 0 || f.flags&uint32(C.O_SYNC) != 0 || f.flags&uint32(C.O_DSYNC) != 0 {
+		if proto.IsHot(c.volType) {
+			wait = true
 		}
-		c.dc.Put(gopath.Clean(path), inoInterval)
-		ino = inoInterval
 	}
-	info := c.ic.Get(ino)
-	if info != nil {
-		return info, nil
+	if f.flags&uint32(C.O_APPEND) != 0 || proto.IsCold(c.volType) {
+		flags |= proto.FlagsAppend
+		flags |= proto.FlagsSyncWrite
 	}
-	info, err := c.mw.InodeGet_ll(ino)
-	if err != nil {
-		return nil, err
+	if f.flags&uint32(C.O_DIRECT) != 0 {
+		// the appends land at the end of the file, only their size is checked
+		if len(buffer)%directIOAlign != 0 || (flags&proto.FlagsAppend == 0 && offset%directIOAlign != 0) {
+			return 0, syscall.EINVAL
+		}
+		// written to the data nodes rather than to the extent handler buffer
+		flags |= proto.FlagsSyncWrite
 	}
-	c.ic.Put(info)
 
-	return info, nil
-}
+	if f.appendBuf != nil {
+		n, err = f.appendBuf.Write(buffer)
+	} else if f.flags&uint32(C.O_APPEND) != 0 {
+		// serialized with appendRecord, which reports where its data lands
+		c.appendLocks.lock(f.ino)
+		n, err = c.write(f, offset, buffer, flags)
+		c.appendLocks.unlock(f.ino)
+	} else {
+		n, err = c.write(f, offset, buffer, flags)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		if flags&proto.FlagsAppend != 0 {
+			offset, _ = c.fileSize(f.ino)
+			f.pos.off = offset
+		} else {
+			f.pos.off = offset + n
+		}
+	}
 
-func (c *client) setattr(info *proto.InodeInfo, valid uint32, mode, uid, gid uint32, atime, mtime int64) error {
-	// Only rwx mode bit can be set
-	if valid&proto.AttrMode != 0 {
-		fuseMode := mode & uint32(0777)
-		mode = info.Mode &^ uint32(0777) // clear rwx mode bit
-		mode |= fuseMode
+	if wait {
+		if err = c.flush(f); err != nil {
+			return 0, syscall.EIO
+		}
 	}
-	return c.mw.Setattr(info.Inode, valid, mode, uid, gid, atime, mtime)
+	return n, nil
 }
 
-func (c *client) create(pino uint64, name string, mode uint32) (info *proto.InodeInfo, err error) {
-	fuseMode := mode & 0777
-	return c.mw.Create_ll(pino, name, fuseMode, 0, 0, nil)
-}
+// fileRead reads f at off into buffer, or at the file offset if off < 0.
+func (c *client) fileRead(f *file, off int, buffer []byte) (n int, err error) {
+	// make the coalesced appends visible to the reader
+	if f.appendBuf != nil {
+		if err = f.appendBuf.Flush(); err != nil {
+			return 0, err
+		}
+	}
 
-func (c *client) mkdir(pino uint64, name string, mode uint32) (info *proto.InodeInfo, err error) {
-	fuseMode := mode & 0777
-	fuseMode |= uint32(os.ModeDir)
-	return c.mw.Create_ll(pino, name, fuseMode, 0, 0, nil)
+	defer f.lockPos(off)()
+	offset := f.ioOffset(off)
+	if f.flags&uint32(C.O_DIRECT) != 0 && (offset%directIOAlign != 0 || len(buffer)%directIOAlign != 0) {
+		return 0, syscall.EINVAL
+	}
+	if n, err = c.read(f, offset, buffer); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		f.pos.off = offset + n
+	}
+	return n, nil
 }
 
-func (c *client) openStream(f *file) {
-	_ = c.ec.OpenStream(f.ino)
+// fileReadNowait is fileRead when the data can be read without waiting for the
+// data nodes, i.e. it is in the block cache. It returns EAGAIN otherwise.
+func (c *client) fileReadNowait(f *file, off int, buffer []byte) (n int, err error) {
+	if !proto.IsHot(c.volType) || f.flags&uint32(C.O_DIRECT) != 0 {
+		return 0, syscall.EAGAIN
+	}
+	if f.appendBuf != nil && f.appendBuf.Buffered() > 0 {
+		return 0, syscall.EAGAIN
+	}
+	offset := f.ioOffset(off)
+	size, _ := c.fileSize(f.ino)
+	if end := offset + len(buffer); end > size {
+		size = size - offset
+	} else {
+		size = len(buffer)
+	}
+	if size > 0 {
+		cached, err := c.ec.IsCached(f.ino, offset, size)
+		if err != nil || !cached {
+			return 0, syscall.EAGAIN
+		}
+	}
+	return c.fileRead(f, off, buffer)
 }
 
-func (c *client) closeStream(f *file) {
-	_ = c.ec.CloseStream(f.ino)
-	_ = c.ec.EvictStream(f.ino)
-	f.fileWriter.FreeCache()
-	f.fileWriter = nil
-	f.fileReader = nil
+// pwritev2 writes the buffers to f as one at off, or at the file offset if off < 0.
+// See cfs_pwritev2 for the flags.
+func (c *client) pwritev2(f *file, off int, buffers [][]byte, flags int) (n int, err error) {
+	if flags&^(rwfHipri|rwfDsync|rwfSync) != 0 {
+		return 0, syscall.EOPNOTSUPP
+	}
+	return c.fileWriteSync(f, off, coalesceBuffers(buffers), flags&(rwfDsync|rwfSync) != 0)
 }
 
-func (c *client) flush(f *file) error {
-	if proto.IsHot(c.volType) {
-		return c.ec.Flush(f.ino)
-	} else {
-		if f.fileWriter != nil {
-			return f.fileWriter.Flush(f.ino, c.ctx(c.id, f.ino))
+// preadv2 reads f at off, or at the file offset if off < 0, into the buffers. See
+// cfs_preadv2 for the flags.
+func (c *client) preadv2(f *file, off int, buffers [][]byte, flags int) (n int, err error) {
+	if flags&^(rwfHipri|rwfDsync|rwfSync|rwfNowait) != 0 {
+		return 0, syscall.EOPNOTSUPP
+	}
+	if len(buffers) == 0 {
+		return 0, nil
+	}
+	buffer := buffers[0]
+	if len(buffers) != 1 {
+		size := 0
+		for _, b := range buffers {
+			size += len(b)
 		}
+		buffer = make([]byte, size)
 	}
-	return nil
+	if flags&rwfNowait != 0 {
+		n, err = c.fileReadNowait(f, off, buffer)
+	} else {
+		n, err = c.fileRead(f, off, buffer)
+	}
+	if err != nil || len(buffers) == 1 {
+		return
+	}
+	for i, data := 0, buffer[:n]; len(data) > 0; i++ {
+		data = data[copy(buffers[i], data):]
+	}
+	return
 }
 
-func (c *client) truncate(f *file, size int) error {
-	err := c.ec.Truncate(c.mw, f.pino, f.ino, size)
-	if err != nil {
-		return err
+// coalesceBuffers returns the buffers as one, the single buffer itself.
+func coalesceBuffers(buffers [][]byte) []byte {
+	if len(buffers) == 1 {
+		return buffers[0]
 	}
-	return nil
+	size := 0
+	for _, b := range buffers {
+		size += len(b)
+	}
+	buffer := make([]byte, 0, size)
+	for _, b := range buffers {
+		buffer = append(buffer, b...)
+	}
+	return buffer
 }
 
 func (c *client) write(f *file, offset int, data []byte, flags int) (n int, err error) {
+	var row bool
 	if proto.IsHot(c.volType) {
+		if flags&proto.FlagsAppend == 0 {
+			size, _ := c.fileSize(f.ino)
+			row = offset < size
+		}
 		c.ec.GetStreamer(f.ino).SetParentInode(f.pino) // set the parent inode
 		checkFunc := func() error {
 			if !c.mw.EnableQuota {
@@ -1433,11 +4534,15 @@ func (c *client) write(f *file, offset int, data []byte, flags int) (n int, err
 	if err != nil {
 		return 0, err
 	}
+	c.inodeStats.recordWrite(f.ino, n, row)
+	c.volStat.addWritten(n)
 	return n, nil
 }
 
 func (c *client) read(f *file, offset int, data []byte) (n int, err error) {
-	if proto.IsHot(c.volType) {
+	if proto.IsHot(c.volType) && f.flags&uint32(C.O_DIRECT) != 0 {
+		n, err = c.ec.ReadDirect(f.ino, data, offset, len(data))
+	} else if proto.IsHot(c.volType) {
 		n, err = c.ec.Read(f.ino, data, offset, len(data))
 	} else {
 		n, err = f.fileReader.Read(c.ctx(c.id, f.ino), data, offset, len(data))
@@ -1445,9 +4550,115 @@ func (c *client) read(f *file, offset int, data []byte) (n int, err error) {
 	if err != nil && err != io.EOF {
 		return 0, err
 	}
+	c.inodeStats.recordRead(f.ino, n)
 	return n, nil
 }
 
+// readTail reads the last len(data) bytes of f, or the whole file when it is
+// shorter. It returns the bytes read.
+func (c *client) readTail(f *file, data []byte) (n int, err error) {
+	// make the coalesced appends visible to the reader
+	if f.appendBuf != nil {
+		if err = f.appendBuf.Flush(); err != nil {
+			return
+		}
+	}
+
+	size, err := c.tailSize(f.ino)
+	if err != nil {
+		return
+	}
+	offset := size - len(data)
+	if offset < 0 {
+		offset = 0
+	}
+	return c.read(f, offset, data[:size-offset])
+}
+
+// tailSize returns the size of the file for readTail: the one of the open stream,
+// else the one of the cached inode, which is fetched again once it has expired.
+func (c *client) tailSize(ino uint64) (int, error) {
+	if size, _, valid := c.ec.FileSize(ino); valid {
+		return size, nil
+	}
+	if info := c.ic.Get(ino); info != nil && time.Now().UnixNano() <= info.Expiration() {
+		return int(info.Size), nil
+	}
+	info, err := c.mw.InodeGet_ll(ino)
+	if err != nil {
+		return 0, err
+	}
+	c.ic.Put(info)
+	return int(info.Size), nil
+}
+
+// copyFileRange copies size bytes of src at srcOff to dst at dstOff through the
+// client, the copy stops at the end of src. It returns the bytes copied.
+func (c *client) copyFileRange(src *file, srcOff int, dst *file, dstOff int, size int, flags uint32) (copied int, err error) {
+	// make the coalesced appends of the source visible to the copy
+	if src.appendBuf != nil {
+		if err = src.appendBuf.Flush(); err != nil {
+			return
+		}
+	}
+
+	srcSize, _ := c.fileSize(src.ino)
+	if srcOff >= srcSize {
+		return
+	}
+	if size > srcSize-srcOff {
+		size = srcSize - srcOff
+	}
+	if src.ino == dst.ino && dstOff < srcOff+size && srcOff < dstOff+size {
+		return 0, syscall.EINVAL
+	}
+
+	bufSize := size
+	if bufSize > copyBufferSize {
+		bufSize = copyBufferSize
+	}
+	buf := make([]byte, bufSize)
+	for copied < size {
+		chunk := buf
+		if size-copied < len(chunk) {
+			chunk = chunk[:size-copied]
+		}
+		var n int
+		if n, err = c.read(src, srcOff+copied, chunk); err != nil {
+			return
+		}
+		if n == 0 {
+			break
+		}
+		if n, err = c.write(dst, dstOff+copied, chunk[:n], 0); err != nil {
+			return
+		}
+		copied += n
+	}
+
+	if flags&copyPreserveMtime != 0 {
+		err = c.preserveMtime(src, dst)
+	}
+	return
+}
+
+// preserveMtime sets the mtime of src on dst, the data of dst is flushed first so
+// that later extent updates don't bump the mtime again.
+func (c *client) preserveMtime(src, dst *file) error {
+	if err := c.flush(dst); err != nil {
+		return err
+	}
+	info, err := c.mw.InodeGet_ll(src.ino)
+	if err != nil {
+		return err
+	}
+	if err = c.mw.Setattr(dst.ino, proto.AttrModifyTime, 0, 0, 0, 0, info.ModifyTime.Unix()); err != nil {
+		return err
+	}
+	c.ic.Delete(dst.ino)
+	return nil
+}
+
 func (c *client) ctx(cid int64, ino uint64) context.Context {
 	_, ctx := trace.StartSpanFromContextWithTraceID(context.Background(), "", fmt.Sprintf("cid=%v,ino=%v", cid, ino))
 	return ctx
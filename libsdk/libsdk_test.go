@@ -0,0 +1,2078 @@
+// Copyright 2020 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/brahma-adshonor/gohook"
+	"github.com/cubefs/cubefs/client/fs"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/stream"
+	masterSDK "github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/stat"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	mockLookupPathCnt int
+	mockInodeGetCnt   int
+	// mtime of the inodes returned by the mocked meta wrapper
+	mockInodeMtime sync.Map
+	// paths the mocked meta wrapper does not find
+	mockMissingPaths sync.Map
+)
+
+func MockLookupPath(mw *meta.MetaWrapper, subdir string) (uint64, error) {
+	mockLookupPathCnt++
+	if _, ok := mockMissingPaths.Load(subdir); ok {
+		return 0, syscall.ENOENT
+	}
+	return uint64(len(subdir)) + proto.RootIno, nil
+}
+
+func MockInodeGet(mw *meta.MetaWrapper, inode uint64) (*proto.InodeInfo, error) {
+	mockInodeGetCnt++
+	info := &proto.InodeInfo{Inode: inode, Mode: 0644}
+	if mtime, ok := mockInodeMtime.Load(inode); ok {
+		info.ModifyTime = mtime.(time.Time)
+	}
+	return info, nil
+}
+
+func MockSetattr(mw *meta.MetaWrapper, inode uint64, valid, mode, uid, gid uint32, atime, mtime int64) error {
+	if valid&proto.AttrModifyTime != 0 {
+		mockInodeMtime.Store(inode, time.Unix(mtime, 0))
+	}
+	return nil
+}
+
+func newMockClient(t *testing.T) *client {
+	mw := &meta.MetaWrapper{}
+	err := gohook.HookMethod(mw, "LookupPath", MockLookupPath, nil)
+	if err != nil {
+		panic(fmt.Sprintf("Hook advance instance method failed:%s", err.Error()))
+	}
+	err = gohook.HookMethod(mw, "InodeGet_ll", MockInodeGet, nil)
+	if err != nil {
+		panic(fmt.Sprintf("Hook advance instance method failed:%s", err.Error()))
+	}
+	t.Cleanup(func() {
+		gohook.UnHookMethod(mw, "LookupPath")
+		gohook.UnHookMethod(mw, "InodeGet_ll")
+	})
+	c := newClient()
+	c.mw = mw
+	t.Cleanup(func() { removeClient(c.id) })
+	return c
+}
+
+func TestLookupPathNoCachePatterns(t *testing.T) {
+	c := newMockClient(t)
+	_, err := parseNoCachePatterns("/locks/[")
+	require.Error(t, err)
+	c.noCachePatterns, err = parseNoCachePatterns("/locks/*.lock, /run/*/ready")
+	require.NoError(t, err)
+
+	mockLookupPathCnt, mockInodeGetCnt = 0, 0
+	for i := 0; i < 3; i++ {
+		_, err = c.lookupPath("/data/file")
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, mockLookupPathCnt)
+	require.Equal(t, 1, mockInodeGetCnt)
+
+	for _, path := range []string{"/locks/a.lock", "/run/job1/ready"} {
+		mockLookupPathCnt, mockInodeGetCnt = 0, 0
+		for i := 0; i < 3; i++ {
+			_, err = c.lookupPath(path)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 3, mockLookupPathCnt, path)
+		require.Equal(t, 3, mockInodeGetCnt, path)
+	}
+}
+
+func TestLookupPathNegativeDentry(t *testing.T) {
+	c := newMockClient(t)
+	mockMissingPaths.Store("/data/missing", true)
+	defer mockMissingPaths.Delete("/data/missing")
+
+	// without a ttl every lookup of a missing path hits the server
+	mockLookupPathCnt = 0
+	for i := 0; i < 3; i++ {
+		_, err := c.lookupPath("/data/missing")
+		require.Equal(t, syscall.ENOENT, err)
+	}
+	require.Equal(t, 3, mockLookupPathCnt)
+
+	c.dc.SetNegativeTTL(100 * time.Millisecond)
+	mockLookupPathCnt = 0
+	for i := 0; i < 3; i++ {
+		_, err := c.lookupPath("/data/missing")
+		require.Equal(t, syscall.ENOENT, err)
+	}
+	require.Equal(t, 1, mockLookupPathCnt)
+
+	// the negative entry expires
+	time.Sleep(150 * time.Millisecond)
+	_, err := c.lookupPath("/data/missing")
+	require.Equal(t, syscall.ENOENT, err)
+	require.Equal(t, 2, mockLookupPathCnt)
+
+	// once the path is created it is looked up again
+	c.dc.Delete("/data/missing")
+	mockMissingPaths.Delete("/data/missing")
+	info, err := c.lookupPath("/data/missing")
+	require.NoError(t, err)
+	require.Equal(t, uint64(len("/data/missing"))+proto.RootIno, info.Inode)
+	require.Equal(t, 3, mockLookupPathCnt)
+}
+
+func TestExportLatency(t *testing.T) {
+	c := newMockClient(t)
+	c.volName = "vol1"
+
+	var observed []map[string]string
+	orig := exportLatency
+	exportLatency = func(labels map[string]string, start time.Time) {
+		observed = append(observed, labels)
+	}
+	defer func() { exportLatency = orig }()
+
+	// nothing is published until the exporter is started
+	c.observeLatency(opRead, time.Now())
+	require.Empty(t, observed)
+
+	c.exportLatency = true
+	c.observeLatency(opRead, time.Now())
+	require.Equal(t, []map[string]string{{"vol": "vol1", "op": "read"}}, observed)
+}
+
+func TestCacheConfig(t *testing.T) {
+	c := newMockClient(t)
+	c.inodeCacheSize = 4
+	c.dentryCacheTTL = 0
+	c.initCaches()
+
+	for ino := uint64(3400); ino < 3404; ino++ {
+		c.ic.Put(&proto.InodeInfo{Inode: ino})
+	}
+	for ino := uint64(3400); ino < 3404; ino++ {
+		require.NotNil(t, c.ic.Get(ino))
+	}
+	// the cache is full, the oldest inodes are evicted to make room
+	c.ic.Put(&proto.InodeInfo{Inode: 3404})
+	require.Nil(t, c.ic.Get(3400))
+	require.NotNil(t, c.ic.Get(3404))
+
+	// a zero ttl keeps no dentry
+	c.dc.Put("/data/file", 3404)
+	_, ok := c.dc.Get("/data/file")
+	require.False(t, ok)
+}
+
+func TestLatencyHandler(t *testing.T) {
+	c := newMockClient(t)
+
+	// a known mix of operations: 5 getattr, 3 read, 1 open
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		_, err := c.lookupPath("/data/file")
+		require.NoError(t, err)
+		c.observeLatency(opGetattr, start)
+	}
+	for i := 0; i < 3; i++ {
+		c.observeLatency(opRead, time.Now().Add(-2*time.Millisecond))
+	}
+	c.observeLatency(opOpen, time.Now().Add(-10*time.Millisecond))
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%v?id=%v", latencyPath, c.id), nil)
+	w := httptest.NewRecorder()
+	latencyHandler(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	reply := make(map[int64]map[string]stat.LatencySnapshot)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reply))
+	ops := reply[c.id]
+	require.Len(t, ops, 3)
+	require.EqualValues(t, 5, ops["getattr"].Count)
+	require.EqualValues(t, 3, ops["read"].Count)
+	require.EqualValues(t, 1, ops["open"].Count)
+	require.GreaterOrEqual(t, ops["read"].P99Us, uint64(2000))
+	require.GreaterOrEqual(t, ops["open"].MaxUs, uint64(10000))
+	require.LessOrEqual(t, ops["read"].P50Us, ops["read"].MaxUs)
+
+	req = httptest.NewRequest(http.MethodGet, latencyPath+"?id=-1", nil)
+	w = httptest.NewRecorder()
+	latencyHandler(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func MockOpenStream(ec *stream.ExtentClient, inode uint64) error {
+	return nil
+}
+
+func TestDupFileOffset(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	require.NoError(t, gohook.HookMethod(ec, "OpenStream", MockOpenStream, nil))
+	defer gohook.UnHookMethod(ec, "OpenStream")
+	require.NoError(t, gohook.HookMethod(ec, "FileSize", MockFileSize, nil))
+	defer gohook.UnHookMethod(ec, "FileSize")
+	c := newMockClient(t)
+	c.ec = ec
+
+	f := c.allocFD(1000, uint32(os.O_RDWR), 0644, false, 0, proto.RootIno)
+	require.NotNil(t, f)
+	f.regular = true
+
+	// io at explicit offset doesn't move the file offset
+	require.Equal(t, 4096, f.ioOffset(4096))
+	require.Equal(t, 0, f.ioOffset(-1))
+	f.pos.off = 10
+
+	shared := c.dupFile(f, true)
+	independent := c.dupFile(f, false)
+	require.NotNil(t, shared)
+	require.NotNil(t, independent)
+	require.NotEqual(t, f.fd, shared.fd)
+	require.NotEqual(t, shared.fd, independent.fd)
+	require.Equal(t, c.getFile(shared.fd), shared)
+	require.True(t, shared.regular)
+
+	// both start at the offset of the original fd
+	require.Equal(t, 10, shared.ioOffset(-1))
+	require.Equal(t, 10, independent.ioOffset(-1))
+
+	// the dup advances together with the original fd
+	f.pos.off += 100
+	require.Equal(t, 110, shared.ioOffset(-1))
+	require.Equal(t, 10, independent.ioOffset(-1))
+	shared.pos.off += 5
+	require.Equal(t, 115, f.ioOffset(-1))
+
+	// the independent cursor moves alone
+	independent.pos.off = 0
+	require.Equal(t, 115, f.ioOffset(-1))
+	require.Equal(t, 115, shared.ioOffset(-1))
+
+	// closing the original fd keeps the offset of the dup
+	c.releaseFD(f.fd)
+	shared.pos.off += 1
+	require.Equal(t, 116, shared.ioOffset(-1))
+}
+
+func TestDup2File(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	require.NoError(t, gohook.HookMethod(ec, "OpenStream", MockOpenStream, nil))
+	defer gohook.UnHookMethod(ec, "OpenStream")
+	require.NoError(t, gohook.HookMethod(ec, "FileSize", MockFileSize, nil))
+	defer gohook.UnHookMethod(ec, "FileSize")
+	c := newMockClient(t)
+	c.ec = ec
+
+	f := c.allocFD(1000, uint32(os.O_RDWR), 0644, false, 0, proto.RootIno)
+	other := c.allocFD(1001, uint32(os.O_RDONLY), 0644, false, 0, proto.RootIno)
+	require.NotNil(t, f)
+	require.NotNil(t, other)
+	f.regular = true
+
+	// dup returns the lowest free fd, distinct from the original
+	dup := c.dupFile(f, true)
+	require.NotNil(t, dup)
+	require.NotEqual(t, f.fd, dup.fd)
+	require.Equal(t, other.fd+1, dup.fd)
+	c.releaseFD(dup.fd)
+
+	// dup2 onto an open fd replaces its file and shares the offset
+	f.pos.off = 10
+	newFile := c.dup2File(f, other.fd)
+	require.NotNil(t, newFile)
+	require.Equal(t, other.fd, newFile.fd)
+	require.Equal(t, newFile, c.getFile(other.fd))
+	require.Equal(t, uint64(1000), newFile.ino)
+	require.Equal(t, uint32(os.O_RDWR), newFile.flags)
+	newFile.pos.off += 5
+	require.Equal(t, 15, f.ioOffset(-1))
+
+	// dup2 onto a free fd allocates it, onto itself it does nothing
+	free := f.fd + 10
+	require.Nil(t, c.getFile(free))
+	require.Equal(t, free, c.dup2File(f, free).fd)
+	require.Equal(t, f, c.dup2File(f, f.fd))
+	require.Equal(t, f, c.getFile(f.fd))
+
+	// the fd taken in between is not replaced
+	require.Nil(t, c.allocFDAt(f.fd, 1002, uint32(os.O_RDWR), 0644, false, 0, proto.RootIno))
+}
+
+func TestSharedOffsetConcurrentIO(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"OpenStream":  MockOpenStream,
+		"FileSize":    MockFileSize,
+		"Read":        MockExtentRead,
+		"Write":       MockExtentWrite,
+		"Flush":       MockExtentFlush,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+
+	f := c.allocFD(2950, uint32(os.O_RDWR), 0644, false, 0, proto.RootIno)
+	require.NotNil(t, f)
+	f.regular = true
+
+	const (
+		writers = 8
+		records = 50
+		recSize = 128
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		dup := c.dupFile(f, true)
+		require.NotNil(t, dup)
+		wg.Add(1)
+		go func(dup *file, b byte) {
+			defer wg.Done()
+			record := bytes.Repeat([]byte{b}, recSize)
+			for j := 0; j < records; j++ {
+				if _, err := c.fileWrite(dup, -1, record); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(dup, byte('a'+i))
+	}
+	wg.Wait()
+
+	// every record has its own range, none is overwritten or skipped
+	require.Equal(t, writers*records*recSize, f.pos.off)
+	mockExtentFiles.Lock()
+	data := append([]byte(nil), mockExtentFiles.data[f.ino]...)
+	mockExtentFiles.Unlock()
+	require.Len(t, data, writers*records*recSize)
+	counts := make(map[byte]int)
+	for off := 0; off < len(data); off += recSize {
+		require.Equal(t, bytes.Repeat(data[off:off+1], recSize), data[off:off+recSize], "offset %v", off)
+		counts[data[off]]++
+	}
+	for i := 0; i < writers; i++ {
+		require.Equal(t, records, counts[byte('a'+i)])
+	}
+
+	// the readers sharing the offset read each record once
+	f.pos.off = 0
+	var readCnt int32
+	for i := 0; i < writers; i++ {
+		dup := c.dupFile(f, true)
+		require.NotNil(t, dup)
+		wg.Add(1)
+		go func(dup *file) {
+			defer wg.Done()
+			buf := make([]byte, recSize)
+			for {
+				n, err := c.fileRead(dup, -1, buf)
+				if n == 0 {
+					return
+				}
+				if err != nil && err != io.EOF {
+					t.Error(err)
+					return
+				}
+				atomic.AddInt32(&readCnt, 1)
+			}
+		}(dup)
+	}
+	wg.Wait()
+	require.EqualValues(t, writers*records, readCnt)
+}
+
+var (
+	mockDirectWriteFlags int
+	mockReadDirectCnt    int
+)
+
+func MockDirectWrite(ec *stream.ExtentClient, inode uint64, offset int, data []byte, flags int, checkFunc func() error) (int, error) {
+	mockDirectWriteFlags = flags
+	return MockExtentWrite(ec, inode, offset, data, flags, checkFunc)
+}
+
+func MockExtentReadDirect(ec *stream.ExtentClient, inode uint64, data []byte, offset int, size int) (int, error) {
+	mockReadDirectCnt++
+	return MockExtentRead(ec, inode, data, offset, size)
+}
+
+func TestDirectIO(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Read":        MockExtentRead,
+		"ReadDirect":  MockExtentReadDirect,
+		"Write":       MockDirectWrite,
+		"Flush":       MockExtentFlush,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+
+	const ino = 3500
+	f := &file{ino: ino, flags: uint32(os.O_RDWR | syscall.O_DIRECT), regular: true, pos: &filePos{}}
+	block := bytes.Repeat([]byte{'d'}, directIOAlign)
+
+	// the aligned io goes straight to the data nodes
+	mockDirectWriteFlags = 0
+	n, err := c.fileWrite(f, directIOAlign, block)
+	require.NoError(t, err)
+	require.Equal(t, directIOAlign, n)
+	require.NotZero(t, mockDirectWriteFlags&proto.FlagsSyncWrite)
+
+	mockReadDirectCnt = 0
+	data := make([]byte, directIOAlign)
+	n, err = c.fileRead(f, directIOAlign, data)
+	require.NoError(t, err)
+	require.Equal(t, directIOAlign, n)
+	require.Equal(t, block, data)
+	require.Equal(t, 1, mockReadDirectCnt)
+
+	// the unaligned offsets and sizes are refused
+	for _, r := range [][2]int{{1, directIOAlign}, {0, 100}, {directIOAlign / 2, directIOAlign / 2}} {
+		_, err = c.fileWrite(f, r[0], make([]byte, r[1]))
+		require.Equal(t, syscall.EINVAL, err, "%v", r)
+		_, err = c.fileRead(f, r[0], make([]byte, r[1]))
+		require.Equal(t, syscall.EINVAL, err, "%v", r)
+	}
+	require.Equal(t, 1, mockReadDirectCnt)
+	f.pos.off = 10
+	_, err = c.fileRead(f, -1, data)
+	require.Equal(t, syscall.EINVAL, err)
+
+	// the buffered fds are not affected
+	f.flags = uint32(os.O_RDWR)
+	_, err = c.fileRead(f, 1, make([]byte, 100))
+	require.NoError(t, err)
+	require.Equal(t, 1, mockReadDirectCnt)
+}
+
+func TestCopyFileRangePreserveMtime(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Read":        MockExtentRead,
+		"Write":       MockExtentWrite,
+		"Flush":       MockExtentFlush,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+	require.NoError(t, gohook.HookMethod(c.mw, "Setattr", MockSetattr, nil))
+	defer gohook.UnHookMethod(c.mw, "Setattr")
+
+	srcIno, dstIno := uint64(2000), uint64(2001)
+	content := bytes.Repeat([]byte("0123456789"), copyBufferSize/5)
+	srcMtime := time.Unix(1600000000, 0)
+	dstMtime := time.Unix(1700000000, 0)
+	mockExtentFiles.Lock()
+	mockExtentFiles.data[srcIno] = content
+	delete(mockExtentFiles.data, dstIno)
+	mockExtentFiles.Unlock()
+	mockInodeMtime.Store(srcIno, srcMtime)
+	mockInodeMtime.Store(dstIno, dstMtime)
+
+	src := &file{ino: srcIno, flags: uint32(os.O_RDONLY), regular: true, pos: &filePos{}}
+	dst := &file{ino: dstIno, flags: uint32(os.O_WRONLY), regular: true, pos: &filePos{}}
+
+	// without the flag the mtime of the destination is left alone
+	n, err := c.copyFileRange(src, 0, dst, 0, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, 10, n)
+	info, err := c.mw.InodeGet_ll(dstIno)
+	require.NoError(t, err)
+	require.Equal(t, dstMtime, info.ModifyTime)
+
+	// the copy spans several buffers and stops at the end of the source
+	n, err = c.copyFileRange(src, 0, dst, 0, len(content)+100, copyPreserveMtime)
+	require.NoError(t, err)
+	require.Equal(t, len(content), n)
+	mockExtentFiles.Lock()
+	require.Equal(t, content, mockExtentFiles.data[dstIno])
+	mockExtentFiles.Unlock()
+
+	info, err = c.mw.InodeGet_ll(dstIno)
+	require.NoError(t, err)
+	require.Equal(t, srcMtime, info.ModifyTime)
+}
+
+func TestCopyFileRangeSameFile(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Read":        MockExtentRead,
+		"Write":       MockExtentWrite,
+		"Flush":       MockExtentFlush,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+
+	ino := uint64(2010)
+	content := bytes.Repeat([]byte("0123456789"), 30)
+	mockExtentFiles.Lock()
+	mockExtentFiles.data[ino] = append([]byte(nil), content...)
+	mockExtentFiles.Unlock()
+	f := &file{ino: ino, flags: uint32(os.O_RDWR), regular: true, pos: &filePos{}}
+
+	// overlapping ranges are refused, nothing is copied
+	for _, off := range [][2]int{{0, 50}, {50, 0}, {0, 0}} {
+		n, err := c.copyFileRange(f, off[0], f, off[1], 100, 0)
+		require.Equal(t, syscall.EINVAL, err)
+		require.Equal(t, 0, n)
+	}
+
+	// the range is clamped to the end of the source before the check
+	n, err := c.copyFileRange(f, 250, f, 0, 1000, 0)
+	require.NoError(t, err)
+	require.Equal(t, 50, n)
+
+	// a copy beyond the end of the file grows it
+	n, err = c.copyFileRange(f, 0, f, 300, 100, 0)
+	require.NoError(t, err)
+	require.Equal(t, 100, n)
+	expected := append(append([]byte(nil), content...), content[:100]...)
+	copy(expected, content[250:])
+	mockExtentFiles.Lock()
+	require.Equal(t, expected, mockExtentFiles.data[ino])
+	mockExtentFiles.Unlock()
+
+	// nothing to copy at the end of the source
+	n, err = c.copyFileRange(f, 400, f, 0, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+}
+
+func TestReadTail(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Read":        MockExtentRead,
+		"Write":       MockExtentWrite,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+
+	ino := uint64(2100)
+	mockExtentFiles.Lock()
+	delete(mockExtentFiles.data, ino)
+	mockExtentFiles.Unlock()
+	writer := &file{ino: ino, flags: uint32(os.O_WRONLY | os.O_APPEND), regular: true, pos: &filePos{}}
+	reader := &file{ino: ino, flags: uint32(os.O_RDONLY), regular: true, pos: &filePos{}}
+
+	// an empty file has no tail
+	tail := make([]byte, 16)
+	n, err := c.readTail(reader, tail)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// a file shorter than the tail is read whole
+	var content []byte
+	_, err = c.appendRecord(writer, []byte("0123456789"))
+	require.NoError(t, err)
+	content = append(content, "0123456789"...)
+	n, err = c.readTail(reader, tail)
+	require.NoError(t, err)
+	require.Equal(t, content, tail[:n])
+
+	// the tail follows the file as it grows
+	for i := 0; i < 10; i++ {
+		line := logLine(i)
+		_, err = c.appendRecord(writer, line)
+		require.NoError(t, err)
+		content = append(content, line...)
+
+		n, err = c.readTail(reader, tail)
+		require.NoError(t, err)
+		require.Equal(t, len(tail), n)
+		require.Equal(t, content[len(content)-len(tail):], tail)
+	}
+
+	// the offset of the file is left alone
+	require.Equal(t, 0, reader.ioOffset(-1))
+}
+
+func TestReadTailStaleSize(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize": func(ec *stream.ExtentClient, inode uint64) (int, uint64, bool) {
+			return 0, 0, false
+		},
+		"Read": MockExtentRead,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+	c.ic = fs.NewInodeCache(50*time.Millisecond, fs.MaxInodeCache)
+
+	ino := uint64(2101)
+	content := []byte("0123456789abcdefghij")
+	mockExtentFiles.Lock()
+	mockExtentFiles.data[ino] = content
+	mockExtentFiles.Unlock()
+	gohook.UnHookMethod(c.mw, "InodeGet_ll")
+	require.NoError(t, gohook.HookMethod(c.mw, "InodeGet_ll", func(mw *meta.MetaWrapper, inode uint64) (*proto.InodeInfo, error) {
+		mockExtentFiles.Lock()
+		defer mockExtentFiles.Unlock()
+		return &proto.InodeInfo{Inode: inode, Mode: 0644, Size: uint64(len(mockExtentFiles.data[inode]))}, nil
+	}, nil))
+	reader := &file{ino: ino, flags: uint32(os.O_RDONLY), regular: true, pos: &filePos{}}
+
+	// a valid cached size is used even if another client has grown the file
+	c.ic.Put(&proto.InodeInfo{Inode: ino, Size: 10})
+	tail := make([]byte, 4)
+	n, err := c.readTail(reader, tail)
+	require.NoError(t, err)
+	require.Equal(t, "6789", string(tail[:n]))
+
+	// an expired one is fetched again
+	time.Sleep(60 * time.Millisecond)
+	n, err = c.readTail(reader, tail)
+	require.NoError(t, err)
+	require.Equal(t, "ghij", string(tail[:n]))
+	require.Equal(t, uint64(len(content)), c.ic.Get(ino).Size)
+}
+
+// setVolSpace sets the volume stat cached by the meta wrapper.
+func setVolSpace(mw *meta.MetaWrapper, total, used uint64) {
+	v := reflect.ValueOf(mw).Elem()
+	atomic.StoreUint64((*uint64)(unsafe.Pointer(v.FieldByName("totalSize").UnsafeAddr())), total)
+	atomic.StoreUint64((*uint64)(unsafe.Pointer(v.FieldByName("usedSize").UnsafeAddr())), used)
+}
+
+func MockExtentTruncate(ec *stream.ExtentClient, mw *meta.MetaWrapper, parentIno uint64, inode uint64, size int) error {
+	mockExtentFiles.Lock()
+	defer mockExtentFiles.Unlock()
+	file := mockExtentFiles.data[inode]
+	if size > len(file) {
+		file = append(file, make([]byte, size-len(file))...)
+	}
+	mockExtentFiles.data[inode] = file[:size]
+	return nil
+}
+
+// mockWriteLimit is the size the files written by MockExtentWriteLimited can't grow beyond,
+// like a volume running out of space in the middle of a write.
+var mockWriteLimit int
+
+func MockExtentWriteLimited(ec *stream.ExtentClient, inode uint64, offset int, data []byte, flags int, checkFunc func() error) (int, error) {
+	if end := offset + len(data); end > mockWriteLimit {
+		if offset < mockWriteLimit {
+			MockExtentWrite(ec, inode, offset, data[:mockWriteLimit-offset], flags, checkFunc)
+		}
+		return 0, syscall.EIO
+	}
+	return MockExtentWrite(ec, inode, offset, data, flags, checkFunc)
+}
+
+func TestPosixFallocateNoSpace(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":     MockFileSize,
+		"Truncate":     MockExtentTruncate,
+		"Read":         MockExtentRead,
+		"Write":        MockExtentWriteLimited,
+		"Flush":        MockExtentFlush,
+		"GetStreamer":  MockGetStreamer,
+		"PrepareWrite": MockPrepareWrite,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+
+	ino := uint64(2200)
+	mockExtentFiles.Lock()
+	mockExtentFiles.data[ino] = bytes.Repeat([]byte("a"), 100)
+	mockExtentFiles.Unlock()
+	mockWriteLimit = 150
+	f := &file{ino: ino, flags: uint32(os.O_RDWR), regular: true, pos: &filePos{}}
+
+	// the range within the file needs no space
+	require.NoError(t, c.posixFallocate(f, 0, 100))
+	_, ok := mockPrepared.Load(ino)
+	require.False(t, ok)
+
+	// the volume fills up while the growth is allocated, the size is restored
+	require.Equal(t, syscall.ENOSPC, c.posixFallocate(f, 100, 101))
+	prepared, _ := mockPrepared.Load(ino)
+	require.Equal(t, [2]int{100, 101}, prepared)
+	size, _ := c.fileSize(ino)
+	require.Equal(t, 100, size)
+
+	// the growth that fits is allocated, the data of the file is left alone
+	require.NoError(t, c.posixFallocate(f, 50, 100))
+	prepared, _ = mockPrepared.Load(ino)
+	require.Equal(t, [2]int{100, 50}, prepared)
+	buf := make([]byte, 200)
+	n, err := c.read(f, 0, buf)
+	require.NoError(t, err)
+	require.Equal(t, 150, n)
+	require.Equal(t, append(bytes.Repeat([]byte("a"), 100), make([]byte, 50)...), buf[:n])
+}
+
+func TestPunchHole(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Read":        MockExtentRead,
+		"Write":       MockExtentWrite,
+		"Flush":       MockExtentFlush,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+
+	ino := uint64(2210)
+	content := bytes.Repeat([]byte("0123456789abcdef"), 768)
+	mockExtentFiles.Lock()
+	mockExtentFiles.data[ino] = append([]byte(nil), content...)
+	mockExtentFiles.Unlock()
+	f := &file{ino: ino, flags: uint32(os.O_RDWR), regular: true, pos: &filePos{}}
+	readAll := func() []byte {
+		buf := make([]byte, len(content))
+		n, err := c.read(f, 0, buf)
+		require.NoError(t, err)
+		return buf[:n]
+	}
+
+	// the hole reads back as zeros, the bytes around it are kept
+	require.NoError(t, c.punchHole(f, 4096, 4096))
+	expected := append([]byte(nil), content...)
+	copy(expected[4096:8192], make([]byte, 4096))
+	require.Equal(t, expected, readAll())
+
+	// a hole across the end of the file does not grow it
+	require.NoError(t, c.punchHole(f, 10000, 10000))
+	copy(expected[10000:], make([]byte, len(expected)-10000))
+	require.Equal(t, expected, readAll())
+	size, _ := c.fileSize(ino)
+	require.Equal(t, len(content), size)
+
+	// a hole beyond the end of the file changes nothing
+	require.NoError(t, c.punchHole(f, 2*len(content), 4096))
+	size, _ = c.fileSize(ino)
+	require.Equal(t, len(content), size)
+}
+
+// mockVersions keeps the content of the files at each snapshot version created
+// through the mocked master.
+var mockVersions = struct {
+	sync.Mutex
+	ver   uint64
+	files map[uint64]map[uint64][]byte
+}{files: make(map[uint64]map[uint64][]byte)}
+
+func MockCreateVersion(api *masterSDK.AdminAPI, volName string) (*proto.VolVersionInfo, error) {
+	mockVersions.Lock()
+	defer mockVersions.Unlock()
+	mockExtentFiles.Lock()
+	defer mockExtentFiles.Unlock()
+	mockVersions.ver++
+	files := make(map[uint64][]byte)
+	for ino, data := range mockExtentFiles.data {
+		files[ino] = append([]byte(nil), data...)
+	}
+	mockVersions.files[mockVersions.ver] = files
+	return &proto.VolVersionInfo{Ver: mockVersions.ver}, nil
+}
+
+func TestCheckpoint(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Write":       MockExtentWrite,
+		"Flush":       MockExtentFlush,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	api := &masterSDK.AdminAPI{}
+	require.NoError(t, gohook.HookMethod(api, "CreateVersion", MockCreateVersion, nil))
+	defer gohook.UnHookMethod(api, "CreateVersion")
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+	c.appendWindow = time.Hour
+	c.appendBufSize = defaultAppendBufferSize
+
+	ino := uint64(2300)
+	mockExtentFiles.Lock()
+	delete(mockExtentFiles.data, ino)
+	mockExtentFiles.Unlock()
+	f := c.allocFD(ino, uint32(os.O_WRONLY|os.O_APPEND), 0644, false, 0, proto.RootIno)
+	require.NotNil(t, f)
+	defer c.releaseFD(f.fd)
+	c.openAppendBuffer(f)
+	require.NotNil(t, f.appendBuf)
+
+	// the writes are still buffered by the client
+	var before []byte
+	for i := 0; i < 10; i++ {
+		_, err := f.appendBuf.Write(logLine(i))
+		require.NoError(t, err)
+		before = append(before, logLine(i)...)
+	}
+	mockExtentFiles.Lock()
+	require.Empty(t, mockExtentFiles.data[ino])
+	mockExtentFiles.Unlock()
+
+	token, err := c.checkpoint()
+	require.NoError(t, err)
+
+	// more writes after the checkpoint
+	for i := 10; i < 20; i++ {
+		_, err = f.appendBuf.Write(logLine(i))
+		require.NoError(t, err)
+	}
+	require.NoError(t, c.flush(f))
+
+	// the version of the checkpoint holds only the data written before it
+	mockVersions.Lock()
+	require.Equal(t, before, mockVersions.files[token][ino])
+	mockVersions.Unlock()
+	mockExtentFiles.Lock()
+	require.Greater(t, len(mockExtentFiles.data[ino]), len(before))
+	require.Equal(t, before, mockExtentFiles.data[ino][:len(before)])
+	mockExtentFiles.Unlock()
+}
+
+// created keeps the owner of the inodes created through the mocked meta wrapper.
+var created sync.Map
+
+func MockCreate(mw *meta.MetaWrapper, parentID uint64, name string, mode, uid, gid uint32, target []byte) (*proto.InodeInfo, error) {
+	info := &proto.InodeInfo{Inode: uint64(len(name)) + 5000, Mode: mode, Uid: uid, Gid: gid}
+	created.Store(name, info)
+	return info, nil
+}
+
+func TestCreateWithFsids(t *testing.T) {
+	c1 := newMockClient(t)
+	c2 := newClient()
+	defer removeClient(c2.id)
+	c2.mw = c1.mw
+	require.NoError(t, gohook.HookMethod(c1.mw, "Create_ll", MockCreate, nil))
+	defer gohook.UnHookMethod(c1.mw, "Create_ll")
+
+	// the files are owned by root until the fsids are set
+	info, err := c1.create(proto.RootIno, "root-file", 0644)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), info.Uid)
+	require.Equal(t, uint32(0), info.Gid)
+
+	// every client creates files as its own user
+	c1.setFsuid(1000)
+	c1.setFsgid(100)
+	c2.setFsuid(2000)
+	c2.setFsgid(200)
+	_, err = c1.create(proto.RootIno, "user1-file", 0644)
+	require.NoError(t, err)
+	_, err = c1.mkdir(proto.RootIno, "user1-dir", 0755)
+	require.NoError(t, err)
+	_, err = c2.create(proto.RootIno, "user2-file", 0644)
+	require.NoError(t, err)
+
+	for name, owner := range map[string][2]uint32{
+		"user1-file": {1000, 100},
+		"user1-dir":  {1000, 100},
+		"user2-file": {2000, 200},
+	} {
+		v, ok := created.Load(name)
+		require.True(t, ok)
+		info = v.(*proto.InodeInfo)
+		require.Equal(t, owner[0], info.Uid, name)
+		require.Equal(t, owner[1], info.Gid, name)
+	}
+	v, _ := created.Load("user1-dir")
+	require.True(t, proto.IsDir(v.(*proto.InodeInfo).Mode))
+}
+
+// createdOnce keeps the names created through MockCreateExcl.
+var createdOnce sync.Map
+
+func MockCreateExcl(mw *meta.MetaWrapper, parentID uint64, name string, mode, uid, gid uint32, target []byte) (*proto.InodeInfo, error) {
+	info := &proto.InodeInfo{Inode: uint64(len(name)) + 6000, Mode: mode}
+	if _, loaded := createdOnce.LoadOrStore(name, info); loaded {
+		return nil, syscall.EEXIST
+	}
+	return info, nil
+}
+
+func TestCreateOrOpenRace(t *testing.T) {
+	c := newMockClient(t)
+	require.NoError(t, gohook.HookMethod(c.mw, "Create_ll", MockCreateExcl, nil))
+	defer gohook.UnHookMethod(c.mw, "Create_ll")
+
+	const openers = 32
+	var (
+		wg         sync.WaitGroup
+		createdCnt int32
+	)
+	for i := 0; i < openers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, created, err := c.createOrOpen(proto.RootIno, "race-file", "/race-file", 0644)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if info == nil {
+				t.Error("no inode returned")
+				return
+			}
+			if created {
+				atomic.AddInt32(&createdCnt, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.EqualValues(t, 1, createdCnt)
+
+	// the file exists, opening it again creates nothing
+	_, created, err := c.createOrOpen(proto.RootIno, "race-file", "/race-file", 0644)
+	require.NoError(t, err)
+	require.False(t, created)
+}
+
+func TestSetupSignals(t *testing.T) {
+	// embedders keep their own SIGTERM handling by default
+	c := newClient()
+	defer removeClient(c.id)
+	c.setupSignals()
+	require.False(t, signal.Ignored(syscall.SIGTERM))
+	require.False(t, signal.Ignored(syscall.SIGHUP))
+
+	c.ignoreSignals = true
+	c.setupSignals()
+	defer signal.Reset(syscall.SIGHUP, syscall.SIGTERM)
+	require.True(t, signal.Ignored(syscall.SIGTERM))
+	require.True(t, signal.Ignored(syscall.SIGHUP))
+}
+
+func TestOpenDeniedByInodeFlags(t *testing.T) {
+	for _, tc := range []struct {
+		inodeFlags uint32
+		openFlags  int
+		denied     bool
+	}{
+		{0, syscall.O_RDWR | syscall.O_TRUNC, false},
+		{proto.InodeFlagImmutable, syscall.O_RDONLY, false},
+		{proto.InodeFlagImmutable, syscall.O_WRONLY | syscall.O_APPEND, true},
+		{proto.InodeFlagImmutable, syscall.O_RDONLY | syscall.O_TRUNC, true},
+		{proto.InodeFlagAppendOnly, syscall.O_RDONLY, false},
+		{proto.InodeFlagAppendOnly, syscall.O_WRONLY | syscall.O_APPEND, false},
+		{proto.InodeFlagAppendOnly, syscall.O_RDWR, true},
+		{proto.InodeFlagAppendOnly, syscall.O_WRONLY | syscall.O_APPEND | syscall.O_TRUNC, true},
+	} {
+		require.Equal(t, tc.denied, openDeniedByInodeFlags(tc.inodeFlags, uint32(tc.openFlags)), "%+v", tc)
+	}
+}
+
+func TestInodeStats(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Read":        MockExtentRead,
+		"Write":       MockExtentWrite,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+
+	ino := uint64(2300)
+	mockExtentFiles.Lock()
+	delete(mockExtentFiles.data, ino)
+	mockExtentFiles.Unlock()
+	f := &file{ino: ino, flags: uint32(os.O_RDWR), regular: true, pos: &filePos{}}
+
+	// two writes extend the file, the third one overwrites the first
+	data := make([]byte, 4096)
+	for _, off := range []int{0, 4096, 0} {
+		n, err := c.write(f, off, data, 0)
+		require.NoError(t, err)
+		require.Equal(t, len(data), n)
+	}
+	// appends never overwrite
+	_, err := c.appendRecord(f, data[:100])
+	require.NoError(t, err)
+
+	buf := make([]byte, 1024)
+	for _, off := range []int{0, 8192} {
+		_, err = c.read(f, off, buf)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, inodeStatsView{
+		Inode:      ino,
+		ReadBytes:  1024 + 100,
+		ReadOps:    2,
+		WriteBytes: 3*4096 + 100,
+		WriteOps:   4,
+		RowOps:     1,
+	}, c.inodeStats.snapshot(ino))
+
+	data, err = json.Marshal(c.inodeStats.snapshot(ino))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"inode":2300,"readBytes":1124,"readOps":2,"writeBytes":12388,"writeOps":4,"rowOps":1}`, string(data))
+
+	// other inodes are counted apart, and a reset starts over
+	require.Equal(t, inodeStatsView{Inode: ino + 1}, c.inodeStats.snapshot(ino+1))
+	c.inodeStats.reset(ino)
+	require.Equal(t, inodeStatsView{Inode: ino}, c.inodeStats.snapshot(ino))
+	_, err = c.write(f, 0, data[:10], 0)
+	require.NoError(t, err)
+	require.Equal(t, inodeStatsView{Inode: ino, WriteBytes: 10, WriteOps: 1, RowOps: 1}, c.inodeStats.snapshot(ino))
+}
+
+func TestAllocFDBase(t *testing.T) {
+	c := newMockClient(t)
+	c.fdset.Set(0).Set(1).Set(2)
+
+	// the fds below the base are never handed out
+	c.fdBase = 100
+	f1 := c.allocFD(1000, uint32(os.O_RDWR), 0644, false, 0, proto.RootIno)
+	f2 := c.allocFD(1001, uint32(os.O_RDWR), 0644, false, 0, proto.RootIno)
+	require.Equal(t, uint(100), f1.fd)
+	require.Equal(t, uint(101), f2.fd)
+
+	// a released fd is reused before the next one
+	c.releaseFD(f1.fd)
+	f3 := c.allocFD(1002, uint32(os.O_RDWR), 0644, false, 0, proto.RootIno)
+	require.Equal(t, uint(100), f3.fd)
+	require.Equal(t, c.getFile(100), f3)
+
+	// without a base the fds start after stdin, stdout and stderr
+	c.fdBase = 0
+	f4 := c.allocFD(1003, uint32(os.O_RDWR), 0644, false, 0, proto.RootIno)
+	require.Equal(t, uint(3), f4.fd)
+}
+
+// mockPrepared keeps the range last prepared for writing on each inode.
+var mockPrepared sync.Map // inode -> [2]int{offset, size}
+
+func MockPrepareWrite(ec *stream.ExtentClient, inode uint64, offset, size int) error {
+	mockPrepared.Store(inode, [2]int{offset, size})
+	return nil
+}
+
+func TestPreallocate(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":     MockFileSize,
+		"Read":         MockExtentRead,
+		"Write":        MockExtentWrite,
+		"Flush":        MockExtentFlush,
+		"GetStreamer":  MockGetStreamer,
+		"PrepareWrite": MockPrepareWrite,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+
+	size := 2*copyBufferSize + 4096
+	setVolSpace(c.mw, uint64(3*copyBufferSize), uint64(copyBufferSize))
+
+	// the volume can't hold the file
+	ino := uint64(2700)
+	f := &file{ino: ino, flags: uint32(os.O_RDWR), regular: true, pos: &filePos{}}
+	require.Equal(t, syscall.ENOSPC, c.preallocate(f, size))
+	fileSize, _ := c.fileSize(ino)
+	require.Equal(t, 0, fileSize)
+	_, ok := mockPrepared.Load(ino)
+	require.False(t, ok)
+
+	// the whole file is written up front, beyond the tiny extent size so in normal extents
+	setVolSpace(c.mw, uint64(4*copyBufferSize), uint64(copyBufferSize))
+	ino = uint64(2701)
+	f = &file{ino: ino, flags: uint32(os.O_RDWR), regular: true, pos: &filePos{}}
+	require.NoError(t, c.preallocate(f, size))
+	prepared, ok := mockPrepared.Load(ino)
+	require.True(t, ok)
+	require.Equal(t, [2]int{0, size}, prepared)
+	require.Greater(t, size, util.DefaultTinySizeLimit)
+	fileSize, _ = c.fileSize(ino)
+	require.Equal(t, size, fileSize)
+	buf := make([]byte, size)
+	n, err := c.read(f, 0, buf)
+	require.NoError(t, err)
+	require.Equal(t, size, n)
+	require.Equal(t, make([]byte, size), buf)
+
+	// the writes within the file land in the space reserved, even if the volume fills up
+	setVolSpace(c.mw, uint64(4*copyBufferSize), uint64(4*copyBufferSize))
+	data := bytes.Repeat([]byte("cubefs"), 1000)
+	n, err = c.write(f, copyBufferSize, data, 0)
+	require.NoError(t, err)
+	require.Equal(t, len(data), n)
+	fileSize, _ = c.fileSize(ino)
+	require.Equal(t, size, fileSize)
+	n, err = c.read(f, copyBufferSize-10, buf[:len(data)+20])
+	require.NoError(t, err)
+	require.Equal(t, len(data)+20, n)
+	require.Equal(t, make([]byte, 10), buf[:10])
+	require.Equal(t, data, buf[10:len(data)+10])
+	require.Equal(t, make([]byte, 10), buf[len(data)+10:len(data)+20])
+}
+
+var mockBatchInodeGetCnt int
+
+func MockBatchInodeGet(mw *meta.MetaWrapper, inodes []uint64) []*proto.InodeInfo {
+	mockBatchInodeGetCnt++
+	infos := make([]*proto.InodeInfo, 0, len(inodes))
+	for _, ino := range inodes {
+		info, _ := MockInodeGet(mw, ino)
+		infos = append(infos, info)
+	}
+	mockInodeGetCnt -= len(inodes)
+	return infos
+}
+
+func TestStatBatch(t *testing.T) {
+	c := newMockClient(t)
+	require.NoError(t, gohook.HookMethod(c.mw, "BatchInodeGet", MockBatchInodeGet, nil))
+	defer gohook.UnHookMethod(c.mw, "BatchInodeGet")
+
+	// the mocked lookup gives the paths of different lengths different inodes
+	paths := make([]string, 100)
+	for i := range paths {
+		paths[i] = "/data/" + strings.Repeat("f", i+1)
+		mockInodeMtime.Store(uint64(len(paths[i]))+proto.RootIno, time.Unix(int64(i), 0))
+	}
+
+	mockLookupPathCnt, mockInodeGetCnt, mockBatchInodeGetCnt = 0, 0, 0
+	infos, errs := c.statBatch(paths)
+	require.Equal(t, 100, mockLookupPathCnt)
+	require.Equal(t, 1, mockBatchInodeGetCnt)
+	require.Equal(t, 0, mockInodeGetCnt)
+
+	// the inodes fetched in the batch are cached
+	mockLookupPathCnt, mockBatchInodeGetCnt = 0, 0
+	_, _ = c.statBatch(paths)
+	require.Equal(t, 0, mockLookupPathCnt)
+	require.Equal(t, 0, mockBatchInodeGetCnt)
+
+	// the same as stat-ing the paths one by one, which takes two round trips each
+	c.noCachePatterns = []string{"/data/*"}
+	mockLookupPathCnt, mockInodeGetCnt = 0, 0
+	for i, path := range paths {
+		info, err := c.lookupPath(path)
+		require.NoError(t, err)
+		require.NoError(t, errs[i])
+		require.Equal(t, info.Inode, infos[i].Inode)
+		require.Equal(t, info.Mode, infos[i].Mode)
+		require.Equal(t, info.Size, infos[i].Size)
+		require.Equal(t, info.ModifyTime, infos[i].ModifyTime)
+		require.Equal(t, time.Unix(int64(i), 0), infos[i].ModifyTime)
+	}
+	require.Equal(t, 100, mockLookupPathCnt)
+	require.Equal(t, 100, mockInodeGetCnt)
+}
+
+// mockTmpInodes keeps the links of the inodes created through the mocked meta wrapper.
+var mockTmpInodes = struct {
+	sync.Mutex
+	nlink   map[uint64]int
+	names   map[string]uint64
+	evicted map[uint64]bool
+}{nlink: make(map[uint64]int), names: make(map[string]uint64), evicted: make(map[uint64]bool)}
+
+func MockInodeCreate(mw *meta.MetaWrapper, parentID uint64, mode, uid, gid uint32, target []byte, quotaIds []uint64) (*proto.InodeInfo, error) {
+	mockTmpInodes.Lock()
+	defer mockTmpInodes.Unlock()
+	ino := uint64(2800 + len(mockTmpInodes.nlink))
+	mockTmpInodes.nlink[ino] = 1
+	return &proto.InodeInfo{Inode: ino, Mode: mode, Nlink: 1}, nil
+}
+
+func MockLink(mw *meta.MetaWrapper, parentID uint64, name string, ino uint64) (*proto.InodeInfo, error) {
+	mockTmpInodes.Lock()
+	defer mockTmpInodes.Unlock()
+	mockTmpInodes.nlink[ino]++
+	mockTmpInodes.names[name] = ino
+	return &proto.InodeInfo{Inode: ino, Nlink: uint32(mockTmpInodes.nlink[ino])}, nil
+}
+
+func MockInodeUnlink(mw *meta.MetaWrapper, ino uint64) (*proto.InodeInfo, error) {
+	mockTmpInodes.Lock()
+	defer mockTmpInodes.Unlock()
+	mockTmpInodes.nlink[ino]--
+	return &proto.InodeInfo{Inode: ino, Nlink: uint32(mockTmpInodes.nlink[ino])}, nil
+}
+
+func MockEvict(mw *meta.MetaWrapper, ino uint64) error {
+	mockTmpInodes.Lock()
+	defer mockTmpInodes.Unlock()
+	mockTmpInodes.evicted[ino] = true
+	return nil
+}
+
+func TestTmpFile(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"OpenStream":  MockOpenStream,
+		"FileSize":    MockFileSize,
+		"Read":        MockExtentRead,
+		"Write":       MockExtentWrite,
+		"Flush":       MockExtentFlush,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+	for name, fn := range map[string]interface{}{
+		"InodeCreate_ll": MockInodeCreate,
+		"Link":           MockLink,
+		"InodeUnlink_ll": MockInodeUnlink,
+		"Evict":          MockEvict,
+	} {
+		require.NoError(t, gohook.HookMethod(c.mw, name, fn, nil))
+		defer gohook.UnHookMethod(c.mw, name)
+	}
+	nlink := func(ino uint64) int {
+		mockTmpInodes.Lock()
+		defer mockTmpInodes.Unlock()
+		return mockTmpInodes.nlink[ino]
+	}
+	evicted := func(ino uint64) bool {
+		mockTmpInodes.Lock()
+		defer mockTmpInodes.Unlock()
+		return mockTmpInodes.evicted[ino]
+	}
+
+	// the data written to the unnamed file is there once it is linked
+	f, err := c.openTmpFile(proto.RootIno, uint32(os.O_RDWR), 0644)
+	require.NoError(t, err)
+	require.True(t, f.regular)
+	content := []byte("written before the file has a name")
+	n, err := c.write(f, 0, content, 0)
+	require.NoError(t, err)
+	require.Equal(t, len(content), n)
+	require.NoError(t, c.linkFile(f, proto.RootIno, "tmp-linked"))
+	mockTmpInodes.Lock()
+	require.Equal(t, f.ino, mockTmpInodes.names["tmp-linked"])
+	mockTmpInodes.Unlock()
+	require.Equal(t, 1, nlink(f.ino))
+
+	buf := make([]byte, 64)
+	n, err = c.read(f, 0, buf)
+	require.NoError(t, err)
+	require.Equal(t, content, buf[:n])
+
+	// a linked file is kept after the last fd is closed
+	c.releaseFD(f.fd)
+	c.closeTmpFile(f)
+	require.False(t, evicted(f.ino))
+	require.Equal(t, 1, nlink(f.ino))
+
+	// an unnamed file is removed when the last of its fds is closed
+	f, err = c.openTmpFile(proto.RootIno, uint32(os.O_WRONLY), 0600)
+	require.NoError(t, err)
+	dup := c.dupFile(f, true)
+	require.NotNil(t, dup)
+	c.releaseFD(f.fd)
+	c.closeTmpFile(f)
+	require.False(t, evicted(f.ino))
+	c.releaseFD(dup.fd)
+	c.closeTmpFile(dup)
+	require.True(t, evicted(f.ino))
+	require.Equal(t, 0, nlink(f.ino))
+}
+
+func TestFcntlFlags(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"OpenStream": MockOpenStream,
+		"FileSize":   MockFileSize,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+	c.appendWindow = time.Millisecond
+
+	f := c.allocFD(2900, uint32(os.O_WRONLY|syscall.O_CLOEXEC), 0644, false, 0, proto.RootIno)
+	require.NotNil(t, f)
+	f.regular = true
+
+	// the access mode and the status flags are returned, O_CLOEXEC is not
+	flags, err := c.fcntl(f, syscall.F_GETFL, 0)
+	require.NoError(t, err)
+	require.Equal(t, os.O_WRONLY, flags)
+
+	// O_APPEND is set and cleared, the access mode is kept
+	_, err = c.fcntl(f, syscall.F_SETFL, syscall.O_APPEND|syscall.O_RDWR)
+	require.NoError(t, err)
+	flags, _ = c.fcntl(f, syscall.F_GETFL, 0)
+	require.Equal(t, os.O_WRONLY|syscall.O_APPEND, flags)
+	require.NotNil(t, f.appendBuf)
+	_, err = c.fcntl(f, syscall.F_SETFL, 0)
+	require.NoError(t, err)
+	flags, _ = c.fcntl(f, syscall.F_GETFL, 0)
+	require.Equal(t, os.O_WRONLY, flags)
+	require.Nil(t, f.appendBuf)
+
+	// FD_CLOEXEC follows O_CLOEXEC of the open and is toggled by F_SETFD
+	fdFlags, err := c.fcntl(f, syscall.F_GETFD, 0)
+	require.NoError(t, err)
+	require.Equal(t, syscall.FD_CLOEXEC, fdFlags)
+	_, err = c.fcntl(f, syscall.F_SETFD, 0)
+	require.NoError(t, err)
+	fdFlags, _ = c.fcntl(f, syscall.F_GETFD, 0)
+	require.Equal(t, 0, fdFlags)
+	_, err = c.fcntl(f, syscall.F_SETFD, syscall.FD_CLOEXEC)
+	require.NoError(t, err)
+	fdFlags, _ = c.fcntl(f, syscall.F_GETFD, 0)
+	require.Equal(t, syscall.FD_CLOEXEC, fdFlags)
+
+	// the duplicate does not inherit FD_CLOEXEC
+	newFd, err := c.fcntl(f, syscall.F_DUPFD, 0)
+	require.NoError(t, err)
+	dup := c.getFile(uint(newFd))
+	require.NotNil(t, dup)
+	fdFlags, _ = c.fcntl(dup, syscall.F_GETFD, 0)
+	require.Equal(t, 0, fdFlags)
+
+	_, err = c.fcntl(f, syscall.F_GETLK, 0)
+	require.Equal(t, syscall.EINVAL, err)
+}
+
+func TestStatvfs(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Write":       MockExtentWrite,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+	setVolSpace(c.mw, 1<<30, 1<<28)
+	// fetched from the master just now
+	c.volStat.fetched = time.Now()
+
+	st := c.statvfs()
+	require.Equal(t, int64(4096), st.Bsize)
+	require.Equal(t, uint64(1<<18), st.Blocks)
+	require.Equal(t, uint64(3<<16), st.Bfree)
+	require.Equal(t, uint64(3<<16), st.Bavail)
+
+	// the bytes written are used before the master reports them
+	f := &file{ino: 2920, flags: uint32(os.O_RDWR), regular: true, pos: &filePos{}}
+	_, err := c.write(f, 0, make([]byte, 1<<20), 0)
+	require.NoError(t, err)
+	st = c.statvfs()
+	require.Equal(t, uint64(3<<16-1<<8), st.Bfree)
+	require.Equal(t, uint64(3<<16-1<<8), st.Bavail)
+	require.Equal(t, uint64(1<<18), st.Blocks)
+
+	// a volume filled up by the writes has no space left
+	c.volStat.addWritten(1 << 30)
+	require.Equal(t, uint64(0), c.statvfs().Bavail)
+}
+
+// mockDirents is the content of the dir returned by the mocked ReadDir_ll.
+var mockDirents struct {
+	sync.Mutex
+	dentries []proto.Dentry
+}
+
+func MockReadDir(mw *meta.MetaWrapper, parentID uint64) ([]proto.Dentry, error) {
+	mockDirents.Lock()
+	defer mockDirents.Unlock()
+	return append([]proto.Dentry(nil), mockDirents.dentries...), nil
+}
+
+func TestDirStreamSeek(t *testing.T) {
+	c := newMockClient(t)
+	require.NoError(t, gohook.HookMethod(c.mw, "ReadDir_ll", MockReadDir, nil))
+	defer gohook.UnHookMethod(c.mw, "ReadDir_ll")
+
+	names := []string{"a", "c", "e", "g", "i", "k"}
+	mockDirents.Lock()
+	mockDirents.dentries = nil
+	for i, name := range names {
+		mockDirents.dentries = append(mockDirents.dentries, proto.Dentry{Name: name, Inode: uint64(3000 + i)})
+	}
+	mockDirents.Unlock()
+
+	f := &file{ino: 2930, pos: &filePos{}}
+	require.NoError(t, c.loadDirStream(f))
+	require.Equal(t, int64(0), f.dirp.tell())
+
+	// read half of the dir, the cookies increase
+	var (
+		read []string
+		last int64
+	)
+	for i := 0; i < 3; i++ {
+		dentry, off, ok := f.dirp.next()
+		require.True(t, ok)
+		require.Greater(t, off, last)
+		read = append(read, dentry.Name)
+		last = off
+	}
+	require.Equal(t, names[:3], read)
+	require.Equal(t, last, f.dirp.tell())
+	half := f.dirp.tell()
+
+	// finish the dir then resume from the half
+	for {
+		if _, _, ok := f.dirp.next(); !ok {
+			break
+		}
+	}
+	require.NoError(t, f.dirp.seek(half))
+	require.NoError(t, c.loadDirStream(f))
+	dentry, _, ok := f.dirp.next()
+	require.True(t, ok)
+	require.Equal(t, "g", dentry.Name)
+
+	// entries created or removed in between skip none of the others
+	mockDirents.Lock()
+	mockDirents.dentries = append(mockDirents.dentries[:1], mockDirents.dentries[2:]...) // remove c
+	mockDirents.dentries = append(mockDirents.dentries, proto.Dentry{Name: "f", Inode: 3100}, proto.Dentry{Name: "b", Inode: 3101})
+	mockDirents.Unlock()
+	require.NoError(t, f.dirp.seek(half))
+	require.NoError(t, c.loadDirStream(f))
+	read = nil
+	for {
+		dentry, _, ok = f.dirp.next()
+		if !ok {
+			break
+		}
+		read = append(read, dentry.Name)
+	}
+	require.Equal(t, []string{"f", "g", "i", "k"}, read)
+
+	// rewinding reads the dir from the start, unknown cookies are rejected
+	require.NoError(t, f.dirp.seek(0))
+	require.NoError(t, c.loadDirStream(f))
+	dentry, _, _ = f.dirp.next()
+	require.Equal(t, "a", dentry.Name)
+	dentry, _, _ = f.dirp.next()
+	require.Equal(t, "b", dentry.Name)
+	require.Equal(t, syscall.EINVAL, f.dirp.seek(1000))
+}
+
+var mockFlushCnt int32
+
+func MockCountedFlush(ec *stream.ExtentClient, inode uint64) error {
+	atomic.AddInt32(&mockFlushCnt, 1)
+	return nil
+}
+
+func TestFdatasync(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Read":        MockExtentRead,
+		"Write":       MockExtentWrite,
+		"GetStreamer": MockGetStreamer,
+		"Flush":       MockCountedFlush,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+
+	f := &file{ino: 2940, flags: uint32(os.O_RDWR), regular: true, pos: &filePos{}}
+	content := []byte("synced without the attributes")
+	_, err := c.write(f, 0, content, 0)
+	require.NoError(t, err)
+
+	// fdatasync flushes the data, the inode is not read back
+	mockInodeGetCnt = 0
+	atomic.StoreInt32(&mockFlushCnt, 0)
+	require.NoError(t, c.fsync(f, true))
+	require.EqualValues(t, 1, atomic.LoadInt32(&mockFlushCnt))
+	require.Equal(t, 0, mockInodeGetCnt)
+	buf := make([]byte, 64)
+	n, err := c.read(f, 0, buf)
+	require.NoError(t, err)
+	require.Equal(t, content, buf[:n])
+
+	// fsync refreshes the cached attributes from the metanode
+	require.NoError(t, c.fsync(f, false))
+	require.EqualValues(t, 2, atomic.LoadInt32(&mockFlushCnt))
+	require.Equal(t, 1, mockInodeGetCnt)
+	require.NotNil(t, c.ic.Get(f.ino))
+}
+
+// mockNamespace maps parent/name to the inode for the mocked renames.
+var mockNamespace = struct {
+	sync.Mutex
+	dentries map[string]uint64
+}{dentries: make(map[string]uint64)}
+
+func mockDentryKey(parentID uint64, name string) string {
+	return fmt.Sprintf("%d/%s", parentID, name)
+}
+
+func MockRename(mw *meta.MetaWrapper, srcParentID uint64, srcName string, dstParentID uint64, dstName string, overwritten bool) error {
+	mockNamespace.Lock()
+	defer mockNamespace.Unlock()
+	src, dst := mockDentryKey(srcParentID, srcName), mockDentryKey(dstParentID, dstName)
+	ino, ok := mockNamespace.dentries[src]
+	if !ok {
+		return syscall.ENOENT
+	}
+	if _, ok = mockNamespace.dentries[dst]; ok && !overwritten {
+		return syscall.EEXIST
+	}
+	delete(mockNamespace.dentries, src)
+	mockNamespace.dentries[dst] = ino
+	return nil
+}
+
+func MockRenameExchange(mw *meta.MetaWrapper, srcParentID uint64, srcName string, dstParentID uint64, dstName string) error {
+	mockNamespace.Lock()
+	defer mockNamespace.Unlock()
+	src, dst := mockDentryKey(srcParentID, srcName), mockDentryKey(dstParentID, dstName)
+	srcIno, srcOk := mockNamespace.dentries[src]
+	dstIno, dstOk := mockNamespace.dentries[dst]
+	if !srcOk || !dstOk {
+		return syscall.ENOENT
+	}
+	mockNamespace.dentries[src], mockNamespace.dentries[dst] = dstIno, srcIno
+	return nil
+}
+
+func TestRenameFlags(t *testing.T) {
+	c := newMockClient(t)
+	for name, fn := range map[string]interface{}{
+		"Rename_ll":         MockRename,
+		"RenameExchange_ll": MockRenameExchange,
+	} {
+		require.NoError(t, gohook.HookMethod(c.mw, name, fn, nil))
+		defer gohook.UnHookMethod(c.mw, name)
+	}
+	dirA, err := c.lookupPath("/rename/a/")
+	require.NoError(t, err)
+	dirB, err := c.lookupPath("/rename/bb/")
+	require.NoError(t, err)
+	dentry := func(parentID uint64, name string) uint64 {
+		mockNamespace.Lock()
+		defer mockNamespace.Unlock()
+		return mockNamespace.dentries[mockDentryKey(parentID, name)]
+	}
+	mockNamespace.Lock()
+	mockNamespace.dentries[mockDentryKey(dirA.Inode, "x")] = 3200
+	mockNamespace.dentries[mockDentryKey(dirB.Inode, "y")] = 3201
+	mockNamespace.Unlock()
+
+	// no-clobber renames fail on an existing destination
+	require.Equal(t, syscall.EEXIST, c.renameFlags("/rename/a/x", "/rename/bb/y", renameNoReplace))
+	require.Equal(t, uint64(3200), dentry(dirA.Inode, "x"))
+	require.Equal(t, uint64(3201), dentry(dirB.Inode, "y"))
+	require.Equal(t, syscall.EINVAL, c.renameFlags("/rename/a/x", "/rename/bb/y", renameNoReplace|renameExchange))
+
+	// the entries swap their inodes, the cached paths are dropped
+	c.dc.Put("/rename/a/x", 3200)
+	c.dc.Put("/rename/bb/y", 3201)
+	require.NoError(t, c.renameFlags("/rename/a/x", "/rename/bb/y", renameExchange))
+	require.Equal(t, uint64(3201), dentry(dirA.Inode, "x"))
+	require.Equal(t, uint64(3200), dentry(dirB.Inode, "y"))
+	_, ok := c.dc.Get("/rename/a/x")
+	require.False(t, ok)
+	_, ok = c.dc.Get("/rename/bb/y")
+	require.False(t, ok)
+
+	// a plain rename replaces the destination
+	require.NoError(t, c.renameFlags("/rename/a/x", "/rename/bb/y", 0))
+	require.Equal(t, uint64(0), dentry(dirA.Inode, "x"))
+	require.Equal(t, uint64(3201), dentry(dirB.Inode, "y"))
+
+	// paths are relative to the dir fds
+	dirf := c.allocFD(dirB.Inode, uint32(os.O_RDONLY), 0755, false, 0, proto.RootIno)
+	require.NotNil(t, dirf)
+	dirf.path = "/rename/bb"
+	p, err := c.atPath(int(dirf.fd), "y")
+	require.NoError(t, err)
+	require.Equal(t, "/rename/bb/y", p)
+	p, err = c.atPath(atFdcwd, "y")
+	require.NoError(t, err)
+	require.Equal(t, "/y", p)
+	regular := c.allocFD(3201, uint32(os.O_RDONLY), 0644, false, 0, dirB.Inode)
+	_, err = c.atPath(int(regular.fd), "y")
+	require.Equal(t, syscall.ENOTDIR, err)
+}
+
+func TestResolveSymlink(t *testing.T) {
+	c := newMockClient(t)
+	// MockLookupPath resolves a path to the inode len(path)+1, the inodes are
+	// put into the inode cache so lookupPath returns them
+	putInode := func(path string, mode uint32, target string) *proto.InodeInfo {
+		info := &proto.InodeInfo{Inode: uint64(len(path)) + proto.RootIno, Mode: mode, Target: []byte(target)}
+		c.ic.Put(info)
+		return info
+	}
+	symlink := uint32(os.ModeSymlink | 0777)
+
+	// a link to itself
+	self := putInode("/l/s", symlink, "s")
+	_, _, err := c.resolveSymlink("/l/s", self)
+	require.Equal(t, syscall.ELOOP, err)
+
+	// a -> b -> a, with relative and absolute targets
+	a := putInode("/loop/a", symlink, "bb")
+	putInode("/loop/bb", symlink, "/loop/a")
+	_, _, err = c.resolveSymlink("/loop/a", a)
+	require.Equal(t, syscall.ELOOP, err)
+
+	// the relative targets are resolved against the dir of the link, not the cwd
+	c.cwd = "/elsewhere"
+	link := putInode("/dir/sub/link", symlink, "../file12")
+	file := putInode("/dir/file12", 0644, "")
+	info, path, err := c.resolveSymlink("/dir/sub/link", link)
+	require.NoError(t, err)
+	require.Equal(t, "/dir/file12", path)
+	require.Equal(t, file.Inode, info.Inode)
+
+	// a regular file is returned as is
+	info, path, err = c.resolveSymlink("/dir/file12", file)
+	require.NoError(t, err)
+	require.Equal(t, "/dir/file12", path)
+	require.Equal(t, file, info)
+}
+
+func TestGetdents64(t *testing.T) {
+	c := newMockClient(t)
+	require.NoError(t, gohook.HookMethod(c.mw, "ReadDir_ll", MockReadDir, nil))
+	defer gohook.UnHookMethod(c.mw, "ReadDir_ll")
+
+	bigIno := uint64(1)<<33 + 7
+	mockDirents.Lock()
+	mockDirents.dentries = []proto.Dentry{
+		{Name: "big", Inode: bigIno, Type: 0644},
+		{Name: "dir", Inode: 3300, Type: uint32(os.ModeDir | 0755)},
+		{Name: strings.Repeat("n", 300), Inode: 3301, Type: uint32(os.ModeSymlink | 0777)},
+	}
+	mockDirents.Unlock()
+
+	type dirent64 struct {
+		ino    uint64
+		off    int64
+		reclen int
+		typ    byte
+		name   string
+	}
+	// the records as laid out by glibc struct dirent64
+	parse := func(buf []byte) (dirents []dirent64) {
+		for len(buf) > 0 {
+			d := dirent64{
+				ino:    binary.LittleEndian.Uint64(buf[0:]),
+				off:    int64(binary.LittleEndian.Uint64(buf[8:])),
+				reclen: int(binary.LittleEndian.Uint16(buf[16:])),
+				typ:    buf[18],
+			}
+			d.name = string(buf[19 : 19+bytes.IndexByte(buf[19:], 0)])
+			require.Zero(t, d.reclen%8)
+			dirents = append(dirents, d)
+			buf = buf[d.reclen:]
+		}
+		return dirents
+	}
+
+	f := &file{ino: 2960, pos: &filePos{}}
+	// too small for the first record
+	_, err := c.getdents64(f, make([]byte, 16))
+	require.Equal(t, syscall.EINVAL, err)
+
+	// a buffer for the first two records only
+	buf := make([]byte, 56)
+	n, err := c.getdents64(f, buf)
+	require.NoError(t, err)
+	require.Equal(t, 48, n)
+	dirents := parse(buf[:n])
+	require.Len(t, dirents, 2)
+	require.Equal(t, bigIno, dirents[0].ino)
+	require.Equal(t, "big", dirents[0].name)
+	require.Equal(t, byte(syscall.DT_REG), dirents[0].typ)
+	require.Equal(t, uint64(3300), dirents[1].ino)
+	require.Equal(t, byte(syscall.DT_DIR), dirents[1].typ)
+	require.Equal(t, dirents[1].off, f.dirp.tell())
+
+	// the rest of the dir, the long name is truncated
+	buf = make([]byte, 4096)
+	n, err = c.getdents64(f, buf)
+	require.NoError(t, err)
+	dirents = parse(buf[:n])
+	require.Len(t, dirents, 1)
+	require.Equal(t, strings.Repeat("n", 255), dirents[0].name)
+	require.Equal(t, byte(syscall.DT_LNK), dirents[0].typ)
+	n, err = c.getdents64(f, buf)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+}
+
+var mockCached int32
+
+func MockIsCached(ec *stream.ExtentClient, inode uint64, offset int, size int) (bool, error) {
+	return atomic.LoadInt32(&mockCached) == 1, nil
+}
+
+func TestVectoredIOFlags(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FileSize":    MockFileSize,
+		"Write":       MockExtentWrite,
+		"Read":        MockExtentRead,
+		"Flush":       MockCountedFlush,
+		"IsCached":    MockIsCached,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	f := c.allocFD(3000, uint32(os.O_RDWR), 0644, false, 0, proto.RootIno)
+	require.NotNil(t, f)
+
+	// the buffers are written as one, without flushing
+	atomic.StoreInt32(&mockFlushCnt, 0)
+	n, err := c.pwritev2(f, 0, [][]byte{[]byte("hello "), []byte("world")}, rwfHipri)
+	require.NoError(t, err)
+	require.Equal(t, 11, n)
+	require.EqualValues(t, 0, atomic.LoadInt32(&mockFlushCnt))
+
+	// RWF_DSYNC flushes the write
+	n, err = c.pwritev2(f, -1, [][]byte{[]byte("!")}, rwfDsync)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.EqualValues(t, 1, atomic.LoadInt32(&mockFlushCnt))
+	require.Equal(t, 1, f.ioOffset(-1))
+
+	// a non-blocking write is not supported
+	_, err = c.pwritev2(f, 0, [][]byte{[]byte("x")}, rwfNowait)
+	require.Equal(t, syscall.EOPNOTSUPP, err)
+	_, err = c.pwritev2(f, 0, [][]byte{[]byte("x")}, int(syscall.O_APPEND))
+	require.Equal(t, syscall.EOPNOTSUPP, err)
+
+	// the data is scattered to the buffers
+	bufs := [][]byte{make([]byte, 4), make([]byte, 3), make([]byte, 10)}
+	n, err = c.preadv2(f, 0, bufs, 0)
+	require.NoError(t, err)
+	require.Equal(t, 11, n)
+	require.Equal(t, "!ell", string(bufs[0]))
+	require.Equal(t, "o w", string(bufs[1]))
+	require.Equal(t, "orld", string(bufs[2][:4]))
+
+	// RWF_NOWAIT reads only the cached data
+	atomic.StoreInt32(&mockCached, 0)
+	_, err = c.preadv2(f, 0, [][]byte{make([]byte, 5)}, rwfNowait)
+	require.Equal(t, syscall.EAGAIN, err)
+	atomic.StoreInt32(&mockCached, 1)
+	buf := make([]byte, 5)
+	n, err = c.preadv2(f, 6, [][]byte{buf}, rwfNowait)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(buf[:n]))
+}
+
+// mockXAttrs are the xattrs set through MockXAttrSet, by inode then name.
+var mockXAttrs = make(map[uint64]map[string]string)
+
+func MockXAttrsList(mw *meta.MetaWrapper, inode uint64) ([]string, error) {
+	keys := make([]string, 0)
+	for key := range mockXAttrs[inode] {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func MockXAttrSet(mw *meta.MetaWrapper, inode uint64, name, value []byte) error {
+	if mockXAttrs[inode] == nil {
+		mockXAttrs[inode] = make(map[string]string)
+	}
+	mockXAttrs[inode][string(name)] = string(value)
+	return nil
+}
+
+func TestSetxattrFlags(t *testing.T) {
+	c := newMockClient(t)
+	require.NoError(t, gohook.HookMethod(c.mw, "XAttrsList_ll", MockXAttrsList, nil))
+	defer gohook.UnHookMethod(c.mw, "XAttrsList_ll")
+	require.NoError(t, gohook.HookMethod(c.mw, "XAttrSet_ll", MockXAttrSet, nil))
+	defer gohook.UnHookMethod(c.mw, "XAttrSet_ll")
+
+	info := &proto.InodeInfo{Inode: 3000, Mode: 0644}
+
+	// replacing a missing xattr fails, creating it succeeds
+	require.Equal(t, syscall.ENODATA, c.setxattr(info, "user.a", []byte("1"), xattrReplace))
+	require.NotContains(t, mockXAttrs[info.Inode], "user.a")
+	require.NoError(t, c.setxattr(info, "user.a", []byte("1"), xattrCreate))
+	require.Equal(t, "1", mockXAttrs[info.Inode]["user.a"])
+
+	// creating an existing xattr fails, replacing it succeeds
+	require.Equal(t, syscall.EEXIST, c.setxattr(info, "user.a", []byte("2"), xattrCreate))
+	require.Equal(t, "1", mockXAttrs[info.Inode]["user.a"])
+	require.NoError(t, c.setxattr(info, "user.a", []byte("2"), xattrReplace))
+	require.Equal(t, "2", mockXAttrs[info.Inode]["user.a"])
+
+	// without flags the xattr is overwritten or created
+	require.NoError(t, c.setxattr(info, "user.a", []byte("3"), 0))
+	require.NoError(t, c.setxattr(info, "trusted.b", []byte("4"), 0))
+	require.Equal(t, map[string]string{"user.a": "3", "trusted.b": "4"}, mockXAttrs[info.Inode])
+
+	// the names and the flags are checked
+	require.Equal(t, syscall.EINVAL, c.setxattr(info, "user.a", nil, xattrCreate|xattrReplace))
+	require.Equal(t, syscall.EOPNOTSUPP, c.setxattr(info, "a", nil, 0))
+	require.Equal(t, syscall.EOPNOTSUPP, c.setxattr(info, "user.", nil, 0))
+	require.Equal(t, syscall.ERANGE, c.setxattr(info, "user."+strings.Repeat("a", xattrNameMax), nil, 0))
+	require.Equal(t, syscall.E2BIG, c.setxattr(info, "user.a", make([]byte, xattrSizeMax+1), 0))
+	link := &proto.InodeInfo{Inode: 3001, Mode: uint32(os.ModeSymlink | 0777)}
+	require.Equal(t, syscall.EPERM, c.setxattr(link, "user.a", nil, 0))
+	require.NoError(t, c.setxattr(link, "trusted.a", nil, 0))
+}
+
+func TestAtEmptyPath(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"OpenStream":  MockOpenStream,
+		"FileSize":    MockFileSize,
+		"Flush":       MockExtentFlush,
+		"GetStreamer": MockGetStreamer,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+	for name, fn := range map[string]interface{}{
+		"InodeCreate_ll": MockInodeCreate,
+		"Link":           MockLink,
+		"InodeUnlink_ll": MockInodeUnlink,
+		"Evict":          MockEvict,
+	} {
+		require.NoError(t, gohook.HookMethod(c.mw, name, fn, nil))
+		defer gohook.UnHookMethod(c.mw, name)
+	}
+	// MockLookupPath resolves a path to the inode len(path)+1
+	dir := &proto.InodeInfo{Inode: uint64(len("/at")) + proto.RootIno, Mode: uint32(os.ModeDir | 0755)}
+	c.ic.Put(dir)
+	file := &proto.InodeInfo{Inode: uint64(len("/at/file")) + proto.RootIno, Mode: 0644}
+	c.ic.Put(file)
+
+	// fstatat of an empty path with AT_EMPTY_PATH stats the fd itself
+	f, err := c.openTmpFile(dir.Inode, uint32(os.O_RDWR), 0644)
+	require.NoError(t, err)
+	info, err := c.fstatat(int(f.fd), "", atEmptyPath)
+	require.NoError(t, err)
+	require.Equal(t, f.ino, info.Inode)
+	_, err = c.fstatat(int(f.fd), "", 0)
+	require.Equal(t, syscall.EINVAL, err)
+	_, err = c.fstatat(int(maxFdNum), "", atEmptyPath)
+	require.Equal(t, syscall.EBADF, err)
+
+	// a path is resolved as usual with the flag
+	info, err = c.fstatat(atFdcwd, "/at/file", atEmptyPath)
+	require.NoError(t, err)
+	require.Equal(t, file.Inode, info.Inode)
+
+	// linkat of an empty path with AT_EMPTY_PATH gives a name to the unnamed file
+	require.Equal(t, syscall.EINVAL, c.linkat(int(f.fd), "", atFdcwd, "/at/named", 0))
+	require.NoError(t, c.linkat(int(f.fd), "", atFdcwd, "/at/named", atEmptyPath))
+	mockTmpInodes.Lock()
+	require.Equal(t, f.ino, mockTmpInodes.names["named"])
+	require.Equal(t, 1, mockTmpInodes.nlink[f.ino])
+	mockTmpInodes.Unlock()
+	require.True(t, f.tmp.linked)
+	c.releaseFD(f.fd)
+	c.closeTmpFile(f)
+	mockTmpInodes.Lock()
+	require.False(t, mockTmpInodes.evicted[f.ino])
+	mockTmpInodes.Unlock()
+
+	// a path is linked as usual with the flag
+	require.NoError(t, c.linkat(atFdcwd, "/at/file", atFdcwd, "/at/other", atEmptyPath))
+	mockTmpInodes.Lock()
+	require.Equal(t, file.Inode, mockTmpInodes.names["other"])
+	mockTmpInodes.Unlock()
+	require.Equal(t, syscall.EPERM, c.linkat(atFdcwd, "/at", atFdcwd, "/at/dir", 0))
+}
+
+// mockFlushRanges records the ranges flushed through MockFlushRange.
+var mockFlushRanges [][3]int
+
+func MockFlushRange(ec *stream.ExtentClient, inode uint64, offset, size int, wait bool) error {
+	w := 0
+	if wait {
+		w = 1
+	}
+	mockFlushRanges = append(mockFlushRanges, [3]int{offset, size, w})
+	return nil
+}
+
+func TestSyncFileRange(t *testing.T) {
+	ec := &stream.ExtentClient{}
+	for name, fn := range map[string]interface{}{
+		"FlushRange": MockFlushRange,
+		"Flush":      MockCountedFlush,
+	} {
+		require.NoError(t, gohook.HookMethod(ec, name, fn, nil))
+		defer gohook.UnHookMethod(ec, name)
+	}
+	c := newMockClient(t)
+	c.ec = ec
+	c.volType = proto.VolumeTypeHot
+	f := &file{ino: 2950, flags: uint32(os.O_RDWR), regular: true, pos: &filePos{}}
+
+	// only the range is flushed, waiting for it with a WAIT flag
+	mockFlushRanges = nil
+	atomic.StoreInt32(&mockFlushCnt, 0)
+	require.NoError(t, c.syncFileRange(f, 4096, 8192, syncFileRangeWrite))
+	require.NoError(t, c.syncFileRange(f, 4096, 8192, syncFileRangeWrite|syncFileRangeWaitAfter))
+	require.NoError(t, c.syncFileRange(f, 1<<20, 0, syncFileRangeWaitBefore))
+	require.Equal(t, [][3]int{{4096, 8192, 0}, {4096, 8192, 1}, {1 << 20, math.MaxInt64 - 1<<20, 1}}, mockFlushRanges)
+	require.EqualValues(t, 0, atomic.LoadInt32(&mockFlushCnt))
+
+	// no flags is a no-op, the bad arguments are rejected
+	require.NoError(t, c.syncFileRange(f, 0, 4096, 0))
+	require.Equal(t, syscall.EINVAL, c.syncFileRange(f, -1, 4096, syncFileRangeWrite))
+	require.Equal(t, syscall.EINVAL, c.syncFileRange(f, 0, -1, syncFileRangeWrite))
+	require.Equal(t, syscall.EINVAL, c.syncFileRange(f, 0, 4096, 8))
+	require.Len(t, mockFlushRanges, 3)
+}
+
+func TestVolumeDev(t *testing.T) {
+	// the files of a volume share the dev, also across the clients of the volume
+	dev := volumeDev("cluster", "vol")
+	require.NotZero(t, dev)
+	require.Equal(t, dev, volumeDev("cluster", "vol"))
+
+	// the volumes of the same or another cluster differ
+	require.NotEqual(t, dev, volumeDev("cluster", "vol2"))
+	require.NotEqual(t, dev, volumeDev("cluster2", "vol"))
+	require.NotEqual(t, volumeDev("ab", "c"), volumeDev("a", "bc"))
+}
+
+func TestFaccessat(t *testing.T) {
+	c := newMockClient(t)
+	// MockLookupPath resolves a path to the inode len(path)+1
+	readOnly := &proto.InodeInfo{Inode: uint64(len("/acc/ro")) + proto.RootIno, Mode: 0444, Uid: 1000, Gid: 1000}
+	c.ic.Put(readOnly)
+	script := &proto.InodeInfo{Inode: uint64(len("/acc/script")) + proto.RootIno, Mode: 0750, Uid: 1000, Gid: 2000}
+	c.ic.Put(script)
+
+	realIDs := processIDs
+	defer func() { processIDs = realIDs }()
+	processIDs = func(effective bool) (uint32, []uint32) {
+		if effective {
+			return 3000, []uint32{2000}
+		}
+		return 1000, []uint32{1000}
+	}
+
+	// by default only the existence is checked
+	require.NoError(t, c.faccessat(atFdcwd, "/acc/ro", accessWrite, 0))
+	mockMissingPaths.Store("/acc/missing", true)
+	defer mockMissingPaths.Delete("/acc/missing")
+	require.Equal(t, syscall.ENOENT, c.faccessat(atFdcwd, "/acc/missing", 0, 0))
+
+	// a read-only file denies W_OK with the access checks enforced
+	c.enforceAccess = true
+	require.NoError(t, c.faccessat(atFdcwd, "/acc/ro", accessRead, 0))
+	require.Equal(t, syscall.EACCES, c.faccessat(atFdcwd, "/acc/ro", accessWrite, 0))
+	require.Equal(t, syscall.EACCES, c.faccessat(atFdcwd, "/acc/ro", accessRead|accessWrite, 0))
+	require.NoError(t, c.faccessat(atFdcwd, "/acc/ro", 0, 0))
+
+	// the real ids are the owner, the effective ones only in the group
+	require.NoError(t, c.faccessat(atFdcwd, "/acc/script", accessRead|accessWrite|accessExec, 0))
+	require.NoError(t, c.faccessat(atFdcwd, "/acc/script", accessRead|accessExec, atEaccess))
+	require.Equal(t, syscall.EACCES, c.faccessat(atFdcwd, "/acc/script", accessWrite, atEaccess))
+
+	require.Equal(t, syscall.EINVAL, c.faccessat(atFdcwd, "/acc/ro", 8, 0))
+	require.Equal(t, syscall.EINVAL, c.faccessat(atFdcwd, "/acc/ro", accessRead, atSymlinkFollow))
+
+	// root may read and write anything, and execute what anyone may execute
+	require.True(t, accessAllowed(readOnly, accessRead|accessWrite, 0, nil))
+	require.False(t, accessAllowed(readOnly, accessExec, 0, nil))
+	require.True(t, accessAllowed(script, accessExec, 0, nil))
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"math"
+	"sync"
+	"syscall"
+)
+
+// rangeLock is a read or write lock held by owner over [start, end) of a file.
+type rangeLock struct {
+	owner uint64
+	typ   int16 // F_RDLCK or F_WRLCK
+	start int64
+	end   int64 // math.MaxInt64 up to the end of the file, however it grows
+}
+
+func (l *rangeLock) overlaps(start, end int64) bool {
+	return l.start < end && start < l.end
+}
+
+// lockTable holds the advisory byte-range locks of the inodes. The locks are
+// only seen by the fds of the client, they are not shared with other clients.
+type lockTable struct {
+	sync.Mutex
+	cond  *sync.Cond // broadcast whenever a lock is released
+	locks map[uint64][]*rangeLock
+}
+
+func (t *lockTable) init() {
+	if t.locks == nil {
+		t.locks = make(map[uint64][]*rangeLock)
+		t.cond = sync.NewCond(&t.Mutex)
+	}
+}
+
+// conflict returns the first lock of another owner which excludes lk.
+func (t *lockTable) conflict(ino uint64, lk *rangeLock) *rangeLock {
+	for _, l := range t.locks[ino] {
+		if l.owner == lk.owner || !l.overlaps(lk.start, lk.end) {
+			continue
+		}
+		if l.typ == syscall.F_WRLCK || lk.typ == syscall.F_WRLCK {
+			return l
+		}
+	}
+	return nil
+}
+
+// getLock returns the lock which would prevent lk from being placed, false if
+// there is none.
+func (t *lockTable) getLock(ino uint64, lk rangeLock) (rangeLock, bool) {
+	t.Lock()
+	defer t.Unlock()
+	t.init()
+	if l := t.conflict(ino, &lk); l != nil {
+		return *l, true
+	}
+	return rangeLock{}, false
+}
+
+// setLock places lk, replacing the locks of the same owner over its range. It
+// returns EAGAIN on conflict unless wait is set, in which case it blocks until
+// the conflicting locks are released. F_UNLCK releases the range.
+func (t *lockTable) setLock(ino uint64, lk rangeLock, wait bool) error {
+	t.Lock()
+	defer t.Unlock()
+	t.init()
+	if lk.typ == syscall.F_UNLCK {
+		t.unlockRange(ino, lk.owner, lk.start, lk.end)
+		return nil
+	}
+	for t.conflict(ino, &lk) != nil {
+		if !wait {
+			return syscall.EAGAIN
+		}
+		t.cond.Wait()
+	}
+	// an owner downgrading its lock lets the waiting readers in
+	t.unlockRange(ino, lk.owner, lk.start, lk.end)
+	t.locks[ino] = append(t.locks[ino], &lk)
+	return nil
+}
+
+// unlockRange releases [start, end) of the locks of owner, splitting the locks
+// which cover only part of it.
+func (t *lockTable) unlockRange(ino uint64, owner uint64, start, end int64) {
+	locks := t.locks[ino]
+	kept := make([]*rangeLock, 0, len(locks))
+	released := false
+	for _, l := range locks {
+		if l.owner != owner || !l.overlaps(start, end) {
+			kept = append(kept, l)
+			continue
+		}
+		released = true
+		if l.start < start {
+			kept = append(kept, &rangeLock{owner: owner, typ: l.typ, start: l.start, end: start})
+		}
+		if end < l.end {
+			kept = append(kept, &rangeLock{owner: owner, typ: l.typ, start: end, end: l.end})
+		}
+	}
+	if len(kept) == 0 {
+		delete(t.locks, ino)
+	} else {
+		t.locks[ino] = kept
+	}
+	if released {
+		t.cond.Broadcast()
+	}
+}
+
+// releaseOwner releases all the locks of owner on the inode, called on close.
+func (t *lockTable) releaseOwner(ino uint64, owner uint64) {
+	t.Lock()
+	defer t.Unlock()
+	t.init()
+	t.unlockRange(ino, owner, 0, math.MaxInt64)
+}
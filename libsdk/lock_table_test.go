@@ -0,0 +1,150 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"io"
+	"math"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockTableConflicts(t *testing.T) {
+	var lt lockTable
+	const ino = 100
+	rd := func(owner uint64, start, end int64) rangeLock {
+		return rangeLock{owner: owner, typ: syscall.F_RDLCK, start: start, end: end}
+	}
+	wr := func(owner uint64, start, end int64) rangeLock {
+		return rangeLock{owner: owner, typ: syscall.F_WRLCK, start: start, end: end}
+	}
+
+	// readers share a range, a writer is excluded by any overlapping reader
+	require.NoError(t, lt.setLock(ino, rd(1, 0, 100), false))
+	require.NoError(t, lt.setLock(ino, rd(2, 50, 150), false))
+	require.Equal(t, syscall.EAGAIN, lt.setLock(ino, wr(3, 120, 200), false))
+	require.NoError(t, lt.setLock(ino, wr(3, 150, 200), false))
+	l, found := lt.getLock(ino, wr(3, 0, 10))
+	require.True(t, found)
+	require.Equal(t, rd(1, 0, 100), l)
+
+	// a writer excludes the readers and the writers of other owners
+	require.Equal(t, syscall.EAGAIN, lt.setLock(ino, rd(1, 199, 300), false))
+	require.Equal(t, syscall.EAGAIN, lt.setLock(ino, wr(2, 160, 170), false))
+	_, found = lt.getLock(ino, rd(1, 200, math.MaxInt64))
+	require.False(t, found)
+
+	// the owner replaces its own locks, a downgrade lets the readers in
+	require.NoError(t, lt.setLock(ino, wr(3, 150, 300), false))
+	_, found = lt.getLock(ino, rd(1, 250, 260))
+	require.True(t, found)
+	require.NoError(t, lt.setLock(ino, rd(3, 150, 300), false))
+	require.NoError(t, lt.setLock(ino, rd(1, 199, 300), false))
+	require.Len(t, lt.locks[ino], 4)
+}
+
+func TestLockTablePartialUnlock(t *testing.T) {
+	var lt lockTable
+	const ino = 101
+	require.NoError(t, lt.setLock(ino, rangeLock{owner: 1, typ: syscall.F_WRLCK, start: 0, end: 100}, false))
+
+	// unlocking the middle keeps both ends locked
+	require.NoError(t, lt.setLock(ino, rangeLock{owner: 1, typ: syscall.F_UNLCK, start: 40, end: 60}, false))
+	for _, tc := range []struct {
+		start, end int64
+		locked     bool
+	}{
+		{0, 40, true},
+		{39, 41, true},
+		{40, 60, false},
+		{59, 61, true},
+		{60, 100, true},
+		{100, 200, false},
+	} {
+		_, found := lt.getLock(ino, rangeLock{owner: 2, typ: syscall.F_RDLCK, start: tc.start, end: tc.end})
+		require.Equal(t, tc.locked, found, "%+v", tc)
+	}
+
+	// closing the fd releases what is left
+	lt.releaseOwner(ino, 1)
+	_, found := lt.getLock(ino, rangeLock{owner: 2, typ: syscall.F_WRLCK, start: 0, end: math.MaxInt64})
+	require.False(t, found)
+	require.Empty(t, lt.locks)
+}
+
+func TestLockTableWait(t *testing.T) {
+	var lt lockTable
+	const ino = 102
+	require.NoError(t, lt.setLock(ino, rangeLock{owner: 1, typ: syscall.F_WRLCK, start: 0, end: 10}, false))
+
+	locked := make(chan error)
+	go func() {
+		locked <- lt.setLock(ino, rangeLock{owner: 2, typ: syscall.F_WRLCK, start: 5, end: 20}, true)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("lock granted over a conflicting lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// releasing a range which does not conflict keeps the waiter blocked
+	require.NoError(t, lt.setLock(ino, rangeLock{owner: 1, typ: syscall.F_UNLCK, start: 0, end: 5}, false))
+	select {
+	case <-locked:
+		t.Fatal("lock granted over a conflicting lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, lt.setLock(ino, rangeLock{owner: 1, typ: syscall.F_UNLCK, start: 5, end: 10}, false))
+	select {
+	case err := <-locked:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken up")
+	}
+}
+
+func TestFcntlLock(t *testing.T) {
+	c := newMockClient(t)
+	f1 := c.allocFD(2910, uint32(os.O_RDWR), 0644, false, 0, proto.RootIno)
+	f2 := c.allocFD(2910, uint32(os.O_RDONLY), 0644, false, 0, proto.RootIno)
+	require.NotNil(t, f1)
+	require.NotNil(t, f2)
+
+	// a write lock of 100 bytes from the file offset
+	f1.pos.off = 4096
+	require.NoError(t, c.fcntlLock(f1, syscall.F_SETLK, &syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekCurrent, Len: 100}))
+
+	flock := &syscall.Flock_t{Type: syscall.F_RDLCK, Whence: io.SeekStart, Start: 4000, Len: 200}
+	require.NoError(t, c.fcntlLock(f2, syscall.F_GETLK, flock))
+	require.Equal(t, syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart, Start: 4096, Len: 100, Pid: -1}, *flock)
+	require.Equal(t, syscall.EAGAIN, c.fcntlLock(f2, syscall.F_SETLK, &syscall.Flock_t{Type: syscall.F_RDLCK, Start: 4100, Len: -10}))
+
+	// a read only fd can not take a write lock
+	require.Equal(t, syscall.EBADF, c.fcntlLock(f2, syscall.F_SETLK, &syscall.Flock_t{Type: syscall.F_WRLCK}))
+	require.Equal(t, syscall.EINVAL, c.fcntlLock(f2, syscall.F_SETLK, &syscall.Flock_t{Type: syscall.F_RDLCK, Start: -1}))
+
+	// the range is free once the owner unlocks it
+	require.NoError(t, c.fcntlLock(f1, syscall.F_SETLK, &syscall.Flock_t{Type: syscall.F_UNLCK}))
+	flock = &syscall.Flock_t{Type: syscall.F_RDLCK, Start: 4000, Len: 200}
+	require.NoError(t, c.fcntlLock(f2, syscall.F_GETLK, flock))
+	require.Equal(t, int16(syscall.F_UNLCK), flock.Type)
+	require.NoError(t, c.fcntlLock(f2, syscall.F_SETLKW, &syscall.Flock_t{Type: syscall.F_RDLCK, Start: 4000, Len: 200}))
+}
@@ -0,0 +1,288 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Watch masks, the same values as CFS_WATCH_* in libcfs.h.
+const (
+	watchModify   uint32 = 0x1 // the data of the file changed
+	watchDelete   uint32 = 0x2 // the file is removed, the watch is dropped
+	watchMove     uint32 = 0x4 // the path no longer names the file, e.g. renamed or a hard link removed
+	watchOverflow uint32 = 0x8 // events are lost, reported with wd -1
+
+	watchAll = watchModify | watchDelete | watchMove
+)
+
+const (
+	defaultWatchInterval = time.Second
+	maxWatchEvents       = 16384
+)
+
+// watchMeta is the part of the meta wrapper used by the watcher.
+type watchMeta interface {
+	GetPartitionByInodeId_ll(inodeId uint64) *meta.MetaPartition
+	GetChangeEvents_ll(pid uint64, fromSeq uint64, inodes, parents []uint64) (*proto.GetChangeEventsResponse, error)
+	InodeGet_ll(inode uint64) (*proto.InodeInfo, error)
+}
+
+type watch struct {
+	wd     int32
+	mask   uint32
+	ino    uint64
+	parent uint64 // the dentry parent/name of the watched path, 0 once the path is gone
+	name   string
+}
+
+type watchEvent struct {
+	wd   int32
+	mask uint32
+	ino  uint64
+}
+
+// watcher polls the change events of the meta partitions holding the watched
+// inodes and their dentries, and queues them for cfs_read_events.
+type watcher struct {
+	sync.Mutex
+	mw       watchMeta
+	interval time.Duration
+	nextWd   int32
+	watches  map[int32]*watch
+	seqs     map[uint64]uint64 // meta partition id -> sequence to poll from
+	events   []watchEvent
+	overflow bool
+	stopC    chan struct{}
+}
+
+func newWatcher(mw watchMeta, interval time.Duration) *watcher {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	return &watcher{
+		mw:       mw,
+		interval: interval,
+		watches:  make(map[int32]*watch),
+		seqs:     make(map[uint64]uint64),
+	}
+}
+
+// add watches the inode, and the dentry parent/name unless parent is 0. Only the
+// changes applied after add returns are reported.
+func (w *watcher) add(ino, parent uint64, name string, mask uint32) (wd int32, err error) {
+	if mask&watchAll == 0 {
+		return 0, syscall.EINVAL
+	}
+	pids := make([]uint64, 0, 2)
+	for _, id := range []uint64{ino, parent} {
+		if id == 0 {
+			continue
+		}
+		mp := w.mw.GetPartitionByInodeId_ll(id)
+		if mp == nil {
+			return 0, syscall.ENOENT
+		}
+		pids = append(pids, mp.PartitionID)
+	}
+	for _, pid := range pids {
+		w.Lock()
+		_, ok := w.seqs[pid]
+		w.Unlock()
+		if ok {
+			continue
+		}
+		resp, err := w.mw.GetChangeEvents_ll(pid, 0, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		w.Lock()
+		if _, ok = w.seqs[pid]; !ok {
+			w.seqs[pid] = resp.NextSeq
+		}
+		w.Unlock()
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	w.nextWd++
+	wd = w.nextWd
+	w.watches[wd] = &watch{wd: wd, mask: mask & watchAll, ino: ino, parent: parent, name: name}
+	if w.stopC == nil {
+		w.stopC = make(chan struct{})
+		go w.run(w.stopC)
+	}
+	return wd, nil
+}
+
+func (w *watcher) remove(wd int32) error {
+	w.Lock()
+	defer w.Unlock()
+	if _, ok := w.watches[wd]; !ok {
+		return syscall.EINVAL
+	}
+	delete(w.watches, wd)
+	return nil
+}
+
+// read dequeues at most max events.
+func (w *watcher) read(max int) (events []watchEvent) {
+	w.Lock()
+	defer w.Unlock()
+	if w.overflow && max > 0 {
+		events = append(events, watchEvent{wd: -1, mask: watchOverflow})
+		w.overflow = false
+		max--
+	}
+	if max > len(w.events) {
+		max = len(w.events)
+	}
+	events = append(events, w.events[:max]...)
+	w.events = w.events[max:]
+	return
+}
+
+func (w *watcher) stop() {
+	w.Lock()
+	defer w.Unlock()
+	if w.stopC != nil {
+		close(w.stopC)
+		w.stopC = nil
+	}
+}
+
+func (w *watcher) run(stopC chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopC:
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+type watchQuery struct {
+	inodes  []uint64
+	parents []uint64
+}
+
+// poll fetches the new events of every meta partition involved in the watches.
+func (w *watcher) poll() {
+	queries := make(map[uint64]*watchQuery)
+	query := func(ino uint64) *watchQuery {
+		mp := w.mw.GetPartitionByInodeId_ll(ino)
+		if mp == nil {
+			return nil
+		}
+		q, ok := queries[mp.PartitionID]
+		if !ok {
+			q = &watchQuery{}
+			queries[mp.PartitionID] = q
+		}
+		return q
+	}
+	w.Lock()
+	for _, wt := range w.watches {
+		if q := query(wt.ino); q != nil {
+			q.inodes = append(q.inodes, wt.ino)
+		}
+		if wt.parent == 0 {
+			continue
+		}
+		if q := query(wt.parent); q != nil {
+			q.parents = append(q.parents, wt.parent)
+		}
+	}
+	w.Unlock()
+
+	for pid, q := range queries {
+		w.Lock()
+		from := w.seqs[pid]
+		w.Unlock()
+		resp, err := w.mw.GetChangeEvents_ll(pid, from, q.inodes, q.parents)
+		if err != nil {
+			log.LogWarnf("watcher poll: pid(%v) from(%v) err(%v)", pid, from, err)
+			continue
+		}
+		if resp.Lost {
+			w.Lock()
+			w.overflow = true
+			w.Unlock()
+		}
+		for _, event := range resp.Events {
+			w.dispatch(event)
+		}
+		w.Lock()
+		if resp.NextSeq > w.seqs[pid] {
+			w.seqs[pid] = resp.NextSeq
+		}
+		w.Unlock()
+	}
+}
+
+func (w *watcher) dispatch(event proto.ChangeEvent) {
+	// the inode is still alive if a removed dentry was one of its links, or it is renamed
+	var alive bool
+	if event.Type == proto.ChangeEventDentryRemove {
+		info, err := w.mw.InodeGet_ll(event.Inode)
+		alive = err == nil && info.Nlink > 0
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	for wd, wt := range w.watches {
+		if wt.ino != event.Inode {
+			continue
+		}
+		switch event.Type {
+		case proto.ChangeEventModify:
+			w.queue(wt, watchModify)
+		case proto.ChangeEventDelete:
+			w.queue(wt, watchDelete)
+			delete(w.watches, wd)
+		case proto.ChangeEventDentryRemove:
+			if wt.parent != event.Parent || wt.name != event.Name {
+				continue
+			}
+			if !alive {
+				w.queue(wt, watchDelete)
+				delete(w.watches, wd)
+				continue
+			}
+			w.queue(wt, watchMove)
+			wt.parent, wt.name = 0, ""
+		}
+	}
+}
+
+func (w *watcher) queue(wt *watch, mask uint32) {
+	if wt.mask&mask == 0 {
+		return
+	}
+	if len(w.events) >= maxWatchEvents {
+		w.overflow = true
+		return
+	}
+	w.events = append(w.events, watchEvent{wd: wt.wd, mask: mask, ino: wt.ino})
+}
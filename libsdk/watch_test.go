@@ -0,0 +1,166 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/stretchr/testify/require"
+)
+
+// mockChangeMeta is a volume of a single meta partition which keeps the change
+// events like the metanode does, shared by the watching and the writing clients.
+type mockChangeMeta struct {
+	sync.Mutex
+	seq    uint64
+	events []proto.ChangeEvent
+	nlink  map[uint64]uint32
+}
+
+func newMockChangeMeta() *mockChangeMeta {
+	return &mockChangeMeta{seq: 100, nlink: make(map[uint64]uint32)}
+}
+
+func (m *mockChangeMeta) GetPartitionByInodeId_ll(inodeId uint64) *meta.MetaPartition {
+	return &meta.MetaPartition{PartitionID: 1}
+}
+
+func (m *mockChangeMeta) GetChangeEvents_ll(pid uint64, fromSeq uint64, inodes, parents []uint64) (*proto.GetChangeEventsResponse, error) {
+	m.Lock()
+	defer m.Unlock()
+	resp := &proto.GetChangeEventsResponse{NextSeq: m.seq}
+	if fromSeq == 0 {
+		return resp, nil
+	}
+	for _, event := range m.events {
+		if event.Seq <= fromSeq {
+			continue
+		}
+		for _, ino := range inodes {
+			if event.Inode == ino {
+				resp.Events = append(resp.Events, event)
+			}
+		}
+	}
+	return resp, nil
+}
+
+func (m *mockChangeMeta) InodeGet_ll(inode uint64) (*proto.InodeInfo, error) {
+	m.Lock()
+	defer m.Unlock()
+	nlink, ok := m.nlink[inode]
+	if !ok || nlink == 0 {
+		return nil, syscall.ENOENT
+	}
+	return &proto.InodeInfo{Inode: inode, Nlink: nlink}, nil
+}
+
+// apply records a change made by any client of the volume.
+func (m *mockChangeMeta) apply(events ...proto.ChangeEvent) {
+	m.Lock()
+	defer m.Unlock()
+	m.seq++
+	for _, event := range events {
+		event.Seq = m.seq
+		m.events = append(m.events, event)
+		if event.Type == proto.ChangeEventDelete {
+			m.nlink[event.Inode] = 0
+		}
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	mw := newMockChangeMeta()
+	mw.nlink[10], mw.nlink[11] = 1, 1
+	// changes made before the watch are not reported
+	mw.apply(proto.ChangeEvent{Type: proto.ChangeEventModify, Inode: 10})
+
+	w := newWatcher(mw, time.Hour)
+	defer w.stop()
+	wd1, err := w.add(10, proto.RootIno, "a", watchAll)
+	require.NoError(t, err)
+	wd2, err := w.add(11, proto.RootIno, "b", watchDelete)
+	require.NoError(t, err)
+	_, err = w.add(11, proto.RootIno, "b", 0)
+	require.Equal(t, syscall.EINVAL, err)
+
+	// another client writes both files and renames a
+	mw.apply(proto.ChangeEvent{Type: proto.ChangeEventModify, Inode: 10})
+	mw.apply(proto.ChangeEvent{Type: proto.ChangeEventModify, Inode: 11})
+	mw.apply(proto.ChangeEvent{Type: proto.ChangeEventDentryRemove, Inode: 10, Parent: proto.RootIno, Name: "a"})
+	w.poll()
+	require.Equal(t, []watchEvent{
+		{wd: wd1, mask: watchModify, ino: 10},
+		{wd: wd1, mask: watchMove, ino: 10},
+	}, w.read(10))
+	require.Empty(t, w.read(10))
+
+	// the renamed file is still watched, the removed one is dropped
+	mw.apply(proto.ChangeEvent{Type: proto.ChangeEventModify, Inode: 10})
+	mw.apply(proto.ChangeEvent{Type: proto.ChangeEventDentryRemove, Inode: 11, Parent: proto.RootIno, Name: "b"},
+		proto.ChangeEvent{Type: proto.ChangeEventDelete, Inode: 11})
+	w.poll()
+	require.Equal(t, []watchEvent{
+		{wd: wd1, mask: watchModify, ino: 10},
+		{wd: wd2, mask: watchDelete, ino: 11},
+	}, w.read(10))
+	require.Equal(t, syscall.EINVAL, w.remove(wd2))
+
+	// the events are read in order, a few at a time
+	for i := 0; i < 3; i++ {
+		mw.apply(proto.ChangeEvent{Type: proto.ChangeEventModify, Inode: 10})
+	}
+	w.poll()
+	require.Len(t, w.read(2), 2)
+	require.Len(t, w.read(2), 1)
+	require.NoError(t, w.remove(wd1))
+}
+
+func TestWatchOverflow(t *testing.T) {
+	mw := newMockChangeMeta()
+	w := newWatcher(mw, time.Hour)
+	defer w.stop()
+	wd, err := w.add(10, 0, "", watchModify)
+	require.NoError(t, err)
+
+	for i := 0; i < maxWatchEvents+1; i++ {
+		mw.apply(proto.ChangeEvent{Type: proto.ChangeEventModify, Inode: 10})
+	}
+	w.poll()
+	events := w.read(maxWatchEvents + 1)
+	require.Len(t, events, maxWatchEvents+1)
+	require.Equal(t, watchEvent{wd: -1, mask: watchOverflow}, events[0])
+	require.Equal(t, watchEvent{wd: wd, mask: watchModify, ino: 10}, events[1])
+}
+
+func TestWatchPolling(t *testing.T) {
+	mw := newMockChangeMeta()
+	w := newWatcher(mw, 10*time.Millisecond)
+	defer w.stop()
+	wd, err := w.add(10, 0, "", watchModify)
+	require.NoError(t, err)
+
+	// the change of another client is delivered by the background poller
+	mw.apply(proto.ChangeEvent{Type: proto.ChangeEventModify, Inode: 10})
+	require.Eventually(t, func() bool {
+		events := w.read(1)
+		return len(events) == 1 && events[0] == watchEvent{wd: wd, mask: watchModify, ino: 10}
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -320,6 +320,18 @@ func parseRequestToDeleteVol(r *http.Request) (name, authKey string, force bool,
 
 }
 
+// parseUintArg parses the value of key as an unsigned integer of bitSize bits,
+// the values which do not fit are rejected rather than truncated.
+func parseUintArg(key, str string, bitSize int) (val uint64, err error) {
+	if val, err = strconv.ParseUint(str, 10, bitSize); err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return 0, fmt.Errorf("args [%s] is out of range, val %s, max %d", key, str, uint64(1)<<bitSize-1)
+		}
+		return 0, fmt.Errorf("args [%s] is not legal, val %s", key, str)
+	}
+	return val, nil
+}
+
 func extractUintWithDefault(r *http.Request, key string, def int) (val int, err error) {
 
 	var str string
@@ -327,11 +339,12 @@ func extractUintWithDefault(r *http.Request, key string, def int) (val int, err
 		return def, nil
 	}
 
-	if val, err = strconv.Atoi(str); err != nil || val < 0 {
-		return 0, fmt.Errorf("parse [%s] is not valid int [%d], err %v", key, val, err)
+	var tmp uint64
+	if tmp, err = parseUintArg(key, str, strconv.IntSize-1); err != nil {
+		return 0, err
 	}
 
-	return val, nil
+	return int(tmp), nil
 }
 
 func extractUint64WithDefault(r *http.Request, key string, def uint64) (val uint64, err error) {
@@ -341,11 +354,7 @@ func extractUint64WithDefault(r *http.Request, key string, def uint64) (val uint
 		return def, nil
 	}
 
-	if val, err = strconv.ParseUint(str, 10, 64); err != nil || val < 0 {
-		return 0, fmt.Errorf("parse [%s] is not valid uint [%d], err %v", key, val, err)
-	}
-
-	return val, nil
+	return parseUintArg(key, str, 64)
 }
 
 func extractInt64WithDefault(r *http.Request, key string, def int64) (val int64, err error) {
@@ -355,11 +364,12 @@ func extractInt64WithDefault(r *http.Request, key string, def int64) (val int64,
 		return def, nil
 	}
 
-	if val, err = strconv.ParseInt(str, 10, 64); err != nil || val < 0 {
-		return 0, fmt.Errorf("parse [%s] is not valid int [%d], err %v", key, val, err)
+	var tmp uint64
+	if tmp, err = parseUintArg(key, str, 63); err != nil {
+		return 0, err
 	}
 
-	return val, nil
+	return int64(tmp), nil
 }
 
 func extractStrWithDefault(r *http.Request, key string, def string) (val string) {
@@ -397,6 +407,12 @@ type updateVolReq struct {
 	txConflictRetryNum      int64
 	txConflictRetryInterval int64
 	txOpLimit               int
+	minWriteQuorum          int
+	disableTinyExtent       bool
+	crcAlg                  uint8
+	quotaByAllocated        bool
+	maxFileSize             uint64
+	inodeDelayDeleteTime    int64
 	zoneName                string
 	description             string
 	dpSelectorName          string
@@ -525,6 +541,30 @@ func parseVolUpdateReq(r *http.Request, vol *Vol, req *updateVolReq) (err error)
 		return
 	}
 
+	if req.minWriteQuorum, err = extractUintWithDefault(r, minWriteQuorumKey, vol.minWriteQuorum); err != nil {
+		return
+	}
+
+	if req.disableTinyExtent, err = extractBoolWithDefault(r, disableTinyExtentKey, vol.disableTinyExtent); err != nil {
+		return
+	}
+
+	if req.crcAlg, err = extractCrcAlg(r, vol.crcAlg); err != nil {
+		return
+	}
+
+	if req.quotaByAllocated, err = extractBoolWithDefault(r, quotaByAllocatedKey, vol.quotaByAllocated); err != nil {
+		return
+	}
+
+	if req.maxFileSize, err = extractMaxFileSize(r, vol.maxFileSize); err != nil {
+		return
+	}
+
+	if req.inodeDelayDeleteTime, err = extractInodeDelayDeleteTime(r, vol.inodeDelayDeleteTime); err != nil {
+		return
+	}
+
 	if req.authenticate, err = extractBoolWithDefault(r, authenticateKey, vol.authenticate); err != nil {
 		return
 	}
@@ -624,6 +664,57 @@ func parseRequestToSetVolCapacity(r *http.Request) (name, authKey string, capaci
 	return
 }
 
+func parseRequestToCloneVolConfig(r *http.Request) (name, newName, owner string, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+
+	if name, err = extractName(r); err != nil {
+		return
+	}
+
+	if newName = r.FormValue(newNameKey); newName == "" {
+		err = keyNotFound(newNameKey)
+		return
+	}
+	if !volNameRegexp.MatchString(newName) {
+		err = errors.New("newName can only be number and letters")
+		return
+	}
+
+	// the clone is owned by the owner of the source unless given
+	if owner = r.FormValue(volOwnerKey); owner != "" && !ownerRegexp.MatchString(owner) {
+		err = errors.New("owner can only be number and letters")
+	}
+
+	return
+}
+
+func parseRequestToRenameVol(r *http.Request) (name, newName, authKey string, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+
+	if name, err = extractName(r); err != nil {
+		return
+	}
+
+	if newName = r.FormValue(newNameKey); newName == "" {
+		err = keyNotFound(newNameKey)
+		return
+	}
+	if !volNameRegexp.MatchString(newName) {
+		err = errors.New("newName can only be number and letters")
+		return
+	}
+
+	if authKey, err = extractAuthKey(r); err != nil {
+		return
+	}
+
+	return
+}
+
 type qosArgs struct {
 	qosEnable     bool
 	diskQosEnable bool
@@ -637,6 +728,31 @@ func (qos *qosArgs) isArgsWork() bool {
 	return (qos.iopsRVal | qos.iopsWVal | qos.flowRVal | qos.flowWVal) > 0
 }
 
+// parseRequestToGetQosLimit parses a request to get the QoS limit in effect.
+// The limit of a vol is asked by its name, narrowed to the clients of a host
+// by the client IP, and the cluster-wide limit of the data nodes by the zone
+// when no vol is given.
+func parseRequestToGetQosLimit(r *http.Request) (volName, host, zoneName string, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	volName = r.FormValue(nameKey)
+	if host = r.FormValue(addrKey); host != "" {
+		if volName == "" {
+			err = keyNotFound(nameKey)
+			return
+		}
+		if !checkIp(host) {
+			err = fmt.Errorf("args [%s] is not legal, val %s", addrKey, host)
+			return
+		}
+	}
+	if zoneName = r.FormValue(zoneNameKey); zoneName == "" {
+		zoneName = DefaultZoneName
+	}
+	return
+}
+
 type coldVolArgs struct {
 	objBlockSize     int
 	cacheCap         uint64
@@ -672,6 +788,12 @@ type createVolReq struct {
 	txTimeout                            int64
 	txConflictRetryNum                   int64
 	txConflictRetryInterval              int64
+	minWriteQuorum                       int
+	disableTinyExtent                    bool
+	crcAlg                               uint8
+	quotaByAllocated                     bool
+	maxFileSize                          uint64
+	inodeDelayDeleteTime                 int64
 	qosLimitArgs                         *qosArgs
 	clientReqPeriod, clientHitTriggerCnt uint32
 	// cold vol args
@@ -725,9 +847,57 @@ func parseColdArgs(r *http.Request) (args coldVolArgs, err error) {
 	return
 }
 
+// parseJSONForm fills the form of r with the fields of its JSON body, so the
+// request is parsed and validated the same way as a form request. The values
+// in the body take precedence over the ones in the url query.
+func parseJSONForm(r *http.Request) (err error) {
+	var body []byte
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	if body, err = ioutil.ReadAll(r.Body); err != nil {
+		return
+	}
+	fields := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewBuffer(body))
+	decoder.UseNumber()
+	if err = decoder.Decode(&fields); err != nil {
+		return fmt.Errorf("invalid json body: %v", err)
+	}
+	for key, value := range fields {
+		switch v := value.(type) {
+		case string:
+			r.Form.Set(key, v)
+		case json.Number:
+			r.Form.Set(key, v.String())
+		case bool:
+			r.Form.Set(key, strconv.FormatBool(v))
+		case nil:
+		default:
+			return fmt.Errorf("invalid value of %v: %v", key, value)
+		}
+	}
+	return
+}
+
+func isJSONRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.TrimSpace(strings.ToLower(contentType)) == "application/json"
+}
+
+// parseRequestToCreateVol parses a form request, or a JSON body with the same
+// keys if the request is sent as application/json.
 func parseRequestToCreateVol(r *http.Request, req *createVolReq) (err error) {
 
-	if err = r.ParseForm(); err != nil {
+	if isJSONRequest(r) {
+		err = parseJSONForm(r)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
 		return
 	}
 
@@ -842,9 +1012,81 @@ func parseRequestToCreateVol(r *http.Request, req *createVolReq) (err error) {
 		return
 	}
 
+	if req.minWriteQuorum, err = extractUintWithDefault(r, minWriteQuorumKey, 0); err != nil {
+		return
+	}
+
+	if req.disableTinyExtent, err = extractBoolWithDefault(r, disableTinyExtentKey, false); err != nil {
+		return
+	}
+
+	if req.crcAlg, err = extractCrcAlg(r, proto.CrcAlgIEEE); err != nil {
+		return
+	}
+
+	if req.quotaByAllocated, err = extractBoolWithDefault(r, quotaByAllocatedKey, false); err != nil {
+		return
+	}
+
+	if req.maxFileSize, err = extractMaxFileSize(r, 0); err != nil {
+		return
+	}
+
+	if req.inodeDelayDeleteTime, err = extractInodeDelayDeleteTime(r, 0); err != nil {
+		return
+	}
+
+	return
+}
+
+// extractMaxFileSize parses the max file size of a vol in bytes, 0 means unlimited.
+func extractMaxFileSize(r *http.Request, def uint64) (maxFileSize uint64, err error) {
+	if maxFileSize, err = extractUint64WithDefault(r, maxFileSizeKey, def); err != nil {
+		return
+	}
+	if maxFileSize != 0 && maxFileSize < util.BlockSize {
+		return 0, fmt.Errorf("maxFileSize(%v) should be 0 (unlimited) or at least %v", maxFileSize, util.BlockSize)
+	}
+	return
+}
+
+// extractCrcAlg parses the checksum algorithm of the data written to a vol,
+// proto.CrcAlgIEEE or proto.CrcAlgCastagnoli.
+func extractCrcAlg(r *http.Request, def uint8) (alg uint8, err error) {
+	var val int
+	if val, err = extractUintWithDefault(r, crcAlgKey, int(def)); err != nil {
+		return
+	}
+	if val > math.MaxUint8 || !proto.IsValidCrcAlg(uint8(val)) {
+		return 0, fmt.Errorf("crcAlg(%v) should be %v (crc32 IEEE) or %v (crc32c)", val, proto.CrcAlgIEEE, proto.CrcAlgCastagnoli)
+	}
+	return uint8(val), nil
+}
+
+// extractInodeDelayDeleteTime parses the seconds an unlinked inode waits in the free list
+// of the meta partitions before being deleted, 0 means the metanode default.
+func extractInodeDelayDeleteTime(r *http.Request, def int64) (delay int64, err error) {
+	if delay, err = extractInt64WithDefault(r, inodeDelayDeleteTimeKey, def); err != nil {
+		return
+	}
+	if delay < 0 {
+		return 0, fmt.Errorf("inodeDelayDeleteTime(%v) should not be negative", delay)
+	}
 	return
 }
 
+// checkMinWriteQuorum checks the write quorum against the replica number of the vol,
+// 0 means the quorum is not set and all replicas must ack a write.
+func checkMinWriteQuorum(quorum, replicaNum int) error {
+	if quorum == 0 {
+		return nil
+	}
+	if quorum < 1 || quorum > replicaNum {
+		return fmt.Errorf("minWriteQuorum(%v) should be between 1 and replicaNum(%v)", quorum, replicaNum)
+	}
+	return nil
+}
+
 func parseRequestToCreateDataPartition(r *http.Request) (count int, name string, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -885,6 +1127,42 @@ func parseRequestToGetDataPartition(r *http.Request) (ID uint64, volName string,
 	return
 }
 
+// parseRequestToListPartitions parses a request to list a page of the data or
+// meta partitions of a vol. The limit defaults to, and is capped at,
+// maxListPartitionsLimit. A zero status lists the partitions of any status.
+func parseRequestToListPartitions(r *http.Request) (volName string, offset, limit int, status int8, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	if volName, err = extractName(r); err != nil {
+		return
+	}
+	if offset, err = extractUintWithDefault(r, offsetKey, 0); err != nil {
+		return
+	}
+	if limit, err = extractUintWithDefault(r, Limit, maxListPartitionsLimit); err != nil {
+		return
+	}
+	if limit == 0 || limit > maxListPartitionsLimit {
+		limit = maxListPartitionsLimit
+	}
+	if str := r.FormValue(statusKey); str != "" {
+		var val int64
+		if val, err = strconv.ParseInt(str, 10, 8); err != nil {
+			err = unmatchedKey(statusKey)
+			return
+		}
+		switch val {
+		case proto.ReadOnly, proto.ReadWrite, proto.Unavailable:
+			status = int8(val)
+		default:
+			err = fmt.Errorf("args [%s] is not legal, val %s", statusKey, str)
+			return
+		}
+	}
+	return
+}
+
 func parseRequestToBalanceMetaPartition(r *http.Request) (zones string, nodeSetIds string, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -895,6 +1173,23 @@ func parseRequestToBalanceMetaPartition(r *http.Request) (zones string, nodeSetI
 	return
 }
 
+func parseRequestToBalanceMetaPartitionInode(r *http.Request) (zones string, nodeSetIds string, maxMoves int, dryRun bool, err error) {
+	if zones, nodeSetIds, err = parseRequestToBalanceMetaPartition(r); err != nil {
+		return
+	}
+	if maxMoves, err = extractUintWithDefault(r, countKey, defaultMigrateMpCnt); err != nil {
+		return
+	}
+	if maxMoves == 0 {
+		err = fmt.Errorf("parameter %v must be greater than 0", countKey)
+		return
+	}
+	if dryRun, err = extractBoolWithDefault(r, dryRunKey, false); err != nil {
+		return
+	}
+	return
+}
+
 func parseRequestToLoadDataPartition(r *http.Request) (ID uint64, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -960,6 +1255,23 @@ func parseRequestToDecommissionDataPartition(r *http.Request) (ID uint64, nodeAd
 	return extractDataPartitionIDAndAddr(r)
 }
 
+// parseRequestToQueryDecommissionProgress parses a request to query the
+// decommission progress of a data node, of one of its disks when the disk path
+// is given, or of a single partition when the partition ID is given.
+func parseRequestToQueryDecommissionProgress(r *http.Request) (nodeAddr, diskPath string, partitionID uint64, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	if nodeAddr, err = extractNodeAddr(r); err != nil {
+		return
+	}
+	diskPath = r.FormValue(diskPathKey)
+	if partitionID, err = extractUint64(r, idKey); err != nil {
+		return
+	}
+	return
+}
+
 func extractNodeAddr(r *http.Request) (nodeAddr string, err error) {
 	if nodeAddr = r.FormValue(addrKey); nodeAddr == "" {
 		err = keyNotFound(addrKey)
@@ -1397,17 +1709,17 @@ func extractName(r *http.Request) (name string, err error) {
 
 func extractUint(r *http.Request, key string) (val int, err error) {
 	var str string
-	var valParsed int64
 	if str = r.FormValue(key); str == "" {
 		return 0, nil
 	}
 
-	if valParsed, err = strconv.ParseInt(str, 10, 32); err != nil || valParsed < 0 {
-		return 0, fmt.Errorf("args [%s] is not legal, val %s", key, str)
+	// an int32, so that it fits an int on any platform
+	var tmp uint64
+	if tmp, err = parseUintArg(key, str, 31); err != nil {
+		return 0, err
 	}
 
-	val = int(valParsed)
-	return val, nil
+	return int(tmp), nil
 }
 
 func extractPositiveUint(r *http.Request, key string) (val int, err error) {
@@ -1429,11 +1741,7 @@ func extractUint64(r *http.Request, key string) (val uint64, err error) {
 		return 0, nil
 	}
 
-	if val, err = strconv.ParseUint(str, 10, 64); err != nil || val < 0 {
-		return 0, fmt.Errorf("args [%s] is not legal, val %s", key, str)
-	}
-
-	return val, nil
+	return parseUintArg(key, str, 64)
 }
 
 func extractUint32(r *http.Request, key string) (val uint32, err error) {
@@ -1443,8 +1751,8 @@ func extractUint32(r *http.Request, key string) (val uint32, err error) {
 	}
 
 	var tmp uint64
-	if tmp, err = strconv.ParseUint(str, 10, 32); err != nil || val < 0 {
-		return 0, fmt.Errorf("args [%s] is not legal, val %s", key, str)
+	if tmp, err = parseUintArg(key, str, 32); err != nil {
+		return 0, err
 	}
 
 	return uint32(tmp), nil
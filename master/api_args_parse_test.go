@@ -0,0 +1,194 @@
+package master
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newArgRequest(t *testing.T, key, val string) *http.Request {
+	form := url.Values{}
+	if val != "" {
+		form.Set(key, val)
+	}
+	r, err := http.NewRequest(http.MethodGet, "/?"+form.Encode(), nil)
+	assert.NoError(t, err)
+	return r
+}
+
+func TestExtractUintOverflow(t *testing.T) {
+	const key = "testKey"
+	for _, tc := range []struct {
+		val      string
+		expected uint64
+		ok       bool
+	}{
+		{"", 0, true},
+		{"0", 0, true},
+		{"2147483647", 2147483647, true},
+		{"2147483648", 0, false},
+		{"-1", 0, false},
+		{"abc", 0, false},
+	} {
+		val, err := extractUint(newArgRequest(t, key, tc.val), key)
+		assert.Equal(t, tc.ok, err == nil, tc.val)
+		assert.Equal(t, int(tc.expected), val, tc.val)
+	}
+
+	for _, tc := range []struct {
+		val      string
+		expected uint32
+		ok       bool
+	}{
+		{"", 0, true},
+		{"4294967295", 4294967295, true},
+		{"4294967296", 0, false},
+		{"5000000000", 0, false},
+		{"-1", 0, false},
+	} {
+		val, err := extractUint32(newArgRequest(t, key, tc.val), key)
+		assert.Equal(t, tc.ok, err == nil, tc.val)
+		assert.Equal(t, tc.expected, val, tc.val)
+	}
+
+	for _, tc := range []struct {
+		val      string
+		expected uint64
+		ok       bool
+	}{
+		{"", 0, true},
+		{"18446744073709551615", 18446744073709551615, true},
+		{"18446744073709551616", 0, false},
+		{"-1", 0, false},
+	} {
+		val, err := extractUint64(newArgRequest(t, key, tc.val), key)
+		assert.Equal(t, tc.ok, err == nil, tc.val)
+		assert.Equal(t, tc.expected, val, tc.val)
+	}
+}
+
+func TestExtractWithDefaultOverflow(t *testing.T) {
+	const key = "testKey"
+	for _, tc := range []struct {
+		val      string
+		expected int64
+		ok       bool
+	}{
+		{"", 7, true},
+		{"9223372036854775807", 9223372036854775807, true},
+		{"9223372036854775808", 0, false},
+		{"-1", 0, false},
+	} {
+		val, err := extractInt64WithDefault(newArgRequest(t, key, tc.val), key, 7)
+		assert.Equal(t, tc.ok, err == nil, tc.val)
+		assert.Equal(t, tc.expected, val, tc.val)
+
+		// an int is 64 bits on the platforms the tests run on
+		intVal, err := extractUintWithDefault(newArgRequest(t, key, tc.val), key, 7)
+		assert.Equal(t, tc.ok, err == nil, tc.val)
+		assert.Equal(t, int(tc.expected), intVal, tc.val)
+	}
+
+	for _, tc := range []struct {
+		val      string
+		expected uint64
+		ok       bool
+	}{
+		{"", 7, true},
+		{"18446744073709551615", 18446744073709551615, true},
+		{"18446744073709551616", 0, false},
+		{"1.5", 0, false},
+	} {
+		val, err := extractUint64WithDefault(newArgRequest(t, key, tc.val), key, 7)
+		assert.Equal(t, tc.ok, err == nil, tc.val)
+		assert.Equal(t, tc.expected, val, tc.val)
+	}
+
+	// the error names the key and the value
+	_, err := extractUint32(newArgRequest(t, key, "5000000000"), key)
+	assert.EqualError(t, err, "args [testKey] is out of range, val 5000000000, max 4294967295")
+}
+
+func TestParseListPartitionsLimit(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		limit    int
+		offset   int
+		status   int8
+		hasError bool
+	}{
+		{"name=vol", maxListPartitionsLimit, 0, 0, false},
+		{"name=vol&limit=10&offset=20", 10, 20, 0, false},
+		{"name=vol&limit=100000", maxListPartitionsLimit, 0, 0, false},
+		{"name=vol&status=-1", maxListPartitionsLimit, 0, -1, false},
+		{"name=vol&status=3", 0, 0, 0, true},
+		{"name=vol&offset=-1", 0, 0, 0, true},
+		{"limit=10", 0, 0, 0, true},
+	} {
+		r, err := http.NewRequest(http.MethodGet, "/?"+tc.query, nil)
+		assert.NoError(t, err)
+		_, offset, limit, status, err := parseRequestToListPartitions(r)
+		if tc.hasError {
+			assert.Error(t, err, tc.query)
+			continue
+		}
+		assert.NoError(t, err, tc.query)
+		assert.Equal(t, tc.limit, limit, tc.query)
+		assert.Equal(t, tc.offset, offset, tc.query)
+		assert.Equal(t, tc.status, status, tc.query)
+	}
+}
+
+func TestParseQueryDecommissionProgress(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/?addr=127.0.0.1:17310&disk=/cfs/disk&id=12", nil)
+	assert.NoError(t, err)
+	addr, disk, id, err := parseRequestToQueryDecommissionProgress(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:17310", addr)
+	assert.Equal(t, "/cfs/disk", disk)
+	assert.Equal(t, uint64(12), id)
+
+	// the disk and the partition are optional, the node is not
+	r, err = http.NewRequest(http.MethodGet, "/?addr=127.0.0.1:17310", nil)
+	assert.NoError(t, err)
+	_, disk, id, err = parseRequestToQueryDecommissionProgress(r)
+	assert.NoError(t, err)
+	assert.Empty(t, disk)
+	assert.Zero(t, id)
+
+	r, err = http.NewRequest(http.MethodGet, "/?disk=/cfs/disk", nil)
+	assert.NoError(t, err)
+	_, _, _, err = parseRequestToQueryDecommissionProgress(r)
+	assert.Error(t, err)
+}
+
+func TestParseGetQosLimit(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		volName  string
+		host     string
+		zoneName string
+		hasError bool
+	}{
+		{"", "", "", DefaultZoneName, false},
+		{"zoneName=zone1", "", "", "zone1", false},
+		{"name=vol", "vol", "", DefaultZoneName, false},
+		{"name=vol&addr=192.168.0.1", "vol", "192.168.0.1", DefaultZoneName, false},
+		{"name=vol&addr=host", "", "", "", true},
+		{"addr=192.168.0.1", "", "", "", true},
+	} {
+		r, err := http.NewRequest(http.MethodGet, "/?"+tc.query, nil)
+		assert.NoError(t, err)
+		volName, host, zoneName, err := parseRequestToGetQosLimit(r)
+		if tc.hasError {
+			assert.Error(t, err, tc.query)
+			continue
+		}
+		assert.NoError(t, err, tc.query)
+		assert.Equal(t, tc.volName, volName, tc.query)
+		assert.Equal(t, tc.host, host, tc.query)
+		assert.Equal(t, tc.zoneName, zoneName, tc.query)
+	}
+}
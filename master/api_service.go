@@ -22,6 +22,7 @@ import (
 	"math"
 	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -98,6 +99,7 @@ func newNodeSetView(dataNodeLen, metaNodeLen int) *NodeSetView {
 type ZoneView struct {
 	Name                string
 	Status              string
+	Drained             bool
 	DataNodesetSelector string
 	MetaNodesetSelector string
 	NodeSet             map[uint64]*NodeSetView
@@ -240,6 +242,7 @@ func (m *Server) getTopology(w http.ResponseWriter, r *http.Request) {
 	for _, zone := range zones {
 		cv := newZoneView(zone.name)
 		cv.Status = zone.getStatusToString()
+		cv.Drained = zone.isDrained()
 		cv.DataNodesetSelector = zone.GetDataNodesetSelector()
 		cv.MetaNodesetSelector = zone.GetMetaNodesetSelector()
 		tv.Zones = append(tv.Zones, cv)
@@ -311,6 +314,41 @@ func (m *Server) updateZone(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("update zone status to [%v] successfully", status)))
 }
 
+// drainZone stops or resumes allocating new partitions in a zone, the
+// partitions already in the zone can still be read and written.
+func (m *Server) drainZone(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		drained bool
+		err     error
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.DrainZone))
+	defer func() {
+		doStatAndMetric(proto.DrainZone, metric, err, nil)
+	}()
+
+	if name = r.FormValue(nameKey); name == "" {
+		err = keyNotFound(nameKey)
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if drained, err = extractBoolWithDefault(r, drainKey, true); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	zone, err := m.cluster.t.getZone(name)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeZoneNotExists, Msg: err.Error()})
+		return
+	}
+	if err = zone.updateDrain(m.cluster, drained); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	log.LogInfof("action[drainZone] zone[%v] drained[%v]", name, drained)
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set zone [%v] drained to [%v] successfully", name, drained)))
+}
+
 func (m *Server) listZone(w http.ResponseWriter, r *http.Request) {
 	metric := exporter.NewTPCnt(apiToMetricsName(proto.GetAllZones))
 	defer func() {
@@ -1252,6 +1290,77 @@ func (m *Server) QosGetZoneLimit(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(zoneSt))
 }
 
+// QosGetLimit replies the QoS limit in effect for a vol, for the clients of a
+// host on the vol, or for the data nodes of a zone. The flow limits take
+// bytes as unit.
+func (m *Server) QosGetLimit(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName  string
+		host     string
+		zoneName string
+		vol      *Vol
+		limit    *qosArgs
+		err      error
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.QosGetLimit))
+	defer func() {
+		doStatAndMetric(proto.QosGetLimit, metric, err, map[string]string{exporter.Vol: volName})
+	}()
+
+	if volName, host, zoneName, err = parseRequestToGetQosLimit(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	type qosLimitStatus struct {
+		VolName         string `json:",omitempty"`
+		Host            string `json:",omitempty"`
+		Zone            string `json:",omitempty"`
+		QosEnable       bool
+		DiskLimitEnable bool
+		LimitWork       bool
+		IopsRVal        uint64
+		IopsWVal        uint64
+		FlowRVal        uint64
+		FlowWVal        uint64
+	}
+
+	status := &qosLimitStatus{DiskLimitEnable: m.cluster.diskQosEnable}
+	if volName != "" {
+		if vol, err = m.cluster.getVol(volName); err != nil {
+			sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+			return
+		}
+		if limit, err = vol.getQosLimit(host); err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+		status.VolName, status.Host = volName, host
+	} else {
+		value, ok := m.cluster.t.zoneMap.Load(zoneName)
+		if !ok {
+			err = fmt.Errorf("zonename [%v] not found", zoneName)
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+		zone := value.(*Zone)
+		limit = &qosArgs{
+			qosEnable: m.cluster.diskQosEnable,
+			iopsRVal:  zone.QosIopsRLimit,
+			iopsWVal:  zone.QosIopsWLimit,
+			flowRVal:  zone.QosFlowRLimit,
+			flowWVal:  zone.QosFlowWLimit,
+		}
+		status.Zone = zoneName
+	}
+	status.QosEnable = limit.qosEnable
+	// the limits only throttle when the qos is enabled
+	status.LimitWork = limit.qosEnable && limit.isArgsWork()
+	status.IopsRVal, status.IopsWVal = limit.iopsRVal, limit.iopsWVal
+	status.FlowRVal, status.FlowWVal = limit.flowRVal, limit.flowWVal
+	sendOkReply(w, r, newSuccessHTTPReply(status))
+}
+
 func (m *Server) QosUpdate(w http.ResponseWriter, r *http.Request) {
 	var (
 		volName   string
@@ -1668,7 +1777,61 @@ func (m *Server) balanceMetaPartitionLeader(w http.ResponseWriter, r *http.Reque
 	}
 	log.LogInfof("zone:%v,nodesetId:%v", zonesKey, nodesetIdKey)
 
-	zonesM := make(map[string]struct{})
+	zonesM, nodesetIdM, err := parseBalanceMetaPartitionScope(zonesKey, nodesetIdKey)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		log.LogErrorf("balanceMetaPartitionLeader.err %v", err)
+		return
+	}
+	log.LogInfof("balanceMetaPartitionLeader zones[%v] length[%d], nodesetIds[%v] length[%d]", zonesKey, len(zonesM), nodesetIdKey, len(nodesetIdM))
+	err = m.cluster.balanceMetaPartitionLeader(zonesM, nodesetIdM)
+	if err != nil {
+		log.LogErrorf("balanceMetaPartitionLeader.err %v", err)
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	rstMsg := fmt.Sprintf("balanceMetaPartitionLeader command sucess")
+	_ = sendOkReply(w, r, newSuccessHTTPReply(rstMsg))
+}
+
+// balance the meta partition replicas by inode count in metaNodes which can select all cluster some zones or noteSet
+func (m *Server) balanceMetaPartitionInode(w http.ResponseWriter, r *http.Request) {
+	var (
+		zonesKey     string
+		nodesetIdKey string
+		maxMoves     int
+		dryRun       bool
+		moves        []*MetaPartitionMove
+		err          error
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.AdminBalanceMetaPartitionInode))
+	defer func() {
+		doStatAndMetric(proto.AdminBalanceMetaPartitionInode, metric, err, nil)
+	}()
+	if zonesKey, nodesetIdKey, maxMoves, dryRun, err = parseRequestToBalanceMetaPartitionInode(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		log.LogErrorf("balanceMetaPartitionInode.err %v", err)
+		return
+	}
+
+	zonesM, nodesetIdM, err := parseBalanceMetaPartitionScope(zonesKey, nodesetIdKey)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		log.LogErrorf("balanceMetaPartitionInode.err %v", err)
+		return
+	}
+	log.LogInfof("balanceMetaPartitionInode zones[%v] nodesetIds[%v] maxMoves[%v] dryRun[%v]",
+		zonesKey, nodesetIdKey, maxMoves, dryRun)
+	if moves, err = m.cluster.balanceMetaPartitionInode(zonesM, nodesetIdM, maxMoves, dryRun); err != nil {
+		log.LogErrorf("balanceMetaPartitionInode.err %v", err)
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	_ = sendOkReply(w, r, newSuccessHTTPReply(moves))
+}
+
+func parseBalanceMetaPartitionScope(zonesKey, nodesetIdKey string) (zonesM map[string]struct{}, nodesetIdM map[uint64]struct{}, err error) {
+	zonesM = make(map[string]struct{})
 	if zonesKey != "" {
 		zones := strings.Split(zonesKey, commaSplit)
 		for _, zone := range zones {
@@ -1676,28 +1839,18 @@ func (m *Server) balanceMetaPartitionLeader(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	nodesetIdM := make(map[uint64]struct{})
+	nodesetIdM = make(map[uint64]struct{})
 	if nodesetIdKey != "" {
 		nodesetIds := strings.Split(nodesetIdKey, commaSplit)
 		for _, nodeSetId := range nodesetIds {
-			id, err := strconv.ParseUint(nodeSetId, 10, 64)
-			if err != nil {
-				sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
-				log.LogErrorf("balanceMetaPartitionLeader.err %v", err)
+			var id uint64
+			if id, err = strconv.ParseUint(nodeSetId, 10, 64); err != nil {
 				return
 			}
 			nodesetIdM[id] = struct{}{}
 		}
 	}
-	log.LogInfof("balanceMetaPartitionLeader zones[%v] length[%d], nodesetIds[%v] length[%d]", zonesKey, len(zonesM), nodesetIdKey, len(nodesetIdM))
-	err = m.cluster.balanceMetaPartitionLeader(zonesM, nodesetIdM)
-	if err != nil {
-		log.LogErrorf("balanceMetaPartitionLeader.err %v", err)
-		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
-		return
-	}
-	rstMsg := fmt.Sprintf("balanceMetaPartitionLeader command sucess")
-	_ = sendOkReply(w, r, newSuccessHTTPReply(rstMsg))
+	return
 }
 
 // Decommission a data partition. This usually happens when disk error has been reported.
@@ -1987,6 +2140,13 @@ func (m *Server) updateVol(w http.ResponseWriter, r *http.Request) {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
+
+	if req.zoneName != "" && req.zoneName != vol.zoneName {
+		if err = m.cluster.checkNormalZoneName(req.zoneName); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+	}
 	if req.followerRead, req.authenticate, err = parseBoolFieldToUpdateVol(r, vol); err != nil {
 		return
 	}
@@ -1996,6 +2156,16 @@ func (m *Server) updateVol(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.minWriteQuorum != 0 && !proto.IsHot(vol.VolType) {
+		err = fmt.Errorf("cold vol doesn't support minWriteQuorum")
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = checkMinWriteQuorum(req.minWriteQuorum, req.replicaNum); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
 	newArgs := getVolVarargs(vol)
 
 	newArgs.zoneName = req.zoneName
@@ -2012,6 +2182,12 @@ func (m *Server) updateVol(w http.ResponseWriter, r *http.Request) {
 	newArgs.txConflictRetryNum = req.txConflictRetryNum
 	newArgs.txConflictRetryInterval = req.txConflictRetryInterval
 	newArgs.txOpLimit = req.txOpLimit
+	newArgs.minWriteQuorum = req.minWriteQuorum
+	newArgs.disableTinyExtent = req.disableTinyExtent
+	newArgs.crcAlg = req.crcAlg
+	newArgs.quotaByAllocated = req.quotaByAllocated
+	newArgs.maxFileSize = req.maxFileSize
+	newArgs.inodeDelayDeleteTime = req.inodeDelayDeleteTime
 	newArgs.enableQuota = req.enableQuota
 	if req.coldArgs != nil {
 		newArgs.coldArgs = req.coldArgs
@@ -2078,6 +2254,78 @@ func (m *Server) volExpand(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(msg))
 }
 
+// cloneVolConfig creates a new vol with the config of an existing one, the data of
+// the source vol is not copied.
+func (m *Server) cloneVolConfig(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		newName string
+		owner   string
+		err     error
+		srcVol  *Vol
+		vol     *Vol
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.AdminCloneVolConfig))
+	defer func() {
+		doStatAndMetric(proto.AdminCloneVolConfig, metric, err, map[string]string{exporter.Vol: newName})
+	}()
+
+	if name, newName, owner, err = parseRequestToCloneVolConfig(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if srcVol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeVolNotExists, Msg: err.Error()})
+		return
+	}
+	if owner == "" {
+		owner = srcVol.Owner
+	}
+
+	req := getCloneVolConfigReq(srcVol, newName, owner)
+	if vol, err = m.cluster.createVol(req); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+
+	if err = m.associateVolWithUser(req.owner, req.name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+
+	msg := fmt.Sprintf("clone the config of vol[%v] into vol[%v] successfully, has allocate [%v] data partitions", name, newName, len(vol.dataPartitions.partitions))
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+// renameVol changes the name of a vol, its ID and partitions are kept.
+func (m *Server) renameVol(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		newName string
+		authKey string
+		err     error
+		msg     string
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.AdminRenameVol))
+	defer func() {
+		doStatAndMetric(proto.AdminRenameVol, metric, err, map[string]string{exporter.Vol: name})
+	}()
+
+	if name, newName, authKey, err = parseRequestToRenameVol(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if err = m.cluster.renameVol(name, newName, authKey, m.user); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg = fmt.Sprintf("rename vol[%v] to [%v] successfully,from[%v]", name, newName, r.RemoteAddr)
+	log.LogWarn(msg)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
 func (m *Server) volShrink(w http.ResponseWriter, r *http.Request) {
 	var (
 		name     string
@@ -2142,8 +2390,11 @@ func (m *Server) checkCreateReq(req *createVolReq) (err error) {
 		if req.dpReplicaNum > 3 {
 			return fmt.Errorf("hot vol's replicaNum should be 1 to 3, received replicaNum is[%v]", req.dpReplicaNum)
 		}
-		return nil
+		return checkMinWriteQuorum(req.minWriteQuorum, int(req.dpReplicaNum))
 	} else if proto.IsCold(req.volType) {
+		if req.minWriteQuorum != 0 {
+			return fmt.Errorf("cold vol doesn't support minWriteQuorum")
+		}
 		if req.dpReplicaNum > 16 {
 			return fmt.Errorf("cold vol's replicaNum should less then 17, received replicaNum is[%v]", req.dpReplicaNum)
 		}
@@ -2231,6 +2482,14 @@ func (m *Server) createVol(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// a vol pinned to an unknown zone could never allocate partitions
+	if req.zoneName != "" {
+		if err = m.cluster.checkNormalZoneName(req.zoneName); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+	}
+
 	if (req.dpReplicaNum == 1 || req.dpReplicaNum == 2) && !req.followerRead {
 		err = fmt.Errorf("replicaNum be 2 and 3,followerRead must set true")
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
@@ -2365,6 +2624,12 @@ func newSimpleView(vol *Vol) (view *proto.SimpleVolView) {
 		TxConflictRetryNum:      vol.txConflictRetryNum,
 		TxConflictRetryInterval: vol.txConflictRetryInterval,
 		TxOpLimit:               vol.txOpLimit,
+		MinWriteQuorum:          vol.minWriteQuorum,
+		DisableTinyExtent:       vol.disableTinyExtent,
+		CrcAlg:                  vol.crcAlg,
+		QuotaByAllocated:        vol.quotaByAllocated,
+		MaxFileSize:             vol.maxFileSize,
+		InodeDelayDeleteTime:    vol.inodeDelayDeleteTime,
 		NeedToLowerReplica:      vol.NeedToLowerReplica,
 		Authenticate:            vol.authenticate,
 		CrossZone:               vol.crossZone,
@@ -3766,6 +4031,7 @@ func (m *Server) queryDiskDecoProgress(w http.ResponseWriter, r *http.Request) {
 		Status:   status,
 		Progress: fmt.Sprintf("%.2f%%", progress*float64(100)),
 	}
+	disk.fillDecommissionProgress(m.cluster, resp)
 	if status == DecommissionFail {
 		dps := disk.GetLatestDecommissionDP(m.cluster)
 		dpIds := make([]uint64, 0)
@@ -4455,6 +4721,64 @@ func (m *Server) getMetaPartitions(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// listDataPartitions replies a page of the data partitions of a vol.
+func (m *Server) listDataPartitions(w http.ResponseWriter, r *http.Request) {
+	var (
+		name   string
+		offset int
+		limit  int
+		status int8
+		vol    *Vol
+		err    error
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.AdminListDataPartitions))
+	defer func() {
+		doStatAndMetric(proto.AdminListDataPartitions, metric, err, map[string]string{exporter.Vol: name})
+	}()
+
+	if name, offset, limit, status, err = parseRequestToListPartitions(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+
+	page := &proto.DataPartitionsPage{}
+	page.Total, page.DataPartitions = vol.listDataPartitions(offset, limit, status)
+	sendOkReply(w, r, newSuccessHTTPReply(page))
+}
+
+// listMetaPartitions replies a page of the meta partitions of a vol.
+func (m *Server) listMetaPartitions(w http.ResponseWriter, r *http.Request) {
+	var (
+		name   string
+		offset int
+		limit  int
+		status int8
+		vol    *Vol
+		err    error
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.AdminListMetaPartitions))
+	defer func() {
+		doStatAndMetric(proto.AdminListMetaPartitions, metric, err, map[string]string{exporter.Vol: name})
+	}()
+
+	if name, offset, limit, status, err = parseRequestToListPartitions(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+
+	page := &proto.MetaPartitionsPage{}
+	page.Total, page.MetaPartitions = vol.listMetaPartitions(offset, limit, status)
+	sendOkReply(w, r, newSuccessHTTPReply(page))
+}
+
 func (m *Server) putDataPartitions(w http.ResponseWriter, r *http.Request) {
 	var (
 		body []byte
@@ -5217,6 +5541,7 @@ func (m *Server) queryDataNodeDecoProgress(w http.ResponseWriter, r *http.Reques
 		Status:   status,
 		Progress: fmt.Sprintf("%.2f%%", progress*float64(100)),
 	}
+	dn.fillDecommissionProgress(m.cluster, resp)
 	if status == DecommissionFail {
 		err, dps := dn.GetDecommissionFailedDPByTerm(m.cluster)
 		if err != nil {
@@ -5229,6 +5554,69 @@ func (m *Server) queryDataNodeDecoProgress(w http.ResponseWriter, r *http.Reques
 	sendOkReply(w, r, newSuccessHTTPReply(resp))
 }
 
+// queryDecoProgressDetail replies how many of the data partitions of a data
+// node, a disk or a single partition are pending, migrating, completed or
+// failed in the running decommission, with the status of each partition.
+func (m *Server) queryDecoProgressDetail(w http.ResponseWriter, r *http.Request) {
+	var (
+		offLineAddr string
+		diskPath    string
+		partitionID uint64
+		dn          *DataNode
+		dp          *DataPartition
+		err         error
+	)
+
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.QueryDecoProgressDetail))
+	defer func() {
+		doStatAndMetric(proto.QueryDecoProgressDetail, metric, err, nil)
+	}()
+
+	if offLineAddr, diskPath, partitionID, err = parseRequestToQueryDecommissionProgress(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	resp := &proto.DecommissionProgressDetail{
+		DecommissionLimit:      m.cluster.DecommissionLimit,
+		DecommissionDiskFactor: m.cluster.DecommissionDiskFactor,
+		Partitions:             make([]*proto.DecommissionPartitionProgress, 0),
+	}
+	switch {
+	case partitionID != 0:
+		if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+			sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+			return
+		}
+		if dp.DecommissionSrcAddr != offLineAddr || (diskPath != "" && dp.DecommissionSrcDiskPath != diskPath) {
+			err = fmt.Errorf("dp[%v] is not decommissioned from node[%v] disk[%v]", partitionID, offLineAddr, diskPath)
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		resp.TotalDpCnt = 1
+		addDecommissionPartitionProgress(resp, dp)
+	case diskPath != "":
+		value, ok := m.cluster.DecommissionDisks.Load(fmt.Sprintf("%s_%s", offLineAddr, diskPath))
+		if !ok {
+			err = fmt.Errorf("cannot found decommission task for node[%v] disk[%v], may be already offline",
+				offLineAddr, diskPath)
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		value.(*DecommissionDisk).fillDecommissionProgressDetail(m.cluster, resp)
+	default:
+		if dn, err = m.cluster.dataNode(offLineAddr); err != nil {
+			sendErrReply(w, r, newErrHTTPReply(proto.ErrDataNodeNotExists))
+			return
+		}
+		dn.fillDecommissionProgressDetail(m.cluster, resp)
+	}
+	sort.Slice(resp.Partitions, func(i, j int) bool {
+		return resp.Partitions[i].PartitionID < resp.Partitions[j].PartitionID
+	})
+
+	sendOkReply(w, r, newSuccessHTTPReply(resp))
+}
+
 func (m *Server) queryDataNodeDecoFailedDps(w http.ResponseWriter, r *http.Request) {
 	var (
 		offLineAddr string
@@ -903,6 +903,58 @@ func TestUpdateZoneStatus(t *testing.T) {
 	process(enableUrl, t)
 }
 
+func TestDrainZone(t *testing.T) {
+	zone, err := server.cluster.t.getZone(testZone2)
+	if !assert.NoError(t, err) {
+		return
+	}
+	nsc := zone.getAllNodeSet()
+	if !assert.NotZero(t, nsc.Len()) {
+		return
+	}
+	dpCnt := len(commonVol.dataPartitions.partitions)
+	assert.NotZero(t, dpCnt)
+
+	reqUrl := fmt.Sprintf("%v%v?name=%v", hostAddr, proto.DrainZone, testZone2)
+	process(fmt.Sprintf("%v&drain=true", reqUrl), t)
+	assert.True(t, zone.isDrained())
+	assert.True(t, zone.getFsmValue().Drained)
+
+	// no nodeset of the drained zone is selected for new partitions
+	for _, nodeType := range []NodeType{DataNodeType, MetaNodeType} {
+		selectors := []NodesetSelector{
+			NewRoundRobinNodesetSelector(nodeType),
+			NewCarryWeightNodesetSelector(nodeType),
+			NewAvailableSpaceFirstNodesetSelector(nodeType),
+			NewTicketNodesetSelector(nodeType),
+		}
+		for _, selector := range selectors {
+			_, err = selector.Select(zone.getAllNodeSet(), nil, 1)
+			assert.Error(t, err, "%v selects a nodeset of a drained zone", selector.GetName())
+		}
+	}
+	_, _, err = zone.getAvailNodeHosts(TypeDataPartition, nil, nil, 1)
+	assert.Error(t, err)
+	zones, err := server.cluster.t.allocZonesForDataNode(1, 1, nil)
+	if assert.NoError(t, err) {
+		for _, z := range zones {
+			assert.NotEqual(t, testZone2, z.name)
+		}
+	}
+
+	// existing partitions in the drained zone are still served
+	assert.Equal(t, dpCnt, len(commonVol.dataPartitions.partitions))
+	reply := process(fmt.Sprintf("%v%v?name=%v", hostAddr, proto.ClientDataPartitions, commonVolName), t)
+	if assert.NotNil(t, reply) {
+		assert.EqualValues(t, proto.ErrCodeSuccess, reply.Code)
+	}
+
+	process(fmt.Sprintf("%v&drain=false", reqUrl), t)
+	assert.False(t, zone.isDrained())
+	_, err = zone.allocNodeSetForDataNode(nil, 1)
+	assert.NoError(t, err)
+}
+
 func post(reqURL string, data []byte, t *testing.T) (reply *proto.HTTPReply) {
 	reader := bytes.NewReader(data)
 	req, err := http.NewRequest(http.MethodPost, reqURL, reader)
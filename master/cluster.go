@@ -631,16 +631,63 @@ func (c *Cluster) checkLeaderAddr() {
 
 func (c *Cluster) checkDataNodeHeartbeat() {
 	tasks := make([]*proto.AdminTask, 0)
+	writeQuorums := c.getVolWriteQuorums()
 	c.dataNodes.Range(func(addr, dataNode interface{}) bool {
 		node := dataNode.(*DataNode)
 		node.checkLiveness()
 		task := node.createHeartbeatTask(c.masterAddr(), c.diskQosEnable)
+		hbReq := task.Request.(*proto.HeartBeatRequest)
+		hbReq.VolWriteQuorums = writeQuorums
+		hbReq.DataPartitionVolNames = c.getRenamedDataPartitions(node)
 		tasks = append(tasks, task)
 		return true
 	})
 	c.addDataNodeTasks(tasks)
 }
 
+// getVolWriteQuorums returns the write quorum of the vols which have one set.
+func (c *Cluster) getVolWriteQuorums() (quorums map[uint64]int) {
+	c.volMutex.RLock()
+	defer c.volMutex.RUnlock()
+	for _, vol := range c.vols {
+		if vol.minWriteQuorum == 0 {
+			continue
+		}
+		if quorums == nil {
+			quorums = make(map[uint64]int)
+		}
+		quorums[vol.ID] = vol.minWriteQuorum
+	}
+	return
+}
+
+// getRenamedDataPartitions returns the vol names of the data partitions which the
+// node last reported under the name their vol had before it was renamed.
+func (c *Cluster) getRenamedDataPartitions(node *DataNode) (volNames map[uint64]string) {
+	node.RLock()
+	reports := node.DataPartitionReports
+	node.RUnlock()
+	for _, report := range reports {
+		if report == nil || report.VolName == "" {
+			continue
+		}
+		if vol, err := c.getVol(report.VolName); err == nil {
+			if _, err = vol.getDataPartitionByID(report.PartitionID); err == nil {
+				continue
+			}
+		}
+		dp, err := c.getDataPartitionByID(report.PartitionID)
+		if err != nil || dp.VolName == report.VolName {
+			continue
+		}
+		if volNames == nil {
+			volNames = make(map[uint64]string)
+		}
+		volNames[report.PartitionID] = dp.VolName
+	}
+	return
+}
+
 func (c *Cluster) checkMetaNodeHeartbeat() {
 	tasks := make([]*proto.AdminTask, 0)
 	c.volMutex.RLock()
@@ -1287,6 +1334,83 @@ func (c *Cluster) markDeleteVol(name, authKey string, force bool) (err error) {
 	return
 }
 
+// renameVol changes the name of the vol to newName. The vol keeps its ID and its
+// partitions, whose records are matched to the vol by ID. The vol, the policies of
+// its users and its lifecycle configuration are moved in one raft command, so the
+// rename is persisted as a whole or not at all.
+func (c *Cluster) renameVol(name, newName, authKey string, u *User) (err error) {
+	c.volMutex.Lock()
+	defer c.volMutex.Unlock()
+
+	vol, ok := c.vols[name]
+	if !ok || vol.Status == markDelete {
+		log.LogErrorf("action[renameVol] vol[%v] not exist", name)
+		return proto.ErrVolNotExists
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	if _, ok = c.vols[newName]; ok {
+		return proto.ErrDuplicateVol
+	}
+
+	var (
+		cmd       *RaftCmd
+		newLcConf *proto.LcConfiguration
+	)
+	cmds := make(map[string]*RaftCmd)
+	undo, err := u.renameVolPolicy(cmds, name, newName)
+	if err != nil {
+		return
+	}
+	vol.Name = newName
+	defer func() {
+		if err != nil {
+			log.LogErrorf("action[renameVol] vol[%v] to [%v] err[%v]", name, newName, err)
+			vol.Name = name
+			undo()
+			err = proto.ErrPersistenceByRaft
+		}
+	}()
+	if cmd, err = c.buildVolRaftCmd(opSyncUpdateVol, vol); err != nil {
+		return
+	}
+	cmds[cmd.K] = cmd
+	lcConf := c.lcMgr.GetS3BucketLifecycle(name)
+	if lcConf != nil {
+		newLcConf = &proto.LcConfiguration{VolName: newName, Rules: lcConf.Rules}
+		if cmd, err = c.buildLcConfRaftCmd(opSyncUpdateLcConf, newLcConf); err != nil {
+			return
+		}
+		cmds[cmd.K] = cmd
+		if cmd, err = c.buildLcConfRaftCmd(opSyncDeleteLcConf, lcConf); err != nil {
+			return
+		}
+		cmds[cmd.K] = cmd
+	}
+	if err = c.syncBatchCommitCmd(cmds); err != nil {
+		return
+	}
+
+	delete(c.vols, name)
+	c.vols[newName] = vol
+	c.volStatInfo.Delete(name)
+	if lcConf != nil {
+		c.lcMgr.DelS3BucketLifecycle(name)
+		c.lcMgr.SetS3BucketLifecycle(newLcConf)
+	}
+	// the nodes are given the new name when they report a partition under the
+	// old one, see updateMetaNode and updateDataNode
+	for _, dp := range vol.dataPartitions.clonePartitions() {
+		dp.VolName = newName
+	}
+	for _, mp := range vol.cloneMetaPartitionMap() {
+		mp.volName = newName
+	}
+	log.LogWarnf("action[renameVol] vol[%v] id[%v] renamed to [%v]", name, vol.ID, newName)
+	return nil
+}
+
 func (c *Cluster) batchCreatePreLoadDataPartition(vol *Vol, preload *DataPartitionPreLoad) (err error, dps []*DataPartition) {
 	if proto.IsHot(vol.VolType) {
 		return fmt.Errorf("vol type is not warm"), nil
@@ -2971,6 +3095,7 @@ func (c *Cluster) checkNormalZoneName(zoneName string) (err error) {
 		zones = c.t.getZoneNameList()
 	}
 
+	var unknownZones []string
 	zoneList := strings.Split(zoneName, ",")
 	for i := 0; i < len(zoneList); i++ {
 		var isZone bool
@@ -2982,9 +3107,12 @@ func (c *Cluster) checkNormalZoneName(zoneName string) (err error) {
 		}
 
 		if !isZone {
-			return fmt.Errorf("action[checkZoneName] the zonename[%s] not found", zoneList[i])
+			unknownZones = append(unknownZones, zoneList[i])
 		}
 	}
+	if len(unknownZones) > 0 {
+		return fmt.Errorf("action[checkZoneName] the zonename[%s] not found", strings.Join(unknownZones, ","))
+	}
 	return
 }
 
@@ -3150,6 +3278,12 @@ func (c *Cluster) doCreateVol(req *createVolReq) (vol *Vol, err error) {
 		TxTimeout:               req.txTimeout,
 		TxConflictRetryNum:      req.txConflictRetryNum,
 		TxConflictRetryInterval: req.txConflictRetryInterval,
+		MinWriteQuorum:          req.minWriteQuorum,
+		DisableTinyExtent:       req.disableTinyExtent,
+		CrcAlg:                  req.crcAlg,
+		QuotaByAllocated:        req.quotaByAllocated,
+		MaxFileSize:             req.maxFileSize,
+		InodeDelayDeleteTime:    req.inodeDelayDeleteTime,
 
 		VolType:          req.volType,
 		EbsBlkSize:       req.coldArgs.objBlockSize,
@@ -3351,6 +3485,63 @@ func (c *Cluster) balanceMetaPartitionLeader(zones map[string]struct{}, nodeSetI
 	return nil
 }
 
+// balanceMetaPartitionInode plans moves of meta partition replicas that even out the
+// inode count of the selected metaNodes, and migrates them in the background unless dryRun is set.
+func (c *Cluster) balanceMetaPartitionInode(zones map[string]struct{}, nodeSetIds map[uint64]struct{}, maxMoves int, dryRun bool) (moves []*MetaPartitionMove, err error) {
+	if c.ForbidMpDecommission {
+		err = fmt.Errorf("cluster mataPartition decommission switch is disabled")
+		return
+	}
+	nodes := c.getInodeMetaNodes(zones, nodeSetIds)
+	if len(nodes) < 2 {
+		return nil, errors.New("at least two active metaNodes are required to balance inode")
+	}
+
+	moves = planMetaPartitionInodeBalance(nodes, maxMoves)
+	log.LogInfof("action[balanceMetaPartitionInode] metaNodes[%d] planned moves[%d] dryRun[%v]", len(nodes), len(moves), dryRun)
+	if dryRun || len(moves) == 0 {
+		return
+	}
+
+	go func() {
+		for _, move := range moves {
+			mp, err := c.getMetaPartitionByID(move.PartitionID)
+			if err != nil {
+				log.LogErrorf("action[balanceMetaPartitionInode] move[%v] err[%v]", move, err)
+				continue
+			}
+			if err = c.migrateMetaPartition(move.Src, move.Dst, mp); err != nil {
+				log.LogErrorf("action[balanceMetaPartitionInode] move[%v] err[%v]", move, err)
+				continue
+			}
+			log.LogInfof("action[balanceMetaPartitionInode] move[%v] success", move)
+		}
+	}()
+	return
+}
+
+func (c *Cluster) getInodeMetaNodes(zones map[string]struct{}, nodeSetIds map[uint64]struct{}) (nodes []*InodeMetaNode) {
+	for _, metaNode := range c.getSpecifiedMetaNodes(zones, nodeSetIds) {
+		node := &InodeMetaNode{
+			addr:       metaNode.Addr,
+			writable:   metaNode.isWritable(),
+			partitions: make(map[uint64]uint64),
+		}
+		metaNode.RLock()
+		if !metaNode.IsActive {
+			metaNode.RUnlock()
+			continue
+		}
+		for _, mr := range metaNode.metaPartitionInfos {
+			node.partitions[mr.PartitionID] = mr.InodeCnt
+			node.inodeCount += mr.InodeCnt
+		}
+		metaNode.RUnlock()
+		nodes = append(nodes, node)
+	}
+	return
+}
+
 func (c *Cluster) getSortLeaderMetaNodes(zones map[string]struct{}, nodeSetIds map[uint64]struct{}) *sortLeaderMetaNode {
 	metaNodes := c.getSpecifiedMetaNodes(zones, nodeSetIds)
 	log.LogInfof("metaNode length:%d", len(metaNodes))
@@ -970,12 +970,12 @@ func (c *Cluster) handleResponseToLoadDataPartition(nodeAddr string, resp *proto
 		return
 	}
 	if resp.VolName != "" {
-		vol, err = c.getVol(resp.VolName)
-		if err != nil {
-			return
+		if vol, err = c.getVol(resp.VolName); err == nil {
+			dp, err = vol.getDataPartitionByID(resp.PartitionId)
 		}
-		dp, err = vol.getDataPartitionByID(resp.PartitionId)
-	} else {
+	}
+	// the vol may have been renamed since the partition was created on the node
+	if dp == nil {
 		dp, err = c.getDataPartitionByID(resp.PartitionId)
 	}
 	if err != nil {
@@ -1078,16 +1078,25 @@ func (c *Cluster) updateDataNode(dataNode *DataNode, dps []*proto.PartitionRepor
 			continue
 		}
 		if vr.VolName != "" {
+			var dp *DataPartition
 			vol, err := c.getVol(vr.VolName)
+			if err == nil {
+				dp, err = vol.getDataPartitionByID(vr.PartitionID)
+			}
 			if err != nil {
-				continue
+				// the vol may have been renamed since the partition was created on the node,
+				// the new name is sent to it by the heartbeat
+				if dp, err = c.getDataPartitionByID(vr.PartitionID); err != nil {
+					continue
+				}
+				if vol, err = c.getVol(dp.VolName); err != nil {
+					continue
+				}
 			}
 			if vol.Status == markDelete {
 				continue
 			}
-			if dp, err := vol.getDataPartitionByID(vr.PartitionID); err == nil {
-				dp.updateMetric(vr, dataNode, c)
-			}
+			dp.updateMetric(vr, dataNode, c)
 		} else {
 			if dp, err := c.getDataPartitionByID(vr.PartitionID); err == nil {
 				dp.updateMetric(vr, dataNode, c)
@@ -1109,19 +1118,23 @@ func (c *Cluster) updateMetaNode(metaNode *MetaNode, metaPartitions []*proto.Met
 		if mr.VolName != "" {
 
 			vol, err = c.getVol(mr.VolName)
+			if err == nil {
+				mp, err = vol.metaPartition(mr.PartitionID)
+			}
 			if err != nil {
-				continue
+				// the vol may have been renamed since the partition was created on the node
+				if mp, err = c.getMetaPartitionByID(mr.PartitionID); err != nil {
+					continue
+				}
+				if vol, err = c.getVol(mp.volName); err != nil {
+					continue
+				}
 			}
 
 			if vol.Status == markDelete {
 				continue
 			}
 
-			mp, err = vol.metaPartition(mr.PartitionID)
-			if err != nil {
-				continue
-			}
-
 		} else {
 			mp, err = c.getMetaPartitionByID(mr.PartitionID)
 			if err != nil {
@@ -1129,8 +1142,8 @@ func (c *Cluster) updateMetaNode(metaNode *MetaNode, metaPartitions []*proto.Met
 			}
 		}
 
-		//send latest end to replica
-		if mr.End != mp.End {
+		//send latest end and vol name to replica
+		if mr.End != mp.End || (mr.VolName != "" && mr.VolName != mp.volName) {
 			mp.addUpdateMetaReplicaTask(c)
 		}
 
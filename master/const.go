@@ -26,10 +26,12 @@ const (
 	addrKey               = "addr"
 	diskPathKey           = "disk"
 	nameKey               = "name"
+	newNameKey            = "newName"
 	idKey                 = "id"
 	countKey              = "count"
 	startKey              = "start"
 	enableKey             = "enable"
+	drainKey              = "drain"
 	thresholdKey          = "threshold"
 	dirQuotaKey           = "dirQuota"
 	dirLimitKey           = "dirSizeLimit"
@@ -95,6 +97,12 @@ const (
 	txConflictRetryNumKey      = "txConflictRetryNum"
 	txConflictRetryIntervalKey = "txConflictRetryInterval"
 	txOpLimitKey               = "txOpLimit"
+	minWriteQuorumKey          = "minWriteQuorum"
+	disableTinyExtentKey       = "disableTinyExtent"
+	crcAlgKey                  = "crcAlg"
+	quotaByAllocatedKey        = "quotaByAllocated"
+	maxFileSizeKey             = "maxFileSize"
+	inodeDelayDeleteTimeKey    = "inodeDelayDeleteTime"
 	txForceResetKey            = "txForceReset"
 	QosEnableKey               = "qosEnable"
 	DiskEnableKey              = "diskenable"
@@ -130,6 +138,9 @@ const (
 	Periodic                   = "periodic"
 	DecommissionType           = "decommissionType"
 	decommissionDiskFactor     = "decommissionDiskFactor"
+	dryRunKey                  = "dryRun"
+	offsetKey                  = "offset"
+	statusKey                  = "status"
 )
 
 const (
@@ -191,6 +202,7 @@ const (
 	defaultNodeSetGrpBatchCnt                    = 3
 	defaultMigrateDpCnt                          = 50
 	defaultMigrateMpCnt                          = 15
+	maxListPartitionsLimit                       = 10000
 	defaultMaxReplicaCnt                         = 16
 	defaultIopsRLimit                     uint64 = 1 << 35
 	defaultIopsWLimit                     uint64 = 1 << 35
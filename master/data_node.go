@@ -418,6 +418,35 @@ func (dataNode *DataNode) GetLatestDecommissionDataPartition(c *Cluster) (partit
 	return
 }
 
+// fillDecommissionProgress adds the partition counts of the decommission of the
+// data node to resp. The disks done are removed from the cache, so their
+// partitions are counted as moved out of DecommissionDpTotal.
+func (dataNode *DataNode) fillDecommissionProgress(c *Cluster, resp *proto.DecommissionProgress) {
+	for _, disk := range dataNode.DecommissionDiskList {
+		key := fmt.Sprintf("%s_%s", dataNode.Addr, disk)
+		if value, ok := c.DecommissionDisks.Load(key); ok {
+			value.(*DecommissionDisk).fillDecommissionProgress(c, resp)
+		}
+	}
+	if dataNode.DecommissionDpTotal > resp.TotalDpCnt {
+		resp.MovedDpCnt += dataNode.DecommissionDpTotal - resp.TotalDpCnt
+		resp.TotalDpCnt = dataNode.DecommissionDpTotal
+	}
+}
+
+func (dataNode *DataNode) fillDecommissionProgressDetail(c *Cluster, resp *proto.DecommissionProgressDetail) {
+	for _, disk := range dataNode.DecommissionDiskList {
+		key := fmt.Sprintf("%s_%s", dataNode.Addr, disk)
+		if value, ok := c.DecommissionDisks.Load(key); ok {
+			value.(*DecommissionDisk).fillDecommissionProgressDetail(c, resp)
+		}
+	}
+	if dataNode.DecommissionDpTotal > resp.TotalDpCnt {
+		resp.CompletedDpCnt += dataNode.DecommissionDpTotal - resp.TotalDpCnt
+		resp.TotalDpCnt = dataNode.DecommissionDpTotal
+	}
+}
+
 func (dataNode *DataNode) GetDecommissionStatus() uint32 {
 	return atomic.LoadUint32(&dataNode.DecommissionStatus)
 }
@@ -1,8 +1,10 @@
 package master
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
 	"testing"
 	"time"
 )
@@ -41,3 +43,157 @@ func decommissionDataNode(addr string, t *testing.T) {
 	fmt.Println(reqURL)
 	process(reqURL, t)
 }
+
+func TestDataNodeDecommissionProgress(t *testing.T) {
+	addr, diskPath := "127.0.0.1:9097", "/cfs/disk"
+	vol := &Vol{Name: "decoProgressVol", Status: normal, dataPartitions: newDataPartitionMap("decoProgressVol")}
+	c := &Cluster{vols: map[string]*Vol{vol.Name: vol}}
+
+	// four partitions of the disk wait to be moved
+	for i := 0; i < 4; i++ {
+		dp := newDataPartition(uint64(i+1), 3, vol.Name, 1, proto.PartitionTypeNormal, 0)
+		dp.used = 100
+		dp.DecommissionSrcAddr = addr
+		dp.DecommissionSrcDiskPath = diskPath
+		dp.DecommissionTerm = 1
+		dp.SetDecommissionStatus(DecommissionPrepare)
+		vol.dataPartitions.put(dp)
+	}
+	disk := &DecommissionDisk{SrcAddr: addr, DiskPath: diskPath, DecommissionTerm: 1, DecommissionDpTotal: 4}
+	disk.SetDecommissionStatus(DecommissionRunning)
+	c.DecommissionDisks.Store(disk.GenerateKey(), disk)
+	dn := &DataNode{Addr: addr, DecommissionDiskList: []string{diskPath}, DecommissionDpTotal: 4}
+
+	progress := func() *proto.DecommissionProgress {
+		resp := &proto.DecommissionProgress{}
+		disk.fillDecommissionProgress(c, resp)
+		nodeResp := &proto.DecommissionProgress{}
+		dn.fillDecommissionProgress(c, nodeResp)
+		require.Equal(t, resp, nodeResp)
+		return resp
+	}
+	resp := progress()
+	require.Equal(t, 4, resp.TotalDpCnt)
+	require.Equal(t, 0, resp.MovedDpCnt)
+	require.Equal(t, 4, resp.RunningDpCnt)
+	require.Equal(t, uint64(400), resp.RemainingBytes)
+
+	// the numbers advance as the partitions are moved
+	dps := vol.dataPartitions.partitions
+	dps[0].ResetDecommissionStatus()
+	dps[1].SetDecommissionStatus(DecommissionFail)
+	resp = progress()
+	require.Equal(t, 4, resp.TotalDpCnt)
+	require.Equal(t, 1, resp.MovedDpCnt)
+	require.Equal(t, 2, resp.RunningDpCnt)
+	require.Equal(t, 1, resp.FailedDpCnt)
+	require.Equal(t, uint64(300), resp.RemainingBytes)
+
+	dps[2].ResetDecommissionStatus()
+	dps[3].ResetDecommissionStatus()
+	resp = progress()
+	require.Equal(t, 3, resp.MovedDpCnt)
+	require.Equal(t, 0, resp.RunningDpCnt)
+	require.Equal(t, uint64(100), resp.RemainingBytes)
+
+	// the disk done is dropped from the cache, its partitions count as moved
+	dps[1].ResetDecommissionStatus()
+	c.DecommissionDisks.Delete(disk.GenerateKey())
+	resp = &proto.DecommissionProgress{}
+	dn.fillDecommissionProgress(c, resp)
+	require.Equal(t, 4, resp.TotalDpCnt)
+	require.Equal(t, 4, resp.MovedDpCnt)
+	require.Equal(t, uint64(0), resp.RemainingBytes)
+}
+
+func TestDecommissionProgressDetail(t *testing.T) {
+	addr, diskPaths := "127.0.0.1:9098", []string{"/cfs/disk1", "/cfs/disk2"}
+	vol := &Vol{Name: "decoDetailVol", Status: normal, dataPartitions: newDataPartitionMap("decoDetailVol")}
+	c := &Cluster{vols: map[string]*Vol{vol.Name: vol}, DecommissionLimit: 10, DecommissionDiskFactor: 0.5}
+
+	// each disk holds three partitions, one of them already moved
+	statuses := []uint32{DecommissionPrepare, DecommissionRunning, DecommissionFail}
+	id := uint64(0)
+	for _, diskPath := range diskPaths {
+		for _, status := range statuses {
+			id++
+			dp := newDataPartition(id, 3, vol.Name, 1, proto.PartitionTypeNormal, 0)
+			dp.DecommissionSrcAddr = addr
+			dp.DecommissionSrcDiskPath = diskPath
+			dp.DecommissionTerm = 1
+			dp.SetDecommissionStatus(status)
+			vol.dataPartitions.put(dp)
+		}
+		disk := &DecommissionDisk{SrcAddr: addr, DiskPath: diskPath, DecommissionTerm: 1, DecommissionDpTotal: 4}
+		disk.SetDecommissionStatus(DecommissionRunning)
+		c.DecommissionDisks.Store(disk.GenerateKey(), disk)
+	}
+
+	// disk level
+	value, _ := c.DecommissionDisks.Load(fmt.Sprintf("%s_%s", addr, diskPaths[0]))
+	resp := &proto.DecommissionProgressDetail{}
+	value.(*DecommissionDisk).fillDecommissionProgressDetail(c, resp)
+	require.Equal(t, 4, resp.TotalDpCnt)
+	require.Equal(t, 1, resp.PendingDpCnt)
+	require.Equal(t, 1, resp.MigratingDpCnt)
+	require.Equal(t, 1, resp.CompletedDpCnt)
+	require.Equal(t, 1, resp.FailedDpCnt)
+	require.Len(t, resp.Partitions, 3)
+	for _, p := range resp.Partitions {
+		require.Equal(t, diskPaths[0], p.DiskPath)
+		dp, err := vol.getDataPartitionByID(p.PartitionID)
+		require.NoError(t, err)
+		require.Equal(t, dp.GetDecommissionStatus(), p.Status)
+	}
+
+	// node level, the partitions of a disk dropped from the cache count as completed
+	dn := &DataNode{Addr: addr, DecommissionDiskList: append(diskPaths, "/cfs/disk3"), DecommissionDpTotal: 10}
+	resp = &proto.DecommissionProgressDetail{}
+	dn.fillDecommissionProgressDetail(c, resp)
+	require.Equal(t, 10, resp.TotalDpCnt)
+	require.Equal(t, 2, resp.PendingDpCnt)
+	require.Equal(t, 2, resp.MigratingDpCnt)
+	require.Equal(t, 4, resp.CompletedDpCnt)
+	require.Equal(t, 2, resp.FailedDpCnt)
+	require.Len(t, resp.Partitions, 6)
+}
+
+func TestQueryDecommissionProgressDetail(t *testing.T) {
+	addr, diskPath := "127.0.0.1:9099", "/cfs/disk"
+	vol, err := server.cluster.getVol(commonVolName)
+	require.NoError(t, err)
+	dp := vol.dataPartitions.clonePartitions()[0]
+	dp.DecommissionSrcAddr = addr
+	dp.DecommissionSrcDiskPath = diskPath
+	dp.SetDecommissionStatus(DecommissionRunning)
+	defer func() {
+		dp.DecommissionSrcAddr = ""
+		dp.DecommissionSrcDiskPath = ""
+		dp.SetDecommissionStatus(DecommissionInitial)
+	}()
+
+	// single partition
+	req := map[string]interface{}{addrKey: addr, diskPathKey: diskPath, idKey: dp.PartitionID}
+	reply := processWithFatalV2(proto.QueryDecoProgressDetail, true, req, t)
+	resp := &proto.DecommissionProgressDetail{}
+	require.NoError(t, json.Unmarshal(reply.Data, resp))
+	require.Equal(t, 1, resp.TotalDpCnt)
+	require.Equal(t, 1, resp.MigratingDpCnt)
+	require.Equal(t, server.cluster.DecommissionLimit, resp.DecommissionLimit)
+	require.Equal(t, server.cluster.DecommissionDiskFactor, resp.DecommissionDiskFactor)
+	require.Len(t, resp.Partitions, 1)
+	require.Equal(t, dp.PartitionID, resp.Partitions[0].PartitionID)
+
+	// the partition is not moving off another disk or node
+	req[diskPathKey] = "/cfs/other"
+	processWithFatalV2(proto.QueryDecoProgressDetail, false, req, t)
+	delete(req, diskPathKey)
+	req[addrKey] = "127.0.0.1:9100"
+	processWithFatalV2(proto.QueryDecoProgressDetail, false, req, t)
+
+	// no decommission runs on the disk or the node
+	req = map[string]interface{}{addrKey: addr, diskPathKey: diskPath}
+	processWithFatalV2(proto.QueryDecoProgressDetail, false, req, t)
+	delete(req, diskPathKey)
+	processWithFatalV2(proto.QueryDecoProgressDetail, false, req, t)
+}
@@ -280,10 +280,12 @@ func (partition *DataPartition) createTaskToCreateDataPartition(addr string, dat
 		leaderSize = int(partition.Replicas[0].Used)
 	}
 
-	task = proto.NewAdminTask(proto.OpCreateDataPartition, addr, newCreateDataPartitionRequest(
+	req := newCreateDataPartitionRequest(
 		partition.VolName, partition.PartitionID, int(partition.ReplicaNum),
 		peers, int(dataPartitionSize), leaderSize, hosts, createType,
-		partitionType, decommissionedDisks, partition.VerSeq))
+		partitionType, decommissionedDisks, partition.VerSeq)
+	req.VolId = partition.VolID
+	task = proto.NewAdminTask(proto.OpCreateDataPartition, addr, req)
 	partition.resetTaskID(task)
 	return
 }
@@ -452,10 +454,24 @@ func (partition *DataPartition) convertToDataPartitionResponse() (dpr *proto.Dat
 	dpr.LeaderAddr = partition.getLeaderAddr()
 	dpr.IsRecover = partition.isRecover
 	dpr.IsDiscard = partition.IsDiscard
+	if len(dpr.Hosts) > 0 {
+		dpr.ZoneName = partition.getZoneOfHost(dpr.Hosts[0])
+	}
 
 	return
 }
 
+// getZoneOfHost returns the zone of the data node of the replica at addr, the
+// caller holds the lock.
+func (partition *DataPartition) getZoneOfHost(addr string) string {
+	for _, replica := range partition.Replicas {
+		if replica.Addr == addr && replica.dataNode != nil {
+			return replica.dataNode.ZoneName
+		}
+	}
+	return ""
+}
+
 func (partition *DataPartition) getLeaderAddr() (leaderAddr string) {
 	for _, replica := range partition.Replicas {
 		if replica.IsLeader {
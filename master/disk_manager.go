@@ -19,6 +19,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/cubefs/cubefs/proto"
 	//"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/log"
 )
@@ -279,6 +280,60 @@ func (dd *DecommissionDisk) updateDecommissionStatus(c *Cluster, debug bool) (ui
 	return dd.GetDecommissionStatus(), progress
 }
 
+// fillDecommissionProgress adds the partition counts of the decommission of the
+// disk to resp, the used space of the partitions not moved yet is added as the
+// remaining bytes.
+func (dd *DecommissionDisk) fillDecommissionProgress(c *Cluster, resp *proto.DecommissionProgress) {
+	if dd.DecommissionDpTotal == InvalidDecommissionDpCnt {
+		return
+	}
+	partitions := c.getAllDecommissionDataPartitionByDiskAndTerm(dd.SrcAddr, dd.DiskPath, dd.DecommissionTerm)
+	resp.TotalDpCnt += dd.DecommissionDpTotal
+	resp.MovedDpCnt += dd.DecommissionDpTotal - len(partitions)
+	for _, dp := range partitions {
+		if dp.IsDecommissionFailed() {
+			resp.FailedDpCnt++
+		} else if dp.IsDecommissionRunning() || dp.IsDecommissionPrepare() {
+			resp.RunningDpCnt++
+		}
+		resp.RemainingBytes += dp.getMaxUsedSpace()
+	}
+}
+
+func (dd *DecommissionDisk) fillDecommissionProgressDetail(c *Cluster, resp *proto.DecommissionProgressDetail) {
+	if dd.DecommissionDpTotal == InvalidDecommissionDpCnt {
+		return
+	}
+	partitions := c.getAllDecommissionDataPartitionByDiskAndTerm(dd.SrcAddr, dd.DiskPath, dd.DecommissionTerm)
+	resp.TotalDpCnt += dd.DecommissionDpTotal
+	// the partitions moved are reset and no longer found by the disk
+	resp.CompletedDpCnt += dd.DecommissionDpTotal - len(partitions)
+	for _, dp := range partitions {
+		addDecommissionPartitionProgress(resp, dp)
+	}
+}
+
+// addDecommissionPartitionProgress counts the partition into the bucket of its
+// decommission status and appends it to the partitions of the reply.
+func addDecommissionPartitionProgress(resp *proto.DecommissionProgressDetail, dp *DataPartition) {
+	status := dp.GetDecommissionStatus()
+	switch status {
+	case DecommissionSuccess:
+		resp.CompletedDpCnt++
+	case DecommissionFail:
+		resp.FailedDpCnt++
+	case DecommissionRunning:
+		resp.MigratingDpCnt++
+	default:
+		resp.PendingDpCnt++
+	}
+	resp.Partitions = append(resp.Partitions, &proto.DecommissionPartitionProgress{
+		PartitionID: dp.PartitionID,
+		Status:      status,
+		DiskPath:    dp.DecommissionSrcDiskPath,
+	})
+}
+
 func (dd *DecommissionDisk) GetDecommissionStatus() uint32 {
 	return atomic.LoadUint32(&dd.DecommissionStatus)
 }
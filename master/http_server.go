@@ -213,6 +213,7 @@ var AuthenticationUri2MsgTypeMap = map[string]proto.MsgType{
 
 	// Master API zone management
 	proto.UpdateZone: proto.MsgMasterUpdateZoneReq,
+	proto.DrainZone:  proto.MsgMasterUpdateZoneReq,
 }
 
 func (m *Server) registerAuthenticationMiddleware(router *mux.Router) {
@@ -368,6 +369,12 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminVolExpand).
 		HandlerFunc(m.volExpand)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminCloneVolConfig).
+		HandlerFunc(m.cloneVolConfig)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminRenameVol).
+		HandlerFunc(m.renameVol)
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.ClientVol).
 		HandlerFunc(m.getVol)
@@ -448,9 +455,15 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminBalanceMetaPartitionLeader).
 		HandlerFunc(m.balanceMetaPartitionLeader)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminBalanceMetaPartitionInode).
+		HandlerFunc(m.balanceMetaPartitionInode)
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.ClientMetaPartitions).
 		HandlerFunc(m.getMetaPartitions)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminListMetaPartitions).
+		HandlerFunc(m.listMetaPartitions)
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.ClientMetaPartition).
 		HandlerFunc(m.getMetaPartition)
@@ -472,6 +485,9 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.QosGetZoneLimitInfo).
 		HandlerFunc(m.QosGetZoneLimit)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.QosGetLimit).
+		HandlerFunc(m.QosGetLimit)
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.QosUpdateMasterLimit).
 		HandlerFunc(m.getQosUpdateMasterLimit)
@@ -498,6 +514,9 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.AdminGetDataPartition).
 		HandlerFunc(m.getDataPartition)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminListDataPartitions).
+		HandlerFunc(m.listDataPartitions)
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminCreateDataPartition).
 		HandlerFunc(m.createDataPartition)
@@ -597,6 +616,9 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.QueryDiskDecoProgress).
 		HandlerFunc(m.queryDiskDecoProgress)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.QueryDecoProgressDetail).
+		HandlerFunc(m.queryDecoProgressDetail)
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.MarkDecoDiskFixed).
 		HandlerFunc(m.markDecoDiskFixed)
@@ -698,6 +720,9 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.UpdateZone).
 		HandlerFunc(m.updateZone)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.DrainZone).
+		HandlerFunc(m.drainZone)
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.GetAllZones).
 		HandlerFunc(m.listZone)
@@ -854,6 +854,39 @@ func (vol *Vol) getClientLimitInfo(id uint64, ip string) (interface{}, error) {
 	return nil, fmt.Errorf("not found")
 }
 
+// getQosLimit returns the limits of the vol, or the sum of the limits assigned
+// to the clients of the host when the host is given.
+func (vol *Vol) getQosLimit(host string) (limit *qosArgs, err error) {
+	vol.qosManager.RLock()
+	defer vol.qosManager.RUnlock()
+
+	limit = &qosArgs{qosEnable: vol.qosManager.qosEnable}
+	vals := [4]*uint64{&limit.iopsRVal, &limit.iopsWVal, &limit.flowRVal, &limit.flowWVal}
+	if host == "" {
+		for i := proto.IopsReadType; i <= proto.FlowWriteType; i++ {
+			*vals[i-proto.IopsReadType] = vol.qosManager.serverFactorLimitMap[i].Total
+		}
+		return
+	}
+
+	found := false
+	for _, info := range vol.qosManager.cliInfoMgrMap {
+		if info.Host != host {
+			continue
+		}
+		found = true
+		for i := proto.IopsReadType; i <= proto.FlowWriteType; i++ {
+			if assign := info.Assign.FactorMap[i]; assign != nil {
+				*vals[i-proto.IopsReadType] += assign.UsedLimit
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("client of host [%v] not found", host)
+	}
+	return
+}
+
 func (vol *Vol) volQosEnable(c *Cluster, enable bool) error {
 	log.LogWarnf("action[qosEnable] vol %v, set qos enable [%v], qosmgr[%v]", vol.Name, enable, vol.qosManager)
 	vol.qosManager.qosEnable = enable
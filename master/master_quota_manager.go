@@ -272,6 +272,9 @@ func (mqMgr *MasterQuotaManager) getQuotaHbInfos() (infos []*proto.QuotaHeartBea
 		info.LimitedInfo.LimitedFiles = quotaInfo.LimitedInfo.LimitedFiles
 		info.LimitedInfo.LimitedBytes = quotaInfo.LimitedInfo.LimitedBytes
 		info.Enable = mqMgr.vol.enableQuota
+		info.MaxFiles = quotaInfo.MaxFiles
+		info.MaxBytes = quotaInfo.MaxBytes
+		info.UsedInfo = quotaInfo.UsedInfo
 		infos = append(infos, info)
 		log.LogDebugf("getQuotaHbInfos info %v", info)
 	}
@@ -15,6 +15,8 @@
 package master
 
 import (
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -232,6 +234,77 @@ func (s *sortLeaderMetaNode) changeLeader(l *LeaderMetaNode) {
 	}
 }
 
+// InodeMetaNode define the meta partition replicas and their inode count in meta node
+type InodeMetaNode struct {
+	addr       string
+	writable   bool
+	inodeCount uint64
+	partitions map[uint64]uint64 // partition id -> inode count
+}
+
+// MetaPartitionMove defines the migration of one meta partition replica planned by the inode balance
+type MetaPartitionMove struct {
+	PartitionID uint64 `json:"partitionID"`
+	InodeCount  uint64 `json:"inodeCount"`
+	Src         string `json:"src"`
+	Dst         string `json:"dst"`
+}
+
+func (move *MetaPartitionMove) String() string {
+	return fmt.Sprintf("mp(%v) inodes(%v) %v->%v", move.PartitionID, move.InodeCount, move.Src, move.Dst)
+}
+
+// planMetaPartitionInodeBalance moves replicas from the metaNode holding the most inodes to the
+// ones holding the least, each move picking the partition that narrows the gap between the two
+// nodes the most. Every partition moves at most once, so the plan never races with itself.
+func planMetaPartitionInodeBalance(nodes []*InodeMetaNode, maxMoves int) (moves []*MetaPartitionMove) {
+	moved := make(map[uint64]struct{})
+	for len(moves) < maxMoves && len(nodes) > 1 {
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].inodeCount > nodes[j].inodeCount
+		})
+		src := nodes[0]
+		var (
+			dst       *InodeMetaNode
+			pid       uint64
+			inodeCnt  uint64
+			remainGap uint64
+		)
+		for i := len(nodes) - 1; i > 0 && dst == nil; i-- {
+			if !nodes[i].writable {
+				continue
+			}
+			gap := src.inodeCount - nodes[i].inodeCount
+			for id, cnt := range src.partitions {
+				if _, ok := moved[id]; ok || cnt == 0 || cnt >= gap {
+					continue
+				}
+				if _, ok := nodes[i].partitions[id]; ok {
+					continue
+				}
+				// the smaller |gap - 2*cnt| is, the closer both nodes get to each other
+				remain := gap - 2*cnt
+				if 2*cnt > gap {
+					remain = 2*cnt - gap
+				}
+				if dst == nil || remain < remainGap {
+					dst, pid, inodeCnt, remainGap = nodes[i], id, cnt, remain
+				}
+			}
+		}
+		if dst == nil {
+			break
+		}
+		moves = append(moves, &MetaPartitionMove{PartitionID: pid, InodeCount: inodeCnt, Src: src.addr, Dst: dst.addr})
+		moved[pid] = struct{}{}
+		delete(src.partitions, pid)
+		src.inodeCount -= inodeCnt
+		dst.partitions[pid] = inodeCnt
+		dst.inodeCount += inodeCnt
+	}
+	return
+}
+
 func (s *sortLeaderMetaNode) balanceLeader() {
 	for _, node := range s.nodes {
 		log.LogDebugf("node[%v] leader count is:%d,average:%d", node.addr, len(node.metaPartitions), s.average)
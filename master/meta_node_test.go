@@ -28,3 +28,69 @@ func decommissionMetaNode(addr string, t *testing.T) {
 	fmt.Println(reqURL)
 	process(reqURL, t)
 }
+
+func TestPlanMetaPartitionInodeBalance(t *testing.T) {
+	nodes := []*InodeMetaNode{
+		{addr: "mn1", writable: true, partitions: map[uint64]uint64{1: 9000, 2: 4000, 3: 3000, 4: 1000}},
+		{addr: "mn2", writable: true, partitions: map[uint64]uint64{1: 9000, 5: 1000}},
+		{addr: "mn3", writable: true, partitions: map[uint64]uint64{2: 4000, 6: 500}},
+		{addr: "mn4", writable: false, partitions: map[uint64]uint64{}},
+	}
+	before := make(map[string]uint64)
+	hosted := make(map[string]map[uint64]bool)
+	for _, node := range nodes {
+		hosted[node.addr] = make(map[uint64]bool)
+		for id, cnt := range node.partitions {
+			node.inodeCount += cnt
+			hosted[node.addr][id] = true
+		}
+		before[node.addr] = node.inodeCount
+	}
+
+	moves := planMetaPartitionInodeBalance(nodes, defaultMigrateMpCnt)
+	if len(moves) == 0 {
+		t.Fatalf("expect moves for skewed inode counts")
+	}
+
+	after := make(map[string]uint64)
+	for addr, cnt := range before {
+		after[addr] = cnt
+	}
+	moved := make(map[uint64]bool)
+	for _, move := range moves {
+		if move.Dst == "mn4" {
+			t.Errorf("move %v targets a metaNode that is not writable", move)
+		}
+		if hosted[move.Dst][move.PartitionID] {
+			t.Errorf("move %v targets a metaNode that already hosts the mp", move)
+		}
+		if moved[move.PartitionID] {
+			t.Errorf("mp %v moved more than once", move.PartitionID)
+		}
+		moved[move.PartitionID] = true
+		after[move.Src] -= move.InodeCount
+		after[move.Dst] += move.InodeCount
+	}
+
+	spread := func(counts map[string]uint64) uint64 {
+		var max, min uint64 = 0, ^uint64(0)
+		for addr, cnt := range counts {
+			if addr == "mn4" {
+				continue
+			}
+			if cnt > max {
+				max = cnt
+			}
+			if cnt < min {
+				min = cnt
+			}
+		}
+		return max - min
+	}
+	if spread(after) >= spread(before) {
+		t.Errorf("plan does not narrow the inode spread, before %v after %v", before, after)
+	}
+	if after["mn1"] >= before["mn1"] {
+		t.Errorf("the most loaded metaNode should be drained, before %v after %v", before["mn1"], after["mn1"])
+	}
+}
@@ -113,6 +113,7 @@ func (mf *MetadataFsm) Apply(command []byte, index uint64) (resp interface{}, er
 	}
 
 	cmdMap := make(map[string][]byte)
+	var delKeys []string
 	if cmd.Op != opSyncBatchPut {
 		cmdMap[cmd.K] = cmd.V
 		cmdMap[applied] = []byte(strconv.FormatUint(uint64(index), 10))
@@ -123,18 +124,21 @@ func (mf *MetadataFsm) Apply(command []byte, index uint64) (resp interface{}, er
 			panic(err)
 		}
 		for cmdK, cmd := range nestedCmdMap {
+			if isDeleteOp(cmd.Op) {
+				delKeys = append(delKeys, cmdK)
+				continue
+			}
 			cmdMap[cmdK] = cmd.V
 		}
 		cmdMap[applied] = []byte(strconv.FormatUint(uint64(index), 10))
 	}
 
-	switch cmd.Op {
-	case opSyncDeleteDataNode, opSyncDeleteMetaNode, opSyncDeleteVol, opSyncDeleteDataPartition, opSyncDeleteMetaPartition,
-		opSyncDeleteUserInfo, opSyncDeleteAKUser, opSyncDeleteVolUser, opSyncDeleteQuota, opSyncDeleteLcNode, opSyncDeleteLcConf, opSyncS3QosDelete:
+	switch {
+	case isDeleteOp(cmd.Op):
 		if err = mf.delKeyAndPutIndex(cmd.K, cmdMap); err != nil {
 			panic(err)
 		}
-	case opSyncPutFollowerApiLimiterInfo, opSyncPutApiLimiterInfo:
+	case cmd.Op == opSyncPutFollowerApiLimiterInfo || cmd.Op == opSyncPutApiLimiterInfo:
 		mf.UserAppCmdHandler(cmd.Op, cmd.K, cmdMap)
 		//if err = mf.delKeyAndPutIndex(cmd.K, cmdMap); err != nil {
 		//	panic(err)
@@ -142,6 +146,11 @@ func (mf *MetadataFsm) Apply(command []byte, index uint64) (resp interface{}, er
 		if err = mf.store.BatchPut(cmdMap, true); err != nil {
 			panic(err)
 		}
+	case len(delKeys) > 0:
+		// a batch deleting some keys, e.g. the ones moved by renaming a vol
+		if err = mf.store.BatchDeleteAndPut(delKeys, cmdMap, true); err != nil {
+			panic(err)
+		}
 	default:
 		// sync put data
 		if err = mf.store.BatchPut(cmdMap, true); err != nil {
@@ -272,6 +281,16 @@ func (mf *MetadataFsm) HandleLeaderChange(leader uint64) {
 	}
 }
 
+// isDeleteOp returns if the raft command of op deletes its key.
+func isDeleteOp(op uint32) bool {
+	switch op {
+	case opSyncDeleteDataNode, opSyncDeleteMetaNode, opSyncDeleteVol, opSyncDeleteDataPartition, opSyncDeleteMetaPartition,
+		opSyncDeleteUserInfo, opSyncDeleteAKUser, opSyncDeleteVolUser, opSyncDeleteQuota, opSyncDeleteLcNode, opSyncDeleteLcConf, opSyncS3QosDelete:
+		return true
+	}
+	return false
+}
+
 func (mf *MetadataFsm) delKeyAndPutIndex(key string, cmdMap map[string][]byte) (err error) {
 	return mf.store.DeleteKeyAndPutIndex(key, cmdMap, true)
 }
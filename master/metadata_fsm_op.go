@@ -276,6 +276,12 @@ type volValue struct {
 	TxConflictRetryNum      int64
 	TxConflictRetryInterval int64
 	TxOpLimit               int
+	MinWriteQuorum          int
+	DisableTinyExtent       bool
+	CrcAlg                  uint8
+	QuotaByAllocated        bool
+	MaxFileSize             uint64
+	InodeDelayDeleteTime    int64
 
 	VolQosEnable                                           bool
 	DiskQosEnable                                          bool
@@ -319,6 +325,12 @@ func newVolValue(vol *Vol) (vv *volValue) {
 		TxConflictRetryNum:      vol.txConflictRetryNum,
 		TxConflictRetryInterval: vol.txConflictRetryInterval,
 		TxOpLimit:               vol.txOpLimit,
+		MinWriteQuorum:          vol.minWriteQuorum,
+		DisableTinyExtent:       vol.disableTinyExtent,
+		CrcAlg:                  vol.crcAlg,
+		QuotaByAllocated:        vol.quotaByAllocated,
+		MaxFileSize:             vol.maxFileSize,
+		InodeDelayDeleteTime:    vol.inodeDelayDeleteTime,
 
 		VolType:             vol.VolType,
 		EbsBlkSize:          vol.EbsBlkSize,
@@ -678,14 +690,22 @@ func (c *Cluster) sycnPutZoneInfo(zone *Zone) error {
 }
 
 func (c *Cluster) syncPutVolInfo(opType uint32, vol *Vol) (err error) {
-	metadata := new(RaftCmd)
+	metadata, err := c.buildVolRaftCmd(opType, vol)
+	if err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) buildVolRaftCmd(opType uint32, vol *Vol) (metadata *RaftCmd, err error) {
+	metadata = new(RaftCmd)
 	metadata.Op = opType
 	metadata.K = volPrefix + strconv.FormatUint(vol.ID, 10)
 	vv := newVolValue(vol)
 	if metadata.V, err = json.Marshal(vv); err != nil {
-		return errors.New(err.Error())
+		return metadata, errors.New(err.Error())
 	}
-	return c.submit(metadata)
+	return
 }
 
 func (c *Cluster) syncAclList(vol *Vol, val []byte) (err error) {
@@ -950,6 +970,7 @@ func (c *Cluster) loadZoneValue() (err error) {
 		if zone.GetMetaNodesetSelector() != cv.MetaNodesetSelector {
 			zone.metaNodesetSelector = NewNodesetSelector(cv.MetaNodesetSelector, MetaNodeType)
 		}
+		zone.applyDrain(cv.Drained)
 		log.LogInfof("action[loadZoneValue] load zonename[%v] with limit [%v,%v,%v,%v]",
 			zone.name, cv.QosFlowRLimit, cv.QosIopsWLimit, cv.QosFlowWLimit, cv.QosIopsRLimit)
 		zone.loadDataNodeQosLimit()
@@ -1379,6 +1400,18 @@ func (c *Cluster) loadVols() (err error) {
 	return
 }
 
+// getVolsByID returns the vols by their IDs. The partitions are loaded by the ID
+// of their vol, the name in their record may be the one before a rename.
+func (c *Cluster) getVolsByID() (vols map[uint64]*Vol) {
+	c.volMutex.RLock()
+	defer c.volMutex.RUnlock()
+	vols = make(map[uint64]*Vol, len(c.vols))
+	for _, vol := range c.vols {
+		vols[vol.ID] = vol
+	}
+	return
+}
+
 func (c *Cluster) loadMetaPartitions() (err error) {
 	result, err := c.fsm.store.SeekForPrefix([]byte(metaPartitionPrefix))
 	if err != nil {
@@ -1386,19 +1419,16 @@ func (c *Cluster) loadMetaPartitions() (err error) {
 		return err
 	}
 
+	vols := c.getVolsByID()
 	for _, value := range result {
 		mpv := &metaPartitionValue{}
 		if err = json.Unmarshal(value, mpv); err != nil {
 			err = fmt.Errorf("action[loadMetaPartitions],value:%v,unmarshal err:%v", string(value), err)
 			return err
 		}
-		vol, err1 := c.getVol(mpv.VolName)
-		if err1 != nil {
-			log.LogErrorf("action[loadMetaPartitions] err:%v", err1.Error())
-			continue
-		}
-		if vol.ID != mpv.VolID {
-			Warn(c.Name, fmt.Sprintf("action[loadMetaPartitions] has duplicate vol[%v],vol.gridId[%v],mpv.VolID[%v]", mpv.VolName, vol.ID, mpv.VolID))
+		vol, ok := vols[mpv.VolID]
+		if !ok {
+			log.LogErrorf("action[loadMetaPartitions] vol[%v] id[%v] mp[%v] not exist", mpv.VolName, mpv.VolID, mpv.PartitionID)
 			continue
 		}
 		for i := 0; i < len(mpv.Peers); i++ {
@@ -1433,6 +1463,7 @@ func (c *Cluster) loadDataPartitions() (err error) {
 		err = fmt.Errorf("action[loadDataPartitions],err:%v", err.Error())
 		return err
 	}
+	vols := c.getVolsByID()
 	for _, value := range result {
 
 		dpv := &dataPartitionValue{}
@@ -1440,15 +1471,12 @@ func (c *Cluster) loadDataPartitions() (err error) {
 			err = fmt.Errorf("action[loadDataPartitions],value:%v,unmarshal err:%v", string(value), err)
 			return err
 		}
-		vol, err1 := c.getVol(dpv.VolName)
-		if err1 != nil {
-			log.LogErrorf("action[loadDataPartitions] err:%v %v", dpv.VolName, err1.Error())
-			continue
-		}
-		if vol.ID != dpv.VolID {
-			Warn(c.Name, fmt.Sprintf("action[loadDataPartitions] has duplicate vol[%v],vol.gridId[%v],mpv.VolID[%v]", dpv.VolName, vol.ID, dpv.VolID))
+		vol, ok := vols[dpv.VolID]
+		if !ok {
+			log.LogErrorf("action[loadDataPartitions] vol[%v] id[%v] dp[%v] not exist", dpv.VolName, dpv.VolID, dpv.PartitionID)
 			continue
 		}
+		dpv.VolName = vol.Name
 
 		dp := dpv.Restore(c)
 		vol.dataPartitions.put(dp)
@@ -1680,14 +1708,22 @@ func (c *Cluster) syncUpdateLcConf(lcConf *bsProto.LcConfiguration) (err error)
 }
 
 func (c *Cluster) syncPutLcConfInfo(opType uint32, lcConf *bsProto.LcConfiguration) (err error) {
-	metadata := new(RaftCmd)
+	metadata, err := c.buildLcConfRaftCmd(opType, lcConf)
+	if err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) buildLcConfRaftCmd(opType uint32, lcConf *bsProto.LcConfiguration) (metadata *RaftCmd, err error) {
+	metadata = new(RaftCmd)
 	metadata.Op = opType
 	metadata.K = lcConfPrefix + lcConf.VolName
 	metadata.V, err = json.Marshal(lcConf)
 	if err != nil {
-		return errors.New(err.Error())
+		return metadata, errors.New(err.Error())
 	}
-	return c.submit(metadata)
+	return
 }
 
 func (c *Cluster) loadLcConfs() (err error) {
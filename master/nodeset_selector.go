@@ -17,10 +17,12 @@ package master
 import (
 	"math/rand"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
 )
 
 const RoundRobinNodesetSelectorName = "RoundRobin"
@@ -31,6 +33,10 @@ const AvailableSpaceFirstNodesetSelectorName = "AvailableSpaceFirst"
 
 const TicketNodesetSelectorName = "Ticket"
 
+const LeastUsedNodesetSelectorName = "LeastUsed"
+
+const ZoneSpreadNodesetSelectorName = "ZoneSpread"
+
 const DefaultNodesetSelectorName = RoundRobinNodeSelectorName
 
 func (ns *nodeSet) getDataNodeTotalSpace() (toalSpace uint64) {
@@ -74,6 +80,10 @@ func (ns *nodeSet) getMetaNodeTotalAvailableSpace() (space uint64) {
 }
 
 func (ns *nodeSet) canWriteFor(nodeType NodeType, replica int) bool {
+	// nodesets of a drained zone are never selected for new partitions
+	if ns.isDrained() {
+		return false
+	}
 	switch nodeType {
 	case DataNodeType:
 		return ns.canWriteForDataNode(replica)
@@ -202,8 +212,8 @@ func (s *CarryWeightNodesetSelector) Select(nsc nodeSetCollection, excludeNodeSe
 	})
 	// pick the first nodeset than has N writable node
 	for i := 0; i < nsc.Len(); i++ {
-		ns = nsc[i]
-		if ns.canWriteFor(s.nodeType, int(replicaNum)) && !containsID(excludeNodeSets, ns.ID) {
+		if nsc[i].canWriteFor(s.nodeType, int(replicaNum)) && !containsID(excludeNodeSets, nsc[i].ID) {
+			ns = nsc[i]
 			if i != 0 {
 				nsc[i], nsc[0] = nsc[0], nsc[i]
 			}
@@ -350,16 +360,184 @@ func NewTicketNodesetSelector(nodeType NodeType) *TicketNodesetSelector {
 	}
 }
 
-func NewNodesetSelector(name string, nodeType NodeType) NodesetSelector {
-	switch name {
-	case CarryWeightNodesetSelectorName:
-		return NewCarryWeightNodesetSelector(nodeType)
-	case RoundRobinNodesetSelectorName:
+type LeastUsedNodesetSelector struct {
+	nodeType NodeType
+}
+
+func (s *LeastUsedNodesetSelector) GetName() string {
+	return LeastUsedNodesetSelectorName
+}
+
+// getUsedRatio returns the used/total ratio of the nodeset, a nodeset without
+// space counts as full
+func (s *LeastUsedNodesetSelector) getUsedRatio(nset *nodeSet) float64 {
+	total := nset.getTotalSpaceOf(s.nodeType)
+	if total == 0 {
+		return 1
+	}
+	available := nset.getTotalAvailableSpaceOf(s.nodeType)
+	if available >= total {
+		return 0
+	}
+	return float64(total-available) / float64(total)
+}
+
+func (s *LeastUsedNodesetSelector) Select(nsc nodeSetCollection, excludeNodeSets []uint64, replicaNum uint8) (ns *nodeSet, err error) {
+	ratios := make(map[uint64]float64, nsc.Len())
+	for _, nset := range nsc {
+		ratios[nset.ID] = s.getUsedRatio(nset)
+	}
+	// sort nodesets by used ratio, by id on a tie to keep the order stable
+	sort.Slice(nsc, func(i, j int) bool {
+		if ratios[nsc[i].ID] == ratios[nsc[j].ID] {
+			return nsc[i].ID < nsc[j].ID
+		}
+		return ratios[nsc[i].ID] < ratios[nsc[j].ID]
+	})
+	// pick the first nodeset that has N writable nodes
+	for i := 0; i < nsc.Len(); i++ {
+		ns = nsc[i]
+		if ns.canWriteFor(s.nodeType, int(replicaNum)) && !containsID(excludeNodeSets, ns.ID) {
+			return
+		}
+	}
+	ns = nil
+	switch s.nodeType {
+	case DataNodeType:
+		err = errors.NewError(proto.ErrNoNodeSetToCreateDataPartition)
+	case MetaNodeType:
+		err = errors.NewError(proto.ErrNoNodeSetToCreateMetaPartition)
+	default:
+		panic("unknow node type")
+	}
+	return
+}
+
+func NewLeastUsedNodesetSelector(nodeType NodeType) *LeastUsedNodesetSelector {
+	return &LeastUsedNodesetSelector{
+		nodeType: nodeType,
+	}
+}
+
+// ZoneSpreadNodesetSelector places the replicas of a single partition, one Select per replica.
+// It prefers the nodesets whose zone holds the fewest replicas selected so far, so
+// the replicas land in distinct zones while there are enough of them, and spread
+// as evenly as possible over the zones otherwise.
+type ZoneSpreadNodesetSelector struct {
+	nodeType NodeType
+	// count of replicas already placed in each zone
+	usedZones map[string]int
+}
+
+func (s *ZoneSpreadNodesetSelector) GetName() string {
+	return ZoneSpreadNodesetSelectorName
+}
+
+// SetUsedZones records the zones already holding a replica of the partition being placed
+func (s *ZoneSpreadNodesetSelector) SetUsedZones(zones []string) {
+	s.usedZones = make(map[string]int, len(zones))
+	for _, zone := range zones {
+		s.usedZones[zone]++
+	}
+}
+
+func (s *ZoneSpreadNodesetSelector) GetUsedZones() (zones []string) {
+	for zone, cnt := range s.usedZones {
+		for i := 0; i < cnt; i++ {
+			zones = append(zones, zone)
+		}
+	}
+	sort.Strings(zones)
+	return
+}
+
+func (s *ZoneSpreadNodesetSelector) Select(nsc nodeSetCollection, excludeNodeSets []uint64, replicaNum uint8) (ns *nodeSet, err error) {
+	// sort nodesets by the replicas their zone already holds, then by available space
+	sort.Slice(nsc, func(i, j int) bool {
+		usedI, usedJ := s.usedZones[nsc[i].zoneName], s.usedZones[nsc[j].zoneName]
+		if usedI != usedJ {
+			return usedI < usedJ
+		}
+		spaceI, spaceJ := nsc[i].getTotalAvailableSpaceOf(s.nodeType), nsc[j].getTotalAvailableSpaceOf(s.nodeType)
+		if spaceI != spaceJ {
+			return spaceI > spaceJ
+		}
+		return nsc[i].ID < nsc[j].ID
+	})
+	// pick the first nodeset that has N writable nodes
+	for i := 0; i < nsc.Len(); i++ {
+		ns = nsc[i]
+		if ns.canWriteFor(s.nodeType, int(replicaNum)) && !containsID(excludeNodeSets, ns.ID) {
+			s.usedZones[ns.zoneName]++
+			return
+		}
+	}
+	ns = nil
+	switch s.nodeType {
+	case DataNodeType:
+		err = errors.NewError(proto.ErrNoNodeSetToCreateDataPartition)
+	case MetaNodeType:
+		err = errors.NewError(proto.ErrNoNodeSetToCreateMetaPartition)
+	default:
+		panic("unknow node type")
+	}
+	return
+}
+
+func NewZoneSpreadNodesetSelector(nodeType NodeType, usedZones []string) *ZoneSpreadNodesetSelector {
+	s := &ZoneSpreadNodesetSelector{
+		nodeType: nodeType,
+	}
+	s.SetUsedZones(usedZones)
+	return s
+}
+
+var (
+	nodesetSelectorRegistryLock sync.RWMutex
+	nodesetSelectorRegistry     = make(map[string]func(nodeType NodeType) NodesetSelector)
+)
+
+func init() {
+	RegisterNodesetSelector(RoundRobinNodesetSelectorName, func(nodeType NodeType) NodesetSelector {
 		return NewRoundRobinNodesetSelector(nodeType)
-	case TicketNodesetSelectorName:
+	})
+	RegisterNodesetSelector(CarryWeightNodesetSelectorName, func(nodeType NodeType) NodesetSelector {
+		return NewCarryWeightNodesetSelector(nodeType)
+	})
+	RegisterNodesetSelector(TicketNodesetSelectorName, func(nodeType NodeType) NodesetSelector {
 		return NewTicketNodesetSelector(nodeType)
-	case AvailableSpaceFirstNodesetSelectorName:
+	})
+	RegisterNodesetSelector(AvailableSpaceFirstNodesetSelectorName, func(nodeType NodeType) NodesetSelector {
 		return NewAvailableSpaceFirstNodesetSelector(nodeType)
+	})
+	RegisterNodesetSelector(LeastUsedNodesetSelectorName, func(nodeType NodeType) NodesetSelector {
+		return NewLeastUsedNodesetSelector(nodeType)
+	})
+}
+
+// RegisterNodesetSelector makes a nodeset selector available by name to NewNodesetSelector,
+// registering an existing name replaces the previous implementation
+func RegisterNodesetSelector(name string, newSelector func(nodeType NodeType) NodesetSelector) {
+	nodesetSelectorRegistryLock.Lock()
+	defer nodesetSelectorRegistryLock.Unlock()
+	nodesetSelectorRegistry[name] = newSelector
+}
+
+func getNodesetSelectorFactory(name string) (newSelector func(nodeType NodeType) NodesetSelector, ok bool) {
+	nodesetSelectorRegistryLock.RLock()
+	defer nodesetSelectorRegistryLock.RUnlock()
+	newSelector, ok = nodesetSelectorRegistry[name]
+	return
+}
+
+// NewNodesetSelector returns the selector registered under name, or the default one for an unknown name
+func NewNodesetSelector(name string, nodeType NodeType) NodesetSelector {
+	newSelector, ok := getNodesetSelectorFactory(name)
+	if !ok {
+		if name != "" {
+			log.LogWarnf("action[NewNodesetSelector] unknown nodeset selector[%v], use %v instead", name, DefaultNodesetSelectorName)
+		}
+		newSelector, _ = getNodesetSelectorFactory(DefaultNodesetSelectorName)
 	}
-	return NewRoundRobinNodesetSelector(nodeType)
+	return newSelector(nodeType)
 }
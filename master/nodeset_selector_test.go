@@ -17,9 +17,11 @@ package master
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/cubefs/cubefs/util"
+	"github.com/stretchr/testify/assert"
 )
 
 func writeNodeset(sb *strings.Builder, nset *nodeSet) {
@@ -95,3 +97,156 @@ func TestAvailableSpaceFirstNodesetSelector(t *testing.T) {
 	selector = NewAvailableSpaceFirstNodesetSelector(MetaNodeType)
 	NodesetSelectorTest(t, selector)
 }
+
+func TestLeastUsedNodesetSelector(t *testing.T) {
+	selector := NewLeastUsedNodesetSelector(DataNodeType)
+	NodesetSelectorTest(t, selector)
+	selector = NewLeastUsedNodesetSelector(MetaNodeType)
+	NodesetSelectorTest(t, selector)
+}
+
+func newFilledNodeset(id uint64, total, used uint64) *nodeSet {
+	nset := &nodeSet{ID: id, dataNodes: new(sync.Map), metaNodes: new(sync.Map)}
+	for i := 0; i < 3; i++ {
+		dataNode := newDataNode(fmt.Sprintf("192.168.%v.%v:17310", id, i), testZone1, "test")
+		dataNode.isActive = true
+		dataNode.Total = total
+		dataNode.AvailableSpace = total - used
+		nset.dataNodes.Store(dataNode.Addr, dataNode)
+	}
+	return nset
+}
+
+func TestLeastUsedNodesetSelectorFillLevels(t *testing.T) {
+	// the larger nodeset holds the most data but is the least full
+	nsc := nodeSetCollection{
+		newFilledNodeset(1, 100*util.GB, 50*util.GB),
+		newFilledNodeset(2, 400*util.GB, 80*util.GB),
+		newFilledNodeset(3, 100*util.GB, 30*util.GB),
+	}
+	selector := NewNodesetSelector(LeastUsedNodesetSelectorName, DataNodeType)
+	assert.Equal(t, LeastUsedNodesetSelectorName, selector.GetName())
+
+	ns, err := selector.Select(nsc, nil, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), ns.ID)
+
+	// the excluded sets are skipped
+	ns, err = selector.Select(nsc, []uint64{2}, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), ns.ID)
+
+	// so are the sets without enough writable nodes
+	ns, err = selector.Select(nsc, []uint64{2}, 4)
+	assert.Error(t, err)
+	assert.Nil(t, ns)
+	_, err = selector.Select(nsc, []uint64{1, 2, 3}, 3)
+	assert.Error(t, err)
+}
+
+func TestCarryWeightNodesetSelectorNoWritable(t *testing.T) {
+	nsc := nodeSetCollection{
+		newFilledNodeset(1, 100*util.GB, 50*util.GB),
+		newFilledNodeset(2, 100*util.GB, 30*util.GB),
+	}
+	selector := NewCarryWeightNodesetSelector(DataNodeType)
+	ns, err := selector.Select(nsc, []uint64{1}, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), ns.ID)
+
+	// no nodeset is returned when none can be written
+	ns, err = selector.Select(nsc, []uint64{1, 2}, 3)
+	assert.Error(t, err)
+	assert.Nil(t, ns)
+	ns, err = selector.Select(nsc, nil, 4)
+	assert.Error(t, err)
+	assert.Nil(t, ns)
+}
+
+func newZoneNodeset(id uint64, zoneName string, total, used uint64) *nodeSet {
+	nset := newFilledNodeset(id, total, used)
+	nset.zoneName = zoneName
+	return nset
+}
+
+func TestZoneSpreadNodesetSelectorDistinctZones(t *testing.T) {
+	nsc := nodeSetCollection{
+		newZoneNodeset(1, "zone1", 100*util.GB, 10*util.GB),
+		newZoneNodeset(2, "zone1", 100*util.GB, 20*util.GB),
+		newZoneNodeset(3, "zone2", 100*util.GB, 50*util.GB),
+		newZoneNodeset(4, "zone3", 100*util.GB, 60*util.GB),
+	}
+	selector := NewZoneSpreadNodesetSelector(DataNodeType, nil)
+	assert.Equal(t, ZoneSpreadNodesetSelectorName, selector.GetName())
+
+	// one replica per zone, even if zone1 has the most space left
+	zones := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		ns, err := selector.Select(nsc, nil, 1)
+		assert.NoError(t, err)
+		zones[ns.zoneName] = true
+	}
+	assert.Equal(t, 3, len(zones))
+	assert.Equal(t, []string{"zone1", "zone2", "zone3"}, selector.GetUsedZones())
+
+	// the zones already used by the partition are skipped
+	selector = NewZoneSpreadNodesetSelector(DataNodeType, []string{"zone1", "zone2"})
+	ns, err := selector.Select(nsc, nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), ns.ID)
+}
+
+func TestZoneSpreadNodesetSelectorFallback(t *testing.T) {
+	nsc := nodeSetCollection{
+		newZoneNodeset(1, "zone1", 100*util.GB, 10*util.GB),
+		newZoneNodeset(2, "zone2", 100*util.GB, 50*util.GB),
+	}
+	selector := NewZoneSpreadNodesetSelector(DataNodeType, nil)
+	ids := make([]uint64, 0, 3)
+	for i := 0; i < 3; i++ {
+		ns, err := selector.Select(nsc, nil, 1)
+		assert.NoError(t, err)
+		ids = append(ids, ns.ID)
+	}
+	// with two zones for three replicas, the third one goes to the zone
+	// with the most available space
+	assert.Equal(t, []uint64{1, 2, 1}, ids)
+	assert.Equal(t, []string{"zone1", "zone1", "zone2"}, selector.GetUsedZones())
+
+	// excluded nodesets are still honored
+	selector = NewZoneSpreadNodesetSelector(DataNodeType, nil)
+	_, err := selector.Select(nsc, []uint64{1, 2}, 1)
+	assert.Error(t, err)
+}
+
+func TestNodesetSelectorRegistry(t *testing.T) {
+	// the builtin selectors are looked up by name
+	for _, name := range []string{
+		RoundRobinNodesetSelectorName,
+		CarryWeightNodesetSelectorName,
+		TicketNodesetSelectorName,
+		AvailableSpaceFirstNodesetSelectorName,
+		LeastUsedNodesetSelectorName,
+	} {
+		assert.Equal(t, name, NewNodesetSelector(name, DataNodeType).GetName())
+		assert.Equal(t, name, NewNodesetSelector(name, MetaNodeType).GetName())
+	}
+
+	// an unknown name falls back to the default
+	assert.Equal(t, DefaultNodesetSelectorName, NewNodesetSelector("noSuchSelector", DataNodeType).GetName())
+	assert.Equal(t, DefaultNodesetSelectorName, NewNodesetSelector("", MetaNodeType).GetName())
+
+	// a registered selector is picked up by the factory
+	const name = "testZoneSpread"
+	RegisterNodesetSelector(name, func(nodeType NodeType) NodesetSelector {
+		return NewZoneSpreadNodesetSelector(nodeType, nil)
+	})
+	defer func() {
+		nodesetSelectorRegistryLock.Lock()
+		delete(nodesetSelectorRegistry, name)
+		nodesetSelectorRegistryLock.Unlock()
+	}()
+	selector := NewNodesetSelector(name, MetaNodeType)
+	assert.Equal(t, ZoneSpreadNodesetSelectorName, selector.GetName())
+	assert.Equal(t, MetaNodeType, selector.(*ZoneSpreadNodesetSelector).nodeType)
+}
@@ -956,6 +956,7 @@ type nodeSet struct {
 	startDecommissionDiskListTraverse chan struct{}
 	DecommissionDisks                 sync.Map
 	diskParallelFactorLk              sync.Mutex
+	drained                           int32 // set when the zone of the nodeset is drained
 }
 
 type nodeSetDecommissionParallelStatus struct {
@@ -1042,6 +1043,18 @@ func (ns *nodeSet) deleteMetaNode(metaNode *MetaNode) {
 	ns.metaNodes.Delete(metaNode.Addr)
 }
 
+func (ns *nodeSet) setDrained(drained bool) {
+	var val int32
+	if drained {
+		val = 1
+	}
+	atomic.StoreInt32(&ns.drained, val)
+}
+
+func (ns *nodeSet) isDrained() bool {
+	return atomic.LoadInt32(&ns.drained) == 1
+}
+
 func (ns *nodeSet) canWriteForDataNode(replicaNum int) bool {
 	var count int
 	ns.dataNodes.Range(func(key, value interface{}) bool {
@@ -1327,7 +1340,7 @@ func (t *topology) allocZonesForMetaNode(zoneNum, replicaNum int, excludeZone []
 		}
 		zone := zones[t.zoneIndexForMetaNode]
 		t.zoneIndexForMetaNode++
-		if zone.status == unavailableZone {
+		if zone.status == unavailableZone || zone.isDrained() {
 			continue
 		}
 		if contains(excludeZone, zone.name) {
@@ -1380,7 +1393,7 @@ func (t *topology) allocZonesForDataNode(zoneNum, replicaNum int, excludeZone []
 		zone := zones[t.zoneIndexForDataNode]
 		t.zoneIndexForDataNode++
 
-		if zone.status == unavailableZone {
+		if zone.status == unavailableZone || zone.isDrained() {
 			continue
 		}
 		if contains(excludeZone, zone.name) {
@@ -1430,6 +1443,7 @@ type Zone struct {
 	QosIopsWLimit           uint64
 	QosFlowRLimit           uint64
 	QosFlowWLimit           uint64
+	drained                 bool // no new partition is allocated in a drained zone
 	sync.RWMutex
 }
 type zoneValue struct {
@@ -1440,6 +1454,7 @@ type zoneValue struct {
 	QosFlowWLimit       uint64
 	DataNodesetSelector string
 	MetaNodesetSelector string
+	Drained             bool
 }
 
 func newZone(name string) (zone *Zone) {
@@ -1499,6 +1514,7 @@ func (zone *Zone) getFsmValue() *zoneValue {
 		QosFlowWLimit:       zone.QosFlowWLimit,
 		DataNodesetSelector: zone.GetDataNodesetSelector(),
 		MetaNodesetSelector: zone.GetMetaNodesetSelector(),
+		Drained:             zone.isDrained(),
 	}
 }
 
@@ -1518,6 +1534,33 @@ func (zone *Zone) getStatusToString() string {
 	}
 }
 
+func (zone *Zone) isDrained() bool {
+	zone.RLock()
+	defer zone.RUnlock()
+	return zone.drained
+}
+
+// applyDrain marks the zone and all its nodesets as drained or not,
+// existing partitions in the zone are not affected.
+func (zone *Zone) applyDrain(drained bool) {
+	zone.Lock()
+	zone.drained = drained
+	zone.Unlock()
+	zone.nsLock.RLock()
+	defer zone.nsLock.RUnlock()
+	for _, ns := range zone.nodeSetMap {
+		ns.setDrained(drained)
+	}
+}
+
+func (zone *Zone) updateDrain(cluster *Cluster, drained bool) error {
+	if drained == zone.isDrained() {
+		return nil
+	}
+	zone.applyDrain(drained)
+	return cluster.sycnPutZoneInfo(zone)
+}
+
 func (zone *Zone) isSingleNodeSet() bool {
 	zone.RLock()
 	defer zone.RUnlock()
@@ -1541,6 +1584,7 @@ func (zone *Zone) putNodeSet(ns *nodeSet) (err error) {
 	if _, ok := zone.nodeSetMap[ns.ID]; ok {
 		return fmt.Errorf("nodeSet [%v] has exist", ns.ID)
 	}
+	ns.setDrained(zone.isDrained())
 	zone.nodeSetMap[ns.ID] = ns
 	return
 }
@@ -408,6 +408,87 @@ func (u *User) deleteVolPolicy(volName string) (err error) {
 	return
 }
 
+// renameVolPolicy moves the ownership and the permissions of volName to newName in
+// memory, and adds the raft commands persisting the move to cmds. They are
+// committed with the vol by Cluster.renameVol, which calls undo if that fails.
+func (u *User) renameVolPolicy(cmds map[string]*RaftCmd, volName, newName string) (undo func(), err error) {
+	var (
+		cmd      *RaftCmd
+		userInfo *proto.UserInfo
+		userIDs  []string
+		renamed  []*proto.UserInfo
+	)
+	undo = func() {
+		for _, userInfo := range renamed {
+			userInfo.Mu.Lock()
+			userInfo.Policy.RenameVol(newName, volName)
+			userInfo.Mu.Unlock()
+		}
+	}
+	if userIDs, err = u.getUsersOfVol(volName); err != nil {
+		if err == proto.ErrHaveNoPolicy {
+			return undo, nil
+		}
+		return
+	}
+	for _, userID := range userIDs {
+		if userInfo, err = u.getUserInfo(userID); err != nil {
+			if err == proto.ErrUserNotExists {
+				log.LogWarnf("action[renameVolPolicy], userID: %v does not exist", userID)
+				continue
+			}
+			undo()
+			return
+		}
+		userInfo.Mu.Lock()
+		userInfo.Policy.RenameVol(volName, newName)
+		renamed = append(renamed, userInfo)
+		cmd, err = u.buildUserInfoRaftCmd(opSyncUpdateUserInfo, userInfo)
+		userInfo.Mu.Unlock()
+		if err != nil {
+			undo()
+			return
+		}
+		cmds[cmd.K] = cmd
+	}
+	err = nil
+
+	//move volName index
+	u.volUserMutex.Lock()
+	defer u.volUserMutex.Unlock()
+	value, exist := u.volUser.Load(volName)
+	if !exist {
+		return
+	}
+	volUser := value.(*proto.VolUser)
+	volUser.Mu.RLock()
+	newVolUser := &proto.VolUser{Vol: newName, UserIDs: volUser.UserIDs}
+	volUser.Mu.RUnlock()
+	if cmd, err = u.buildVolUserRaftCmd(opSyncAddVolUser, newVolUser); err != nil {
+		undo()
+		return
+	}
+	cmds[cmd.K] = cmd
+	// the old key is deleted in the same batch, see isDeleteOp
+	if cmd, err = u.buildVolUserRaftCmd(opSyncDeleteVolUser, volUser); err != nil {
+		undo()
+		return
+	}
+	cmds[cmd.K] = cmd
+	u.volUser.Store(newName, newVolUser)
+	u.volUser.Delete(volName)
+	undoPolicy := undo
+	undo = func() {
+		undoPolicy()
+		u.volUserMutex.Lock()
+		defer u.volUserMutex.Unlock()
+		u.volUser.Store(volName, volUser)
+		u.volUser.Delete(newName)
+	}
+	log.LogInfof("action[renameVolPolicy], volName: %v, newName: %v", volName, newName)
+	return
+}
+
 func (u *User) transferVol(params *proto.UserTransferVolParam) (targetUserInfo *proto.UserInfo, err error) {
 	var userInfo *proto.UserInfo
 	userInfo, err = u.getUserInfo(params.UserSrc)
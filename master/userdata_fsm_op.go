@@ -49,14 +49,22 @@ func (u *User) syncUpdateUserInfo(userInfo *proto.UserInfo) (err error) {
 }
 
 func (u *User) syncPutUserInfo(opType uint32, userInfo *proto.UserInfo) (err error) {
-	raftCmd := new(RaftCmd)
+	raftCmd, err := u.buildUserInfoRaftCmd(opType, userInfo)
+	if err != nil {
+		return
+	}
+	return u.submit(raftCmd)
+}
+
+func (u *User) buildUserInfoRaftCmd(opType uint32, userInfo *proto.UserInfo) (raftCmd *RaftCmd, err error) {
+	raftCmd = new(RaftCmd)
 	raftCmd.Op = opType
 	raftCmd.K = userPrefix + userInfo.UserID
 	raftCmd.V, err = json.Marshal(userInfo)
 	if err != nil {
-		return errors.New(err.Error())
+		return raftCmd, errors.New(err.Error())
 	}
-	return u.submit(raftCmd)
+	return
 }
 
 // key = #user#userid, value = userInfo
@@ -93,14 +101,22 @@ func (u *User) syncUpdateVolUser(volUser *proto.VolUser) (err error) {
 }
 
 func (u *User) syncPutVolUser(opType uint32, volUser *proto.VolUser) (err error) {
-	userInfo := new(RaftCmd)
+	userInfo, err := u.buildVolUserRaftCmd(opType, volUser)
+	if err != nil {
+		return
+	}
+	return u.submit(userInfo)
+}
+
+func (u *User) buildVolUserRaftCmd(opType uint32, volUser *proto.VolUser) (userInfo *RaftCmd, err error) {
+	userInfo = new(RaftCmd)
 	userInfo.Op = opType
 	userInfo.K = volUserPrefix + volUser.Vol
 	userInfo.V, err = json.Marshal(volUser)
 	if err != nil {
-		return errors.New(err.Error())
+		return userInfo, errors.New(err.Error())
 	}
-	return u.submit(userInfo)
+	return
 }
 
 func (u *User) loadUserStore() (err error) {
@@ -17,6 +17,7 @@ package master
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -47,6 +48,12 @@ type VolVarargs struct {
 	txConflictRetryNum      int64
 	txConflictRetryInterval int64
 	txOpLimit               int
+	minWriteQuorum          int
+	disableTinyExtent       bool
+	crcAlg                  uint8
+	quotaByAllocated        bool
+	maxFileSize             uint64
+	inodeDelayDeleteTime    int64
 }
 
 // Vol represents a set of meta partitionMap and data partitionMap
@@ -87,6 +94,12 @@ type Vol struct {
 	txConflictRetryNum      int64
 	txConflictRetryInterval int64
 	txOpLimit               int
+	minWriteQuorum          int    // replicas which must ack a write, 0 means all replicas
+	disableTinyExtent       bool   // clients write small files to normal extents too
+	crcAlg                  uint8  // checksum algorithm of the data packets of the clients
+	quotaByAllocated        bool   // quota charges the allocated bytes of files instead of their size
+	maxFileSize             uint64 // files can not grow beyond it, 0 means unlimited
+	inodeDelayDeleteTime    int64  // seconds unlinked inodes stay in the free list, 0 means the metanode default
 	zoneName                string
 	MetaPartitions          map[uint64]*MetaPartition `graphql:"-"`
 	mpsLock                 sync.RWMutex
@@ -145,6 +158,12 @@ func newVol(vv volValue) (vol *Vol) {
 	vol.txConflictRetryNum = vv.TxConflictRetryNum
 	vol.txConflictRetryInterval = vv.TxConflictRetryInterval
 	vol.txOpLimit = vv.TxOpLimit
+	vol.minWriteQuorum = vv.MinWriteQuorum
+	vol.disableTinyExtent = vv.DisableTinyExtent
+	vol.crcAlg = vv.CrcAlg
+	vol.quotaByAllocated = vv.QuotaByAllocated
+	vol.maxFileSize = vv.MaxFileSize
+	vol.inodeDelayDeleteTime = vv.InodeDelayDeleteTime
 
 	vol.VolType = vv.VolType
 	vol.EbsBlkSize = vv.EbsBlkSize
@@ -885,6 +904,47 @@ func (vol *Vol) getMetaPartitionsView() (mpViews []*proto.MetaPartitionView) {
 	return
 }
 
+// listDataPartitions returns up to limit data partitions of the given status
+// from offset, in the order of their IDs, and the number of the partitions of
+// the status. A zero status matches any status.
+func (vol *Vol) listDataPartitions(offset, limit int, status int8) (total int, dps []*proto.DataPartitionResponse) {
+	partitions := vol.dataPartitions.clonePartitions()
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].PartitionID < partitions[j].PartitionID })
+	dps = make([]*proto.DataPartitionResponse, 0)
+	for _, dp := range partitions {
+		if status != 0 && dp.Status != status {
+			continue
+		}
+		if total >= offset && len(dps) < limit {
+			dps = append(dps, dp.convertToDataPartitionResponse())
+		}
+		total++
+	}
+	return
+}
+
+// listMetaPartitions returns up to limit meta partitions of the given status
+// from offset, in the order of their IDs, and the number of the partitions of
+// the status. A zero status matches any status.
+func (vol *Vol) listMetaPartitions(offset, limit int, status int8) (total int, mps []*proto.MetaPartitionView) {
+	partitions := make([]*MetaPartition, 0)
+	for _, mp := range vol.cloneMetaPartitionMap() {
+		partitions = append(partitions, mp)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].PartitionID < partitions[j].PartitionID })
+	mps = make([]*proto.MetaPartitionView, 0)
+	for _, mp := range partitions {
+		if status != 0 && mp.Status != status {
+			continue
+		}
+		if total >= offset && len(mps) < limit {
+			mps = append(mps, getMetaPartitionView(mp))
+		}
+		total++
+	}
+	return
+}
+
 func (vol *Vol) setMpsCache(body []byte) {
 	vol.volLock.Lock()
 	defer vol.volLock.Unlock()
@@ -1289,6 +1349,12 @@ func setVolFromArgs(args *VolVarargs, vol *Vol) {
 	vol.txConflictRetryInterval = args.txConflictRetryInterval
 	vol.txOpLimit = args.txOpLimit
 	vol.dpReplicaNum = args.dpReplicaNum
+	vol.minWriteQuorum = args.minWriteQuorum
+	vol.disableTinyExtent = args.disableTinyExtent
+	vol.crcAlg = args.crcAlg
+	vol.quotaByAllocated = args.quotaByAllocated
+	vol.maxFileSize = args.maxFileSize
+	vol.inodeDelayDeleteTime = args.inodeDelayDeleteTime
 
 	if proto.IsCold(vol.VolType) {
 		coldArgs := args.coldArgs
@@ -1340,11 +1406,68 @@ func getVolVarargs(vol *Vol) *VolVarargs {
 		txConflictRetryNum:      vol.txConflictRetryNum,
 		txConflictRetryInterval: vol.txConflictRetryInterval,
 		txOpLimit:               vol.txOpLimit,
+		minWriteQuorum:          vol.minWriteQuorum,
+		disableTinyExtent:       vol.disableTinyExtent,
+		crcAlg:                  vol.crcAlg,
+		quotaByAllocated:        vol.quotaByAllocated,
+		maxFileSize:             vol.maxFileSize,
+		inodeDelayDeleteTime:    vol.inodeDelayDeleteTime,
 		coldArgs:                args,
 		dpReadOnlyWhenVolFull:   vol.DpReadOnlyWhenVolFull,
 	}
 }
 
+// getCloneVolConfigReq returns the request creating a new empty vol with the config of vol.
+func getCloneVolConfigReq(vol *Vol, name, owner string) *createVolReq {
+	return &createVolReq{
+		name:                    name,
+		owner:                   owner,
+		size:                    int(vol.dataPartitionSize / util.GB),
+		dpReplicaNum:            vol.dpReplicaNum,
+		capacity:                int(vol.Capacity),
+		deleteLockTime:          vol.DeleteLockTime,
+		followerRead:            vol.FollowerRead,
+		authenticate:            vol.authenticate,
+		crossZone:               vol.crossZone,
+		normalZonesFirst:        vol.defaultPriority,
+		domainId:                vol.domainId,
+		zoneName:                vol.zoneName,
+		description:             vol.description,
+		volType:                 vol.VolType,
+		enablePosixAcl:          vol.enablePosixAcl,
+		DpReadOnlyWhenVolFull:   vol.DpReadOnlyWhenVolFull,
+		enableTransaction:       vol.enableTransaction,
+		enableQuota:             vol.enableQuota,
+		txTimeout:               vol.txTimeout,
+		txConflictRetryNum:      vol.txConflictRetryNum,
+		txConflictRetryInterval: vol.txConflictRetryInterval,
+		minWriteQuorum:          vol.minWriteQuorum,
+		disableTinyExtent:       vol.disableTinyExtent,
+		crcAlg:                  vol.crcAlg,
+		quotaByAllocated:        vol.quotaByAllocated,
+		maxFileSize:             vol.maxFileSize,
+		inodeDelayDeleteTime:    vol.inodeDelayDeleteTime,
+		qosLimitArgs: &qosArgs{
+			qosEnable: vol.qosManager.qosEnable,
+			iopsRVal:  vol.qosManager.getQosLimit(proto.IopsReadType),
+			iopsWVal:  vol.qosManager.getQosLimit(proto.IopsWriteType),
+			flowRVal:  vol.qosManager.getQosLimit(proto.FlowReadType),
+			flowWVal:  vol.qosManager.getQosLimit(proto.FlowWriteType),
+		},
+		coldArgs: coldVolArgs{
+			objBlockSize:     vol.EbsBlkSize,
+			cacheCap:         vol.CacheCapacity,
+			cacheAction:      vol.CacheAction,
+			cacheThreshold:   vol.CacheThreshold,
+			cacheTtl:         vol.CacheTTL,
+			cacheHighWater:   vol.CacheHighWater,
+			cacheLowWater:    vol.CacheLowWater,
+			cacheLRUInterval: vol.CacheLRUInterval,
+			cacheRule:        vol.CacheRule,
+		},
+	}
+}
+
 func (vol *Vol) initQuotaManager(c *Cluster) {
 	vol.quotaManager = &MasterQuotaManager{
 		MpQuotaInfoMap: make(map[uint64][]*proto.QuotaReportInfo),
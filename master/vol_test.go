@@ -1,8 +1,11 @@
 package master
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
@@ -365,3 +368,486 @@ func TestConcurrentReadWriteDataPartitionMap(t *testing.T) {
 		vol.updateViewCache(server.cluster)
 	}
 }
+
+func TestVolMinWriteQuorum(t *testing.T) {
+	volName := "writeQuorumVol"
+	req := map[string]interface{}{
+		nameKey:           volName,
+		minWriteQuorumKey: 2,
+	}
+	checkCreateVolParam(minWriteQuorumKey, req, 4, 2, t)
+	createVol(req, t)
+	defer delVol(volName, t)
+
+	view := getSimpleVol(volName, true, t)
+	assert.Equal(t, 2, view.MinWriteQuorum)
+
+	updateReq := map[string]interface{}{
+		nameKey:    volName,
+		volAuthKey: buildAuthKey(testOwner),
+	}
+	checkUpdateVolParm(minWriteQuorumKey, updateReq, 4, 3, t)
+	checkUpdateVolParm(minWriteQuorumKey, updateReq, -1, 3, t)
+	setUpdateVolParm(minWriteQuorumKey, updateReq, 3, t)
+
+	view = getSimpleVol(volName, true, t)
+	assert.Equal(t, 3, view.MinWriteQuorum)
+	vol, err := server.cluster.getVol(volName)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, server.cluster.getVolWriteQuorums()[vol.ID])
+
+	// reduce replicaNum below the write quorum is not allowed
+	updateReq[replicaNumKey] = 2
+	updateReq[followerReadKey] = true
+	processWithFatalV2(proto.AdminUpdateVol, false, updateReq, t)
+}
+
+func TestVolDisableTinyExtent(t *testing.T) {
+	volName := "noTinyExtentVol"
+	req := map[string]interface{}{
+		nameKey:              volName,
+		disableTinyExtentKey: true,
+	}
+	checkCreateVolParam(disableTinyExtentKey, req, "no", true, t)
+	createVol(req, t)
+	defer delVol(volName, t)
+
+	view := getSimpleVol(volName, true, t)
+	assert.True(t, view.DisableTinyExtent)
+
+	updateReq := map[string]interface{}{
+		nameKey:    volName,
+		volAuthKey: buildAuthKey(testOwner),
+	}
+	// the setting is kept when the update doesn't carry it
+	setUpdateVolParm(descriptionKey, updateReq, "largeFiles", t)
+	assert.True(t, getSimpleVol(volName, true, t).DisableTinyExtent)
+
+	checkUpdateVolParm(disableTinyExtentKey, updateReq, "no", false, t)
+	setUpdateVolParm(disableTinyExtentKey, updateReq, false, t)
+	assert.False(t, getSimpleVol(volName, true, t).DisableTinyExtent)
+}
+
+func TestVolQuotaByAllocated(t *testing.T) {
+	volName := "allocatedQuotaVol"
+	req := map[string]interface{}{
+		nameKey:             volName,
+		quotaByAllocatedKey: true,
+	}
+	checkCreateVolParam(quotaByAllocatedKey, req, "no", true, t)
+	createVol(req, t)
+	defer delVol(volName, t)
+
+	assert.True(t, getSimpleVol(volName, true, t).QuotaByAllocated)
+
+	updateReq := map[string]interface{}{
+		nameKey:    volName,
+		volAuthKey: buildAuthKey(testOwner),
+	}
+	setUpdateVolParm(descriptionKey, updateReq, "sparseFiles", t)
+	assert.True(t, getSimpleVol(volName, true, t).QuotaByAllocated)
+
+	checkUpdateVolParm(quotaByAllocatedKey, updateReq, "no", false, t)
+	setUpdateVolParm(quotaByAllocatedKey, updateReq, false, t)
+	assert.False(t, getSimpleVol(volName, true, t).QuotaByAllocated)
+}
+
+func TestVolMaxFileSize(t *testing.T) {
+	volName := "maxFileSizeVol"
+	req := map[string]interface{}{
+		nameKey:        volName,
+		maxFileSizeKey: util.GB,
+	}
+	checkCreateVolParam(maxFileSizeKey, req, "big", util.GB, t)
+	checkCreateVolParam(maxFileSizeKey, req, 4096, util.GB, t)
+	createVol(req, t)
+	defer delVol(volName, t)
+
+	assert.Equal(t, uint64(util.GB), getSimpleVol(volName, true, t).MaxFileSize)
+
+	updateReq := map[string]interface{}{
+		nameKey:    volName,
+		volAuthKey: buildAuthKey(testOwner),
+	}
+	setUpdateVolParm(descriptionKey, updateReq, "limitedFiles", t)
+	assert.Equal(t, uint64(util.GB), getSimpleVol(volName, true, t).MaxFileSize)
+
+	checkUpdateVolParm(maxFileSizeKey, updateReq, -1, 0, t)
+	setUpdateVolParm(maxFileSizeKey, updateReq, 0, t)
+	assert.Equal(t, uint64(0), getSimpleVol(volName, true, t).MaxFileSize)
+}
+
+func TestCloneVolConfig(t *testing.T) {
+	srcName := "cloneSrcVol"
+	req := map[string]interface{}{
+		nameKey:              srcName,
+		descriptionKey:       "production",
+		disableTinyExtentKey: true,
+		maxFileSizeKey:       util.GB,
+	}
+	createVol(req, t)
+	defer delVol(srcName, t)
+
+	cloneName := "cloneDstVol"
+	cloneReq := map[string]interface{}{
+		nameKey: srcName,
+	}
+	checkParam(newNameKey, proto.AdminCloneVolConfig, cloneReq, "-bad", cloneName, t)
+	checkParam(nameKey, proto.AdminCloneVolConfig, cloneReq, "noSuchVol", srcName, t)
+	processWithFatalV2(proto.AdminCloneVolConfig, true, cloneReq, t)
+	defer delVol(cloneName, t)
+
+	// the vol exists already
+	processWithFatalV2(proto.AdminCloneVolConfig, false, cloneReq, t)
+
+	src := getSimpleVol(srcName, true, t)
+	clone := getSimpleVol(cloneName, true, t)
+	assert.Equal(t, cloneName, clone.Name)
+	assert.Equal(t, src.Owner, clone.Owner)
+	assert.Equal(t, src.Description, clone.Description)
+	assert.Equal(t, src.Capacity, clone.Capacity)
+	assert.Equal(t, src.DpReplicaNum, clone.DpReplicaNum)
+	assert.Equal(t, src.ZoneName, clone.ZoneName)
+	assert.Equal(t, src.VolType, clone.VolType)
+	assert.True(t, clone.DisableTinyExtent)
+	assert.Equal(t, uint64(util.GB), clone.MaxFileSize)
+	assert.NotEqual(t, src.ID, clone.ID)
+
+	// the clone is a vol of its own, updating it leaves the source alone
+	updateReq := map[string]interface{}{
+		nameKey:    cloneName,
+		volAuthKey: buildAuthKey(testOwner),
+	}
+	setUpdateVolParm(descriptionKey, updateReq, "testing", t)
+	assert.Equal(t, "testing", getSimpleVol(cloneName, true, t).Description)
+	assert.Equal(t, "production", getSimpleVol(srcName, true, t).Description)
+}
+
+func TestRenameVol(t *testing.T) {
+	name := "renameSrcVol"
+	createVol(map[string]interface{}{nameKey: name}, t)
+	vol, err := server.cluster.getVol(name)
+	assert.NoError(t, err)
+	volID := vol.ID
+	dpCount := len(vol.dataPartitions.clonePartitions())
+	mpCount := len(vol.cloneMetaPartitionMap())
+
+	newName := "renameDstVol"
+	req := map[string]interface{}{
+		nameKey:    name,
+		volAuthKey: buildAuthKey(testOwner),
+	}
+	checkParam(newNameKey, proto.AdminRenameVol, req, "-bad", newName, t)
+	checkParam(volAuthKey, proto.AdminRenameVol, req, buildAuthKey("noSuchOwner"), buildAuthKey(testOwner), t)
+	// the new name is taken by another vol
+	checkParam(newNameKey, proto.AdminRenameVol, req, commonVolName, newName, t)
+	processWithFatalV2(proto.AdminRenameVol, true, req, t)
+	defer delVol(newName, t)
+
+	// the old name is gone, the vol keeps its ID and partitions
+	getSimpleVol(name, false, t)
+	view := getSimpleVol(newName, true, t)
+	assert.Equal(t, newName, view.Name)
+	assert.Equal(t, volID, view.ID)
+	vol, err = server.cluster.getVol(newName)
+	assert.NoError(t, err)
+	assert.Len(t, vol.dataPartitions.clonePartitions(), dpCount)
+	for _, dp := range vol.dataPartitions.clonePartitions() {
+		assert.Equal(t, newName, dp.VolName)
+	}
+	assert.Len(t, vol.cloneMetaPartitionMap(), mpCount)
+	for _, mp := range vol.cloneMetaPartitionMap() {
+		assert.Equal(t, newName, mp.volName)
+	}
+
+	// the owner owns the vol under its new name
+	userInfo, err := server.user.getUserInfo(testOwner)
+	assert.NoError(t, err)
+	assert.True(t, userInfo.Policy.IsOwn(newName))
+	assert.False(t, userInfo.Policy.IsOwn(name))
+	_, ok := server.user.volUser.Load(newName)
+	assert.True(t, ok)
+	_, ok = server.user.volUser.Load(name)
+	assert.False(t, ok)
+
+	// the vol can not be renamed again under the old name
+	processWithFatalV2(proto.AdminRenameVol, false, req, t)
+}
+
+func TestParseCreateVolJSON(t *testing.T) {
+	form := url.Values{}
+	form.Set(nameKey, "jsonVol")
+	form.Set(volOwnerKey, testOwner)
+	form.Set(volCapacityKey, "300")
+	form.Set(volTypeKey, "0")
+	form.Set(replicaNumKey, "2")
+	form.Set(followerReadKey, "true")
+	form.Set(descriptionKey, "created from json")
+	form.Set(enableTxMaskKey, "create|mkdir")
+	form.Set(maxFileSizeKey, fmt.Sprint(util.GB))
+	formReq, err := http.NewRequest(http.MethodGet, proto.AdminCreateVol+"?"+form.Encode(), nil)
+	assert.NoError(t, err)
+	expected := &createVolReq{}
+	assert.NoError(t, parseRequestToCreateVol(formReq, expected))
+
+	body := map[string]interface{}{
+		nameKey:         "jsonVol",
+		volOwnerKey:     testOwner,
+		volCapacityKey:  300,
+		volTypeKey:      0,
+		replicaNumKey:   2,
+		followerReadKey: true,
+		descriptionKey:  "created from json",
+		enableTxMaskKey: "create|mkdir",
+		maxFileSizeKey:  util.GB,
+	}
+	data, err := json.Marshal(body)
+	assert.NoError(t, err)
+	jsonReq, err := http.NewRequest(http.MethodPost, proto.AdminCreateVol, bytes.NewReader(data))
+	assert.NoError(t, err)
+	jsonReq.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req := &createVolReq{}
+	assert.NoError(t, parseRequestToCreateVol(jsonReq, req))
+	assert.Equal(t, expected, req)
+	assert.Equal(t, "jsonVol", req.name)
+	assert.Equal(t, 300, req.capacity)
+	assert.Equal(t, uint64(util.GB), req.maxFileSize)
+
+	// the values are validated as in a form request
+	for key, value := range map[string]interface{}{
+		replicaNumKey:  300,
+		volCapacityKey: "large",
+		nameKey:        []string{"jsonVol"},
+	} {
+		invalid := map[string]interface{}{}
+		for k, v := range body {
+			invalid[k] = v
+		}
+		invalid[key] = value
+		data, err = json.Marshal(invalid)
+		assert.NoError(t, err)
+		jsonReq, err = http.NewRequest(http.MethodPost, proto.AdminCreateVol, bytes.NewReader(data))
+		assert.NoError(t, err)
+		jsonReq.Header.Set("Content-Type", "application/json")
+		assert.Error(t, parseRequestToCreateVol(jsonReq, &createVolReq{}), key)
+	}
+}
+
+func TestListPartitions(t *testing.T) {
+	vol, err := server.cluster.getVol(commonVolName)
+	assert.NoError(t, err)
+	dpCount := len(vol.dataPartitions.clonePartitions())
+	mpCount := len(vol.cloneMetaPartitionMap())
+	assert.True(t, dpCount > 1)
+
+	req := map[string]interface{}{nameKey: commonVolName}
+	checkParam(statusKey, proto.AdminListDataPartitions, req, "3", "1", t)
+	delete(req, statusKey)
+	processWithFatalV2(proto.AdminListDataPartitions, true, req, t)
+	processWithFatalV2(proto.AdminListMetaPartitions, true, req, t)
+
+	// the pages are sorted by ID and add up to the whole list
+	total, first := vol.listDataPartitions(0, 1, 0)
+	assert.Equal(t, dpCount, total)
+	assert.Len(t, first, 1)
+	_, rest := vol.listDataPartitions(1, maxListPartitionsLimit, 0)
+	assert.Len(t, rest, dpCount-1)
+	assert.True(t, first[0].PartitionID < rest[0].PartitionID)
+
+	// an offset past the end gives an empty page
+	total, dps := vol.listDataPartitions(dpCount, 10, 0)
+	assert.Equal(t, dpCount, total)
+	assert.Empty(t, dps)
+	total, mps := vol.listMetaPartitions(mpCount, 10, 0)
+	assert.Equal(t, mpCount, total)
+	assert.Empty(t, mps)
+
+	// only the partitions of the status are counted
+	dp := vol.dataPartitions.clonePartitions()[0]
+	status := dp.Status
+	dp.Status = proto.Unavailable
+	defer func() { dp.Status = status }()
+	total, dps = vol.listDataPartitions(0, maxListPartitionsLimit, proto.Unavailable)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, dp.PartitionID, dps[0].PartitionID)
+}
+
+func TestQosGetLimit(t *testing.T) {
+	type qosLimitStatus struct {
+		VolName   string
+		Host      string
+		Zone      string
+		QosEnable bool
+		LimitWork bool
+		IopsRVal  uint64
+		IopsWVal  uint64
+		FlowRVal  uint64
+		FlowWVal  uint64
+	}
+	getLimit := func(req map[string]interface{}) *qosLimitStatus {
+		reply := processWithFatalV2(proto.QosGetLimit, true, req, t)
+		status := &qosLimitStatus{}
+		assert.NoError(t, json.Unmarshal(reply.Data, status))
+		return status
+	}
+
+	name := "qosLimitVol"
+	createVol(map[string]interface{}{nameKey: name}, t)
+	defer delVol(name, t)
+
+	// a vol without limits
+	status := getLimit(map[string]interface{}{nameKey: name})
+	assert.Equal(t, name, status.VolName)
+	assert.False(t, status.LimitWork)
+
+	// the vol limits round-trip
+	processWithFatalV2(proto.QosUpdate, true, map[string]interface{}{
+		nameKey:      name,
+		QosEnableKey: true,
+		FlowRKey:     200,
+		FlowWKey:     300,
+	}, t)
+	status = getLimit(map[string]interface{}{nameKey: name})
+	assert.True(t, status.QosEnable)
+	assert.True(t, status.LimitWork)
+	assert.Equal(t, uint64(200*util.MB), status.FlowRVal)
+	assert.Equal(t, uint64(300*util.MB), status.FlowWVal)
+
+	// the limits assigned to the clients of a host
+	vol, err := server.cluster.getVol(name)
+	assert.NoError(t, err)
+	host := "192.168.0.1"
+	assign, err := vol.qosManager.initClientQosInfo(1, host)
+	assert.NoError(t, err)
+	status = getLimit(map[string]interface{}{nameKey: name, addrKey: host})
+	assert.Equal(t, host, status.Host)
+	assert.Equal(t, assign.FactorMap[proto.FlowReadType].UsedLimit, status.FlowRVal)
+	assert.Equal(t, assign.FactorMap[proto.FlowWriteType].UsedLimit, status.FlowWVal)
+	processWithFatalV2(proto.QosGetLimit, false, map[string]interface{}{nameKey: name, addrKey: "192.168.0.2"}, t)
+	processWithFatalV2(proto.QosGetLimit, false, map[string]interface{}{addrKey: host}, t)
+
+	// the cluster-wide limits of the data nodes of a zone
+	zoneName := server.cluster.t.getAllZones()[0].name
+	processWithFatalV2(proto.QosUpdateZoneLimit, true, map[string]interface{}{
+		zoneNameKey:   zoneName,
+		DiskEnableKey: true,
+		IopsRKey:      1000,
+		IopsWKey:      2000,
+	}, t)
+	defer processWithFatalV2(proto.QosUpdateZoneLimit, true, map[string]interface{}{
+		zoneNameKey:   zoneName,
+		DiskEnableKey: false,
+	}, t)
+	status = getLimit(map[string]interface{}{zoneNameKey: zoneName})
+	assert.Equal(t, zoneName, status.Zone)
+	assert.True(t, status.QosEnable)
+	assert.True(t, status.LimitWork)
+	assert.Equal(t, uint64(1000), status.IopsRVal)
+	assert.Equal(t, uint64(2000), status.IopsWVal)
+	processWithFatalV2(proto.QosGetLimit, false, map[string]interface{}{zoneNameKey: "noSuchZone"}, t)
+}
+
+func TestVolZoneNameValidation(t *testing.T) {
+	// the unknown zones are listed, on create and on update
+	req := map[string]interface{}{
+		nameKey:        "zoneCheckVol",
+		volTypeKey:     proto.VolumeTypeHot,
+		volOwnerKey:    testOwner,
+		volCapacityKey: 300,
+		replicaNumKey:  3,
+		crossZoneKey:   true,
+		zoneNameKey:    fmt.Sprintf("%v,noSuchZone1,noSuchZone2", testZone1),
+	}
+	reply := processWithFatalV2(proto.AdminCreateVol, false, req, t)
+	assert.Equal(t, int32(proto.ErrCodeParamError), reply.Code)
+	assert.Contains(t, reply.Msg, "zonename[noSuchZone1,noSuchZone2] not found")
+
+	// a single unknown zone fails as such even with cross zone set
+	req[zoneNameKey] = "noSuchZone1"
+	reply = processWithFatalV2(proto.AdminCreateVol, false, req, t)
+	assert.Contains(t, reply.Msg, "zonename[noSuchZone1] not found")
+
+	// a valid multi-zone string
+	req[zoneNameKey] = fmt.Sprintf("%v,%v", testZone1, testZone2)
+	processWithFatalV2(proto.AdminCreateVol, true, req, t)
+	defer delVol("zoneCheckVol", t)
+	view := getSimpleVol("zoneCheckVol", true, t)
+	assert.Equal(t, req[zoneNameKey], view.ZoneName)
+
+	update := map[string]interface{}{
+		nameKey:     "zoneCheckVol",
+		volAuthKey:  buildAuthKey(testOwner),
+		zoneNameKey: fmt.Sprintf("%v,noSuchZone1", testZone2),
+	}
+	reply = processWithFatalV2(proto.AdminUpdateVol, false, update, t)
+	assert.Contains(t, reply.Msg, "zonename[noSuchZone1] not found")
+}
+
+func TestCheckZoneNameDefault(t *testing.T) {
+	c := &Cluster{t: newTopology()}
+	assert.NoError(t, c.t.putZone(newZone(DefaultZoneName)))
+	assert.NoError(t, c.t.putZone(newZone(testZone1)))
+
+	// an empty zone name keeps using the default zone
+	zoneName, err := c.checkZoneName("defaultZoneVol", false, false, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultZoneName, zoneName)
+
+	err = c.checkNormalZoneName(fmt.Sprintf("%v,%v", DefaultZoneName, testZone1))
+	assert.NoError(t, err)
+	err = c.checkNormalZoneName(fmt.Sprintf("a,%v,b", testZone1))
+	assert.EqualError(t, err, "action[checkZoneName] the zonename[a,b] not found")
+}
+
+func TestVolInodeDelayDeleteTime(t *testing.T) {
+	volName := "inodeDelayDeleteVol"
+	req := map[string]interface{}{
+		nameKey:                 volName,
+		inodeDelayDeleteTimeKey: 3600,
+	}
+	checkCreateVolParam(inodeDelayDeleteTimeKey, req, "hour", 3600, t)
+	checkCreateVolParam(inodeDelayDeleteTimeKey, req, -1, 3600, t)
+	createVol(req, t)
+	defer delVol(volName, t)
+
+	assert.Equal(t, int64(3600), getSimpleVol(volName, true, t).InodeDelayDeleteTime)
+
+	updateReq := map[string]interface{}{
+		nameKey:    volName,
+		volAuthKey: buildAuthKey(testOwner),
+	}
+	setUpdateVolParm(descriptionKey, updateReq, "shortDelay", t)
+	assert.Equal(t, int64(3600), getSimpleVol(volName, true, t).InodeDelayDeleteTime)
+
+	checkUpdateVolParm(inodeDelayDeleteTimeKey, updateReq, -1, 60, t)
+	setUpdateVolParm(inodeDelayDeleteTimeKey, updateReq, 60, t)
+	assert.Equal(t, int64(60), getSimpleVol(volName, true, t).InodeDelayDeleteTime)
+	setUpdateVolParm(inodeDelayDeleteTimeKey, updateReq, 0, t)
+	assert.Equal(t, int64(0), getSimpleVol(volName, true, t).InodeDelayDeleteTime)
+}
+
+func TestVolCrcAlg(t *testing.T) {
+	volName := "crc32cVol"
+	req := map[string]interface{}{
+		nameKey:   volName,
+		crcAlgKey: proto.CrcAlgCastagnoli,
+	}
+	checkCreateVolParam(crcAlgKey, req, 2, proto.CrcAlgCastagnoli, t)
+	createVol(req, t)
+	defer delVol(volName, t)
+
+	view := getSimpleVol(volName, true, t)
+	assert.Equal(t, proto.CrcAlgCastagnoli, view.CrcAlg)
+
+	updateReq := map[string]interface{}{
+		nameKey:    volName,
+		volAuthKey: buildAuthKey(testOwner),
+	}
+	// the setting is kept when the update doesn't carry it
+	setUpdateVolParm(descriptionKey, updateReq, "crc32c", t)
+	assert.Equal(t, proto.CrcAlgCastagnoli, getSimpleVol(volName, true, t).CrcAlg)
+
+	checkUpdateVolParm(crcAlgKey, updateReq, 2, proto.CrcAlgIEEE, t)
+	setUpdateVolParm(crcAlgKey, updateReq, proto.CrcAlgIEEE, t)
+	assert.Equal(t, proto.CrcAlgIEEE, getSimpleVol(volName, true, t).CrcAlg)
+}
@@ -24,6 +24,7 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"time"
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/config"
@@ -62,9 +63,13 @@ func (m *MetaNode) registerAPIHandler() (err error) {
 	http.HandleFunc("/getEbsExtentsByInode", m.getEbsExtentsByInodeHandler)
 	// get all inodes of the partitionID
 	http.HandleFunc("/getAllInodes", m.getAllInodesHandler)
+	http.HandleFunc("/getInodeSizeMismatch", m.getInodeSizeMismatchHandler)
+	// delete the xattrs left by deleted inodes
+	http.HandleFunc("/gcOrphanExtends", m.gcOrphanExtendsHandler)
 	// get dentry information
 	http.HandleFunc("/getDentry", m.getDentryHandler)
 	http.HandleFunc("/getDirectory", m.getDirectoryHandler)
+	http.HandleFunc("/getDirChildCount", m.getDirChildCountHandler)
 	http.HandleFunc("/getAllDentry", m.getAllDentriesHandler)
 	http.HandleFunc("/getAllTxInfo", m.getAllTxHandler)
 	http.HandleFunc("/getParams", m.getParamsHandler)
@@ -229,6 +234,169 @@ func (m *MetaNode) getAllInodesHandler(w http.ResponseWriter, r *http.Request) {
 	mp.GetInodeTree().Ascend(f)
 }
 
+// InodeSizeMismatch is a regular file inode whose size disagrees with its extent keys.
+type InodeSizeMismatch struct {
+	Inode       uint64 `json:"ino"`
+	Size        uint64 `json:"size"`
+	ExtentsSize uint64 `json:"extentsSize"` // sum of the extent key sizes
+	ExtentsEnd  uint64 `json:"extentsEnd"`  // end offset of the last extent key
+	ExtentCnt   int    `json:"extentCnt"`
+}
+
+type InodeSizeMismatchResp struct {
+	Mismatches []*InodeSizeMismatch `json:"mismatches"`
+	Scanned    int                  `json:"scanned"`
+	NextMarker uint64               `json:"nextMarker"` // 0 if the scan reaches the end of the partition
+	TimedOut   bool                 `json:"timedOut"`   // the page ends early as the scan exceeds the timeout
+}
+
+// getInodeSizeMismatchHandler scans the inodes of a partition page by page, starting from "marker" and
+// at most "limit" inodes a time, and reports the ones whose size and extents size differ beyond "tolerance" bytes.
+// With "timeout" in milliseconds, the page ends once the scan takes longer, and the scan resumes from its next marker.
+func (m *MetaNode) getInodeSizeMismatchHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	resp := NewAPIResponse(http.StatusBadRequest, "")
+	defer func() {
+		data, _ := resp.Marshal()
+		if _, err := w.Write(data); err != nil {
+			log.LogErrorf("[getInodeSizeMismatchHandler] response %s", err)
+		}
+	}()
+	pid, err := strconv.ParseUint(r.FormValue("pid"), 10, 64)
+	if err != nil {
+		resp.Msg = err.Error()
+		return
+	}
+	var marker, tolerance uint64
+	var deadline time.Time
+	limit := defaultInodeScanLimit
+	if v := r.FormValue("marker"); v != "" {
+		if marker, err = strconv.ParseUint(v, 10, 64); err != nil {
+			resp.Msg = err.Error()
+			return
+		}
+	}
+	if v := r.FormValue("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			resp.Msg = fmt.Sprintf("invalid limit(%v)", v)
+			return
+		}
+	}
+	if v := r.FormValue("tolerance"); v != "" {
+		if tolerance, err = strconv.ParseUint(v, 10, 64); err != nil {
+			resp.Msg = err.Error()
+			return
+		}
+	}
+	if v := r.FormValue("timeout"); v != "" {
+		var timeout uint64
+		if timeout, err = strconv.ParseUint(v, 10, 32); err != nil || timeout == 0 {
+			resp.Msg = fmt.Sprintf("invalid timeout(%v)", v)
+			return
+		}
+		deadline = time.Now().Add(time.Duration(timeout) * time.Millisecond)
+	}
+	mp, err := m.metadataManager.GetPartition(pid)
+	if err != nil {
+		resp.Code = http.StatusNotFound
+		resp.Msg = err.Error()
+		return
+	}
+
+	resp.Data = scanInodeSizeMismatch(mp.GetInodeTree(), marker, limit, tolerance, deadline)
+	resp.Code = http.StatusOK
+	resp.Msg = "OK"
+}
+
+// scanInodeSizeMismatch scans at most limit inodes from marker. If deadline is not
+// zero, the scan stops once it passes, after one inode at least so that it makes
+// progress anyway.
+func scanInodeSizeMismatch(tree *BTree, marker uint64, limit int, tolerance uint64, deadline time.Time) *InodeSizeMismatchResp {
+	result := &InodeSizeMismatchResp{Mismatches: make([]*InodeSizeMismatch, 0)}
+	tree.AscendGreaterOrEqual(NewInode(marker, 0), func(i BtreeItem) bool {
+		ino := i.(*Inode)
+		if result.Scanned >= limit {
+			result.NextMarker = ino.Inode
+			return false
+		}
+		if result.Scanned > 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			result.NextMarker = ino.Inode
+			result.TimedOut = true
+			return false
+		}
+		result.Scanned++
+
+		ino.RLock()
+		defer ino.RUnlock()
+		// data of cold volume is kept in obj extents
+		if !proto.IsRegular(ino.Type) || (ino.ObjExtents != nil && ino.ObjExtents.Size() > 0) {
+			return true
+		}
+		mismatch := &InodeSizeMismatch{
+			Inode:       ino.Inode,
+			Size:        ino.Size,
+			ExtentsSize: ino.Extents.LayerSize(),
+			ExtentsEnd:  ino.Extents.Size(),
+			ExtentCnt:   ino.Extents.Len(),
+		}
+		diff := mismatch.Size - mismatch.ExtentsSize
+		if mismatch.ExtentsSize > mismatch.Size {
+			diff = mismatch.ExtentsSize - mismatch.Size
+		}
+		if diff > tolerance {
+			result.Mismatches = append(result.Mismatches, mismatch)
+		}
+		return true
+	})
+	return result
+}
+
+// gcOrphanExtendsHandler deletes the extends of a partition whose inode is gone, page by page
+// like getInodeSizeMismatchHandler, at most "limit" extends a time from the inode "marker".
+func (m *MetaNode) gcOrphanExtendsHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	resp := NewAPIResponse(http.StatusBadRequest, "")
+	defer func() {
+		data, _ := resp.Marshal()
+		if _, err := w.Write(data); err != nil {
+			log.LogErrorf("[gcOrphanExtendsHandler] response %s", err)
+		}
+	}()
+	pid, err := strconv.ParseUint(r.FormValue("pid"), 10, 64)
+	if err != nil {
+		resp.Msg = err.Error()
+		return
+	}
+	var marker uint64
+	limit := defaultInodeScanLimit
+	if v := r.FormValue("marker"); v != "" {
+		if marker, err = strconv.ParseUint(v, 10, 64); err != nil {
+			resp.Msg = err.Error()
+			return
+		}
+	}
+	if v := r.FormValue("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			resp.Msg = fmt.Sprintf("invalid limit(%v)", v)
+			return
+		}
+	}
+	mp, err := m.metadataManager.GetPartition(pid)
+	if err != nil {
+		resp.Code = http.StatusNotFound
+		resp.Msg = err.Error()
+		return
+	}
+
+	if resp.Data, err = mp.GCOrphanExtends(marker, limit); err != nil {
+		resp.Code = http.StatusInternalServerError
+		resp.Msg = err.Error()
+		return
+	}
+	resp.Code = http.StatusOK
+	resp.Msg = "OK"
+}
+
 func (m *MetaNode) getSplitKeyHandler(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 	log.LogDebugf("getSplitKeyHandler")
@@ -796,6 +964,46 @@ func (m *MetaNode) getDirectoryHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (m *MetaNode) getDirChildCountHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	resp := NewAPIResponse(http.StatusBadRequest, "")
+	defer func() {
+		data, _ := resp.Marshal()
+		if _, err := w.Write(data); err != nil {
+			log.LogErrorf("[getDirChildCountHandler] response %s", err)
+		}
+	}()
+	pid, err := strconv.ParseUint(r.FormValue("pid"), 10, 64)
+	if err != nil {
+		resp.Msg = err.Error()
+		return
+	}
+	id, err := strconv.ParseUint(r.FormValue("ino"), 10, 64)
+	if err != nil {
+		resp.Msg = err.Error()
+		return
+	}
+	mp, err := m.metadataManager.GetPartition(pid)
+	if err != nil {
+		resp.Code = http.StatusNotFound
+		resp.Msg = err.Error()
+		return
+	}
+
+	count, err := mp.GetDirChildCount(id)
+	if err != nil {
+		resp.Code = http.StatusNotFound
+		resp.Msg = err.Error()
+		return
+	}
+	resp.Code = http.StatusOK
+	resp.Msg = "OK"
+	resp.Data = map[string]interface{}{
+		"ino":        id,
+		"childCount": count,
+	}
+}
+
 func (m *MetaNode) genClusterVersionFileHandler(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 	resp := NewAPIResponse(http.StatusOK, "Generate cluster version file success")
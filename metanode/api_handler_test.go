@@ -3,6 +3,7 @@ package metanode
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,7 +11,9 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
+	"github.com/cubefs/cubefs/proto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -161,3 +164,124 @@ func TestWithWrongMetaPartitionID(t *testing.T) {
 	data := httpReqHandle(url, t)
 	require.Contains(t, string(data), "unknown meta partition")
 }
+
+func TestGetInodeSizeMismatch(t *testing.T) {
+	testPath := "/tmp/testMetaNodeApiHandler/"
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+
+	mp := createMetaPartition(testPath, t)
+	require.NotNil(t, mp)
+
+	addFile := func(ino, size uint64, ekSizes ...uint32) {
+		inode := NewInode(ino, 0644)
+		inode.Size = size
+		offset := uint64(0)
+		for i, ekSize := range ekSizes {
+			inode.Extents.Append(proto.ExtentKey{FileOffset: offset, PartitionId: 1, ExtentId: ino*10 + uint64(i), Size: ekSize})
+			offset += uint64(ekSize)
+		}
+		mp.inodeTree.ReplaceOrInsert(inode, true)
+	}
+	addFile(10, 4096)             // nonzero size without extents
+	addFile(11, 8192, 4096, 4096) // consistent
+	addFile(12, 8192, 4096)       // size larger than extents
+	addFile(13, 0, 4096)          // extents without size
+	addFile(14, 4100, 4096)       // within tolerance
+	mp.inodeTree.ReplaceOrInsert(NewInode(15, uint32(os.ModeDir)), true)
+
+	scan := func(marker uint64, limit int) (result *InodeSizeMismatchResp) {
+		url := fmt.Sprintf("http://127.0.0.1:%v/getInodeSizeMismatch?pid=%v&marker=%v&limit=%v&tolerance=16",
+			PROF_PORT, METAPARTITION_ID, marker, limit)
+		result = &InodeSizeMismatchResp{}
+		resp := &APIResponse{Data: result}
+		require.NoError(t, json.Unmarshal(httpReqHandle(url, t), resp))
+		require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+		return
+	}
+
+	// scan the partition page by page
+	var found []uint64
+	var marker uint64
+	pages := 0
+	for {
+		result := scan(marker, 2)
+		pages++
+		for _, m := range result.Mismatches {
+			found = append(found, m.Inode)
+			switch m.Inode {
+			case 10:
+				require.Equal(t, uint64(4096), m.Size)
+				require.Equal(t, uint64(0), m.ExtentsSize)
+				require.Equal(t, 0, m.ExtentCnt)
+			case 12:
+				require.Equal(t, uint64(8192), m.Size)
+				require.Equal(t, uint64(4096), m.ExtentsSize)
+			case 13:
+				require.Equal(t, uint64(0), m.Size)
+				require.Equal(t, uint64(4096), m.ExtentsEnd)
+			}
+		}
+		if result.NextMarker == 0 {
+			break
+		}
+		marker = result.NextMarker
+	}
+	require.Equal(t, []uint64{10, 12, 13}, found)
+	require.Equal(t, 4, pages)
+
+	url := fmt.Sprintf("http://127.0.0.1:%v/getInodeSizeMismatch?pid=%v&limit=0", PROF_PORT, METAPARTITION_ID)
+	require.Contains(t, string(httpReqHandle(url, t)), "invalid limit")
+	url = fmt.Sprintf("http://127.0.0.1:%v/getInodeSizeMismatch?pid=%v&timeout=0", PROF_PORT, METAPARTITION_ID)
+	require.Contains(t, string(httpReqHandle(url, t)), "invalid timeout")
+}
+
+func TestScanInodeSizeMismatchDeadline(t *testing.T) {
+	const inodeCnt = 200000
+	tree := NewBtree()
+	var expected []uint64
+	for ino := uint64(1); ino <= inodeCnt; ino++ {
+		inode := NewInode(ino, 0644)
+		inode.Extents.Append(proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: ino, Size: 4096})
+		inode.Size = 4096
+		if ino%1000 == 0 {
+			inode.Size = 8192
+			expected = append(expected, ino)
+		}
+		tree.ReplaceOrInsert(inode, true)
+	}
+
+	// an expired deadline still scans one inode, and resumes from the next one
+	result := scanInodeSizeMismatch(tree, 0, inodeCnt, 0, time.Now().Add(-time.Second))
+	require.True(t, result.TimedOut)
+	require.Equal(t, 1, result.Scanned)
+	require.Equal(t, uint64(2), result.NextMarker)
+
+	// a short deadline returns partial pages, the markers complete the scan
+	var found []uint64
+	var marker uint64
+	scanned, partial := 0, 0
+	for {
+		result = scanInodeSizeMismatch(tree, marker, inodeCnt, 0, time.Now().Add(time.Millisecond))
+		scanned += result.Scanned
+		for _, m := range result.Mismatches {
+			found = append(found, m.Inode)
+		}
+		if result.TimedOut {
+			// the inodes are numbered from 1 without holes
+			first := marker
+			if first == 0 {
+				first = 1
+			}
+			partial++
+			require.Equal(t, first+uint64(result.Scanned), result.NextMarker)
+		}
+		if result.NextMarker == 0 {
+			break
+		}
+		marker = result.NextMarker
+	}
+	require.Equal(t, inodeCnt, scanned)
+	require.Equal(t, expected, found)
+	require.Greater(t, partial, 0)
+}
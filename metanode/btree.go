@@ -35,8 +35,17 @@ type BTree struct {
 
 // NewBtree creates a new btree.
 func NewBtree() *BTree {
+	return NewBtreeWithDegree(defaultBTreeDegree)
+}
+
+// NewBtreeWithDegree creates a new btree of the given degree, a larger degree means
+// fewer and larger nodes. A degree less than 2 is not valid and the default is used.
+func NewBtreeWithDegree(degree int) *BTree {
+	if degree < 2 {
+		degree = defaultBTreeDegree
+	}
 	return &BTree{
-		tree: btree.New(defaultBTreeDegree),
+		tree: btree.New(degree),
 	}
 }
 
@@ -140,14 +149,46 @@ func (b *BTree) AscendGreaterOrEqual(pivot BtreeItem, iterator func(i BtreeItem)
 	b.RUnlock()
 }
 
+// CountRange returns the number of items in the range [greaterOrEqual, lessThan),
+// only the items of the range are visited.
+func (b *BTree) CountRange(greaterOrEqual, lessThan BtreeItem) (count int) {
+	b.RLock()
+	b.tree.AscendRange(greaterOrEqual, lessThan, func(i BtreeItem) bool {
+		count++
+		return true
+	})
+	b.RUnlock()
+	return
+}
+
+// Descend is the wrapper of the google's btree Descend.
+// Like Ascend it scans the entire btree, it is recommended to do it on the snapshot returned by GetTree.
+func (b *BTree) Descend(fn func(i BtreeItem) bool) {
+	b.RLock()
+	b.tree.Descend(fn)
+	b.RUnlock()
+}
+
+// DescendRange is the wrapper of the google's btree DescendRange.
+func (b *BTree) DescendRange(lessOrEqual, greaterThan BtreeItem, iterator func(i BtreeItem) bool) {
+	b.RLock()
+	b.tree.DescendRange(lessOrEqual, greaterThan, iterator)
+	b.RUnlock()
+}
+
+// DescendLessOrEqual is the wrapper of the google's btree DescendLessOrEqual.
+func (b *BTree) DescendLessOrEqual(pivot BtreeItem, iterator func(i BtreeItem) bool) {
+	b.RLock()
+	b.tree.DescendLessOrEqual(pivot, iterator)
+	b.RUnlock()
+}
+
 // GetTree returns the snapshot of a btree.
 func (b *BTree) GetTree() *BTree {
 	b.Lock()
 	t := b.tree.Clone()
 	b.Unlock()
-	nb := NewBtree()
-	nb.tree = t
-	return nb
+	return &BTree{tree: t}
 }
 
 // Reset resets the current btree.
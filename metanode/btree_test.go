@@ -53,3 +53,95 @@ func TestBtree(t *testing.T) {
 	item = bt.Get(key2)
 	require.Nil(t, item)
 }
+
+func TestBtreeDescend(t *testing.T) {
+	bt := NewBtree()
+	for i := 0; i < 100; i++ {
+		bt.ReplaceOrInsert(&testItem{data: i * 2}, true)
+	}
+	collect := func(scan func(fn func(i BtreeItem) bool)) (res []int) {
+		scan(func(i BtreeItem) bool {
+			res = append(res, i.(*testItem).data)
+			return true
+		})
+		return
+	}
+	reverse := func(s []int) []int {
+		for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+			s[i], s[j] = s[j], s[i]
+		}
+		return s
+	}
+
+	require.Equal(t, reverse(collect(bt.Ascend)), collect(bt.Descend))
+	require.Equal(t,
+		reverse(collect(func(fn func(i BtreeItem) bool) {
+			bt.AscendRange(&testItem{data: 21}, &testItem{data: 81}, fn)
+		})),
+		collect(func(fn func(i BtreeItem) bool) {
+			bt.DescendRange(&testItem{data: 80}, &testItem{data: 20}, fn)
+		}))
+	require.Equal(t,
+		reverse(collect(func(fn func(i BtreeItem) bool) {
+			bt.AscendRange(&testItem{data: 0}, &testItem{data: 51}, fn)
+		})),
+		collect(func(fn func(i BtreeItem) bool) {
+			bt.DescendLessOrEqual(&testItem{data: 51}, fn)
+		}))
+
+	// the iteration stops when the iterator returns false
+	var res []int
+	bt.Descend(func(i BtreeItem) bool {
+		res = append(res, i.(*testItem).data)
+		return len(res) < 3
+	})
+	require.Equal(t, []int{198, 196, 194}, res)
+}
+
+func TestBtreeWithDegree(t *testing.T) {
+	for _, degree := range []int{0, 2, 3, 32, 128} {
+		bt := NewBtreeWithDegree(degree)
+		for i := 999; i >= 0; i-- {
+			bt.ReplaceOrInsert(&testItem{data: i}, true)
+		}
+		require.Equal(t, 1000, bt.Len())
+		for i := 0; i < 1000; i += 2 {
+			require.NotNil(t, bt.Delete(&testItem{data: i}))
+		}
+		var prev = -1
+		bt.GetTree().Ascend(func(i BtreeItem) bool {
+			data := i.(*testItem).data
+			require.Equal(t, 1, data%2)
+			require.Greater(t, data, prev)
+			prev = data
+			return true
+		})
+		require.Equal(t, 500, bt.Len())
+		require.Equal(t, 999, bt.MaxItem().(*testItem).data)
+	}
+}
+
+func benchmarkBtreeDegree(b *testing.B, degree int) {
+	items := make([]*testItem, 100000)
+	for i := range items {
+		items[i] = &testItem{data: (i * 7919) % len(items)}
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		bt := NewBtreeWithDegree(degree)
+		for _, item := range items {
+			bt.ReplaceOrInsert(item, true)
+		}
+		for _, item := range items {
+			bt.Get(item)
+		}
+	}
+}
+
+func BenchmarkBtreeDegree32(b *testing.B) {
+	benchmarkBtreeDegree(b, 32)
+}
+
+func BenchmarkBtreeDegree128(b *testing.B) {
+	benchmarkBtreeDegree(b, 128)
+}
@@ -61,10 +61,12 @@ type (
 	ReadDirReq      = proto.ReadDirRequest
 	ReadDirOnlyReq  = proto.ReadDirOnlyRequest
 	ReadDirLimitReq = proto.ReadDirLimitRequest
+	ReadDirPlusReq  = proto.ReadDirPlusRequest
 	// MetaNode -> Client read dir response
 	ReadDirResp      = proto.ReadDirResponse
 	ReadDirOnlyResp  = proto.ReadDirOnlyResponse
 	ReadDirLimitResp = proto.ReadDirLimitResponse
+	ReadDirPlusResp  = proto.ReadDirPlusResponse
 
 	// MetaNode -> Client lookup
 	LookupReq = proto.LookupRequest
@@ -185,6 +187,14 @@ const (
 
 	opFSMSentToChanV1 = 71
 	opFSMStoreTickV1  = 72
+
+	opFSMSetDentryAttr = 73
+	opFSMTxSetAttr     = 74
+	opFSMSetInodeFlags = 75
+
+	opFSMQuotaByAllocated    = 76
+	opFSMDeleteOrphanExtends = 77
+	opFSMCloneInode          = 78
 )
 
 var (
@@ -225,6 +235,7 @@ const (
 	cfgRetainLogs                = "retainLogs"                //string, raft RetainLogs
 	cfgRaftSyncSnapFormatVersion = "raftSyncSnapFormatVersion" //int, format version of snapshot that raft leader sent to follower
 	cfgServiceIDKey              = "serviceIDKey"
+	cfgBTreeDegree               = "btreeDegree" //int, degree of the in-memory trees of the meta partitions
 
 	metaNodeDeleteBatchCountKey = "batchCount"
 	configNameResolveInterval   = "nameResolveInterval" // int
@@ -238,6 +249,8 @@ const (
 	defaultDelExtentsCnt         = 100000
 	defaultMaxQuotaGoroutine     = 5
 	defaultQuotaSwitch           = true
+	defaultSubtreeQuotaLimit     = 1024
+	defaultInodeScanLimit        = 10000
 	DefaultNameResolveInterval   = 1 // minutes
 	DefaultRaftNumOfLogsToRetain = 20000 * 2
 )
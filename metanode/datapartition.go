@@ -49,6 +49,10 @@ type Vol struct {
 	sync.RWMutex
 	dataPartitionView map[uint64]*DataPartition
 	volDeleteLockTime int64
+	quotaByAllocated  bool   // setting of the volume, see syncQuotaByAllocated
+	maxFileSize       uint64 // files can not grow beyond it, 0 means unlimited
+	// seconds an unlinked inode stays in the free list, 0 means InodeNLink0DelayDeleteSeconds
+	inodeDelayDeleteTime int64
 }
 
 // NewVol returns a new volume instance.
@@ -22,6 +22,7 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/log"
 	"math"
+	"sort"
 )
 
 // Dentry wraps necessary properties of the `dentry` information in file system.
@@ -32,11 +33,19 @@ import (
 //  | bytes |    8     | rest |
 //  +-------+----------+------+
 // Marshal value:
-//  +-------+-------+------+
-//  | item  | Inode | Type |
-//  +-------+-------+------+
-//  | bytes |   8   |   4  |
-//  +-------+-------+------+
+//  +-------+-------+------+-----+--------+-------------+---------+-------+
+//  | item  | Inode | Type | Seq | VerCnt | VerCnt*Snap | AttrCnt | Attrs |
+//  +-------+-------+------+-----+--------+-------------+---------+-------+
+//  | bytes |   8   |   4  |  8  |    4   |  VerCnt*20  |    4    | rest  |
+//  +-------+-------+------+-----+--------+-------------+---------+-------+
+// Seq and the following items are written only for versioned dentries or
+// dentries with attributes, attrs are written only if there are any.
+// Marshal attr:
+//  +-------+--------+-----+----------+-------+
+//  | item  | KeyLen | Key | ValueLen | Value |
+//  +-------+--------+-----+----------+-------+
+//  | bytes |    4   | rest|     4    |  rest |
+//  +-------+--------+-----+----------+-------+
 // Marshal entity:
 //  +-------+-----------+--------------+-----------+--------------+
 //  | item  | KeyLength | MarshaledKey | ValLength | MarshaledVal |
@@ -56,6 +65,10 @@ type Dentry struct {
 	Type     uint32
 	//snapshot
 	multiSnap *DentryMultiSnap
+	// attrs are the attributes of the dentry itself, they are not shared with
+	// other dentries of the same inode. The map is replaced instead of updated
+	// in place since dentry copies share it.
+	attrs map[string]string
 }
 
 func NewDentrySnap(seq uint64) *DentryMultiSnap {
@@ -64,6 +77,29 @@ func NewDentrySnap(seq uint64) *DentryMultiSnap {
 	}
 }
 
+func (d *Dentry) getAttr(key string) (val string, ok bool) {
+	val, ok = d.attrs[key]
+	return
+}
+
+func (d *Dentry) setAttr(key, val string) {
+	attrs := make(map[string]string, len(d.attrs)+1)
+	for k, v := range d.attrs {
+		attrs[k] = v
+	}
+	attrs[key] = val
+	d.attrs = attrs
+}
+
+func (d *Dentry) listAttrs() (keys []string) {
+	keys = make([]string, 0, len(d.attrs))
+	for k := range d.attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
 func (d *Dentry) getSnapListLen() int {
 	if d.multiSnap == nil {
 		return 0
@@ -660,7 +696,7 @@ func (d *Dentry) MarshalValue() []byte {
 	writeBinary(&d.Inode)
 	writeBinary(&d.Type)
 	seq := d.getSeqFiled()
-	if seq == 0 {
+	if seq == 0 && len(d.attrs) == 0 {
 		return buff.Bytes()
 	}
 	writeBinary(&seq)
@@ -677,6 +713,19 @@ func (d *Dentry) MarshalValue() []byte {
 		}
 	}
 
+	if len(d.attrs) == 0 {
+		return buff.Bytes()
+	}
+	attrCnt := uint32(len(d.attrs))
+	writeBinary(&attrCnt)
+	for _, k := range d.listAttrs() {
+		v := d.attrs[k]
+		writeBinary(uint32(len(k)))
+		buff.WriteString(k)
+		writeBinary(uint32(len(v)))
+		buff.WriteString(v)
+	}
+
 	return buff.Bytes()
 }
 
@@ -722,7 +771,44 @@ func (d *Dentry) UnmarshalValue(val []byte) (err error) {
 			}
 			d.multiSnap.dentryList = append(d.multiSnap.dentryList, den)
 		}
+		if d.multiSnap.VerSeq == 0 && verCnt == 0 {
+			// the seq section is written for the attrs only
+			d.multiSnap = nil
+		}
 	}
 
+	if buff.Len() > 0 {
+		err = d.unmarshalAttrs(buff)
+	}
+	return
+}
+
+func (d *Dentry) unmarshalAttrs(buff *bytes.Buffer) (err error) {
+	readString := func() (str string, err error) {
+		var l uint32
+		if err = binary.Read(buff, binary.BigEndian, &l); err != nil {
+			return
+		}
+		if int(l) > buff.Len() {
+			return "", fmt.Errorf("attr length %v exceeds the remaining %v bytes", l, buff.Len())
+		}
+		return string(buff.Next(int(l))), nil
+	}
+
+	var attrCnt uint32
+	if err = binary.Read(buff, binary.BigEndian, &attrCnt); err != nil {
+		return
+	}
+	d.attrs = make(map[string]string, attrCnt)
+	for i := 0; i < int(attrCnt); i++ {
+		var k, v string
+		if k, err = readString(); err != nil {
+			return
+		}
+		if v, err = readString(); err != nil {
+			return
+		}
+		d.attrs[k] = v
+	}
 	return
 }
@@ -20,7 +20,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestExtend_Bytes(t *testing.T) {
@@ -55,3 +58,42 @@ func TestExtend_Bytes(t *testing.T) {
 	}
 
 }
+
+func TestGCOrphanExtends(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mp := mockPartitionRaftForQuotaTest(mockCtrl)
+	mp.config.NodeId = 1
+	mp.multiVersionList = &proto.VolVersionInfoList{}
+
+	// 10 is alive, 11 is deleted but not freed yet, the inodes of 12 and 13 are gone
+	mp.inodeTree.ReplaceOrInsert(NewInode(10, FileModeType), true)
+	deleted := NewInode(11, FileModeType)
+	deleted.SetDeleteMark()
+	mp.inodeTree.ReplaceOrInsert(deleted, true)
+	for ino := uint64(10); ino <= 13; ino++ {
+		extend := NewExtend(ino)
+		extend.Put([]byte("user.tag"), []byte("v"), 0)
+		mp.extendTree.ReplaceOrInsert(extend, true)
+	}
+
+	resp, err := mp.GCOrphanExtends(0, 2)
+	require.NoError(t, err)
+	require.Equal(t, &OrphanExtendGCResp{Scanned: 2, Reclaimed: 0, NextMarker: 12}, resp)
+
+	resp, err = mp.GCOrphanExtends(resp.NextMarker, 2)
+	require.NoError(t, err)
+	require.Equal(t, &OrphanExtendGCResp{Scanned: 2, Reclaimed: 2, NextMarker: 0}, resp)
+
+	kept := make([]uint64, 0)
+	mp.extendTree.Ascend(func(i BtreeItem) bool {
+		kept = append(kept, i.(*Extend).inode)
+		return true
+	})
+	require.Equal(t, []uint64{10, 11}, kept)
+
+	// followers only run it through raft
+	mp.config.NodeId = 2
+	_, err = mp.GCOrphanExtends(0, 2)
+	require.Error(t, err)
+}
@@ -29,8 +29,13 @@ import (
 )
 
 const (
-	DeleteMarkFlag = 1 << 0
-	InodeDelTop    = 1 << 1
+	DeleteMarkFlag  = 1 << 0
+	InodeDelTop     = 1 << 1
+	InodeImmutable  = 1 << 2
+	InodeAppendOnly = 1 << 3
+	// InodeSharedExtents is set on both the source and the clone of fsmCloneInode,
+	// the extents of such an inode may be referenced by another inode.
+	InodeSharedExtents = 1 << 4
 )
 
 var (
@@ -1699,6 +1704,18 @@ func (i *Inode) GetNLink() uint32 {
 	return i.NLink
 }
 
+// GetChildCount returns the number of entries in the dir, the dentry ops keep
+// it in the nlink of the dir on top of the links of "." and "..". An entry
+// removed by a pending transaction is counted until the transaction commits.
+func (i *Inode) GetChildCount() uint32 {
+	i.RLock()
+	defer i.RUnlock()
+	if !proto.IsDir(i.Type) || i.NLink < 2 {
+		return 0
+	}
+	return i.NLink - 2
+}
+
 func (i *Inode) IsTempFile() bool {
 	i.RLock()
 	ok := i.NLink == 0 && !proto.IsDir(i.Type)
@@ -1742,15 +1759,79 @@ func (i *Inode) ShouldDelete() (ok bool) {
 	return
 }
 
+// attrFlags converts the immutable and append-only bits of Flag to proto.InodeFlag*.
+// The caller must hold the inode lock.
+func (i *Inode) attrFlags() (flags uint32) {
+	if i.Flag&InodeImmutable != 0 {
+		flags |= proto.InodeFlagImmutable
+	}
+	if i.Flag&InodeAppendOnly != 0 {
+		flags |= proto.InodeFlagAppendOnly
+	}
+	return
+}
+
+// SetAttrFlags replaces the immutable and append-only bits of the inode.
+func (i *Inode) SetAttrFlags(flags uint32) {
+	i.Lock()
+	defer i.Unlock()
+	i.Flag &^= InodeImmutable | InodeAppendOnly
+	if flags&proto.InodeFlagImmutable != 0 {
+		i.Flag |= InodeImmutable
+	}
+	if flags&proto.InodeFlagAppendOnly != 0 {
+		i.Flag |= InodeAppendOnly
+	}
+}
+
+// IsImmutable returns if the inode can not be modified, linked or removed.
+func (i *Inode) IsImmutable() bool {
+	i.RLock()
+	defer i.RUnlock()
+	return i.Flag&InodeImmutable != 0
+}
+
+// IsSharedExtents returns if the inode has been cloned or is a clone.
+func (i *Inode) IsSharedExtents() bool {
+	i.RLock()
+	defer i.RUnlock()
+	return i.Flag&InodeSharedExtents != 0
+}
+
+// SetSharedExtents marks the extents of the inode as possibly shared. The
+// generation is bumped as well, so that the clients holding the extents reload
+// them with the shared marks.
+func (i *Inode) SetSharedExtents() {
+	i.Lock()
+	i.Flag |= InodeSharedExtents
+	i.Generation++
+	i.Unlock()
+}
+
+// IsAppendOnly returns if the inode only accepts writes beyond its size.
+func (i *Inode) IsAppendOnly() bool {
+	i.RLock()
+	defer i.RUnlock()
+	return i.Flag&InodeAppendOnly != 0
+}
+
+// IsProtected returns if either the immutable or the append-only flag is set,
+// both of them forbid truncate, link, unlink, rename and setattr.
+func (i *Inode) IsProtected() bool {
+	i.RLock()
+	defer i.RUnlock()
+	return i.Flag&(InodeImmutable|InodeAppendOnly) != 0
+}
+
 // inode should delay remove if as 3 conditions:
 // 1. DeleteMarkFlag is unset
 // 2. NLink == 0
-// 3. AccessTime is 7 days ago
-func (i *Inode) ShouldDelayDelete() (ok bool) {
+// 3. AccessTime is within delaySeconds
+func (i *Inode) ShouldDelayDelete(delaySeconds int64) (ok bool) {
 	i.RLock()
 	ok = (i.Flag&DeleteMarkFlag != DeleteMarkFlag) &&
 		(i.NLink == 0) &&
-		time.Now().Unix()-i.AccessTime < InodeNLink0DelayDeleteSeconds
+		time.Now().Unix()-i.AccessTime < delaySeconds
 	i.RUnlock()
 	return
 }
@@ -1779,6 +1860,10 @@ func (i *Inode) SetAttr(req *SetattrRequest) {
 	if req.Valid&proto.AttrModifyTime != 0 {
 		i.ModifyTime = req.ModifyTime
 	}
+	// CreateTime is the ctime reported to the clients
+	if req.Valid&proto.AttrChangeTime != 0 {
+		i.CreateTime = req.ChangeTime
+	}
 
 	i.Unlock()
 }
@@ -61,6 +61,8 @@ type MetadataManagerConfig struct {
 	RootDir   string
 	ZoneName  string
 	RaftStore raftstore.RaftStore
+	// BTreeDegree is the degree of the in-memory trees of the meta partitions.
+	BTreeDegree int
 }
 
 type verOp2Phase struct {
@@ -88,6 +90,7 @@ type metadataManager struct {
 	cpuUtil              atomicutil.Float64
 	samplerDone          chan struct{}
 	volUpdating          *sync.Map //map[string]*verOp2Phase
+	btreeDegree          int
 }
 
 func (m *metadataManager) getPacketLabels(p *Packet) (labels map[string]string) {
@@ -170,6 +173,8 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opReadDirOnly(conn, p, remoteAddr)
 	case proto.OpMetaReadDirLimit:
 		err = m.opReadDirLimit(conn, p, remoteAddr)
+	case proto.OpMetaReadDirPlus:
+		err = m.opReadDirPlus(conn, p, remoteAddr)
 	case proto.OpCreateMetaPartition:
 		err = m.opCreateMetaPartition(conn, p, remoteAddr)
 	case proto.OpMetaNodeHeartbeat:
@@ -231,6 +236,13 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opMetaListXAttr(conn, p, remoteAddr)
 	case proto.OpMetaUpdateXAttr:
 		err = m.opMetaUpdateXAttr(conn, p, remoteAddr)
+	// operations for dentry attributes
+	case proto.OpMetaSetDentryAttr:
+		err = m.opMetaSetDentryAttr(conn, p, remoteAddr)
+	case proto.OpMetaGetDentryAttr:
+		err = m.opMetaGetDentryAttr(conn, p, remoteAddr)
+	case proto.OpMetaListDentryAttr:
+		err = m.opMetaListDentryAttr(conn, p, remoteAddr)
 	// operations for multipart session
 	case proto.OpCreateMultipart:
 		err = m.opCreateMultipart(conn, p, remoteAddr)
@@ -268,12 +280,22 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opTxUpdateDentry(conn, p, remoteAddr)
 	case proto.OpMetaTxLinkInode:
 		err = m.opTxMetaLinkInode(conn, p, remoteAddr)
+	case proto.OpMetaTxSetAttr:
+		err = m.opTxMetaSetAttr(conn, p, remoteAddr)
+	case proto.OpMetaSetInodeFlags:
+		err = m.opMetaSetInodeFlags(conn, p, remoteAddr)
+	case proto.OpMetaCloneInode:
+		err = m.opMetaCloneInode(conn, p, remoteAddr)
+	case proto.OpMetaGetChangeEvents:
+		err = m.opMetaGetChangeEvents(conn, p, remoteAddr)
 	case proto.OpMetaBatchSetInodeQuota:
 		err = m.opMetaBatchSetInodeQuota(conn, p, remoteAddr)
 	case proto.OpMetaBatchDeleteInodeQuota:
 		err = m.opMetaBatchDeleteInodeQuota(conn, p, remoteAddr)
 	case proto.OpMetaGetInodeQuota:
 		err = m.opMetaGetInodeQuota(conn, p, remoteAddr)
+	case proto.OpMetaSetSubtreeQuota:
+		err = m.opMetaSetSubtreeQuota(conn, p, remoteAddr)
 	case proto.OpQuotaCreateInode:
 		err = m.opQuotaCreateInode(conn, p, remoteAddr)
 	case proto.OpQuotaCreateDentry:
@@ -452,6 +474,7 @@ func (m *metadataManager) loadPartitions() (err error) {
 					RaftStore:   m.raftStore,
 					RootDir:     path.Join(m.rootDir, fileName),
 					ConnPool:    m.connPool,
+					BTreeDegree: m.btreeDegree,
 				}
 				partitionConfig.AfterStop = func() {
 					m.detachPartition(id)
@@ -535,6 +558,7 @@ func (m *metadataManager) createPartition(request *proto.CreateMetaPartitionRequ
 		RootDir:     path.Join(m.rootDir, partitionPrefix+partitionId),
 		ConnPool:    m.connPool,
 		VerSeq:      request.VerSeq,
+		BTreeDegree: m.btreeDegree,
 	}
 	mpc.AfterStop = func() {
 		m.detachPartition(request.PartitionID)
@@ -646,6 +670,7 @@ func NewMetadataManager(conf MetadataManagerConfig, metaNode *MetaNode) Metadata
 		metaNode:             metaNode,
 		maxQuotaGoroutineNum: defaultMaxQuotaGoroutine,
 		volUpdating:          new(sync.Map),
+		btreeDegree:          conf.BTreeDegree,
 	}
 }
 
@@ -29,13 +29,23 @@ type MetaQuotaManager struct {
 	statisticRebuildTemp *sync.Map // key quotaId, value proto.QuotaUsedInfo
 	statisticRebuildBase *sync.Map // key quotaId, value proto.QuotaUsedInfo
 	limitedMap           *sync.Map
+	maxInfoMap           *sync.Map // key quotaId, value quotaMaxInfo
 	rbuilding            bool
+	rbuildStale          bool // the usage was reset while rebuilding
 	volName              string
 	rwlock               sync.RWMutex
 	mpID                 uint64
 	enable               bool
 }
 
+// quotaMaxInfo is the limit of a quota and the usage of the whole volume the
+// master knew about when sending it.
+type quotaMaxInfo struct {
+	maxFiles uint64
+	maxBytes uint64
+	usedInfo proto.QuotaUsedInfo
+}
+
 type MetaQuotaInode struct {
 	inode    *Inode
 	quotaIds []uint32
@@ -53,6 +63,7 @@ func NewQuotaManager(volName string, mpId uint64) (mqMgr *MetaQuotaManager) {
 		statisticRebuildTemp: new(sync.Map),
 		statisticRebuildBase: new(sync.Map),
 		limitedMap:           new(sync.Map),
+		maxInfoMap:           new(sync.Map),
 		volName:              volName,
 		mpID:                 mpId,
 	}
@@ -177,6 +188,16 @@ func (mqMgr *MetaQuotaManager) setQuotaHbInfo(infos []*proto.QuotaHeartBeatInfo)
 		}
 		mqMgr.enable = info.Enable
 		mqMgr.limitedMap.Store(info.QuotaId, info.LimitedInfo)
+		// masters before the hard limit do not send the max values
+		if info.MaxFiles != 0 || info.MaxBytes != 0 {
+			mqMgr.maxInfoMap.Store(info.QuotaId, quotaMaxInfo{
+				maxFiles: info.MaxFiles,
+				maxBytes: info.MaxBytes,
+				usedInfo: info.UsedInfo,
+			})
+		} else {
+			mqMgr.maxInfoMap.Delete(info.QuotaId)
+		}
 		log.LogDebugf("mp [%v] quotaId [%v] limitedInfo [%v]", mqMgr.mpID, info.QuotaId, info.LimitedInfo)
 	}
 	mqMgr.limitedMap.Range(func(key, value interface{}) bool {
@@ -195,6 +216,7 @@ func (mqMgr *MetaQuotaManager) setQuotaHbInfo(infos []*proto.QuotaHeartBeatInfo)
 
 		if !found {
 			mqMgr.limitedMap.Delete(quotaId)
+			mqMgr.maxInfoMap.Delete(quotaId)
 		}
 		return true
 	})
@@ -260,7 +282,8 @@ func (mqMgr *MetaQuotaManager) statisticRebuildFin(rebuild bool) {
 	mqMgr.rwlock.Lock()
 	defer mqMgr.rwlock.Unlock()
 	mqMgr.rbuilding = false
-	if !rebuild {
+	if !rebuild || mqMgr.rbuildStale {
+		mqMgr.rbuildStale = false
 		mqMgr.statisticRebuildBase = new(sync.Map)
 		mqMgr.statisticRebuildTemp = new(sync.Map)
 		return
@@ -286,6 +309,18 @@ func (mqMgr *MetaQuotaManager) statisticRebuildFin(rebuild bool) {
 	}
 }
 
+// statisticReset replaces the usage with base. A rebuild running meanwhile is
+// dropped when it finishes since it counted part of the inodes the old way.
+func (mqMgr *MetaQuotaManager) statisticReset(base *sync.Map) {
+	mqMgr.rwlock.Lock()
+	defer mqMgr.rwlock.Unlock()
+	mqMgr.statisticBase = base
+	mqMgr.statisticTemp = new(sync.Map)
+	if mqMgr.rbuilding {
+		mqMgr.rbuildStale = true
+	}
+}
+
 func (mqMgr *MetaQuotaManager) IsOverQuota(size bool, files bool, quotaId uint32) (status uint8) {
 	var limitedInfo proto.QuotaLimitedInfo
 	mqMgr.rwlock.RLock()
@@ -309,6 +344,37 @@ func (mqMgr *MetaQuotaManager) IsOverQuota(size bool, files bool, quotaId uint32
 	return
 }
 
+// IsOverQuotaLimit returns OpDirQuota if adding size bytes and files files would
+// exceed the MaxBytes or MaxFiles of the quota. The usage is the one of the whole
+// volume from the last heartbeat of the master plus the changes of this partition
+// not reported yet, so it is exact only as long as this partition is the writer.
+func (mqMgr *MetaQuotaManager) IsOverQuotaLimit(size int64, files int64, quotaId uint32) (status uint8) {
+	mqMgr.rwlock.RLock()
+	defer mqMgr.rwlock.RUnlock()
+	if !mqMgr.enable || (size <= 0 && files <= 0) {
+		return
+	}
+	value, isFind := mqMgr.maxInfoMap.Load(quotaId)
+	if !isFind {
+		return
+	}
+	maxInfo := value.(quotaMaxInfo)
+	usedInfo := maxInfo.usedInfo
+	if value, isFind = mqMgr.statisticTemp.Load(quotaId); isFind {
+		tempInfo := value.(proto.QuotaUsedInfo)
+		usedInfo.Add(&tempInfo)
+	}
+	if size > 0 && usedInfo.UsedBytes+size > 0 && uint64(usedInfo.UsedBytes+size) > maxInfo.maxBytes {
+		status = proto.OpDirQuota
+	}
+	if files > 0 && usedInfo.UsedFiles+files > 0 && uint64(usedInfo.UsedFiles+files) > maxInfo.maxFiles {
+		status = proto.OpDirQuota
+	}
+	log.LogInfof("IsOverQuotaLimit quotaId [%v] maxInfo [%v] usedInfo [%v] size [%v] files [%v] status [%v]",
+		quotaId, maxInfo, usedInfo, size, files, status)
+	return
+}
+
 func (mqMgr *MetaQuotaManager) updateUsedInfo(size int64, files int64, quotaId uint32) {
 	var baseInfo proto.QuotaUsedInfo
 	var baseTemp proto.QuotaUsedInfo
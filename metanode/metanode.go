@@ -75,6 +75,7 @@ type MetaNode struct {
 	clusterUuid               string
 	clusterUuidEnable         bool
 	serviceIDKey              string
+	btreeDegree               int
 
 	control common.Control
 }
@@ -218,6 +219,13 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 
 	m.serviceIDKey = cfg.GetString(cfgServiceIDKey)
 
+	m.btreeDegree = defaultBTreeDegree
+	if cfg.HasKey(cfgBTreeDegree) {
+		if m.btreeDegree = int(cfg.GetInt64(cfgBTreeDegree)); m.btreeDegree < 2 {
+			return fmt.Errorf("bad %v config %v, should be at least 2", cfgBTreeDegree, m.btreeDegree)
+		}
+	}
+
 	total, _, err := util.GetMemInfo()
 	if err != nil {
 		log.LogErrorf("get total mem failed, err %s", err.Error())
@@ -305,6 +313,7 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 	log.LogInfof("[parseConfig] load raftHeartbeatPort[%v].", m.raftHeartbeatPort)
 	log.LogInfof("[parseConfig] load raftReplicatePort[%v].", m.raftReplicatePort)
 	log.LogInfof("[parseConfig] load zoneName[%v].", m.zoneName)
+	log.LogInfof("[parseConfig] load btreeDegree[%v].", m.btreeDegree)
 
 	if err = m.parseSmuxConfig(cfg); err != nil {
 		return fmt.Errorf("parseSmuxConfig fail err %v", err)
@@ -422,10 +431,11 @@ func (m *MetaNode) newMetaManager() (err error) {
 
 	// load metadataManager
 	conf := MetadataManagerConfig{
-		NodeID:    m.nodeId,
-		RootDir:   m.metadataDir,
-		RaftStore: m.raftStore,
-		ZoneName:  m.zoneName,
+		NodeID:      m.nodeId,
+		RootDir:     m.metadataDir,
+		RaftStore:   m.raftStore,
+		ZoneName:    m.zoneName,
+		BTreeDegree: m.btreeDegree,
 	}
 	m.metadataManager = NewMetadataManager(conf, m)
 	return
@@ -92,6 +92,11 @@ type MetaPartitionConfig struct {
 	AfterStop     func()              `json:"-"`
 	RaftStore     raftstore.RaftStore `json:"-"`
 	ConnPool      *util.ConnectPool   `json:"-"`
+	BTreeDegree   int                 `json:"-"` // degree of the in-memory trees, the default if 0
+
+	// quota charges the allocated bytes of files, see quotaSize. It is only
+	// changed by the raft log, thus all the replicas charge in the same way.
+	QuotaByAllocated bool `json:"quota_by_allocated"`
 }
 
 func (c *MetaPartitionConfig) checkMeta() (err error) {
@@ -141,6 +146,10 @@ type OpInode interface {
 	TxCreateInode(req *proto.TxCreateInodeRequest, p *Packet) (err error)
 	TxUnlinkInode(req *proto.TxUnlinkInodeRequest, p *Packet) (err error)
 	TxCreateInodeLink(req *proto.TxLinkInodeRequest, p *Packet) (err error)
+	TxSetAttr(req *proto.TxSetAttrRequest, p *Packet) (err error)
+	SetInodeFlags(req *proto.SetInodeFlagsRequest, p *Packet) (err error)
+	CloneInode(req *proto.CloneInodeRequest, p *Packet) (err error)
+	GetChangeEvents(req *proto.GetChangeEventsRequest, p *Packet) (err error)
 	QuotaCreateInode(req *proto.QuotaCreateInodeRequest, p *Packet) (err error)
 }
 
@@ -153,6 +162,7 @@ type OpExtend interface {
 	RemoveXAttr(req *proto.RemoveXAttrRequest, p *Packet) (err error)
 	ListXAttr(req *proto.ListXAttrRequest, p *Packet) (err error)
 	UpdateXAttr(req *proto.UpdateXAttrRequest, p *Packet) (err error)
+	GCOrphanExtends(marker uint64, limit int) (resp *OrphanExtendGCResp, err error)
 }
 
 // OpDentry defines the interface for the dentry operations.
@@ -163,14 +173,19 @@ type OpDentry interface {
 	UpdateDentry(req *UpdateDentryReq, p *Packet) (err error)
 	ReadDir(req *ReadDirReq, p *Packet) (err error)
 	ReadDirLimit(req *ReadDirLimitReq, p *Packet) (err error)
+	ReadDirPlus(req *ReadDirPlusReq, p *Packet) (err error)
 	ReadDirOnly(req *ReadDirOnlyReq, p *Packet) (err error)
 	Lookup(req *LookupReq, p *Packet) (err error)
 	GetDentryTree() *BTree
 	GetDentryTreeLen() int
+	GetDirChildCount(ino uint64) (count uint32, err error)
 	TxCreateDentry(req *proto.TxCreateDentryRequest, p *Packet) (err error)
 	TxDeleteDentry(req *proto.TxDeleteDentryRequest, p *Packet) (err error)
 	TxUpdateDentry(req *proto.TxUpdateDentryRequest, p *Packet) (err error)
 	QuotaCreateDentry(req *proto.QuotaCreateDentryRequest, p *Packet) (err error)
+	SetDentryAttr(req *proto.SetDentryAttrRequest, p *Packet) (err error)
+	GetDentryAttr(req *proto.GetDentryAttrRequest, p *Packet) (err error)
+	ListDentryAttr(req *proto.ListDentryAttrRequest, p *Packet) (err error)
 }
 
 type OpTransaction interface {
@@ -458,6 +473,7 @@ type OpQuota interface {
 		resp *proto.BatchSetMetaserverQuotaResponse) (err error)
 	batchDeleteInodeQuota(req *proto.BatchDeleteMetaserverQuotaReuqest,
 		resp *proto.BatchDeleteMetaserverQuotaResponse) (err error)
+	setSubtreeQuota(req *proto.SetSubtreeQuotaRequest, resp *proto.SetSubtreeQuotaResponse) (err error)
 	getInodeQuota(inode uint64, p *Packet) (err error)
 }
 
@@ -485,6 +501,7 @@ type metaPartition struct {
 	freeList               *freeList // free inode list
 	extDelCh               chan []proto.ExtentKey
 	extReset               chan struct{}
+	sharedExts             sharedExtents // inodes whose extents may be shared by a clone
 	vol                    *Vol
 	manager                *metadataManager
 	isLoadingMetaPartition bool
@@ -501,6 +518,7 @@ type metaPartition struct {
 	verSeq                 uint64
 	multiVersionList       *proto.VolVersionInfoList
 	versionLock            sync.Mutex
+	changes                changeLog // latest changes for the watching clients
 }
 
 func (mp *metaPartition) acucumRebuildStart() bool {
@@ -675,6 +693,9 @@ func (mp *metaPartition) onStart(isCreate bool) (err error) {
 	}
 
 	mp.vol.volDeleteLockTime = volumeInfo.DeleteLockTime
+	mp.vol.quotaByAllocated = volumeInfo.QuotaByAllocated
+	mp.vol.maxFileSize = volumeInfo.MaxFileSize
+	mp.vol.inodeDelayDeleteTime = volumeInfo.InodeDelayDeleteTime
 	verList, err = masterClient.AdminAPI().GetVerList(mp.config.VolName)
 
 	if err != nil {
@@ -840,10 +861,10 @@ func (mp *metaPartition) getRaftPort() (heartbeat, replica int, err error) {
 func NewMetaPartition(conf *MetaPartitionConfig, manager *metadataManager) MetaPartition {
 	mp := &metaPartition{
 		config:        conf,
-		dentryTree:    NewBtree(),
-		inodeTree:     NewBtree(),
-		extendTree:    NewBtree(),
-		multipartTree: NewBtree(),
+		dentryTree:    NewBtreeWithDegree(conf.BTreeDegree),
+		inodeTree:     NewBtreeWithDegree(conf.BTreeDegree),
+		extendTree:    NewBtreeWithDegree(conf.BTreeDegree),
+		multipartTree: NewBtreeWithDegree(conf.BTreeDegree),
 		stopC:         make(chan bool),
 		storeChan:     make(chan *storeMsg, 100),
 		freeList:      newFreeList(),
@@ -0,0 +1,171 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// changeLogCapacity is the number of change events kept by a partition, a watcher
+// polling less often than the partition changes is told that it lost events.
+const changeLogCapacity = 4096
+
+// changeLog keeps the latest changes applied by the partition in memory, so that
+// clients can watch files by polling it. The events are stamped with the raft index
+// of the change, thus a watcher can move between the replicas. The log is not
+// persisted, after a restart the events before the first applied index are lost.
+// The ring is only allocated once a watcher asks for events, so the partitions
+// nobody watches keep none.
+type changeLog struct {
+	sync.RWMutex
+	events    []proto.ChangeEvent // ring buffer, nil until watched
+	head      int                 // index of the oldest event
+	count     int
+	applying  uint64 // raft index of the change being applied
+	committed uint64 // all the events up to this index are in the log
+	oldest    uint64 // watchers asking from an earlier index lost events
+	started   bool
+	paused    bool // set while a transaction is rolled back
+}
+
+// begin is called by Apply before the change of index is applied.
+func (cl *changeLog) begin(index uint64) {
+	cl.Lock()
+	defer cl.Unlock()
+	if !cl.started {
+		cl.started = true
+		cl.oldest = index - 1
+	}
+	cl.applying = index
+}
+
+// commit makes the events of index visible to the watchers.
+func (cl *changeLog) commit(index uint64) {
+	cl.Lock()
+	defer cl.Unlock()
+	cl.committed = index
+}
+
+// reset drops all the events, e.g. after a snapshot is applied.
+func (cl *changeLog) reset(index uint64) {
+	cl.Lock()
+	defer cl.Unlock()
+	cl.head, cl.count = 0, 0
+	cl.started = true
+	cl.applying, cl.committed, cl.oldest = index, index, index
+}
+
+// pause stops recording the events until resume is called. The changes a
+// transaction rolls back were never reported, neither is undoing them.
+func (cl *changeLog) pause() {
+	cl.Lock()
+	defer cl.Unlock()
+	cl.paused = true
+}
+
+func (cl *changeLog) resume() {
+	cl.Lock()
+	defer cl.Unlock()
+	cl.paused = false
+}
+
+// subscribe starts keeping the events. The caller must hold the lock.
+func (cl *changeLog) subscribe() {
+	if cl.events != nil {
+		return
+	}
+	cl.events = make([]proto.ChangeEvent, changeLogCapacity)
+	if cl.started {
+		// the events of the index being applied may be missing
+		cl.oldest = cl.applying
+	}
+}
+
+func (cl *changeLog) add(typ uint8, ino, parent uint64, name string) {
+	cl.Lock()
+	defer cl.Unlock()
+	if cl.events == nil || cl.paused {
+		return
+	}
+	event := proto.ChangeEvent{Seq: cl.applying, Type: typ, Inode: ino, Parent: parent, Name: name}
+	if cl.count == len(cl.events) {
+		cl.oldest = cl.events[cl.head].Seq
+		cl.events[cl.head] = event
+		cl.head = (cl.head + 1) % len(cl.events)
+		return
+	}
+	cl.events[(cl.head+cl.count)%len(cl.events)] = event
+	cl.count++
+}
+
+// get returns the committed events after req.FromSeq about the inodes or parents
+// in the request, applyID is the index to start from if nothing has been applied
+// since the partition was loaded.
+func (cl *changeLog) get(req *proto.GetChangeEventsRequest, applyID uint64) (resp *proto.GetChangeEventsResponse) {
+	cl.Lock()
+	defer cl.Unlock()
+	cl.subscribe()
+	resp = &proto.GetChangeEventsResponse{}
+	if !cl.started {
+		resp.NextSeq = applyID
+		resp.Lost = req.FromSeq != 0 && req.FromSeq < applyID
+		return
+	}
+	resp.NextSeq = cl.committed
+	if req.FromSeq == 0 {
+		return
+	}
+	resp.Lost = req.FromSeq < cl.oldest
+
+	inodes := make(map[uint64]struct{}, len(req.Inodes))
+	for _, ino := range req.Inodes {
+		inodes[ino] = struct{}{}
+	}
+	parents := make(map[uint64]struct{}, len(req.Parents))
+	for _, pino := range req.Parents {
+		parents[pino] = struct{}{}
+	}
+	for i := 0; i < cl.count; i++ {
+		event := cl.events[(cl.head+i)%len(cl.events)]
+		if event.Seq <= req.FromSeq || event.Seq > cl.committed {
+			continue
+		}
+		_, inoMatch := inodes[event.Inode]
+		_, parentMatch := parents[event.Parent]
+		if inoMatch || (event.Parent != 0 && parentMatch) {
+			resp.Events = append(resp.Events, event)
+		}
+	}
+	return
+}
+
+func (mp *metaPartition) recordChange(typ uint8, ino, parent uint64, name string) {
+	mp.changes.add(typ, ino, parent, name)
+}
+
+// GetChangeEvents returns the change events of the partition a watcher asks for.
+func (mp *metaPartition) GetChangeEvents(req *proto.GetChangeEventsRequest, p *Packet) (err error) {
+	resp := mp.changes.get(req, mp.getApplyID())
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
@@ -0,0 +1,202 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeLogEvents(t *testing.T) {
+	mp := newMetaPartition(10030, &metadataManager{})
+	mp.applyID = 9
+
+	// a watcher starts from the applied index of an idle partition
+	req := &proto.GetChangeEventsRequest{Inodes: []uint64{inodeNum}, Parents: []uint64{pInodeNum}}
+	resp := mp.changes.get(req, mp.getApplyID())
+	require.Equal(t, uint64(9), resp.NextSeq)
+	require.False(t, resp.Lost)
+	req.FromSeq = resp.NextSeq
+
+	mp.changes.begin(10)
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(inodeNum, FileModeType)))
+	require.Equal(t, proto.OpOk, mp.fsmCreateDentry(&Dentry{ParentId: pInodeNum, Name: "f", Inode: inodeNum, Type: FileModeType}, true))
+	file := NewInode(inodeNum, FileModeType)
+	file.Extents = NewSortedExtentsFromEks([]proto.ExtentKey{{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 4096}})
+	require.Equal(t, proto.OpOk, mp.fsmAppendExtents(file))
+
+	// the events are not visible before the index is committed
+	require.Empty(t, mp.changes.get(req, 0).Events)
+	mp.changes.commit(10)
+	resp = mp.changes.get(req, 0)
+	require.Equal(t, uint64(10), resp.NextSeq)
+	require.Equal(t, []proto.ChangeEvent{{Seq: 10, Type: proto.ChangeEventModify, Inode: inodeNum}}, resp.Events)
+	req.FromSeq = resp.NextSeq
+
+	mp.changes.begin(11)
+	mp.fsmDeleteDentry(&Dentry{ParentId: pInodeNum, Name: "f", Inode: inodeNum}, true)
+	mp.changes.commit(11)
+	mp.changes.begin(12)
+	mp.fsmUnlinkInode(NewInode(inodeNum, 0), 0)
+	mp.changes.commit(12)
+
+	resp = mp.changes.get(req, 0)
+	require.Equal(t, uint64(12), resp.NextSeq)
+	require.Equal(t, []proto.ChangeEvent{
+		{Seq: 11, Type: proto.ChangeEventDentryRemove, Inode: inodeNum, Parent: pInodeNum, Name: "f"},
+		{Seq: 12, Type: proto.ChangeEventDelete, Inode: inodeNum},
+	}, resp.Events)
+
+	// events of other inodes are filtered out
+	other := &proto.GetChangeEventsRequest{FromSeq: 9, Inodes: []uint64{inodeNum2}}
+	require.Empty(t, mp.changes.get(other, 0).Events)
+}
+
+func TestChangeLogLost(t *testing.T) {
+	cl := &changeLog{}
+	cl.get(&proto.GetChangeEventsRequest{}, 99)
+	cl.begin(100)
+	cl.commit(100)
+	req := &proto.GetChangeEventsRequest{FromSeq: 99, Inodes: []uint64{1}}
+	require.False(t, cl.get(req, 0).Lost)
+	// events before the first applied index are unknown
+	req.FromSeq = 50
+	require.True(t, cl.get(req, 0).Lost)
+
+	// the oldest events are dropped when the log is full
+	for i := uint64(101); i <= 101+changeLogCapacity; i++ {
+		cl.begin(i)
+		cl.add(proto.ChangeEventModify, 1, 0, "")
+		cl.commit(i)
+	}
+	req.FromSeq = 100
+	resp := cl.get(req, 0)
+	require.True(t, resp.Lost)
+	require.Len(t, resp.Events, changeLogCapacity)
+	req.FromSeq = 101
+	resp = cl.get(req, 0)
+	require.False(t, resp.Lost)
+	require.Len(t, resp.Events, changeLogCapacity)
+
+	cl.reset(10000)
+	resp = cl.get(req, 0)
+	require.True(t, resp.Lost)
+	require.Empty(t, resp.Events)
+	require.Equal(t, uint64(10000), resp.NextSeq)
+}
+
+func TestChangeLogTxEvents(t *testing.T) {
+	mp := newMetaPartition(10031, &metadataManager{})
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(pInodeNum, DirModeType)))
+	for name, ino := range map[string]uint64{"f": inodeNum, "g": inodeNum2} {
+		require.Equal(t, proto.OpOk, mp.fsmCreateDentry(&Dentry{ParentId: pInodeNum, Name: name, Inode: ino, Type: FileModeType}, false))
+	}
+	newTx := func(names ...string) *proto.TransactionInfo {
+		txInfo := proto.NewTransactionInfo(5, proto.TxTypeRename)
+		for _, name := range names {
+			txDentryInfo := proto.NewTxDentryInfo(MemberAddrs, pInodeNum, name, mp.config.PartitionId)
+			txInfo.TxDentryInfos[txDentryInfo.GetKey()] = txDentryInfo
+		}
+		require.NoError(t, mp.initTxInfo(txInfo))
+		txInfo.TmID = int64(mp.config.PartitionId)
+		require.NoError(t, mp.txProcessor.txManager.registerTransaction(txInfo))
+		return txInfo
+	}
+	// f is renamed over g
+	apply := func(txInfo *proto.TransactionInfo) {
+		resp := mp.fsmTxUpdateDentry(NewTxUpdateDentry(
+			&Dentry{ParentId: pInodeNum, Name: "g", Inode: inodeNum2},
+			&Dentry{ParentId: pInodeNum, Name: "g", Inode: inodeNum}, txInfo))
+		require.Equal(t, proto.OpOk, resp.Status)
+		resp = mp.fsmTxDeleteDentry(NewTxDentry(pInodeNum, "f", inodeNum, FileModeType, nil, txInfo))
+		require.Equal(t, proto.OpOk, resp.Status)
+	}
+	txRsc := mp.txProcessor.txResource
+	req := &proto.GetChangeEventsRequest{FromSeq: 9, Parents: []uint64{pInodeNum}}
+	mp.changes.get(req, 9)
+
+	// a rolled back transaction changes nothing
+	txInfo := newTx("f", "g")
+	mp.changes.begin(10)
+	apply(txInfo)
+	mp.changes.commit(10)
+	mp.changes.begin(11)
+	for _, name := range []string{"f", "g"} {
+		status, err := txRsc.rollbackDentry(&proto.TxDentryApplyRequest{TxID: txInfo.TxID, Pid: pInodeNum, Name: name})
+		require.True(t, status == proto.OpOk && err == nil)
+	}
+	mp.changes.commit(11)
+	require.Empty(t, mp.changes.get(req, 0).Events)
+
+	// the dentries removed or replaced are reported when the transaction commits
+	txInfo = newTx("f", "g")
+	mp.changes.begin(12)
+	apply(txInfo)
+	mp.changes.commit(12)
+	require.Empty(t, mp.changes.get(req, 0).Events)
+	mp.changes.begin(13)
+	for _, name := range []string{"f", "g"} {
+		status, err := txRsc.commitDentry(txInfo.TxID, pInodeNum, name)
+		require.True(t, status == proto.OpOk && err == nil)
+	}
+	mp.changes.commit(13)
+	events := []proto.ChangeEvent{
+		{Seq: 13, Type: proto.ChangeEventDentryRemove, Inode: inodeNum, Parent: pInodeNum, Name: "f"},
+		{Seq: 13, Type: proto.ChangeEventDentryRemove, Inode: inodeNum2, Parent: pInodeNum, Name: "g"},
+	}
+	require.Equal(t, events, mp.changes.get(req, 0).Events)
+
+	// removing a dentry created by a rolled back transaction is not reported
+	txInfo = newTx("h")
+	mp.changes.begin(14)
+	require.Equal(t, proto.OpOk, mp.fsmTxCreateDentry(NewTxDentry(pInodeNum, "h", inodeNum2, FileModeType, nil, txInfo)))
+	mp.changes.commit(14)
+	mp.changes.begin(15)
+	status, err := txRsc.rollbackDentry(&proto.TxDentryApplyRequest{TxID: txInfo.TxID, Pid: pInodeNum, Name: "h"})
+	require.True(t, status == proto.OpOk && err == nil)
+	mp.changes.commit(15)
+	require.Nil(t, mp.dentryTree.Get(&Dentry{ParentId: pInodeNum, Name: "h"}))
+	require.Equal(t, events, mp.changes.get(req, 0).Events)
+}
+
+func TestChangeLogSubscribe(t *testing.T) {
+	cl := &changeLog{}
+	// nothing is kept before a watcher asks
+	cl.begin(100)
+	cl.add(proto.ChangeEventModify, 1, 0, "")
+	cl.commit(100)
+	require.Nil(t, cl.events)
+
+	// a watcher subscribing in the middle of an apply may miss its events
+	cl.begin(101)
+	cl.add(proto.ChangeEventModify, 1, 0, "")
+	resp := cl.get(&proto.GetChangeEventsRequest{Inodes: []uint64{1}}, 0)
+	require.Equal(t, uint64(100), resp.NextSeq)
+	require.Len(t, cl.events, changeLogCapacity)
+	cl.add(proto.ChangeEventDelete, 1, 0, "")
+	cl.commit(101)
+	resp = cl.get(&proto.GetChangeEventsRequest{FromSeq: 100, Inodes: []uint64{1}}, 0)
+	require.True(t, resp.Lost)
+
+	// the events after the subscription are all kept
+	cl.begin(102)
+	cl.add(proto.ChangeEventModify, 1, 0, "")
+	cl.commit(102)
+	resp = cl.get(&proto.GetChangeEventsRequest{FromSeq: 101, Inodes: []uint64{1}}, 0)
+	require.False(t, resp.Lost)
+	require.Equal(t, []proto.ChangeEvent{{Seq: 102, Type: proto.ChangeEventModify, Inode: 1}}, resp.Events)
+}
@@ -133,6 +133,9 @@ LOOP:
 		case eks := <-mp.extDelCh:
 			var data []byte
 			buf = buf[:0]
+			if eks = mp.skipSharedExtents(eks); len(eks) == 0 {
+				continue
+			}
 			log.LogDebugf("del eks [%v]", eks)
 			for _, ek := range eks {
 				data, err = ek.MarshalBinaryWithCheckSum(true)
@@ -72,9 +72,22 @@ func (mp *metaPartition) updateVolView(convert func(view *proto.DataPartitionsVi
 		return
 	}
 	mp.vol.volDeleteLockTime = volView.DeleteLockTime
+	mp.vol.quotaByAllocated = volView.QuotaByAllocated
+	mp.syncQuotaByAllocated()
+	mp.vol.maxFileSize = volView.MaxFileSize
+	mp.vol.inodeDelayDeleteTime = volView.InodeDelayDeleteTime
 	return nil
 }
 
+// getInodeDelayDeleteSeconds returns how long an unlinked inode stays in the free list
+// before it can really be deleted.
+func (mp *metaPartition) getInodeDelayDeleteSeconds() int64 {
+	if delay := mp.vol.inodeDelayDeleteTime; delay > 0 {
+		return delay
+	}
+	return InodeNLink0DelayDeleteSeconds
+}
+
 func (mp *metaPartition) updateVolWorker() {
 	t := time.NewTicker(UpdateVolTicket)
 	var convert = func(view *proto.DataPartitionsView) *DataPartitionsView {
@@ -162,7 +175,7 @@ func (mp *metaPartition) deleteWorker() {
 			//check inode nlink == 0 and deleteMarkFlag unset
 			if inode, ok := mp.inodeTree.Get(&Inode{Inode: ino}).(*Inode); ok {
 				inTx, _ := mp.txProcessor.txResource.isInodeInTransction(inode)
-				if inode.ShouldDelayDelete() || inTx {
+				if inode.ShouldDelayDelete(mp.getInodeDelayDeleteSeconds()) || inTx {
 					log.LogDebugf("[metaPartition] deleteWorker delay to remove inode: %v as NLink is 0, inTx %v", inode, inTx)
 					delayDeleteInos = append(delayDeleteInos, ino)
 					continue
@@ -274,6 +287,11 @@ func (mp *metaPartition) deleteMarkedInodes(inoSlice []uint64) {
 
 		extInfo := inode.GetAllExtsOfflineInode(mp.config.PartitionId)
 		for dpID, inodeExts := range extInfo {
+			if inode.IsSharedExtents() {
+				if inodeExts = mp.skipSharedExtentKeys(inodeExts); len(inodeExts) == 0 {
+					continue
+				}
+			}
 			exts, ok := deleteExtentsByPartition[dpID]
 			if !ok {
 				exts = make([]*proto.ExtentKey, 0)
@@ -0,0 +1,45 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInodeDelayDeleteTime(t *testing.T) {
+	mp := newMetaPartition(10031, &metadataManager{})
+	require.Equal(t, int64(InodeNLink0DelayDeleteSeconds), mp.getInodeDelayDeleteSeconds())
+	mp.vol.inodeDelayDeleteTime = 1
+	require.Equal(t, int64(1), mp.getInodeDelayDeleteSeconds())
+
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(inodeNum, FileModeType)))
+	mp.fsmUnlinkInode(NewInode(inodeNum, 0), 0)
+	require.Equal(t, 1, mp.freeList.Len())
+	inode := mp.inodeTree.Get(NewInode(inodeNum, 0)).(*Inode)
+	require.Equal(t, uint32(0), inode.NLink)
+
+	// the unlinked inode waits in the free list for the configured delay only
+	require.True(t, inode.ShouldDelayDelete(mp.getInodeDelayDeleteSeconds()))
+	time.Sleep(2 * time.Second)
+	require.False(t, inode.ShouldDelayDelete(mp.getInodeDelayDeleteSeconds()))
+
+	// while the default one still holds it
+	mp.vol.inodeDelayDeleteTime = 0
+	require.True(t, inode.ShouldDelayDelete(mp.getInodeDelayDeleteSeconds()))
+}
@@ -41,6 +41,7 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 	defer func() {
 		if err == nil {
 			mp.uploadApplyID(index)
+			mp.changes.commit(index)
 		}
 	}()
 	if err = msg.UnmarshalJson(command); err != nil {
@@ -49,6 +50,7 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 
 	mp.nonIdempotent.Lock()
 	defer mp.nonIdempotent.Unlock()
+	mp.changes.begin(index)
 
 	switch msg.Op {
 	case opFSMCreateInode:
@@ -202,7 +204,7 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 		if err = json.Unmarshal(msg.V, req); err != nil {
 			return
 		}
-		resp, err = mp.fsmUpdatePartition(req.End)
+		resp, err = mp.fsmUpdatePartition(req)
 	case opFSMExtentsAdd:
 		ino := NewInode(0, 0)
 		if err = ino.Unmarshal(msg.V); err != nil {
@@ -279,6 +281,16 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 		err = mp.delOldExtentFile(msg.V)
 	case opFSMInternalDelExtentCursor:
 		err = mp.setExtentDeleteFileCursor(msg.V)
+	case opFSMSetDentryAttr:
+		den := &Dentry{}
+		if err = den.Unmarshal(msg.V); err != nil {
+			return
+		}
+		if status := mp.dentryInTx(den.ParentId, den.Name); status != proto.OpOk {
+			resp = status
+			return
+		}
+		resp = mp.fsmSetDentryAttr(den)
 	case opFSMSetXAttr:
 		var extend *Extend
 		if extend, err = NewExtendFromBytes(msg.V); err != nil {
@@ -415,6 +427,36 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		resp = mp.fsmTxUpdateDentry(txUpdateDen)
+	case opFSMTxSetAttr:
+		req := &proto.TxSetAttrRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmTxSetAttr(req)
+	case opFSMSetInodeFlags:
+		req := &proto.SetInodeFlagsRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmSetInodeFlags(req)
+	case opFSMQuotaByAllocated:
+		var byAllocated bool
+		if err = json.Unmarshal(msg.V, &byAllocated); err != nil {
+			return
+		}
+		resp = mp.fsmSetQuotaByAllocated(byAllocated)
+	case opFSMDeleteOrphanExtends:
+		var inodes []uint64
+		if err = json.Unmarshal(msg.V, &inodes); err != nil {
+			return
+		}
+		resp = mp.fsmDeleteOrphanExtends(inodes)
+	case opFSMCloneInode:
+		req := &proto.CloneInodeRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmCloneInode(req)
 	case opFSMTxCreateLinkInode:
 		txIno := NewTxInode(0, 0, nil)
 		if err = txIno.Unmarshal(msg.V); err != nil {
@@ -552,10 +594,10 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 		txID           uint64
 		uniqID         uint64
 		cursor         uint64
-		inodeTree      = NewBtree()
-		dentryTree     = NewBtree()
-		extendTree     = NewBtree()
-		multipartTree  = NewBtree()
+		inodeTree      = NewBtreeWithDegree(mp.config.BTreeDegree)
+		dentryTree     = NewBtreeWithDegree(mp.config.BTreeDegree)
+		extendTree     = NewBtreeWithDegree(mp.config.BTreeDegree)
+		multipartTree  = NewBtreeWithDegree(mp.config.BTreeDegree)
 		txTree         = NewBtree()
 		txRbInodeTree  = NewBtree()
 		txRbDentryTree = NewBtree()
@@ -604,6 +646,7 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 	defer func() {
 		if err == io.EOF {
 			mp.applyID = appIndexID
+			mp.changes.reset(appIndexID)
 			mp.config.UniqId = uniqID
 			mp.txProcessor.txManager.txIdAlloc.setTransactionID(txID)
 			mp.inodeTree = inodeTree
@@ -615,6 +658,7 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 			mp.txProcessor.txResource.txRbInodeTree = txRbInodeTree
 			mp.txProcessor.txResource.txRbDentryTree = txRbDentryTree
 			mp.uniqChecker = uniqChecker
+			mp.resetSharedExtents()
 
 			err = nil
 			// store message
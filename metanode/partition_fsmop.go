@@ -75,20 +75,26 @@ func (mp *metaPartition) decommissionPartition() (err error) {
 	return
 }
 
-func (mp *metaPartition) fsmUpdatePartition(end uint64) (status uint8,
+func (mp *metaPartition) fsmUpdatePartition(req *UpdatePartitionReq) (status uint8,
 	err error) {
 	status = proto.OpOk
 	oldEnd := mp.config.End
-	mp.config.End = end
+	mp.config.End = req.End
 
-	if end < mp.config.Cursor {
+	if req.End < mp.config.Cursor {
 		status = proto.OpAgain
 		mp.config.End = oldEnd
 		return
 	}
+	// the vol may have been renamed on the master
+	oldVolName := mp.config.VolName
+	if req.VolName != "" {
+		mp.config.VolName = req.VolName
+	}
 	if err = mp.PersistMetadata(); err != nil {
 		status = proto.OpDiskErr
 		mp.config.End = oldEnd
+		mp.config.VolName = oldVolName
 	}
 	return
 }
@@ -105,6 +105,7 @@ func (mp *metaPartition) fsmCreateDentry(dentry *Dentry,
 			d.setVerSeq(dentry.getSeqFiled())
 			d.Type = dentry.Type
 			d.ParentId = dentry.ParentId
+			d.attrs = dentry.attrs
 			log.LogDebugf("action[fsmCreateDentry.ver] latest dentry already deleted.Now create new one [%v]", dentry)
 
 			if !forceUpdate {
@@ -184,6 +185,12 @@ func (mp *metaPartition) fsmTxDeleteDentry(txDentry *TxDentry) (resp *DentryResp
 		return
 	}
 
+	if mp.hasProtectedInode(tmpDen.Inode) {
+		log.LogWarnf("fsmTxDeleteDentry: inode of dentry %v is immutable or append-only", tmpDen)
+		resp.Status = proto.OpNotPerm
+		return
+	}
+
 	rbDentry := NewTxRollbackDentry(tmpDen, txDenInfo, TxAdd)
 	resp.Status = mp.txProcessor.txResource.addTxRollbackDentry(rbDentry)
 	if resp.Status == proto.OpExistErr {
@@ -209,11 +216,40 @@ func (mp *metaPartition) fsmTxDeleteDentry(txDentry *TxDentry) (resp *DentryResp
 	}
 
 	mp.dentryTree.Delete(tmpDen)
-	// parent link count not change
+	// the nlink of the parent is decremented once the transaction commits, so the
+	// dir can't be removed as empty while the dentry may still be rolled back
 	resp.Msg = item.(*Dentry)
 	return
 }
 
+// hasProtectedInode returns if one of the inodes is immutable or append-only. The
+// inodes may live in another partition, then the client checks them before the
+// transaction, or their unlink in the same transaction is refused.
+func (mp *metaPartition) hasProtectedInode(inos ...uint64) bool {
+	for _, ino := range inos {
+		if item := mp.inodeTree.Get(NewInode(ino, 0)); item != nil && item.(*Inode).IsProtected() {
+			return true
+		}
+	}
+	return false
+}
+
+// decParentLink drops the link of a removed entry from the nlink of its parent dir.
+func (mp *metaPartition) decParentLink(parentID uint64) {
+	mp.inodeTree.CopyFind(NewInode(parentID, 0), func(item BtreeItem) {
+		if item == nil {
+			return
+		}
+		parIno := item.(*Inode)
+		if parIno.ShouldDelete() || !proto.IsDir(parIno.Type) || parIno.GetNLink() <= 2 {
+			log.LogWarnf("action[decParentLink] parent %v has no entry link to drop", parIno)
+			return
+		}
+		parIno.DecNLink()
+		parIno.SetMtime()
+	})
+}
+
 // Delete dentry from the dentry tree.
 func (mp *metaPartition) fsmDeleteDentry(denParm *Dentry, checkInode bool) (resp *DentryResponse) {
 
@@ -300,6 +336,9 @@ func (mp *metaPartition) fsmDeleteDentry(denParm *Dentry, checkInode bool) (resp
 					}
 				}
 			})
+		if denParm.getSeqFiled() == 0 {
+			mp.recordChange(proto.ChangeEventDentryRemove, denFound.Inode, denFound.ParentId, denFound.Name)
+		}
 	}
 	resp.Msg = denFound
 	return
@@ -346,6 +385,12 @@ func (mp *metaPartition) fsmTxUpdateDentry(txUpDateDentry *TxUpdateDentry) (resp
 		return
 	}
 
+	if mp.hasProtectedInode(oldDen.Inode, newDen.Inode) {
+		log.LogWarnf("fsmTxUpdateDentry: inode of dentry %v or %v is immutable or append-only", oldDen, newDen)
+		resp.Status = proto.OpNotPerm
+		return
+	}
+
 	rbDentry := NewTxRollbackDentry(txUpDateDentry.OldDentry, txDenInfo, TxUpdate)
 	resp.Status = mp.txProcessor.txResource.addTxRollbackDentry(rbDentry)
 	if resp.Status == proto.OpExistErr {
@@ -365,6 +410,17 @@ func (mp *metaPartition) fsmTxUpdateDentry(txUpDateDentry *TxUpdateDentry) (resp
 
 func (mp *metaPartition) fsmUpdateDentry(dentry *Dentry) (
 	resp *DentryResponse) {
+	resp = mp.updateDentryInode(dentry)
+	if resp.Msg != nil {
+		mp.recordChange(proto.ChangeEventDentryRemove, resp.Msg.Inode, resp.Msg.ParentId, resp.Msg.Name)
+	}
+	return
+}
+
+// updateDentryInode points the dentry with the same name to dentry.Inode, the
+// replaced inode is returned in resp.Msg. A transaction rolled back uses it to
+// restore a dentry, which is no change to report to the watchers.
+func (mp *metaPartition) updateDentryInode(dentry *Dentry) (resp *DentryResponse) {
 	resp = NewDentryResponse()
 	resp.Status = proto.OpOk
 	mp.dentryTree.CopyFind(dentry, func(item BtreeItem) {
@@ -382,6 +438,26 @@ func (mp *metaPartition) fsmUpdateDentry(dentry *Dentry) (
 	return
 }
 
+// fsmSetDentryAttr sets the attrs carried by the dentry on the dentry with the same name.
+func (mp *metaPartition) fsmSetDentryAttr(dentry *Dentry) (status uint8) {
+	status = proto.OpOk
+	mp.dentryTree.CopyFind(dentry, func(item BtreeItem) {
+		if item == nil {
+			status = proto.OpNotExistErr
+			return
+		}
+		d := item.(*Dentry)
+		if d.isDeleted() {
+			status = proto.OpNotExistErr
+			return
+		}
+		for k, v := range dentry.attrs {
+			d.setAttr(k, v)
+		}
+	})
+	return
+}
+
 func (mp *metaPartition) getDentryTree() *BTree {
 	return mp.dentryTree.GetTree()
 }
@@ -392,6 +468,12 @@ func (mp *metaPartition) getDentryByVerSeq(dy *Dentry, verSeq uint64) (d *Dentry
 	return
 }
 
+// countChildren returns the number of dentries under the parent in the dentry tree,
+// unlike the child count of the parent inode it is computed from the dentries.
+func (mp *metaPartition) countChildren(parentID uint64) int {
+	return mp.dentryTree.CountRange(&Dentry{ParentId: parentID}, &Dentry{ParentId: parentID + 1})
+}
+
 func (mp *metaPartition) readDirOnly(req *ReadDirOnlyReq) (resp *ReadDirOnlyResp) {
 	resp = &ReadDirOnlyResp{}
 	begDentry := &Dentry{
@@ -467,6 +549,9 @@ func (mp *metaPartition) readDirLimit(req *ReadDirLimitReq) (resp *ReadDirLimitR
 				return true
 			}
 		}
+		if !proto.ReadDirFilterMatch(req.Filter, i.(*Dentry).Type) {
+			return true
+		}
 		d := mp.getDentryByVerSeq(i.(*Dentry), req.VerSeq)
 		if d == nil {
 			return true
@@ -485,3 +570,38 @@ func (mp *metaPartition) readDirLimit(req *ReadDirLimitReq) (resp *ReadDirLimitR
 	log.LogDebugf("action[readDirLimit] resp %v", resp)
 	return
 }
+
+// readDirPlus reads the dentries like readDirLimit and joins them with the inodes
+// of the partition at the same version. The inodes owned by another partition, or
+// deleted since, are left without info for the client to get them separately.
+func (mp *metaPartition) readDirPlus(req *ReadDirPlusReq) (resp *ReadDirPlusResp, err error) {
+	children := mp.readDirLimit(&ReadDirLimitReq{
+		ParentID: req.ParentID,
+		Marker:   req.Marker,
+		Limit:    req.Limit,
+		VerSeq:   req.VerSeq,
+		VerOpt:   req.VerOpt,
+		Filter:   req.Filter,
+	}).Children
+
+	resp = &ReadDirPlusResp{Children: make([]proto.DentryPlus, 0, len(children))}
+	for _, d := range children {
+		child := proto.DentryPlus{Dentry: d}
+		ino := NewInode(d.Inode, 0)
+		ino.setVer(req.VerSeq)
+		if retMsg := mp.getInode(ino, false); retMsg.Status == proto.OpOk {
+			var quotaInfos map[uint32]*proto.MetaQuotaInfo
+			if mp.mqMgr.EnableQuota() {
+				if quotaInfos, err = mp.getInodeQuotaInfos(d.Inode); err != nil {
+					return nil, err
+				}
+			}
+			info := &proto.InodeInfo{}
+			if replyInfo(info, retMsg.Msg, quotaInfos) {
+				child.Info = info
+			}
+		}
+		resp.Children = append(resp.Children, child)
+	}
+	return
+}
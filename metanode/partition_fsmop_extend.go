@@ -70,3 +70,17 @@ func (mp *metaPartition) fsmRemoveXAttr(extend *Extend) (err error) {
 	})
 	return
 }
+
+// fsmDeleteOrphanExtends deletes the extends of the given inodes which are not in the
+// inode tree any more, and returns how many are deleted.
+func (mp *metaPartition) fsmDeleteOrphanExtends(inodes []uint64) (reclaimed int) {
+	for _, ino := range inodes {
+		if mp.inodeTree.Get(NewInode(ino, 0)) != nil {
+			continue
+		}
+		if mp.extendTree.Delete(NewExtend(ino)) != nil {
+			reclaimed++
+		}
+	}
+	return
+}
@@ -134,6 +134,11 @@ func (mp *metaPartition) fsmCreateLinkInode(ino *Inode, uniqID uint64) (resp *In
 		return
 	}
 
+	if i.IsProtected() {
+		resp.Status = proto.OpNotPerm
+		return
+	}
+
 	resp.Msg = i
 	if !mp.uniqChecker.legalIn(uniqID) {
 		log.LogWarnf("fsmCreateLinkInode repeated, ino %v uniqID %v nlink %v", ino.Inode, uniqID, ino.GetNLink())
@@ -240,6 +245,12 @@ func (mp *metaPartition) fsmTxUnlinkInode(txIno *TxInode) (resp *InodeResponse)
 		return
 	}
 
+	if item := mp.inodeTree.Get(txIno.Inode); item != nil && item.(*Inode).IsProtected() {
+		log.LogWarnf("fsmTxUnlinkInode: inode is immutable or append-only, txInode %v", txIno)
+		resp.Status = proto.OpNotPerm
+		return
+	}
+
 	inodeInfo, ok := txIno.TxInfo.TxInodeInfos[txIno.Inode.Inode]
 	if !ok {
 		resp.Status = proto.OpTxInodeInfoNotExistErr
@@ -306,6 +317,12 @@ func (mp *metaPartition) fsmUnlinkInode(ino *Inode, uniqID uint64) (resp *InodeR
 		return
 	}
 
+	if ino.getVer() == 0 && inode.IsProtected() {
+		log.LogWarnf("action[fsmUnlinkInode] ino %v is immutable or append-only", ino.Inode)
+		resp.Status = proto.OpNotPerm
+		return
+	}
+
 	topLayerEmpty := inode.IsTopLayerEmptyDir()
 
 	resp.Msg = inode
@@ -347,9 +364,12 @@ func (mp *metaPartition) fsmUnlinkInode(ino *Inode, uniqID uint64) (resp *InodeR
 		mp.updateUsedInfo(0, -1, inode.Inode)
 	}
 
-	//Fix#760: when nlink == 0, push into freeList and delay delete inode after 7 days
+	//Fix#760: when nlink == 0, push into freeList and delay delete inode, see getInodeDelayDeleteSeconds
 	if inode.IsTempFile() {
-		mp.updateUsedInfo(-1*int64(inode.Size), -1, inode.Inode)
+		if ino.getVer() == 0 {
+			mp.recordChange(proto.ChangeEventDelete, inode.Inode, 0, "")
+		}
+		mp.updateUsedInfo(-1*mp.quotaSize(inode), -1, inode.Inode)
 		inode.DoWriteFunc(func() {
 			if inode.NLink == 0 {
 				inode.AccessTime = time.Now().Unix()
@@ -452,13 +472,17 @@ func (mp *metaPartition) fsmAppendExtents(ino *Inode) (status uint8) {
 		status = proto.OpNotExistErr
 		return
 	}
-	oldSize := int64(ino2.Size)
+	oldSize := mp.quotaSize(ino2)
 	eks := ino.Extents.CopyExtents()
+	if status = checkExtentsAppendable(ino2, eks); status != proto.OpOk {
+		return
+	}
 	if status = mp.uidManager.addUidSpace(ino2.Uid, ino2.Inode, eks); status != proto.OpOk {
 		return
 	}
 	delExtents := ino2.AppendExtents(eks, ino.ModifyTime, mp.volType)
-	mp.updateUsedInfo(int64(ino2.Size)-oldSize, 0, ino2.Inode)
+	mp.updateUsedInfo(mp.quotaSize(ino2)-oldSize, 0, ino2.Inode)
+	mp.recordChange(proto.ChangeEventModify, ino2.Inode, 0, "")
 	log.LogInfof("fsmAppendExtents inode(%v) deleteExtents(%v)", ino2.Inode, delExtents)
 	mp.uidManager.minusUidSpace(ino2.Uid, ino2.Inode, delExtents)
 
@@ -490,7 +514,7 @@ func (mp *metaPartition) fsmAppendExtentsWithCheck(ino *Inode, isSplit bool) (st
 	var (
 		discardExtentKey []proto.ExtentKey
 	)
-	oldSize := int64(ino2.Size)
+	oldSize := mp.quotaSize(ino2)
 	eks := ino.Extents.CopyExtents()
 	log.LogDebugf("action[fsmAppendExtentsWithCheck] inode %v hist len %v,eks %v", ino2.Inode, ino2.getLayerLen(), eks)
 	if len(eks) < 1 {
@@ -499,6 +523,13 @@ func (mp *metaPartition) fsmAppendExtentsWithCheck(ino *Inode, isSplit bool) (st
 	if len(eks) > 1 {
 		discardExtentKey = eks[1:]
 	}
+	if isSplit && ino2.IsProtected() {
+		status = proto.OpNotPerm
+		return
+	}
+	if status = checkExtentsAppendable(ino2, eks[:1]); status != proto.OpOk {
+		return
+	}
 
 	if status = mp.uidManager.addUidSpace(ino2.Uid, ino2.Inode, eks[:1]); status != proto.OpOk {
 		log.LogErrorf("fsmAppendExtentsWithCheck.addUidSpace status %v", status)
@@ -510,6 +541,7 @@ func (mp *metaPartition) fsmAppendExtentsWithCheck(ino *Inode, isSplit bool) (st
 		delExtents, status = ino2.AppendExtentWithCheck(mp.verSeq, mp.multiVersionList, ino.getVer(), eks[0], ino.ModifyTime, discardExtentKey, mp.volType)
 		if status == proto.OpOk {
 			log.LogInfof("action[fsmAppendExtentsWithCheck] delExtents [%v]", delExtents)
+			mp.recordChange(proto.ChangeEventModify, ino2.Inode, 0, "")
 			ino2.DecSplitExts(delExtents)
 			mp.extDelCh <- delExtents
 		}
@@ -543,7 +575,7 @@ func (mp *metaPartition) fsmAppendExtentsWithCheck(ino *Inode, isSplit bool) (st
 	log.LogInfof("fsmAppendExtentWithCheck inode(%v) ek(%v) deleteExtents(%v) discardExtents(%v) status(%v) isSplit(%v)",
 		ino2.Inode, eks[0], delExtents, discardExtentKey, status, isSplit)
 
-	mp.updateUsedInfo(int64(ino2.Size)-oldSize, 0, ino2.Inode)
+	mp.updateUsedInfo(mp.quotaSize(ino2)-oldSize, 0, ino2.Inode)
 	log.LogInfof("fsmAppendExtentWithCheck inode(%v) ek(%v) deleteExtents(%v) discardExtents(%v) status(%v)", ino2.Inode, eks[0], delExtents, discardExtentKey, status)
 
 	return
@@ -564,13 +596,27 @@ func (mp *metaPartition) fsmAppendObjExtents(ino *Inode) (status uint8) {
 	}
 
 	eks := ino.ObjExtents.CopyExtents()
+	if inode.IsImmutable() {
+		status = proto.OpNotPerm
+		return
+	}
+	if inode.IsAppendOnly() {
+		for _, ek := range eks {
+			if ek.FileOffset < inode.Size {
+				status = proto.OpNotPerm
+				return
+			}
+		}
+	}
 	err := inode.AppendObjExtents(eks, ino.ModifyTime)
 
 	// if err is not nil, means obj eks exist overlap.
 	if err != nil {
 		log.LogErrorf("fsmAppendExtents inode(%v) err(%v)", inode.Inode, err)
 		status = proto.OpConflictExtentsErr
+		return
 	}
+	mp.recordChange(proto.ChangeEventModify, inode.Inode, 0, "")
 	return
 }
 
@@ -593,6 +639,10 @@ func (mp *metaPartition) fsmExtentsTruncate(ino *Inode) (resp *InodeResponse) {
 		resp.Status = proto.OpArgMismatchErr
 		return
 	}
+	if i.IsProtected() {
+		resp.Status = proto.OpNotPerm
+		return
+	}
 
 	doOnLastKey := func(lastKey *proto.ExtentKey) {
 		var eks []proto.ExtentKey
@@ -608,8 +658,11 @@ func (mp *metaPartition) fsmExtentsTruncate(ino *Inode) (resp *InodeResponse) {
 	if err = i.CreateLowerVersion(i.getVer(), mp.multiVersionList); err != nil {
 		return
 	}
-	oldSize := int64(i.Size)
+	oldSize := mp.quotaSize(i)
 	delExtents := i.ExtentsTruncate(ino.Size, ino.ModifyTime, doOnLastKey)
+	mp.recordChange(proto.ChangeEventModify, i.Inode, 0, "")
+	// an extending truncate deletes nothing but still changes the size
+	mp.updateUsedInfo(mp.quotaSize(i)-oldSize, 0, i.Inode)
 
 	if len(delExtents) == 0 {
 		return
@@ -618,7 +671,6 @@ func (mp *metaPartition) fsmExtentsTruncate(ino *Inode) (resp *InodeResponse) {
 	if delExtents, err = i.RestoreExts2NextLayer(delExtents, mp.verSeq, 0); err != nil {
 		panic("RestoreExts2NextLayer should not be error")
 	}
-	mp.updateUsedInfo(int64(i.Size)-oldSize, 0, i.Inode)
 
 	// now we should delete the extent
 	log.LogInfof("fsmExtentsTruncate inode(%v) exts(%v)", i.Inode, delExtents)
@@ -671,6 +723,16 @@ func (mp *metaPartition) fsmBatchEvictInode(ib InodeBatch) (resp []*InodeRespons
 	return
 }
 
+// fsmBatchInodeGet gets the inodes of the batch in one call, each entry is
+// resolved by getInode so the version and the access time are handled the same.
+func (mp *metaPartition) fsmBatchInodeGet(ib InodeBatch) (resp []*InodeResponse) {
+	resp = make([]*InodeResponse, 0, len(ib))
+	for _, ino := range ib {
+		resp = append(resp, mp.getInode(ino, false))
+	}
+	return
+}
+
 func (mp *metaPartition) checkAndInsertFreeList(ino *Inode) {
 	if proto.IsDir(ino.Type) {
 		return
@@ -683,6 +745,53 @@ func (mp *metaPartition) checkAndInsertFreeList(ino *Inode) {
 	}
 }
 
+// fsmTxSetAttr changes the mode and owner of the inode, the inode is kept as
+// it was before so that a rollback of the transaction restores them.
+func (mp *metaPartition) fsmTxSetAttr(req *proto.TxSetAttrRequest) (status uint8) {
+	if mp.txProcessor.txManager.txInRMDone(req.TxInfo.TxID) {
+		log.LogWarnf("fsmTxSetAttr: tx is already finish. txId %s", req.TxInfo.TxID)
+		return proto.OpTxInfoNotExistErr
+	}
+
+	inodeInfo, ok := req.TxInfo.TxInodeInfos[req.Inode]
+	if !ok {
+		return proto.OpTxInodeInfoNotExistErr
+	}
+
+	item := mp.inodeTree.Get(NewInode(req.Inode, 0))
+	if item == nil || item.(*Inode).ShouldDelete() {
+		return proto.OpNotExistErr
+	}
+	if setAttrDenied(item.(*Inode), req.Valid&(proto.AttrMode|proto.AttrUid|proto.AttrGid)) {
+		return proto.OpNotPerm
+	}
+
+	rbInode := NewTxRollbackInode(item.(*Inode).Copy().(*Inode), []uint32{}, inodeInfo, TxUpdate)
+	status = mp.txProcessor.txResource.addTxRollbackInode(rbInode)
+	if status == proto.OpExistErr {
+		// the request is retried, the attributes are set already
+		return proto.OpOk
+	}
+	if status != proto.OpOk {
+		return
+	}
+
+	valid := req.Valid & (proto.AttrMode | proto.AttrUid | proto.AttrGid)
+	if valid != 0 && req.ChangeTime != 0 {
+		valid |= proto.AttrChangeTime
+	}
+	mp.fsmSetAttr(&SetattrRequest{
+		Inode:      req.Inode,
+		Mode:       req.Mode,
+		Uid:        req.Uid,
+		Gid:        req.Gid,
+		ChangeTime: req.ChangeTime,
+		Valid:      valid,
+		VerSeq:     req.VerSeq,
+	})
+	return
+}
+
 func (mp *metaPartition) fsmSetAttr(req *SetattrRequest) (err error) {
 	log.LogDebugf("action[fsmSetAttr] req %v", req)
 	ino := NewInode(req.Inode, req.Mode)
@@ -694,6 +803,10 @@ func (mp *metaPartition) fsmSetAttr(req *SetattrRequest) (err error) {
 	if ino.ShouldDelete() {
 		return
 	}
+	if setAttrDenied(ino, req.Valid) {
+		log.LogWarnf("action[fsmSetAttr] inode %v is immutable or append-only, req %v", ino.Inode, req)
+		return
+	}
 	ino.SetAttr(req)
 	return
 }
@@ -855,7 +968,7 @@ func (mp *metaPartition) fsmSetInodeQuotaBatch(req *proto.BatchSetMetaserverQuot
 		resp.InodeRes[ino] = proto.OpOk
 		if !isExist {
 			files += 1
-			bytes += int64(inode.Size)
+			bytes += mp.quotaSize(inode)
 		}
 	}
 	mp.mqMgr.updateUsedInfo(bytes, files, req.QuotaId)
@@ -925,9 +1038,109 @@ func (mp *metaPartition) fsmDeleteInodeQuotaBatch(req *proto.BatchDeleteMetaserv
 			}
 		}
 		files -= 1
-		bytes -= int64(inode.Size)
+		bytes -= mp.quotaSize(inode)
 	}
 	mp.mqMgr.updateUsedInfo(bytes, files, req.QuotaId)
 	log.LogInfof("fsmDeleteInodeQuotaBatch quotaId [%v] resp [%v] success.", req.QuotaId, resp)
 	return
 }
+
+// checkExtentsAppendable returns OpNotPerm if the extents would modify the data of an
+// immutable inode, or overwrite the existing data of an append-only inode. An extent key
+// of an append-only inode may only start below the size if it extends an existing key
+// in place, as the stream writer does when it keeps appending to the same extent.
+func checkExtentsAppendable(ino *Inode, eks []proto.ExtentKey) (status uint8) {
+	ino.RLock()
+	defer ino.RUnlock()
+	if ino.Flag&InodeImmutable != 0 {
+		return proto.OpNotPerm
+	}
+	if ino.Flag&InodeAppendOnly == 0 {
+		return proto.OpOk
+	}
+	for _, ek := range eks {
+		if ek.FileOffset >= ino.Size {
+			continue
+		}
+		extended := false
+		ino.Extents.Range(func(cur proto.ExtentKey) bool {
+			if cur.FileOffset == ek.FileOffset {
+				extended = cur.PartitionId == ek.PartitionId && cur.ExtentId == ek.ExtentId &&
+					cur.ExtentOffset == ek.ExtentOffset && ek.Size >= cur.Size &&
+					ek.FileOffset+uint64(ek.Size) >= ino.Size
+				return false
+			}
+			return cur.FileOffset < ek.FileOffset
+		})
+		if !extended {
+			return proto.OpNotPerm
+		}
+	}
+	return proto.OpOk
+}
+
+// setAttrDenied returns if the attributes in valid can not be changed because the
+// inode is immutable or append-only, only the access time is still allowed.
+func setAttrDenied(ino *Inode, valid uint32) bool {
+	return valid&(proto.AttrMode|proto.AttrUid|proto.AttrGid|proto.AttrModifyTime|proto.AttrChangeTime) != 0 && ino.IsProtected()
+}
+
+// fsmCloneInode creates req.NewInode referring to the same extents as req.Inode
+// without copying the data. Both inodes are flagged InodeSharedExtents: clients
+// never overwrite the extents of such an inode in place but append new extents
+// instead, and the extents dropped by one of them are kept on the data node while
+// the other still refers to them.
+func (mp *metaPartition) fsmCloneInode(req *proto.CloneInodeRequest) (status uint8) {
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil {
+		return proto.OpNotExistErr
+	}
+	src := item.(*Inode)
+	if src.ShouldDelete() {
+		return proto.OpNotExistErr
+	}
+	if !proto.IsRegular(src.Type) {
+		return proto.OpArgMismatchErr
+	}
+	// snapshots keep their own references on split extents and the data of cold
+	// volumes is in the blob store, neither can be shared this way.
+	if mp.verSeq != 0 || proto.IsCold(mp.volType) {
+		return proto.OpNotPerm
+	}
+	if mp.inodeTree.Has(NewInode(req.NewInode, 0)) {
+		return proto.OpExistErr
+	}
+
+	ino := NewInode(req.NewInode, src.Type)
+	ino.Uid = req.Uid
+	ino.Gid = req.Gid
+	src.RLock()
+	ino.Size = src.Size
+	ino.Extents = NewSortedExtentsFromEks(src.Extents.CopyExtents())
+	src.RUnlock()
+	ino.Flag |= InodeSharedExtents
+	if status = mp.uidManager.addUidSpace(ino.Uid, ino.Inode, ino.Extents.eks); status != proto.OpOk {
+		return
+	}
+	src.SetSharedExtents()
+	mp.inodeTree.ReplaceOrInsert(ino, true)
+	mp.addSharedExtentsInode(src.Inode, ino.Inode)
+	return proto.OpOk
+}
+
+// fsmSetInodeFlags replaces the immutable and append-only flags of a regular file.
+func (mp *metaPartition) fsmSetInodeFlags(req *proto.SetInodeFlagsRequest) (status uint8) {
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil {
+		return proto.OpNotExistErr
+	}
+	ino := item.(*Inode)
+	if ino.ShouldDelete() {
+		return proto.OpNotExistErr
+	}
+	if !proto.IsRegular(ino.Type) {
+		return proto.OpArgMismatchErr
+	}
+	ino.SetAttrFlags(req.Flags)
+	return proto.OpOk
+}
@@ -487,6 +487,22 @@ func (mp *metaPartition) ReadDirLimit(req *ReadDirLimitReq, p *Packet) (err erro
 	return
 }
 
+// ReadDirPlus reads the directory with the attributes of the children inodes.
+func (mp *metaPartition) ReadDirPlus(req *ReadDirPlusReq, p *Packet) (err error) {
+	resp, err := mp.readDirPlus(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
 // Lookup looks up the given dentry from the request.
 func (mp *metaPartition) Lookup(req *LookupReq, p *Packet) (err error) {
 	dentry := &Dentry{
@@ -529,6 +545,85 @@ func (mp *metaPartition) Lookup(req *LookupReq, p *Packet) (err error) {
 	return
 }
 
+// SetDentryAttr sets an attribute of the dentry, other dentries of the same inode are not affected.
+func (mp *metaPartition) SetDentryAttr(req *proto.SetDentryAttrRequest, p *Packet) (err error) {
+	dentry := &Dentry{
+		ParentId: req.ParentID,
+		Name:     req.Name,
+	}
+	dentry.setAttr(req.Key, req.Value)
+	val, err := dentry.Marshal()
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMSetDentryAttr, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.ResultCode = resp.(uint8)
+	return
+}
+
+// getLatestDentry returns the current dentry with the given name, or nil if it does not exist.
+func (mp *metaPartition) getLatestDentry(parentID uint64, name string) *Dentry {
+	item := mp.dentryTree.Get(&Dentry{ParentId: parentID, Name: name})
+	if item == nil {
+		return nil
+	}
+	dentry := item.(*Dentry)
+	if dentry.isDeleted() {
+		return nil
+	}
+	return dentry
+}
+
+func (mp *metaPartition) GetDentryAttr(req *proto.GetDentryAttrRequest, p *Packet) (err error) {
+	dentry := mp.getLatestDentry(req.ParentID, req.Name)
+	if dentry == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	response := &proto.GetDentryAttrResponse{
+		VolName:     req.VolName,
+		PartitionId: req.PartitionId,
+		ParentID:    req.ParentID,
+		Name:        req.Name,
+		Key:         req.Key,
+	}
+	response.Value, _ = dentry.getAttr(req.Key)
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(encoded)
+	return
+}
+
+func (mp *metaPartition) ListDentryAttr(req *proto.ListDentryAttrRequest, p *Packet) (err error) {
+	dentry := mp.getLatestDentry(req.ParentID, req.Name)
+	if dentry == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	response := &proto.ListDentryAttrResponse{
+		VolName:     req.VolName,
+		PartitionId: req.PartitionId,
+		ParentID:    req.ParentID,
+		Name:        req.Name,
+		Attrs:       dentry.listAttrs(),
+	}
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(encoded)
+	return
+}
+
 // GetDentryTree returns the dentry tree stored in the meta partition.
 func (mp *metaPartition) GetDentryTree() *BTree {
 	return mp.dentryTree.GetTree()
@@ -541,3 +636,16 @@ func (mp *metaPartition) GetDentryTreeLen() int {
 	}
 	return mp.dentryTree.Len()
 }
+
+// GetDirChildCount returns the number of entries in the dir ino, without walking them.
+func (mp *metaPartition) GetDirChildCount(ino uint64) (count uint32, err error) {
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	if item == nil {
+		return 0, fmt.Errorf("inode(%v) not exists", ino)
+	}
+	dir := item.(*Inode)
+	if !proto.IsDir(dir.Type) {
+		return 0, fmt.Errorf("inode(%v) is not a dir", ino)
+	}
+	return dir.GetChildCount(), nil
+}
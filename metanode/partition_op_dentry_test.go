@@ -0,0 +1,372 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDentryAttrMarshal(t *testing.T) {
+	// a dentry without attrs keeps the original layout
+	d := &Dentry{ParentId: 1, Name: "a", Inode: 100, Type: FileModeType}
+	require.Len(t, d.MarshalValue(), 12)
+
+	d.setAttr("tag", "blue")
+	d.setAttr("empty", "")
+	raw, err := d.Marshal()
+	require.NoError(t, err)
+	nd := &Dentry{}
+	require.NoError(t, nd.Unmarshal(raw))
+	require.Equal(t, d.Inode, nd.Inode)
+	require.Nil(t, nd.multiSnap)
+	require.Equal(t, d.attrs, nd.attrs)
+
+	// attrs come after the snapshot versions
+	d.setVerSeq(10)
+	d.multiSnap.dentryList = DentryBatch{{ParentId: 1, Name: "a", Inode: 99, Type: FileModeType}}
+	raw, err = d.Marshal()
+	require.NoError(t, err)
+	nd = &Dentry{}
+	require.NoError(t, nd.Unmarshal(raw))
+	require.Equal(t, uint64(10), nd.getSeqFiled())
+	require.Equal(t, 1, nd.getSnapListLen())
+	require.Equal(t, d.attrs, nd.attrs)
+
+	// a truncated attr is reported
+	val := d.MarshalValue()
+	require.Error(t, (&Dentry{}).UnmarshalValue(val[:len(val)-1]))
+}
+
+func getDentryAttr(t *testing.T, mp *metaPartition, parentID uint64, name, key string) string {
+	p := &Packet{}
+	require.NoError(t, mp.GetDentryAttr(&proto.GetDentryAttrRequest{ParentID: parentID, Name: name, Key: key}, p))
+	require.Equal(t, proto.OpOk, p.ResultCode)
+	resp := &proto.GetDentryAttrResponse{}
+	require.NoError(t, json.Unmarshal(p.Data, resp))
+	return resp.Value
+}
+
+func listDentryAttr(t *testing.T, mp *metaPartition, parentID uint64, name string) []string {
+	p := &Packet{}
+	require.NoError(t, mp.ListDentryAttr(&proto.ListDentryAttrRequest{ParentID: parentID, Name: name}, p))
+	require.Equal(t, proto.OpOk, p.ResultCode)
+	resp := &proto.ListDentryAttrResponse{}
+	require.NoError(t, json.Unmarshal(p.Data, resp))
+	return resp.Attrs
+}
+
+func setDentryAttr(mp *metaPartition, parentID uint64, name, key, value string) uint8 {
+	d := &Dentry{ParentId: parentID, Name: name}
+	d.setAttr(key, value)
+	return mp.fsmSetDentryAttr(d)
+}
+
+func TestDentryAttrHardlinks(t *testing.T) {
+	mp := newMetaPartition(10010, &metadataManager{})
+
+	// two hardlinks of the same inode
+	link1 := &Dentry{ParentId: pInodeNum, Name: "link1", Inode: inodeNum, Type: FileModeType}
+	link2 := &Dentry{ParentId: pInodeNum, Name: "link2", Inode: inodeNum, Type: FileModeType}
+	require.Equal(t, proto.OpOk, mp.fsmCreateDentry(link1, true))
+	require.Equal(t, proto.OpOk, mp.fsmCreateDentry(link2, true))
+
+	require.Equal(t, proto.OpOk, setDentryAttr(mp, pInodeNum, "link1", "tag", "red"))
+	require.Equal(t, proto.OpOk, setDentryAttr(mp, pInodeNum, "link2", "tag", "green"))
+	require.Equal(t, proto.OpOk, setDentryAttr(mp, pInodeNum, "link2", "owner", "bob"))
+
+	require.Equal(t, "red", getDentryAttr(t, mp, pInodeNum, "link1", "tag"))
+	require.Equal(t, "green", getDentryAttr(t, mp, pInodeNum, "link2", "tag"))
+	require.Equal(t, "", getDentryAttr(t, mp, pInodeNum, "link1", "owner"))
+	require.Equal(t, []string{"tag"}, listDentryAttr(t, mp, pInodeNum, "link1"))
+	require.Equal(t, []string{"owner", "tag"}, listDentryAttr(t, mp, pInodeNum, "link2"))
+
+	// the attrs of the dentry are not xattrs of the inode
+	require.Nil(t, mp.extendTree.Get(NewExtend(inodeNum)))
+
+	// a copy of the tree is not affected by later updates
+	snap := mp.dentryTree.GetTree()
+	require.Equal(t, proto.OpOk, setDentryAttr(mp, pInodeNum, "link1", "tag", "yellow"))
+	require.Equal(t, "yellow", getDentryAttr(t, mp, pInodeNum, "link1", "tag"))
+	old, _ := snap.Get(&Dentry{ParentId: pInodeNum, Name: "link1"}).(*Dentry).getAttr("tag")
+	require.Equal(t, "red", old)
+
+	// attrs of a missing dentry
+	require.Equal(t, proto.OpNotExistErr, setDentryAttr(mp, pInodeNum, "missing", "tag", "red"))
+	p := &Packet{}
+	require.NoError(t, mp.GetDentryAttr(&proto.GetDentryAttrRequest{ParentID: pInodeNum, Name: "missing", Key: "tag"}, p))
+	require.Equal(t, proto.OpNotExistErr, p.ResultCode)
+}
+
+func readDirByType(mp *metaPartition, parentID uint64, limit uint64, filter uint8) (names []string) {
+	marker := ""
+	for {
+		children := mp.readDirLimit(&ReadDirLimitReq{ParentID: parentID, Marker: marker, Limit: limit, Filter: filter}).Children
+		more := limit > 0 && uint64(len(children)) == limit
+		// the marker is returned again as the first entry of the next page
+		if marker != "" && len(children) > 0 && children[0].Name == marker {
+			children = children[1:]
+		}
+		for _, child := range children {
+			names = append(names, child.Name)
+		}
+		if !more {
+			return
+		}
+		marker = children[len(children)-1].Name
+	}
+}
+
+func TestReadDirLimitByType(t *testing.T) {
+	mp := newMetaPartition(10011, &metadataManager{})
+
+	// files and directories interleaved in name order
+	for i, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		mode := uint32(FileModeType)
+		if i%3 == 0 {
+			mode = uint32(DirModeType)
+		}
+		d := &Dentry{ParentId: pInodeNum, Name: name, Inode: inodeNum + uint64(i), Type: mode}
+		require.Equal(t, proto.OpOk, mp.fsmCreateDentry(d, true))
+	}
+
+	for _, limit := range []uint64{0, 2, 3, 10} {
+		require.Equal(t, []string{"a", "d", "g"}, readDirByType(mp, pInodeNum, limit, proto.ReadDirFilterDirs), "limit %v", limit)
+		require.Equal(t, []string{"b", "c", "e", "f"}, readDirByType(mp, pInodeNum, limit, proto.ReadDirFilterFiles), "limit %v", limit)
+		require.Equal(t, []string{"a", "b", "c", "d", "e", "f", "g"}, readDirByType(mp, pInodeNum, limit, proto.ReadDirFilterAll), "limit %v", limit)
+	}
+
+	// the limit counts the matching entries only
+	resp := mp.readDirLimit(&ReadDirLimitReq{ParentID: pInodeNum, Limit: 2, Filter: proto.ReadDirFilterDirs})
+	require.Len(t, resp.Children, 2)
+	require.Equal(t, "d", resp.Children[1].Name)
+}
+
+func TestDirChildCount(t *testing.T) {
+	testPath := t.TempDir()
+	newPartition := func() *metaPartition {
+		mp := newMetaPartition(10012, &metadataManager{})
+		mp.config.RootDir = testPath
+		mp.mqMgr = NewQuotaManager(mp.config.VolName, mp.config.PartitionId)
+		mp.multiVersionList = &proto.VolVersionInfoList{}
+		mp.uniqChecker = newUniqChecker()
+		return mp
+	}
+	mp := newPartition()
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(pInodeNum, DirModeType)))
+	childCount := func(mp *metaPartition) uint32 {
+		count, err := mp.GetDirChildCount(pInodeNum)
+		require.NoError(t, err)
+		return count
+	}
+	dentry := func(name string, ino uint64) *Dentry {
+		return &Dentry{ParentId: pInodeNum, Name: name, Inode: ino, Type: FileModeType}
+	}
+	require.Equal(t, uint32(0), childCount(mp))
+
+	for i, name := range []string{"a", "b", "c", "d"} {
+		require.Equal(t, proto.OpOk, mp.fsmCreateDentry(dentry(name, inodeNum+uint64(i)), false))
+	}
+	require.Equal(t, uint32(4), childCount(mp))
+
+	// creating an existing entry again counts nothing
+	require.Equal(t, proto.OpOk, mp.fsmCreateDentry(dentry("a", inodeNum), false))
+	require.Equal(t, proto.OpExistErr, mp.fsmCreateDentry(dentry("a", inodeNum+10), false))
+	require.Equal(t, uint32(4), childCount(mp))
+
+	// deleting, missing entries are not counted
+	require.Equal(t, proto.OpOk, mp.fsmDeleteDentry(dentry("d", inodeNum+3), false).Status)
+	require.Equal(t, proto.OpNotExistErr, mp.fsmDeleteDentry(dentry("d", inodeNum+3), false).Status)
+	require.Equal(t, uint32(3), childCount(mp))
+
+	// a rename within the dir links the new name before unlinking the old one
+	require.Equal(t, proto.OpOk, mp.fsmCreateDentry(dentry("e", inodeNum), false))
+	require.Equal(t, proto.OpOk, mp.fsmDeleteDentry(dentry("a", inodeNum), true).Status)
+	require.Equal(t, uint32(3), childCount(mp))
+
+	// a transaction deleting an entry unlinks it from the dir when it commits
+	newTx := func(names ...string) *proto.TransactionInfo {
+		txInfo := proto.NewTransactionInfo(5, proto.TxTypeRemove)
+		for _, name := range names {
+			txDentryInfo := proto.NewTxDentryInfo(MemberAddrs, pInodeNum, name, mp.config.PartitionId)
+			txInfo.TxDentryInfos[txDentryInfo.GetKey()] = txDentryInfo
+		}
+		require.NoError(t, mp.initTxInfo(txInfo))
+		txInfo.TmID = int64(mp.config.PartitionId)
+		require.NoError(t, mp.txProcessor.txManager.registerTransaction(txInfo))
+		return txInfo
+	}
+	txRsc := mp.txProcessor.txResource
+	txInfo := newTx("b")
+	resp := mp.fsmTxDeleteDentry(NewTxDentry(pInodeNum, "b", inodeNum+1, FileModeType, nil, txInfo))
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, uint32(3), childCount(mp))
+	status, err := txRsc.rollbackDentry(&proto.TxDentryApplyRequest{TxID: txInfo.TxID, Pid: pInodeNum, Name: "b"})
+	require.True(t, status == proto.OpOk && err == nil)
+	require.Equal(t, uint32(3), childCount(mp))
+	require.NotNil(t, mp.dentryTree.Get(dentry("b", inodeNum+1)))
+
+	txInfo = newTx("b", "g")
+	resp = mp.fsmTxDeleteDentry(NewTxDentry(pInodeNum, "b", inodeNum+1, FileModeType, nil, txInfo))
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, proto.OpOk, mp.fsmTxCreateDentry(NewTxDentry(pInodeNum, "g", inodeNum+6, FileModeType, nil, txInfo)))
+	require.Equal(t, uint32(4), childCount(mp))
+	for _, name := range []string{"b", "g"} {
+		status, err = txRsc.commitDentry(txInfo.TxID, pInodeNum, name)
+		require.True(t, status == proto.OpOk && err == nil)
+	}
+	require.Equal(t, uint32(3), childCount(mp))
+	require.Nil(t, mp.dentryTree.Get(dentry("b", inodeNum+1)))
+
+	// a transaction creating an entry links it at once, and unlinks it when rolled back
+	txInfo = newTx("h")
+	require.Equal(t, proto.OpOk, mp.fsmTxCreateDentry(NewTxDentry(pInodeNum, "h", inodeNum+7, FileModeType, nil, txInfo)))
+	require.Equal(t, uint32(4), childCount(mp))
+	status, err = txRsc.rollbackDentry(&proto.TxDentryApplyRequest{TxID: txInfo.TxID, Pid: pInodeNum, Name: "h"})
+	require.True(t, status == proto.OpOk && err == nil)
+	require.Equal(t, uint32(3), childCount(mp))
+
+	// the limit compares the nlink of the dir, "." and ".." included
+	defer updateDirChildrenNumLimit(atomic.LoadUint32(&dirChildrenNumLimit))
+	updateDirChildrenNumLimit(5)
+	p := &Packet{}
+	require.Error(t, mp.CreateDentry(&CreateDentryReq{ParentID: pInodeNum, Name: "f", Inode: inodeNum + 5, Mode: FileModeType}, p))
+	require.Equal(t, proto.OpDirQuota, p.ResultCode)
+
+	_, err = mp.GetDirChildCount(inodeNum)
+	require.Error(t, err)
+
+	// the count survives a reload of the partition
+	msg := &storeMsg{
+		command:        1,
+		inodeTree:      mp.inodeTree,
+		dentryTree:     mp.dentryTree,
+		extendTree:     mp.extendTree,
+		multipartTree:  mp.multipartTree,
+		txTree:         mp.txProcessor.txManager.txTree,
+		txRbInodeTree:  mp.txProcessor.txResource.txRbInodeTree,
+		txRbDentryTree: mp.txProcessor.txResource.txRbDentryTree,
+		uniqChecker:    mp.uniqChecker,
+	}
+	require.NoError(t, mp.store(msg))
+	reloaded := newPartition()
+	require.NoError(t, reloaded.LoadSnapshot(path.Join(testPath, snapshotDir)))
+	require.Equal(t, uint32(3), childCount(reloaded))
+	require.Equal(t, 3, reloaded.dentryTree.Len())
+}
+
+func TestCountChildren(t *testing.T) {
+	mp := newMetaPartition(10013, &metadataManager{})
+	for i := 0; i < 5; i++ {
+		d := &Dentry{ParentId: pInodeNum, Name: fmt.Sprintf("a%v", i), Inode: inodeNum + uint64(i), Type: FileModeType}
+		require.Equal(t, proto.OpOk, mp.fsmCreateDentry(d, true))
+	}
+	for i := 0; i < 3; i++ {
+		d := &Dentry{ParentId: pInodeNum + 1, Name: fmt.Sprintf("b%v", i), Inode: inodeNum + 10 + uint64(i), Type: FileModeType}
+		require.Equal(t, proto.OpOk, mp.fsmCreateDentry(d, true))
+	}
+	require.Equal(t, 5, mp.countChildren(pInodeNum))
+	require.Equal(t, 3, mp.countChildren(pInodeNum+1))
+	require.Equal(t, 0, mp.countChildren(pInodeNum+2))
+
+	// the counts of the parents are independent
+	require.Equal(t, proto.OpOk, mp.fsmDeleteDentry(&Dentry{ParentId: pInodeNum, Name: "a0", Inode: inodeNum}, true).Status)
+	require.Equal(t, 4, mp.countChildren(pInodeNum))
+	require.Equal(t, 3, mp.countChildren(pInodeNum+1))
+}
+
+func readDirPlus(t *testing.T, mp *metaPartition, req *ReadDirPlusReq) []proto.DentryPlus {
+	p := &Packet{}
+	require.NoError(t, mp.ReadDirPlus(req, p))
+	require.Equal(t, proto.OpOk, p.ResultCode)
+	resp := &ReadDirPlusResp{}
+	require.NoError(t, json.Unmarshal(p.Data, resp))
+	return resp.Children
+}
+
+func TestReadDirPlus(t *testing.T) {
+	mp := newMetaPartition(10014, &metadataManager{})
+	mp.mqMgr = NewQuotaManager(mp.config.VolName, mp.config.PartitionId)
+	mp.multiVersionList = &proto.VolVersionInfoList{}
+	mp.uniqChecker = newUniqChecker()
+
+	for i := 0; i < 10; i++ {
+		mode := uint32(FileModeType)
+		if i%4 == 0 {
+			mode = uint32(DirModeType)
+		}
+		ino := inodeNum + uint64(i)
+		d := &Dentry{ParentId: pInodeNum, Name: fmt.Sprintf("f%02d", i), Inode: ino, Type: mode}
+		require.Equal(t, proto.OpOk, mp.fsmCreateDentry(d, true))
+		// the inodes of the last entries live in another partition
+		if i < 8 {
+			inode := NewInode(ino, mode)
+			inode.Size = uint64(i * 100)
+			inode.Uid = uint32(i)
+			require.Equal(t, proto.OpOk, mp.fsmCreateInode(inode))
+		}
+	}
+
+	// the same as reading the dir then getting every inode
+	expected := make([]proto.DentryPlus, 0)
+	for _, d := range mp.readDir(&ReadDirReq{ParentID: pInodeNum}).Children {
+		child := proto.DentryPlus{Dentry: d}
+		p := &Packet{}
+		require.NoError(t, mp.InodeGet(&InodeGetReq{Inode: d.Inode}, p))
+		if p.ResultCode == proto.OpOk {
+			resp := &proto.InodeGetResponse{}
+			require.NoError(t, json.Unmarshal(p.Data, resp))
+			child.Info = resp.Info
+		}
+		expected = append(expected, child)
+	}
+	// getting an inode updates its atime
+	clearAtime := func(children []proto.DentryPlus) []proto.DentryPlus {
+		for _, c := range children {
+			if c.Info != nil {
+				c.Info.AccessTime = time.Time{}
+			}
+		}
+		return children
+	}
+	expected = clearAtime(expected)
+	children := clearAtime(readDirPlus(t, mp, &ReadDirPlusReq{ParentID: pInodeNum}))
+	require.Equal(t, expected, children)
+	require.NotNil(t, children[7].Info)
+	require.Nil(t, children[8].Info)
+
+	// paged with the last name as the marker, the marker entry comes again
+	paged := clearAtime(readDirPlus(t, mp, &ReadDirPlusReq{ParentID: pInodeNum, Limit: 4}))
+	for len(paged) < len(expected) {
+		page := readDirPlus(t, mp, &ReadDirPlusReq{ParentID: pInodeNum, Limit: 4, Marker: paged[len(paged)-1].Dentry.Name})
+		require.Equal(t, paged[len(paged)-1].Dentry, page[0].Dentry)
+		paged = append(paged, clearAtime(page[1:])...)
+	}
+	require.Equal(t, expected, paged)
+
+	// filtered like readDirLimit
+	dirs := readDirPlus(t, mp, &ReadDirPlusReq{ParentID: pInodeNum, Filter: proto.ReadDirFilterDirs})
+	require.Len(t, dirs, 3)
+	for _, d := range dirs {
+		require.True(t, proto.IsDir(d.Dentry.Type))
+	}
+}
@@ -16,10 +16,12 @@ package metanode
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
 )
 
 func (mp *metaPartition) UpdateXAttr(req *proto.UpdateXAttrRequest, p *Packet) (err error) {
@@ -221,3 +223,50 @@ func (mp *metaPartition) putExtend(op uint32, extend *Extend) (resp interface{},
 	resp, err = mp.submit(op, marshaled)
 	return
 }
+
+type OrphanExtendGCResp struct {
+	Scanned    int    `json:"scanned"`
+	Reclaimed  int    `json:"reclaimed"`
+	NextMarker uint64 `json:"nextMarker"` // 0 if the scan reaches the end of the partition
+}
+
+// GCOrphanExtends scans at most limit extends from the inode marker and deletes the ones
+// whose inode is gone, which internalDeleteInode should have deleted together with the inode.
+// It runs on the leader, the deletion goes through raft.
+func (mp *metaPartition) GCOrphanExtends(marker uint64, limit int) (resp *OrphanExtendGCResp, err error) {
+	if _, ok := mp.IsLeader(); !ok {
+		return nil, fmt.Errorf("mp(%v) is not the leader", mp.config.PartitionId)
+	}
+
+	resp = &OrphanExtendGCResp{}
+	orphans := make([]uint64, 0)
+	mp.extendTree.AscendGreaterOrEqual(NewExtend(marker), func(i BtreeItem) bool {
+		extend := i.(*Extend)
+		if resp.Scanned >= limit {
+			resp.NextMarker = extend.inode
+			return false
+		}
+		resp.Scanned++
+		ino := NewInode(extend.inode, 0)
+		// an inode marked as deleted is still in the tree, its extend is freed with it
+		if !mp.hasInode(ino) && mp.inodeTree.Get(ino) == nil {
+			orphans = append(orphans, extend.inode)
+		}
+		return true
+	})
+	if len(orphans) == 0 {
+		return
+	}
+
+	val, err := json.Marshal(orphans)
+	if err != nil {
+		return nil, err
+	}
+	r, err := mp.submit(opFSMDeleteOrphanExtends, val)
+	if err != nil {
+		return nil, err
+	}
+	resp.Reclaimed = r.(int)
+	log.LogInfof("GCOrphanExtends: mp(%v) marker(%v) scanned(%v) reclaimed(%v)", mp.config.PartitionId, marker, resp.Scanned, resp.Reclaimed)
+	return
+}
@@ -58,6 +58,19 @@ func (mp *metaPartition) CheckQuota(inodeId uint64, p *Packet) (iParm *Inode, in
 	return
 }
 
+// checkMaxFileSize fails the request if it grows the inode to more than size
+// bytes beyond the max file size of the volume. Shrinking is always allowed.
+func (mp *metaPartition) checkMaxFileSize(ino *Inode, size uint64, p *Packet) (err error) {
+	limit := mp.vol.maxFileSize
+	if limit == 0 || size <= limit || size <= ino.Size {
+		return
+	}
+	err = fmt.Errorf("ino(%v) size(%v) exceeds the max file size(%v) of the volume", ino.Inode, size, limit)
+	log.LogWarnf("checkMaxFileSize: mp(%v) %v", mp.config.PartitionId, err)
+	p.PacketErrorWithBody(proto.OpFileTooLargeErr, []byte(err.Error()))
+	return
+}
+
 // ExtentAppend appends an extent.
 func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Packet) (err error) {
 	if !proto.IsHot(mp.volType) {
@@ -66,11 +79,18 @@ func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Pack
 		return
 	}
 	ino := NewInode(req.Inode, 0)
-	if _, _, err = mp.CheckQuota(req.Inode, p); err != nil {
+	var i *Inode
+	if _, i, err = mp.CheckQuota(req.Inode, p); err != nil {
 		log.LogErrorf("ExtentAppend fail status [%v]", err)
 		return
 	}
 	ext := req.Extent
+	if err = mp.checkMaxFileSize(i, ext.FileOffset+uint64(ext.Size), p); err != nil {
+		return
+	}
+	if err = mp.checkQuotaLimit(i, ext, p); err != nil {
+		return
+	}
 	ino.Extents.Append(ext)
 	val, err := ino.Marshal()
 	if err != nil {
@@ -126,6 +146,12 @@ func (mp *metaPartition) ExtentAppendWithCheck(req *proto.AppendExtentKeyWithChe
 	}
 
 	ext := req.Extent
+	if err = mp.checkMaxFileSize(i, ext.FileOffset+uint64(ext.Size), p); err != nil {
+		return
+	}
+	if err = mp.checkQuotaLimit(i, ext, p); err != nil {
+		return
+	}
 
 	// extent key verSeq not set value since marshal will not include verseq
 	// use inode verSeq instead
@@ -340,7 +366,11 @@ func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest, p *Packet) (e
 			ino.DoReadFunc(func() {
 				resp.Generation = ino.Generation
 				resp.Size = ino.Size
+				shared := ino.Flag&InodeSharedExtents != 0
 				ino.Extents.Range(func(ek proto.ExtentKey) bool {
+					if shared {
+						ek.SetShared()
+					}
 					resp.Extents = append(resp.Extents, ek)
 					log.LogInfof("action[ExtentsList] append ek %v", ek)
 					return true
@@ -419,6 +449,9 @@ func (mp *metaPartition) ExtentsTruncate(req *ExtentsTruncateReq, p *Packet) (er
 		p.PacketErrorWithBody(status, reply)
 		return
 	}
+	if err = mp.checkMaxFileSize(i, req.Size, p); err != nil {
+		return
+	}
 
 	ino.Size = req.Size
 	ino.setVer(mp.verSeq)
@@ -444,14 +477,17 @@ func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p
 		return
 	}
 
-	var ino *Inode
-	if ino, _, err = mp.CheckQuota(req.Inode, p); err != nil {
+	var ino, i *Inode
+	if ino, i, err = mp.CheckQuota(req.Inode, p); err != nil {
 		log.LogErrorf("BatchExtentAppend fail err [%v]", err)
 		return
 	}
 
 	extents := req.Extents
 	for _, extent := range extents {
+		if err = mp.checkMaxFileSize(i, extent.FileOffset+uint64(extent.Size), p); err != nil {
+			return
+		}
 		ino.Extents.Append(extent)
 	}
 	val, err := ino.Marshal()
@@ -470,14 +506,17 @@ func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p
 
 func (mp *metaPartition) BatchObjExtentAppend(req *proto.AppendObjExtentKeysRequest, p *Packet) (err error) {
 
-	var ino *Inode
-	if ino, _, err = mp.CheckQuota(req.Inode, p); err != nil {
+	var ino, i *Inode
+	if ino, i, err = mp.CheckQuota(req.Inode, p); err != nil {
 		log.LogErrorf("BatchObjExtentAppend fail status [%v]", err)
 		return
 	}
 
 	objExtents := req.Extents
 	for _, objExtent := range objExtents {
+		if err = mp.checkMaxFileSize(i, objExtent.FileOffset+objExtent.Size, p); err != nil {
+			return
+		}
 		err = ino.ObjExtents.Append(objExtent)
 		if err != nil {
 			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
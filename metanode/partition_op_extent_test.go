@@ -0,0 +1,68 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtentMaxFileSize(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mp := mockPartitionRaftForQuotaTest(mockCtrl)
+	mp.uidManager = NewUidMgr(VolNameForTest, PartitionIdForTest)
+	mp.multiVersionList = &proto.VolVersionInfoList{}
+	mp.vol.maxFileSize = 16384
+
+	ino := uint64(2)
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(ino, FileModeType)))
+	file := mp.inodeTree.Get(NewInode(ino, 0)).(*Inode)
+
+	appendExtent := func(ek proto.ExtentKey) uint8 {
+		p := &Packet{}
+		mp.ExtentAppendWithCheck(&proto.AppendExtentKeyWithCheckRequest{Inode: ino, Extent: ek}, p)
+		return p.ResultCode
+	}
+	truncate := func(size uint64) uint8 {
+		p := &Packet{}
+		mp.ExtentsTruncate(&ExtentsTruncateReq{Inode: ino, Size: size}, p)
+		return p.ResultCode
+	}
+
+	// writes up to the limit are allowed
+	require.Equal(t, proto.OpOk, appendExtent(proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 8192}))
+	require.Equal(t, proto.OpOk, appendExtent(proto.ExtentKey{FileOffset: 8192, PartitionId: 1, ExtentId: 1026, Size: 8192}))
+	require.Equal(t, uint64(16384), file.Size)
+
+	// growing the file beyond the limit is rejected
+	require.Equal(t, proto.OpFileTooLargeErr, appendExtent(proto.ExtentKey{FileOffset: 16384, PartitionId: 1, ExtentId: 1027, Size: 4096}))
+	require.Equal(t, proto.OpFileTooLargeErr, truncate(16385))
+	require.Equal(t, uint64(16384), file.Size)
+
+	// shrinking the file is always allowed, even if the limit is lowered later
+	mp.vol.maxFileSize = 4096
+	require.Equal(t, proto.OpOk, truncate(8192))
+	require.Equal(t, uint64(8192), file.Size)
+	require.Equal(t, proto.OpFileTooLargeErr, truncate(8193))
+
+	// 0 means unlimited
+	mp.vol.maxFileSize = 0
+	require.Equal(t, proto.OpOk, truncate(1<<40))
+	require.Equal(t, uint64(1<<40), file.Size)
+}
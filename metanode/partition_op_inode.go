@@ -37,6 +37,7 @@ func replyInfoNoCheck(info *proto.InodeInfo, ino *Inode) bool {
 	info.Gid = ino.Gid
 	info.Generation = ino.Generation
 	info.VerSeq = ino.getVer()
+	info.Flags = ino.attrFlags()
 	if length := len(ino.LinkTarget); length > 0 {
 		info.Target = make([]byte, length)
 		copy(info.Target, ino.LinkTarget)
@@ -61,6 +62,7 @@ func replyInfo(info *proto.InodeInfo, ino *Inode, quotaInfos map[uint32]*proto.M
 	info.Gid = ino.Gid
 	info.Generation = ino.Generation
 	info.VerSeq = ino.getVer()
+	info.Flags = ino.attrFlags()
 	if length := len(ino.LinkTarget); length > 0 {
 		info.Target = make([]byte, length)
 		copy(info.Target, ino.LinkTarget)
@@ -166,6 +168,9 @@ func (mp *metaPartition) QuotaCreateInode(req *proto.QuotaCreateInodeRequest, p
 
 	for _, quotaId := range req.QuotaIds {
 		status = mp.mqMgr.IsOverQuota(false, true, quotaId)
+		if status == 0 {
+			status = mp.mqMgr.IsOverQuotaLimit(0, 1, quotaId)
+		}
 		if status != 0 {
 			err = errors.New("create inode is over quota")
 			reply = []byte(err.Error())
@@ -519,14 +524,16 @@ func (mp *metaPartition) InodeGet(req *InodeGetReq, p *Packet) (err error) {
 func (mp *metaPartition) InodeGetBatch(req *InodeGetReqBatch, p *Packet) (err error) {
 
 	resp := &proto.BatchInodeGetResponse{}
-	ino := NewInode(0, 0)
+	ib := make(InodeBatch, 0, len(req.Inodes))
 	for _, inoId := range req.Inodes {
-		var quotaInfos map[uint32]*proto.MetaQuotaInfo
-		ino.Inode = inoId
+		ino := NewInode(inoId, 0)
 		ino.setVer(req.VerSeq)
-		retMsg := mp.getInode(ino, false)
+		ib = append(ib, ino)
+	}
+	for idx, retMsg := range mp.fsmBatchInodeGet(ib) {
+		var quotaInfos map[uint32]*proto.MetaQuotaInfo
 		if mp.mqMgr.EnableQuota() {
-			quotaInfos, err = mp.getInodeQuotaInfos(inoId)
+			quotaInfos, err = mp.getInodeQuotaInfos(ib[idx].Inode)
 			if err != nil {
 				p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 				return
@@ -694,8 +701,21 @@ func (mp *metaPartition) EvictInodeBatch(req *BatchEvictInodeReq, p *Packet) (er
 
 // SetAttr set the inode attributes.
 func (mp *metaPartition) SetAttr(req *SetattrRequest, reqData []byte, p *Packet) (err error) {
+	if item := mp.inodeTree.Get(NewInode(req.Inode, 0)); item != nil && setAttrDenied(item.(*Inode), req.Valid) {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte("inode is immutable or append-only"))
+		return
+	}
+	// chmod and chown change the ctime, the leader picks it so that all the
+	// replicas apply the same one
+	stampCtime := req.Valid&(proto.AttrMode|proto.AttrUid|proto.AttrGid) != 0 && req.Valid&proto.AttrChangeTime == 0
+	if stampCtime {
+		req.ChangeTime = time.Now().Unix()
+		req.Valid |= proto.AttrChangeTime
+	}
 	if mp.verSeq != 0 {
 		req.VerSeq = mp.GetVerSeq()
+	}
+	if mp.verSeq != 0 || stampCtime {
 		reqData, err = json.Marshal(req)
 		if err != nil {
 			log.LogErrorf("setattr: marshal err(%v)", err)
@@ -712,6 +732,82 @@ func (mp *metaPartition) SetAttr(req *SetattrRequest, reqData []byte, p *Packet)
 	return
 }
 
+// TxSetAttr changes the mode and owner of the inode as part of a transaction,
+// e.g. a rename which puts the file in place with its final permissions.
+func (mp *metaPartition) TxSetAttr(req *proto.TxSetAttrRequest, p *Packet) (err error) {
+	if mp.verSeq != 0 {
+		req.VerSeq = mp.GetVerSeq()
+	}
+	if req.Valid&(proto.AttrMode|proto.AttrUid|proto.AttrGid) != 0 {
+		req.ChangeTime = time.Now().Unix()
+	}
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMTxSetAttr, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.ResultCode = resp.(uint8)
+	return
+}
+
+// SetInodeFlags sets the immutable and append-only flags of the inode.
+func (mp *metaPartition) SetInodeFlags(req *proto.SetInodeFlagsRequest, p *Packet) (err error) {
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMSetInodeFlags, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.ResultCode = resp.(uint8)
+	return
+}
+
+// CloneInode creates a new inode sharing the extents of req.Inode.
+func (mp *metaPartition) CloneInode(req *proto.CloneInodeRequest, p *Packet) (err error) {
+	if req.NewInode, err = mp.nextInodeID(); err != nil {
+		p.PacketErrorWithBody(proto.OpInodeFullErr, []byte(err.Error()))
+		return
+	}
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMCloneInode, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	status := resp.(uint8)
+	if status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	item := mp.inodeTree.Get(NewInode(req.NewInode, 0))
+	if item == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	reply := &proto.CloneInodeResponse{Info: &proto.InodeInfo{}}
+	replyInfoNoCheck(reply.Info, item.(*Inode))
+	data, err := json.Marshal(reply)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(data)
+	return
+}
+
 // GetInodeTree returns the inode tree.
 func (mp *metaPartition) GetInodeTree() *BTree {
 	return mp.inodeTree.GetTree()
@@ -831,6 +927,9 @@ func (mp *metaPartition) TxCreateInode(req *proto.TxCreateInodeRequest, p *Packe
 	if defaultQuotaSwitch {
 		for _, quotaId := range req.QuotaIds {
 			status = mp.mqMgr.IsOverQuota(false, true, quotaId)
+			if status == 0 {
+				status = mp.mqMgr.IsOverQuotaLimit(0, 1, quotaId)
+			}
 			if status != 0 {
 				err = errors.New("tx create inode is over quota")
 				reply = []byte(err.Error())
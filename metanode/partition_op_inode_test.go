@@ -0,0 +1,259 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func newFlaggedFile(t *testing.T, mp *metaPartition, ino uint64, flags uint32) {
+	file := NewInode(ino, FileModeType)
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(file))
+	ek := proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 4096}
+	file.Extents = NewSortedExtentsFromEks([]proto.ExtentKey{ek})
+	require.Equal(t, proto.OpOk, mp.fsmAppendExtents(file))
+	require.Equal(t, proto.OpOk, mp.fsmSetInodeFlags(&proto.SetInodeFlagsRequest{Inode: ino, Flags: flags}))
+}
+
+func appendExtentKey(mp *metaPartition, ino uint64, ek proto.ExtentKey) uint8 {
+	file := NewInode(ino, FileModeType)
+	file.Extents = NewSortedExtentsFromEks([]proto.ExtentKey{ek})
+	return mp.fsmAppendExtentsWithCheck(file, false)
+}
+
+func TestInodeFlagsImmutable(t *testing.T) {
+	mp := newMetaPartition(10020, &metadataManager{})
+	newFlaggedFile(t, mp, inodeNum, proto.InodeFlagImmutable)
+
+	info := &proto.InodeInfo{}
+	require.True(t, replyInfo(info, mp.inodeTree.Get(NewInode(inodeNum, 0)).(*Inode), nil))
+	require.Equal(t, proto.InodeFlagImmutable, info.Flags)
+
+	// writes, truncates, links and unlinks are rejected
+	ek := proto.ExtentKey{FileOffset: 4096, PartitionId: 1, ExtentId: 1026, Size: 4096}
+	require.Equal(t, proto.OpNotPerm, appendExtentKey(mp, inodeNum, ek))
+	require.Equal(t, proto.OpNotPerm, mp.fsmExtentsTruncate(&Inode{Inode: inodeNum, Size: 0}).Status)
+	require.Equal(t, proto.OpNotPerm, mp.fsmCreateLinkInode(NewInode(inodeNum, 0), 0).Status)
+	require.Equal(t, proto.OpNotPerm, mp.fsmUnlinkInode(NewInode(inodeNum, 0), 0).Status)
+	require.True(t, setAttrDenied(mp.inodeTree.Get(NewInode(inodeNum, 0)).(*Inode), proto.AttrMode))
+	require.False(t, setAttrDenied(mp.inodeTree.Get(NewInode(inodeNum, 0)).(*Inode), proto.AttrAccessTime))
+
+	ino := mp.inodeTree.Get(NewInode(inodeNum, 0)).(*Inode)
+	require.Equal(t, uint64(4096), ino.Size)
+	require.Equal(t, uint32(1), ino.GetNLink())
+
+	// the data can be changed again once the flag is cleared
+	require.Equal(t, proto.OpOk, mp.fsmSetInodeFlags(&proto.SetInodeFlagsRequest{Inode: inodeNum}))
+	require.Equal(t, proto.OpOk, appendExtentKey(mp, inodeNum, ek))
+	require.Equal(t, uint64(8192), ino.Size)
+
+	// directories have no flags
+	dir := NewInode(inodeNum2, DirModeType)
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(dir))
+	require.Equal(t, proto.OpArgMismatchErr, mp.fsmSetInodeFlags(&proto.SetInodeFlagsRequest{Inode: inodeNum2, Flags: proto.InodeFlagImmutable}))
+}
+
+func TestInodeFlagsAppendOnly(t *testing.T) {
+	mp := newMetaPartition(10021, &metadataManager{})
+	newFlaggedFile(t, mp, inodeNum, proto.InodeFlagAppendOnly)
+	ino := mp.inodeTree.Get(NewInode(inodeNum, 0)).(*Inode)
+
+	// overwriting the existing data is rejected
+	overwrite := proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1026, Size: 4096}
+	require.Equal(t, proto.OpNotPerm, appendExtentKey(mp, inodeNum, overwrite))
+	require.Equal(t, proto.OpNotPerm, mp.fsmExtentsTruncate(&Inode{Inode: inodeNum, Size: 0}).Status)
+	require.Equal(t, proto.OpNotPerm, mp.fsmUnlinkInode(NewInode(inodeNum, 0), 0).Status)
+	require.Equal(t, uint64(4096), ino.Size)
+
+	// appending a new extent and extending the last one in place are allowed
+	appended := proto.ExtentKey{FileOffset: 4096, PartitionId: 1, ExtentId: 1027, Size: 4096}
+	require.Equal(t, proto.OpOk, appendExtentKey(mp, inodeNum, appended))
+	require.Equal(t, uint64(8192), ino.Size)
+	appended.Size = 8192
+	require.Equal(t, proto.OpOk, appendExtentKey(mp, inodeNum, appended))
+	require.Equal(t, uint64(12288), ino.Size)
+
+	// shrinking the last extent is an overwrite
+	appended.Size = 2048
+	require.Equal(t, proto.OpNotPerm, appendExtentKey(mp, inodeNum, appended))
+	require.Equal(t, uint64(12288), ino.Size)
+}
+
+func TestBatchInodeGet(t *testing.T) {
+	mp := newMetaPartition(10032, &metadataManager{})
+	mp.mqMgr = NewQuotaManager(mp.config.VolName, mp.config.PartitionId)
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(inodeNum, FileModeType)))
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(inodeNum2, FileModeType)))
+	deleted := mp.inodeTree.Get(NewInode(inodeNum2, 0)).(*Inode)
+	deleted.SetDeleteMark()
+	file := mp.inodeTree.Get(NewInode(inodeNum, 0)).(*Inode)
+	file.AccessTime = 0
+
+	ib := InodeBatch{NewInode(inodeNum, 0), NewInode(inodeNum2, 0), NewInode(inodeNum3, 0)}
+	resp := mp.fsmBatchInodeGet(ib)
+	require.Equal(t, 3, len(resp))
+	require.Equal(t, proto.OpOk, resp[0].Status)
+	require.Equal(t, uint64(inodeNum), resp[0].Msg.Inode)
+	require.Equal(t, proto.OpNotExistErr, resp[1].Status)
+	require.Equal(t, proto.OpNotExistErr, resp[2].Status)
+
+	// the access time is updated as by a single get
+	require.NotZero(t, file.AccessTime)
+
+	// the batch get of the client only replies the found inodes
+	p := &Packet{}
+	require.NoError(t, mp.InodeGetBatch(&InodeGetReqBatch{Inodes: []uint64{inodeNum, inodeNum2, inodeNum3}}, p))
+	require.Equal(t, proto.OpOk, p.ResultCode)
+	batch := &proto.BatchInodeGetResponse{}
+	require.NoError(t, json.Unmarshal(p.Data, batch))
+	require.Equal(t, 1, len(batch.Infos))
+	require.Equal(t, uint64(inodeNum), batch.Infos[0].Inode)
+}
+
+func TestCloneInode(t *testing.T) {
+	mp := newMetaPartition(10033, &metadataManager{})
+	newFlaggedFile(t, mp, inodeNum, 0)
+	src := mp.inodeTree.Get(NewInode(inodeNum, 0)).(*Inode)
+	gen := src.Generation
+
+	require.Equal(t, proto.OpOk, mp.fsmCloneInode(&proto.CloneInodeRequest{Inode: inodeNum, NewInode: inodeNum2, Uid: 1, Gid: 1}))
+	require.Equal(t, proto.OpExistErr, mp.fsmCloneInode(&proto.CloneInodeRequest{Inode: inodeNum, NewInode: inodeNum2}))
+	require.Equal(t, proto.OpNotExistErr, mp.fsmCloneInode(&proto.CloneInodeRequest{Inode: inodeNum3, NewInode: inodeNum3 + 1}))
+	clone := mp.inodeTree.Get(NewInode(inodeNum2, 0)).(*Inode)
+	require.True(t, src.IsSharedExtents())
+	require.True(t, clone.IsSharedExtents())
+	// the clients holding the extents of the source reload them
+	require.Equal(t, gen+1, src.Generation)
+	require.Equal(t, src.Size, clone.Size)
+	require.Equal(t, uint32(1), clone.Uid)
+
+	// the clone reads the same extents, marked shared for the clients
+	listExtents := func(ino uint64) []proto.ExtentKey {
+		p := &Packet{}
+		require.NoError(t, mp.ExtentsList(&proto.GetExtentsRequest{Inode: ino}, p))
+		require.Equal(t, proto.OpOk, p.ResultCode)
+		resp := &proto.GetExtentsResponse{}
+		require.NoError(t, json.Unmarshal(p.Data, resp))
+		return resp.Extents
+	}
+	eks := listExtents(inodeNum2)
+	require.Equal(t, 1, len(eks))
+	require.True(t, eks[0].IsShared())
+	require.Equal(t, uint64(1025), eks[0].ExtentId)
+	require.Equal(t, src.Extents.CopyExtents()[0].ExtentId, eks[0].ExtentId)
+	require.False(t, src.Extents.CopyExtents()[0].IsShared())
+
+	// writing the source replaces its extent but leaves the clone unchanged, the
+	// dropped extent is not freed while the clone refers to it
+	file := NewInode(inodeNum, FileModeType)
+	file.Extents = NewSortedExtentsFromEks([]proto.ExtentKey{{FileOffset: 0, PartitionId: 1, ExtentId: 1026, Size: 4096}})
+	require.Equal(t, proto.OpOk, mp.fsmAppendExtents(file))
+	require.Equal(t, uint64(1026), listExtents(inodeNum)[0].ExtentId)
+	eks = listExtents(inodeNum2)
+	require.Equal(t, 1, len(eks))
+	require.Equal(t, uint64(1025), eks[0].ExtentId)
+	require.Equal(t, uint32(4096), eks[0].Size)
+
+	var dropped []proto.ExtentKey
+	for len(mp.extDelCh) > 0 {
+		dropped = append(dropped, <-mp.extDelCh...)
+	}
+	require.Equal(t, 1, len(dropped))
+	require.Equal(t, uint64(1025), dropped[0].ExtentId)
+	require.Empty(t, mp.skipSharedExtents(dropped))
+
+	// once the clone is deleted the extent can be freed
+	clone.SetDeleteMark()
+	require.Equal(t, dropped, mp.skipSharedExtents(dropped))
+
+	// only regular files can be cloned
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(inodeNum3, DirModeType)))
+	require.Equal(t, proto.OpArgMismatchErr, mp.fsmCloneInode(&proto.CloneInodeRequest{Inode: inodeNum3, NewInode: inodeNum3 + 1}))
+}
+
+func TestSharedExtentRefs(t *testing.T) {
+	mp := newMetaPartition(10034, &metadataManager{})
+	normal := proto.ExtentKey{PartitionId: 1, ExtentId: 1025, Size: 4096}
+	tiny := proto.ExtentKey{PartitionId: 1, ExtentId: 1, ExtentOffset: 4096, Size: 4096}
+	var inos []*Inode
+	for _, id := range []uint64{inodeNum, inodeNum2} {
+		ino := NewInode(id, FileModeType)
+		ino.Extents = NewSortedExtentsFromEks([]proto.ExtentKey{normal, tiny})
+		ino.SetSharedExtents()
+		mp.inodeTree.ReplaceOrInsert(ino, true)
+		inos = append(inos, ino)
+	}
+	mp.addSharedExtentsInode(inodeNum, inodeNum2)
+
+	refs := mp.sharedExtentRefs()
+	require.Equal(t, 2, refs.refs[sharedExtentID{partitionID: 1, extentID: 1025}])
+	require.True(t, refs.isShared(&normal))
+	require.False(t, refs.isShared(&proto.ExtentKey{PartitionId: 2, ExtentId: 1025}))
+	require.True(t, refs.isShared(&proto.ExtentKey{PartitionId: 1, ExtentId: 1, ExtentOffset: 6144, Size: 4096}))
+	require.False(t, refs.isShared(&proto.ExtentKey{PartitionId: 1, ExtentId: 1, ExtentOffset: 8192, Size: 4096}))
+
+	// the extents stay shared until no live flagged inode refers to them
+	inos[0].SetDeleteMark()
+	require.Empty(t, mp.skipSharedExtents([]proto.ExtentKey{normal, tiny}))
+	mp.inodeTree.Delete(inos[1])
+	require.Equal(t, []proto.ExtentKey{normal, tiny}, mp.skipSharedExtents([]proto.ExtentKey{normal, tiny}))
+}
+
+func TestSetAttrChangeTime(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mp := mockPartitionRaftForQuotaTest(mockCtrl)
+	ino := NewInode(inodeNum, FileModeType)
+	ino.CreateTime = 1000
+	mp.inodeTree.ReplaceOrInsert(ino, true)
+
+	setAttr := func(req *SetattrRequest) {
+		req.Inode = inodeNum
+		data, err := json.Marshal(req)
+		require.NoError(t, err)
+		p := &Packet{}
+		require.NoError(t, mp.SetAttr(req, data, p))
+		require.Equal(t, proto.OpOk, p.ResultCode)
+	}
+
+	// changing the access time leaves the ctime alone
+	setAttr(&SetattrRequest{AccessTime: 2000, Valid: proto.AttrAccessTime})
+	require.Equal(t, int64(1000), ino.CreateTime)
+
+	// chmod bumps the ctime
+	before := time.Now().Unix()
+	setAttr(&SetattrRequest{Mode: FileModeType | 0600, Valid: proto.AttrMode})
+	require.Equal(t, FileModeType|0600, ino.Type)
+	require.True(t, ino.CreateTime >= before)
+
+	// an explicit ctime is kept and survives a marshal round trip
+	setAttr(&SetattrRequest{Uid: 1, ChangeTime: 3000, Valid: proto.AttrUid | proto.AttrChangeTime})
+	require.Equal(t, int64(3000), ino.CreateTime)
+	data, err := ino.Marshal()
+	require.NoError(t, err)
+	loaded := NewInode(0, 0)
+	require.NoError(t, loaded.Unmarshal(data))
+	require.Equal(t, int64(3000), loaded.CreateTime)
+
+	info := &proto.InodeInfo{}
+	require.True(t, replyInfo(info, ino, nil))
+	require.Equal(t, int64(3000), info.CreateTime.Unix())
+}
@@ -16,6 +16,8 @@ package metanode
 
 import (
 	"encoding/json"
+	"fmt"
+	"sync"
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/log"
@@ -44,6 +46,82 @@ func (mp *metaPartition) batchSetInodeQuota(req *proto.BatchSetMetaserverQuotaRe
 	return
 }
 
+// setSubtreeQuota walks the directories of the request breadth first and associates the quota
+// with every descendant inode held by this partition. At most req.Limit dentries are visited per
+// call and applied with a single raft proposal; the response carries the cursor to resume from,
+// so a huge subtree never turns into one huge apply.
+func (mp *metaPartition) setSubtreeQuota(req *proto.SetSubtreeQuotaRequest, resp *proto.SetSubtreeQuotaResponse) (err error) {
+	limit := uint64(req.Limit)
+	if limit == 0 {
+		limit = defaultSubtreeQuotaLimit
+	}
+	var (
+		inodes  []uint64
+		visited uint64
+		dirs    = append([]uint64{}, req.Dirs...)
+		marker  = req.Marker
+	)
+	for len(dirs) > 0 && visited < limit {
+		rdReq := &ReadDirLimitReq{
+			ParentID: dirs[0],
+			Marker:   marker,
+			Limit:    limit - visited,
+			VerSeq:   mp.verSeq,
+		}
+		if marker != "" {
+			// the marker itself is returned again
+			rdReq.Limit++
+		}
+		children := mp.readDirLimit(rdReq).Children
+		exhausted := uint64(len(children)) < rdReq.Limit
+		if marker != "" && len(children) > 0 && children[0].Name == marker {
+			children = children[1:]
+		}
+		for _, child := range children {
+			visited++
+			if !mp.isLocalInode(child.Inode) {
+				resp.RemoteInodes = append(resp.RemoteInodes, child.Inode)
+				if proto.IsDir(child.Type) {
+					resp.RemoteDirs = append(resp.RemoteDirs, child.Inode)
+				}
+				continue
+			}
+			inodes = append(inodes, child.Inode)
+			if proto.IsDir(child.Type) {
+				dirs = append(dirs, child.Inode)
+			}
+		}
+		if exhausted {
+			dirs = dirs[1:]
+			marker = ""
+		} else if len(children) > 0 {
+			marker = children[len(children)-1].Name
+		}
+	}
+	resp.Dirs = dirs
+	resp.Marker = marker
+
+	batchReq := &proto.BatchSetMetaserverQuotaReuqest{
+		PartitionId: req.PartitionId,
+		Inodes:      inodes,
+		QuotaId:     req.QuotaId,
+	}
+	batchResp := &proto.BatchSetMetaserverQuotaResponse{}
+	if err = mp.batchSetInodeQuota(batchReq, batchResp); err != nil {
+		log.LogErrorf("setSubtreeQuota mp [%v] quotaId [%v] inodes [%v] err [%v]", mp.config.PartitionId,
+			req.QuotaId, len(inodes), err)
+		return
+	}
+	resp.InodeRes = batchResp.InodeRes
+	log.LogInfof("setSubtreeQuota mp [%v] quotaId [%v] visited [%v] local [%v] remote [%v] left dirs [%v]",
+		mp.config.PartitionId, req.QuotaId, visited, len(inodes), len(resp.RemoteInodes), len(resp.Dirs))
+	return
+}
+
+func (mp *metaPartition) isLocalInode(ino uint64) bool {
+	return ino >= mp.config.Start && ino <= mp.config.End
+}
+
 func (mp *metaPartition) batchDeleteInodeQuota(req *proto.BatchDeleteMetaserverQuotaReuqest,
 	resp *proto.BatchDeleteMetaserverQuotaResponse) (err error) {
 	if len(req.Inodes) == 0 {
@@ -98,7 +176,7 @@ func (mp *metaPartition) statisticExtendByLoad(extend *Extend) {
 			if isFind {
 				baseInfo = value.(proto.QuotaUsedInfo)
 			}
-			baseInfo.UsedBytes += int64(ino.Size)
+			baseInfo.UsedBytes += mp.quotaSize(ino)
 			baseInfo.UsedFiles += 1
 			mqMgr.statisticBase.Store(quotaId, baseInfo)
 			log.LogDebugf("[statisticExtendByLoad] quotaId [%v] baseInfo [%v]", quotaId, baseInfo)
@@ -110,6 +188,11 @@ func (mp *metaPartition) statisticExtendByLoad(extend *Extend) {
 }
 
 func (mp *metaPartition) statisticExtendByStore(extend *Extend, inodeTree *BTree) {
+	mp.statisticExtendTo(extend, mp.mqMgr.statisticRebuildBase)
+}
+
+// statisticExtendTo adds the usage of the inode of extend to the quotas in base.
+func (mp *metaPartition) statisticExtendTo(extend *Extend, base *sync.Map) {
 	mqMgr := mp.mqMgr
 	ino := NewInode(extend.GetInode(), 0)
 
@@ -138,13 +221,13 @@ func (mp *metaPartition) statisticExtendByStore(extend *Extend, inodeTree *BTree
 	defer mqMgr.rwlock.Unlock()
 	for quotaId := range quotaInfos.QuotaInfoMap {
 		var baseInfo proto.QuotaUsedInfo
-		value, isFind := mqMgr.statisticRebuildBase.Load(quotaId)
+		value, isFind := base.Load(quotaId)
 		if isFind {
 			baseInfo = value.(proto.QuotaUsedInfo)
 		}
-		baseInfo.UsedBytes += int64(ino.Size)
+		baseInfo.UsedBytes += mp.quotaSize(ino)
 		baseInfo.UsedFiles += 1
-		mqMgr.statisticRebuildBase.Store(quotaId, baseInfo)
+		base.Store(quotaId, baseInfo)
 		log.LogDebugf("[statisticExtendByStore] mp [%v] quotaId [%v] inode [%v] baseInfo [%v]",
 			mp.config.PartitionId, quotaId, extend.GetInode(), baseInfo)
 	}
@@ -152,6 +235,59 @@ func (mp *metaPartition) statisticExtendByStore(extend *Extend, inodeTree *BTree
 	return
 }
 
+// quotaSize returns the bytes of the inode charged to its quotas. By default it
+// is the file size, a volume with quotaByAllocated charges the bytes of the
+// extent keys instead, thus the holes of sparse files are free.
+func (mp *metaPartition) quotaSize(ino *Inode) int64 {
+	if !mp.config.QuotaByAllocated || proto.IsCold(mp.volType) {
+		return int64(ino.Size)
+	}
+	return int64(ino.Extents.LayerSize())
+}
+
+// syncQuotaByAllocated proposes the quotaByAllocated setting of the volume to the
+// partition when it differs, so the replicas switch at the same log index.
+func (mp *metaPartition) syncQuotaByAllocated() {
+	byAllocated := mp.vol.quotaByAllocated
+	if byAllocated == mp.config.QuotaByAllocated {
+		return
+	}
+	if _, ok := mp.IsLeader(); !ok {
+		return
+	}
+	val, err := json.Marshal(byAllocated)
+	if err != nil {
+		log.LogErrorf("syncQuotaByAllocated: mp(%v) marshal err(%v)", mp.config.PartitionId, err)
+		return
+	}
+	if _, err = mp.submit(opFSMQuotaByAllocated, val); err != nil {
+		log.LogErrorf("syncQuotaByAllocated: mp(%v) byAllocated(%v) submit err(%v)",
+			mp.config.PartitionId, byAllocated, err)
+	}
+}
+
+// fsmSetQuotaByAllocated switches the way the quotas are charged. The usage
+// counted so far can not be converted, so it is counted again from the inodes.
+func (mp *metaPartition) fsmSetQuotaByAllocated(byAllocated bool) (status uint8) {
+	status = proto.OpOk
+	// the config is persisted at once, a replay of the log may apply it again
+	if mp.config.QuotaByAllocated == byAllocated {
+		return
+	}
+	mp.config.QuotaByAllocated = byAllocated
+	if err := mp.persistMetadata(); err != nil {
+		log.LogErrorf("fsmSetQuotaByAllocated: mp(%v) persist err(%v)", mp.config.PartitionId, err)
+	}
+	base := new(sync.Map)
+	mp.extendTree.Ascend(func(i BtreeItem) bool {
+		mp.statisticExtendTo(i.(*Extend), base)
+		return true
+	})
+	mp.mqMgr.statisticReset(base)
+	log.LogInfof("fsmSetQuotaByAllocated: mp(%v) byAllocated(%v)", mp.config.PartitionId, byAllocated)
+	return
+}
+
 func (mp *metaPartition) updateUsedInfo(size int64, files int64, ino uint64) {
 	quotaIds, isFind := mp.isExistQuota(ino)
 	if isFind {
@@ -208,6 +344,42 @@ func (mp *metaPartition) isOverQuota(ino uint64, size bool, files bool) (status
 	return
 }
 
+// isOverQuotaLimit is IsOverQuotaLimit for each quota of the inode.
+func (mp *metaPartition) isOverQuotaLimit(ino uint64, size int64, files int64) (status uint8) {
+	quotaIds, isFind := mp.isExistQuota(ino)
+	if isFind {
+		for _, quotaId := range quotaIds {
+			if status = mp.mqMgr.IsOverQuotaLimit(size, files, quotaId); status != 0 {
+				log.LogWarnf("isOverQuotaLimit ino [%v] quotaId [%v] size [%v] files [%v] status [%v]", ino, quotaId, size, files, status)
+				return
+			}
+		}
+	}
+	return
+}
+
+// checkQuotaLimit fails the request if appending ek to the inode would exceed the
+// max bytes of one of its quotas. Overwrites do not grow the file size, they are
+// charged the whole extent with quotaByAllocated since the replaced part is not
+// known before the apply.
+func (mp *metaPartition) checkQuotaLimit(ino *Inode, ek proto.ExtentKey, p *Packet) (err error) {
+	var size int64
+	if !mp.config.QuotaByAllocated || proto.IsCold(mp.volType) {
+		size = int64(ek.FileOffset+uint64(ek.Size)) - int64(ino.Size)
+	} else {
+		size = int64(ek.Size)
+	}
+	if size <= 0 {
+		return
+	}
+	if status := mp.isOverQuotaLimit(ino.Inode, size, 0); status != 0 {
+		err = fmt.Errorf("ino(%v) appending %v bytes exceeds the dir quota", ino.Inode, size)
+		log.LogWarnf("checkQuotaLimit: mp(%v) %v", mp.config.PartitionId, err)
+		p.PacketErrorWithBody(status, []byte(err.Error()))
+	}
+	return
+}
+
 func (mp *metaPartition) getInodeQuota(inode uint64, p *Packet) (err error) {
 	var extend = NewExtend(inode)
 	var quotaInfos = &proto.MetaQuotaInfos{
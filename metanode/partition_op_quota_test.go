@@ -15,6 +15,9 @@
 package metanode
 
 import (
+	"fmt"
+	"os"
+	"sync"
 	"testing"
 
 	raftstoremock "github.com/cubefs/cubefs/metanode/mocktest/raftstore"
@@ -152,3 +155,233 @@ func mockPartitionRaftForQuotaTest(ctrl *gomock.Controller) *metaPartition {
 
 	return partition
 }
+
+func TestSetSubtreeQuota(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mp := mockPartitionRaftForQuotaTest(mockCtrl)
+	mp.config.Start = 1
+	mp.config.End = 1000
+
+	// /dir(10) holds 5 files and /dir/sub(20) holds 3 more files,
+	// /dir/remote(2000) belongs to another partition.
+	addDentry := func(parent, ino uint64, name string, mode uint32, size uint64) {
+		if ino <= mp.config.End {
+			inode := NewInode(ino, mode)
+			inode.Size = size
+			inode.NLink = 1
+			mp.inodeTree.ReplaceOrInsert(inode, true)
+		}
+		mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: parent, Name: name, Inode: ino, Type: mode}, true)
+	}
+	addDentry(proto.RootIno, 10, "dir", uint32(os.ModeDir), 0)
+	for i := uint64(0); i < 5; i++ {
+		addDentry(10, 100+i, fmt.Sprintf("f%d", i), 0644, 10)
+	}
+	addDentry(10, 20, "sub", uint32(os.ModeDir), 0)
+	for i := uint64(0); i < 3; i++ {
+		addDentry(20, 200+i, fmt.Sprintf("f%d", i), 0644, 100)
+	}
+	addDentry(10, 2000, "remote", uint32(os.ModeDir), 0)
+
+	var quotaId uint32 = 1
+	req := &proto.SetSubtreeQuotaRequest{
+		PartitionId: PartitionIdForTest,
+		QuotaId:     quotaId,
+		Dirs:        []uint64{10},
+		Limit:       2,
+	}
+	var remoteDirs, remoteInodes []uint64
+	for rounds := 0; len(req.Dirs) > 0; rounds++ {
+		require.True(t, rounds < 10, "subtree walk does not finish")
+		resp := &proto.SetSubtreeQuotaResponse{}
+		require.NoError(t, mp.setSubtreeQuota(req, resp))
+		require.True(t, len(resp.InodeRes)+len(resp.RemoteInodes) <= int(req.Limit))
+		remoteDirs = append(remoteDirs, resp.RemoteDirs...)
+		remoteInodes = append(remoteInodes, resp.RemoteInodes...)
+		req.Dirs, req.Marker = resp.Dirs, resp.Marker
+	}
+
+	require.Equal(t, []uint64{2000}, remoteDirs)
+	require.Equal(t, []uint64{2000}, remoteInodes)
+	for _, ino := range []uint64{20, 100, 101, 102, 103, 104, 200, 201, 202} {
+		quotaIds, isFind := mp.isExistQuota(ino)
+		require.True(t, isFind, "inode %v", ino)
+		require.Equal(t, []uint32{quotaId}, quotaIds)
+	}
+	size, files := mp.mqMgr.getUsedInfoForTest(quotaId)
+	require.Equal(t, int64(5*10+3*100), size)
+	require.Equal(t, int64(9), files)
+
+	// applying again must not count anything twice
+	req = &proto.SetSubtreeQuotaRequest{PartitionId: PartitionIdForTest, QuotaId: quotaId, Dirs: []uint64{10}}
+	resp := &proto.SetSubtreeQuotaResponse{}
+	require.NoError(t, mp.setSubtreeQuota(req, resp))
+	require.Empty(t, resp.Dirs)
+	size, files = mp.mqMgr.getUsedInfoForTest(quotaId)
+	require.Equal(t, int64(350), size)
+	require.Equal(t, int64(9), files)
+}
+
+func TestQuotaByAllocatedSize(t *testing.T) {
+	const hole = 1 << 20
+	testCases := []struct {
+		byAllocated bool
+		used        []int64 // after the sparse write, the overwrite of the hole, the extending and the shrinking truncate
+	}{
+		{byAllocated: false, used: []int64{hole + 4096, hole + 4096, 2 * hole, 4096}},
+		{byAllocated: true, used: []int64{4096, 8192, 8192, 4096}},
+	}
+	for _, tc := range testCases {
+		mp := newMetaPartition(PartitionIdForTest, &metadataManager{})
+		mp.mqMgr = NewQuotaManager(VolNameForTest, PartitionIdForTest)
+		mp.multiVersionList = &proto.VolVersionInfoList{}
+		mp.config.QuotaByAllocated = tc.byAllocated
+		var quotaId uint32 = 1
+
+		require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(2, FileModeType)))
+		req := &proto.BatchSetMetaserverQuotaReuqest{
+			PartitionId: PartitionIdForTest,
+			Inodes:      []uint64{2},
+			QuotaId:     quotaId,
+		}
+		mp.fsmSetInodeQuotaBatch(req)
+		size, files := mp.mqMgr.getUsedInfoForTest(quotaId)
+		require.Equal(t, int64(0), size)
+		require.Equal(t, int64(1), files)
+
+		for i, ek := range []proto.ExtentKey{
+			{FileOffset: hole, PartitionId: 1, ExtentId: 1025, Size: 4096},
+			{FileOffset: 0, PartitionId: 1, ExtentId: 1026, Size: 4096},
+		} {
+			file := NewInode(2, FileModeType)
+			file.Extents = NewSortedExtentsFromEks([]proto.ExtentKey{ek})
+			require.Equal(t, proto.OpOk, mp.fsmAppendExtents(file))
+			size, _ = mp.mqMgr.getUsedInfoForTest(quotaId)
+			require.Equal(t, tc.used[i], size, "byAllocated %v", tc.byAllocated)
+		}
+		for i, fileSize := range []uint64{2 * hole, 4096} {
+			require.Equal(t, proto.OpOk, mp.fsmExtentsTruncate(&Inode{Inode: 2, Size: fileSize}).Status)
+			size, _ = mp.mqMgr.getUsedInfoForTest(quotaId)
+			require.Equal(t, tc.used[i+2], size, "byAllocated %v", tc.byAllocated)
+		}
+
+		// the rebuilt usage matches the accumulated one
+		mp.mqMgr.statisticRebuildStart()
+		mp.statisticExtendByStore(mp.extendTree.Get(NewExtend(2)).(*Extend), mp.inodeTree)
+		base, _ := mp.mqMgr.statisticRebuildBase.Load(quotaId)
+		require.Equal(t, tc.used[3], base.(proto.QuotaUsedInfo).UsedBytes)
+		mp.mqMgr.statisticRebuildFin(false)
+
+		require.Equal(t, proto.OpOk, mp.fsmUnlinkInode(NewInode(2, 0), 0).Status)
+		size, files = mp.mqMgr.getUsedInfoForTest(quotaId)
+		require.Equal(t, int64(0), size)
+		require.Equal(t, int64(0), files)
+	}
+}
+
+func TestQuotaByAllocatedSwitch(t *testing.T) {
+	const hole = 1 << 20
+	mp := newMetaPartition(PartitionIdForTest, &metadataManager{})
+	mp.mqMgr = NewQuotaManager(VolNameForTest, PartitionIdForTest)
+	mp.mqMgr.enable = true
+	mp.multiVersionList = &proto.VolVersionInfoList{}
+	var quotaId uint32 = 1
+
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(2, FileModeType)))
+	mp.fsmSetInodeQuotaBatch(&proto.BatchSetMetaserverQuotaReuqest{
+		PartitionId: PartitionIdForTest,
+		Inodes:      []uint64{2},
+		QuotaId:     quotaId,
+	})
+	file := NewInode(2, FileModeType)
+	file.Extents = NewSortedExtentsFromEks([]proto.ExtentKey{{FileOffset: hole, PartitionId: 1, ExtentId: 1025, Size: 4096}})
+	require.Equal(t, proto.OpOk, mp.fsmAppendExtents(file))
+	size, files := totalUsedInfo(mp.mqMgr, quotaId)
+	require.Equal(t, int64(hole+4096), size)
+	require.Equal(t, int64(1), files)
+
+	// a rebuild started before the switch counts the old way and is dropped
+	require.True(t, mp.mqMgr.statisticRebuildStart())
+	mp.statisticExtendByStore(mp.extendTree.Get(NewExtend(2)).(*Extend), mp.inodeTree)
+
+	// the data written before the switch is charged the new way
+	require.Equal(t, proto.OpOk, mp.fsmSetQuotaByAllocated(true))
+	size, files = totalUsedInfo(mp.mqMgr, quotaId)
+	require.Equal(t, int64(4096), size)
+	require.Equal(t, int64(1), files)
+	mp.mqMgr.statisticRebuildFin(true)
+	size, _ = totalUsedInfo(mp.mqMgr, quotaId)
+	require.Equal(t, int64(4096), size)
+
+	// unlinking after the switch takes back what is charged now
+	require.Equal(t, proto.OpOk, mp.fsmUnlinkInode(NewInode(2, 0), 0).Status)
+	size, files = totalUsedInfo(mp.mqMgr, quotaId)
+	require.Equal(t, int64(0), size)
+	require.Equal(t, int64(0), files)
+}
+
+// totalUsedInfo returns the usage of the quota counted in the base and the temp.
+func totalUsedInfo(mqMgr *MetaQuotaManager, quotaId uint32) (size int64, files int64) {
+	for _, m := range []*sync.Map{mqMgr.statisticBase, mqMgr.statisticTemp} {
+		if value, ok := m.Load(quotaId); ok {
+			size += value.(proto.QuotaUsedInfo).UsedBytes
+			files += value.(proto.QuotaUsedInfo).UsedFiles
+		}
+	}
+	return
+}
+
+func TestQuotaLimitOnAppend(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mp := mockPartitionRaftForQuotaTest(mockCtrl)
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+
+	var quotaId uint32 = 1
+	for _, ino := range []uint64{2, 3} {
+		mp.inodeTree.ReplaceOrInsert(NewInode(ino, FileModeType), true)
+		mp.setInodeQuota([]uint32{quotaId}, ino)
+		mp.mqMgr.updateUsedInfo(0, 1, quotaId)
+	}
+	hbInfo := &proto.QuotaHeartBeatInfo{
+		VolName:  VolNameForTest,
+		QuotaId:  quotaId,
+		Enable:   true,
+		MaxFiles: 2,
+		MaxBytes: 8192,
+	}
+	mp.mqMgr.setQuotaHbInfo([]*proto.QuotaHeartBeatInfo{hbInfo})
+
+	appendExtent := func(ino uint64, fileOffset uint64, extentId uint64) uint8 {
+		p := &Packet{}
+		req := &proto.AppendExtentKeyWithCheckRequest{
+			PartitionID: PartitionIdForTest,
+			Inode:       ino,
+			Extent:      proto.ExtentKey{FileOffset: fileOffset, PartitionId: 1, ExtentId: extentId, Size: 4096},
+		}
+		mp.ExtentAppendWithCheck(req, p)
+		return p.ResultCode
+	}
+
+	// fill the quota to the limit, the next append is rejected
+	require.Equal(t, proto.OpOk, appendExtent(2, 0, 1025))
+	require.Equal(t, proto.OpOk, appendExtent(3, 0, 1026))
+	size, _ := mp.mqMgr.getUsedInfoForTest(quotaId)
+	require.Equal(t, int64(8192), size)
+	require.Equal(t, proto.OpDirQuota, appendExtent(2, 4096, 1027))
+	require.Equal(t, uint64(4096), mp.inodeTree.Get(NewInode(2, 0)).(*Inode).Size)
+
+	// no more files can be created in the quota either
+	require.Equal(t, proto.OpDirQuota, mp.mqMgr.IsOverQuotaLimit(0, 1, quotaId))
+
+	// deleting a file frees room
+	require.Equal(t, proto.OpOk, mp.fsmUnlinkInode(NewInode(3, 0), 0).Status)
+	require.Equal(t, uint8(0), mp.mqMgr.IsOverQuotaLimit(0, 1, quotaId))
+	require.Equal(t, proto.OpOk, appendExtent(2, 4096, 1027))
+
+	// the limit is only enforced on volumes with quota enabled
+	hbInfo.Enable = false
+	mp.mqMgr.setQuotaHbInfo([]*proto.QuotaHeartBeatInfo{hbInfo})
+	require.Equal(t, proto.OpOk, appendExtent(2, 8192, 1028))
+}
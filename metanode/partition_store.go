@@ -84,6 +84,7 @@ func (mp *metaPartition) loadMetadata() (err error) {
 	mp.config.Start = mConf.Start
 	mp.config.End = mConf.End
 	mp.config.Peers = mConf.Peers
+	mp.config.QuotaByAllocated = mConf.QuotaByAllocated
 	mp.config.Cursor = mp.config.Start
 	mp.config.UniqId = 0
 
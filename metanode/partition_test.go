@@ -137,3 +137,40 @@ func TestMetaPartition_LoadSnapshot(t *testing.T) {
 	require.Equal(t, ErrSnapshotCrcMismatch, err)
 
 }
+
+func TestMetaPartition_UpdatePartitionVolName(t *testing.T) {
+	testPath := "/tmp/testMetaPartitionUpdate/"
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	mp := &metaPartition{config: &MetaPartitionConfig{
+		PartitionId:   1,
+		VolName:       "test_vol",
+		Start:         0,
+		End:           100,
+		PartitionType: 1,
+		Peers:         []proto.Peer{{ID: 1, Addr: "127.0.0.1:17210"}},
+		RootDir:       testPath,
+	}}
+	loadConfig := func() *MetaPartitionConfig {
+		loaded := &metaPartition{config: &MetaPartitionConfig{RootDir: testPath}}
+		require.NoError(t, loaded.loadMetadata())
+		return loaded.config
+	}
+
+	// the vol is renamed on the master
+	status, err := mp.fsmUpdatePartition(&UpdatePartitionReq{PartitionID: 1, VolName: "renamed_vol", End: 200})
+	require.NoError(t, err)
+	require.Equal(t, proto.OpOk, status)
+	require.Equal(t, "renamed_vol", mp.config.VolName)
+	conf := loadConfig()
+	require.Equal(t, "renamed_vol", conf.VolName)
+	require.EqualValues(t, 200, conf.End)
+
+	// a request without a vol name keeps the current one
+	status, err = mp.fsmUpdatePartition(&UpdatePartitionReq{PartitionID: 1, End: 300})
+	require.NoError(t, err)
+	require.Equal(t, proto.OpOk, status)
+	conf = loadConfig()
+	require.Equal(t, "renamed_vol", conf.VolName)
+	require.EqualValues(t, 300, conf.End)
+}
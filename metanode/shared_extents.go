@@ -0,0 +1,174 @@
+// Copyright 2018 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// sharedExtents tracks the inodes flagged InodeSharedExtents. An extent dropped by
+// one of them may still be referenced by another one, so it must not be freed on
+// the data node until no live inode of the set refers to it anymore.
+type sharedExtents struct {
+	sync.Mutex
+	loaded bool
+	inodes map[uint64]struct{}
+}
+
+// loadSharedExtents scans the inode tree once for the flagged inodes, the set is
+// not persisted since the flag is. The caller must hold the lock.
+func (mp *metaPartition) loadSharedExtents() {
+	se := &mp.sharedExts
+	if se.loaded {
+		return
+	}
+	se.inodes = make(map[uint64]struct{})
+	if mp.inodeTree != nil {
+		mp.inodeTree.GetTree().Ascend(func(i BtreeItem) bool {
+			if ino := i.(*Inode); ino.IsSharedExtents() {
+				se.inodes[ino.Inode] = struct{}{}
+			}
+			return true
+		})
+	}
+	se.loaded = true
+}
+
+// resetSharedExtents makes the set reload from a new inode tree, like the one of
+// an applied snapshot.
+func (mp *metaPartition) resetSharedExtents() {
+	se := &mp.sharedExts
+	se.Lock()
+	se.loaded = false
+	se.inodes = nil
+	se.Unlock()
+}
+
+func (mp *metaPartition) addSharedExtentsInode(inos ...uint64) {
+	se := &mp.sharedExts
+	se.Lock()
+	defer se.Unlock()
+	mp.loadSharedExtents()
+	for _, ino := range inos {
+		se.inodes[ino] = struct{}{}
+	}
+}
+
+// sharedExtentID identifies an extent on the data nodes.
+type sharedExtentID struct {
+	partitionID uint64
+	extentID    uint64
+}
+
+// sharedExtentRefs indexes the extent keys of the live inodes flagged
+// InodeSharedExtents. Normal extents belong to a single file so the extent id is
+// enough, tiny extents are shared by all files and only an overlapping range counts.
+type sharedExtentRefs struct {
+	refs map[sharedExtentID]int               // number of keys referring to a normal extent
+	tiny map[sharedExtentID][]proto.ExtentKey // keys referring to a tiny extent
+}
+
+func (r *sharedExtentRefs) isShared(ek *proto.ExtentKey) bool {
+	id := sharedExtentID{partitionID: ek.PartitionId, extentID: ek.ExtentId}
+	if !storage.IsTinyExtent(ek.ExtentId) {
+		return r.refs[id] > 0
+	}
+	for _, key := range r.tiny[id] {
+		if key.ExtentOffset < ek.ExtentOffset+uint64(ek.Size) && ek.ExtentOffset < key.ExtentOffset+uint64(key.Size) {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedExtentRefs indexes the extents of the flagged inodes once per batch, so
+// checking a key does not walk the extents of every flagged inode again.
+func (mp *metaPartition) sharedExtentRefs() *sharedExtentRefs {
+	r := &sharedExtentRefs{
+		refs: make(map[sharedExtentID]int),
+		tiny: make(map[sharedExtentID][]proto.ExtentKey),
+	}
+	se := &mp.sharedExts
+	se.Lock()
+	defer se.Unlock()
+	mp.loadSharedExtents()
+	for id := range se.inodes {
+		item := mp.inodeTree.Get(&Inode{Inode: id})
+		if item == nil {
+			delete(se.inodes, id)
+			continue
+		}
+		ino := item.(*Inode)
+		if ino.ShouldDelete() {
+			continue
+		}
+		ino.Extents.Range(func(key proto.ExtentKey) bool {
+			eid := sharedExtentID{partitionID: key.PartitionId, extentID: key.ExtentId}
+			if storage.IsTinyExtent(key.ExtentId) {
+				r.tiny[eid] = append(r.tiny[eid], key)
+			} else {
+				r.refs[eid]++
+			}
+			return true
+		})
+	}
+	return r
+}
+
+// skipSharedExtents returns the extents in eks that can be freed.
+func (mp *metaPartition) skipSharedExtents(eks []proto.ExtentKey) []proto.ExtentKey {
+	if !mp.hasSharedExtents() {
+		return eks
+	}
+	refs := mp.sharedExtentRefs()
+	res := make([]proto.ExtentKey, 0, len(eks))
+	for i := range eks {
+		if refs.isShared(&eks[i]) {
+			log.LogInfof("skipSharedExtents: mp(%v) extent %v is still shared", mp.config.PartitionId, eks[i])
+			continue
+		}
+		res = append(res, eks[i])
+	}
+	return res
+}
+
+// skipSharedExtentKeys is skipSharedExtents for the extents of a deleted inode.
+func (mp *metaPartition) skipSharedExtentKeys(eks []*proto.ExtentKey) []*proto.ExtentKey {
+	if !mp.hasSharedExtents() {
+		return eks
+	}
+	refs := mp.sharedExtentRefs()
+	res := make([]*proto.ExtentKey, 0, len(eks))
+	for _, ek := range eks {
+		if refs.isShared(ek) {
+			log.LogInfof("skipSharedExtentKeys: mp(%v) extent %v is still shared", mp.config.PartitionId, ek)
+			continue
+		}
+		res = append(res, ek)
+	}
+	return res
+}
+
+func (mp *metaPartition) hasSharedExtents() bool {
+	se := &mp.sharedExts
+	se.Lock()
+	defer se.Unlock()
+	mp.loadSharedExtents()
+	return len(se.inodes) > 0
+}
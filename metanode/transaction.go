@@ -1361,6 +1361,8 @@ func (tr *TransactionResource) addTxRollbackDentry(rbDentry *TxRollbackDentry) (
 func (tr *TransactionResource) rollbackInodeInternal(rbInode *TxRollbackInode) (status uint8, err error) {
 	status = proto.OpOk
 	mp := tr.txProcessor.mp
+	mp.changes.pause()
+	defer mp.changes.resume()
 	switch rbInode.rbType {
 	case TxAdd:
 		var ino *Inode
@@ -1377,7 +1379,7 @@ func (tr *TransactionResource) rollbackInodeInternal(rbInode *TxRollbackInode) (
 			if mp.mqMgr != nil && len(rbInode.quotaIds) > 0 && item == nil {
 				mp.setInodeQuota(rbInode.quotaIds, rbInode.inode.Inode)
 				for _, quotaId := range rbInode.quotaIds {
-					mp.mqMgr.updateUsedInfo(int64(rbInode.inode.Size), 1, quotaId)
+					mp.mqMgr.updateUsedInfo(mp.quotaSize(rbInode.inode), 1, quotaId)
 				}
 			}
 			mp.inodeTree.ReplaceOrInsert(rbInode.inode, true)
@@ -1393,13 +1395,24 @@ func (tr *TransactionResource) rollbackInodeInternal(rbInode *TxRollbackInode) (
 
 			if tr.txProcessor.mp.mqMgr != nil && len(rbInode.quotaIds) > 0 {
 				for _, quotaId := range rbInode.quotaIds {
-					tr.txProcessor.mp.mqMgr.updateUsedInfo(-1*int64(rbInode.inode.Size), -1, quotaId)
+					tr.txProcessor.mp.mqMgr.updateUsedInfo(-1*tr.txProcessor.mp.quotaSize(rbInode.inode), -1, quotaId)
 				}
 			}
 			tr.txProcessor.mp.fsmUnlinkInode(rbInode.inode, 0)
 			tr.txProcessor.mp.fsmEvictInode(rbInode.inode)
 		}
 
+	case TxUpdate:
+		// restore the attributes changed by the transaction
+		if item := mp.inodeTree.CopyGet(rbInode.inode); item != nil {
+			ino := item.(*Inode)
+			ino.DoWriteFunc(func() {
+				ino.Type = rbInode.inode.Type
+				ino.Uid = rbInode.inode.Uid
+				ino.Gid = rbInode.inode.Gid
+			})
+		}
+
 	default:
 		status = proto.OpTxRollbackUnknownRbType
 		err = fmt.Errorf("rollbackInode: unknown rbType %d", rbInode.rbType)
@@ -1448,6 +1461,8 @@ func (tr *TransactionResource) rollbackDentryInternal(rbDentry *TxRollbackDentry
 		}
 	}()
 	status = proto.OpOk
+	tr.txProcessor.mp.changes.pause()
+	defer tr.txProcessor.mp.changes.resume()
 	switch rbDentry.rbType {
 	case TxAdd:
 		// need to be true to assert link not change.
@@ -1456,7 +1471,7 @@ func (tr *TransactionResource) rollbackDentryInternal(rbDentry *TxRollbackDentry
 		resp := tr.txProcessor.mp.fsmDeleteDentry(rbDentry.dentry, true)
 		status = resp.Status
 	case TxUpdate:
-		resp := tr.txProcessor.mp.fsmUpdateDentry(rbDentry.dentry)
+		resp := tr.txProcessor.mp.updateDentryInode(rbDentry.dentry)
 		status = resp.Status
 	default:
 		status = proto.OpTxRollbackUnknownRbType
@@ -1554,14 +1569,14 @@ func (tr *TransactionResource) commitDentry(txID string, pId uint64, name string
 	}
 
 	tr.txRbDentryTree.Delete(rbDentry)
-	// unlink parent inode
-	if rbDentry.rbType == TxAdd {
-		parInode := NewInode(pId, 0)
-		st := tr.txProcessor.mp.fsmUnlinkInode(parInode, 0)
-		if st.Status != proto.OpOk {
-			log.LogWarnf("commitDentry: try unlink parent inode failed, txId %s, inode %v", txID, parInode)
-			return
-		}
+	// the dentry deleted or replaced by the transaction is gone for good now
+	mp := tr.txProcessor.mp
+	switch rbDentry.rbType {
+	case TxAdd:
+		mp.decParentLink(pId)
+		mp.recordChange(proto.ChangeEventDentryRemove, rbDentry.dentry.Inode, pId, name)
+	case TxUpdate:
+		mp.recordChange(proto.ChangeEventDentryRemove, rbDentry.dentry.Inode, pId, name)
 	}
 
 	log.LogDebugf("commitDentry: dentry[%v] is committed", rbDentry.txDentryInfo.GetKey())
@@ -17,6 +17,7 @@ package metanode
 import (
 	"fmt"
 	"github.com/cubefs/cubefs/util/log"
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -522,3 +523,195 @@ func TestGetTxHandler(t *testing.T) {
 	assert.True(t, mp1.TxGetInfo(req, p) == nil)
 	assert.True(t, p.ResultCode == proto.OpOk)
 }
+
+// mockRenameWithAttrTx registers a rename transaction of parent/src to parent/dst
+// which changes the attributes of the inode as well.
+func mockRenameWithAttrTx(t *testing.T, mp *metaPartition, src, dst string) *proto.TransactionInfo {
+	txInfo := proto.NewTransactionInfo(5, proto.TxTypeRename)
+	for _, name := range []string{src, dst} {
+		txDentryInfo := proto.NewTxDentryInfo(MemberAddrs, pInodeNum, name, mp.config.PartitionId)
+		txInfo.TxDentryInfos[txDentryInfo.GetKey()] = txDentryInfo
+	}
+	txInodeInfo := proto.NewTxInodeInfo(MemberAddrs, inodeNum, mp.config.PartitionId)
+	txInfo.TxInodeInfos[txInodeInfo.GetKey()] = txInodeInfo
+	assert.NoError(t, mp.initTxInfo(txInfo))
+	txInfo.TmID = int64(mp.config.PartitionId)
+	assert.NoError(t, mp.txProcessor.txManager.registerTransaction(txInfo))
+	return txInfo
+}
+
+func TestTxRenameWithAttr(t *testing.T) {
+	initMps(t)
+	mp := mp1
+	mp.inodeTree.ReplaceOrInsert(NewInode(pInodeNum, proto.Mode(os.ModeDir|0755)), true)
+	ino := NewInode(inodeNum, proto.Mode(0600))
+	mp.inodeTree.ReplaceOrInsert(ino, true)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: pInodeNum, Name: "tmp", Inode: inodeNum, Type: ino.Type}, true)
+
+	newMode := proto.Mode(0644)
+	apply := func(txInfo *proto.TransactionInfo, src, dst string) {
+		status := mp.fsmTxCreateDentry(NewTxDentry(pInodeNum, dst, inodeNum, ino.Type, nil, txInfo))
+		assert.Equal(t, proto.OpOk, status)
+		resp := mp.fsmTxDeleteDentry(NewTxDentry(pInodeNum, src, inodeNum, ino.Type, nil, txInfo))
+		assert.Equal(t, proto.OpOk, resp.Status)
+		status = mp.fsmTxSetAttr(&proto.TxSetAttrRequest{
+			Inode:  inodeNum,
+			Mode:   newMode,
+			Uid:    1000,
+			Gid:    100,
+			Valid:  proto.AttrMode | proto.AttrUid | proto.AttrGid | proto.AttrModifyTime,
+			TxInfo: txInfo,
+		})
+		assert.Equal(t, proto.OpOk, status)
+	}
+	lookup := func(name string) *Inode {
+		item := mp.dentryTree.Get(&Dentry{ParentId: pInodeNum, Name: name})
+		if item == nil {
+			return nil
+		}
+		return mp.getInode(NewInode(item.(*Dentry).Inode, 0), false).Msg
+	}
+
+	// a rolled back rename leaves the file at the old path with the old attributes
+	txInfo := mockRenameWithAttrTx(t, mp, "tmp", "final")
+	apply(txInfo, "tmp", "final")
+	txRsc := mp.txProcessor.txResource
+	status, err := txRsc.rollbackInode(&proto.TxInodeApplyRequest{TxID: txInfo.TxID, Inode: inodeNum})
+	assert.True(t, status == proto.OpOk && err == nil)
+	for _, name := range []string{"tmp", "final"} {
+		status, err = txRsc.rollbackDentry(&proto.TxDentryApplyRequest{TxID: txInfo.TxID, Pid: pInodeNum, Name: name})
+		assert.True(t, status == proto.OpOk && err == nil)
+	}
+	assert.Nil(t, lookup("final"))
+	old := lookup("tmp")
+	assert.Equal(t, proto.Mode(0600), old.Type)
+	assert.Equal(t, uint32(0), old.Uid)
+	assert.Equal(t, uint32(0), old.Gid)
+
+	// a committed rename shows the file at the new path with the new attributes only
+	txInfo = mockRenameWithAttrTx(t, mp, "tmp", "final")
+	apply(txInfo, "tmp", "final")
+	status, err = txRsc.commitInode(txInfo.TxID, inodeNum)
+	assert.True(t, status == proto.OpOk && err == nil)
+	for _, name := range []string{"tmp", "final"} {
+		status, err = txRsc.commitDentry(txInfo.TxID, pInodeNum, name)
+		assert.True(t, status == proto.OpOk && err == nil)
+	}
+	assert.Nil(t, lookup("tmp"))
+	renamed := lookup("final")
+	assert.Equal(t, newMode, renamed.Type)
+	assert.Equal(t, uint32(1000), renamed.Uid)
+	assert.Equal(t, uint32(100), renamed.Gid)
+	// only the mode and owner are changed by the transaction
+	assert.Equal(t, old.ModifyTime, renamed.ModifyTime)
+
+	// the inode can't be changed by another transaction before the rename is done
+	txInfo = mockRenameWithAttrTx(t, mp, "final", "other")
+	status = mp.fsmTxSetAttr(&proto.TxSetAttrRequest{Inode: inodeNum, Mode: newMode, Valid: proto.AttrMode, TxInfo: txInfo})
+	assert.Equal(t, proto.OpOk, status)
+	conflict := mockRenameWithAttrTx(t, mp, "final", "another")
+	status = mp.fsmTxSetAttr(&proto.TxSetAttrRequest{Inode: inodeNum, Mode: proto.Mode(0600), Valid: proto.AttrMode, TxInfo: conflict})
+	assert.Equal(t, proto.OpTxConflictErr, status)
+	assert.Equal(t, newMode, lookup("final").Type)
+}
+
+func TestTxProtectedInode(t *testing.T) {
+	for _, flags := range []uint32{proto.InodeFlagImmutable, proto.InodeFlagAppendOnly} {
+		initMps(t)
+		mp := mp1
+		mp.inodeTree.ReplaceOrInsert(NewInode(pInodeNum, proto.Mode(os.ModeDir|0755)), true)
+		newFlaggedFile(t, mp, inodeNum, flags)
+		newFlaggedFile(t, mp, inodeNum2, 0)
+		mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: pInodeNum, Name: "tmp", Inode: inodeNum, Type: FileModeType}, true)
+		mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: pInodeNum, Name: "final", Inode: inodeNum2, Type: FileModeType}, true)
+
+		// neither the dentry nor the inode can be removed by a transaction
+		txInfo := proto.NewTransactionInfo(5, proto.TxTypeRemove)
+		txDentryInfo := proto.NewTxDentryInfo(MemberAddrs, pInodeNum, "tmp", mp.config.PartitionId)
+		txInfo.TxDentryInfos[txDentryInfo.GetKey()] = txDentryInfo
+		txInodeInfo := proto.NewTxInodeInfo(MemberAddrs, inodeNum, mp.config.PartitionId)
+		txInfo.TxInodeInfos[txInodeInfo.GetKey()] = txInodeInfo
+		assert.NoError(t, mp.initTxInfo(txInfo))
+		txInfo.TmID = int64(mp.config.PartitionId)
+		assert.NoError(t, mp.txProcessor.txManager.registerTransaction(txInfo))
+
+		resp := mp.fsmTxDeleteDentry(NewTxDentry(pInodeNum, "tmp", inodeNum, FileModeType, nil, txInfo))
+		assert.Equal(t, proto.OpNotPerm, resp.Status)
+		assert.NotNil(t, mp.dentryTree.Get(&Dentry{ParentId: pInodeNum, Name: "tmp"}))
+		inoResp := mp.fsmTxUnlinkInode(NewTxInode(inodeNum, FileModeType, txInfo))
+		assert.Equal(t, proto.OpNotPerm, inoResp.Status)
+		assert.Equal(t, uint32(1), mp.inodeTree.Get(NewInode(inodeNum, 0)).(*Inode).GetNLink())
+
+		// nor renamed over another file, nor replaced by one
+		txInfo = mockRenameWithAttrTx(t, mp, "tmp", "final")
+		moveTmp := NewTxUpdateDentry(&Dentry{ParentId: pInodeNum, Name: "final", Inode: inodeNum2},
+			&Dentry{ParentId: pInodeNum, Name: "final", Inode: inodeNum}, txInfo)
+		resp = mp.fsmTxUpdateDentry(moveTmp)
+		assert.Equal(t, proto.OpNotPerm, resp.Status)
+		replaceTmp := NewTxUpdateDentry(&Dentry{ParentId: pInodeNum, Name: "tmp", Inode: inodeNum},
+			&Dentry{ParentId: pInodeNum, Name: "tmp", Inode: inodeNum2}, txInfo)
+		resp = mp.fsmTxUpdateDentry(replaceTmp)
+		assert.Equal(t, proto.OpNotPerm, resp.Status)
+		assert.Equal(t, uint64(inodeNum), mp.dentryTree.Get(&Dentry{ParentId: pInodeNum, Name: "tmp"}).(*Dentry).Inode)
+		assert.Equal(t, uint64(inodeNum2), mp.dentryTree.Get(&Dentry{ParentId: pInodeNum, Name: "final"}).(*Dentry).Inode)
+
+		// the refused operations don't hold the dentries in the transactions
+		assert.Equal(t, proto.OpOk, mp.fsmSetInodeFlags(&proto.SetInodeFlagsRequest{Inode: inodeNum}))
+		resp = mp.fsmTxUpdateDentry(moveTmp)
+		assert.Equal(t, proto.OpOk, resp.Status)
+		resp = mp.fsmTxDeleteDentry(NewTxDentry(pInodeNum, "tmp", inodeNum, FileModeType, nil, txInfo))
+		assert.Equal(t, proto.OpOk, resp.Status)
+	}
+}
+
+func TestTxRenameBatchRollback(t *testing.T) {
+	initMps(t)
+	mp := mp1
+	mp.inodeTree.ReplaceOrInsert(NewInode(pInodeNum, proto.Mode(os.ModeDir|0755)), true)
+	for _, ino := range []uint64{inodeNum, inodeNum2} {
+		mp.inodeTree.ReplaceOrInsert(NewInode(ino, proto.Mode(0600)), true)
+	}
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: pInodeNum, Name: "a", Inode: inodeNum, Type: proto.Mode(0600)}, true)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: pInodeNum, Name: "b", Inode: inodeNum2, Type: proto.Mode(0600)}, true)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: pInodeNum, Name: "c", Inode: inodeNum2 + 1, Type: proto.Mode(0600)}, true)
+
+	// a -> a2 and b -> c in one transaction, the second one is invalid as c exists
+	txInfo := proto.NewTransactionInfo(5, proto.TxTypeRename)
+	for _, name := range []string{"a", "a2", "b", "c"} {
+		txDentryInfo := proto.NewTxDentryInfo(MemberAddrs, pInodeNum, name, mp.config.PartitionId)
+		txInfo.TxDentryInfos[txDentryInfo.GetKey()] = txDentryInfo
+	}
+	assert.NoError(t, mp.initTxInfo(txInfo))
+	txInfo.TmID = int64(mp.config.PartitionId)
+	assert.NoError(t, mp.txProcessor.txManager.registerTransaction(txInfo))
+
+	status := mp.fsmTxCreateDentry(NewTxDentry(pInodeNum, "a2", inodeNum, proto.Mode(0600), nil, txInfo))
+	assert.Equal(t, proto.OpOk, status)
+	resp := mp.fsmTxDeleteDentry(NewTxDentry(pInodeNum, "a", inodeNum, proto.Mode(0600), nil, txInfo))
+	assert.Equal(t, proto.OpOk, resp.Status)
+	status = mp.fsmTxCreateDentry(NewTxDentry(pInodeNum, "c", inodeNum2, proto.Mode(0600), nil, txInfo))
+	assert.Equal(t, proto.OpExistErr, status)
+
+	// the failed pair rolls back the whole batch, the dentries which were not
+	// prepared have nothing to roll back
+	txRsc := mp.txProcessor.txResource
+	for _, name := range []string{"a", "a2"} {
+		status, err := txRsc.rollbackDentry(&proto.TxDentryApplyRequest{TxID: txInfo.TxID, Pid: pInodeNum, Name: name})
+		assert.True(t, status == proto.OpOk && err == nil)
+	}
+	for _, name := range []string{"b", "c"} {
+		status, _ := txRsc.rollbackDentry(&proto.TxDentryApplyRequest{TxID: txInfo.TxID, Pid: pInodeNum, Name: name})
+		assert.Equal(t, proto.OpTxRbDentryNotExistErr, status)
+	}
+	lookup := func(name string) uint64 {
+		item := mp.dentryTree.Get(&Dentry{ParentId: pInodeNum, Name: name})
+		if item == nil {
+			return 0
+		}
+		return item.(*Dentry).Inode
+	}
+	assert.Equal(t, uint64(inodeNum), lookup("a"))
+	assert.Equal(t, uint64(0), lookup("a2"))
+	assert.Equal(t, uint64(inodeNum2), lookup("b"))
+	assert.Equal(t, uint64(inodeNum2+1), lookup("c"))
+}
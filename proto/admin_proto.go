@@ -38,12 +38,15 @@ const (
 	AdminDiagnoseDataPartition                = "/dataPartition/diagnose"
 	AdminResetDataPartitionDecommissionStatus = "/dataPartition/resetDecommissionStatus"
 	AdminQueryDataPartitionDecommissionStatus = "/dataPartition/queryDecommissionStatus"
+	AdminListDataPartitions                   = "/dataPartition/list"
 	AdminDeleteDataReplica                    = "/dataReplica/delete"
 	AdminAddDataReplica                       = "/dataReplica/add"
 	AdminDeleteVol                            = "/vol/delete"
 	AdminUpdateVol                            = "/vol/update"
 	AdminVolShrink                            = "/vol/shrink"
 	AdminVolExpand                            = "/vol/expand"
+	AdminCloneVolConfig                       = "/vol/cloneConfig"
+	AdminRenameVol                            = "/vol/rename"
 	AdminCreateVol                            = "/admin/createVol"
 	AdminGetVol                               = "/admin/getVol"
 	AdminClusterFreeze                        = "/cluster/freeze"
@@ -114,6 +117,7 @@ const (
 
 	// qos api
 	QosGetStatus           = "/qos/getStatus"
+	QosGetLimit            = "/qos/getLimit"
 	QosGetClientsLimitInfo = "/qos/getClientsInfo"
 	QosGetZoneLimitInfo    = "/qos/getZoneLimit" // include disk enable
 	QosUpdate              = "/qos/update"       // include disk enable
@@ -144,6 +148,7 @@ const (
 	DecommissionDisk                   = "/disk/decommission"
 	RecommissionDisk                   = "/disk/recommission"
 	QueryDiskDecoProgress              = "/disk/queryDecommissionProgress"
+	QueryDecoProgressDetail            = "/decommission/queryProgressDetail"
 	MarkDecoDiskFixed                  = "/disk/MarkDecommissionDiskFixed"
 	CancelDecommissionDisk             = "/disk/cancelDecommission"
 	QueryDecommissionDiskDecoFailedDps = "/disk/queryDecommissionFailedDps"
@@ -163,6 +168,8 @@ const (
 	AdminDecommissionMetaPartition     = "/metaPartition/decommission"
 	AdminChangeMetaPartitionLeader     = "/metaPartition/changeleader"
 	AdminBalanceMetaPartitionLeader    = "/metaPartition/balanceLeader"
+	AdminBalanceMetaPartitionInode     = "/metaPartition/balanceInode"
+	AdminListMetaPartitions            = "/metaPartition/list"
 	AdminAddMetaReplica                = "/metaReplica/add"
 	AdminDeleteMetaReplica             = "/metaReplica/delete"
 	AdminPutDataPartitions             = "/dataPartitions/set"
@@ -190,6 +197,7 @@ const (
 
 	GetTopologyView = "/topo/get"
 	UpdateZone      = "/zone/update"
+	DrainZone       = "/zone/drain"
 	GetAllZones     = "/zone/list"
 	GetAllNodeSets  = "/nodeSet/list"
 	GetNodeSet      = "/nodeSet/get"
@@ -237,6 +245,7 @@ var GApiInfo map[string]string = map[string]string{
 	"admingetcluster":                  AdminGetCluster,
 	"adminsetclusterinfo":              AdminSetClusterInfo,
 	"admingetdatapartition":            AdminGetDataPartition,
+	"adminlistdatapartitions":          AdminListDataPartitions,
 	"adminloaddatapartition":           AdminLoadDataPartition,
 	"admincreatedatapartition":         AdminCreateDataPartition,
 	"admincreatepreloaddatapartition":  AdminCreatePreLoadDataPartition,
@@ -248,6 +257,8 @@ var GApiInfo map[string]string = map[string]string{
 	"adminupdatevol":                   AdminUpdateVol,
 	"adminvolshrink":                   AdminVolShrink,
 	"adminvolexpand":                   AdminVolExpand,
+	"adminclonevolconfig":              AdminCloneVolConfig,
+	"adminrenamevol":                   AdminRenameVol,
 	"admincreatevol":                   AdminCreateVol,
 	"admingetvol":                      AdminGetVol,
 	"adminclusterfreeze":               AdminClusterFreeze,
@@ -289,6 +300,7 @@ var GApiInfo map[string]string = map[string]string{
 	"qosgetstatus":           QosGetStatus,
 	"qosgetclientslimitinfo": QosGetClientsLimitInfo,
 	"qosgetzonelimitinfo":    QosGetZoneLimitInfo,
+	"qosgetlimit":            QosGetLimit,
 	"qosupdate":              QosUpdate,
 	//"qosupdatemagnify":               QosUpdateMagnify,
 	"qosupdateclientparam":            QosUpdateClientParam,
@@ -316,12 +328,15 @@ var GApiInfo map[string]string = map[string]string{
 	"admindecommissionmetapartition":  AdminDecommissionMetaPartition,
 	"adminchangemetapartitionleader":  AdminChangeMetaPartitionLeader,
 	"adminbalancemetapartitionleader": AdminBalanceMetaPartitionLeader,
+	"adminbalancemetapartitioninode":  AdminBalanceMetaPartitionInode,
+	"adminlistmetapartitions":         AdminListMetaPartitions,
 	"adminaddmetareplica":             AdminAddMetaReplica,
 	"admindeletemetareplica":          AdminDeleteMetaReplica,
 	"getmetanodetaskresponse":         GetMetaNodeTaskResponse,
 	"getdatanodetaskresponse":         GetDataNodeTaskResponse,
 	"gettopologyview":                 GetTopologyView,
 	"updatezone":                      UpdateZone,
+	"drainzone":                       DrainZone,
 	"getallzones":                     GetAllZones,
 	"usercreate":                      UserCreate,
 	"userdelete":                      UserDelete,
@@ -414,6 +429,7 @@ type CreateDataPartitionRequest struct {
 	PartitionSize       int
 	ReplicaNum          int
 	VolumeId            string
+	VolId               uint64 // unlike the name, the ID of the vol never changes
 	IsRandomWrite       bool
 	Members             []Peer
 	Hosts               []string
@@ -579,6 +595,11 @@ type HeartBeatRequest struct {
 	CurrTime   int64
 	MasterAddr string
 	FLReadVols []string
+	// volume ID -> replicas which must ack a write, only vols with a quorum set
+	VolWriteQuorums map[uint64]int
+	// data partition ID -> name of its vol, only the partitions last reported under
+	// the name their vol had before it was renamed
+	DataPartitionVolNames map[uint64]string
 	QosToDataNode
 	FileStatsEnable bool
 	UidLimitToMetaNode
@@ -755,6 +776,7 @@ type DataPartitionResponse struct {
 	IsRecover     bool
 	PartitionTTL  int64
 	IsDiscard     bool
+	ZoneName      string `json:",omitempty"` // zone of Hosts[0], the replica written by the clients
 }
 
 // DataPartitionsView defines the view of a data partition
@@ -768,6 +790,20 @@ func NewDataPartitionsView() (dataPartitionsView *DataPartitionsView) {
 	return
 }
 
+// DataPartitionsPage is a page of the data partitions of a vol, Total is the
+// number of the partitions of all the pages.
+type DataPartitionsPage struct {
+	Total          int
+	DataPartitions []*DataPartitionResponse
+}
+
+// MetaPartitionsPage is a page of the meta partitions of a vol, Total is the
+// number of the partitions of all the pages.
+type MetaPartitionsPage struct {
+	Total          int
+	MetaPartitions []*MetaPartitionView
+}
+
 // MetaPartitionView defines the view of a meta partition
 type MetaPartitionView struct {
 	PartitionID uint64
@@ -957,6 +993,12 @@ type SimpleVolView struct {
 	TxConflictRetryNum      int64
 	TxConflictRetryInterval int64
 	TxOpLimit               int
+	MinWriteQuorum          int
+	DisableTinyExtent       bool
+	CrcAlg                  uint8
+	QuotaByAllocated        bool
+	MaxFileSize             uint64
+	InodeDelayDeleteTime    int64
 	Description             string
 	DpSelectorName          string
 	DpSelectorParm          string
@@ -1045,6 +1087,7 @@ func NewVolInfo(name, owner string, createTime int64, status uint8, totalSize, u
 type ZoneView struct {
 	Name                string
 	Status              string
+	Drained             bool
 	DataNodesetSelector string
 	MetaNodesetSelector string
 	NodeSet             map[uint64]*NodeSetView
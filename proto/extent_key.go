@@ -45,6 +45,7 @@ type ExtSnapInfo struct {
 	VerSeq  uint64
 	IsSplit bool
 	ModGen  uint64
+	Shared  bool // the extent is shared with a cloned inode, only set in replies to clients
 }
 
 // ExtentKey defines the extent key struct.
@@ -114,6 +115,27 @@ func (k *ExtentKey) SetSplit(split bool) {
 	k.SnapInfo.IsSplit = split
 }
 
+// IsShared returns if the extent is referenced by more than one inode, such an
+// extent must never be overwritten in place.
+func (k *ExtentKey) IsShared() bool {
+	if k.SnapInfo == nil {
+		return false
+	}
+	return k.SnapInfo.Shared
+}
+
+// SetShared marks the extent as shared, SnapInfo is copied first since it may be
+// referenced by the extent key kept in the inode.
+func (k *ExtentKey) SetShared() {
+	if k.SnapInfo == nil {
+		k.SnapInfo = &ExtSnapInfo{Shared: true}
+		return
+	}
+	snapInfo := *k.SnapInfo
+	snapInfo.Shared = true
+	k.SnapInfo = &snapInfo
+}
+
 func (k *ExtentKey) GenerateId() uint64 {
 	if k.PartitionId > math.MaxUint32 || k.ExtentId > math.MaxUint32 {
 		log.LogFatalf("ext %v abnormal", k)
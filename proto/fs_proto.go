@@ -93,6 +93,7 @@ type InodeInfo struct {
 	Target     []byte                    `json:"tgt"`
 	QuotaInfos map[uint32]*MetaQuotaInfo `json:"qifs"`
 	VerSeq     uint64                    `json:"seq"`
+	Flags      uint32                    `json:"flags"`
 	expiration int64
 }
 
@@ -309,6 +310,25 @@ func (tx *TxLinkInodeRequest) GetInfo() string {
 	return tx.TxInfo.String()
 }
 
+// TxSetAttrRequest changes the mode and owner of an inode within a transaction,
+// the old attributes are restored if the transaction is rolled back.
+type TxSetAttrRequest struct {
+	VolName     string           `json:"vol"`
+	PartitionID uint64           `json:"pid"`
+	Inode       uint64           `json:"ino"`
+	Mode        uint32           `json:"mode"`
+	Uid         uint32           `json:"uid"`
+	Gid         uint32           `json:"gid"`
+	Valid       uint32           `json:"valid"`
+	ChangeTime  int64            `json:"ct"`
+	VerSeq      uint64           `json:"seq"`
+	TxInfo      *TransactionInfo `json:"tx"`
+}
+
+func (tx *TxSetAttrRequest) GetInfo() string {
+	return tx.TxInfo.String()
+}
+
 type TxLinkInodeResponse struct {
 	Info *InodeInfo `json:"info"`
 }
@@ -615,12 +635,58 @@ type ReadDirLimitRequest struct {
 	Limit       uint64 `json:"limit"`
 	VerSeq      uint64 `json:"seq"`
 	VerOpt      uint8  `json:"VerOpt"`
+	Filter      uint8  `json:"filter,omitempty"`
+}
+
+// Type filters of ReadDirLimitRequest, the entries not matching the filter are
+// skipped by the metanode and not counted in the limit.
+const (
+	ReadDirFilterAll uint8 = iota
+	ReadDirFilterDirs
+	ReadDirFilterFiles
+)
+
+// ReadDirFilterMatch returns if an entry of the given mode passes the filter,
+// files-only matches every entry that is not a directory.
+func ReadDirFilterMatch(filter uint8, mode uint32) bool {
+	switch filter {
+	case ReadDirFilterDirs:
+		return IsDir(mode)
+	case ReadDirFilterFiles:
+		return !IsDir(mode)
+	default:
+		return true
+	}
 }
 
 type ReadDirLimitResponse struct {
 	Children []Dentry `json:"children"`
 }
 
+// ReadDirPlusRequest defines the request to read dir with the inode attributes of
+// the children. It pages and filters the dentries like ReadDirLimitRequest.
+type ReadDirPlusRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	ParentID    uint64 `json:"pino"`
+	Marker      string `json:"marker"`
+	Limit       uint64 `json:"limit"`
+	VerSeq      uint64 `json:"seq"`
+	VerOpt      uint8  `json:"VerOpt"`
+	Filter      uint8  `json:"filter,omitempty"`
+}
+
+// DentryPlus is a dentry with the attributes of its inode. Info is nil if the inode
+// is not in the meta partition of the dentry.
+type DentryPlus struct {
+	Dentry Dentry     `json:"dentry"`
+	Info   *InodeInfo `json:"info,omitempty"`
+}
+
+type ReadDirPlusResponse struct {
+	Children []DentryPlus `json:"children"`
+}
+
 // AppendExtentKeyRequest defines the request to append an extent key.
 type AppendExtentKeyRequest struct {
 	VolName     string    `json:"vol"`
@@ -711,6 +777,7 @@ type SetAttrRequest struct {
 	Gid         uint32 `json:"gid"`
 	ModifyTime  int64  `json:"mt"`
 	AccessTime  int64  `json:"at"`
+	ChangeTime  int64  `json:"ct"`
 	Valid       uint32 `json:"valid"`
 	VerSeq      uint64 `json:"seq"`
 }
@@ -721,8 +788,74 @@ const (
 	AttrGid
 	AttrModifyTime
 	AttrAccessTime
+	AttrChangeTime
 )
 
+// Inode attribute flags, like the immutable and append-only flags of chattr.
+const (
+	InodeFlagImmutable uint32 = 1 << iota
+	InodeFlagAppendOnly
+)
+
+// SetInodeFlagsRequest replaces the attribute flags of an inode.
+type SetInodeFlagsRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	Flags       uint32 `json:"flags"`
+}
+
+// CloneInodeRequest creates a regular file sharing the extents of Inode, the
+// data is not copied. NewInode is allocated by the leader of the partition.
+type CloneInodeRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	NewInode    uint64 `json:"newIno"`
+	Uid         uint32 `json:"uid"`
+	Gid         uint32 `json:"gid"`
+}
+
+type CloneInodeResponse struct {
+	Info *InodeInfo `json:"info"`
+}
+
+// Types of the change events kept by the metanode for watching clients.
+const (
+	ChangeEventModify       uint8 = iota + 1 // the data of the inode changed
+	ChangeEventDelete                        // the last link of the inode is removed
+	ChangeEventDentryRemove                  // the dentry Parent/Name pointing to Inode is removed or replaced
+)
+
+// ChangeEvent is a change applied by a meta partition, Seq is the raft index of
+// the change so it is the same on every replica.
+type ChangeEvent struct {
+	Seq    uint64 `json:"seq"`
+	Type   uint8  `json:"type"`
+	Inode  uint64 `json:"ino"`
+	Parent uint64 `json:"pino,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// GetChangeEventsRequest asks for the change events after FromSeq concerning the
+// given inodes, or the dentries in the given parents. A zero FromSeq only returns
+// the current sequence to start watching from.
+type GetChangeEventsRequest struct {
+	VolName     string   `json:"vol"`
+	PartitionID uint64   `json:"pid"`
+	FromSeq     uint64   `json:"from"`
+	Inodes      []uint64 `json:"inos"`
+	Parents     []uint64 `json:"pinos"`
+}
+
+// GetChangeEventsResponse returns the events and the sequence to ask from next
+// time. Lost is set if events after FromSeq are no longer kept by the partition.
+type GetChangeEventsResponse struct {
+	Events  []ChangeEvent `json:"events"`
+	NextSeq uint64        `json:"next"`
+	Lost    bool          `json:"lost"`
+}
+
 // DeleteInodeRequest defines the request to delete an inode.
 type DeleteInodeRequest struct {
 	VolName     string `json:"vol"`
@@ -832,6 +965,49 @@ type UpdateXAttrRequest struct {
 	Value       string `json:"val"`
 }
 
+// SetDentryAttrRequest sets an attribute of the dentry itself, unlike xattrs
+// the attributes are not shared by the hardlinks of the inode.
+type SetDentryAttrRequest struct {
+	VolName     string `json:"vol"`
+	PartitionId uint64 `json:"pid"`
+	ParentID    uint64 `json:"pino"`
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	Value       string `json:"val"`
+}
+
+type GetDentryAttrRequest struct {
+	VolName     string `json:"vol"`
+	PartitionId uint64 `json:"pid"`
+	ParentID    uint64 `json:"pino"`
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+}
+
+type GetDentryAttrResponse struct {
+	VolName     string `json:"vol"`
+	PartitionId uint64 `json:"pid"`
+	ParentID    uint64 `json:"pino"`
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	Value       string `json:"val"`
+}
+
+type ListDentryAttrRequest struct {
+	VolName     string `json:"vol"`
+	PartitionId uint64 `json:"pid"`
+	ParentID    uint64 `json:"pino"`
+	Name        string `json:"name"`
+}
+
+type ListDentryAttrResponse struct {
+	VolName     string   `json:"vol"`
+	PartitionId uint64   `json:"pid"`
+	ParentID    uint64   `json:"pino"`
+	Name        string   `json:"name"`
+	Attrs       []string `json:"attrs"`
+}
+
 type MultipartInfo struct {
 	ID       string               `json:"id"`
 	Path     string               `json:"path"`
@@ -955,6 +1131,27 @@ type BatchSetMetaserverQuotaResponse struct {
 	InodeRes map[uint64]uint8 `json:"inores"`
 }
 
+// SetSubtreeQuotaRequest asks a meta partition to associate a quota with every inode below Dirs.
+// The first directory is resumed from Marker, which is exclusive.
+type SetSubtreeQuotaRequest struct {
+	PartitionId uint64   `json:"pid"`
+	QuotaId     uint32   `json:"qid"`
+	Dirs        []uint64 `json:"dirs"`
+	Marker      string   `json:"marker"`
+	Limit       uint32   `json:"limit"`
+}
+
+// SetSubtreeQuotaResponse carries the cursor to resume the walk from; the walk of the partition
+// is finished once Dirs is empty. Remote entries live in other partitions: RemoteInodes still need
+// the quota set on them and RemoteDirs still need to be walked there.
+type SetSubtreeQuotaResponse struct {
+	Dirs         []uint64         `json:"dirs"`
+	Marker       string           `json:"marker"`
+	RemoteDirs   []uint64         `json:"rdirs"`
+	RemoteInodes []uint64         `json:"rino"`
+	InodeRes     map[uint64]uint8 `json:"inores"`
+}
+
 type BatchDeleteMetaserverQuotaReuqest struct {
 	PartitionId uint64   `json:"pid"`
 	Inodes      []uint64 `json:"ino"`
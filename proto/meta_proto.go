@@ -102,6 +102,9 @@ type QuotaHeartBeatInfo struct {
 	QuotaId     uint32
 	LimitedInfo QuotaLimitedInfo
 	Enable      bool
+	MaxFiles    uint64
+	MaxBytes    uint64
+	UsedInfo    QuotaUsedInfo // usage of the whole volume summed by the master
 }
 
 type MetaQuotaInfos struct {
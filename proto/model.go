@@ -327,9 +327,35 @@ type MetaPartitionDiagnosis struct {
 }
 
 type DecommissionProgress struct {
-	Status    uint32
-	Progress  string
-	FailedDps []uint64
+	Status         uint32
+	Progress       string
+	FailedDps      []uint64
+	TotalDpCnt     int
+	MovedDpCnt     int
+	RunningDpCnt   int
+	FailedDpCnt    int
+	RemainingBytes uint64
+}
+
+// DecommissionPartitionProgress is the decommission status of a data partition
+// moving off a node or a disk.
+type DecommissionPartitionProgress struct {
+	PartitionID uint64
+	Status      uint32
+	DiskPath    string
+}
+
+// DecommissionProgressDetail breaks the decommission progress of a node or a
+// disk down to its data partitions, along with the limits in effect.
+type DecommissionProgressDetail struct {
+	TotalDpCnt             int
+	PendingDpCnt           int
+	MigratingDpCnt         int
+	CompletedDpCnt         int
+	FailedDpCnt            int
+	DecommissionLimit      uint64
+	DecommissionDiskFactor float64
+	Partitions             []*DecommissionPartitionProgress
 }
 
 type BadDiskInfo struct {
@@ -71,6 +71,10 @@ const (
 	LocallyProf
 	MinWriteAbleDataPartitionCnt
 	FileSystemName
+	WriteReplyTimeout
+	MaxRecoverBackoff
+	ExtentHandlerBufferSize
+	FlushConcurrency
 
 	//snapshot
 	SnapshotReadVerSeq
@@ -165,6 +169,10 @@ func InitMountOptions(opts []MountOption) {
 		"", int64(10)}
 
 	opts[FileSystemName] = MountOption{"fileSystemName", "The explicit name of the filesystem", "", ""}
+	opts[WriteReplyTimeout] = MountOption{"writeReplyTimeout", "The timeout in milliseconds of waiting for the reply of a data write", "", int64(0)}
+	opts[MaxRecoverBackoff] = MountOption{"maxRecoverBackoff", "The max wait in milliseconds before retrying a failed data write", "", int64(0)}
+	opts[ExtentHandlerBufferSize] = MountOption{"extentHandlerBufferSize", "The count of packets buffered by each file being written, each one can hold a full data block", "", int64(0)}
+	opts[FlushConcurrency] = MountOption{"flushConcurrency", "The count of extents of a file flushed at once", "", int64(0)}
 	opts[SnapshotReadVerSeq] = MountOption{"snapshotReadSeq", "Snapshot read seq", "", int64(0)} //default false
 
 	for i := 0; i < MaxMountOption; i++ {
@@ -325,4 +333,8 @@ type MountOptions struct {
 	MinWriteAbleDataPartitionCnt int
 	FileSystemName               string
 	VerReadSeq                   uint64
+	WriteReplyTimeout            int64
+	MaxRecoverBackoff            int64
+	ExtentHandlerBufferSize      int
+	FlushConcurrency             int
 }
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"net"
 	"strconv"
@@ -100,6 +101,8 @@ const (
 	OpMetaBatchGetXAttr      uint8 = 0x39
 	OpMetaExtentAddWithCheck uint8 = 0x3A // Append extent key with discard extents check
 	OpMetaReadDirLimit       uint8 = 0x3D
+	OpMetaSetDentryAttr      uint8 = 0x3E
+	OpMetaGetDentryAttr      uint8 = 0x3F
 
 	// Operations: Master -> MetaNode
 	OpCreateMetaPartition           uint8 = 0x40
@@ -118,6 +121,7 @@ const (
 	OpMetaGetInodeQuota         uint8 = 0x52
 	OpQuotaCreateInode          uint8 = 0x53
 	OpQuotaCreateDentry         uint8 = 0x54
+	OpMetaSetSubtreeQuota       uint8 = 0x58
 
 	// Operations: Master -> LcNode
 
@@ -168,6 +172,7 @@ const (
 	OpMetaTxUpdateDentry uint8 = 0xA9
 	OpMetaTxLinkInode    uint8 = 0xAA
 	OpMetaTxGet          uint8 = 0xAB
+	OpMetaTxSetAttr      uint8 = 0xAD
 
 	//Operations: Client -> MetaNode.
 	OpMetaGetUniqID uint8 = 0xAC
@@ -182,8 +187,9 @@ const (
 	OpSyncTryWriteAppend    uint8 = 0xB7
 
 	// Commons
-	OpNoSpaceErr uint8 = 0xEE
-	OpDirQuota   uint8 = 0xF1
+	OpNoSpaceErr      uint8 = 0xEE
+	OpDirQuota        uint8 = 0xF1
+	OpFileTooLargeErr uint8 = 0xDC
 
 	// Commons
 
@@ -218,6 +224,13 @@ const (
 	OpMetaBatchSetXAttr uint8 = 0xD2
 	OpMetaGetAllXAttr   uint8 = 0xD3
 
+	OpMetaListDentryAttr uint8 = 0xD4
+
+	OpMetaSetInodeFlags   uint8 = 0xD7
+	OpMetaGetChangeEvents uint8 = 0xD8
+	OpMetaCloneInode      uint8 = 0xD9
+	OpMetaReadDirPlus     uint8 = 0xDA
+
 	//transaction error
 
 	OpTxInodeInfoNotExistErr  uint8 = 0xE0
@@ -250,8 +263,31 @@ const (
 	GetAllWatermarksDeadLineTime              = 60
 	DefaultClusterLoadFactor          float64 = 10
 	MultiVersionFlag                          = 0x80
+	// CrcCastagnoliFlag is set in the extent type of a data packet whose crc is a
+	// crc32c one, it is moved to Packet.CrcAlg when the header is unmarshaled.
+	CrcCastagnoliFlag = 0x40
+)
+
+// checksum algorithms of the data packets
+const (
+	CrcAlgIEEE       uint8 = 0
+	CrcAlgCastagnoli uint8 = 1
 )
 
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+func IsValidCrcAlg(alg uint8) bool {
+	return alg == CrcAlgIEEE || alg == CrcAlgCastagnoli
+}
+
+// Checksum returns the crc of data computed with the given algorithm.
+func Checksum(alg uint8, data []byte) uint32 {
+	if alg == CrcAlgCastagnoli {
+		return crc32.Checksum(data, castagnoliTable)
+	}
+	return crc32.ChecksumIEEE(data)
+}
+
 // multi version operation
 const (
 	CreateVersion        = 1
@@ -308,6 +344,7 @@ type Packet struct {
 	mesg               string
 	HasPrepare         bool
 	VerSeq             uint64 // only used in mod request to datanode
+	CrcAlg             uint8  // algorithm of CRC, sent as CrcCastagnoliFlag in the extent type
 }
 
 // NewPacket returns a new packet.
@@ -343,6 +380,11 @@ func (p *Packet) String() string {
 		p.ReqID, p.GetOpMsg(), p.PartitionID, p.GetResultMsg(), p.ExtentID, p.ExtentOffset, p.KernelOffset, p.ExtentType, p.VerSeq)
 }
 
+// ComputeCRC returns the crc of data computed with the algorithm of the packet.
+func (p *Packet) ComputeCRC(data []byte) uint32 {
+	return Checksum(p.CrcAlg, data)
+}
+
 // GetStoreType returns the store type.
 func (p *Packet) GetStoreType() (m string) {
 	switch p.ExtentType {
@@ -421,6 +463,20 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaReadDir"
 	case OpMetaReadDirLimit:
 		m = "OpMetaReadDirLimit"
+	case OpMetaSetDentryAttr:
+		m = "OpMetaSetDentryAttr"
+	case OpMetaGetDentryAttr:
+		m = "OpMetaGetDentryAttr"
+	case OpMetaListDentryAttr:
+		m = "OpMetaListDentryAttr"
+	case OpMetaSetInodeFlags:
+		m = "OpMetaSetInodeFlags"
+	case OpMetaGetChangeEvents:
+		m = "OpMetaGetChangeEvents"
+	case OpMetaCloneInode:
+		m = "OpMetaCloneInode"
+	case OpMetaReadDirPlus:
+		m = "OpMetaReadDirPlus"
 	case OpMetaInodeGet:
 		m = "OpMetaInodeGet"
 	case OpMetaBatchInodeGet:
@@ -567,6 +623,8 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaTxUpdateDentry"
 	case OpMetaTxLinkInode:
 		m = "OpMetaTxLinkInode"
+	case OpMetaTxSetAttr:
+		m = "OpMetaTxSetAttr"
 	case OpMetaTxGet:
 		m = "OpMetaTxGet"
 	case OpMetaBatchSetInodeQuota:
@@ -575,6 +633,8 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaBatchDeleteInodeQuota"
 	case OpMetaGetInodeQuota:
 		m = "OpMetaGetInodeQuota"
+	case OpMetaSetSubtreeQuota:
+		m = "OpMetaSetSubtreeQuota"
 	case OpStopDataPartitionRepair:
 		m = "OpStopDataPartitionRepair"
 	case OpLcNodeHeartbeat:
@@ -634,6 +694,8 @@ func (p *Packet) GetResultMsg() (m string) {
 		m = "OpDirQuota"
 	case OpNoSpaceErr:
 		m = "NoSpaceErr"
+	case OpFileTooLargeErr:
+		m = "FileTooLargeErr"
 	case OpTxInodeInfoNotExistErr:
 		m = "OpTxInodeInfoNotExistErr"
 	case OpTxConflictErr:
@@ -678,6 +740,9 @@ func (p *Packet) GetReqID() int64 {
 func (p *Packet) MarshalHeader(out []byte) {
 	out[0] = p.Magic
 	out[1] = p.ExtentType
+	if p.CrcAlg == CrcAlgCastagnoli {
+		out[1] |= CrcCastagnoliFlag
+	}
 	out[2] = p.Opcode
 	out[3] = p.ResultCode
 	out[4] = p.RemainingFollowers
@@ -702,7 +767,11 @@ func (p *Packet) UnmarshalHeader(in []byte) error {
 		return errors.New("Bad Magic " + strconv.Itoa(int(p.Magic)))
 	}
 
-	p.ExtentType = in[1]
+	p.ExtentType = in[1] &^ CrcCastagnoliFlag
+	p.CrcAlg = CrcAlgIEEE
+	if in[1]&CrcCastagnoliFlag != 0 {
+		p.CrcAlg = CrcAlgCastagnoli
+	}
 	p.Opcode = in[2]
 	p.ResultCode = in[3]
 	p.RemainingFollowers = in[4]
@@ -799,7 +868,16 @@ func (p *Packet) ReadFromConnWithVer(c net.Conn, timeoutSec int) (err error) {
 	} else {
 		c.SetReadDeadline(time.Time{})
 	}
+	return p.readFromConnWithVer(c)
+}
+
+// ReadFromConnWithVerTimeout is ReadFromConnWithVer with a timeout finer than a second.
+func (p *Packet) ReadFromConnWithVerTimeout(c net.Conn, timeout time.Duration) (err error) {
+	c.SetReadDeadline(time.Now().Add(timeout))
+	return p.readFromConnWithVer(c)
+}
 
+func (p *Packet) readFromConnWithVer(c net.Conn) (err error) {
 	header, err := Buffers.Get(util.PacketHeaderSize)
 	if err != nil {
 		header = make([]byte, util.PacketHeaderSize)
@@ -0,0 +1,55 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"hash/crc32"
+	"testing"
+
+	"github.com/cubefs/cubefs/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketCrcAlg(t *testing.T) {
+	data := []byte("cubefs packet checksum")
+	require.Equal(t, crc32.ChecksumIEEE(data), Checksum(CrcAlgIEEE, data))
+	require.Equal(t, crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)), Checksum(CrcAlgCastagnoli, data))
+	require.True(t, IsValidCrcAlg(CrcAlgCastagnoli))
+	require.False(t, IsValidCrcAlg(2))
+
+	for _, alg := range []uint8{CrcAlgIEEE, CrcAlgCastagnoli} {
+		for _, extentType := range []uint8{TinyExtentType, NormalExtentType} {
+			p := NewPacket()
+			p.ExtentType = extentType
+			p.CrcAlg = alg
+			p.Data = data
+			p.Size = uint32(len(data))
+			p.CRC = p.ComputeCRC(data)
+
+			header := make([]byte, util.PacketHeaderSize)
+			p.MarshalHeader(header)
+			q := new(Packet)
+			require.NoError(t, q.UnmarshalHeader(header))
+			// the algorithm does not leak into the extent type checked by the data node
+			require.Equal(t, extentType, q.ExtentType)
+			require.Equal(t, alg, q.CrcAlg)
+			require.Equal(t, p.CRC, q.ComputeCRC(data))
+
+			corrupted := append([]byte{}, data...)
+			corrupted[0] ^= 1
+			require.NotEqual(t, p.CRC, q.ComputeCRC(corrupted))
+		}
+	}
+}
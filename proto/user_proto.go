@@ -236,6 +236,21 @@ func (policy *UserPolicy) RemoveAuthorizedVol(volume string) {
 	delete(policy.AuthorizedVols, volume)
 }
 
+// RenameVol moves the ownership and the permissions of volume to newName.
+func (policy *UserPolicy) RenameVol(volume, newName string) {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	for i, ownVol := range policy.OwnVols {
+		if ownVol == volume {
+			policy.OwnVols[i] = newName
+		}
+	}
+	if actions, ok := policy.AuthorizedVols[volume]; ok {
+		delete(policy.AuthorizedVols, volume)
+		policy.AuthorizedVols[newName] = actions
+	}
+}
+
 func (policy *UserPolicy) SetPerm(volume string, perm Permission) {
 	policy.mu.Lock()
 	defer policy.mu.Unlock()
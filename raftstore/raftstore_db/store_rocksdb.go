@@ -229,6 +229,28 @@ func (rs *RocksDBStore) BatchPut(cmdMap map[string][]byte, isSync bool) error {
 	return nil
 }
 
+// BatchDeleteAndPut deletes the keys and puts the key-value pairs in one batch.
+func (rs *RocksDBStore) BatchDeleteAndPut(delKeys []string, cmdMap map[string][]byte, isSync bool) error {
+	wo := gorocksdb.NewDefaultWriteOptions()
+	wo.SetSync(isSync)
+	wb := gorocksdb.NewWriteBatch()
+	defer func() {
+		wo.Destroy()
+		wb.Destroy()
+	}()
+	for _, key := range delKeys {
+		wb.Delete([]byte(key))
+	}
+	for key, value := range cmdMap {
+		wb.Put([]byte(key), value)
+	}
+	if err := rs.db.Write(wo, wb); err != nil {
+		err = fmt.Errorf("action[batchDeleteAndPutToRocksDB],err:%v", err)
+		return err
+	}
+	return nil
+}
+
 // SeekForPrefix seeks for the place where the prefix is located in the snapshots.
 func (rs *RocksDBStore) SeekForPrefix(prefix []byte) (result map[string][]byte, err error) {
 	result = make(map[string][]byte)
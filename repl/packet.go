@@ -50,6 +50,9 @@ type Packet struct {
 	// used locally
 	shallDegrade bool
 	AfterPre     bool
+	writeQuorum  int // replicas, leader included, which must ack the packet, 0 means all
+	// followers which failed a packet that still reached its write quorum
+	laggingFollowers []string
 }
 
 type FollowerPacket struct {
@@ -130,6 +133,7 @@ func copyPacket(src *Packet, dst *FollowerPacket) {
 	dst.Opcode = src.Opcode
 	dst.ResultCode = src.ResultCode
 	dst.CRC = src.CRC
+	dst.CrcAlg = src.CrcAlg
 	dst.Size = src.Size
 	dst.KernelOffset = src.KernelOffset
 	dst.PartitionID = src.PartitionID
@@ -402,6 +406,26 @@ func (p *Packet) IsMasterCommand() bool {
 	return false
 }
 
+// SetWriteQuorum sets how many replicas, leader included, must ack the packet.
+func (p *Packet) SetWriteQuorum(quorum int) {
+	p.writeQuorum = quorum
+}
+
+// LaggingFollowers returns the followers which missed the packet although it succeeded
+// on the write quorum, their replica has to be repaired.
+func (p *Packet) LaggingFollowers() []string {
+	return p.laggingFollowers
+}
+
+// requiredAcks returns the acks needed for the packet to succeed, the leader included.
+func (p *Packet) requiredAcks() int {
+	replicas := len(p.followersAddrs) + 1
+	if p.writeQuorum <= 0 || p.writeQuorum > replicas {
+		return replicas
+	}
+	return p.writeQuorum
+}
+
 func (p *Packet) IsForwardPacket() bool {
 	r := p.RemainingFollowers > 0 && !p.isSpecialReplicaCntPacket()
 	return r
@@ -367,8 +367,8 @@ func (rp *ReplProtocol) writeResponseToClientGoRroutine() {
 // }
 
 // Read a packet from the list, scan all the connections of the followers of this packet and read the responses.
-// If failed to read the response, then mark the packet as failure, and delete it from the list.
-// If all the reads succeed, then mark the packet as success.
+// If the acks can't reach the write quorum of the packet, then mark the packet as failure, and delete it from the list.
+// Otherwise mark the packet as success.
 func (rp *ReplProtocol) checkLocalResultAndReciveAllFollowerResponse() {
 	var (
 		e *list.Element
@@ -384,14 +384,30 @@ func (rp *ReplProtocol) checkLocalResultAndReciveAllFollowerResponse() {
 	if request.IsErrPacket() {
 		return
 	}
+	receiveFollowerResponses(request)
+}
+
+// receiveFollowerResponses waits for the followers of the request, the leader counts as one ack.
+// It gives up as soon as the write quorum can't be reached any more. The followers which failed
+// a request that reached its write quorum are recorded as lagging.
+func receiveFollowerResponses(request *Packet) {
+	acks := 1
+	required := request.requiredAcks()
 	for index := 0; index < len(request.followersAddrs); index++ {
 		followerPacket := request.followerPackets[index]
 		err := <-followerPacket.respCh
-		if err != nil {
+		if err == nil {
+			acks++
+			continue
+		}
+		remaining := len(request.followersAddrs) - index - 1
+		if acks+remaining < required {
 			request.PackErrorBody(ActionReceiveFromFollower, err.Error())
 			return
 		}
-
+		log.LogWarnf("action[receiveFollowerResponses] follower(%v) failed, write quorum(%v) still reachable, err(%v)",
+			request.followersAddrs[index], required, err)
+		request.laggingFollowers = append(request.laggingFollowers, request.followersAddrs[index])
 	}
 }
 
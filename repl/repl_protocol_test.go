@@ -0,0 +1,87 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package repl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+// newReplicatedPacket builds a leader packet whose followers already replied with results.
+func newReplicatedPacket(quorum int, results ...error) *Packet {
+	p := NewPacket()
+	p.Opcode = proto.OpWrite
+	p.ResultCode = proto.OpOk
+	p.SetWriteQuorum(quorum)
+	for i, res := range results {
+		p.followersAddrs = append(p.followersAddrs, "follower"+string(rune('a'+i)))
+		fp := NewFollowerPacket()
+		fp.respCh <- res
+		p.followerPackets = append(p.followerPackets, fp)
+	}
+	return p
+}
+
+func TestReceiveFollowerResponsesWriteQuorum(t *testing.T) {
+	errAck := errors.New("follower write failed")
+
+	// 3 replicas with quorum 3, only 2 replicas ack
+	p := newReplicatedPacket(3, nil, errAck)
+	receiveFollowerResponses(p)
+	require.True(t, p.IsErrPacket())
+
+	// quorum not set means all replicas
+	p = newReplicatedPacket(0, errAck, nil)
+	receiveFollowerResponses(p)
+	require.True(t, p.IsErrPacket())
+
+	// 3 replicas with quorum 2, 2 replicas ack
+	p = newReplicatedPacket(2, errAck, nil)
+	receiveFollowerResponses(p)
+	require.False(t, p.IsErrPacket())
+
+	// 3 replicas with quorum 2, only the leader acks
+	p = newReplicatedPacket(2, errAck, errAck)
+	receiveFollowerResponses(p)
+	require.True(t, p.IsErrPacket())
+
+	// all replicas ack
+	p = newReplicatedPacket(3, nil, nil)
+	receiveFollowerResponses(p)
+	require.False(t, p.IsErrPacket())
+
+	// quorum larger than the replicas falls back to all replicas
+	p = newReplicatedPacket(5, nil, nil)
+	require.Equal(t, 3, p.requiredAcks())
+}
+
+func TestReceiveFollowerResponsesLaggingFollower(t *testing.T) {
+	errAck := errors.New("follower write failed")
+
+	// 3 replicas with quorum 2, the second follower fails
+	p := newReplicatedPacket(2, nil, errAck)
+	receiveFollowerResponses(p)
+	require.False(t, p.IsErrPacket())
+	require.Equal(t, []string{"followerb"}, p.LaggingFollowers())
+
+	// all replicas ack, no replica to repair
+	p = newReplicatedPacket(2, nil, nil)
+	receiveFollowerResponses(p)
+	require.False(t, p.IsErrPacket())
+	require.Empty(t, p.LaggingFollowers())
+}
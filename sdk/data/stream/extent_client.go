@@ -18,6 +18,7 @@ import (
 	"container/list"
 	"context"
 	"fmt"
+	"math/rand"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -61,6 +62,12 @@ const (
 	kHighWatermarkPct    = 1.01
 	slowStreamerEvictNum = 10
 	fastStreamerEvictNum = 10000
+
+	recoverBackoffBase       = 10 * time.Millisecond
+	defaultMaxRecoverBackoff = time.Second
+
+	defaultExtentHandlerBufferSize = 1024
+	defaultFlushConcurrency        = 8
 )
 
 var (
@@ -101,6 +108,8 @@ type ExtentConfig struct {
 	Masters           []string
 	FollowerRead      bool
 	NearRead          bool
+	WriteAffinity     string // see wrapper.SetWriteAffinity
+	WriteZone         string
 	Preload           bool
 	ReadRate          int64
 	WriteRate         int64
@@ -119,6 +128,14 @@ type ExtentConfig struct {
 
 	DisableMetaCache             bool
 	MinWriteAbleDataPartitionCnt int
+	WriteReplyTimeout            time.Duration // wait for the reply of a write, proto.ReadDeadlineTime seconds if 0
+	MaxRecoverBackoff            time.Duration // cap of the wait before recovering a failed write, defaultMaxRecoverBackoff if 0
+	// Capacity of the request and reply channels of an extent handler, 1024 if 0.
+	// Each slot may hold a packet with a full block of data, so a handler can pin
+	// up to twice this count of blocks: larger buffers help heavy writers, smaller
+	// ones bound the memory of clients with many files open for writing.
+	ExtentHandlerBufferSize int
+	FlushConcurrency        int // dirty extent handlers of a file flushed at once, defaultFlushConcurrency if 0
 }
 
 type MultiVerMgr struct {
@@ -155,6 +172,10 @@ type ExtentClient struct {
 	inflightL1cache    sync.Map
 	inflightL1BigBlock int32
 	multiVerMgr        *MultiVerMgr
+	writeReplyTimeout  time.Duration
+	maxRecoverBackoff  time.Duration
+	handlerBufferSize  int
+	flushConcurrency   int
 }
 
 func (client *ExtentClient) UidIsLimited(uid uint32) bool {
@@ -229,6 +250,9 @@ func (client *ExtentClient) backgroundEvictStream() {
 
 // NewExtentClient returns a new extent client.
 func NewExtentClient(config *ExtentConfig) (client *ExtentClient, err error) {
+	if config.ExtentHandlerBufferSize < 0 {
+		return nil, fmt.Errorf("invalid extent handler buffer size %v", config.ExtentHandlerBufferSize)
+	}
 	client = new(ExtentClient)
 	client.LimitManager = manager.NewLimitManager(client)
 	client.LimitManager.WrapperUpdate = client.UploadFlowInfo
@@ -261,6 +285,10 @@ retry:
 	client.evictIcache = config.OnEvictIcache
 	client.dataWrapper.InitFollowerRead(config.FollowerRead)
 	client.dataWrapper.SetNearRead(config.NearRead)
+	if err = client.dataWrapper.SetWriteAffinity(config.WriteAffinity, config.WriteZone); err != nil {
+		client.dataWrapper.Stop()
+		return nil, err
+	}
 	client.loadBcache = config.OnLoadBcache
 	client.cacheBcache = config.OnCacheBcache
 	client.evictBcache = config.OnEvictBcache
@@ -272,6 +300,10 @@ retry:
 	client.BcacheHealth = true
 	client.preload = config.Preload
 	client.disableMetaCache = config.DisableMetaCache
+	client.writeReplyTimeout = config.WriteReplyTimeout
+	client.maxRecoverBackoff = config.MaxRecoverBackoff
+	client.handlerBufferSize = config.ExtentHandlerBufferSize
+	client.flushConcurrency = config.FlushConcurrency
 
 	var readLimit, writeLimit rate.Limit
 	if config.ReadRate <= 0 {
@@ -309,6 +341,57 @@ retry:
 	return
 }
 
+// getWriteReplyTimeout returns how long a write waits for the reply of the data node
+// before the packet is recovered on another extent.
+func (client *ExtentClient) getWriteReplyTimeout() time.Duration {
+	if client.writeReplyTimeout <= 0 {
+		return time.Duration(proto.ReadDeadlineTime) * time.Second
+	}
+	return client.writeReplyTimeout
+}
+
+// recoverBackoff returns how long to wait before the errCount-th recovery of a
+// write packet. It doubles from recoverBackoffBase up to the max recover backoff,
+// and the upper half of it is random so that the writers do not retry in step.
+func (client *ExtentClient) recoverBackoff(errCount int) time.Duration {
+	limit := client.maxRecoverBackoff
+	if limit <= 0 {
+		limit = defaultMaxRecoverBackoff
+	}
+	backoff := limit
+	if errCount > 0 && errCount < 32 {
+		if b := recoverBackoffBase << uint(errCount-1); b < limit {
+			backoff = b
+		}
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// getHandlerBufferSize returns the capacity of the channels of an extent handler.
+func (client *ExtentClient) getHandlerBufferSize() int {
+	if client.handlerBufferSize <= 0 {
+		return defaultExtentHandlerBufferSize
+	}
+	return client.handlerBufferSize
+}
+
+// crcAlg returns the checksum algorithm of the data packets of the volume.
+func (client *ExtentClient) crcAlg() uint8 {
+	if client.dataWrapper == nil {
+		return proto.CrcAlgIEEE
+	}
+	return client.dataWrapper.CrcAlg
+}
+
+// getFlushConcurrency returns how many dirty extent handlers of a streamer are
+// flushed at once.
+func (client *ExtentClient) getFlushConcurrency() int {
+	if client.flushConcurrency <= 0 {
+		return defaultFlushConcurrency
+	}
+	return client.flushConcurrency
+}
+
 func (client *ExtentClient) GetEnablePosixAcl() bool {
 	return client.dataWrapper.EnablePosixAcl
 }
@@ -511,6 +594,34 @@ func (client *ExtentClient) Write(inode uint64, offset int, data []byte, flags i
 	return
 }
 
+// SetPreferredDPs pins the new extents of the open stream of the inode to the given
+// data partitions. The other partitions are used when none of them is writable.
+func (client *ExtentClient) SetPreferredDPs(inode uint64, ids []uint64) error {
+	s := client.GetStreamer(inode)
+	if s == nil {
+		log.LogErrorf("SetPreferredDPs: stream is not opened yet, ino(%v)", inode)
+		return syscall.EBADF
+	}
+	s.SetPreferredDPs(ids)
+	return nil
+}
+
+// PrepareWrite sets up the stream of the inode for an append write of size bytes at offset.
+func (client *ExtentClient) PrepareWrite(inode uint64, offset, size int) error {
+	s := client.GetStreamer(inode)
+	if s == nil {
+		log.LogErrorf("PrepareWrite: stream is not opened yet, ino(%v)", inode)
+		return syscall.EBADF
+	}
+
+	s.once.Do(func() {
+		// TODO unhandled error
+		s.GetExtents()
+	})
+
+	return s.IssuePrepareWriteRequest(offset, size)
+}
+
 func (client *ExtentClient) Truncate(mw *meta.MetaWrapper, parentIno uint64, inode uint64, size int) error {
 	prefix := fmt.Sprintf("Truncate{ino(%v)size(%v)}", inode, size)
 	s := client.GetStreamer(inode)
@@ -537,6 +648,20 @@ func (client *ExtentClient) Truncate(mw *meta.MetaWrapper, parentIno uint64, ino
 	return err
 }
 
+// CloneInode clones inode with mw.CloneInode. If the inode has an open stream, its
+// pending writes go to the clone, and the stream stops overwriting the extents now
+// shared with the clone in place.
+func (client *ExtentClient) CloneInode(mw *meta.MetaWrapper, inode uint64, uid, gid uint32) (*proto.InodeInfo, error) {
+	clone := func() (*proto.InodeInfo, error) {
+		return mw.CloneInode(inode, uid, gid)
+	}
+	s := client.GetStreamer(inode)
+	if s == nil {
+		return clone()
+	}
+	return s.IssueCloneRequest(clone)
+}
+
 func (client *ExtentClient) Flush(inode uint64) error {
 	s := client.GetStreamer(inode)
 	if s == nil {
@@ -546,6 +671,18 @@ func (client *ExtentClient) Flush(inode uint64) error {
 	return s.IssueFlushRequest()
 }
 
+// FlushRange flushes the data of the inode written to [offset, offset+size). Without
+// wait it only sends the pending data, with it the data and its extent keys are
+// persisted once it returns.
+func (client *ExtentClient) FlushRange(inode uint64, offset, size int, wait bool) error {
+	s := client.GetStreamer(inode)
+	if s == nil {
+		log.LogErrorf("FlushRange: stream is not opened yet, ino(%v)", inode)
+		return syscall.EBADF
+	}
+	return s.IssueFlushRangeRequest(offset, size, wait)
+}
+
 func (client *ExtentClient) Read(inode uint64, data []byte, offset int, size int) (read int, err error) {
 	//log.LogErrorf("======> ExtentClient Read Enter, inode(%v), len(data)=(%v), offset(%v), size(%v).", inode, len(data), offset, size)
 	//t1 := time.Now()
@@ -568,11 +705,48 @@ func (client *ExtentClient) Read(inode uint64, data []byte, offset int, size int
 		return
 	}
 
-	read, err = s.read(data, offset, size)
+	read, err = s.read(data, offset, size, false)
 	// log.LogErrorf("======> ExtentClient Read Exit, inode(%v), time[%v us].", inode, time.Since(t1).Microseconds())
 	return
 }
 
+// ReadDirect reads the data from the data nodes, bypassing the block cache.
+func (client *ExtentClient) ReadDirect(inode uint64, data []byte, offset int, size int) (read int, err error) {
+	if size == 0 {
+		return
+	}
+
+	s := client.GetStreamer(inode)
+	if s == nil {
+		log.LogErrorf("ReadDirect: stream is not opened yet, ino(%v) offset(%v) size(%v)", inode, offset, size)
+		return 0, syscall.EBADF
+	}
+
+	s.once.Do(func() {
+		s.GetExtents()
+	})
+
+	err = s.IssueFlushRequest()
+	if err != nil {
+		return
+	}
+
+	return s.read(data, offset, size, true)
+}
+
+// IsCached returns whether the range of the inode is fully present in the block cache.
+func (client *ExtentClient) IsCached(inode uint64, offset int, size int) (cached bool, err error) {
+	s := client.GetStreamer(inode)
+	if s == nil {
+		log.LogErrorf("IsCached: stream is not opened yet, ino(%v) offset(%v) size(%v)", inode, offset, size)
+		return false, syscall.EBADF
+	}
+	s.once.Do(func() {
+		s.GetExtents()
+	})
+	return s.isCached(offset, size), nil
+}
+
 func (client *ExtentClient) ReadExtent(inode uint64, ek *proto.ExtentKey, data []byte, offset int, size int) (read int, err error, isStream bool) {
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -673,6 +847,12 @@ func (client *ExtentClient) shouldBcache() bool {
 	return client.bcacheEnable && client.BcacheHealth
 }
 
+// tinyExtentDisabled returns true if the volume is set to write small files to
+// normal extents, whatever the client does by default.
+func (client *ExtentClient) tinyExtentDisabled() bool {
+	return client.dataWrapper != nil && client.dataWrapper.DisableTinyExtent
+}
+
 func getRate(lim *rate.Limiter) string {
 	val := int(lim.Limit())
 	if val > 0 {
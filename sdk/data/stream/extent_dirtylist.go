@@ -59,3 +59,14 @@ func (dl *DirtyExtentList) Len() int {
 	defer dl.RUnlock()
 	return dl.list.Len()
 }
+
+// Elements returns the elements of the dirty extent list in order.
+func (dl *DirtyExtentList) Elements() []*list.Element {
+	dl.RLock()
+	defer dl.RUnlock()
+	elements := make([]*list.Element, 0, dl.list.Len())
+	for e := dl.list.Front(); e != nil; e = e.Next() {
+		elements = append(elements, e)
+	}
+	return elements
+}
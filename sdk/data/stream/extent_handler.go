@@ -91,6 +91,9 @@ type ExtentHandler struct {
 	// Created in receiver ONLY in recovery status.
 	// Will not be changed once assigned.
 	recoverHandler *ExtentHandler
+	// Unix nano time before which no packet is pushed to recoverHandler,
+	// set by the first failed packet.
+	recoverAt int64
 
 	// The stream writer gets the write requests, and constructs the packets
 	// to be sent to the request channel.
@@ -114,6 +117,7 @@ type ExtentHandler struct {
 // NewExtentHandler returns a new extent handler.
 func NewExtentHandler(stream *Streamer, offset int, storeMode int, size int) *ExtentHandler {
 	//	log.LogDebugf("NewExtentHandler stack(%v)", string(debug.Stack()))
+	bufferSize := stream.client.getHandlerBufferSize()
 	eh := &ExtentHandler{
 		stream:       stream,
 		id:           GetExtentHandlerID(),
@@ -121,9 +125,9 @@ func NewExtentHandler(stream *Streamer, offset int, storeMode int, size int) *Ex
 		fileOffset:   offset,
 		size:         size,
 		storeMode:    storeMode,
-		empty:        make(chan struct{}, 1024),
-		request:      make(chan *Packet, 1024),
-		reply:        make(chan *Packet, 1024),
+		empty:        make(chan struct{}, bufferSize),
+		request:      make(chan *Packet, bufferSize),
+		reply:        make(chan *Packet, bufferSize),
 		doneSender:   make(chan struct{}),
 		doneReceiver: make(chan struct{}),
 		verUpdate:    make(chan uint64),
@@ -249,6 +253,7 @@ func (eh *ExtentHandler) sender() {
 			// fill the packet according to the extent
 			packet.PartitionID = eh.dp.PartitionID
 			packet.ExtentType = uint8(eh.storeMode)
+			packet.CrcAlg = eh.stream.client.crcAlg()
 			packet.ExtentID = uint64(eh.extID)
 			packet.ExtentOffset = int64(extOffset)
 			packet.Arg = ([]byte)(eh.dp.GetAllAddrs())
@@ -318,7 +323,7 @@ func (eh *ExtentHandler) processReply(packet *Packet) {
 	}
 
 	reply := NewReply(packet.ReqID, packet.PartitionID, packet.ExtentID)
-	err := reply.ReadFromConnWithVer(eh.conn, proto.ReadDeadlineTime)
+	err := reply.ReadFromConnWithVerTimeout(eh.conn, eh.stream.client.getWriteReplyTimeout())
 	if err != nil {
 		eh.processReplyError(packet, err.Error())
 		return
@@ -490,6 +495,8 @@ func (eh *ExtentHandler) recoverPacket(packet *Packet) error {
 		return errors.New(fmt.Sprintf("recoverPacket failed: reach max error limit, eh(%v) packet(%v)", eh, packet))
 	}
 
+	eh.waitRecoverBackoff(packet)
+
 	handler := eh.recoverHandler
 	if handler == nil {
 		// Always use normal extent store mode for recovery.
@@ -508,12 +515,47 @@ func (eh *ExtentHandler) recoverPacket(packet *Packet) error {
 	return nil
 }
 
+// waitRecoverBackoff delays the recovery of the packet so that a failing data
+// node is not hammered. The first failed packet of the handler picks the wait
+// from its error count, the packets in flight behind it wait for the same time.
+func (eh *ExtentHandler) waitRecoverBackoff(packet *Packet) {
+	now := time.Now()
+	recoverAt := now.Add(eh.stream.client.recoverBackoff(packet.errCount)).UnixNano()
+	if !atomic.CompareAndSwapInt64(&eh.recoverAt, 0, recoverAt) {
+		recoverAt = atomic.LoadInt64(&eh.recoverAt)
+	}
+	if wait := time.Duration(recoverAt - now.UnixNano()); wait > 0 {
+		log.LogDebugf("waitRecoverBackoff: eh(%v) packet(%v) wait(%v)", eh, packet, wait)
+		time.Sleep(wait)
+	}
+}
+
 func (eh *ExtentHandler) discardPacket(packet *Packet) {
 	proto.Buffers.Put(packet.Data)
 	packet.Data = nil
 	eh.setError()
 }
 
+// getDataPartitionForWrite selects the data partition of a new extent, one of the
+// preferred partitions of the stream if any is writable and did not fail yet.
+func (eh *ExtentHandler) getDataPartitionForWrite(exclude map[string]struct{}, failed map[uint64]struct{}) (*wrapper.DataPartition, error) {
+	preferred := eh.stream.PreferredDPs()
+	if len(preferred) == 0 {
+		return eh.stream.client.dataWrapper.GetDataPartitionForWrite(exclude)
+	}
+	candidates := make([]uint64, 0, len(preferred))
+	for _, id := range preferred {
+		if _, ok := failed[id]; !ok {
+			candidates = append(candidates, id)
+		}
+	}
+	dp, err := eh.stream.client.dataWrapper.GetPreferredDataPartitionForWrite(candidates, exclude)
+	if err == nil && len(candidates) == 0 {
+		log.LogWarnf("allocateExtent: preferred dps(%v) all failed, eh(%v) writes to dp(%v)", preferred, eh, dp.PartitionID)
+	}
+	return dp, err
+}
+
 func (eh *ExtentHandler) allocateExtent() (err error) {
 	var (
 		dp    *wrapper.DataPartition
@@ -524,10 +566,12 @@ func (eh *ExtentHandler) allocateExtent() (err error) {
 	log.LogDebugf("ExtentHandler allocateExtent enter: eh(%v)", eh)
 
 	exclude := make(map[string]struct{})
+	// preferred partitions the extent failed on, they are not tried again
+	failed := make(map[uint64]struct{})
 
 	for i := 0; i < MaxSelectDataPartitionForWrite; i++ {
 		if eh.key == nil {
-			if dp, err = eh.stream.client.dataWrapper.GetDataPartitionForWrite(exclude); err != nil {
+			if dp, err = eh.getDataPartitionForWrite(exclude, failed); err != nil {
 				log.LogWarnf("allocateExtent: failed to get write data partition, eh(%v) exclude(%v), clear exclude and try again!", eh, exclude)
 				exclude = make(map[string]struct{})
 				continue
@@ -542,6 +586,7 @@ func (eh *ExtentHandler) allocateExtent() (err error) {
 					dp, eh, err, exclude)
 				eh.stream.client.dataWrapper.RemoveDataPartitionForWrite(dp.PartitionID)
 				dp.CheckAllHostsIsAvail(exclude)
+				failed[dp.PartitionID] = struct{}{}
 				continue
 			}
 		} else {
@@ -560,6 +605,7 @@ func (eh *ExtentHandler) allocateExtent() (err error) {
 			if eh.key != nil {
 				break
 			}
+			failed[dp.PartitionID] = struct{}{}
 			continue
 		}
 
@@ -0,0 +1,153 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtentHandlerWriteReplyTimeout(t *testing.T) {
+	proto.InitBufferPool(int64(32768))
+	client := &ExtentClient{volumeType: proto.VolumeTypeHot}
+	require.Equal(t, time.Duration(proto.ReadDeadlineTime)*time.Second, client.getWriteReplyTimeout())
+	client.writeReplyTimeout = 200 * time.Millisecond
+	require.Equal(t, 200*time.Millisecond, client.getWriteReplyTimeout())
+
+	// a data node which accepts the write but never replies
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			accepted <- c
+		}
+	}()
+	conn, err := net.DialTCP("tcp", nil, ln.Addr().(*net.TCPAddr))
+	require.NoError(t, err)
+	defer conn.Close()
+	defer func() { (<-accepted).Close() }()
+
+	recoverHandler := &ExtentHandler{request: make(chan *Packet, 1)}
+	eh := &ExtentHandler{
+		stream:         &Streamer{client: client, inode: 100},
+		inode:          100,
+		conn:           conn,
+		empty:          make(chan struct{}, 1),
+		recoverHandler: recoverHandler,
+		inflight:       1,
+	}
+	packet := NewWritePacket(100, 0, proto.NormalExtentType)
+
+	start := time.Now()
+	eh.processReply(packet)
+	elapsed := time.Since(start)
+
+	// the write is recovered as soon as the configured timeout expires
+	require.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+	require.Less(t, elapsed, time.Duration(proto.ReadDeadlineTime)*time.Second)
+	require.Equal(t, int32(ExtentStatusRecovery), eh.getStatus())
+	require.Equal(t, packet, <-recoverHandler.request)
+	require.Equal(t, 1, packet.errCount)
+}
+
+func TestExtentHandlerRecoverBackoff(t *testing.T) {
+	proto.InitBufferPool(int64(32768))
+	client := &ExtentClient{volumeType: proto.VolumeTypeHot, maxRecoverBackoff: 80 * time.Millisecond}
+
+	// a data node which drops every connection, so each write fails at once
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	const failures = 6
+	handlers := make([]*ExtentHandler, failures+1)
+	handlers[failures] = &ExtentHandler{request: make(chan *Packet, 1)}
+	for i := failures - 1; i >= 0; i-- {
+		conn, err := net.DialTCP("tcp", nil, ln.Addr().(*net.TCPAddr))
+		require.NoError(t, err)
+		defer conn.Close()
+		handlers[i] = &ExtentHandler{
+			stream:         &Streamer{client: client, inode: 100},
+			inode:          100,
+			conn:           conn,
+			empty:          make(chan struct{}, 1),
+			recoverHandler: handlers[i+1],
+			request:        make(chan *Packet, 1),
+			inflight:       1,
+		}
+	}
+
+	// the packet fails on each handler and is recovered on the next one
+	packet := NewWritePacket(100, 0, proto.NormalExtentType)
+	waits := make([]time.Duration, 0, failures)
+	for i := 0; i < failures; i++ {
+		start := time.Now()
+		handlers[i].processReply(packet)
+		waits = append(waits, time.Since(start))
+		require.Equal(t, i+1, packet.errCount)
+		require.Equal(t, packet, <-handlers[i+1].request)
+	}
+
+	// the waits double from the base with up to half of jitter, then stay capped
+	for i, wait := range waits {
+		backoff := recoverBackoffBase << uint(i)
+		if backoff > client.maxRecoverBackoff {
+			backoff = client.maxRecoverBackoff
+		}
+		require.GreaterOrEqual(t, wait, backoff/2, "attempt %v", i+1)
+		require.Less(t, wait, backoff+50*time.Millisecond, "attempt %v", i+1)
+	}
+	require.Greater(t, waits[3], waits[0])
+
+	// cold volumes fail fast without waiting
+	client.volumeType = proto.VolumeTypeCold
+	start := time.Now()
+	require.Error(t, handlers[0].recoverPacket(packet))
+	require.Less(t, time.Since(start), recoverBackoffBase)
+}
+
+func TestExtentHandlerBufferSize(t *testing.T) {
+	client := &ExtentClient{volumeType: proto.VolumeTypeHot}
+	stream := &Streamer{client: client, inode: 100}
+
+	eh := NewExtentHandler(stream, 0, proto.NormalExtentType, 0)
+	require.Equal(t, defaultExtentHandlerBufferSize, cap(eh.request))
+	require.NoError(t, eh.cleanup())
+
+	client.handlerBufferSize = 16
+	eh = NewExtentHandler(stream, 0, proto.NormalExtentType, 0)
+	require.Equal(t, 16, cap(eh.request))
+	require.Equal(t, 16, cap(eh.reply))
+	require.Equal(t, 16, cap(eh.empty))
+	require.NoError(t, eh.cleanup())
+
+	_, err := NewExtentClient(&ExtentConfig{ExtentHandlerBufferSize: -1})
+	require.Error(t, err)
+}
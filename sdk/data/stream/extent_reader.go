@@ -16,7 +16,6 @@ package stream
 
 import (
 	"fmt"
-	"hash/crc32"
 	"net"
 	"strings"
 
@@ -34,16 +33,19 @@ type ExtentReader struct {
 	dp           *wrapper.DataPartition
 	followerRead bool
 	retryRead    bool
+	crcAlg       uint8
 }
 
-// NewExtentReader returns a new extent reader.
-func NewExtentReader(inode uint64, key *proto.ExtentKey, dp *wrapper.DataPartition, followerRead bool, retryRead bool) *ExtentReader {
+// NewExtentReader returns a new extent reader, the data read is verified with the
+// checksum algorithm crcAlg.
+func NewExtentReader(inode uint64, key *proto.ExtentKey, dp *wrapper.DataPartition, followerRead bool, retryRead bool, crcAlg uint8) *ExtentReader {
 	return &ExtentReader{
 		inode:        inode,
 		key:          key,
 		dp:           dp,
 		followerRead: followerRead,
 		retryRead:    retryRead,
+		crcAlg:       crcAlg,
 	}
 }
 
@@ -59,6 +61,7 @@ func (reader *ExtentReader) Read(req *ExtentRequest) (readBytes int, err error)
 	size := req.Size
 
 	reqPacket := NewReadPacket(reader.key, offset, size, reader.inode, req.FileOffset, reader.followerRead)
+	reqPacket.CrcAlg = reader.crcAlg
 	sc := NewStreamConn(reader.dp, reader.followerRead)
 
 	log.LogDebugf("ExtentReader Read enter: size(%v) req(%v) reqPacket(%v)", size, req, reqPacket)
@@ -127,7 +130,9 @@ func (reader *ExtentReader) checkStreamReply(request *Packet, reply *Packet) (er
 		err = errors.New(fmt.Sprintf("checkStreamReply: inconsistent req and reply, req(%v) reply(%v)", request, reply))
 		return
 	}
-	expectCrc := crc32.ChecksumIEEE(reply.Data[:reply.Size])
+	// a data node not knowing the algorithm of the request replies another crc,
+	// so the data is checked with the algorithm requested, not the replied one
+	expectCrc := proto.Checksum(request.CrcAlg, reply.Data[:reply.Size])
 	if reply.CRC != expectCrc {
 		err = errors.New(fmt.Sprintf("checkStreamReply: inconsistent CRC, expectCRC(%v) replyCRC(%v)", expectCrc, reply.CRC))
 		return
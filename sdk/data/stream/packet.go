@@ -20,7 +20,6 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/data/wrapper"
 	"github.com/cubefs/cubefs/util"
-	"hash/crc32"
 	"io"
 	"net"
 	"time"
@@ -182,7 +181,7 @@ func (p *Packet) isValidReadReply(q *Packet) bool {
 }
 
 func (p *Packet) writeToConn(conn net.Conn) error {
-	p.CRC = crc32.ChecksumIEEE(p.Data[:p.Size])
+	p.CRC = p.ComputeCRC(p.Data[:p.Size])
 	return p.WriteToConn(conn)
 }
 
@@ -54,6 +54,8 @@ type Streamer struct {
 	pendingCache         chan bcacheKey
 	verSeq               uint64
 	needUpdateVer        int32
+	preferredLock        sync.RWMutex
+	preferredDPs         []uint64 // partitions the new extents are created on first
 }
 
 type bcacheKey struct {
@@ -84,6 +86,21 @@ func (s *Streamer) SetParentInode(inode uint64) {
 	s.parentInode = inode
 }
 
+// SetPreferredDPs pins the new extents of the stream to the given data partitions,
+// they are created on the first writable one of them. Nil removes the pin.
+func (s *Streamer) SetPreferredDPs(ids []uint64) {
+	s.preferredLock.Lock()
+	defer s.preferredLock.Unlock()
+	s.preferredDPs = append([]uint64(nil), ids...)
+}
+
+// PreferredDPs returns the data partitions the new extents of the stream are pinned to.
+func (s *Streamer) PreferredDPs() []uint64 {
+	s.preferredLock.RLock()
+	defer s.preferredLock.RUnlock()
+	return s.preferredDPs
+}
+
 // String returns the string format of the streamer.
 func (s *Streamer) String() string {
 	return fmt.Sprintf("Streamer{ino(%v)}", s.inode)
@@ -120,11 +137,12 @@ func (s *Streamer) GetExtentReader(ek *proto.ExtentKey) (*ExtentReader, error) {
 		retryRead = false
 	}
 
-	reader := NewExtentReader(s.inode, ek, partition, s.client.dataWrapper.FollowerRead(), retryRead)
+	reader := NewExtentReader(s.inode, ek, partition, s.client.dataWrapper.FollowerRead(), retryRead, s.client.crcAlg())
 	return reader, nil
 }
 
-func (s *Streamer) read(data []byte, offset int, size int) (total int, err error) {
+// read reads the data of the file, through the block cache unless direct is set.
+func (s *Streamer) read(data []byte, offset int, size int, direct bool) (total int, err error) {
 	//log.LogErrorf("==========> Streamer Read Enter, inode(%v).", s.inode)
 	//t1 := time.Now()
 	var (
@@ -133,6 +151,7 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 		requests        []*ExtentRequest
 		revisedRequests []*ExtentRequest
 	)
+	needBCache := s.needBCache && !direct
 	log.LogDebugf("action[streamer.read] offset %v size %v", offset, size)
 	ctx := context.Background()
 	s.client.readLimiter.Wait(ctx)
@@ -185,16 +204,16 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 			total += req.Size
 			log.LogDebugf("Stream read hole: ino(%v) req(%v) total(%v)", s.inode, req, total)
 		} else {
-			log.LogDebugf("Stream read: ino(%v) req(%v) s.needBCache(%v) s.client.bcacheEnable(%v)", s.inode, req, s.needBCache, s.client.bcacheEnable)
-			if s.needBCache {
+			log.LogDebugf("Stream read: ino(%v) req(%v) needBCache(%v) s.client.bcacheEnable(%v)", s.inode, req, needBCache, s.client.bcacheEnable)
+			if needBCache {
 				bcacheMetric := exporter.NewCounter("fileReadL1Cache")
 				bcacheMetric.AddWithLabels(1, map[string]string{exporter.Vol: s.client.volumeName})
 			}
 
 			//skip hole,ek is not nil,read block cache firstly
-			log.LogDebugf("Stream read: ino(%v) req(%v) s.client.bcacheEnable(%v) s.needBCache(%v)", s.inode, req, s.client.bcacheEnable, s.needBCache)
-			cacheKey := util.GenerateRepVolKey(s.client.volumeName, s.inode, req.ExtentKey.PartitionId, req.ExtentKey.ExtentId, req.ExtentKey.FileOffset)
-			if s.client.bcacheEnable && s.needBCache && filesize <= bcache.MaxFileSize {
+			log.LogDebugf("Stream read: ino(%v) req(%v) s.client.bcacheEnable(%v) needBCache(%v)", s.inode, req, s.client.bcacheEnable, needBCache)
+			cacheKey := s.bcacheKey(req.ExtentKey)
+			if s.client.bcacheEnable && needBCache && filesize <= bcache.MaxFileSize {
 				offset := req.FileOffset - int(req.ExtentKey.FileOffset)
 				if s.client.loadBcache != nil {
 					readBytes, err = s.client.loadBcache(cacheKey, req.Data, uint64(offset), uint32(req.Size))
@@ -209,7 +228,7 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 				log.LogDebugf("TRACE Stream read. miss blockCache cacheKey(%v) loadBcache(%v)", cacheKey, s.client.loadBcache)
 			}
 
-			if s.needBCache {
+			if needBCache {
 				bcacheMetric := exporter.NewCounter("fileReadL1CacheMiss")
 				bcacheMetric.AddWithLabels(1, map[string]string{exporter.Vol: s.client.volumeName})
 			}
@@ -221,7 +240,7 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 				break
 			}
 
-			if s.client.bcacheEnable && s.needBCache && filesize <= bcache.MaxFileSize {
+			if s.client.bcacheEnable && needBCache && filesize <= bcache.MaxFileSize {
 				//limit big block cache
 				if s.exceedBlockSize(req.ExtentKey.Size) && atomic.LoadInt32(&s.client.inflightL1BigBlock) > 10 {
 					//do nothing
@@ -253,6 +272,48 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 	return
 }
 
+func (s *Streamer) bcacheKey(ek *proto.ExtentKey) string {
+	return util.GenerateRepVolKey(s.client.volumeName, s.inode, ek.PartitionId, ek.ExtentId, ek.FileOffset)
+}
+
+// isCached reports whether the range can be read without fetching data from the data nodes.
+// Block cache entries hold whole extents, so probing one byte of every overlapped extent is enough,
+// holes and the part beyond the file size are filled locally and count as cached.
+func (s *Streamer) isCached(offset int, size int) bool {
+	if size <= 0 {
+		return true
+	}
+	filesize, _ := s.extents.Size()
+	if !s.client.bcacheEnable || !s.needBCache || s.client.loadBcache == nil || filesize > bcache.MaxFileSize {
+		return false
+	}
+	end := offset + size
+	if end > filesize {
+		end = filesize
+	}
+	probe := make([]byte, 1)
+	for _, ek := range s.extents.List() {
+		ekStart := int(ek.FileOffset)
+		ekEnd := ekStart + int(ek.Size)
+		if ekEnd <= offset {
+			continue
+		}
+		if ekStart >= end {
+			break
+		}
+		start := offset
+		if start < ekStart {
+			start = ekStart
+		}
+		readBytes, err := s.client.loadBcache(s.bcacheKey(ek), probe, uint64(start-ekStart), 1)
+		if err != nil || readBytes != 1 {
+			log.LogDebugf("isCached: ino(%v) ek(%v) not cached, err(%v)", s.inode, ek, err)
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Streamer) asyncBlockCache() {
 	if !s.needBCache || !s.isOpen {
 		return
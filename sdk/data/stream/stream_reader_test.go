@@ -0,0 +1,121 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+// memBcache stands for the block cache service, entries are kept in memory.
+type memBcache struct {
+	sync.Mutex
+	blocks map[string][]byte
+}
+
+func (m *memBcache) get(key string, buf []byte, offset uint64, size uint32) (int, error) {
+	m.Lock()
+	defer m.Unlock()
+	block, ok := m.blocks[key]
+	if !ok || offset+uint64(size) > uint64(len(block)) {
+		return 0, fmt.Errorf("key %v not found", key)
+	}
+	return copy(buf, block[offset:offset+uint64(size)]), nil
+}
+
+func (m *memBcache) put(key string, buf []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	m.blocks[key] = append([]byte(nil), buf...)
+	return nil
+}
+
+func TestStreamerIsCached(t *testing.T) {
+	bc := &memBcache{blocks: make(map[string][]byte)}
+	client := &ExtentClient{
+		volumeName:   "vol",
+		bcacheEnable: true,
+		loadBcache:   bc.get,
+		cacheBcache:  bc.put,
+	}
+	ino := uint64(100)
+	s := &Streamer{client: client, inode: ino, extents: NewExtentCache(ino), needBCache: true}
+
+	// two 4K extents with a 4K hole between them
+	eks := []*proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1, Size: 4096},
+		{FileOffset: 8192, PartitionId: 1, ExtentId: 2, Size: 4096},
+	}
+	for _, ek := range eks {
+		s.extents.Append(ek, true)
+	}
+	s.extents.SetSize(12288, true)
+
+	require.False(t, s.isCached(0, 4096))
+	require.True(t, s.isCached(0, 0))
+
+	// reading the first extent puts it into the block cache
+	require.NoError(t, client.cacheBcache(s.bcacheKey(eks[0]), make([]byte, eks[0].Size)))
+	require.True(t, s.isCached(0, 4096))
+	require.True(t, s.isCached(100, 1000))
+	// the hole needs no fetch
+	require.True(t, s.isCached(1000, 7000))
+	require.True(t, s.isCached(4096, 4096))
+	// the second extent is not read yet
+	require.False(t, s.isCached(4000, 4200))
+	require.False(t, s.isCached(8192, 4096))
+	require.False(t, s.isCached(0, 12288))
+
+	require.NoError(t, client.cacheBcache(s.bcacheKey(eks[1]), make([]byte, eks[1].Size)))
+	require.True(t, s.isCached(0, 12288))
+	// the part beyond the file size is never fetched
+	require.True(t, s.isCached(8192, 1<<20))
+
+	// nothing is cached when the block cache is not used by the stream
+	s.needBCache = false
+	require.False(t, s.isCached(0, 4096))
+}
+
+func TestExtentReaderCheckCrcAlg(t *testing.T) {
+	key := &proto.ExtentKey{PartitionId: 1, ExtentId: 1025, Size: 4096}
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for _, alg := range []uint8{proto.CrcAlgIEEE, proto.CrcAlgCastagnoli} {
+		reader := NewExtentReader(100, key, nil, false, false, alg)
+		request := NewReadPacket(key, 0, len(data), 100, 0, false)
+		request.CrcAlg = reader.crcAlg
+		reply := NewReply(request.ReqID, key.PartitionId, key.ExtentId)
+		reply.ResultCode = proto.OpOk
+		reply.Data = append([]byte{}, data...)
+		reply.Size = uint32(len(data))
+		reply.CRC = proto.Checksum(alg, data)
+		require.NoError(t, reader.checkStreamReply(request, reply))
+
+		// corrupted data
+		reply.Data[10] ^= 1
+		require.Error(t, reader.checkStreamReply(request, reply))
+		reply.Data[10] ^= 1
+
+		// a data node replying the crc of another algorithm
+		reply.CRC = proto.Checksum(1-alg, data)
+		require.Error(t, reader.checkStreamReply(request, reply))
+	}
+}
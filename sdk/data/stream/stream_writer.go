@@ -15,10 +15,12 @@
 package stream
 
 import (
+	"container/list"
 	"context"
 	"fmt"
-	"hash/crc32"
+	"math"
 	"net"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -73,12 +75,29 @@ type WriteRequest struct {
 	checkFunc  func() error
 }
 
+// PrepareWriteRequest defines a request to set up the extent handler for the upcoming writes.
+type PrepareWriteRequest struct {
+	fileOffset int
+	size       int
+	err        error
+	done       chan struct{}
+}
+
 // FlushRequest defines a flush request.
 type FlushRequest struct {
 	err  error
 	done chan struct{}
 }
 
+// FlushRangeRequest defines a request to flush the handlers writing a file range.
+type FlushRangeRequest struct {
+	fileOffset int
+	size       int
+	wait       bool
+	err        error
+	done       chan struct{}
+}
+
 // ReleaseRequest defines a release request.
 type ReleaseRequest struct {
 	err  error
@@ -92,6 +111,14 @@ type TruncRequest struct {
 	done chan struct{}
 }
 
+// CloneRequest defines a request to clone the inode of the streamer.
+type CloneRequest struct {
+	clone func() (*proto.InodeInfo, error)
+	info  *proto.InodeInfo
+	err   error
+	done  chan struct{}
+}
+
 // EvictRequest defines an evict request.
 type EvictRequest struct {
 	err  error
@@ -133,6 +160,26 @@ func (s *Streamer) IssueWriteRequest(offset int, data []byte, flags int, checkFu
 	return
 }
 
+// IssuePrepareWriteRequest opens an extent handler at offset and allocates its extent and
+// connection in advance, so that the first write of size bytes does not pay for them.
+func (s *Streamer) IssuePrepareWriteRequest(offset, size int) error {
+	if atomic.LoadInt32(&s.status) >= StreamerError {
+		return errors.New(fmt.Sprintf("IssuePrepareWriteRequest: stream writer in error status, ino(%v)", s.inode))
+	}
+
+	s.writeLock.Lock()
+	request := &PrepareWriteRequest{
+		fileOffset: offset,
+		size:       size,
+		done:       make(chan struct{}, 1),
+	}
+	s.request <- request
+	s.writeLock.Unlock()
+
+	<-request.done
+	return request.err
+}
+
 func (s *Streamer) IssueFlushRequest() error {
 	request := flushRequestPool.Get().(*FlushRequest)
 	request.done = make(chan struct{}, 1)
@@ -143,6 +190,20 @@ func (s *Streamer) IssueFlushRequest() error {
 	return err
 }
 
+// IssueFlushRangeRequest flushes the dirty handlers writing [offset, offset+size),
+// see flushRangeHandlers.
+func (s *Streamer) IssueFlushRangeRequest(offset, size int, wait bool) error {
+	request := &FlushRangeRequest{
+		fileOffset: offset,
+		size:       size,
+		wait:       wait,
+		done:       make(chan struct{}, 1),
+	}
+	s.request <- request
+	<-request.done
+	return request.err
+}
+
 func (s *Streamer) IssueReleaseRequest() error {
 	request := releaseRequestPool.Get().(*ReleaseRequest)
 	request.done = make(chan struct{}, 1)
@@ -165,6 +226,17 @@ func (s *Streamer) IssueTruncRequest(size int) error {
 	return err
 }
 
+// IssueCloneRequest calls clone once the pending writes are persisted, see clone.
+func (s *Streamer) IssueCloneRequest(clone func() (*proto.InodeInfo, error)) (*proto.InodeInfo, error) {
+	request := &CloneRequest{
+		clone: clone,
+		done:  make(chan struct{}, 1),
+	}
+	s.request <- request
+	<-request.done
+	return request.info, request.err
+}
+
 func (s *Streamer) IssueEvictRequest() error {
 	request := evictRequestPool.Get().(*EvictRequest)
 	request.done = make(chan struct{}, 1)
@@ -178,8 +250,8 @@ func (s *Streamer) IssueEvictRequest() error {
 
 func (s *Streamer) GetStoreMod(offset int, size int) (storeMode int) {
 	// Small files are usually written in a single write, so use tiny extent
-	// store only for the first write operation.
-	if offset > 0 || offset+size > s.tinySizeLimit() {
+	// store only for the first write operation, unless the volume disables it.
+	if offset > 0 || offset+size > s.tinySizeLimit() || s.client.tinyExtentDisabled() {
 		storeMode = proto.NormalExtentType
 	} else {
 		storeMode = proto.TinyExtentType
@@ -254,15 +326,24 @@ func (s *Streamer) abortRequest(request interface{}) {
 	case *WriteRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
+	case *PrepareWriteRequest:
+		request.err = syscall.EAGAIN
+		request.done <- struct{}{}
 	case *TruncRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
 	case *FlushRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
+	case *FlushRangeRequest:
+		request.err = syscall.EAGAIN
+		request.done <- struct{}{}
 	case *ReleaseRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
+	case *CloneRequest:
+		request.err = syscall.EAGAIN
+		request.done <- struct{}{}
 	case *EvictRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
@@ -283,18 +364,27 @@ func (s *Streamer) handleRequest(request interface{}) {
 	case *WriteRequest:
 		request.writeBytes, request.err = s.write(request.data, request.fileOffset, request.size, request.flags, request.checkFunc)
 		request.done <- struct{}{}
+	case *PrepareWriteRequest:
+		request.err = s.prepareWrite(request.fileOffset, request.size)
+		request.done <- struct{}{}
 	case *TruncRequest:
 		request.err = s.truncate(request.size)
 		request.done <- struct{}{}
 	case *FlushRequest:
 		request.err = s.flush()
 		request.done <- struct{}{}
+	case *FlushRangeRequest:
+		request.err = s.flushRangeHandlers(request.fileOffset, request.size, request.wait)
+		request.done <- struct{}{}
 	case *ReleaseRequest:
 		request.err = s.release()
 		request.done <- struct{}{}
 	case *EvictRequest:
 		request.err = s.evict()
 		request.done <- struct{}{}
+	case *CloneRequest:
+		request.info, request.err = s.clone(request.clone)
+		request.done <- struct{}{}
 	case *VerUpdateRequest:
 		request.err = s.updateVer(request.verSeq)
 		request.done <- struct{}{}
@@ -358,7 +448,8 @@ begin:
 			}
 			log.LogDebugf("action[streamer.write] inode [%v] latest seq [%v] extentkey seq [%v]  info [%v]",
 				s.inode, s.verSeq, req.ExtentKey.GetSeq(), req.ExtentKey)
-			if req.ExtentKey.GetSeq() == s.verSeq {
+			// extents shared with a cloned inode are never overwritten in place
+			if req.ExtentKey.GetSeq() == s.verSeq && !req.ExtentKey.IsShared() {
 				writeSize, err = s.doOverwrite(req, direct)
 				if err == proto.ErrCodeVersionOp {
 					log.LogDebugf("action[streamer.write] write need version update")
@@ -494,7 +585,8 @@ func (s *Streamer) doOverwriteByAppend(req *ExtentRequest, direct bool, op uint8
 		packSize := util.Min(size-total, util.BlockSize)
 		copy(reqPacket.Data[:packSize], req.Data[total:total+packSize])
 		reqPacket.Size = uint32(packSize)
-		reqPacket.CRC = crc32.ChecksumIEEE(reqPacket.Data[:packSize])
+		reqPacket.CrcAlg = s.client.crcAlg()
+		reqPacket.CRC = reqPacket.ComputeCRC(reqPacket.Data[:packSize])
 
 		err = sc.Send(&retry, reqPacket, func(conn *net.TCPConn) (error, bool) {
 			e := replyPacket.ReadFromConnWithVer(conn, proto.ReadDeadlineTime)
@@ -621,7 +713,8 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 		packSize := util.Min(size-total, util.BlockSize)
 		copy(reqPacket.Data[:packSize], req.Data[total:total+packSize])
 		reqPacket.Size = uint32(packSize)
-		reqPacket.CRC = crc32.ChecksumIEEE(reqPacket.Data[:packSize])
+		reqPacket.CrcAlg = s.client.crcAlg()
+		reqPacket.CRC = reqPacket.ComputeCRC(reqPacket.Data[:packSize])
 		reqPacket.VerSeq = s.verSeq
 
 		replyPacket := new(Packet)
@@ -682,7 +775,8 @@ func (s *Streamer) ExistExtentNeedMoreWork(offset, size int) (find bool) {
 
 	// && (s.handler == nil || s.handler != nil && s.handler.fileOffset+s.handler.size != offset)  delete ??
 	if storeMode == proto.NormalExtentType && (s.handler == nil || s.handler != nil && s.handler.fileOffset+s.handler.size != offset) {
-		if currentEK := s.extents.GetEndForAppendWrite(uint64(offset), s.verSeq, false); currentEK != nil && !storage.IsTinyExtent(currentEK.ExtentId) {
+		// a shared extent may be appended by the other inode as well, start a new one
+		if currentEK := s.extents.GetEndForAppendWrite(uint64(offset), s.verSeq, false); currentEK != nil && !storage.IsTinyExtent(currentEK.ExtentId) && !currentEK.IsShared() {
 			if currentEK.GetSeq() != s.verSeq {
 				log.LogDebugf("doAppendWrite. exist ek seq %v vs request seq %v", currentEK.GetSeq(), s.verSeq)
 				find = true
@@ -730,7 +824,7 @@ func (s *Streamer) doAppendWrite(data []byte, offset, size int, direct bool, che
 
 	// Small files are usually written in a single write, so use tiny extent
 	// store only for the first write operation.
-	storeMode = s.GetStoreMod(offset, size)
+	storeMode = s.appendStoreMode(offset, size)
 
 	log.LogDebugf("doAppendWrite enter: ino(%v) offset(%v) size(%v) storeMode(%v)", s.inode, offset, size, storeMode)
 	if proto.IsHot(s.client.volumeType) {
@@ -786,21 +880,166 @@ func (s *Streamer) doAppendWrite(data []byte, offset, size int, direct bool, che
 	return
 }
 
+// appendStoreMode returns the store mode of an append write. A normal extent handler
+// prepared at offset keeps serving the write even if it would fit in a tiny extent.
+func (s *Streamer) appendStoreMode(offset, size int) int {
+	if s.handler != nil && s.handler.storeMode == proto.NormalExtentType && s.handler.fileOffset+s.handler.size == offset {
+		return proto.NormalExtentType
+	}
+	return s.GetStoreMod(offset, size)
+}
+
+// prepareWrite sets up the open handler for an append write of size bytes at offset,
+// allocating its extent and connection right away instead of on the first packet.
+func (s *Streamer) prepareWrite(offset, size int) (err error) {
+	if !proto.IsHot(s.client.volumeType) {
+		// cold volumes open a handler per write, there is nothing to keep
+		return
+	}
+	if s.handler != nil && s.handler.getStatus() == ExtentStatusOpen && s.handler.fileOffset+s.handler.size == offset {
+		return
+	}
+	s.closeOpenHandler()
+
+	eh := NewExtentHandler(s, offset, s.GetStoreMod(offset, size), 0)
+	if err = eh.allocateExtent(); err != nil {
+		log.LogWarnf("prepareWrite: ino(%v) offset(%v) size(%v) err(%v)", s.inode, offset, size, err)
+		eh.cleanup()
+		return
+	}
+	s.handler = eh
+	s.dirty = false
+	log.LogDebugf("prepareWrite: ino(%v) offset(%v) size(%v) eh(%v) dp(%v) extID(%v)", s.inode, offset, size, eh, eh.dp, eh.extID)
+	return
+}
+
+// flush persists the extent keys of all the dirty handlers. Handlers whose file
+// ranges do not overlap are flushed concurrently, up to the flush concurrency of
+// the client. The meta node resolves overlapping extent keys by their append
+// order, so a handler overlapping an earlier one in the dirty list, like the
+// handler recovering its packets, is only flushed once the earlier one is done.
 func (s *Streamer) flush() (err error) {
 	for {
-		element := s.dirtylist.Get()
-		if element == nil {
+		elements := s.dirtylist.Elements()
+		if len(elements) == 0 {
 			break
 		}
-		eh := element.Value.(*ExtentHandler)
+		for len(elements) > 0 {
+			n := nextFlushBatch(elements)
+			if err = s.flushHandlers(elements[:n]); err != nil {
+				return
+			}
+			elements = elements[n:]
+		}
+	}
+	return
+}
 
-		log.LogDebugf("Streamer flush begin: eh(%v)", eh)
-		err = eh.flush()
-		if err != nil {
-			log.LogErrorf("Streamer flush failed: eh(%v)", eh)
+// flushRangeHandlers flushes the dirty handlers writing [offset, offset+size), the
+// other handlers stay dirty. Without wait the pending packets of the handlers are
+// only sent, with it the handlers are flushed like flush does, which waits for
+// their inflight packets and persists their extent keys. The handlers earlier in
+// the dirty list overlapping one of them are flushed with it, so the extent keys
+// are still appended in order.
+func (s *Streamer) flushRangeHandlers(offset, size int, wait bool) (err error) {
+	elements := s.dirtylist.Elements()
+	selected := make([]bool, len(elements))
+	for i := len(elements) - 1; i >= 0; i-- {
+		start, end := flushRange(elements[i].Value.(*ExtentHandler))
+		if !selected[i] && start < offset+size && offset < end {
+			selected[i] = true
+		}
+		if !selected[i] {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			prevStart, prevEnd := flushRange(elements[j].Value.(*ExtentHandler))
+			if start < prevEnd && prevStart < end {
+				selected[j] = true
+			}
+		}
+	}
+	n := 0
+	for i, e := range elements {
+		if selected[i] {
+			elements[n] = e
+			n++
+		}
+	}
+	elements = elements[:n]
+
+	if !wait {
+		for _, e := range elements {
+			e.Value.(*ExtentHandler).flushPacket()
+		}
+		return
+	}
+	for len(elements) > 0 {
+		n = nextFlushBatch(elements)
+		if err = s.flushHandlers(elements[:n]); err != nil {
 			return
 		}
-		eh.stream.dirtylist.Remove(element)
+		elements = elements[n:]
+	}
+	return
+}
+
+// nextFlushBatch returns the length of the longest prefix of elements in which
+// no handler overlaps another one.
+func nextFlushBatch(elements []*list.Element) int {
+	for n := 1; n < len(elements); n++ {
+		start, end := flushRange(elements[n].Value.(*ExtentHandler))
+		for _, e := range elements[:n] {
+			prevStart, prevEnd := flushRange(e.Value.(*ExtentHandler))
+			if start < prevEnd && prevStart < end {
+				return n
+			}
+		}
+	}
+	return len(elements)
+}
+
+// flushRange returns the file range an extent handler may write. A recovery
+// handler is fed packets of the failed handler instead of writes so its size
+// stays zero, it is taken to reach the end of the file.
+func flushRange(eh *ExtentHandler) (start, end int) {
+	if eh.size == 0 {
+		return eh.fileOffset, math.MaxInt64
+	}
+	return eh.fileOffset, eh.fileOffset + eh.size
+}
+
+func (s *Streamer) flushHandlers(elements []*list.Element) (err error) {
+	errs := make([]error, len(elements))
+	if len(elements) == 1 {
+		errs[0] = s.flushHandler(elements[0].Value.(*ExtentHandler))
+	} else {
+		var wg sync.WaitGroup
+		limit := make(chan struct{}, s.client.getFlushConcurrency())
+		for i, e := range elements {
+			wg.Add(1)
+			limit <- struct{}{}
+			go func(i int, eh *ExtentHandler) {
+				defer func() {
+					<-limit
+					wg.Done()
+				}()
+				errs[i] = s.flushHandler(eh)
+			}(i, e.Value.(*ExtentHandler))
+		}
+		wg.Wait()
+	}
+
+	// the handlers flushed are done even if another one of the batch failed
+	for i, e := range elements {
+		eh := e.Value.(*ExtentHandler)
+		if errs[i] != nil {
+			if err == nil {
+				err = errs[i]
+			}
+			continue
+		}
+		s.dirtylist.Remove(e)
 		if eh.getStatus() == ExtentStatusOpen {
 			s.dirty = false
 			log.LogDebugf("Streamer flush handler open: eh(%v)", eh)
@@ -809,11 +1048,20 @@ func (s *Streamer) flush() (err error) {
 			eh.cleanup()
 			log.LogDebugf("Streamer flush handler cleaned up: eh(%v)", eh)
 		}
-		log.LogDebugf("Streamer flush end: eh(%v)", eh)
 	}
 	return
 }
 
+func (s *Streamer) flushHandler(eh *ExtentHandler) (err error) {
+	log.LogDebugf("Streamer flush begin: eh(%v)", eh)
+	if err = eh.flush(); err != nil {
+		log.LogErrorf("Streamer flush failed: eh(%v)", eh)
+		return
+	}
+	log.LogDebugf("Streamer flush end: eh(%v)", eh)
+	return
+}
+
 func (s *Streamer) traverse() (err error) {
 	s.traversed++
 	length := s.dirtylist.Len()
@@ -951,6 +1199,20 @@ func (s *Streamer) truncate(size int) error {
 	return s.GetExtentsForce()
 }
 
+// clone persists the pending writes before the inode is cloned, so that the clone
+// has them. Afterwards the cached extents are reloaded: they are marked as shared
+// now, and must no longer be overwritten in place nor appended to.
+func (s *Streamer) clone(clone func() (*proto.InodeInfo, error)) (info *proto.InodeInfo, err error) {
+	s.closeOpenHandler()
+	if err = s.flush(); err != nil {
+		return
+	}
+	if info, err = clone(); err != nil {
+		return
+	}
+	return info, s.GetExtentsForce()
+}
+
 func (s *Streamer) updateVer(verSeq uint64) (err error) {
 	log.LogInfof("action[stream.updateVer] ver %v update to %v", s.verSeq, verSeq)
 	if s.verSeq != verSeq {
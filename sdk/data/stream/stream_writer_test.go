@@ -0,0 +1,204 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/wrapper"
+	"github.com/cubefs/cubefs/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamerStoreModeDisableTinyExtent(t *testing.T) {
+	client := &ExtentClient{dataWrapper: &wrapper.Wrapper{}}
+	s := &Streamer{client: client, inode: 100}
+
+	// a default client writes the first small write to a tiny extent
+	require.Equal(t, proto.TinyExtentType, s.GetStoreMod(0, 4096))
+	require.Equal(t, proto.NormalExtentType, s.GetStoreMod(4096, 4096))
+
+	// the volume setting overrides it
+	client.dataWrapper.DisableTinyExtent = true
+	require.Equal(t, proto.NormalExtentType, s.GetStoreMod(0, 4096))
+	require.Equal(t, proto.NormalExtentType, s.GetStoreMod(0, 1))
+}
+
+func TestStreamerAppendStoreModePrepared(t *testing.T) {
+	client := &ExtentClient{dataWrapper: &wrapper.Wrapper{}, volumeType: proto.VolumeTypeHot}
+	s := &Streamer{client: client, inode: 100}
+
+	// without a prepared handler the first small write goes to a tiny extent
+	require.Equal(t, proto.TinyExtentType, s.appendStoreMode(0, 4096))
+
+	// a normal extent handler prepared at the offset serves the write instead
+	s.handler = &ExtentHandler{fileOffset: 0, storeMode: proto.NormalExtentType}
+	require.Equal(t, proto.NormalExtentType, s.appendStoreMode(0, 4096))
+	s.handler.size = 4096
+	require.Equal(t, proto.NormalExtentType, s.appendStoreMode(4096, 4096))
+
+	// unless the write is not continuous with it
+	require.Equal(t, proto.TinyExtentType, s.appendStoreMode(0, 4096))
+	s.handler = &ExtentHandler{fileOffset: 0, storeMode: proto.TinyExtentType}
+	require.Equal(t, proto.TinyExtentType, s.appendStoreMode(0, 4096))
+
+	// cold volumes have nothing to prepare
+	client.volumeType = proto.VolumeTypeCold
+	s.handler = nil
+	require.NoError(t, s.prepareWrite(0, 4096))
+	require.Nil(t, s.handler)
+}
+
+// newFlushTestStreamer returns a streamer whose extent keys are appended by
+// appendKey, with count closed dirty handlers of size bytes laid end to end.
+func newFlushTestStreamer(appendKey AppendExtentKeyFunc, concurrency, count, size int) *Streamer {
+	client := &ExtentClient{volumeType: proto.VolumeTypeHot, appendExtentKey: appendKey, flushConcurrency: concurrency}
+	s := &Streamer{client: client, inode: 100, extents: NewExtentCache(100), dirtylist: NewDirtyExtentList()}
+	for i := 0; i < count; i++ {
+		addFlushTestHandler(s, i*size, size, uint64(i+1))
+	}
+	return s
+}
+
+func addFlushTestHandler(s *Streamer, offset, size int, extID uint64) *ExtentHandler {
+	eh := NewExtentHandler(s, offset, proto.NormalExtentType, 0)
+	eh.setClosed()
+	eh.size = size
+	eh.dirty = true
+	eh.key = &proto.ExtentKey{FileOffset: uint64(offset), PartitionId: 1, ExtentId: extID, Size: uint32(size)}
+	s.dirtylist.Put(eh)
+	return eh
+}
+
+func TestStreamerParallelFlush(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		appended []proto.ExtentKey
+		running  int32
+		maxRun   int32
+	)
+	appendKey := func(parentInode, inode uint64, key proto.ExtentKey, discard []proto.ExtentKey) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRun)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRun, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		lock.Lock()
+		appended = append(appended, key)
+		lock.Unlock()
+		return nil
+	}
+
+	const count, size = 8, 128 * 1024 * 1024
+	s := newFlushTestStreamer(appendKey, 4, count, size)
+	// a handler recovering the packets of the last one must be appended after it
+	addFlushTestHandler(s, (count-1)*size+4096, 0, count+1)
+
+	require.NoError(t, s.flush())
+	require.Equal(t, 0, s.dirtylist.Len())
+	require.Len(t, appended, count+1)
+	require.EqualValues(t, 4, maxRun)
+	require.EqualValues(t, count+1, appended[count].ExtentId)
+
+	extIDs := make(map[uint64]bool)
+	for _, ek := range appended {
+		extIDs[ek.ExtentId] = true
+	}
+	require.Len(t, extIDs, count+1)
+	require.NotEmpty(t, s.extents.List())
+	for _, ek := range s.extents.List() {
+		require.True(t, extIDs[ek.ExtentId])
+	}
+}
+
+func TestStreamerFlushRange(t *testing.T) {
+	var appended []uint64
+	appendKey := func(parentInode, inode uint64, key proto.ExtentKey, discard []proto.ExtentKey) error {
+		appended = append(appended, key.ExtentId)
+		return nil
+	}
+	// two disjoint regions, the second one written by two handlers
+	s := newFlushTestStreamer(appendKey, 4, 0, 0)
+	addFlushTestHandler(s, 0, 4096, 1)
+	addFlushTestHandler(s, 1<<20, 4096, 2)
+	addFlushTestHandler(s, 1<<20+8192, 4096, 3)
+
+	// only the handlers writing the range are flushed
+	require.NoError(t, s.flushRangeHandlers(0, 4096, true))
+	require.Equal(t, []uint64{1}, appended)
+	require.Equal(t, 2, s.dirtylist.Len())
+
+	// a range outside the written regions flushes nothing
+	require.NoError(t, s.flushRangeHandlers(4096, 4096, true))
+	require.Equal(t, []uint64{1}, appended)
+
+	// the earlier handlers overlapping a selected one are flushed first
+	s = newFlushTestStreamer(appendKey, 4, 0, 0)
+	addFlushTestHandler(s, 0, 8192, 4)
+	addFlushTestHandler(s, 4096, 8192, 5)
+	addFlushTestHandler(s, 1<<20, 4096, 6)
+	appended = nil
+	require.NoError(t, s.flushRangeHandlers(8192, 4096, true))
+	require.Equal(t, []uint64{4, 5}, appended)
+	require.Equal(t, 1, s.dirtylist.Len())
+
+	// without wait the handlers stay dirty
+	require.NoError(t, s.flushRangeHandlers(1<<20, 4096, false))
+	require.Equal(t, []uint64{4, 5}, appended)
+	require.Equal(t, 1, s.dirtylist.Len())
+}
+
+func BenchmarkStreamerFlush(b *testing.B) {
+	appendKey := func(parentInode, inode uint64, key proto.ExtentKey, discard []proto.ExtentKey) error {
+		// the round trip to the meta node
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+	for _, concurrency := range []int{1, defaultFlushConcurrency} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s := newFlushTestStreamer(appendKey, concurrency, 16, util.ExtentSize)
+				if err := s.flush(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamerPreferredDPs(t *testing.T) {
+	client := &ExtentClient{streamers: make(map[uint64]*Streamer)}
+	require.Equal(t, syscall.EBADF, client.SetPreferredDPs(100, []uint64{1}))
+
+	s := &Streamer{client: client, inode: 100, isOpen: true}
+	client.streamers[s.inode] = s
+	ids := []uint64{3, 5}
+	require.NoError(t, client.SetPreferredDPs(s.inode, ids))
+	ids[0] = 4
+	require.Equal(t, []uint64{3, 5}, s.PreferredDPs())
+
+	s.SetPreferredDPs(nil)
+	require.Empty(t, s.PreferredDPs())
+}
@@ -16,8 +16,10 @@ package wrapper
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
+	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/log"
 )
 
@@ -112,21 +114,136 @@ func (w *Wrapper) refreshDpSelector(partitions []*DataPartition) {
 	}
 
 	_ = dpSelector.Refresh(partitions)
+	w.refreshLocalDpSelector(partitions)
 }
 
 // getDataPartitionForWrite returns an available data partition for write.
 func (w *Wrapper) GetDataPartitionForWrite(exclude map[string]struct{}) (*DataPartition, error) {
 	w.Lock.RLock()
 	dpSelector := w.dpSelector
+	localDpSelector := w.localDpSelector
+	writeAffinity, writeZone := w.writeAffinity, w.writeZone
 	w.Lock.RUnlock()
 
+	if localDpSelector != nil {
+		dp, err := localDpSelector.Select(exclude)
+		if err == nil || writeAffinity == WriteAffinityStrict {
+			return dp, err
+		}
+		log.LogDebugf("GetDataPartitionForWrite: no writable partition in zone(%v), select in all zones", writeZone)
+	} else if writeAffinity == WriteAffinityStrict {
+		return nil, fmt.Errorf("no writable data partition in zone(%v)", writeZone)
+	}
 	return dpSelector.Select(exclude)
 }
 
+// GetPreferredDataPartitionForWrite returns the first partition of preferred which
+// is writable and has no excluded host. If there is none, the partition is selected
+// by GetDataPartitionForWrite.
+func (w *Wrapper) GetPreferredDataPartitionForWrite(preferred []uint64, exclude map[string]struct{}) (*DataPartition, error) {
+	for _, id := range preferred {
+		dp, ok := w.tryGetPartition(id)
+		if ok && dp.Status == proto.ReadWrite && !isExcluded(dp, exclude) {
+			return dp, nil
+		}
+	}
+	dp, err := w.GetDataPartitionForWrite(exclude)
+	if err == nil && len(preferred) > 0 {
+		log.LogWarnf("GetPreferredDataPartitionForWrite: preferred partitions(%v) unavailable, write to dp(%v) instead",
+			preferred, dp.PartitionID)
+	}
+	return dp, err
+}
+
 func (w *Wrapper) RemoveDataPartitionForWrite(partitionID uint64) {
 	w.Lock.RLock()
 	dpSelector := w.dpSelector
+	localDpSelector := w.localDpSelector
 	w.Lock.RUnlock()
 
 	dpSelector.RemoveDP(partitionID)
+	if localDpSelector != nil {
+		localDpSelector.RemoveDP(partitionID)
+	}
+}
+
+// Write affinity modes of SetWriteAffinity.
+const (
+	WriteAffinityNone   = ""
+	WriteAffinityPrefer = "prefer" // write to the partitions of the zone, the other zones once they are full
+	WriteAffinityStrict = "strict" // write to the partitions of the zone only
+)
+
+// SetWriteAffinity makes the client write to the data partitions whose leader is
+// in zone. If zone is empty, the zone of the data nodes on the client host is
+// used, found out from the partitions led by them.
+func (w *Wrapper) SetWriteAffinity(mode, zone string) error {
+	switch mode {
+	case WriteAffinityNone, WriteAffinityPrefer, WriteAffinityStrict:
+	default:
+		return fmt.Errorf("invalid write affinity mode(%v)", mode)
+	}
+	w.Lock.Lock()
+	w.writeAffinity = mode
+	w.writeZone = zone
+	partitions := w.rwPartitions
+	w.Lock.Unlock()
+
+	log.LogInfof("SetWriteAffinity: mode(%v) zone(%v)", mode, zone)
+	w.refreshLocalDpSelector(partitions)
+	return nil
+}
+
+// refreshLocalDpSelector refreshes the selector of the write affinity zone by the
+// rw partitions of the volume.
+func (w *Wrapper) refreshLocalDpSelector(partitions []*DataPartition) {
+	w.Lock.RLock()
+	mode, zone := w.writeAffinity, w.writeZone
+	localDpSelector := w.localDpSelector
+	w.Lock.RUnlock()
+
+	if mode == WriteAffinityNone {
+		w.Lock.Lock()
+		w.localDpSelector = nil
+		w.rwPartitions = partitions
+		w.Lock.Unlock()
+		return
+	}
+	if zone == "" {
+		zone = localZone(partitions)
+	}
+	local := make([]*DataPartition, 0)
+	if zone != "" {
+		for _, dp := range partitions {
+			if dp.ZoneName == zone {
+				local = append(local, dp)
+			}
+		}
+	}
+
+	if len(local) == 0 {
+		localDpSelector = nil
+	} else if localDpSelector == nil {
+		// the default selector prefers the partitions led by the client host as well
+		localDpSelector, _ = newDefaultRandomSelector("")
+	}
+	if localDpSelector != nil {
+		_ = localDpSelector.Refresh(local)
+	}
+	log.LogInfof("refreshLocalDpSelector: %v of %v rw partitions in zone(%v)", len(local), len(partitions), zone)
+
+	w.Lock.Lock()
+	w.localDpSelector = localDpSelector
+	w.rwPartitions = partitions
+	w.Lock.Unlock()
+}
+
+// localZone returns the zone of the partitions whose leader is on the client host.
+func localZone(partitions []*DataPartition) string {
+	for _, dp := range partitions {
+		if len(dp.Hosts) > 0 && dp.ZoneName != "" && strings.Split(dp.Hosts[0], ":")[0] == LocalIP {
+			return dp.ZoneName
+		}
+	}
+	return ""
 }
@@ -0,0 +1,128 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wrapper
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func newZonePartitions(zone string, subnet, count int) []*DataPartition {
+	partitions := make([]*DataPartition, 0, count)
+	for i := 0; i < count; i++ {
+		dp := &DataPartition{DataPartitionResponse: proto.DataPartitionResponse{
+			PartitionID: uint64(subnet*100 + i),
+			Hosts:       []string{fmt.Sprintf("192.168.%d.%d:17310", subnet, i+1)},
+			Status:      proto.ReadWrite,
+			ZoneName:    zone,
+		}}
+		partitions = append(partitions, dp)
+	}
+	return partitions
+}
+
+func TestWriteAffinity(t *testing.T) {
+	w := &Wrapper{}
+	var err error
+	w.dpSelector, err = newDefaultRandomSelector("")
+	require.NoError(t, err)
+
+	local := newZonePartitions("z1", 1, 3)
+	remote := newZonePartitions("z2", 2, 5)
+	w.refreshDpSelector(append(append([]*DataPartition{}, local...), remote...))
+
+	selectZones := func(exclude map[string]struct{}) map[string]int {
+		zones := make(map[string]int)
+		for i := 0; i < 50; i++ {
+			dp, err := w.GetDataPartitionForWrite(exclude)
+			if err != nil {
+				zones[""]++
+				continue
+			}
+			zones[dp.ZoneName]++
+		}
+		return zones
+	}
+	localFull := make(map[string]struct{})
+	for _, dp := range local {
+		localFull[dp.Hosts[0]] = struct{}{}
+	}
+
+	// the partitions of the zone are preferred, the others are used once they are full
+	require.NoError(t, w.SetWriteAffinity(WriteAffinityPrefer, "z1"))
+	require.Equal(t, map[string]int{"z1": 50}, selectZones(nil))
+	require.Equal(t, map[string]int{"z2": 50}, selectZones(localFull))
+
+	// strict affinity never writes to other zones
+	require.NoError(t, w.SetWriteAffinity(WriteAffinityStrict, "z1"))
+	require.Equal(t, map[string]int{"z1": 50}, selectZones(nil))
+	require.Equal(t, map[string]int{"": 50}, selectZones(localFull))
+	require.NoError(t, w.SetWriteAffinity(WriteAffinityStrict, "z3"))
+	require.Equal(t, map[string]int{"": 50}, selectZones(nil))
+
+	// the zone of the client is the zone of the data nodes on its host
+	oldLocalIP := LocalIP
+	defer func() { LocalIP = oldLocalIP }()
+	LocalIP = "192.168.2.3"
+	require.NoError(t, w.SetWriteAffinity(WriteAffinityPrefer, ""))
+	require.Equal(t, map[string]int{"z2": 50}, selectZones(nil))
+
+	// removed partitions are not selected any more
+	for _, dp := range remote {
+		w.RemoveDataPartitionForWrite(dp.PartitionID)
+	}
+	require.Equal(t, map[string]int{"z1": 50}, selectZones(nil))
+
+	require.NoError(t, w.SetWriteAffinity(WriteAffinityNone, ""))
+	require.Nil(t, w.localDpSelector)
+	require.Error(t, w.SetWriteAffinity("nearest", "z1"))
+}
+
+func TestPreferredDataPartitionForWrite(t *testing.T) {
+	w := &Wrapper{partitions: make(map[uint64]*DataPartition)}
+	var err error
+	w.dpSelector, err = newDefaultRandomSelector("")
+	require.NoError(t, err)
+	partitions := newZonePartitions("z1", 1, 4)
+	for _, dp := range partitions {
+		w.partitions[dp.PartitionID] = dp
+	}
+	w.refreshDpSelector(partitions)
+
+	// the new extents go to the pinned partition while it is healthy
+	for i := 0; i < 20; i++ {
+		dp, err := w.GetPreferredDataPartitionForWrite([]uint64{102, 103}, nil)
+		require.NoError(t, err)
+		require.EqualValues(t, 102, dp.PartitionID)
+	}
+
+	// the next pinned one is used once a host of it is excluded
+	exclude := map[string]struct{}{partitions[2].Hosts[0]: {}}
+	dp, err := w.GetPreferredDataPartitionForWrite([]uint64{102, 103}, exclude)
+	require.NoError(t, err)
+	require.EqualValues(t, 103, dp.PartitionID)
+
+	// and any other writable partition once none of them is usable
+	partitions[3].Status = proto.ReadOnly
+	for i := 0; i < 20; i++ {
+		dp, err = w.GetPreferredDataPartitionForWrite([]uint64{102, 103, 999}, exclude)
+		require.NoError(t, err)
+		require.NotEqual(t, uint64(102), dp.PartitionID)
+		require.NotEqual(t, uint64(999), dp.PartitionID)
+	}
+}
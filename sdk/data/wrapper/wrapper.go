@@ -57,6 +57,8 @@ type Wrapper struct {
 	volName               string
 	volType               int
 	EnablePosixAcl        bool
+	DisableTinyExtent     bool
+	CrcAlg                uint8
 	masters               []string
 	partitions            map[uint64]*DataPartition
 	followerRead          bool
@@ -71,6 +73,12 @@ type Wrapper struct {
 
 	dpSelector DataPartitionSelector
 
+	// write affinity, see SetWriteAffinity
+	writeAffinity   string
+	writeZone       string
+	localDpSelector DataPartitionSelector // selects in the rw partitions of writeZone
+	rwPartitions    []*DataPartition      // the latest rw partitions, to refresh localDpSelector
+
 	HostsStatus map[string]bool
 	Uids        map[uint32]*proto.UidSimpleInfo
 	UidLock     sync.RWMutex
@@ -212,6 +220,8 @@ func (w *Wrapper) GetSimpleVolView() (err error) {
 	w.dpSelectorParm = view.DpSelectorParm
 	w.volType = view.VolType
 	w.EnablePosixAcl = view.EnablePosixAcl
+	w.DisableTinyExtent = view.DisableTinyExtent
+	w.CrcAlg = view.CrcAlg
 	w.UpdateUidsView(view)
 
 	log.LogDebugf("GetSimpleVolView: get volume simple info: ID(%v) name(%v) owner(%v) status(%v) capacity(%v) "+
@@ -330,6 +340,17 @@ func (w *Wrapper) updateSimpleVolView(client SimpleClientInfo) (err error) {
 		w.followerRead = view.FollowerRead
 	}
 
+	if w.DisableTinyExtent != view.DisableTinyExtent {
+		log.LogDebugf("UpdateSimpleVolView: update disableTinyExtent from old(%v) to new(%v)",
+			w.DisableTinyExtent, view.DisableTinyExtent)
+		w.DisableTinyExtent = view.DisableTinyExtent
+	}
+
+	if w.CrcAlg != view.CrcAlg {
+		log.LogDebugf("UpdateSimpleVolView: update crcAlg from old(%v) to new(%v)", w.CrcAlg, view.CrcAlg)
+		w.CrcAlg = view.CrcAlg
+	}
+
 	if w.dpSelectorName != view.DpSelectorName || w.dpSelectorParm != view.DpSelectorParm {
 		log.LogDebugf("UpdateSimpleVolView: update dpSelector from old(%v %v) to new(%v %v)",
 			w.dpSelectorName, w.dpSelectorParm, view.DpSelectorName, view.DpSelectorParm)
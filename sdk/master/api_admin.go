@@ -184,6 +184,48 @@ func (api *AdminAPI) GetDataPartition(volName string, partitionID uint64) (parti
 	return
 }
 
+// ListDataPartitions returns up to limit data partitions of the vol from
+// offset, a zero status lists the partitions of any status.
+func (api *AdminAPI) ListDataPartitions(volName string, offset, limit int, status int8) (page *proto.DataPartitionsPage, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminListDataPartitions)
+	request.addParam("name", volName)
+	request.addParam("offset", strconv.Itoa(offset))
+	request.addParam("limit", strconv.Itoa(limit))
+	if status != 0 {
+		request.addParam("status", strconv.Itoa(int(status)))
+	}
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	page = &proto.DataPartitionsPage{}
+	if err = json.Unmarshal(buf, page); err != nil {
+		return
+	}
+	return
+}
+
+// ListMetaPartitions returns up to limit meta partitions of the vol from
+// offset, a zero status lists the partitions of any status.
+func (api *AdminAPI) ListMetaPartitions(volName string, offset, limit int, status int8) (page *proto.MetaPartitionsPage, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminListMetaPartitions)
+	request.addParam("name", volName)
+	request.addParam("offset", strconv.Itoa(offset))
+	request.addParam("limit", strconv.Itoa(limit))
+	if status != 0 {
+		request.addParam("status", strconv.Itoa(int(status)))
+	}
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	page = &proto.MetaPartitionsPage{}
+	if err = json.Unmarshal(buf, page); err != nil {
+		return
+	}
+	return
+}
+
 func (api *AdminAPI) GetDataPartitionById(partitionID uint64) (partition *proto.DataPartitionInfo, err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AdminGetDataPartition)
 	request.addParam("id", strconv.Itoa(int(partitionID)))
@@ -428,6 +470,29 @@ func (api *AdminAPI) VolExpand(volName string, capacity uint64, authKey, clientI
 	return
 }
 
+func (api *AdminAPI) CloneVolConfig(volName, newName, owner, clientIDKey string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminCloneVolConfig)
+	request.addParam("name", volName)
+	request.addParam("newName", newName)
+	request.addParam("owner", owner)
+	request.addParam("clientIDKey", clientIDKey)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) RenameVol(volName, newName, authKey string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminRenameVol)
+	request.addParam("name", volName)
+	request.addParam("newName", newName)
+	request.addParam("authKey", authKey)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
 func (api *AdminAPI) CreateVolName(volName, owner string, capacity uint64, deleteLockTime int64, crossZone, normalZonesFirst bool, business string,
 	mpCount, replicaNum, size, volType int, followerRead bool, zoneName, cacheRuleKey string, ebsBlkSize,
 	cacheCapacity, cacheAction, cacheThreshold, cacheTTL, cacheHighWater, cacheLowWater, cacheLRUInterval int,
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	syslog "log"
 	"math"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -53,6 +54,12 @@ const (
 	ForceUpdateRWMP        = "ForceUpdateRWMP"
 )
 
+// The flags of XAttrSetFlags_ll, as the XATTR_* flags of setxattr(2).
+const (
+	XAttrCreate  = 0x1
+	XAttrReplace = 0x2
+)
+
 func mapHaveSameKeys(m1, m2 map[uint32]*proto.MetaQuotaInfo) bool {
 	if len(m1) != len(m2) {
 		return false
@@ -111,6 +118,12 @@ func (mw *MetaWrapper) Statfs() (total, used, inodeCount uint64) {
 	return
 }
 
+// RefreshVolStat fetches the space of the volume from the master now, instead
+// of waiting for the periodic refresh.
+func (mw *MetaWrapper) RefreshVolStat() error {
+	return mw.updateVolStatInfo()
+}
+
 func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid uint32, target []byte) (*proto.InodeInfo, error) {
 	// if mw.EnableTransaction {
 	txMask := proto.TxOpMaskOff
@@ -782,6 +795,9 @@ func (mw *MetaWrapper) Delete_ll_EX(parentID uint64, name string, isDir bool, ve
 			if info == nil || info.Nlink > 2 {
 				return nil, syscall.ENOTEMPTY
 			}
+			if info.Flags&(proto.InodeFlagImmutable|proto.InodeFlagAppendOnly) != 0 {
+				return nil, syscall.EPERM
+			}
 		}
 		if mw.EnableQuota {
 			quotaInfos, err := mw.GetInodeQuota_ll(inode)
@@ -804,7 +820,8 @@ func (mw *MetaWrapper) Delete_ll_EX(parentID uint64, name string, isDir bool, ve
 			}
 		}
 	} else {
-		if mw.volDeleteLockTime > 0 {
+		// the flags of the inode are checked before its dentry is deleted
+		if verSeq == 0 || mw.volDeleteLockTime > 0 {
 			status, inode, _, err = mw.lookup(parentMP, parentID, name, verSeq)
 			if err != nil || status != statusOK {
 				return nil, statusToErrno(status)
@@ -818,6 +835,11 @@ func (mw *MetaWrapper) Delete_ll_EX(parentID uint64, name string, isDir bool, ve
 			if err != nil || status != statusOK {
 				return nil, statusToErrno(status)
 			}
+		}
+		if verSeq == 0 && info.Flags&(proto.InodeFlagImmutable|proto.InodeFlagAppendOnly) != 0 {
+			return nil, syscall.EPERM
+		}
+		if mw.volDeleteLockTime > 0 {
 			inodeCreateTime = info.CreateTime.Unix()
 			if ok, err := mw.canDeleteInode(mp, info, inode); !ok {
 				return nil, err
@@ -845,6 +867,12 @@ func (mw *MetaWrapper) Delete_ll_EX(parentID uint64, name string, isDir bool, ve
 	}
 	log.LogDebugf("action[Delete_ll] parentID %v name %v verSeq %v", parentID, name, verSeq)
 	status, info, err = mw.iunlink(mp, inode, verSeq, denVer)
+	if status == statusNotPerm {
+		// the flags were set after they were checked above
+		log.LogErrorf("delete_ll: inode is immutable or append-only, its dentry is deleted, parentID(%v) name(%v) ino(%v)",
+			parentID, name, inode)
+		return nil, syscall.EPERM
+	}
 	if err != nil || status != statusOK {
 		log.LogDebugf("action[Delete_ll] parentID %v inode %v name %v verSeq %v err %v", parentID, inode, name, verSeq, err)
 		return nil, nil
@@ -863,6 +891,27 @@ func (mw *MetaWrapper) Delete_ll_EX(parentID uint64, name string, isDir bool, ve
 	return info, nil
 }
 
+// checkDentryNotProtected returns EPERM if the inode of the dentry is immutable or append-only.
+func (mw *MetaWrapper) checkDentryNotProtected(parentMP *MetaPartition, parentID uint64, name string) error {
+	status, inode, _, err := mw.lookup(parentMP, parentID, name, mw.VerReadSeq)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	return mw.checkNotProtected(inode)
+}
+
+// checkNotProtected returns EPERM if the inode is immutable or append-only.
+func (mw *MetaWrapper) checkNotProtected(inode uint64) error {
+	info, err := mw.InodeGet_ll(inode)
+	if err != nil {
+		return err
+	}
+	if info.Flags&(proto.InodeFlagImmutable|proto.InodeFlagAppendOnly) != 0 {
+		return syscall.EPERM
+	}
+	return nil
+}
+
 func (mw *MetaWrapper) deletewithcond_ll(parentID, cond uint64, name string, isDir bool) (*proto.InodeInfo, error) {
 	var (
 		status int
@@ -966,7 +1015,111 @@ func (mw *MetaWrapper) Rename_ll(srcParentID uint64, srcName string, dstParentID
 	}
 }
 
+// RenameWithAttr_ll renames the dentry and changes the mode and owner of its inode
+// in one transaction, so the file shows up at the destination with its final
+// attributes, or the rename fails as a whole. Only AttrMode, AttrUid and AttrGid
+// of valid are applied, and mode is the full mode of the inode.
+func (mw *MetaWrapper) RenameWithAttr_ll(srcParentID uint64, srcName string, dstParentID uint64, dstName string,
+	overwritten bool, valid, mode, uid, gid uint32) (err error) {
+	attr := &proto.SetAttrRequest{
+		Valid: valid & (proto.AttrMode | proto.AttrUid | proto.AttrGid),
+		Mode:  mode,
+		Uid:   uid,
+		Gid:   gid,
+	}
+	return mw.txRename(srcParentID, srcName, dstParentID, dstName, overwritten, attr)
+}
+
 func (mw *MetaWrapper) txRename_ll(srcParentID uint64, srcName string, dstParentID uint64, dstName string, overwritten bool) (err error) {
+	return mw.txRename(srcParentID, srcName, dstParentID, dstName, overwritten, nil)
+}
+
+// txRename renames the dentry in a transaction, the attributes of the inode are
+// changed in the same transaction if attr is not nil.
+func (mw *MetaWrapper) txRename(srcParentID uint64, srcName string, dstParentID uint64, dstName string,
+	overwritten bool, attr *proto.SetAttrRequest) (err error) {
+	var tx *Transaction
+	defer func() {
+		if tx != nil {
+			err = tx.OnDone(err, mw)
+		}
+	}()
+
+	dstParentMP := mw.getPartitionByInode(dstParentID)
+	if dstParentMP == nil {
+		return syscall.ENOENT
+	}
+	pair := RenamePair{SrcParentID: srcParentID, SrcName: srcName, DstParentID: dstParentID, DstName: dstName}
+	tx = NewTransaction(mw.TxTimeout, proto.TxTypeRename)
+	funcs, onPrepared, err := mw.txRenameOps(tx, pair, overwritten, attr)
+	if err != nil {
+		return err
+	}
+	return mw.txRenameRun(tx, dstParentMP, funcs, []func(){onPrepared})
+}
+
+// RenamePair is a rename of RenameBatch_ll.
+type RenamePair struct {
+	SrcParentID uint64
+	SrcName     string
+	DstParentID uint64
+	DstName     string
+}
+
+// RenameBatch_ll applies the renames in one transaction, so either all of them
+// are done or none is. A dentry can be used by one pair only, e.g. a file can't
+// be renamed to the old name of another file of the batch.
+func (mw *MetaWrapper) RenameBatch_ll(pairs []RenamePair, overwritten bool) (err error) {
+	if len(pairs) == 0 {
+		return nil
+	}
+	dentries := make(map[string]struct{}, 2*len(pairs))
+	for _, pair := range pairs {
+		for _, key := range []string{
+			fmt.Sprintf("%d/%s", pair.SrcParentID, pair.SrcName),
+			fmt.Sprintf("%d/%s", pair.DstParentID, pair.DstName),
+		} {
+			if _, ok := dentries[key]; ok {
+				return syscall.EINVAL
+			}
+			dentries[key] = struct{}{}
+		}
+	}
+
+	var tx *Transaction
+	defer func() {
+		if tx != nil {
+			err = tx.OnDone(err, mw)
+		}
+	}()
+
+	tmMP := mw.getPartitionByInode(pairs[0].DstParentID)
+	if tmMP == nil {
+		return syscall.ENOENT
+	}
+	tx = NewTransaction(mw.TxTimeout, proto.TxTypeRename)
+	funcs := make([]func() (int, error), 0, 3*len(pairs))
+	jobs := make([]func(), 0, len(pairs))
+	for _, pair := range pairs {
+		pairFuncs, onPrepared, err := mw.txRenameOps(tx, pair, overwritten, nil)
+		if err != nil {
+			log.LogWarnf("RenameBatch_ll: pair(%v) err(%v)", pair, err)
+			return err
+		}
+		funcs = append(funcs, pairFuncs...)
+		jobs = append(jobs, onPrepared)
+	}
+	return mw.txRenameRun(tx, tmMP, funcs, jobs)
+}
+
+// RenameExchange_ll swaps the inodes of the two dentries in one transaction.
+// Both have to exist and be of the same file type, so the dentry types and the
+// subdir counts of the parents stay right.
+func (mw *MetaWrapper) RenameExchange_ll(srcParentID uint64, srcName string, dstParentID uint64, dstName string) (err error) {
+	if srcParentID == dstParentID && srcName == dstName {
+		return nil
+	}
+
 	var tx *Transaction
 	defer func() {
 		if tx != nil {
@@ -982,35 +1135,108 @@ func (mw *MetaWrapper) txRename_ll(srcParentID uint64, srcName string, dstParent
 	if dstParentMP == nil {
 		return syscall.ENOENT
 	}
-	// look up for the src ino
 	status, srcInode, srcMode, err := mw.lookup(srcParentMP, srcParentID, srcName, mw.LastVerSeq)
 	if err != nil || status != statusOK {
 		return statusToErrno(status)
 	}
+	status, dstInode, dstMode, err := mw.lookup(dstParentMP, dstParentID, dstName, mw.LastVerSeq)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	if os.FileMode(srcMode).Type() != os.FileMode(dstMode).Type() {
+		return syscall.EINVAL
+	}
+	// a dir can't be moved under itself
+	if srcInode == dstParentID || dstInode == srcParentID {
+		return syscall.EINVAL
+	}
 
-	tx, err = NewRenameTransaction(srcParentMP, srcParentID, srcName, dstParentMP, dstParentID, dstName, mw.TxTimeout)
-	if err != nil {
+	tx = NewTransaction(mw.TxTimeout, proto.TxTypeRename)
+	if err = RenameTxAddDentries(tx, srcParentMP, srcParentID, srcName, dstParentMP, dstParentID, dstName); err != nil {
 		return syscall.EAGAIN
 	}
+	funcs := []func() (int, error){
+		func() (int, error) {
+			newSt, _, newErr := mw.txDupdate(tx, srcParentMP, srcParentID, srcName, dstInode, srcInode)
+			return newSt, newErr
+		},
+		func() (int, error) {
+			newSt, _, newErr := mw.txDupdate(tx, dstParentMP, dstParentID, dstName, srcInode, dstInode)
+			return newSt, newErr
+		},
+	}
+	// the files swap their parents, so do their sizes in the summaries
+	onPrepared := func() {
+		if !mw.EnableSummary || srcParentID == dstParentID || !proto.IsRegular(srcMode) {
+			return
+		}
+		srcInfo, _ := mw.InodeGet_ll(srcInode)
+		dstInfo, _ := mw.InodeGet_ll(dstInode)
+		if srcInfo == nil || dstInfo == nil {
+			return
+		}
+		sizeInc := int64(dstInfo.Size) - int64(srcInfo.Size)
+		tx.SetOnCommit(func() {
+			mw.UpdateSummary_ll(srcParentID, 0, 0, sizeInc)
+			mw.UpdateSummary_ll(dstParentID, 0, 0, -sizeInc)
+		})
+	}
+	log.LogDebugf("RenameExchange_ll: tx(%v) src(%v/%v ino:%v) dst(%v/%v ino:%v)",
+		tx.txInfo, srcParentID, srcName, srcInode, dstParentID, dstName, dstInode)
+	return mw.txRenameRun(tx, dstParentMP, funcs, []func(){onPrepared})
+}
+
+// txRenameOps adds the dentries and inodes of the rename to tx. It returns the
+// operations to prepare the rename, and a function to call once they are done.
+func (mw *MetaWrapper) txRenameOps(tx *Transaction, pair RenamePair, overwritten bool,
+	attr *proto.SetAttrRequest) (funcs []func() (int, error), onPrepared func(), err error) {
+	srcParentID, srcName, dstParentID, dstName := pair.SrcParentID, pair.SrcName, pair.DstParentID, pair.DstName
+
+	srcParentMP := mw.getPartitionByInode(srcParentID)
+	if srcParentMP == nil {
+		return nil, nil, syscall.ENOENT
+	}
+	dstParentMP := mw.getPartitionByInode(dstParentID)
+	if dstParentMP == nil {
+		return nil, nil, syscall.ENOENT
+	}
+	// look up for the src ino
+	status, srcInode, srcMode, err := mw.lookup(srcParentMP, srcParentID, srcName, mw.LastVerSeq)
+	if err != nil || status != statusOK {
+		return nil, nil, statusToErrno(status)
+	}
+	// the metanode refuses to move the dentry of a protected inode it holds,
+	// the inodes of the other partitions are checked here
+	if proto.IsRegular(srcMode) {
+		if srcInodeMP := mw.getPartitionByInode(srcInode); srcInodeMP == nil || srcInodeMP.PartitionID != srcParentMP.PartitionID {
+			if err = mw.checkNotProtected(srcInode); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
 
-	funcs := make([]func() (int, error), 0)
+	if err = RenameTxAddDentries(tx, srcParentMP, srcParentID, srcName, dstParentMP, dstParentID, dstName); err != nil {
+		return nil, nil, syscall.EAGAIN
+	}
+
+	funcs = make([]func() (int, error), 0)
 
 	status, dstInode, dstMode, err := mw.lookup(dstParentMP, dstParentID, dstName, mw.LastVerSeq)
 	if err == nil && status == statusOK {
 
 		// Note that only regular files are allowed to be overwritten.
 		if !proto.IsRegular(dstMode) || !overwritten || !proto.IsRegular(srcMode) {
-			return syscall.EEXIST
+			return nil, nil, syscall.EEXIST
 		}
 
 		oldInodeMP := mw.getPartitionByInode(dstInode)
 		if oldInodeMP == nil {
-			return syscall.EAGAIN
+			return nil, nil, syscall.EAGAIN
 		}
 
 		err = RenameTxReplaceInode(tx, oldInodeMP, dstInode)
 		if err != nil {
-			return syscall.EAGAIN
+			return nil, nil, syscall.EAGAIN
 		}
 
 		funcs = append(funcs, func() (int, error) {
@@ -1043,7 +1269,7 @@ func (mw *MetaWrapper) txRename_ll(srcParentID uint64, srcName string, dstParent
 		})
 
 	} else {
-		return statusToErrno(status)
+		return nil, nil, statusToErrno(status)
 	}
 
 	//var inode uint64
@@ -1054,39 +1280,30 @@ func (mw *MetaWrapper) txRename_ll(srcParentID uint64, srcName string, dstParent
 		return newSt, newErr
 	})
 
+	if attr != nil && attr.Valid != 0 {
+		srcInodeMP := mw.getPartitionByInode(srcInode)
+		if srcInodeMP == nil {
+			return nil, nil, syscall.EAGAIN
+		}
+		if err = RenameTxReplaceInode(tx, srcInodeMP, srcInode); err != nil {
+			return nil, nil, syscall.EAGAIN
+		}
+		funcs = append(funcs, func() (int, error) {
+			return mw.txSetattr(tx, srcInodeMP, srcInode, attr.Valid, attr.Mode, attr.Uid, attr.Gid)
+		})
+	}
+
 	if log.EnableDebug() {
 		log.LogDebugf("txRename_ll: tx(%v), pid:%v, name:%v, old(ino:%v) is replaced by src(new ino:%v)",
 			tx.txInfo, dstParentID, dstName, dstInode, srcInode)
 	}
 
-	// 1. create transaction
-	status, err = mw.txCreateTX(tx, dstParentMP)
-	if status != statusOK || err != nil {
-		return statusErrToErrno(status, err)
-	}
-
-	// 2. prepare transaction
-	var preErr error
-	wg := sync.WaitGroup{}
-	for _, fc := range funcs {
-		wg.Add(1)
-		go func(f func() (int, error)) {
-			defer wg.Done()
-			tStatus, tErr := f()
-			if tStatus != statusOK || tErr != nil {
-				preErr = statusErrToErrno(tStatus, tErr)
-			}
-		}(fc)
-	}
-	wg.Wait()
-
-	if preErr != nil {
-		return preErr
-	}
-
 	// update summary
-	var job func()
-	if mw.EnableSummary {
+	onPrepared = func() {
+		if !mw.EnableSummary {
+			return
+		}
+		var job func()
 		var srcInodeInfo *proto.InodeInfo
 		var dstInodeInfo *proto.InodeInfo
 
@@ -1118,6 +1335,40 @@ func (mw *MetaWrapper) txRename_ll(srcParentID uint64, srcName string, dstParent
 			tx.SetOnCommit(job)
 		}
 	}
+	return funcs, onPrepared, nil
+}
+
+// txRenameRun creates the transaction of the renames on tmMP and prepares it. The
+// caller commits or rolls back the transaction according to the returned error.
+func (mw *MetaWrapper) txRenameRun(tx *Transaction, tmMP *MetaPartition, funcs []func() (int, error), onPrepared []func()) (err error) {
+	// 1. create transaction
+	status, err := mw.txCreateTX(tx, tmMP)
+	if status != statusOK || err != nil {
+		return statusErrToErrno(status, err)
+	}
+
+	// 2. prepare transaction
+	var preErr error
+	wg := sync.WaitGroup{}
+	for _, fc := range funcs {
+		wg.Add(1)
+		go func(f func() (int, error)) {
+			defer wg.Done()
+			tStatus, tErr := f()
+			if tStatus != statusOK || tErr != nil {
+				preErr = statusErrToErrno(tStatus, tErr)
+			}
+		}(fc)
+	}
+	wg.Wait()
+
+	if preErr != nil {
+		return preErr
+	}
+
+	for _, job := range onPrepared {
+		job()
+	}
 
 	// TODO
 	// job = func() {
@@ -1211,6 +1462,11 @@ func (mw *MetaWrapper) rename_ll(srcParentID uint64, srcName string, dstParentID
 		if !overwritten {
 			return syscall.EEXIST
 		}
+		// the unlink of a protected inode is refused once its dentry is replaced
+		if err = mw.checkDentryNotProtected(dstParentMP, dstParentID, dstName); err != nil {
+			mw.iunlink(srcMP, inode, lastVerSeq, 0)
+			return err
+		}
 
 		status, oldInode, err = mw.dupdate(dstParentMP, dstParentID, dstName, inode)
 		if err != nil {
@@ -1342,7 +1598,7 @@ func (mw *MetaWrapper) ReadDirLimitByVer(parentID uint64, from string, limit uin
 	if is2nd {
 		opt |= uint8(proto.FlagsVerDelDir)
 	}
-	status, children, err := mw.readDirLimit(parentMP, parentID, from, limit, verSeq, opt)
+	status, children, err := mw.readDirLimit(parentMP, parentID, from, limit, verSeq, opt, proto.ReadDirFilterAll)
 	if err != nil || status != statusOK {
 		return nil, statusToErrno(status)
 	}
@@ -1360,13 +1616,67 @@ func (mw *MetaWrapper) ReadDirLimit_ll(parentID uint64, from string, limit uint6
 		return nil, syscall.ENOENT
 	}
 
-	status, children, err := mw.readDirLimit(parentMP, parentID, from, limit, mw.VerReadSeq, 0)
+	status, children, err := mw.readDirLimit(parentMP, parentID, from, limit, mw.VerReadSeq, 0, proto.ReadDirFilterAll)
 	if err != nil || status != statusOK {
 		return nil, statusToErrno(status)
 	}
 	return children, nil
 }
 
+// ReadDirLimitByType_ll is ReadDirLimit_ll that only returns the entries matching
+// filter (proto.ReadDirFilter*). The filter is applied by the metanode, so a full
+// page still holds limit entries and the name of the last one is the next marker.
+func (mw *MetaWrapper) ReadDirLimitByType_ll(parentID uint64, from string, limit uint64, filter uint8) ([]proto.Dentry, error) {
+	log.LogDebugf("action[ReadDirLimitByType_ll] parentID %v from %v limit %v filter %v", parentID, from, limit, filter)
+	parentMP := mw.getPartitionByInode(parentID)
+	if parentMP == nil {
+		return nil, syscall.ENOENT
+	}
+
+	status, children, err := mw.readDirLimit(parentMP, parentID, from, limit, mw.VerReadSeq, 0, filter)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return children, nil
+}
+
+// ReadDirPlus reads limit dentries of parentID from the name from, like
+// ReadDirLimit_ll, together with the attributes of their inodes. The metanode of
+// the parent returns the attributes of the inodes it owns, the others are got in a
+// batch from their partitions. Info is nil for the inodes removed meanwhile.
+func (mw *MetaWrapper) ReadDirPlus(parentID uint64, from string, limit uint64) ([]proto.DentryPlus, error) {
+	log.LogDebugf("action[ReadDirPlus] parentID %v from %v limit %v", parentID, from, limit)
+	parentMP := mw.getPartitionByInode(parentID)
+	if parentMP == nil {
+		return nil, syscall.ENOENT
+	}
+
+	status, children, err := mw.readDirPlus(parentMP, parentID, from, limit, mw.VerReadSeq)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+
+	missing := make([]uint64, 0)
+	for _, child := range children {
+		if child.Info == nil {
+			missing = append(missing, child.Dentry.Inode)
+		}
+	}
+	if len(missing) == 0 {
+		return children, nil
+	}
+	infos := make(map[uint64]*proto.InodeInfo, len(missing))
+	for _, info := range mw.BatchInodeGet(missing) {
+		infos[info.Inode] = info
+	}
+	for i := range children {
+		if children[i].Info == nil {
+			children[i].Info = infos[children[i].Dentry.Inode]
+		}
+	}
+	return children, nil
+}
+
 func (mw *MetaWrapper) DentryCreate_ll(parentID uint64, name string, inode uint64, mode uint32) error {
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
@@ -1728,6 +2038,42 @@ func (mw *MetaWrapper) Setattr(inode uint64, valid, mode, uid, gid uint32, atime
 	return nil
 }
 
+// SetInodeFlags_ll replaces the immutable and append-only flags (proto.InodeFlag*) of a regular file.
+func (mw *MetaWrapper) SetInodeFlags_ll(inode uint64, flags uint32) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("SetInodeFlags_ll: No such partition, ino(%v)", inode)
+		return syscall.EINVAL
+	}
+
+	status, err := mw.setInodeFlags(mp, inode, flags)
+	if err != nil || status != statusOK {
+		log.LogErrorf("SetInodeFlags_ll: ino(%v) flags(%v) err(%v) status(%v)", inode, flags, err, status)
+		return statusToErrno(status)
+	}
+	return nil
+}
+
+// CloneInode creates a regular file sharing the extents of inode without copying
+// its data, the new inode has no dentry yet. Neither file overwrites the shared
+// extents in place afterwards, so writing one of them leaves the other unchanged.
+// The open streams of inode hold extents loaded before the clone, clone it with
+// ExtentClient.CloneInode to reload them.
+func (mw *MetaWrapper) CloneInode(inode uint64, uid, gid uint32) (*proto.InodeInfo, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("CloneInode: No such partition, ino(%v)", inode)
+		return nil, syscall.EINVAL
+	}
+
+	status, info, err := mw.cloneInode(mp, inode, uid, gid)
+	if err != nil || status != statusOK {
+		log.LogErrorf("CloneInode: ino(%v) err(%v) status(%v)", inode, err, status)
+		return nil, statusToErrno(status)
+	}
+	return info, nil
+}
+
 func (mw *MetaWrapper) InodeCreate_ll(parentID uint64, mode, uid, gid uint32, target []byte, quotaIds []uint64) (*proto.InodeInfo, error) {
 	var (
 		status       int
@@ -2055,6 +2401,46 @@ func (mw *MetaWrapper) XAttrSet_ll(inode uint64, name, value []byte) error {
 	return nil
 }
 
+// XAttrSetFlags_ll is XAttrSet_ll honoring the XAttrCreate and XAttrReplace flags,
+// it fails with EEXIST if XAttrCreate is set and the xattr exists, with ENODATA if
+// XAttrReplace is set and the xattr is missing. The check is done before the set,
+// a racing set or remove of the same xattr by another client may go unnoticed.
+func (mw *MetaWrapper) XAttrSetFlags_ll(inode uint64, name, value []byte, flags int) error {
+	if flags&^(XAttrCreate|XAttrReplace) != 0 || flags == XAttrCreate|XAttrReplace {
+		return syscall.EINVAL
+	}
+	if flags != 0 {
+		keys, err := mw.XAttrsList_ll(inode)
+		if err != nil {
+			return err
+		}
+		if err = checkXAttrFlags(keys, string(name), flags); err != nil {
+			log.LogDebugf("XAttrSetFlags_ll: volume(%v) inode(%v) name(%v) flags(%v) err(%v)",
+				mw.volname, inode, string(name), flags, err)
+			return err
+		}
+	}
+	return mw.XAttrSet_ll(inode, name, value)
+}
+
+// checkXAttrFlags checks the flags of setting the xattr name against the existing keys.
+func checkXAttrFlags(keys []string, name string, flags int) error {
+	exist := false
+	for _, key := range keys {
+		if key == name {
+			exist = true
+			break
+		}
+	}
+	if exist && flags&XAttrCreate != 0 {
+		return syscall.EEXIST
+	}
+	if !exist && flags&XAttrReplace != 0 {
+		return syscall.ENODATA
+	}
+	return nil
+}
+
 func (mw *MetaWrapper) BatchSetXAttr_ll(inode uint64, attrs map[string]string) error {
 	var err error
 	mp := mw.getPartitionByInode(inode)
@@ -2151,6 +2537,48 @@ func (mw *MetaWrapper) XAttrsList_ll(inode uint64) ([]string, error) {
 	return keys, nil
 }
 
+// DentryAttrSet_ll sets an attribute of the dentry, unlike xattrs it is not shared by hardlinks.
+func (mw *MetaWrapper) DentryAttrSet_ll(parentID uint64, name, key, value string) error {
+	mp := mw.getPartitionByInode(parentID)
+	if mp == nil {
+		log.LogErrorf("DentryAttrSet_ll: no such partition, parentID(%v)", parentID)
+		return syscall.ENOENT
+	}
+	status, err := mw.setDentryAttr(mp, parentID, name, key, value)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	log.LogDebugf("DentryAttrSet_ll: volume(%v) parentID(%v) name(%v) key(%v) value(%v)",
+		mw.volname, parentID, name, key, value)
+	return nil
+}
+
+func (mw *MetaWrapper) DentryAttrGet_ll(parentID uint64, name, key string) (string, error) {
+	mp := mw.getPartitionByInode(parentID)
+	if mp == nil {
+		log.LogErrorf("DentryAttrGet_ll: no such partition, parentID(%v)", parentID)
+		return "", syscall.ENOENT
+	}
+	value, status, err := mw.getDentryAttr(mp, parentID, name, key)
+	if err != nil || status != statusOK {
+		return "", statusToErrno(status)
+	}
+	return value, nil
+}
+
+func (mw *MetaWrapper) DentryAttrsList_ll(parentID uint64, name string) ([]string, error) {
+	mp := mw.getPartitionByInode(parentID)
+	if mp == nil {
+		log.LogErrorf("DentryAttrsList_ll: no such partition, parentID(%v)", parentID)
+		return nil, syscall.ENOENT
+	}
+	keys, status, err := mw.listDentryAttr(mp, parentID, name)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return keys, nil
+}
+
 func (mw *MetaWrapper) UpdateSummary_ll(parentIno uint64, filesInc int64, dirsInc int64, bytesInc int64) {
 	if filesInc == 0 && dirsInc == 0 && bytesInc == 0 {
 		return
@@ -2475,10 +2903,60 @@ func (mw *MetaWrapper) BatchSetInodeQuota_ll(inodes []uint64, quotaId uint32, Is
 	return
 }
 
+// SetSubtreeQuota_ll associates the quota with every inode below the root directory, the root
+// itself excluded. Each meta partition walks the part of the subtree it holds in chunks of limit
+// dentries and hands back the entries owned by other partitions, which are continued there.
+func (mw *MetaWrapper) SetSubtreeQuota_ll(rootIno uint64, quotaId uint32, limit uint32) (err error) {
+	pending := []uint64{rootIno}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+		mp := mw.getPartitionByInode(dir)
+		if mp == nil {
+			log.LogErrorf("SetSubtreeQuota_ll: no such partition, ino(%v)", dir)
+			return syscall.ENOENT
+		}
+		dirs, marker := []uint64{dir}, ""
+		for len(dirs) > 0 {
+			var resp *proto.SetSubtreeQuotaResponse
+			if resp, err = mw.setSubtreeQuota(mp, dirs, marker, quotaId, limit); err != nil {
+				log.LogErrorf("SetSubtreeQuota_ll: quota [%v] dir [%v] err [%v]", quotaId, dir, err)
+				return
+			}
+			if len(resp.RemoteInodes) > 0 {
+				if _, err = mw.BatchSetInodeQuota_ll(resp.RemoteInodes, quotaId, false); err != nil {
+					return
+				}
+			}
+			pending = append(pending, resp.RemoteDirs...)
+			dirs, marker = resp.Dirs, resp.Marker
+		}
+	}
+	log.LogInfof("SetSubtreeQuota_ll: root [%v] quota [%v] success.", rootIno, quotaId)
+	return
+}
+
 func (mw *MetaWrapper) GetPartitionByInodeId_ll(inodeId uint64) (mp *MetaPartition) {
 	return mw.getPartitionByInode(inodeId)
 }
 
+// GetChangeEvents_ll polls the change events of the meta partition pid after fromSeq,
+// concerning the given inodes or the dentries in the given parents. Pass a zero
+// fromSeq to get the sequence to start watching from.
+func (mw *MetaWrapper) GetChangeEvents_ll(pid uint64, fromSeq uint64, inodes, parents []uint64) (*proto.GetChangeEventsResponse, error) {
+	mp := mw.getPartitionByID(pid)
+	if mp == nil {
+		log.LogErrorf("GetChangeEvents_ll: No such partition, pid(%v)", pid)
+		return nil, syscall.EINVAL
+	}
+	resp, status, err := mw.getChangeEvents(mp, fromSeq, inodes, parents)
+	if err != nil || status != statusOK {
+		log.LogErrorf("GetChangeEvents_ll: pid(%v) err(%v) status(%v)", pid, err, status)
+		return nil, statusToErrno(status)
+	}
+	return resp, nil
+}
+
 func (mw *MetaWrapper) BatchDeleteInodeQuota_ll(inodes []uint64, quotaId uint32) (ret map[uint64]uint8, err error) {
 	batchInodeMap := make(map[uint64][]uint64)
 	ret = make(map[uint64]uint8, 0)
@@ -0,0 +1,147 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	// DefaultBreakerFailThreshold is the number of consecutive failed requests to a
+	// meta partition which opens its breaker.
+	DefaultBreakerFailThreshold = 5
+	// DefaultBreakerFailWindow is the time within which the failures must happen, a
+	// failed request may take the whole send timeout.
+	DefaultBreakerFailWindow = 3 * time.Minute
+	// DefaultBreakerCoolDown is how long an open breaker fails the requests before
+	// letting one through to probe the partition.
+	DefaultBreakerCoolDown = 10 * time.Second
+)
+
+var ErrMetaPartitionBroken = errors.New("meta partition breaker open")
+
+// partitionBreaker is the circuit breaker of a meta partition. It opens when the
+// requests to the partition keep failing, then the requests fail fast instead of
+// waiting for the send timeout. After the cool down a single request probes the
+// partition, the breaker closes if it succeeds and opens again otherwise.
+type partitionBreaker struct {
+	sync.Mutex
+	failures  int
+	firstFail time.Time
+	open      bool
+	openUntil time.Time
+	probing   bool
+}
+
+func (mw *MetaWrapper) getBreaker(partitionID uint64) *partitionBreaker {
+	if b, ok := mw.breakers.Load(partitionID); ok {
+		return b.(*partitionBreaker)
+	}
+	b, _ := mw.breakers.LoadOrStore(partitionID, &partitionBreaker{})
+	return b.(*partitionBreaker)
+}
+
+func (mw *MetaWrapper) breakerFailThreshold() int {
+	if mw.breakerThreshold > 0 {
+		return mw.breakerThreshold
+	}
+	return DefaultBreakerFailThreshold
+}
+
+func (mw *MetaWrapper) breakerCoolDownTime() time.Duration {
+	if mw.breakerCoolDown > 0 {
+		return mw.breakerCoolDown
+	}
+	return DefaultBreakerCoolDown
+}
+
+// breakerAllow returns ErrMetaPartitionBroken if a request to the partition must
+// fail fast.
+func (mw *MetaWrapper) breakerAllow(partitionID uint64) error {
+	if mw.breakerThreshold < 0 {
+		return nil
+	}
+	b := mw.getBreaker(partitionID)
+	b.Lock()
+	defer b.Unlock()
+	if !b.open {
+		return nil
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return ErrMetaPartitionBroken
+	}
+	b.probing = true
+	log.LogInfof("breakerAllow: probe mp(%v)", partitionID)
+	return nil
+}
+
+// breakerDone records the result of a request allowed by breakerAllow.
+func (mw *MetaWrapper) breakerDone(partitionID uint64, failed bool) {
+	if mw.breakerThreshold < 0 {
+		return
+	}
+	b := mw.getBreaker(partitionID)
+	b.Lock()
+	if !failed {
+		if b.open {
+			log.LogInfof("breakerDone: mp(%v) breaker closed", partitionID)
+		}
+		b.failures = 0
+		b.open = false
+		b.probing = false
+		b.Unlock()
+		return
+	}
+
+	now := time.Now()
+	if b.failures == 0 || now.Sub(b.firstFail) > DefaultBreakerFailWindow {
+		b.failures = 0
+		b.firstFail = now
+	}
+	b.failures++
+	opened := false
+	if b.probing || (!b.open && b.failures >= mw.breakerFailThreshold()) {
+		opened = !b.open
+		b.open = true
+		b.probing = false
+		b.openUntil = now.Add(mw.breakerCoolDownTime())
+	}
+	b.Unlock()
+
+	if opened {
+		log.LogWarnf("breakerDone: mp(%v) breaker opened after %v failures", partitionID, mw.breakerFailThreshold())
+		// the partition may have moved, refresh the view without waiting for it
+		select {
+		case mw.forceUpdate <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// isPartitionBroken returns if the breaker of the partition is open.
+func (mw *MetaWrapper) isPartitionBroken(partitionID uint64) bool {
+	b, ok := mw.breakers.Load(partitionID)
+	if !ok {
+		return false
+	}
+	pb := b.(*partitionBreaker)
+	pb.Lock()
+	defer pb.Unlock()
+	return pb.open
+}
@@ -16,6 +16,7 @@ package meta
 
 import (
 	"fmt"
+	"math/rand"
 	"net"
 	"syscall"
 	"time"
@@ -27,8 +28,15 @@ import (
 )
 
 const (
-	SendRetryLimit    = 200 // times
-	SendRetryInterval = 100 // ms
+	SendRetryLimit         = 200  // times
+	SendRetryInterval      = 100  // ms
+	SendRetryMaxInterval   = 5000 // ms
+	DefaultSendRetryJitter = 0.2
+)
+
+var (
+	ErrSendRetryExhausted = errors.New("meta request retries exhausted")
+	ErrMetaWrapperClosed  = errors.New("meta wrapper closed")
 )
 
 type MetaConn struct {
@@ -66,17 +74,18 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 		start   time.Time
 		lastSeq uint64
 	)
-	var sendTimeLimit int
-	if mw.metaSendTimeout < 20 {
-		sendTimeLimit = 20 * 1000 // ms
-	} else {
-		sendTimeLimit = int(mw.metaSendTimeout) * 1000 // ms
-	}
-	delta := (sendTimeLimit*2/SendRetryLimit - SendRetryInterval*2) / SendRetryLimit // ms
-	log.LogDebugf("mw.metaSendTimeout: %v s, sendTimeLimit: %v ms, delta: %v ms", mw.metaSendTimeout, sendTimeLimit, delta)
+	sendTimeLimit := mw.sendTimeLimit(mp.PartitionID) // ms
+	retryLimit, retryInterval, delta := mw.sendRetryPolicy(sendTimeLimit)
+	log.LogDebugf("mw.metaSendTimeout: %v s, sendTimeLimit: %v ms, retryLimit: %v, retryInterval: %v ms, delta: %v ms",
+		mw.metaSendTimeout, sendTimeLimit, retryLimit, retryInterval, delta)
 
 	req.ExtentType |= proto.MultiVersionFlag
 
+	if err = mw.breakerAllow(mp.PartitionID); err != nil {
+		log.LogWarnf("sendToMetaPartition: fail fast req(%v) mp(%v) err(%v)", req, mp, err)
+		return nil, err
+	}
+
 	errs := make(map[int]error, len(mp.Members))
 	var j int
 
@@ -94,8 +103,12 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 	if mw.Client != nil { // compatible lcNode not init Client
 		lastSeq = mw.Client.GetLatestVer()
 	}
+	start = time.Now()
 	resp, err = mc.send(req, lastSeq)
 	mw.putConn(mc, err)
+	if err == nil {
+		mw.recordLatency(mp.PartitionID, time.Since(start))
+	}
 
 	if err == nil && !resp.ShouldRetry() {
 		goto out
@@ -104,7 +117,7 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 
 retry:
 	start = time.Now()
-	for i := 0; i <= SendRetryLimit; i++ {
+	for i := 0; mw.sendInfiniteRetry || i <= retryLimit; i++ {
 		for j, addr = range mp.Members {
 			mc, err = mw.getConn(mp.PartitionID, addr)
 			errs[j] = err
@@ -112,8 +125,12 @@ retry:
 				log.LogWarnf("sendToMetaPartition: getConn failed and continue to retry, req(%v) mp(%v) addr(%v) err(%v)", req, mp, addr, err)
 				continue
 			}
+			sendStart := time.Now()
 			resp, err = mc.send(req, lastSeq)
 			mw.putConn(mc, err)
+			if err == nil {
+				mw.recordLatency(mp.PartitionID, time.Since(sendStart))
+			}
 			if err == nil && !resp.ShouldRetry() {
 				goto out
 			}
@@ -124,19 +141,34 @@ retry:
 			}
 			log.LogWarnf("sendToMetaPartition: retry failed req(%v) mp(%v) mc(%v) errs(%v) resp(%v)", req, mp, mc, errs, resp)
 		}
-		if time.Since(start) > time.Duration(sendTimeLimit)*time.Millisecond {
-			log.LogWarnf("sendToMetaPartition: retry timeout req(%v) mp(%v) time(%v)", req, mp, time.Since(start))
-			break
+		if !mw.sendInfiniteRetry {
+			if time.Since(start) > time.Duration(sendTimeLimit)*time.Millisecond {
+				log.LogWarnf("sendToMetaPartition: retry timeout req(%v) mp(%v) time(%v)", req, mp, time.Since(start))
+				break
+			}
+			if i == retryLimit {
+				log.LogWarnf("sendToMetaPartition: retry limit reached req(%v) mp(%v) retries(%v)", req, mp, retryLimit)
+				break
+			}
 		}
-		sendRetryInterval := time.Duration(SendRetryInterval+i*delta) * time.Millisecond
+		sendRetryInterval := mw.sendRetryDelay(i, retryInterval, delta)
 		log.LogWarnf("sendToMetaPartition: req(%v) mp(%v) retry in (%v), retry_iteration (%v), retry_totalTime (%v)", req, mp,
 			sendRetryInterval, i+1, time.Since(start))
-		time.Sleep(sendRetryInterval)
+		timer := time.NewTimer(sendRetryInterval)
+		select {
+		case <-mw.closeCh:
+			timer.Stop()
+			log.LogWarnf("sendToMetaPartition: stop retrying req(%v) mp(%v) on close", req, mp)
+			mw.breakerDone(mp.PartitionID, true)
+			return nil, ErrMetaWrapperClosed
+		case <-timer.C:
+		}
 	}
 
 out:
+	mw.breakerDone(mp.PartitionID, err != nil || resp == nil)
 	if err != nil || resp == nil {
-		return nil, errors.New(fmt.Sprintf("sendToMetaPartition failed: req(%v) mp(%v) errs(%v) resp(%v)", req, mp, errs, resp))
+		return nil, fmt.Errorf("%w: req(%v) mp(%v) errs(%v) resp(%v)", ErrSendRetryExhausted, req, mp, errs, resp)
 	}
 	log.LogDebugf("sendToMetaPartition: succeed! req(%v) mc(%v) resp(%v)", req, mc, resp)
 	if mw.Client != nil { // compatible lcNode not init Client
@@ -145,6 +177,60 @@ out:
 	return resp, nil
 }
 
+// sendRetryPolicy returns how many times a request failed with an EAGAIN-class
+// result is retried on the members after the first round, so SendRetryLimit makes
+// SendRetryLimit+1 rounds like before, the first backoff between rounds and how much
+// the backoff grows every round, in ms. By default the backoff grows so that the
+// retries roughly span twice the send time limit, a configured backoff is constant.
+func (mw *MetaWrapper) sendRetryPolicy(sendTimeLimit int) (limit int, interval int, delta int) {
+	limit = SendRetryLimit
+	if mw.sendRetryLimit > 0 {
+		limit = mw.sendRetryLimit
+	}
+	if mw.sendRetryInterval > 0 {
+		return limit, int(mw.sendRetryInterval), 0
+	}
+	interval = SendRetryInterval
+	delta = (sendTimeLimit*2/limit - interval*2) / limit
+	if delta < 0 {
+		delta = 0
+	}
+	return
+}
+
+// sendRetryDelay returns the backoff before the retry round after round i, growing
+// by delta ms from interval ms and capped at the max interval, spread by the jitter.
+func (mw *MetaWrapper) sendRetryDelay(i int, interval int, delta int) time.Duration {
+	maxInterval := SendRetryMaxInterval
+	if mw.sendRetryMaxInterval > 0 {
+		maxInterval = int(mw.sendRetryMaxInterval)
+	}
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+	d := interval + i*delta
+	if d > maxInterval || d < 0 {
+		d = maxInterval
+	}
+	jitter := DefaultSendRetryJitter
+	if mw.sendRetryJitter != 0 {
+		jitter = mw.sendRetryJitter
+	}
+	return jitterDelay(time.Duration(d)*time.Millisecond, jitter)
+}
+
+// jitterDelay returns d spread uniformly within d*(1-jitter) and d*(1+jitter), the
+// jitter is at most 1.
+func jitterDelay(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 || d <= 0 {
+		return d
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+	return d + time.Duration((rand.Float64()*2-1)*jitter*float64(d))
+}
+
 func (mc *MetaConn) send(req *proto.Packet, verSeq uint64) (resp *proto.Packet, err error) {
 	req.ExtentType |= proto.MultiVersionFlag
 	req.VerSeq = verSeq
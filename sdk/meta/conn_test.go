@@ -0,0 +1,302 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/stretchr/testify/require"
+)
+
+// mockMetaNode replies OpAgain to the first againCnt requests and OpOk to the others.
+// While down is set it drops the connections instead of replying.
+type mockMetaNode struct {
+	ln       net.Listener
+	againCnt int32
+	reqCnt   int32
+	down     int32
+}
+
+// initBufferPool makes the packet buffer pool once, the mocks of the tests
+// running in parallel would race re-creating it.
+var initBufferPool sync.Once
+
+func newMockMetaNode(t *testing.T, againCnt int32) *mockMetaNode {
+	initBufferPool.Do(func() { proto.InitBufferPool(int64(32768)) })
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	m := &mockMetaNode{ln: ln, againCnt: againCnt}
+	go m.serve()
+	t.Cleanup(func() { ln.Close() })
+	return m
+}
+
+func (m *mockMetaNode) serve() {
+	for {
+		conn, err := m.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			for {
+				p := proto.NewPacket()
+				if err := p.ReadFromConnWithVer(conn, proto.NoReadDeadlineTime); err != nil {
+					return
+				}
+				if atomic.LoadInt32(&m.down) == 1 {
+					return
+				}
+				p.ResultCode = proto.OpOk
+				if atomic.AddInt32(&m.reqCnt, 1) <= m.againCnt {
+					p.ResultCode = proto.OpAgain
+				}
+				p.Size = 0
+				p.Data = nil
+				if err := p.WriteToConn(conn); err != nil {
+					return
+				}
+			}
+		}(conn)
+	}
+}
+
+func newRetryTestWrapper(retryLimit int) *MetaWrapper {
+	return &MetaWrapper{
+		conns:             util.NewConnectPool(),
+		sendRetryLimit:    retryLimit,
+		sendRetryInterval: 1,
+	}
+}
+
+func TestSendToMetaPartitionRetryAgain(t *testing.T) {
+	// a transient EAGAIN is retried until the request succeeds
+	node := newMockMetaNode(t, 2)
+	addr := node.ln.Addr().String()
+	mp := &MetaPartition{PartitionID: 1, Members: []string{addr}, LeaderAddr: addr}
+	mw := newRetryTestWrapper(5)
+
+	req := proto.NewPacketReqID()
+	req.Opcode = proto.OpMetaInodeGet
+	resp, err := mw.sendToMetaPartition(mp, req)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpOk, resp.ResultCode)
+	require.EqualValues(t, 3, atomic.LoadInt32(&node.reqCnt))
+
+	// a persistent EAGAIN surfaces once the retries are used up
+	node = newMockMetaNode(t, 1000)
+	addr = node.ln.Addr().String()
+	mp = &MetaPartition{PartitionID: 1, Members: []string{addr}, LeaderAddr: addr}
+	mw = newRetryTestWrapper(3)
+
+	req = proto.NewPacketReqID()
+	req.Opcode = proto.OpMetaInodeGet
+	resp, err = mw.sendToMetaPartition(mp, req)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpAgain, resp.ResultCode)
+	// the request to the leader, the first round on the members and one per retry
+	require.EqualValues(t, 5, atomic.LoadInt32(&node.reqCnt))
+	require.Equal(t, syscall.EAGAIN, statusToErrno(parseStatus(resp.ResultCode)))
+}
+
+func TestSendRetryPolicy(t *testing.T) {
+	mw := &MetaWrapper{}
+	limit, interval, delta := mw.sendRetryPolicy(20 * 1000)
+	require.Equal(t, SendRetryLimit, limit)
+	require.Equal(t, SendRetryInterval, interval)
+	require.Equal(t, (20*1000*2/SendRetryLimit-SendRetryInterval*2)/SendRetryLimit, delta)
+
+	mw = &MetaWrapper{sendRetryLimit: 10, sendRetryInterval: 50}
+	limit, interval, delta = mw.sendRetryPolicy(20 * 1000)
+	require.Equal(t, 10, limit)
+	require.Equal(t, 50, interval)
+	require.Equal(t, 0, delta)
+}
+
+func TestSendRetryDelay(t *testing.T) {
+	// the delays are spread within the jitter around the backoff
+	mw := &MetaWrapper{sendRetryJitter: 0.5}
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		d := mw.sendRetryDelay(0, 100, 0)
+		require.GreaterOrEqual(t, d, 50*time.Millisecond)
+		require.LessOrEqual(t, d, 150*time.Millisecond)
+		seen[d] = true
+	}
+	require.Greater(t, len(seen), 1)
+
+	// the backoff grows up to the max interval
+	mw = &MetaWrapper{sendRetryJitter: -1, sendRetryMaxInterval: 300}
+	require.Equal(t, 100*time.Millisecond, mw.sendRetryDelay(0, 100, 50))
+	require.Equal(t, 200*time.Millisecond, mw.sendRetryDelay(2, 100, 50))
+	require.Equal(t, 300*time.Millisecond, mw.sendRetryDelay(10, 100, 50))
+	require.Equal(t, 300*time.Millisecond, mw.sendRetryDelay(1<<62, 100, 50))
+
+	mw = &MetaWrapper{}
+	d := mw.sendRetryDelay(1000, 100, 50)
+	require.GreaterOrEqual(t, d, time.Duration(float64(SendRetryMaxInterval*time.Millisecond)*(1-DefaultSendRetryJitter)))
+	require.LessOrEqual(t, d, time.Duration(float64(SendRetryMaxInterval*time.Millisecond)*(1+DefaultSendRetryJitter)))
+}
+
+func TestSendRetryBudget(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadAddr := ln.Addr().String()
+	ln.Close()
+	mp := &MetaPartition{PartitionID: 1, Members: []string{deadAddr}, LeaderAddr: deadAddr}
+
+	// an unreachable partition fails once the retries are used up
+	mw := newRetryTestWrapper(3)
+	mw.breakerThreshold = -1
+	req := proto.NewPacketReqID()
+	req.Opcode = proto.OpMetaInodeGet
+	_, err = mw.sendToMetaPartition(mp, req)
+	require.ErrorIs(t, err, ErrSendRetryExhausted)
+
+	// with the infinite retry a transient EAGAIN outlasts the retry limit
+	node := newMockMetaNode(t, 5)
+	addr := node.ln.Addr().String()
+	mw = newRetryTestWrapper(1)
+	mw.sendInfiniteRetry = true
+	resp, err := mw.sendToMetaPartition(&MetaPartition{PartitionID: 2, Members: []string{addr}, LeaderAddr: addr}, req)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpOk, resp.ResultCode)
+	require.EqualValues(t, 6, atomic.LoadInt32(&node.reqCnt))
+
+	// and stops when the wrapper is closed
+	mw = newRetryTestWrapper(1)
+	mw.sendInfiniteRetry = true
+	mw.breakerThreshold = -1
+	mw.closeCh = make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := mw.sendToMetaPartition(mp, req)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(mw.closeCh)
+	select {
+	case err = <-done:
+		require.Equal(t, ErrMetaWrapperClosed, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("infinite retry not stopped by close")
+	}
+}
+
+func TestStatusToErrno(t *testing.T) {
+	require.Equal(t, syscall.ENOSPC, statusToErrno(parseStatus(proto.OpNoSpaceErr)))
+	require.Equal(t, syscall.EDQUOT, statusToErrno(parseStatus(proto.OpDirQuota)))
+	require.Equal(t, syscall.EFBIG, statusToErrno(parseStatus(proto.OpFileTooLargeErr)))
+	require.Equal(t, syscall.EAGAIN, statusToErrno(parseStatus(proto.OpErr)))
+}
+
+func TestMetaPartitionBreaker(t *testing.T) {
+	node := newMockMetaNode(t, 0)
+	addr := node.ln.Addr().String()
+	mp := &MetaPartition{PartitionID: 1, Members: []string{addr}, LeaderAddr: addr}
+	other := &MetaPartition{PartitionID: 2, Members: []string{addr}, LeaderAddr: addr}
+	mw := newRetryTestWrapper(1)
+	mw.breakerThreshold = 3
+	mw.breakerCoolDown = 200 * time.Millisecond
+	mw.forceUpdate = make(chan struct{}, 1)
+	mw.rwPartitions = []*MetaPartition{mp, other}
+	send := func() error {
+		req := proto.NewPacketReqID()
+		req.Opcode = proto.OpMetaInodeGet
+		_, err := mw.sendToMetaPartition(mp, req)
+		return err
+	}
+
+	// the breaker opens after the threshold of consecutive failures
+	atomic.StoreInt32(&node.down, 1)
+	for i := 0; i < 3; i++ {
+		err := send()
+		require.Error(t, err)
+		require.NotEqual(t, ErrMetaPartitionBroken, err)
+	}
+	require.True(t, mw.isPartitionBroken(mp.PartitionID))
+	require.False(t, mw.isPartitionBroken(other.PartitionID))
+	require.Len(t, mw.forceUpdate, 1, "an open breaker triggers a view refresh")
+	require.Equal(t, []*MetaPartition{other}, mw.getRWPartitions())
+
+	// while open the requests fail fast, the partition recovering does not matter
+	atomic.StoreInt32(&node.down, 0)
+	require.Equal(t, ErrMetaPartitionBroken, send())
+	require.EqualValues(t, 0, atomic.LoadInt32(&node.reqCnt))
+
+	// after the cool down a successful probe closes the breaker
+	time.Sleep(mw.breakerCoolDown)
+	require.NoError(t, send())
+	require.False(t, mw.isPartitionBroken(mp.PartitionID))
+	require.NoError(t, send())
+	require.EqualValues(t, 2, atomic.LoadInt32(&node.reqCnt))
+
+	// a failed probe opens the breaker again at once
+	atomic.StoreInt32(&node.down, 1)
+	for i := 0; i < 3; i++ {
+		require.Error(t, send())
+	}
+	time.Sleep(mw.breakerCoolDown)
+	require.Error(t, send())
+	require.Equal(t, ErrMetaPartitionBroken, send())
+}
+
+func TestAdaptiveSendTimeLimit(t *testing.T) {
+	mw := &MetaWrapper{metaSendTimeout: 60}
+	// disabled, the static limit is used
+	for i := 0; i < 100; i++ {
+		mw.recordLatency(1, time.Millisecond)
+	}
+	require.Equal(t, 60*1000, mw.sendTimeLimit(1))
+
+	mw = &MetaWrapper{metaSendTimeout: 60, adaptiveSendTimeout: true, sendTimeoutMin: time.Second, sendTimeoutMax: 30 * time.Second}
+	// the static limit until enough round trips are known
+	for i := 0; i < latencyMinSamples-1; i++ {
+		mw.recordLatency(1, 100*time.Millisecond)
+	}
+	require.Equal(t, 60*1000, mw.sendTimeLimit(1))
+	mw.recordLatency(1, 100*time.Millisecond)
+	require.Equal(t, int(100*adaptiveTimeoutFactor), mw.sendTimeLimit(1))
+
+	// the limit tracks the p99 of the last round trips, 1% of slow ones count
+	for i := 0; i < latencyWindow; i++ {
+		d := 100 * time.Millisecond
+		if i%100 == 0 {
+			d = 300 * time.Millisecond
+		}
+		mw.recordLatency(1, d)
+	}
+	p, ok := mw.getLatencyTracker(1).percentile(adaptiveTimeoutPercentile)
+	require.True(t, ok)
+	require.Equal(t, 300*time.Millisecond, p)
+	require.Equal(t, int(300*adaptiveTimeoutFactor), mw.sendTimeLimit(1))
+
+	// bounded by the min and the max
+	for i := 0; i < latencyWindow; i++ {
+		mw.recordLatency(1, time.Millisecond)
+		mw.recordLatency(2, 10*time.Second)
+	}
+	require.Equal(t, 1000, mw.sendTimeLimit(1))
+	require.Equal(t, 30*1000, mw.sendTimeLimit(2))
+	// the partitions are tracked apart
+	require.Equal(t, 60*1000, mw.sendTimeLimit(3))
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyWindow is the number of recent round trips kept per meta partition.
+	latencyWindow = 256
+	// latencyMinSamples is the number of round trips needed before the adaptive send
+	// timeout is used.
+	latencyMinSamples = 32
+	// adaptiveTimeoutPercentile of the round trips, multiplied by adaptiveTimeoutFactor,
+	// is the adaptive send timeout.
+	adaptiveTimeoutPercentile = 0.99
+	adaptiveTimeoutFactor     = 20
+
+	DefaultMetaSendTimeoutMin = 5 * time.Second
+)
+
+// latencyTracker keeps the latencies of the last round trips to a meta partition.
+type latencyTracker struct {
+	sync.Mutex
+	samples [latencyWindow]time.Duration
+	count   int
+	next    int
+}
+
+func (t *latencyTracker) add(d time.Duration) {
+	t.Lock()
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % latencyWindow
+	if t.count < latencyWindow {
+		t.count++
+	}
+	t.Unlock()
+}
+
+// percentile returns the p percentile of the kept latencies, false if there are
+// fewer than latencyMinSamples of them.
+func (t *latencyTracker) percentile(p float64) (time.Duration, bool) {
+	t.Lock()
+	if t.count < latencyMinSamples {
+		t.Unlock()
+		return 0, false
+	}
+	sorted := make([]time.Duration, t.count)
+	copy(sorted, t.samples[:t.count])
+	t.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(math.Ceil(float64(len(sorted))*p)) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx], true
+}
+
+func (mw *MetaWrapper) getLatencyTracker(partitionID uint64) *latencyTracker {
+	if t, ok := mw.latencies.Load(partitionID); ok {
+		return t.(*latencyTracker)
+	}
+	t, _ := mw.latencies.LoadOrStore(partitionID, &latencyTracker{})
+	return t.(*latencyTracker)
+}
+
+// recordLatency records the latency of a round trip answered by the partition.
+func (mw *MetaWrapper) recordLatency(partitionID uint64, d time.Duration) {
+	if !mw.adaptiveSendTimeout {
+		return
+	}
+	mw.getLatencyTracker(partitionID).add(d)
+}
+
+// staticSendTimeLimit returns the send time limit set by MetaSendTimeout in ms.
+func (mw *MetaWrapper) staticSendTimeLimit() int {
+	if mw.metaSendTimeout < 20 {
+		return 20 * 1000
+	}
+	return int(mw.metaSendTimeout) * 1000
+}
+
+// sendTimeLimit returns how long in ms the requests to the partition are retried.
+// With the adaptive send timeout it follows the recent round trips of the partition,
+// bounded by the configured min and max, the static limit is used until enough
+// round trips are known.
+func (mw *MetaWrapper) sendTimeLimit(partitionID uint64) int {
+	static := mw.staticSendTimeLimit()
+	if !mw.adaptiveSendTimeout {
+		return static
+	}
+	p, ok := mw.getLatencyTracker(partitionID).percentile(adaptiveTimeoutPercentile)
+	if !ok {
+		return static
+	}
+	minLimit, maxLimit := mw.sendTimeoutMin, mw.sendTimeoutMax
+	if minLimit <= 0 {
+		minLimit = DefaultMetaSendTimeoutMin
+	}
+	if maxLimit <= 0 {
+		maxLimit = time.Duration(static) * time.Millisecond
+	}
+	if maxLimit < minLimit {
+		maxLimit = minLimit
+	}
+	limit := p * adaptiveTimeoutFactor
+	if limit < minLimit {
+		limit = minLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return int(limit / time.Millisecond)
+}
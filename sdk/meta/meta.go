@@ -38,6 +38,8 @@ import (
 const (
 	HostsSeparator                = ","
 	RefreshMetaPartitionsInterval = time.Minute * 5
+	// MinRefreshMetaPartitionsInterval is the floor of MetaConfig.RefreshInterval.
+	MinRefreshMetaPartitionsInterval = time.Second * 10
 )
 
 const (
@@ -58,6 +60,7 @@ const (
 	statusTxTimeout
 	statusUploadPartConflict
 	statusNotEmpty
+	statusFileTooLarge
 )
 
 const (
@@ -91,6 +94,36 @@ type MetaConfig struct {
 	OnAsyncTaskError AsyncTaskErrorFunc
 	EnableSummary    bool
 	MetaSendTimeout  int64
+	// Retries of the requests failed with EAGAIN-class results and the backoff in ms between
+	// them, zero means SendRetryLimit and a backoff growing from SendRetryInterval.
+	MetaSendRetryLimit    int
+	MetaSendRetryInterval int64
+	// Cap in ms of the backoff between the retries, zero means SendRetryMaxInterval.
+	MetaSendRetryMaxInterval int64
+	// Fraction of the backoff by which it is randomly spread, so that the clients
+	// failing together do not retry together. Zero means DefaultSendRetryJitter and a
+	// negative jitter disables it.
+	MetaSendRetryJitter float64
+	// MetaSendInfiniteRetry retries the requests until they succeed or the wrapper is
+	// closed, ignoring the retry limit and the send time limit.
+	MetaSendInfiniteRetry bool
+	// Interval of the periodic refresh of the meta partitions, zero means
+	// RefreshMetaPartitionsInterval. It is at least MinRefreshMetaPartitionsInterval.
+	RefreshInterval time.Duration
+	// Minimum interval between two forced refreshes of the meta partitions, zero
+	// means MinForceUpdateMetaPartitionsInterval seconds.
+	ForceUpdateInterval time.Duration
+	// Consecutive failed requests to a meta partition opening its breaker and how long
+	// the breaker stays open, zero means the defaults and a negative threshold disables
+	// the breakers.
+	BreakerFailThreshold int
+	BreakerCoolDown      time.Duration
+	// AdaptiveSendTimeout makes the send time limit of a meta partition follow the
+	// latency of its recent round trips instead of MetaSendTimeout, bounded by
+	// MetaSendTimeoutMin and MetaSendTimeoutMax. Zero bounds mean the defaults.
+	AdaptiveSendTimeout bool
+	MetaSendTimeoutMin  time.Duration
+	MetaSendTimeoutMax  time.Duration
 
 	//EnableTransaction uint8
 	//EnableTransaction bool
@@ -147,9 +180,22 @@ type MetaWrapper struct {
 	// Allocated to trigger and throttle instant partition updates
 	forceUpdate             chan struct{}
 	forceUpdateLimit        *rate.Limiter
+	refreshInterval         time.Duration
+	breakers                sync.Map // partition id -> *partitionBreaker
+	breakerThreshold        int
+	breakerCoolDown         time.Duration
+	adaptiveSendTimeout     bool
+	sendTimeoutMin          time.Duration
+	sendTimeoutMax          time.Duration
+	latencies               sync.Map // partition id -> *latencyTracker
 	singleflight            singleflight.Group
 	EnableSummary           bool
 	metaSendTimeout         int64
+	sendRetryLimit          int
+	sendRetryInterval       int64
+	sendRetryMaxInterval    int64
+	sendRetryJitter         float64
+	sendInfiniteRetry       bool
 	DirChildrenNumLimit     uint32
 	EnableTransaction       proto.TxOpMask
 	TxTimeout               int64
@@ -209,13 +255,24 @@ func NewMetaWrapper(config *MetaConfig) (*MetaWrapper, error) {
 	mw.mc = masterSDK.NewMasterClient(config.Masters, false)
 	mw.onAsyncTaskError = config.OnAsyncTaskError
 	mw.metaSendTimeout = config.MetaSendTimeout
+	mw.sendRetryLimit = config.MetaSendRetryLimit
+	mw.sendRetryInterval = config.MetaSendRetryInterval
+	mw.sendRetryMaxInterval = config.MetaSendRetryMaxInterval
+	mw.sendRetryJitter = config.MetaSendRetryJitter
+	mw.sendInfiniteRetry = config.MetaSendInfiniteRetry
+	mw.breakerThreshold = config.BreakerFailThreshold
+	mw.breakerCoolDown = config.BreakerCoolDown
+	mw.adaptiveSendTimeout = config.AdaptiveSendTimeout
+	mw.sendTimeoutMin = config.MetaSendTimeoutMin
+	mw.sendTimeoutMax = config.MetaSendTimeoutMax
 	mw.conns = util.NewConnectPool()
 	mw.partitions = make(map[uint64]*MetaPartition)
 	mw.ranges = btree.New(32)
 	mw.rwPartitions = make([]*MetaPartition, 0)
 	mw.partCond = sync.NewCond(&mw.partMutex)
 	mw.forceUpdate = make(chan struct{}, 1)
-	mw.forceUpdateLimit = rate.NewLimiter(1, MinForceUpdateMetaPartitionsInterval)
+	mw.refreshInterval = getRefreshInterval(config.RefreshInterval)
+	mw.forceUpdateLimit = rate.NewLimiter(rate.Every(getForceUpdateInterval(config.ForceUpdateInterval)), 1)
 	mw.EnableSummary = config.EnableSummary
 	mw.DirChildrenNumLimit = proto.DefaultDirChildrenNumLimit
 	//mw.EnableTransaction = config.EnableTransaction
@@ -241,7 +298,7 @@ func NewMetaWrapper(config *MetaConfig) (*MetaWrapper, error) {
 		}
 		if err != nil {
 			limit++
-			time.Sleep(MountRetryInterval * time.Duration(limit))
+			time.Sleep(jitterDelay(MountRetryInterval*time.Duration(limit), DefaultSendRetryJitter))
 			continue
 		}
 		break
@@ -256,6 +313,23 @@ func NewMetaWrapper(config *MetaConfig) (*MetaWrapper, error) {
 	return mw, nil
 }
 
+func getRefreshInterval(interval time.Duration) time.Duration {
+	if interval == 0 {
+		return RefreshMetaPartitionsInterval
+	}
+	if interval < MinRefreshMetaPartitionsInterval {
+		return MinRefreshMetaPartitionsInterval
+	}
+	return interval
+}
+
+func getForceUpdateInterval(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return MinForceUpdateMetaPartitionsInterval * time.Second
+	}
+	return interval
+}
+
 func (mw *MetaWrapper) initMetaWrapper() (err error) {
 	if err = mw.updateClusterInfo(); err != nil {
 		return err
@@ -308,6 +382,11 @@ func (mw *MetaWrapper) LocalIP() string {
 	return mw.localIP
 }
 
+// ConnPoolStat returns the stat of the connections to the metanodes.
+func (mw *MetaWrapper) ConnPoolStat() *util.ConnPoolStat {
+	return mw.conns.GetStat()
+}
+
 func (mw *MetaWrapper) exporterKey(act string) string {
 	return fmt.Sprintf("%s_sdk_meta_%s", mw.cluster, act)
 }
@@ -337,6 +416,8 @@ func parseStatus(result uint8) (status int) {
 		status = statusNotEmpty
 	case proto.OpNoSpaceErr:
 		status = statusNoSpace
+	case proto.OpFileTooLargeErr:
+		status = statusFileTooLarge
 	case proto.OpTxInodeInfoNotExistErr:
 		status = statusTxInodeInfoNotExist
 	case proto.OpTxConflictErr:
@@ -386,6 +467,8 @@ func statusToErrno(status int) error {
 		return syscall.EDQUOT
 	case statusNoSpace:
 		return syscall.ENOSPC
+	case statusFileTooLarge:
+		return syscall.EFBIG
 	case statusTxInodeInfoNotExist:
 		return syscall.EAGAIN
 	case statusTxConflict:
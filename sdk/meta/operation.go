@@ -320,6 +320,37 @@ func (mw *MetaWrapper) txIunlink(tx *Transaction, mp *MetaPartition, inode uint6
 	return statusOK, resp.Info, nil
 }
 
+func (mw *MetaWrapper) txSetattr(tx *Transaction, mp *MetaPartition, inode uint64, valid, mode, uid, gid uint32) (status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("txSetattr", err, bgTime, 1)
+	}()
+
+	req := &proto.TxSetAttrRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Mode:        mode,
+		Uid:         uid,
+		Gid:         gid,
+		Valid:       valid,
+		TxInfo:      tx.txInfo,
+	}
+	metric := exporter.NewTPCnt("OpMetaTxSetAttr")
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	var packet *proto.Packet
+	if status, err, packet = mw.SendTxPack(req, nil, proto.OpMetaTxSetAttr, mp, nil); err != nil {
+		log.LogErrorf("txSetattr: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("txSetattr: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+	return statusOK, nil
+}
+
 func (mw *MetaWrapper) iunlink(mp *MetaPartition, inode uint64, verSeq uint64, denVerSeq uint64) (status int, info *proto.InodeInfo, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -1212,7 +1243,7 @@ func (mw *MetaWrapper) readDir(mp *MetaPartition, parentID uint64) (status int,
 }
 
 // read limit dentries start from
-func (mw *MetaWrapper) readDirLimit(mp *MetaPartition, parentID uint64, from string, limit uint64, verSeq uint64, verOpt uint8) (status int, children []proto.Dentry, err error) {
+func (mw *MetaWrapper) readDirLimit(mp *MetaPartition, parentID uint64, from string, limit uint64, verSeq uint64, verOpt uint8, filter uint8) (status int, children []proto.Dentry, err error) {
 	req := &proto.ReadDirLimitRequest{
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
@@ -1221,6 +1252,7 @@ func (mw *MetaWrapper) readDirLimit(mp *MetaPartition, parentID uint64, from str
 		Limit:       limit,
 		VerSeq:      verSeq,
 		VerOpt:      verOpt,
+		Filter:      filter,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -1260,6 +1292,55 @@ func (mw *MetaWrapper) readDirLimit(mp *MetaPartition, parentID uint64, from str
 	return statusOK, resp.Children, nil
 }
 
+// read limit dentries start from, with the attributes of the inodes in mp
+func (mw *MetaWrapper) readDirPlus(mp *MetaPartition, parentID uint64, from string, limit uint64, verSeq uint64) (status int, children []proto.DentryPlus, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("readDirPlus", err, bgTime, 1)
+	}()
+
+	req := &proto.ReadDirPlusRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		ParentID:    parentID,
+		Marker:      from,
+		Limit:       limit,
+		VerSeq:      verSeq,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaReadDirPlus
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("readDirPlus: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	if packet, err = mw.sendToMetaPartition(mp, packet); err != nil {
+		log.LogErrorf("readDirPlus: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("readDirPlus: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.ReadDirPlusResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("readDirPlus: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("readDirPlus: packet(%v) mp(%v) req(%v) children(%v)", packet, mp, *req, len(resp.Children))
+	return statusOK, resp.Children, nil
+}
+
 func (mw *MetaWrapper) appendExtentKey(mp *MetaPartition, inode uint64, extent proto.ExtentKey, discard []proto.ExtentKey, isSplit bool) (status int, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -2314,6 +2395,288 @@ func (mw *MetaWrapper) listXAttr(mp *MetaPartition, inode uint64) (keys []string
 	return
 }
 
+func (mw *MetaWrapper) setInodeFlags(mp *MetaPartition, inode uint64, flags uint32) (status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("setInodeFlags", err, bgTime, 1)
+	}()
+
+	req := &proto.SetInodeFlagsRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Flags:       flags,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaSetInodeFlags
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("setInodeFlags: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	if packet, err = mw.sendToMetaPartition(mp, packet); err != nil {
+		log.LogErrorf("setInodeFlags: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("setInodeFlags: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("setInodeFlags: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	return
+}
+
+func (mw *MetaWrapper) cloneInode(mp *MetaPartition, inode uint64, uid, gid uint32) (status int, info *proto.InodeInfo, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("cloneInode", err, bgTime, 1)
+	}()
+
+	req := &proto.CloneInodeRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Uid:         uid,
+		Gid:         gid,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaCloneInode
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("cloneInode: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	if packet, err = mw.sendToMetaPartition(mp, packet); err != nil {
+		log.LogErrorf("cloneInode: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("cloneInode: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.CloneInodeResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("cloneInode: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	if resp.Info == nil {
+		err = fmt.Errorf("cloneInode: info is nil, packet(%v) mp(%v) req(%v) PacketData(%v)", packet, mp, *req, string(packet.Data))
+		log.LogWarn(err)
+		return
+	}
+	info = resp.Info
+	log.LogDebugf("cloneInode: packet(%v) mp(%v) req(%v) info(%v)", packet, mp, *req, info)
+	return
+}
+
+func (mw *MetaWrapper) setDentryAttr(mp *MetaPartition, parentID uint64, name, key, value string) (status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("setDentryAttr", err, bgTime, 1)
+	}()
+
+	req := &proto.SetDentryAttrRequest{
+		VolName:     mw.volname,
+		PartitionId: mp.PartitionID,
+		ParentID:    parentID,
+		Name:        name,
+		Key:         key,
+		Value:       value,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaSetDentryAttr
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("setDentryAttr: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	if packet, err = mw.sendToMetaPartition(mp, packet); err != nil {
+		log.LogErrorf("setDentryAttr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("setDentryAttr: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("setDentryAttr: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	return
+}
+
+func (mw *MetaWrapper) getChangeEvents(mp *MetaPartition, fromSeq uint64, inodes, parents []uint64) (resp *proto.GetChangeEventsResponse, status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("getChangeEvents", err, bgTime, 1)
+	}()
+
+	req := &proto.GetChangeEventsRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		FromSeq:     fromSeq,
+		Inodes:      inodes,
+		Parents:     parents,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaGetChangeEvents
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("getChangeEvents: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	if packet, err = mw.sendToMetaPartition(mp, packet); err != nil {
+		log.LogErrorf("getChangeEvents: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("getChangeEvents: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp = new(proto.GetChangeEventsResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("getChangeEvents: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	return
+}
+
+func (mw *MetaWrapper) getDentryAttr(mp *MetaPartition, parentID uint64, name, key string) (value string, status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("getDentryAttr", err, bgTime, 1)
+	}()
+
+	req := &proto.GetDentryAttrRequest{
+		VolName:     mw.volname,
+		PartitionId: mp.PartitionID,
+		ParentID:    parentID,
+		Name:        name,
+		Key:         key,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaGetDentryAttr
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("getDentryAttr: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	if packet, err = mw.sendToMetaPartition(mp, packet); err != nil {
+		log.LogErrorf("getDentryAttr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("getDentryAttr: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.GetDentryAttrResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("getDentryAttr: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	value = resp.Value
+	return
+}
+
+func (mw *MetaWrapper) listDentryAttr(mp *MetaPartition, parentID uint64, name string) (keys []string, status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("listDentryAttr", err, bgTime, 1)
+	}()
+
+	req := &proto.ListDentryAttrRequest{
+		VolName:     mw.volname,
+		PartitionId: mp.PartitionID,
+		ParentID:    parentID,
+		Name:        name,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaListDentryAttr
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("listDentryAttr: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	if packet, err = mw.sendToMetaPartition(mp, packet); err != nil {
+		log.LogErrorf("listDentryAttr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("listDentryAttr: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.ListDentryAttrResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("listDentryAttr: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	keys = resp.Attrs
+	return
+}
+
 func (mw *MetaWrapper) listMultiparts(mp *MetaPartition, prefix, delimiter, keyMarker string, multipartIdMarker string, maxUploads uint64) (status int, sessions *proto.ListMultipartResponse, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -2569,6 +2932,56 @@ func (mw *MetaWrapper) batchSetInodeQuota(mp *MetaPartition, inodes []uint64, qu
 	return
 }
 
+func (mw *MetaWrapper) setSubtreeQuota(mp *MetaPartition, dirs []uint64, marker string, quotaId uint32,
+	limit uint32) (resp *proto.SetSubtreeQuotaResponse, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("setSubtreeQuota", err, bgTime, 1)
+	}()
+
+	req := &proto.SetSubtreeQuotaRequest{
+		PartitionId: mp.PartitionID,
+		QuotaId:     quotaId,
+		Dirs:        dirs,
+		Marker:      marker,
+		Limit:       limit,
+	}
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaSetSubtreeQuota
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("setSubtreeQuota MarshalData req [%v] fail.", req)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("setSubtreeQuota: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status := parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("setSubtreeQuota: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+	resp = new(proto.SetSubtreeQuotaResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("setSubtreeQuota: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("setSubtreeQuota: mp(%v) req(%v) left dirs(%v) remote dirs(%v) remote inodes(%v)",
+		mp, *req, len(resp.Dirs), len(resp.RemoteDirs), len(resp.RemoteInodes))
+	return
+}
+
 func (mw *MetaWrapper) batchDeleteInodeQuota(mp *MetaPartition, inodes []uint64,
 	quotaId uint32) (resp *proto.BatchDeleteMetaserverQuotaResponse, err error) {
 	bgTime := stat.BeginStat()
@@ -117,6 +117,16 @@ func (mw *MetaWrapper) getRWPartitions() []*MetaPartition {
 			rwPartitions = append(rwPartitions, mp)
 		}
 	}
+	// skip the partitions whose breaker is open, unless all of them are broken
+	healthy := make([]*MetaPartition, 0, len(rwPartitions))
+	for _, mp := range rwPartitions {
+		if !mw.isPartitionBroken(mp.PartitionID) {
+			healthy = append(healthy, mp)
+		}
+	}
+	if len(healthy) > 0 {
+		return healthy
+	}
 	return rwPartitions
 }
 
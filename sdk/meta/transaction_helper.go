@@ -99,30 +99,36 @@ func NewDeleteTransaction(
 func NewRenameTransaction(srcMp *MetaPartition, srcDenParentID uint64, srcName string,
 	dstMp *MetaPartition, dstDenParentID uint64, dstName string, txTimeout int64) (tx *Transaction, err error) {
 	tx = NewTransaction(txTimeout, proto.TxTypeRename)
+	if err = RenameTxAddDentries(tx, srcMp, srcDenParentID, srcName, dstMp, dstDenParentID, dstName); err != nil {
+		return nil, err
+	}
+
+	if log.EnableDebug() {
+		log.LogDebugf("NewRenameTransaction: txInfo(%v)", tx.txInfo)
+	}
+	return tx, nil
+}
 
+// RenameTxAddDentries adds the source and destination dentries of a rename to tx,
+// a transaction may carry several renames.
+func RenameTxAddDentries(tx *Transaction, srcMp *MetaPartition, srcDenParentID uint64, srcName string,
+	dstMp *MetaPartition, dstDenParentID uint64, dstName string) (err error) {
 	srcMembers := getMembersFromMp(srcMp)
 	if srcMembers == "" {
-		return nil, fmt.Errorf("invalid parent metapartition")
+		return fmt.Errorf("invalid parent metapartition")
 	}
 
 	dstMembers := getMembersFromMp(dstMp)
 	if dstMembers == "" {
-		return nil, fmt.Errorf("invalid parent metapartition")
+		return fmt.Errorf("invalid parent metapartition")
 	}
 
 	txSrcDentryInfo := proto.NewTxDentryInfo(srcMembers, srcDenParentID, srcName, srcMp.PartitionID)
 	txDstDentryInfo := proto.NewTxDentryInfo(dstMembers, dstDenParentID, dstName, dstMp.PartitionID)
 	if err = tx.AddDentry(txSrcDentryInfo); err != nil {
-		return nil, err
-	}
-	if err = tx.AddDentry(txDstDentryInfo); err != nil {
-		return nil, err
+		return
 	}
-
-	if log.EnableDebug() {
-		log.LogDebugf("NewRenameTransaction: txInfo(%v)", tx.txInfo)
-	}
-	return tx, nil
+	return tx.AddDentry(txDstDentryInfo)
 }
 
 func RenameTxReplaceInode(tx *Transaction, inoMp *MetaPartition, ino uint64) (err error) {
@@ -216,7 +216,7 @@ func (mw *MetaWrapper) updateMetaPartitions() error {
 
 func (mw *MetaWrapper) forceUpdateMetaPartitions() error {
 	// Only one forceUpdateMetaPartition is allowed in a specific period of time.
-	if ok := mw.forceUpdateLimit.AllowN(time.Now(), MinForceUpdateMetaPartitionsInterval); !ok {
+	if ok := mw.forceUpdateLimit.Allow(); !ok {
 		return errors.New("Force update meta partitions throttled!")
 	}
 
@@ -234,34 +234,58 @@ func (mw *MetaWrapper) triggerAndWaitForceUpdate() {
 	mw.partMutex.Unlock()
 }
 
+// refreshTimer is the timer of the periodic refresh, it is a time.Timer out of the
+// tests.
+type refreshTimer interface {
+	Chan() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+type sysRefreshTimer struct {
+	*time.Timer
+}
+
+func (t sysRefreshTimer) Chan() <-chan time.Time {
+	return t.C
+}
+
 func (mw *MetaWrapper) refresh() {
+	mw.refreshLoop(sysRefreshTimer{time.NewTimer(mw.refreshInterval)}, mw.refreshMetaInfo)
+}
+
+func (mw *MetaWrapper) refreshMetaInfo() {
+	var err error
+	if err = mw.updateMetaPartitions(); err != nil {
+		mw.onAsyncTaskError.OnError(err)
+		log.LogErrorf("updateMetaPartition fail cause: %v", err)
+	}
+	if err = mw.updateVolStatInfo(); err != nil {
+		mw.onAsyncTaskError.OnError(err)
+		log.LogErrorf("updateVolStatInfo fail cause: %v", err)
+	}
+	if err = mw.updateDirChildrenNumLimit(); err != nil {
+		mw.onAsyncTaskError.OnError(err)
+		log.LogErrorf("updateDirChildrenNumLimit fail cause: %v", err)
+	}
+}
+
+func (mw *MetaWrapper) refreshLoop(t refreshTimer, update func()) {
 	var err error
 
-	t := time.NewTimer(RefreshMetaPartitionsInterval)
 	defer t.Stop()
 
 	for {
 		select {
-		case <-t.C:
-			if err = mw.updateMetaPartitions(); err != nil {
-				mw.onAsyncTaskError.OnError(err)
-				log.LogErrorf("updateMetaPartition fail cause: %v", err)
-			}
-			if err = mw.updateVolStatInfo(); err != nil {
-				mw.onAsyncTaskError.OnError(err)
-				log.LogErrorf("updateVolStatInfo fail cause: %v", err)
-			}
-			if err = mw.updateDirChildrenNumLimit(); err != nil {
-				mw.onAsyncTaskError.OnError(err)
-				log.LogErrorf("updateDirChildrenNumLimit fail cause: %v", err)
-			}
-			t.Reset(RefreshMetaPartitionsInterval)
+		case <-t.Chan():
+			update()
+			t.Reset(mw.refreshInterval)
 		case <-mw.forceUpdate:
 			log.LogInfof("Start forceUpdateMetaPartitions")
 			mw.partMutex.Lock()
 			if err = mw.forceUpdateMetaPartitions(); err == nil {
 				if err = mw.updateVolStatInfo(); err == nil {
-					t.Reset(RefreshMetaPartitionsInterval)
+					t.Reset(mw.refreshInterval)
 				}
 			}
 			mw.partMutex.Unlock()
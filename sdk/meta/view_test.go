@@ -0,0 +1,87 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// fakeRefreshTimer fires when the test sends to c and records the resets.
+type fakeRefreshTimer struct {
+	c      chan time.Time
+	resets chan time.Duration
+}
+
+func (t *fakeRefreshTimer) Chan() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeRefreshTimer) Reset(d time.Duration) bool {
+	t.resets <- d
+	return true
+}
+
+func (t *fakeRefreshTimer) Stop() bool {
+	return true
+}
+
+func TestRefreshInterval(t *testing.T) {
+	require.Equal(t, RefreshMetaPartitionsInterval, getRefreshInterval(0))
+	require.Equal(t, MinRefreshMetaPartitionsInterval, getRefreshInterval(time.Second))
+	require.Equal(t, time.Minute, getRefreshInterval(time.Minute))
+
+	mw := &MetaWrapper{
+		closeCh:         make(chan struct{}),
+		refreshInterval: getRefreshInterval(15 * time.Second),
+	}
+	timer := &fakeRefreshTimer{c: make(chan time.Time), resets: make(chan time.Duration, 10)}
+	updated := make(chan struct{}, 10)
+	done := make(chan struct{})
+	go func() {
+		mw.refreshLoop(timer, func() { updated <- struct{}{} })
+		close(done)
+	}()
+
+	// every tick updates the partitions once and rearms the timer with the interval
+	for i := 0; i < 3; i++ {
+		timer.c <- time.Now()
+		select {
+		case <-updated:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no update after the tick")
+		}
+		require.Equal(t, 15*time.Second, <-timer.resets)
+	}
+	require.Len(t, updated, 0)
+
+	close(mw.closeCh)
+	<-done
+}
+
+func TestForceUpdateInterval(t *testing.T) {
+	limiter := rate.NewLimiter(rate.Every(getForceUpdateInterval(0)), 1)
+	now := time.Now()
+	require.True(t, limiter.AllowN(now, 1))
+	require.False(t, limiter.AllowN(now.Add(4*time.Second), 1))
+	require.True(t, limiter.AllowN(now.Add(MinForceUpdateMetaPartitionsInterval*time.Second), 1))
+
+	limiter = rate.NewLimiter(rate.Every(getForceUpdateInterval(time.Second)), 1)
+	require.True(t, limiter.AllowN(now, 1))
+	require.True(t, limiter.AllowN(now.Add(time.Second), 1))
+}
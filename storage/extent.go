@@ -40,9 +40,62 @@ const (
 )
 
 const (
-	ExtentMaxSize = 1024 * 1024 * 1024 * 1024 * 4 // 4TB
+	DefaultExtentMaxSize = 1024 * 1024 * 1024 * 1024 * 4 // 4TB
 )
 
+// extentMaxSize bounds the end of the writes to an extent.
+var extentMaxSize int64 = DefaultExtentMaxSize
+
+// SetExtentMaxSize sets the size the writes can not grow an extent beyond. It only
+// bounds the new writes, the extents already larger stay readable.
+func SetExtentMaxSize(size int64) error {
+	if size < util.ExtentSize || size%util.PageSize != 0 {
+		return fmt.Errorf("extent max size(%v) should be a multiple of %v and at least %v", size, util.PageSize, util.ExtentSize)
+	}
+	atomic.StoreInt64(&extentMaxSize, size)
+	return nil
+}
+
+func GetExtentMaxSize() int64 {
+	return atomic.LoadInt64(&extentMaxSize)
+}
+
+// verifyReadCrc makes the reads of normal extents check the data against the block
+// crcs of the header.
+var verifyReadCrc int32
+
+// SetVerifyReadCrc enables the check of the data read against the block crcs. It
+// costs a crc computation per block read, and a disk read for the blocks read in part.
+func SetVerifyReadCrc(enable bool) {
+	var val int32
+	if enable {
+		val = 1
+	}
+	atomic.StoreInt32(&verifyReadCrc, val)
+}
+
+func IsVerifyReadCrc() bool {
+	return atomic.LoadInt32(&verifyReadCrc) == 1
+}
+
+// preallocateExtents makes InitToFS allocate the disk space of a normal extent up
+// front, so that it is laid out contiguously on the disk.
+var preallocateExtents int32
+
+// SetPreallocateExtents enables the preallocation of the ExtentSize bytes of the new
+// normal extents. The file size is kept, the space is only reserved.
+func SetPreallocateExtents(enable bool) {
+	var val int32
+	if enable {
+		val = 1
+	}
+	atomic.StoreInt32(&preallocateExtents, val)
+}
+
+func IsPreallocateExtents() bool {
+	return atomic.LoadInt32(&preallocateExtents) == 1
+}
+
 type ExtentInfo struct {
 	FileID              uint64 `json:"fileId"`
 	Size                uint64 `json:"size"`
@@ -156,6 +209,11 @@ func (e *Extent) InitToFS() (err error) {
 		e.dataSize = 0
 		return
 	}
+	if IsPreallocateExtents() {
+		if perr := fallocate(int(e.file.Fd()), util.FallocFLKeepSize, 0, util.ExtentSize); perr != nil {
+			log.LogWarnf("action[InitToFS] path %v preallocate err %v", e.filePath, perr)
+		}
+	}
 	atomic.StoreInt64(&e.modifyTime, time.Now().Unix())
 	atomic.StoreInt64(&e.accessTime, time.Now().Unix())
 	e.dataSize = 0
@@ -263,7 +321,7 @@ func (e *Extent) WriteTiny(data []byte, offset, size int64, crc uint32, writeTyp
 	e.Lock()
 	defer e.Unlock()
 	index := offset + size
-	if index >= ExtentMaxSize {
+	if index >= GetExtentMaxSize() {
 		return ExtentIsFullError
 	}
 
@@ -320,8 +378,13 @@ func (e *Extent) Write(data []byte, offset, size int64, crc uint32, writeType in
 		return
 	}
 
-	if _, err = e.file.WriteAt(data[:size], int64(offset)); err != nil {
-		log.LogErrorf("action[Extent.Write] offset %v size %v writeType %v err %v", offset, size, writeType, err)
+	var written int
+	if written, err = e.file.WriteAt(data[:size], int64(offset)); err != nil {
+		log.LogErrorf("action[Extent.Write] path %v offset %v size %v writeType %v written %v err %v",
+			e.filePath, offset, size, writeType, written, err)
+		if written > 0 {
+			e.rollbackPartialWrite(writeType, offset, int64(written), crcFunc)
+		}
 		return
 	}
 
@@ -367,11 +430,47 @@ func (e *Extent) Write(data []byte, offset, size int64, crc uint32, writeType in
 	return
 }
 
-// Read reads data from an extent.
-func (e *Extent) Read(data []byte, offset, size int64, isRepairRead bool) (crc uint32, err error) {
+// rollbackPartialWrite restores the extent to a consistent state after WriteAt
+// failed having written only part of the data. Appended bytes are dropped so the
+// file ends at the previous watermark again, while for an overwrite the crc of the
+// touched blocks is cleared and will be recomputed from the data on disk.
+func (e *Extent) rollbackPartialWrite(writeType int, offset, written int64, crcFunc UpdateCrcFunc) {
+	var err error
+	switch {
+	case IsAppendWrite(writeType):
+		if e.snapshotDataOff == util.ExtentSize {
+			err = e.file.Truncate(e.dataSize)
+		} else {
+			err = fallocate(int(e.file.Fd()), util.FallocFLPunchHole|util.FallocFLKeepSize, offset, written)
+		}
+	case IsAppendRandomWrite(writeType):
+		if uint64(offset) >= e.snapshotDataOff {
+			err = e.file.Truncate(int64(e.snapshotDataOff))
+		} else {
+			err = fallocate(int(e.file.Fd()), util.FallocFLPunchHole|util.FallocFLKeepSize, offset, written)
+		}
+	default:
+		for blockNo := offset / util.BlockSize; blockNo <= (offset+written-1)/util.BlockSize; blockNo++ {
+			if err = crcFunc(e, int(blockNo), 0); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.LogErrorf("action[rollbackPartialWrite] path %v offset %v written %v writeType %v err %v",
+			e.filePath, offset, written, writeType, err)
+		return
+	}
+	log.LogWarnf("action[rollbackPartialWrite] path %v offset %v written %v writeType %v dataSize %v snapshotDataOff %v",
+		e.filePath, offset, written, writeType, e.dataSize, e.snapshotDataOff)
+}
+
+// Read reads data from an extent and returns its crc computed with crcAlg, the
+// algorithm the reader verifies the data with.
+func (e *Extent) Read(data []byte, offset, size int64, isRepairRead bool, crcAlg uint8) (crc uint32, err error) {
 	log.LogDebugf("action[Extent.read] offset %v size %v extent %v", offset, size, e)
 	if IsTinyExtent(e.extentID) {
-		return e.ReadTiny(data, offset, size, isRepairRead)
+		return e.ReadTiny(data, offset, size, isRepairRead, crcAlg)
 	}
 
 	if err = e.checkReadOffsetAndSize(offset, size); err != nil {
@@ -386,17 +485,84 @@ func (e *Extent) Read(data []byte, offset, size int64, isRepairRead bool) (crc u
 			offset, size, err, rSize)
 		return
 	}
-	crc = crc32.ChecksumIEEE(data)
+	if IsVerifyReadCrc() {
+		if err = e.verifyBlockCrc(data[:size], offset); err != nil {
+			return
+		}
+	}
+	crc = proto.Checksum(crcAlg, data)
 	return
 }
 
+// verifyBlockCrc checks the blocks overlapped by the data read at offset against
+// their crc in the header, the blocks whose crc is not known yet are skipped. A
+// stored crc covers a whole block, so a block read in part is read again in full.
+// A mismatch may come from a write racing with the read, it is only reported if
+// the block still mismatches when read under the lock of the extent.
+func (e *Extent) verifyBlockCrc(data []byte, offset int64) (err error) {
+	end := offset + int64(len(data))
+	for blockNo := offset / util.BlockSize; blockNo*util.BlockSize < end; blockNo++ {
+		blockStart := blockNo * util.BlockSize
+		blockEnd := blockStart + util.BlockSize
+		if blockStart >= offset && blockEnd <= end {
+			if e.blockCrcMatch(blockNo, data[blockStart-offset:blockEnd-offset]) {
+				continue
+			}
+		} else if e.blockCrcMatch(blockNo, nil) {
+			continue
+		}
+		e.Lock()
+		match := e.blockCrcMatch(blockNo, nil)
+		e.Unlock()
+		if !match {
+			log.LogErrorf("action[verifyBlockCrc] path %v extent %v blockNo %v crc mismatch", e.filePath, e.extentID, blockNo)
+			return CrcMismatchError
+		}
+	}
+	return nil
+}
+
+// blockCrcMatch returns false if the block data, read from the disk if nil, does not
+// match the crc of the block in the header.
+func (e *Extent) blockCrcMatch(blockNo int64, data []byte) bool {
+	header := e.header
+	if int((blockNo+1)*util.PerBlockCrcSize) > len(header) {
+		return true
+	}
+	blockCrc := binary.BigEndian.Uint32(header[blockNo*util.PerBlockCrcSize : (blockNo+1)*util.PerBlockCrcSize])
+	if blockCrc == 0 {
+		return true
+	}
+	if data == nil {
+		size := int64(util.BlockSize)
+		extSize := e.Size()
+		if blockNo*util.BlockSize >= util.ExtentSize {
+			extSize = int64(e.snapshotDataOff)
+		}
+		if extSize-blockNo*util.BlockSize < size {
+			size = extSize - blockNo*util.BlockSize
+		}
+		if size <= 0 {
+			return true
+		}
+		data = make([]byte, size)
+		n, err := e.file.ReadAt(data, blockNo*util.BlockSize)
+		if err != nil && err != io.EOF {
+			log.LogWarnf("action[blockCrcMatch] path %v blockNo %v err %v", e.filePath, blockNo, err)
+			return true
+		}
+		data = data[:n]
+	}
+	return crc32.ChecksumIEEE(data) == blockCrc
+}
+
 // ReadTiny read data from a tiny extent.
-func (e *Extent) ReadTiny(data []byte, offset, size int64, isRepairRead bool) (crc uint32, err error) {
+func (e *Extent) ReadTiny(data []byte, offset, size int64, isRepairRead bool, crcAlg uint8) (crc uint32, err error) {
 	_, err = e.file.ReadAt(data[:size], offset)
 	if isRepairRead && err == io.EOF {
 		err = nil
 	}
-	crc = crc32.ChecksumIEEE(data[:size])
+	crc = proto.Checksum(crcAlg, data[:size])
 
 	return
 }
@@ -426,6 +592,9 @@ func (e *Extent) checkWriteOffsetAndSize(writeType int, offset, size int64) erro
 		if offset < util.ExtentSize || size == 0 {
 			return NewParameterMismatchErr(fmt.Sprintf("writeType=%v offset=%v size=%v", writeType, offset, size))
 		}
+		if offset+size > GetExtentMaxSize() {
+			return ExtentIsFullError
+		}
 	}
 	return nil
 }
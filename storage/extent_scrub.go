@@ -0,0 +1,234 @@
+// Copyright 2018 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ExtScrubFileName = "EXTENT_SCRUB"
+
+	// scrubPersistBatch is the number of extents scrubbed between two saves of the
+	// scrub times.
+	scrubPersistBatch = 64
+)
+
+// extentScrubber keeps the time each extent of the store was last scrubbed and the
+// blocks found corrupt. The times are persisted, so a restarted data node goes on
+// with the extents scrubbed the longest ago instead of starting over.
+type extentScrubber struct {
+	sync.Mutex
+	loaded  bool
+	times   map[uint64]int64
+	corrupt map[uint64][]int64
+}
+
+// loadScrubTimes reads the scrub times once. The caller must hold the lock.
+func (s *ExtentStore) loadScrubTimes() {
+	sc := &s.scrub
+	if sc.loaded {
+		return
+	}
+	sc.times = make(map[uint64]int64)
+	sc.corrupt = make(map[uint64][]int64)
+	sc.loaded = true
+	data, err := ioutil.ReadFile(path.Join(s.dataPath, ExtScrubFileName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.LogWarnf("action[loadScrubTimes] partition %v err %v", s.partitionID, err)
+		}
+		return
+	}
+	if err = json.Unmarshal(data, &sc.times); err != nil {
+		log.LogWarnf("action[loadScrubTimes] partition %v unmarshal err %v", s.partitionID, err)
+		sc.times = make(map[uint64]int64)
+	}
+}
+
+// persistScrubTimes saves the scrub times. The caller must hold the lock.
+func (s *ExtentStore) persistScrubTimes() (err error) {
+	data, err := json.Marshal(s.scrub.times)
+	if err != nil {
+		return
+	}
+	fileName := path.Join(s.dataPath, ExtScrubFileName)
+	tmpFileName := fileName + ".tmp"
+	fp, err := os.OpenFile(tmpFileName, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
+	if err != nil {
+		return
+	}
+	defer func() {
+		fp.Close()
+		os.Remove(tmpFileName)
+	}()
+	if _, err = fp.Write(data); err != nil {
+		return
+	}
+	if err = fp.Sync(); err != nil {
+		return
+	}
+	return os.Rename(tmpFileName, fileName)
+}
+
+// ScrubExtents checks the blocks of the normal extents not scrubbed for the given
+// interval against their crc, the extents scrubbed the longest ago first. The reads
+// are throttled by the limiter, whose burst must not be less than util.BlockSize.
+// It returns the corrupt blocks of the extents found corrupt by this pass.
+func (s *ExtentStore) ScrubExtents(ctx context.Context, interval time.Duration, limiter *rate.Limiter) (corrupt map[uint64][]int64, err error) {
+	corrupt = make(map[uint64][]int64)
+	if !proto.IsNormalDp(s.partitionType) {
+		return
+	}
+
+	extentIDs := make([]uint64, 0)
+	s.eiMutex.RLock()
+	for id, ei := range s.extentInfoMap {
+		if !IsTinyExtent(id) && !ei.IsDeleted {
+			extentIDs = append(extentIDs, id)
+		}
+	}
+	s.eiMutex.RUnlock()
+
+	sc := &s.scrub
+	sc.Lock()
+	s.loadScrubTimes()
+	times := make(map[uint64]int64, len(extentIDs))
+	for _, id := range extentIDs {
+		times[id] = sc.times[id]
+	}
+	// drop the records of the deleted extents
+	sc.times = times
+	for id := range sc.corrupt {
+		if _, ok := times[id]; !ok {
+			delete(sc.corrupt, id)
+		}
+	}
+	sc.Unlock()
+
+	now := time.Now().Unix()
+	todo := extentIDs[:0]
+	for _, id := range extentIDs {
+		if now-times[id] >= int64(interval/time.Second) {
+			todo = append(todo, id)
+		}
+	}
+	sort.Slice(todo, func(i, j int) bool {
+		return times[todo[i]] < times[todo[j]]
+	})
+
+	defer func() {
+		sc.Lock()
+		if perr := s.persistScrubTimes(); perr != nil {
+			log.LogWarnf("action[ScrubExtents] partition %v persist err %v", s.partitionID, perr)
+		}
+		sc.Unlock()
+	}()
+	for i, id := range todo {
+		var badBlocks []int64
+		if badBlocks, err = s.ScrubExtent(ctx, id, limiter); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.LogWarnf("action[ScrubExtents] partition %v extent %v err %v", s.partitionID, id, err)
+			err = nil
+			continue
+		}
+		sc.Lock()
+		sc.times[id] = time.Now().Unix()
+		if len(badBlocks) > 0 {
+			sc.corrupt[id] = badBlocks
+			corrupt[id] = badBlocks
+		} else {
+			delete(sc.corrupt, id)
+		}
+		if (i+1)%scrubPersistBatch == 0 {
+			if perr := s.persistScrubTimes(); perr != nil {
+				log.LogWarnf("action[ScrubExtents] partition %v persist err %v", s.partitionID, perr)
+			}
+		}
+		sc.Unlock()
+	}
+	return
+}
+
+// ScrubExtent reads the blocks of the extent whose crc is known and returns the ones
+// not matching it. Like for verifyBlockCrc a block is only reported if it still
+// mismatches when read under the lock of the extent.
+func (s *ExtentStore) ScrubExtent(ctx context.Context, extentID uint64, limiter *rate.Limiter) (badBlocks []int64, err error) {
+	s.eiMutex.RLock()
+	ei := s.extentInfoMap[extentID]
+	s.eiMutex.RUnlock()
+	e, err := s.extentWithHeader(ei)
+	if err != nil {
+		return
+	}
+
+	end := e.Size()
+	if e.snapshotDataOff > util.ExtentSize {
+		end = int64(e.snapshotDataOff)
+	}
+	for blockNo := int64(0); blockNo*util.BlockSize < end; blockNo++ {
+		if err = limiter.WaitN(ctx, util.BlockSize); err != nil {
+			return
+		}
+		if e.blockCrcMatch(blockNo, nil) {
+			continue
+		}
+		e.Lock()
+		match := e.blockCrcMatch(blockNo, nil)
+		e.Unlock()
+		if !match {
+			log.LogErrorf("action[ScrubExtent] path %v extent %v blockNo %v crc mismatch", e.filePath, extentID, blockNo)
+			badBlocks = append(badBlocks, blockNo)
+		}
+	}
+	return
+}
+
+// GetCorruptExtents returns the corrupt blocks of the extents found corrupt by the
+// scrubs so far.
+func (s *ExtentStore) GetCorruptExtents() (corrupt map[uint64][]int64) {
+	sc := &s.scrub
+	sc.Lock()
+	defer sc.Unlock()
+	s.loadScrubTimes()
+	corrupt = make(map[uint64][]int64, len(sc.corrupt))
+	for id, blocks := range sc.corrupt {
+		corrupt[id] = append([]int64(nil), blocks...)
+	}
+	return
+}
+
+// GetScrubTime returns the unix time the extent was last scrubbed, 0 if never.
+func (s *ExtentStore) GetScrubTime(extentID uint64) int64 {
+	sc := &s.scrub
+	sc.Lock()
+	defer sc.Unlock()
+	s.loadScrubTimes()
+	return sc.times[extentID]
+}
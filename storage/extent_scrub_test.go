@@ -0,0 +1,88 @@
+// Copyright 2018 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"hash/crc32"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestExtentStoreScrub(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewExtentStore(dir, 1, 1<<30, proto.PartitionTypeNormal, true)
+	require.NoError(t, err)
+
+	data := make([]byte, util.BlockSize)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	extentIDs := make([]uint64, 0)
+	for i := 0; i < 2; i++ {
+		id, err := s.NextExtentID()
+		require.NoError(t, err)
+		require.NoError(t, s.Create(id))
+		for j := int64(0); j < 2; j++ {
+			_, err = s.Write(id, j*util.BlockSize, util.BlockSize, data, crc32.ChecksumIEEE(data), AppendWriteType, false)
+			require.NoError(t, err)
+		}
+		extentIDs = append(extentIDs, id)
+	}
+
+	// corrupt a byte of the second block of the second extent on the disk
+	fp, err := os.OpenFile(path.Join(dir, strconv.FormatUint(extentIDs[1], 10)), os.O_RDWR, 0666)
+	require.NoError(t, err)
+	_, err = fp.WriteAt([]byte{^data[300]}, util.BlockSize+300)
+	require.NoError(t, err)
+	fp.Close()
+
+	limiter := rate.NewLimiter(rate.Inf, util.BlockSize)
+	corrupt, err := s.ScrubExtents(context.Background(), time.Hour, limiter)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]int64{extentIDs[1]: {1}}, corrupt)
+	require.Equal(t, corrupt, s.GetCorruptExtents())
+	for _, id := range extentIDs {
+		require.NotZero(t, s.GetScrubTime(id))
+	}
+
+	// the extents scrubbed within the interval are skipped
+	corrupt, err = s.ScrubExtents(context.Background(), time.Hour, limiter)
+	require.NoError(t, err)
+	require.Empty(t, corrupt)
+	require.Len(t, s.GetCorruptExtents(), 1)
+
+	// the scrub times survive a restart
+	scrubTime := s.GetScrubTime(extentIDs[0])
+	s.Close()
+	s, err = NewExtentStore(dir, 1, 1<<30, proto.PartitionTypeNormal, false)
+	require.NoError(t, err)
+	defer s.Close()
+	require.Equal(t, scrubTime, s.GetScrubTime(extentIDs[0]))
+
+	// a canceled scrub stops
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.ScrubExtents(ctx, 0, rate.NewLimiter(1, util.BlockSize))
+	require.Error(t, err)
+}
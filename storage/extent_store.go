@@ -135,6 +135,7 @@ type ExtentStore struct {
 	partitionType                     int
 	ApplyId                           uint64
 	ApplyIdMutex                      sync.RWMutex
+	scrub                             extentScrubber
 }
 
 func MkdirAll(name string) (err error) {
@@ -432,8 +433,8 @@ func IsTinyExtent(extentID uint64) bool {
 	return extentID >= TinyExtentStartID && extentID < TinyExtentStartID+TinyExtentCount
 }
 
-// Read reads the extent based on the given id.
-func (s *ExtentStore) Read(extentID uint64, offset, size int64, nbuf []byte, isRepairRead bool) (crc uint32, err error) {
+// Read reads the extent based on the given id, crc is computed with crcAlg.
+func (s *ExtentStore) Read(extentID uint64, offset, size int64, nbuf []byte, isRepairRead bool, crcAlg uint8) (crc uint32, err error) {
 	var e *Extent
 	s.eiMutex.RLock()
 	ei := s.extentInfoMap[extentID]
@@ -453,7 +454,7 @@ func (s *ExtentStore) Read(extentID uint64, offset, size int64, nbuf []byte, isR
 	//if err = s.checkOffsetAndSize(extentID, offset, size); err != nil {
 	//	return
 	//}
-	crc, err = e.Read(nbuf, offset, size, isRepairRead)
+	crc, err = e.Read(nbuf, offset, size, isRepairRead, crcAlg)
 
 	return
 }
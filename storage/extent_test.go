@@ -0,0 +1,227 @@
+// Copyright 2018 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/brahma-adshonor/gohook"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/stretchr/testify/require"
+)
+
+//go:noinline
+func shortWriteAt(f *os.File, b []byte, off int64) (n int, err error) {
+	n, _ = shortWriteAtTramp(f, b[:len(b)/2], off)
+	return n, syscall.ENOSPC
+}
+
+//go:noinline
+func shortWriteAtTramp(f *os.File, b []byte, off int64) (n int, err error) {
+	return 0, nil
+}
+
+func TestExtentWriteRollbackPartialWrite(t *testing.T) {
+	e := NewExtentInCore(path.Join(t.TempDir(), "1025"), 1025)
+	require.NoError(t, e.InitToFS())
+	defer e.Close()
+
+	var crcs = make(map[int]uint32)
+	crcFunc := func(e *Extent, blockNo int, crc uint32) error {
+		crcs[blockNo] = crc
+		return nil
+	}
+
+	data := make([]byte, util.BlockSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	crc := crc32.ChecksumIEEE(data)
+	_, err := e.Write(data, 0, util.BlockSize, crc, AppendWriteType, false, crcFunc, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, util.BlockSize, e.Size())
+
+	require.NoError(t, gohook.HookMethod(e.file, "WriteAt", shortWriteAt, shortWriteAtTramp))
+	_, err = e.Write(data, util.BlockSize, util.BlockSize, crc, AppendWriteType, false, crcFunc, nil)
+	require.NoError(t, gohook.UnHookMethod(e.file, "WriteAt"))
+	require.Error(t, err)
+
+	// the half written block must be gone and the watermark must not move
+	require.EqualValues(t, util.BlockSize, e.Size())
+	info, err := e.file.Stat()
+	require.NoError(t, err)
+	require.EqualValues(t, util.BlockSize, info.Size())
+	require.Equal(t, crc, crcs[0])
+
+	// the extent still accepts appends at its previous watermark
+	_, err = e.Write(data, util.BlockSize, util.BlockSize, crc, AppendWriteType, false, crcFunc, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 2*util.BlockSize, e.Size())
+
+	// a short overwrite invalidates the crc of the touched block
+	require.NoError(t, gohook.HookMethod(e.file, "WriteAt", shortWriteAt, shortWriteAtTramp))
+	_, err = e.Write(data, util.BlockSize, util.BlockSize, crc, RandomWriteType, false, crcFunc, nil)
+	require.NoError(t, gohook.UnHookMethod(e.file, "WriteAt"))
+	require.Error(t, err)
+	require.EqualValues(t, 2*util.BlockSize, e.Size())
+	require.EqualValues(t, 0, crcs[1])
+}
+
+func TestExtentReadCrcAlg(t *testing.T) {
+	e := NewExtentInCore(path.Join(t.TempDir(), "1026"), 1026)
+	require.NoError(t, e.InitToFS())
+	defer e.Close()
+
+	crcs := make(map[int]uint32)
+	crcFunc := func(e *Extent, blockNo int, crc uint32) error {
+		crcs[blockNo] = crc
+		return nil
+	}
+	data := make([]byte, util.BlockSize)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	_, err := e.Write(data, 0, util.BlockSize, crc32.ChecksumIEEE(data), AppendWriteType, false, crcFunc, nil)
+	require.NoError(t, err)
+
+	for _, alg := range []uint8{proto.CrcAlgIEEE, proto.CrcAlgCastagnoli} {
+		buf := make([]byte, util.BlockSize)
+		crc, err := e.Read(buf, 0, util.BlockSize, false, alg)
+		require.NoError(t, err)
+		require.Equal(t, data, buf)
+		require.Equal(t, proto.Checksum(alg, data), crc)
+	}
+	require.NotEqual(t, proto.Checksum(proto.CrcAlgIEEE, data), proto.Checksum(proto.CrcAlgCastagnoli, data))
+
+	// the data corrupted on the disk no longer matches the crc of the writer
+	_, err = e.file.WriteAt([]byte{^data[100]}, 100)
+	require.NoError(t, err)
+	for _, alg := range []uint8{proto.CrcAlgIEEE, proto.CrcAlgCastagnoli} {
+		buf := make([]byte, util.BlockSize)
+		crc, err := e.Read(buf, 0, util.BlockSize, false, alg)
+		require.NoError(t, err)
+		require.NotEqual(t, proto.Checksum(alg, data), crc)
+	}
+}
+
+func TestExtentMaxSize(t *testing.T) {
+	require.EqualValues(t, DefaultExtentMaxSize, GetExtentMaxSize())
+	require.Error(t, SetExtentMaxSize(0))
+	require.Error(t, SetExtentMaxSize(util.ExtentSize-util.PageSize))
+	require.Error(t, SetExtentMaxSize(2*util.ExtentSize+1))
+
+	maxSize := int64(2 * util.ExtentSize)
+	require.NoError(t, SetExtentMaxSize(maxSize))
+	defer SetExtentMaxSize(DefaultExtentMaxSize)
+
+	e := NewExtentInCore(path.Join(t.TempDir(), "1"), 1)
+	require.NoError(t, e.InitToFS())
+	defer e.Close()
+
+	data := make([]byte, util.PageSize)
+	require.NoError(t, e.WriteTiny(data, 0, util.PageSize, 0, AppendWriteType, false))
+	require.Equal(t, ExtentIsFullError, e.WriteTiny(data, maxSize-util.PageSize, util.PageSize, 0, AppendWriteType, false))
+	require.Equal(t, ExtentIsFullError, e.WriteTiny(data, maxSize, util.PageSize, 0, RandomWriteType, false))
+
+	// writes beyond the max to the snapshot part of a normal extent are refused too
+	ne := NewExtentInCore(path.Join(t.TempDir(), "1025"), 1025)
+	require.NoError(t, ne.InitToFS())
+	defer ne.Close()
+	require.NoError(t, ne.checkWriteOffsetAndSize(AppendRandomWriteType, maxSize-util.PageSize, util.PageSize))
+	require.Equal(t, ExtentIsFullError, ne.checkWriteOffsetAndSize(AppendRandomWriteType, maxSize-util.PageSize, 2*util.PageSize))
+}
+
+func TestExtentVerifyReadCrc(t *testing.T) {
+	e := NewExtentInCore(path.Join(t.TempDir(), "1027"), 1027)
+	require.NoError(t, e.InitToFS())
+	defer e.Close()
+	e.header = make([]byte, util.BlockHeaderSize)
+	crcFunc := func(e *Extent, blockNo int, crc uint32) error {
+		binary.BigEndian.PutUint32(e.header[blockNo*util.PerBlockCrcSize:], crc)
+		return nil
+	}
+
+	data := make([]byte, util.BlockSize)
+	for i := range data {
+		data[i] = byte(i * 3)
+	}
+	for i := int64(0); i < 2; i++ {
+		_, err := e.Write(data, i*util.BlockSize, util.BlockSize, crc32.ChecksumIEEE(data), AppendWriteType, false, crcFunc, nil)
+		require.NoError(t, err)
+	}
+	// the crc of a block written in part is not known
+	_, err := e.Write(data, 2*util.BlockSize, 100, crc32.ChecksumIEEE(data[:100]), AppendWriteType, false, crcFunc, nil)
+	require.NoError(t, err)
+
+	SetVerifyReadCrc(true)
+	defer SetVerifyReadCrc(false)
+	read := func(offset, size int64) error {
+		_, err := e.Read(make([]byte, size), offset, size, false, proto.CrcAlgIEEE)
+		return err
+	}
+	require.NoError(t, read(0, 2*util.BlockSize))
+	require.NoError(t, read(100, 4096))
+	require.NoError(t, read(util.BlockSize-10, util.BlockSize+100))
+
+	// corrupt a byte of the second block on the disk
+	_, err = e.file.WriteAt([]byte{^data[200]}, util.BlockSize+200)
+	require.NoError(t, err)
+	require.NoError(t, read(0, util.BlockSize))
+	require.Equal(t, CrcMismatchError, read(0, 2*util.BlockSize))
+	// a read of a part of the block not covering the corrupted byte detects it too
+	require.Equal(t, CrcMismatchError, read(util.BlockSize+4096, 4096))
+	// the block without crc can not be verified
+	_, err = e.file.WriteAt([]byte{1}, 2*util.BlockSize+10)
+	require.NoError(t, err)
+	require.NoError(t, read(2*util.BlockSize, 100))
+
+	SetVerifyReadCrc(false)
+	require.NoError(t, read(0, 2*util.BlockSize))
+}
+
+func TestExtentPreallocate(t *testing.T) {
+	blocks := func(e *Extent) int64 {
+		var st syscall.Stat_t
+		require.NoError(t, syscall.Fstat(int(e.file.Fd()), &st))
+		require.Zero(t, st.Size)
+		return st.Blocks * 512
+	}
+
+	dir := t.TempDir()
+	e := NewExtentInCore(path.Join(dir, "1028"), 1028)
+	require.NoError(t, e.InitToFS())
+	defer e.Close()
+	require.Zero(t, blocks(e))
+
+	SetPreallocateExtents(true)
+	defer SetPreallocateExtents(false)
+	e = NewExtentInCore(path.Join(dir, "1029"), 1029)
+	require.NoError(t, e.InitToFS())
+	defer e.Close()
+	require.GreaterOrEqual(t, blocks(e), int64(util.ExtentSize))
+	require.Zero(t, e.Size())
+
+	// tiny extents are not preallocated
+	e = NewExtentInCore(path.Join(dir, "1"), 1)
+	require.NoError(t, e.InitToFS())
+	defer e.Close()
+	require.Zero(t, blocks(e))
+}
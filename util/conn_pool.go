@@ -17,6 +17,7 @@ package util
 import (
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -104,6 +105,13 @@ func (cp *ConnectPool) PutConnect(c *net.TCPConn, forceClose bool) {
 	if c == nil {
 		return
 	}
+	addr := c.RemoteAddr().String()
+	cp.RLock()
+	pool, ok := cp.pools[addr]
+	cp.RUnlock()
+	if ok {
+		atomic.AddInt64(&pool.inUse, -1)
+	}
 	if forceClose {
 		_ = c.Close()
 		return
@@ -114,10 +122,6 @@ func (cp *ConnectPool) PutConnect(c *net.TCPConn, forceClose bool) {
 		return
 	default:
 	}
-	addr := c.RemoteAddr().String()
-	cp.RLock()
-	pool, ok := cp.pools[addr]
-	cp.RUnlock()
 	if !ok {
 		c.Close()
 		return
@@ -167,6 +171,31 @@ func (cp *ConnectPool) Close() {
 	})
 }
 
+type ConnPoolStat struct {
+	TotalIdle         int                  `json:"totalIdle"`
+	TotalInUse        int                  `json:"totalInUse"`
+	TotalDialFailures int                  `json:"totalDialFailures"`
+	Pools             map[string]*PoolStat `json:"pools"`
+}
+
+// GetStat returns the connections of the pool, in total and per target.
+func (cp *ConnectPool) GetStat() *ConnPoolStat {
+	stat := &ConnPoolStat{
+		Pools: make(map[string]*PoolStat),
+	}
+	cp.RLock()
+	for target, pool := range cp.pools {
+		stat.Pools[target] = pool.GetStat()
+	}
+	cp.RUnlock()
+	for _, poolStat := range stat.Pools {
+		stat.TotalIdle += poolStat.Idle
+		stat.TotalInUse += poolStat.InUse
+		stat.TotalDialFailures += poolStat.DialFailures
+	}
+	return stat
+}
+
 type Pool struct {
 	objects        chan *Object
 	mincap         int
@@ -174,6 +203,17 @@ type Pool struct {
 	target         string
 	timeout        int64
 	connectTimeout int64
+	inUse          int64 // connections got from the pool and not put back
+	dials          int64
+	dialFailures   int64
+}
+
+type PoolStat struct {
+	Addr         string `json:"addr"`
+	Idle         int    `json:"idle"`
+	InUse        int    `json:"inUse"`
+	Dials        int    `json:"dials"`
+	DialFailures int    `json:"dialFailures"`
 }
 
 func NewPool(min, max int, timeout, connectTimeout int64, target string) (p *Pool) {
@@ -190,8 +230,11 @@ func NewPool(min, max int, timeout, connectTimeout int64, target string) (p *Poo
 
 func (p *Pool) initAllConnect() {
 	for i := 0; i < p.mincap; i++ {
+		atomic.AddInt64(&p.dials, 1)
 		c, err := net.Dial("tcp", p.target)
-		if err == nil {
+		if err != nil {
+			atomic.AddInt64(&p.dialFailures, 1)
+		} else {
 			conn := c.(*net.TCPConn)
 			conn.SetKeepAlive(true)
 			conn.SetNoDelay(true)
@@ -243,8 +286,11 @@ func (p *Pool) ReleaseAll() {
 
 func (p *Pool) NewConnect(target string) (c *net.TCPConn, err error) {
 	var connect net.Conn
+	atomic.AddInt64(&p.dials, 1)
 	connect, err = net.DialTimeout("tcp", p.target, time.Duration(p.connectTimeout)*time.Second)
-	if err == nil {
+	if err != nil {
+		atomic.AddInt64(&p.dialFailures, 1)
+	} else {
 		conn := connect.(*net.TCPConn)
 		conn.SetKeepAlive(true)
 		conn.SetNoDelay(true)
@@ -261,13 +307,27 @@ func (p *Pool) GetConnectFromPool() (c *net.TCPConn, err error) {
 		select {
 		case o = <-p.objects:
 		default:
-			return p.NewConnect(p.target)
+			if c, err = p.NewConnect(p.target); err == nil {
+				atomic.AddInt64(&p.inUse, 1)
+			}
+			return
 		}
 		if time.Now().UnixNano()-int64(o.idle) > p.timeout {
 			_ = o.conn.Close()
 			o = nil
 			continue
 		}
+		atomic.AddInt64(&p.inUse, 1)
 		return o.conn, nil
 	}
 }
+
+func (p *Pool) GetStat() *PoolStat {
+	return &PoolStat{
+		Addr:         p.target,
+		Idle:         len(p.objects),
+		InUse:        int(atomic.LoadInt64(&p.inUse)),
+		Dials:        int(atomic.LoadInt64(&p.dials)),
+		DialFailures: int(atomic.LoadInt64(&p.dialFailures)),
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package util
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectPoolStat(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			if _, err := ln.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+	addr := ln.Addr().String()
+
+	cp := NewConnectPool()
+	defer cp.Close()
+	conns := make([]*net.TCPConn, 0)
+	for i := 0; i < 8; i++ {
+		c, err := cp.GetConnect(addr)
+		require.NoError(t, err)
+		conns = append(conns, c)
+	}
+	stat := cp.GetStat()
+	require.Equal(t, 8, stat.TotalInUse)
+	require.Equal(t, 0, stat.TotalIdle)
+	require.Equal(t, 8, stat.Pools[addr].InUse)
+	require.Equal(t, 8, stat.Pools[addr].Dials)
+
+	// put back conns are idle, force closed ones are gone
+	cp.PutConnect(conns[0], false)
+	cp.PutConnect(conns[1], false)
+	cp.PutConnect(conns[2], true)
+	stat = cp.GetStat()
+	require.Equal(t, 5, stat.TotalInUse)
+	require.Equal(t, 2, stat.TotalIdle)
+
+	// the idle conns are reused
+	c, err := cp.GetConnect(addr)
+	require.NoError(t, err)
+	stat = cp.GetStat()
+	require.Equal(t, 6, stat.Pools[addr].InUse)
+	require.Equal(t, 1, stat.Pools[addr].Idle)
+	require.Equal(t, 8, stat.Pools[addr].Dials)
+	cp.PutConnect(c, true)
+
+	// a bad target shows up on its own
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	badAddr := ln2.Addr().String()
+	ln2.Close()
+	_, err = cp.GetConnect(badAddr)
+	require.Error(t, err)
+	stat = cp.GetStat()
+	require.Equal(t, 0, stat.Pools[badAddr].InUse)
+	require.Equal(t, stat.Pools[badAddr].Dials, stat.Pools[badAddr].DialFailures)
+	require.Equal(t, 0, stat.Pools[addr].DialFailures)
+	require.Equal(t, stat.Pools[badAddr].DialFailures, stat.TotalDialFailures)
+}
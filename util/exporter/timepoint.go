@@ -35,6 +35,13 @@ func NewTP(name string) (tp *TimePoint) {
 	return
 }
 
+// NewTPSince returns a time point which started at start.
+func NewTPSince(name string, start time.Time) (tp *TimePoint) {
+	tp = NewTP(name)
+	tp.startTime = start
+	return
+}
+
 func (tp *TimePoint) Set() {
 	if !enabledPrometheus {
 		return
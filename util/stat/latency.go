@@ -0,0 +1,115 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stat
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets is the number of power-of-two microsecond buckets,
+// bucket i holds latencies in [2^(i-1), 2^i) us, the last one is open ended.
+const latencyBuckets = 32
+
+// LatencyHistogram is a lock free histogram of operation latencies.
+// Recording a sample costs a few atomic adds, so it is cheap enough to
+// be used on every client operation.
+type LatencyHistogram struct {
+	totalUs uint64
+	maxUs   uint64
+	buckets [latencyBuckets]uint64
+}
+
+// LatencySnapshot is a point-in-time summary of a LatencyHistogram,
+// percentiles are the upper bound of the bucket they fall in.
+type LatencySnapshot struct {
+	Count uint64 `json:"count"`
+	AvgUs uint64 `json:"avgUs"`
+	P50Us uint64 `json:"p50Us"`
+	P95Us uint64 `json:"p95Us"`
+	P99Us uint64 `json:"p99Us"`
+	MaxUs uint64 `json:"maxUs"`
+}
+
+func latencyBucket(us uint64) int {
+	idx := bits.Len64(us)
+	if idx >= latencyBuckets {
+		idx = latencyBuckets - 1
+	}
+	return idx
+}
+
+// Observe records one sample.
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	var us uint64
+	if d > 0 {
+		us = uint64(d / time.Microsecond)
+	}
+	atomic.AddUint64(&h.buckets[latencyBucket(us)], 1)
+	atomic.AddUint64(&h.totalUs, us)
+	for {
+		max := atomic.LoadUint64(&h.maxUs)
+		if us <= max || atomic.CompareAndSwapUint64(&h.maxUs, max, us) {
+			return
+		}
+	}
+}
+
+// ObserveSince records the time elapsed since start.
+func (h *LatencyHistogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start))
+}
+
+// Snapshot returns the count, average, percentiles and max of the samples
+// recorded so far.
+func (h *LatencyHistogram) Snapshot() (s LatencySnapshot) {
+	var buckets [latencyBuckets]uint64
+	var total uint64
+	for i := range buckets {
+		buckets[i] = atomic.LoadUint64(&h.buckets[i])
+		total += buckets[i]
+	}
+	s.Count = total
+	s.MaxUs = atomic.LoadUint64(&h.maxUs)
+	if total == 0 {
+		return
+	}
+	s.AvgUs = atomic.LoadUint64(&h.totalUs) / total
+	s.P50Us = percentile(buckets[:], total, 50, s.MaxUs)
+	s.P95Us = percentile(buckets[:], total, 95, s.MaxUs)
+	s.P99Us = percentile(buckets[:], total, 99, s.MaxUs)
+	return
+}
+
+func percentile(buckets []uint64, total, pct, max uint64) uint64 {
+	rank := (total*pct + 99) / 100
+	var seen uint64
+	for i, n := range buckets {
+		seen += n
+		if seen < rank {
+			continue
+		}
+		var upper uint64
+		if i > 0 {
+			upper = uint64(1)<<uint(i) - 1
+		}
+		if upper > max || i == len(buckets)-1 {
+			upper = max
+		}
+		return upper
+	}
+	return max
+}
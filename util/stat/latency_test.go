@@ -0,0 +1,56 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := &LatencyHistogram{}
+	if s := h.Snapshot(); s.Count != 0 || s.MaxUs != 0 || s.P99Us != 0 {
+		t.Fatalf("unexpected empty snapshot %+v", s)
+	}
+
+	// 90 fast samples, 9 medium ones and a single slow outlier
+	for i := 0; i < 90; i++ {
+		h.Observe(10 * time.Microsecond)
+	}
+	for i := 0; i < 9; i++ {
+		h.Observe(time.Millisecond)
+	}
+	h.Observe(100 * time.Millisecond)
+
+	s := h.Snapshot()
+	if s.Count != 100 {
+		t.Fatalf("expect count 100, got %v", s.Count)
+	}
+	if s.MaxUs != 100000 {
+		t.Fatalf("expect max 100000us, got %v", s.MaxUs)
+	}
+	if s.P50Us < 10 || s.P50Us >= 20 {
+		t.Fatalf("unexpected p50 %v", s.P50Us)
+	}
+	if s.P95Us < 1000 || s.P95Us >= 2000 {
+		t.Fatalf("unexpected p95 %v", s.P95Us)
+	}
+	if s.P99Us < 1000 || s.P99Us >= 2000 {
+		t.Fatalf("unexpected p99 %v", s.P99Us)
+	}
+	if s.AvgUs != (90*10+9*1000+100000)/100 {
+		t.Fatalf("unexpected avg %v", s.AvgUs)
+	}
+}